@@ -82,7 +82,7 @@ func New(
 
 	// Queue to manage tasks
 	queueMetrics := NewQueueMetrics(r)
-	tasksQueue := queue.NewRequestQueue(cfg.MaxQueuedTasksPerTenant, 0, NewQueueLimits(limits), queueMetrics)
+	tasksQueue := queue.NewRequestQueue(cfg.MaxQueuedTasksPerTenant, 0, NewQueueLimits(limits), queue.PriorityConfig{}, queueMetrics)
 
 	// Clean metrics for inactive users: do not have added tasks to the queue in the last 1 hour
 	activeUsers := util.NewActiveUsersCleanupService(5*time.Minute, 1*time.Hour, func(user string) {
@@ -89,7 +89,7 @@ func New(cfg Config, store bloomshipper.Store, logger log.Logger, reg prometheus
 	}
 
 	queueMetrics := queue.NewMetrics(reg, constants.Loki, metricsSubsystem)
-	g.queue = queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, time.Minute, &fixedQueueLimits{0}, queueMetrics)
+	g.queue = queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, time.Minute, &fixedQueueLimits{0}, queue.PriorityConfig{}, queueMetrics)
 	g.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(queueMetrics.Cleanup)
 
 	if err := g.initServices(); err != nil {
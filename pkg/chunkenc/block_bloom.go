@@ -0,0 +1,142 @@
+package chunkenc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/grafana/regexp"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+	"github.com/grafana/loki/v3/pkg/storage/bloom/v1/filter"
+)
+
+const (
+	// The blooms hold the n-grams of bytes of the lines of the blocks, which are packed into uint32s when building them.
+	blockBloomNGramLen          = 4
+	blockBloomFalsePositiveRate = 0.02
+	// The bits of a bloom are capped to a quarter of the size of its compressed block, the false positive rate
+	// increasing with the number of n-grams beyond it. The blocks whose bloom would be mostly false positives have none.
+	blockBloomMaxSizeRatio         = 0.25
+	blockBloomMaxFalsePositiveRate = 0.5
+)
+
+// buildBlockBloom returns the bloom of the n-grams of the lines of the head block, of at most maxBits bits,
+// or nil if the lines are too short to have any or if they have too many for the bloom to be useful.
+func buildBlockBloom(head HeadBlock, maxBits uint) ([]byte, error) {
+	hb, ok := head.(*unorderedHeadBlock)
+	if !ok {
+		return nil, fmt.Errorf("block blooms can't be built from the %s head block format", head.Format())
+	}
+
+	ngrams := map[uint32]struct{}{}
+	_ = hb.forEntries(
+		context.Background(),
+		logproto.FORWARD,
+		0,
+		math.MaxInt64,
+		func(_ *stats.Context, _ int64, line string, _ symbols) error {
+			b := unsafeGetBytes(line)
+			for i := 0; i+blockBloomNGramLen <= len(b); i++ {
+				ngrams[binary.LittleEndian.Uint32(b[i:])] = struct{}{}
+			}
+			return nil
+		},
+	)
+	if len(ngrams) == 0 {
+		return nil, nil
+	}
+
+	n, fpRate := uint(len(ngrams)), blockBloomFalsePositiveRate
+	if filter.OptimalM(n, fpRate) > maxBits {
+		// the false positive rate of a bloom of m bits holding n items is e^(-m/n * ln(2)^2).
+		fpRate = math.Exp(-float64(maxBits) / float64(n) * math.Ln2 * math.Ln2)
+		if fpRate > blockBloomMaxFalsePositiveRate {
+			return nil, nil
+		}
+	}
+	bloom := filter.NewPartitionedBloomFilter(n, fpRate)
+	ngram := make([]byte, blockBloomNGramLen)
+	for n := range ngrams {
+		binary.LittleEndian.PutUint32(ngram, n)
+		bloom.Add(ngram)
+	}
+	buf := &bytes.Buffer{}
+	if _, err := bloom.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// blockBloomChecker tells if the lines of a block may contain a string, from the bloom of their n-grams.
+type blockBloomChecker struct {
+	bloom *filter.PartitionedBloomFilter
+}
+
+// Test implements log.Checker.
+func (c blockBloomChecker) Test(s []byte, caseInsensitive bool, _ bool) bool {
+	if caseInsensitive {
+		return true
+	}
+	for i := 0; i+blockBloomNGramLen <= len(s); i++ {
+		if !c.bloom.Test(s[i : i+blockBloomNGramLen]) {
+			return false
+		}
+	}
+	return true
+}
+
+// TestRegex implements log.Checker.
+func (c blockBloomChecker) TestRegex(_ *regexp.Regexp) bool {
+	return true
+}
+
+// blockBloomFilter is the bloom of a block, decoded once when the block is first tested.
+type blockBloomFilter struct {
+	b      []byte
+	once   sync.Once
+	filter *filter.PartitionedBloomFilter
+}
+
+func newBlockBloomFilter(b []byte) *blockBloomFilter {
+	if len(b) == 0 {
+		return nil
+	}
+	return &blockBloomFilter{b: b}
+}
+
+// checker returns the checker of the bloom, or false if it can't be decoded.
+func (f *blockBloomFilter) checker() (blockBloomChecker, bool) {
+	f.once.Do(func() {
+		bloom := filter.NewPartitionedBloomFilter(0, blockBloomFalsePositiveRate)
+		if _, err := bloom.DecodeFrom(f.b); err == nil {
+			f.filter = bloom
+		}
+	})
+	if f.filter == nil {
+		return blockBloomChecker{}, false
+	}
+	// the copies share the partitions of the filter, but not its hash which can't be used concurrently.
+	bloom := *f.filter
+	bloom.SetHash(fnv.New64())
+	return blockBloomChecker{bloom: &bloom}, true
+}
+
+// mayMatch tells if some lines of the block may match the line filters of the pipeline or the sample extractor.
+func (b block) mayMatch(pipeline any) bool {
+	m, ok := pipeline.(log.LineFilterMatcher)
+	if !ok || b.bloomFilter == nil {
+		return true
+	}
+	checker, ok := b.bloomFilter.checker()
+	if !ok {
+		return true
+	}
+	return m.MatchesLineFilters(checker)
+}
@@ -0,0 +1,200 @@
+package chunkenc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+)
+
+func blockBloomTestLine(i int) string {
+	level := "info"
+	if i >= 10 && i < 20 {
+		level = "error"
+	}
+	return fmt.Sprintf("level=%s msg=\"request %d served\"", level, i)
+}
+
+// newBlockBloomTestChunk returns a chunk of 3 blocks of 10 entries, only the lines of the second one being errors.
+func newBlockBloomTestChunk(t *testing.T, format byte, blooms bool) *MemChunk {
+	t.Helper()
+	chk := NewMemChunk(format, EncSnappy, UnorderedWithStructuredMetadataHeadBlockFmt, testBlockSize, testTargetSize)
+	chk.UseBlockBlooms(blooms)
+	for b := 0; b < 3; b++ {
+		for i := 0; i < 10; i++ {
+			ts := b*10 + i
+			dup, err := chk.Append(&logproto.Entry{Timestamp: time.Unix(0, int64(ts)), Line: blockBloomTestLine(ts)})
+			require.NoError(t, err)
+			require.False(t, dup)
+		}
+		require.NoError(t, chk.cut())
+	}
+	return chk
+}
+
+func TestBlockBlooms(t *testing.T) {
+	chk := newBlockBloomTestChunk(t, ChunkFormatV5, true)
+	b, err := chk.Bytes()
+	require.NoError(t, err)
+	chk, err = NewByteChunk(b, testBlockSize, testTargetSize)
+	require.NoError(t, err)
+	require.True(t, chk.blockBlooms)
+	for _, blk := range chk.blocks {
+		require.NotEmpty(t, blk.bloom)
+	}
+	streamLabels := labels.FromStrings("job", "fake")
+
+	for _, tc := range []struct {
+		query         string
+		expectedLines int64
+	}{
+		{query: `{job="fake"}`, expectedLines: 30},
+		{query: `{job="fake"} |= "level=error"`, expectedLines: 10},
+		{query: `{job="fake"} |= "level=error" or "level=debug"`, expectedLines: 10},
+		{query: `{job="fake"} |~ "level=(error|debug)"`, expectedLines: 10},
+		{query: `{job="fake"} |= "level=error" != "request 12"`, expectedLines: 10},
+		{query: `{job="fake"} |= "LEVEL=ERROR"`, expectedLines: 0},
+		{query: `{job="fake"} |~ "(?i)LEVEL=ERROR"`, expectedLines: 30},
+		{query: `{job="fake"} != "level=error"`, expectedLines: 30},
+		{query: `{job="fake"} | line_format "level=error" |= "level=error"`, expectedLines: 30},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			expr, err := syntax.ParseLogSelector(tc.query, true)
+			require.NoError(t, err)
+			pipeline, err := expr.Pipeline()
+			require.NoError(t, err)
+
+			sts, ctx := stats.NewContext(context.Background())
+			it, err := chk.Iterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, pipeline.ForStream(streamLabels))
+			require.NoError(t, err)
+			var lines []string
+			for it.Next() {
+				lines = append(lines, it.At().Line)
+			}
+			require.NoError(t, it.Close())
+			require.Equal(t, tc.expectedLines, sts.Result(0, 0, 0).Querier.Store.Chunk.DecompressedLines)
+
+			// skipping the blocks doesn't change the result of the query.
+			expected, err := expr.Pipeline()
+			require.NoError(t, err)
+			var expectedLines []string
+			for i := 0; i < 30; i++ {
+				if line, _, ok := expected.ForStream(streamLabels).ProcessString(int64(i), blockBloomTestLine(i)); ok {
+					expectedLines = append(expectedLines, line)
+				}
+			}
+			require.Equal(t, expectedLines, lines)
+		})
+	}
+
+	t.Run("samples", func(t *testing.T) {
+		expr, err := syntax.ParseSampleExpr(`count_over_time({job="fake"} |= "level=error" [1m])`)
+		require.NoError(t, err)
+		extractor, err := expr.Extractor()
+		require.NoError(t, err)
+
+		sts, ctx := stats.NewContext(context.Background())
+		it := chk.SampleIterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), extractor.ForStream(streamLabels))
+		var samples int
+		for it.Next() {
+			samples++
+		}
+		require.NoError(t, it.Close())
+		require.Equal(t, 10, samples)
+		require.Equal(t, int64(10), sts.Result(0, 0, 0).Querier.Store.Chunk.DecompressedLines)
+	})
+}
+
+func TestBlockBlooms_Optional(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		format byte
+		blooms bool
+	}{
+		{name: "disabled", format: ChunkFormatV5, blooms: false},
+		{name: "unsupported format", format: ChunkFormatV4, blooms: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chk := newBlockBloomTestChunk(t, tc.format, tc.blooms)
+			b, err := chk.Bytes()
+			require.NoError(t, err)
+			chk, err = NewByteChunk(b, testBlockSize, testTargetSize)
+			require.NoError(t, err)
+			require.False(t, chk.blockBlooms)
+			for _, blk := range chk.blocks {
+				require.Empty(t, blk.bloom)
+			}
+		})
+	}
+}
+
+func TestBlockBlooms_Size(t *testing.T) {
+	chk := newBlockBloomTestChunk(t, ChunkFormatV5, true)
+	size := 0
+	for _, blk := range chk.blocks {
+		require.NotEmpty(t, blk.bloom)
+		size += len(blk.b) + len(blk.bloom)
+	}
+	require.Equal(t, size, chk.cutBlockSize, "the blooms count toward the target size")
+	b, err := chk.Bytes()
+	require.NoError(t, err)
+	chk, err = NewByteChunk(b, testBlockSize, testTargetSize)
+	require.NoError(t, err)
+	require.Equal(t, size, chk.cutBlockSize)
+
+	// the bloom of lines with too many distinct n-grams for their compressed size is capped, or not written.
+	head := newUnorderedHeadBlock(UnorderedWithStructuredMetadataHeadBlockFmt, newSymbolizer())
+	for i := 0; i < 100; i++ {
+		_, err := head.Append(int64(i), fmt.Sprintf("%x", rand.Uint64()), labels.EmptyLabels())
+		require.NoError(t, err)
+	}
+	bloom, err := buildBlockBloom(head, 1<<20)
+	require.NoError(t, err)
+	require.NotEmpty(t, bloom)
+	capped, err := buildBlockBloom(head, 8<<10)
+	require.NoError(t, err)
+	require.NotEmpty(t, capped)
+	require.Less(t, len(capped), len(bloom))
+	bloom, err = buildBlockBloom(head, 1<<10)
+	require.NoError(t, err)
+	require.Nil(t, bloom, "the bloom would be mostly false positives")
+}
+
+func TestBlockBloomFilter_DecodedOnce(t *testing.T) {
+	chk := newBlockBloomTestChunk(t, ChunkFormatV5, true)
+	blk := chk.blocks[0]
+	require.NotNil(t, blk.bloomFilter)
+
+	checker, ok := blk.bloomFilter.checker()
+	require.True(t, ok)
+	decoded := blk.bloomFilter.filter
+	require.NotNil(t, decoded)
+	require.True(t, checker.Test([]byte("level=info"), false, false))
+	require.False(t, checker.Test([]byte("level=error"), false, false))
+
+	// the copies of the block share the decoded filter, and can be tested concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(blk block) {
+			defer wg.Done()
+			checker, ok := blk.bloomFilter.checker()
+			require.True(t, ok)
+			for j := 0; j < 100; j++ {
+				require.False(t, checker.Test([]byte("level=error"), false, false))
+			}
+		}(blk)
+	}
+	wg.Wait()
+	require.Same(t, decoded, blk.bloomFilter.filter)
+}
@@ -0,0 +1,377 @@
+package chunkenc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+)
+
+// The blocks of the ChunkFormatV5 chunks store their entries in columns, so that the structured metadata
+// of the entries can be read without decompressing their lines:
+//
+//	| numEntries (uvarint) | numMetadataColumns (uvarint) | name symbol (uvarint) | ... |
+//	| timestamps length (uvarint) | lines length (uvarint) | metadata column length (uvarint) | ... |
+//	| timestamps column | lines column | metadata column | ... |
+//
+// Each column is compressed on its own with the encoding of the chunk.
+// The timestamps column holds the delta of each timestamp with the previous one (varint), the lines column
+// the length (uvarint) and the bytes of each line, and each metadata column holds the value symbol of its name
+// for each entry, plus one (uvarint), zero meaning the entry has no structured metadata with this name.
+
+// serialiseColumns serialises the head block into a columnar block.
+func serialiseColumns(head HeadBlock, pool WriterPool) ([]byte, error) {
+	hb, ok := head.(*unorderedHeadBlock)
+	if !ok || hb.format < UnorderedWithStructuredMetadataHeadBlockFmt {
+		return nil, fmt.Errorf("columnar blocks can't be cut from the %s head block format", head.Format())
+	}
+
+	var (
+		encBuf    = make([]byte, binary.MaxVarintLen64)
+		tsCol     = &bytes.Buffer{}
+		linesCol  = &bytes.Buffer{}
+		entrySyms = make([]symbols, 0, hb.lines)
+		columns   = map[uint32]int{}
+		names     []uint32
+		prevTs    int64
+	)
+	_ = hb.forEntries(
+		context.Background(),
+		logproto.FORWARD,
+		0,
+		math.MaxInt64,
+		func(_ *stats.Context, ts int64, line string, structuredMetadataSymbols symbols) error {
+			n := binary.PutVarint(encBuf, ts-prevTs)
+			tsCol.Write(encBuf[:n])
+			prevTs = ts
+
+			n = binary.PutUvarint(encBuf, uint64(len(line)))
+			linesCol.Write(encBuf[:n])
+			linesCol.WriteString(line)
+
+			for _, s := range structuredMetadataSymbols {
+				if _, ok := columns[s.Name]; !ok {
+					columns[s.Name] = len(names)
+					names = append(names, s.Name)
+				}
+			}
+			entrySyms = append(entrySyms, structuredMetadataSymbols)
+			return nil
+		},
+	)
+
+	// the columns are sorted by name, which is the order of the structured metadata of the entries once read.
+	sort.Slice(names, func(i, j int) bool {
+		return hb.symbolizer.lookup(names[i]) < hb.symbolizer.lookup(names[j])
+	})
+	for i, name := range names {
+		columns[name] = i
+	}
+	metadataCols := make([]bytes.Buffer, len(names))
+	values := make([]uint64, len(names))
+	for _, syms := range entrySyms {
+		for i := range values {
+			values[i] = 0
+		}
+		for _, s := range syms {
+			values[columns[s.Name]] = uint64(s.Value) + 1
+		}
+		for i, v := range values {
+			n := binary.PutUvarint(encBuf, v)
+			metadataCols[i].Write(encBuf[:n])
+		}
+	}
+
+	compressed := make([][]byte, 0, 2+len(names))
+	for _, col := range append([]*bytes.Buffer{tsCol, linesCol}, buffersOf(metadataCols)...) {
+		b, err := compressColumn(pool, col.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		compressed = append(compressed, b)
+	}
+
+	outBuf := &bytes.Buffer{}
+	n := binary.PutUvarint(encBuf, uint64(len(entrySyms)))
+	outBuf.Write(encBuf[:n])
+	n = binary.PutUvarint(encBuf, uint64(len(names)))
+	outBuf.Write(encBuf[:n])
+	for _, name := range names {
+		n = binary.PutUvarint(encBuf, uint64(name))
+		outBuf.Write(encBuf[:n])
+	}
+	for _, col := range compressed {
+		n = binary.PutUvarint(encBuf, uint64(len(col)))
+		outBuf.Write(encBuf[:n])
+	}
+	for _, col := range compressed {
+		outBuf.Write(col)
+	}
+	return outBuf.Bytes(), nil
+}
+
+func buffersOf(bufs []bytes.Buffer) []*bytes.Buffer {
+	res := make([]*bytes.Buffer, len(bufs))
+	for i := range bufs {
+		res[i] = &bufs[i]
+	}
+	return res
+}
+
+func compressColumn(pool WriterPool, b []byte) ([]byte, error) {
+	outBuf := &bytes.Buffer{}
+	compressedWriter := pool.GetWriter(outBuf)
+	defer pool.PutWriter(compressedWriter)
+
+	if _, err := compressedWriter.Write(b); err != nil {
+		return nil, errors.Wrap(err, "appending column")
+	}
+	if err := compressedWriter.Close(); err != nil {
+		return nil, errors.Wrap(err, "flushing pending compress buffer")
+	}
+	return outBuf.Bytes(), nil
+}
+
+// columnarIterator iterates over the entries of a columnar block.
+// The timestamps and the structured metadata are decompressed first, and the entries the filterer excludes
+// are skipped before their lines are read: the lines column isn't decompressed when no entry of the block matches.
+type columnarIterator struct {
+	origBytes  []byte
+	stats      *stats.Context
+	pool       ReaderPool
+	symbolizer *symbolizer
+	filterer   log.StructuredMetadataFilterer
+
+	err     error
+	decoded bool
+	closed  bool
+
+	numEntries int
+	idx        int // index of the current entry.
+	timestamps []int64
+	names      []uint32
+	values     [][]uint64 // the value symbols of each metadata column, plus one.
+
+	linesCol   []byte // the compressed lines column.
+	lines      []byte // the decompressed lines column, once an entry matches.
+	lineStarts []int  // the offsets of the lines of the entries in lines.
+	lineEnds   []int
+
+	symbolsBuf             []symbol
+	metadataBuf            labels.Labels
+	currTs                 int64
+	currLine               []byte
+	currStructuredMetadata labels.Labels
+}
+
+func newColumnarIterator(ctx context.Context, pool ReaderPool, b []byte, symbolizer *symbolizer, filterer log.StructuredMetadataFilterer) *columnarIterator {
+	stats := stats.FromContext(ctx)
+	stats.AddCompressedBytes(int64(len(b)))
+	return &columnarIterator{
+		stats:      stats,
+		origBytes:  b,
+		pool:       pool,
+		symbolizer: symbolizer,
+		filterer:   filterer,
+		idx:        -1,
+	}
+}
+
+func (ci *columnarIterator) Next() bool {
+	if ci.closed {
+		return false
+	}
+	if !ci.decoded {
+		ci.decoded = true
+		if err := ci.decode(); err != nil {
+			ci.err = err
+			ci.Close()
+			return false
+		}
+	}
+
+	for ci.idx++; ci.idx < ci.numEntries; ci.idx++ {
+		ts := ci.timestamps[ci.idx]
+		structuredMetadata := ci.structuredMetadata(ci.idx)
+		if ci.filterer != nil && !ci.filterer.MatchesStructuredMetadata(ts, structuredMetadata...) {
+			continue
+		}
+		line, err := ci.line(ci.idx)
+		if err != nil {
+			ci.err = err
+			ci.Close()
+			return false
+		}
+		// the filterer may have changed the structured metadata in place, so it's looked up again.
+		ci.currStructuredMetadata = ci.structuredMetadata(ci.idx)
+		ci.currTs = ts
+		ci.currLine = line
+		return true
+	}
+	ci.Close()
+	return false
+}
+
+// decode reads the header of the block, and decompresses its timestamps and structured metadata columns.
+func (ci *columnarIterator) decode() error {
+	db := decbuf{b: ci.origBytes}
+	ci.numEntries = db.uvarint()
+	numColumns := db.uvarint()
+	if db.err() != nil {
+		return errors.Wrap(db.err(), "decoding block header")
+	}
+	ci.names = make([]uint32, numColumns)
+	for i := range ci.names {
+		ci.names[i] = uint32(db.uvarint())
+	}
+	lengths := make([]int, 2+numColumns)
+	for i := range lengths {
+		lengths[i] = db.uvarint()
+	}
+	if db.err() != nil {
+		return errors.Wrap(db.err(), "decoding block header")
+	}
+	cols := make([][]byte, len(lengths))
+	for i, l := range lengths {
+		cols[i] = db.bytes(l)
+	}
+	if db.err() != nil {
+		return errors.Wrap(db.err(), "decoding block columns")
+	}
+
+	tsCol, err := ci.decompress(cols[0])
+	if err != nil {
+		return errors.Wrap(err, "decompressing timestamps")
+	}
+	ci.stats.AddDecompressedBytes(int64(len(tsCol)))
+	ci.timestamps = make([]int64, ci.numEntries)
+	tsBuf := decbuf{b: tsCol}
+	var ts int64
+	for i := range ci.timestamps {
+		ts += tsBuf.varint64()
+		ci.timestamps[i] = ts
+	}
+	if tsBuf.err() != nil {
+		return errors.Wrap(tsBuf.err(), "decoding timestamps")
+	}
+
+	ci.values = make([][]uint64, numColumns)
+	for i := range ci.values {
+		col, err := ci.decompress(cols[2+i])
+		if err != nil {
+			return errors.Wrap(err, "decompressing structured metadata")
+		}
+		ci.stats.AddDecompressedStructuredMetadataBytes(int64(len(col)))
+		ci.stats.AddDecompressedBytes(int64(len(col)))
+		values := make([]uint64, ci.numEntries)
+		valuesBuf := decbuf{b: col}
+		for j := range values {
+			values[j] = uint64(valuesBuf.uvarint())
+		}
+		if valuesBuf.err() != nil {
+			return errors.Wrap(valuesBuf.err(), "decoding structured metadata")
+		}
+		ci.values[i] = values
+	}
+
+	ci.linesCol = cols[1]
+	return nil
+}
+
+func (ci *columnarIterator) decompress(b []byte) ([]byte, error) {
+	r, err := ci.pool.GetReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer ci.pool.PutReader(r)
+
+	var out bytes.Buffer
+	if _, err := out.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (ci *columnarIterator) structuredMetadata(idx int) labels.Labels {
+	if len(ci.values) > cap(ci.symbolsBuf) {
+		if ci.symbolsBuf != nil {
+			SymbolsPool.Put(ci.symbolsBuf)
+		}
+		ci.symbolsBuf = SymbolsPool.Get(len(ci.values)).([]symbol)
+	}
+	ci.symbolsBuf = ci.symbolsBuf[:0]
+	for i, values := range ci.values {
+		if values[idx] == 0 {
+			continue
+		}
+		ci.symbolsBuf = append(ci.symbolsBuf, symbol{Name: ci.names[i], Value: uint32(values[idx] - 1)})
+	}
+	if len(ci.symbolsBuf) == 0 {
+		return nil
+	}
+	ci.metadataBuf = ci.symbolizer.Lookup(ci.symbolsBuf, ci.metadataBuf)
+	return ci.metadataBuf
+}
+
+// line returns the line of an entry, decompressing the lines column the first time a line is needed.
+func (ci *columnarIterator) line(idx int) ([]byte, error) {
+	if ci.lineStarts == nil {
+		lines, err := ci.decompress(ci.linesCol)
+		if err != nil {
+			return nil, errors.Wrap(err, "decompressing lines")
+		}
+		ci.stats.AddDecompressedBytes(int64(len(lines)))
+		ci.lines = lines
+		ci.lineStarts = make([]int, ci.numEntries)
+		ci.lineEnds = make([]int, ci.numEntries)
+		var offset int
+		for i := 0; i < ci.numEntries; i++ {
+			l, n := binary.Uvarint(lines[offset:])
+			if n <= 0 || l > uint64(len(lines)-offset-n) {
+				return nil, fmt.Errorf("invalid data in chunk")
+			}
+			if l >= maxLineLength {
+				return nil, fmt.Errorf("line too long %d, maximum %d", l, maxLineLength)
+			}
+			ci.lineStarts[i] = offset + n
+			offset += n + int(l)
+			ci.lineEnds[i] = offset
+		}
+	}
+
+	ci.stats.AddDecompressedLines(1)
+	return ci.lines[ci.lineStarts[idx]:ci.lineEnds[idx]], nil
+}
+
+func (ci *columnarIterator) entry() (int64, []byte, labels.Labels) {
+	return ci.currTs, ci.currLine, ci.currStructuredMetadata
+}
+
+func (ci *columnarIterator) Err() error { return ci.err }
+
+func (ci *columnarIterator) Close() error {
+	if !ci.closed {
+		ci.closed = true
+		if ci.symbolsBuf != nil {
+			SymbolsPool.Put(ci.symbolsBuf)
+			ci.symbolsBuf = nil
+		}
+		if ci.metadataBuf != nil {
+			structuredMetadataPool.Put(ci.metadataBuf) // nolint:staticcheck
+			ci.metadataBuf = nil
+		}
+		ci.currLine, ci.currStructuredMetadata = nil, nil
+		ci.origBytes, ci.linesCol, ci.lines = nil, nil, nil
+		ci.timestamps, ci.values = nil, nil
+	}
+	return ci.err
+}
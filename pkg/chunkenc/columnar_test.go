@@ -0,0 +1,136 @@
+package chunkenc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+)
+
+// newColumnarTestChunk returns a chunk of 3 blocks of 10 entries, only the entries of the second one having a trace_id.
+func newColumnarTestChunk(t *testing.T, enc Encoding) *MemChunk {
+	t.Helper()
+	chk := NewMemChunk(ChunkFormatV5, enc, UnorderedWithStructuredMetadataHeadBlockFmt, testBlockSize, testTargetSize)
+	for b := 0; b < 3; b++ {
+		for i := 0; i < 10; i++ {
+			ts := int64(b*10 + i)
+			structuredMetadata := []logproto.LabelAdapter{{Name: "pod", Value: fmt.Sprintf("pod-%d", i%2)}}
+			if b == 1 {
+				structuredMetadata = append(structuredMetadata, logproto.LabelAdapter{Name: "trace_id", Value: fmt.Sprintf("trace-%d", i%5)})
+			}
+			dup, err := chk.Append(logprotoEntryWithStructuredMetadata(ts, fmt.Sprintf("line %d", ts), structuredMetadata))
+			require.NoError(t, err)
+			require.False(t, dup)
+		}
+		require.NoError(t, chk.cut())
+	}
+	return chk
+}
+
+func TestColumnarBlocks_RoundTrip(t *testing.T) {
+	for _, enc := range testEncoding {
+		t.Run(enc.String(), func(t *testing.T) {
+			chk := newColumnarTestChunk(t, enc)
+			b, err := chk.Bytes()
+			require.NoError(t, err)
+
+			chk, err = NewByteChunk(b, testBlockSize, testTargetSize)
+			require.NoError(t, err)
+			require.Equal(t, ChunkFormatV5, chk.format)
+			require.Len(t, chk.blocks, 3)
+
+			it, err := chk.Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, noopStreamPipeline)
+			require.NoError(t, err)
+			var i int64
+			for ; it.Next(); i++ {
+				e := it.At()
+				require.Equal(t, i, e.Timestamp.UnixNano())
+				require.Equal(t, fmt.Sprintf("line %d", i), e.Line)
+				expected := labels.FromStrings("pod", fmt.Sprintf("pod-%d", i%2))
+				if i >= 10 && i < 20 {
+					expected = labels.FromStrings("pod", fmt.Sprintf("pod-%d", i%2), "trace_id", fmt.Sprintf("trace-%d", i%5))
+				}
+				require.Equal(t, push.LabelsAdapter(logproto.FromLabelsToLabelAdapters(expected)), e.StructuredMetadata)
+			}
+			require.NoError(t, it.Close())
+			require.Equal(t, int64(30), i)
+		})
+	}
+}
+
+func TestColumnarBlocks_SkipLines(t *testing.T) {
+	chk := newColumnarTestChunk(t, EncSnappy)
+	streamLabels := labels.FromStrings("job", "fake")
+
+	for _, tc := range []struct {
+		query         string
+		expectedLines int64
+	}{
+		{query: `{job="fake"}`, expectedLines: 30},
+		{query: `{job="fake"} |= "line"`, expectedLines: 30},
+		{query: `{job="fake"} | trace_id="trace-2"`, expectedLines: 2},
+		{query: `{job="fake"} | trace_id="trace-2" |= "line 17"`, expectedLines: 2},
+		{query: `{job="fake"} | trace_id="trace-2" | pod="pod-1"`, expectedLines: 1},
+		{query: `{job="fake"} | trace_id="unknown"`, expectedLines: 0},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			expr, err := syntax.ParseLogSelector(tc.query, true)
+			require.NoError(t, err)
+			pipeline, err := expr.Pipeline()
+			require.NoError(t, err)
+
+			sts, ctx := stats.NewContext(context.Background())
+			it, err := chk.Iterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, pipeline.ForStream(streamLabels))
+			require.NoError(t, err)
+			var lines []string
+			for it.Next() {
+				lines = append(lines, it.At().Line)
+			}
+			require.NoError(t, it.Close())
+			require.Equal(t, tc.expectedLines, sts.Result(0, 0, 0).Querier.Store.Chunk.DecompressedLines)
+
+			// skipping the lines doesn't change the result of the query.
+			expected, err := expr.Pipeline()
+			require.NoError(t, err)
+			var expectedLines []string
+			for i := 0; i < 30; i++ {
+				structuredMetadata := labels.FromStrings("pod", fmt.Sprintf("pod-%d", i%2))
+				if i >= 10 && i < 20 {
+					structuredMetadata = labels.FromStrings("pod", fmt.Sprintf("pod-%d", i%2), "trace_id", fmt.Sprintf("trace-%d", i%5))
+				}
+				line := fmt.Sprintf("line %d", i)
+				if _, _, ok := expected.ForStream(streamLabels).ProcessString(int64(i), line, structuredMetadata...); ok {
+					expectedLines = append(expectedLines, line)
+				}
+			}
+			require.Equal(t, expectedLines, lines)
+		})
+	}
+
+	t.Run("samples", func(t *testing.T) {
+		expr, err := syntax.ParseSampleExpr(`count_over_time({job="fake"} | trace_id="trace-2"[1m])`)
+		require.NoError(t, err)
+		extractor, err := expr.Extractor()
+		require.NoError(t, err)
+
+		sts, ctx := stats.NewContext(context.Background())
+		it := chk.SampleIterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), extractor.ForStream(streamLabels))
+		var timestamps []int64
+		for it.Next() {
+			timestamps = append(timestamps, it.At().Timestamp)
+		}
+		require.NoError(t, it.Close())
+		require.Equal(t, []int64{12, 17}, timestamps)
+		require.Equal(t, int64(2), sts.Result(0, 0, 0).Querier.Store.Chunk.DecompressedLines)
+	})
+}
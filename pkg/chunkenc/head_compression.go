@@ -0,0 +1,493 @@
+package chunkenc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Workiva/go-datastructures/rangetree"
+	"github.com/cespare/xxhash/v2"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+)
+
+// The compressed entries of the head blocks are decompressed by the queries of the recent logs
+// and when the head blocks are cut, so they are compressed with a fast encoding whatever the chunk encoding.
+const headCompressionEncoding = EncSnappy
+
+// compressedEntries holds entries of a head block, serialised like the entries of the blocks.
+type compressedEntries struct {
+	b          []byte
+	lines      int
+	mint, maxt int64
+	// keys of the entries ordered by timestamp and line hash, to detect the duplicates of the appended entries.
+	keys []entryKey
+}
+
+type entryKey struct {
+	ts       int64
+	lineHash uint64
+}
+
+func newEntryKey(ts int64, line string) entryKey {
+	return entryKey{ts: ts, lineHash: xxhash.Sum64String(line)}
+}
+
+func (k entryKey) less(o entryKey) bool {
+	return k.ts < o.ts || (k.ts == o.ts && k.lineHash < o.lineHash)
+}
+
+// has reports whether the entries hold the key.
+func (c compressedEntries) has(k entryKey) bool {
+	if k.ts < c.mint || k.ts > c.maxt {
+		return false
+	}
+	i := sort.Search(len(c.keys), func(i int) bool { return !c.keys[i].less(k) })
+	return i < len(c.keys) && c.keys[i] == k
+}
+
+type headEntry struct {
+	ts                        int64
+	line                      string
+	structuredMetadataSymbols symbols
+}
+
+// isCompressedDuplicate reports whether an entry with the same timestamp and line was compressed.
+// The lines are compared by hash, so a hash collision drops a distinct line with the same timestamp.
+func (hb *unorderedHeadBlock) isCompressedDuplicate(ts int64, line string) bool {
+	if len(hb.compressed) == 0 || ts < hb.mint || ts > hb.maxt {
+		return false
+	}
+	k := newEntryKey(ts, line)
+	for _, c := range hb.compressed {
+		if c.has(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendMark records that the entries of the generations up to gen were appended before at.
+type appendMark struct {
+	at  int64 // unix nanoseconds
+	gen uint32
+}
+
+// compress re-encodes the uncompressed entries appended at least the given age ago into compressed entries,
+// which are still iterated along the uncompressed ones until the head block is cut, and reports whether
+// any entries were compressed. The append times are known at the granularity of the calls, so the entries
+// are compressed by the first call at least the given age after the call following their append.
+// The compressed entries are merged with the previous ones of a similar size, so that the head block
+// holds a logarithmic number of compressed entries.
+func (hb *unorderedHeadBlock) compress(now time.Time, age time.Duration) (bool, error) {
+	if hb.lines > hb.markedLines {
+		hb.appendMarks = append(hb.appendMarks, appendMark{at: now.UnixNano(), gen: hb.gen})
+		hb.markedLines = hb.lines
+		hb.gen++
+	}
+	compressedGen := hb.compressedGen
+	cutoff := now.Add(-age).UnixNano()
+	for len(hb.appendMarks) > 0 && hb.appendMarks[0].at <= cutoff {
+		compressedGen = hb.appendMarks[0].gen + 1
+		hb.appendMarks = hb.appendMarks[1:]
+	}
+	if compressedGen == hb.compressedGen {
+		return false, nil
+	}
+
+	// the entries appended since are kept uncompressed.
+	var entries []headEntry
+	rt := rangetree.New(1)
+	for _, e := range hb.rt.Query(interval{mint: math.MinInt64, maxt: math.MaxInt64}) {
+		es := e.(*nsEntries)
+		var recent []nsEntry
+		for _, entry := range es.entries {
+			if entry.gen < compressedGen {
+				entries = append(entries, headEntry{es.ts, entry.line, entry.structuredMetadataSymbols})
+				continue
+			}
+			recent = append(recent, entry)
+		}
+		if len(recent) > 0 {
+			rt.Add(&nsEntries{ts: es.ts, entries: recent})
+		}
+	}
+
+	if len(entries) == 0 {
+		hb.compressedGen = compressedGen
+		return false, nil
+	}
+	c, err := hb.compressEntries(entries)
+	if err != nil {
+		return false, err
+	}
+	hb.compressed = append(hb.compressed, c)
+	hb.rt = rt
+	hb.compressedGen = compressedGen
+
+	for n := len(hb.compressed); n > 1 && hb.compressed[n-2].lines <= hb.compressed[n-1].lines; n-- {
+		if err := hb.mergeCompressed(n - 2); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// mergeCompressed replaces the compressed entries at i and i+1 with their merged entries.
+func (hb *unorderedHeadBlock) mergeCompressed(i int) error {
+	entries, err := hb.compressed[i].appendEntries(nil, hb.format, math.MinInt64, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	if entries, err = hb.compressed[i+1].appendEntries(entries, hb.format, math.MinInt64, math.MaxInt64); err != nil {
+		return err
+	}
+	// the entries at i are older than the ones at i+1, so the stable sort preserves the write order.
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ts < entries[j].ts
+	})
+
+	c, err := hb.compressEntries(entries)
+	if err != nil {
+		return err
+	}
+	hb.compressed[i] = c
+	hb.compressed = append(hb.compressed[:i+1], hb.compressed[i+2:]...)
+	return nil
+}
+
+// compressEntries serialises the entries, which are ordered by timestamp.
+func (hb *unorderedHeadBlock) compressEntries(entries []headEntry) (compressedEntries, error) {
+	c := compressedEntries{
+		lines: len(entries),
+		mint:  math.MaxInt64,
+		maxt:  math.MinInt64,
+		keys:  make([]entryKey, 0, len(entries)),
+	}
+	b, err := hb.serialise(GetWriterPool(headCompressionEncoding), func(_ context.Context, _ logproto.Direction, _, _ int64, entryFn func(*stats.Context, int64, string, symbols) error) error {
+		for _, e := range entries {
+			c.mint = min(c.mint, e.ts)
+			c.maxt = max(c.maxt, e.ts)
+			c.keys = append(c.keys, newEntryKey(e.ts, e.line))
+			if err := entryFn(nil, e.ts, e.line, e.structuredMetadataSymbols); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c, err
+	}
+	c.b = b
+	sort.Slice(c.keys, func(i, j int) bool {
+		return c.keys[i].less(c.keys[j])
+	})
+	return c, nil
+}
+
+// forMergedEntries iterates over the compressed and the uncompressed entries of the head block,
+// ordered by timestamp and then by write order like the uncompressed ones. The entries of each tier,
+// the compressed entries and the uncompressed ones, are already ordered by timestamp, so the tiers
+// are merged as they are iterated, and only the tiers overlapping the time range are decompressed.
+func (hb *unorderedHeadBlock) forMergedEntries(
+	ctx context.Context,
+	direction logproto.Direction,
+	mint,
+	maxt int64,
+	entryFn func(*stats.Context, int64, string, symbols) error,
+) (err error) {
+	// the tiers are ordered from the oldest entries to the newest ones.
+	tiers := make([]headEntryIterator, 0, len(hb.compressed)+1)
+	defer func() {
+		for _, it := range tiers {
+			if closeErr := it.close(); err == nil {
+				err = closeErr
+			}
+		}
+	}()
+	for _, c := range hb.compressed {
+		if maxt <= c.mint || c.maxt < mint {
+			continue
+		}
+		it, err := c.iterator(hb.format, direction, mint, maxt)
+		if err != nil {
+			return err
+		}
+		tiers = append(tiers, it)
+	}
+	tiers = append(tiers, newUncompressedEntriesIterator(hb.rt.Query(interval{mint: mint, maxt: maxt}), direction))
+
+	heads := make([]bool, len(tiers))
+	for i, it := range tiers {
+		heads[i] = it.next()
+	}
+
+	chunkStats := stats.FromContext(ctx)
+	for {
+		// the entries with the same timestamp are returned in write order, or in reverse write order backward.
+		next := -1
+		for i, it := range tiers {
+			if !heads[i] {
+				continue
+			}
+			if next < 0 ||
+				(direction == logproto.FORWARD && it.at().ts < tiers[next].at().ts) ||
+				(direction == logproto.BACKWARD && it.at().ts >= tiers[next].at().ts) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		e := tiers[next].at()
+		structuredMetadataBytes := int64(2 * len(e.structuredMetadataSymbols) * 4) // 2 * num_symbols * 4 bytes(uint32)
+		chunkStats.AddHeadChunkLines(1)
+		chunkStats.AddHeadChunkStructuredMetadataBytes(structuredMetadataBytes)
+		chunkStats.AddHeadChunkBytes(int64(len(e.line)) + structuredMetadataBytes)
+
+		if err := entryFn(chunkStats, e.ts, e.line, e.structuredMetadataSymbols); err != nil {
+			return err
+		}
+		heads[next] = tiers[next].next()
+	}
+	for _, it := range tiers {
+		if err := it.err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// headEntryIterator iterates over the entries of a tier of a head block, ordered by timestamp in the direction of the query.
+type headEntryIterator interface {
+	next() bool
+	at() headEntry
+	err() error
+	close() error
+}
+
+// iterator returns an iterator over the entries within [mint, maxt). The entries are decompressed as they are
+// iterated forward, so that the iteration stops decompressing at maxt. Iterating backward decompresses them first.
+func (c compressedEntries) iterator(format HeadBlockFmt, direction logproto.Direction, mint, maxt int64) (headEntryIterator, error) {
+	if direction == logproto.BACKWARD {
+		entries, err := c.appendEntries(nil, format, mint, maxt)
+		if err != nil {
+			return nil, err
+		}
+		return &headEntriesIterator{entries: entries, i: len(entries)}, nil
+	}
+	return c.forwardIterator(format, mint, maxt)
+}
+
+func (c compressedEntries) forwardIterator(format HeadBlockFmt, mint, maxt int64) (*compressedEntriesIterator, error) {
+	pool := GetReaderPool(headCompressionEncoding)
+	r, err := pool.GetReader(bytes.NewReader(c.b))
+	if err != nil {
+		return nil, errors.Wrap(err, "getting head block reader")
+	}
+	return &compressedEntriesIterator{
+		pool:      pool,
+		r:         r,
+		br:        bufio.NewReader(r),
+		format:    format,
+		remaining: c.lines,
+		mint:      mint,
+		maxt:      maxt,
+	}, nil
+}
+
+// appendEntries decompresses the entries and appends the ones within [mint, maxt).
+func (c compressedEntries) appendEntries(entries []headEntry, format HeadBlockFmt, mint, maxt int64) ([]headEntry, error) {
+	it, err := c.forwardIterator(format, mint, maxt)
+	if err != nil {
+		return entries, err
+	}
+	for it.next() {
+		entries = append(entries, it.at())
+	}
+	if err := it.err(); err != nil {
+		_ = it.close()
+		return entries, err
+	}
+	return entries, it.close()
+}
+
+// compressedEntriesIterator decompresses the entries of a tier forward, as they are iterated.
+type compressedEntriesIterator struct {
+	pool       ReaderPool
+	r          io.Reader
+	br         *bufio.Reader
+	format     HeadBlockFmt
+	remaining  int
+	mint, maxt int64
+
+	buf  []byte
+	cur  headEntry
+	fail error
+}
+
+func (it *compressedEntriesIterator) next() bool {
+	for it.remaining > 0 && it.fail == nil {
+		it.remaining--
+		e, err := it.decode()
+		if err != nil {
+			it.fail = errors.Wrap(err, "decoding head block entries")
+			return false
+		}
+		if e.ts < it.mint {
+			continue
+		}
+		if e.ts >= it.maxt {
+			// the entries are ordered by timestamp.
+			it.remaining = 0
+			return false
+		}
+		it.cur = e
+		return true
+	}
+	return false
+}
+
+func (it *compressedEntriesIterator) decode() (headEntry, error) {
+	ts, err := binary.ReadVarint(it.br)
+	if err != nil {
+		return headEntry{}, err
+	}
+	n, err := binary.ReadUvarint(it.br)
+	if err != nil {
+		return headEntry{}, err
+	}
+	it.buf = slices.Grow(it.buf[:0], int(n))[:n]
+	if _, err := io.ReadFull(it.br, it.buf); err != nil {
+		return headEntry{}, err
+	}
+	line := string(it.buf)
+
+	var structuredMetadataSymbols symbols
+	if it.format >= UnorderedWithStructuredMetadataHeadBlockFmt {
+		// length of the symbols section.
+		if _, err := binary.ReadUvarint(it.br); err != nil {
+			return headEntry{}, err
+		}
+		n, err := binary.ReadUvarint(it.br)
+		if err != nil {
+			return headEntry{}, err
+		}
+		if n > 0 {
+			structuredMetadataSymbols = make(symbols, n)
+			for j := range structuredMetadataSymbols {
+				name, err := binary.ReadUvarint(it.br)
+				if err != nil {
+					return headEntry{}, err
+				}
+				value, err := binary.ReadUvarint(it.br)
+				if err != nil {
+					return headEntry{}, err
+				}
+				structuredMetadataSymbols[j] = symbol{Name: uint32(name), Value: uint32(value)}
+			}
+		}
+	}
+	return headEntry{ts: ts, line: line, structuredMetadataSymbols: structuredMetadataSymbols}, nil
+}
+
+func (it *compressedEntriesIterator) at() headEntry { return it.cur }
+func (it *compressedEntriesIterator) err() error    { return it.fail }
+
+func (it *compressedEntriesIterator) close() error {
+	if it.r != nil {
+		it.pool.PutReader(it.r)
+		it.r = nil
+	}
+	return nil
+}
+
+// headEntriesIterator iterates backward over decompressed entries.
+type headEntriesIterator struct {
+	entries []headEntry
+	i       int
+}
+
+func (it *headEntriesIterator) next() bool {
+	it.i--
+	return it.i >= 0
+}
+
+func (it *headEntriesIterator) at() headEntry { return it.entries[it.i] }
+func (it *headEntriesIterator) err() error    { return nil }
+func (it *headEntriesIterator) close() error  { return nil }
+
+// uncompressedEntriesIterator iterates over the uncompressed entries of a head block, in write order
+// for the same timestamp, or in reverse write order backward.
+type uncompressedEntriesIterator struct {
+	entries   []rangetree.Entry
+	direction logproto.Direction
+	// the indexes of the current timestamp in entries and of the current entry of this timestamp.
+	i, j int
+	cur  headEntry
+}
+
+func newUncompressedEntriesIterator(entries []rangetree.Entry, direction logproto.Direction) *uncompressedEntriesIterator {
+	it := &uncompressedEntriesIterator{entries: entries, direction: direction, i: -1}
+	if direction == logproto.BACKWARD {
+		it.i = len(entries)
+	}
+	return it
+}
+
+func (it *uncompressedEntriesIterator) next() bool {
+	for {
+		if it.i >= 0 && it.i < len(it.entries) {
+			es := it.entries[it.i].(*nsEntries)
+			if it.direction == logproto.FORWARD {
+				it.j++
+			} else {
+				it.j--
+			}
+			if it.j >= 0 && it.j < len(es.entries) {
+				entry := es.entries[it.j]
+				it.cur = headEntry{es.ts, entry.line, entry.structuredMetadataSymbols}
+				return true
+			}
+		}
+
+		if it.direction == logproto.FORWARD {
+			it.i++
+			if it.i >= len(it.entries) {
+				return false
+			}
+			it.j = -1
+		} else {
+			it.i--
+			if it.i < 0 {
+				return false
+			}
+			it.j = len(it.entries[it.i].(*nsEntries).entries)
+		}
+	}
+}
+
+func (it *uncompressedEntriesIterator) at() headEntry { return it.cur }
+func (it *uncompressedEntriesIterator) err() error    { return nil }
+func (it *uncompressedEntriesIterator) close() error  { return nil }
+
+// CompressHead re-encodes the entries of the head block appended at least the given age ago into a compressed
+// in-memory form, which is still queried through the head block iterators, to reduce the memory used by the
+// head blocks which aren't cut yet. The append times are tracked by the calls, so it is meant to be called
+// periodically. It reports whether any entries were compressed. Only the unordered head blocks are compressed.
+func (c *MemChunk) CompressHead(now time.Time, age time.Duration) (bool, error) {
+	hb, ok := c.head.(*unorderedHeadBlock)
+	if !ok {
+		return false, nil
+	}
+	return hb.compress(now, age)
+}
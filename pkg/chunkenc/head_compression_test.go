@@ -0,0 +1,269 @@
+package chunkenc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/iter"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+)
+
+func drainEntries(t *testing.T, it iter.EntryIterator) []logproto.Entry {
+	t.Helper()
+	var entries []logproto.Entry
+	for it.Next() {
+		entries = append(entries, it.At())
+	}
+	require.NoError(t, it.Close())
+	return entries
+}
+
+func drainSamples(t *testing.T, it iter.SampleIterator) []logproto.Sample {
+	t.Helper()
+	var samples []logproto.Sample
+	for it.Next() {
+		samples = append(samples, it.At())
+	}
+	require.NoError(t, it.Close())
+	return samples
+}
+
+func TestUnorderedHeadBlockCompression(t *testing.T) {
+	for _, format := range []HeadBlockFmt{UnorderedHeadBlockFmt, UnorderedWithStructuredMetadataHeadBlockFmt} {
+		t.Run(format.String(), func(t *testing.T) {
+			compressed := newUnorderedHeadBlock(format, newSymbolizer())
+			uncompressed := newUnorderedHeadBlock(format, newSymbolizer())
+
+			// the entries of each round overlap the ones of the previous rounds, some of them at the same timestamps.
+			for round := 0; round < 3; round++ {
+				for i := 0; i < 20; i++ {
+					ts := int64((i*7 + round*3) % 50)
+					line := fmt.Sprintf("round %d line %d", round, i)
+					structuredMetadata := labels.FromStrings("round", fmt.Sprint(round))
+					for _, hb := range []*unorderedHeadBlock{compressed, uncompressed} {
+						dup, err := hb.Append(ts, line, structuredMetadata)
+						require.NoError(t, err)
+						require.False(t, dup)
+					}
+				}
+				if round < 2 {
+					compressAll(t, compressed)
+				}
+			}
+			// the compressed entries of the same size are merged.
+			require.Len(t, compressed.compressed, 1)
+
+			require.Equal(t, uncompressed.Entries(), compressed.Entries())
+			require.Equal(t, uncompressed.UncompressedSize(), compressed.UncompressedSize())
+			expectedMint, expectedMaxt := uncompressed.Bounds()
+			mint, maxt := compressed.Bounds()
+			require.Equal(t, expectedMint, mint)
+			require.Equal(t, expectedMaxt, maxt)
+
+			// the head blocks are cut and checkpointed the same.
+			expectedBlock, err := uncompressed.Serialise(GetWriterPool(EncSnappy))
+			require.NoError(t, err)
+			block, err := compressed.Serialise(GetWriterPool(EncSnappy))
+			require.NoError(t, err)
+			require.Equal(t, expectedBlock, block)
+
+			expectedCheckpoint, err := uncompressed.CheckpointBytes(nil)
+			require.NoError(t, err)
+			checkpoint, err := compressed.CheckpointBytes(nil)
+			require.NoError(t, err)
+			require.Equal(t, expectedCheckpoint, checkpoint)
+
+			for _, direction := range []logproto.Direction{logproto.FORWARD, logproto.BACKWARD} {
+				expected := drainEntries(t, uncompressed.Iterator(context.Background(), direction, 10, 40, log.NewNoopPipeline().ForStream(labels.Labels{})))
+				require.NotEmpty(t, expected)
+				require.Equal(t, expected, drainEntries(t, compressed.Iterator(context.Background(), direction, 10, 40, log.NewNoopPipeline().ForStream(labels.Labels{}))))
+			}
+			expectedSamples := drainSamples(t, uncompressed.SampleIterator(context.Background(), 10, 40, countExtractor))
+			require.NotEmpty(t, expectedSamples)
+			require.Equal(t, expectedSamples, drainSamples(t, compressed.SampleIterator(context.Background(), 10, 40, countExtractor)))
+
+			// the entries appended after a compression are uncompressed.
+			compressAll(t, compressed)
+			require.Len(t, compressed.compressed, 2)
+			dup, err := compressed.Append(100, "new line", nil)
+			require.NoError(t, err)
+			require.False(t, dup)
+			require.Equal(t, 1, int(compressed.rt.Len()))
+
+			// the duplicates of the compressed entries are still detected.
+			dup, err = compressed.Append(3, "round 1 line 0", nil)
+			require.NoError(t, err)
+			require.True(t, dup)
+			dup, err = compressed.Append(3, "round 1 line 1", nil)
+			require.NoError(t, err)
+			require.False(t, dup)
+			require.Equal(t, uncompressed.Entries()+2, compressed.Entries())
+
+			compressed.Reset()
+			require.True(t, compressed.IsEmpty())
+			require.Empty(t, compressed.compressed)
+		})
+	}
+}
+
+func TestUnorderedHeadBlockCompressionMergedTiers(t *testing.T) {
+	compressed := newUnorderedHeadBlock(UnorderedWithStructuredMetadataHeadBlockFmt, newSymbolizer())
+	uncompressed := newUnorderedHeadBlock(UnorderedWithStructuredMetadataHeadBlockFmt, newSymbolizer())
+
+	// the tiers have fewer entries than the previous ones so that they aren't merged, and share timestamps.
+	for round, lines := range []int{40, 20, 10} {
+		for i := 0; i < lines; i++ {
+			ts := int64(100*round + i*5)
+			for _, hb := range []*unorderedHeadBlock{compressed, uncompressed} {
+				_, err := hb.Append(ts, fmt.Sprintf("round %d line %d", round, i), nil)
+				require.NoError(t, err)
+				_, err = hb.Append(ts%50, fmt.Sprintf("round %d line %d at %d", round, i, ts%50), nil)
+				require.NoError(t, err)
+			}
+		}
+		if round < 2 {
+			compressAll(t, compressed)
+		}
+	}
+	require.Len(t, compressed.compressed, 2)
+
+	for _, r := range [][2]int64{{0, 50}, {0, 1000}, {20, 120}, {150, 300}} {
+		for _, direction := range []logproto.Direction{logproto.FORWARD, logproto.BACKWARD} {
+			expected := drainEntries(t, uncompressed.Iterator(context.Background(), direction, r[0], r[1], log.NewNoopPipeline().ForStream(labels.Labels{})))
+			require.NotEmpty(t, expected)
+			require.Equal(t, expected, drainEntries(t, compressed.Iterator(context.Background(), direction, r[0], r[1], log.NewNoopPipeline().ForStream(labels.Labels{}))), "%s %v", direction, r)
+		}
+	}
+
+	// only the tiers overlapping the time range are decompressed.
+	compressed.compressed[1].b = []byte("not snappy")
+	entries := drainEntries(t, compressed.Iterator(context.Background(), logproto.FORWARD, 200, 300, log.NewNoopPipeline().ForStream(labels.Labels{})))
+	require.Len(t, entries, 10)
+}
+
+func compressAll(t *testing.T, hb *unorderedHeadBlock) {
+	t.Helper()
+	compressed, err := hb.compress(time.Now(), 0)
+	require.NoError(t, err)
+	require.True(t, compressed)
+}
+
+func TestUnorderedHeadBlockCompressionAge(t *testing.T) {
+	hb := newUnorderedHeadBlock(UnorderedWithStructuredMetadataHeadBlockFmt, newSymbolizer())
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		_, err := hb.Append(int64(i), fmt.Sprint(i), nil)
+		require.NoError(t, err)
+	}
+	compressed, err := hb.compress(now, time.Hour)
+	require.NoError(t, err)
+	require.False(t, compressed)
+
+	// the recent entries are interleaved with the old ones.
+	for i := 0; i < 10; i++ {
+		_, err := hb.Append(int64(i), fmt.Sprint(i+10), nil)
+		require.NoError(t, err)
+	}
+	compressed, err = hb.compress(now.Add(time.Minute), time.Hour)
+	require.NoError(t, err)
+	require.False(t, compressed)
+	require.Empty(t, hb.compressed)
+
+	// only the entries appended before the first call are an hour old.
+	compressed, err = hb.compress(now.Add(time.Hour), time.Hour)
+	require.NoError(t, err)
+	require.True(t, compressed)
+	require.Len(t, hb.compressed, 1)
+	require.Equal(t, 10, hb.compressed[0].lines)
+	require.Equal(t, 10, int(hb.rt.Len()))
+
+	compressed, err = hb.compress(now.Add(time.Hour), time.Hour)
+	require.NoError(t, err)
+	require.False(t, compressed)
+
+	// the entries with the same timestamp are still iterated in write order.
+	entries := drainEntries(t, hb.Iterator(context.Background(), logproto.FORWARD, 0, 10, log.NewNoopPipeline().ForStream(labels.Labels{})))
+	require.Len(t, entries, 20)
+	for i, e := range entries {
+		require.Equal(t, fmt.Sprint(i/2+(i%2)*10), e.Line)
+	}
+
+	compressed, err = hb.compress(now.Add(time.Hour+time.Minute), time.Hour)
+	require.NoError(t, err)
+	require.True(t, compressed)
+	require.Len(t, hb.compressed, 1)
+	require.Equal(t, 0, int(hb.rt.Len()))
+	require.Equal(t, entries, drainEntries(t, hb.Iterator(context.Background(), logproto.FORWARD, 0, 10, log.NewNoopPipeline().ForStream(labels.Labels{}))))
+}
+
+func TestUnorderedHeadBlockCompressionTiers(t *testing.T) {
+	hb := newUnorderedHeadBlock(UnorderedWithStructuredMetadataHeadBlockFmt, newSymbolizer())
+	for round := 1; round <= 8; round++ {
+		for i := 0; i < 5; i++ {
+			_, err := hb.Append(int64(i), fmt.Sprintf("round %d line %d", round, i), nil)
+			require.NoError(t, err)
+		}
+		compressAll(t, hb)
+
+		// the compressed entries grow like a binary counter.
+		var expected []int
+		for tier := 3; tier >= 0; tier-- {
+			if round&(1<<tier) != 0 {
+				expected = append(expected, 5<<tier)
+			}
+		}
+		var lines []int
+		for _, c := range hb.compressed {
+			lines = append(lines, c.lines)
+		}
+		require.Equal(t, expected, lines)
+	}
+
+	entries := drainEntries(t, hb.Iterator(context.Background(), logproto.FORWARD, 0, 5, log.NewNoopPipeline().ForStream(labels.Labels{})))
+	require.Len(t, entries, 40)
+	for i, e := range entries {
+		require.Equal(t, fmt.Sprintf("round %d line %d", i%8+1, i/8), e.Line)
+	}
+	for round := 1; round <= 8; round++ {
+		dup, err := hb.Append(2, fmt.Sprintf("round %d line 2", round), nil)
+		require.NoError(t, err)
+		require.True(t, dup)
+	}
+}
+
+func TestMemChunkCompressHead(t *testing.T) {
+	chk := NewMemChunk(ChunkFormatV4, EncGZIP, UnorderedWithStructuredMetadataHeadBlockFmt, testBlockSize, testTargetSize)
+	for i := 0; i < 10; i++ {
+		_, err := chk.Append(&logproto.Entry{Timestamp: time.Unix(0, int64(10-i)), Line: fmt.Sprint(i)})
+		require.NoError(t, err)
+		if i%3 == 0 {
+			compressed, err := chk.CompressHead(time.Now(), 0)
+			require.NoError(t, err)
+			require.True(t, compressed)
+		}
+	}
+
+	it, err := chk.Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, 100), logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.Labels{}))
+	require.NoError(t, err)
+	entries := drainEntries(t, it)
+	require.Len(t, entries, 10)
+	for i, e := range entries {
+		require.Equal(t, fmt.Sprint(9-i), e.Line)
+	}
+
+	// the compressed entries are cut with the others.
+	require.NoError(t, chk.Close())
+	b, err := chk.Bytes()
+	require.NoError(t, err)
+	chk, err = NewByteChunk(b, testBlockSize, testTargetSize)
+	require.NoError(t, err)
+	it, err = chk.Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, 100), logproto.FORWARD, log.NewNoopPipeline().ForStream(labels.Labels{}))
+	require.NoError(t, err)
+	require.Equal(t, entries, drainEntries(t, it))
+}
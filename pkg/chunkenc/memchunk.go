@@ -31,6 +31,11 @@ const (
 	ChunkFormatV2
 	ChunkFormatV3
 	ChunkFormatV4
+	// ChunkFormatV5 has the layout of ChunkFormatV4, but stores the timestamps, the lines and
+	// each structured metadata name of a block in separately compressed columns. The meta of each
+	// block also ends with the length of the optional bloom of the n-grams of its lines, 0 when
+	// it has none, followed by the bloom.
+	ChunkFormatV5
 
 	blocksPerChunk = 10
 	maxLineLength  = 1024 * 1024 * 1024
@@ -134,6 +139,11 @@ type MemChunk struct {
 	encoding Encoding
 	headFmt  HeadBlockFmt
 
+	// The zstd dictionary the blocks are compressed with, for the EncZstdDict encoding.
+	zstdDictID uint32
+	// Whether the blocks are cut with the bloom of their lines.
+	blockBlooms bool
+
 	// compressed size of chunk. Set when chunk is cut or while decoding chunk from storage.
 	compressedSize int
 }
@@ -147,6 +157,11 @@ type block struct {
 
 	offset           int // The offset of the block in the chunk.
 	uncompressedSize int // Total uncompressed size in bytes when the chunk is cut.
+
+	// The bloom of the n-grams of the lines, which is optional and only written from the chunk format v5,
+	// and its filter, decoded when the block is first tested.
+	bloom       []byte
+	bloomFilter *blockBloomFilter
 }
 
 // This block holds the un-compressed entries. Once it has enough data, this is
@@ -366,6 +381,10 @@ func panicIfInvalidFormat(chunkFmt byte, head HeadBlockFmt) {
 		fmt.Println("received head fmt", head.String())
 		panic("only UnorderedWithStructuredMetadataHeadBlockFmt is supported for V4 chunks")
 	}
+	if chunkFmt == ChunkFormatV5 && head != UnorderedWithStructuredMetadataHeadBlockFmt {
+		fmt.Println("received head fmt", head.String())
+		panic("only UnorderedWithStructuredMetadataHeadBlockFmt is supported for V5 chunks")
+	}
 }
 
 // NewMemChunk returns a new in-mem chunk.
@@ -414,7 +433,7 @@ func newByteChunk(b []byte, blockSize, targetSize int, fromCheckpoint bool) (*Me
 	switch version {
 	case ChunkFormatV1:
 		bc.encoding = EncGZIP
-	case ChunkFormatV2, ChunkFormatV3, ChunkFormatV4:
+	case ChunkFormatV2, ChunkFormatV3, ChunkFormatV4, ChunkFormatV5:
 		// format v2+ has a byte for block encoding.
 		enc := Encoding(db.byte())
 		if db.err() != nil {
@@ -483,6 +502,12 @@ func newByteChunk(b []byte, blockSize, targetSize int, fromCheckpoint bool) (*Me
 			blk.uncompressedSize = db.uvarint()
 		}
 		l := db.uvarint()
+		if version >= ChunkFormatV5 {
+			if bloomLen := db.uvarint(); bloomLen > 0 {
+				blk.bloom = db.bytes(bloomLen)
+				blk.bloomFilter = newBlockBloomFilter(blk.bloom)
+			}
+		}
 
 		invalidBlockErr := validateBlock(b, blk.offset, l)
 		if invalidBlockErr != nil {
@@ -515,7 +540,7 @@ func newByteChunk(b []byte, blockSize, targetSize int, fromCheckpoint bool) (*Me
 		bc.blocks = append(bc.blocks, blk)
 
 		// Update the counter used to track the size of cut blocks.
-		bc.cutBlockSize += len(blk.b)
+		bc.cutBlockSize += len(blk.b) + len(blk.bloom)
 
 		if db.err() != nil {
 			return nil, errors.Wrap(db.err(), "decoding block meta")
@@ -543,6 +568,16 @@ func newByteChunk(b []byte, blockSize, targetSize int, fromCheckpoint bool) (*Me
 		}
 	}
 
+	if bc.encoding == EncZstdDict && len(bc.blocks) > 0 {
+		// the next blocks are compressed with the dictionary of the last one.
+		id, _ := binary.Uvarint(bc.blocks[len(bc.blocks)-1].b)
+		bc.zstdDictID = uint32(id)
+	}
+	if len(bc.blocks) > 0 {
+		// the next blocks are cut with a bloom if the last one was.
+		bc.blockBlooms = len(bc.blocks[len(bc.blocks)-1].bloom) > 0
+	}
+
 	return bc, nil
 }
 
@@ -583,6 +618,9 @@ func (c *MemChunk) BytesSize() int {
 			size += binary.MaxVarintLen32 // uncompressed size
 		}
 		size += binary.MaxVarintLen32 // len(b)
+		if c.format >= ChunkFormatV5 {
+			size += binary.MaxVarintLen32 + len(b.bloom) // bloom
+		}
 	}
 
 	// blockmeta
@@ -653,7 +691,7 @@ func (c *MemChunk) writeTo(w io.Writer, forCheckpoint bool) (int64, error) {
 			}
 		} else {
 			var err error
-			n, crcHash, err = c.symbolizer.SerializeTo(w, GetWriterPool(c.encoding))
+			n, crcHash, err = c.symbolizer.SerializeTo(w, c.writerPool())
 			if err != nil {
 				return offset, errors.Wrap(err, "write structured metadata")
 			}
@@ -700,6 +738,10 @@ func (c *MemChunk) writeTo(w io.Writer, forCheckpoint bool) (int64, error) {
 			eb.putUvarint(b.uncompressedSize)
 		}
 		eb.putUvarint(len(b.b))
+		if c.format >= ChunkFormatV5 {
+			eb.putUvarint(len(b.bloom))
+			eb.putBytes(b.bloom)
+		}
 	}
 	metasLen := len(eb.get())
 	eb.putHash(crc32Hash)
@@ -935,17 +977,52 @@ func (c *MemChunk) ConvertHead(desired HeadBlockFmt) error {
 	return nil
 }
 
+// UseZstdDictionary sets the zstd dictionary the next blocks of an EncZstdDict chunk are compressed with.
+// The blocks are compressed without dictionary if it isn't registered in ZstdDictionaries.
+func (c *MemChunk) UseZstdDictionary(id uint32) {
+	c.zstdDictID = id
+}
+
+// UseBlockBlooms sets whether the next blocks are cut with the bloom of the n-grams of their lines,
+// which lets the queries skip the blocks which can't match their line filters.
+// The blooms are only written from the chunk format v5.
+func (c *MemChunk) UseBlockBlooms(enabled bool) {
+	c.blockBlooms = enabled
+}
+
+func (c *MemChunk) writerPool() WriterPool {
+	if c.encoding == EncZstdDict {
+		return ZstdDictionaries.WriterPool(c.zstdDictID)
+	}
+	return GetWriterPool(c.encoding)
+}
+
 // cut a new block and add it to finished blocks.
 func (c *MemChunk) cut() error {
 	if c.head.IsEmpty() {
 		return nil
 	}
 
-	b, err := c.head.Serialise(GetWriterPool(c.encoding))
+	var (
+		b   []byte
+		err error
+	)
+	if c.format >= ChunkFormatV5 {
+		b, err = serialiseColumns(c.head, c.writerPool())
+	} else {
+		b, err = c.head.Serialise(c.writerPool())
+	}
 	if err != nil {
 		return err
 	}
 
+	var bloom []byte
+	if c.blockBlooms && c.format >= ChunkFormatV5 {
+		if bloom, err = buildBlockBloom(c.head, uint(8*float64(len(b))*blockBloomMaxSizeRatio)); err != nil {
+			return err
+		}
+	}
+
 	mint, maxt := c.head.Bounds()
 	c.blocks = append(c.blocks, block{
 		b:                b,
@@ -953,9 +1030,12 @@ func (c *MemChunk) cut() error {
 		mint:             mint,
 		maxt:             maxt,
 		uncompressedSize: c.head.UncompressedSize(),
+		bloom:            bloom,
+		bloomFilter:      newBlockBloomFilter(bloom),
 	})
 
-	c.cutBlockSize += len(b)
+	// the blooms are written uncompressed after their block, so they count toward the target size too.
+	c.cutBlockSize += len(b) + len(bloom)
 
 	c.head.Reset()
 	return nil
@@ -1145,6 +1225,8 @@ func (c *MemChunk) Rebound(start, end time.Time, filter filter.Func) (Chunk, err
 		// For target chunk size I am using compressed size of original chunk since the newChunk should anyways be lower in size than that.
 		newChunk = NewMemChunk(c.format, c.Encoding(), c.headFmt, defaultBlockSize, c.CompressedSize())
 	}
+	newChunk.UseZstdDictionary(c.zstdDictID)
+	newChunk.UseBlockBlooms(c.blockBlooms)
 
 	for itr.Next() {
 		entry := itr.At()
@@ -1179,14 +1261,14 @@ type encBlock struct {
 }
 
 func (b encBlock) Iterator(ctx context.Context, pipeline log.StreamPipeline) iter.EntryIterator {
-	if len(b.b) == 0 {
+	if len(b.b) == 0 || !b.mayMatch(pipeline) {
 		return iter.NoopEntryIterator
 	}
 	return newEntryIterator(ctx, GetReaderPool(b.enc), b.b, pipeline, b.format, b.symbolizer)
 }
 
 func (b encBlock) SampleIterator(ctx context.Context, extractor log.StreamSampleExtractor) iter.SampleIterator {
-	if len(b.b) == 0 {
+	if len(b.b) == 0 || !b.mayMatch(extractor) {
 		return iter.NoopSampleIterator
 	}
 	return newSampleIterator(ctx, GetReaderPool(b.enc), b.b, b.format, extractor, b.symbolizer)
@@ -1587,6 +1669,10 @@ func (si *bufferedIterator) moveNext() (int64, []byte, labels.Labels, bool) {
 
 func (si *bufferedIterator) Err() error { return si.err }
 
+func (si *bufferedIterator) entry() (int64, []byte, labels.Labels) {
+	return si.currTs, si.currLine, si.currStructuredMetadata
+}
+
 func (si *bufferedIterator) Close() error {
 	if !si.closed {
 		si.closed = true
@@ -1619,16 +1705,35 @@ func (si *bufferedIterator) close() {
 	si.origBytes = nil
 }
 
+// blockReader reads the entries of a block.
+type blockReader interface {
+	Next() bool
+	Err() error
+	Close() error
+	// entry returns the timestamp, the line and the structured metadata of the current entry.
+	entry() (int64, []byte, labels.Labels)
+}
+
+// newBlockReader returns the reader of the blocks of the given chunk format.
+// The filterer, if any, lets the columnar blocks skip the lines of the entries it excludes.
+func newBlockReader(ctx context.Context, pool ReaderPool, b []byte, format byte, symbolizer *symbolizer, filterer log.StructuredMetadataFilterer) blockReader {
+	if format >= ChunkFormatV5 {
+		return newColumnarIterator(ctx, pool, b, symbolizer, filterer)
+	}
+	return newBufferedIterator(ctx, pool, b, format, symbolizer)
+}
+
 func newEntryIterator(ctx context.Context, pool ReaderPool, b []byte, pipeline log.StreamPipeline, format byte, symbolizer *symbolizer) iter.EntryIterator {
+	filterer, _ := pipeline.(log.StructuredMetadataFilterer)
 	return &entryBufferedIterator{
-		bufferedIterator: newBufferedIterator(ctx, pool, b, format, symbolizer),
-		pipeline:         pipeline,
-		stats:            stats.FromContext(ctx),
+		blockReader: newBlockReader(ctx, pool, b, format, symbolizer, filterer),
+		pipeline:    pipeline,
+		stats:       stats.FromContext(ctx),
 	}
 }
 
 type entryBufferedIterator struct {
-	*bufferedIterator
+	blockReader
 	pipeline log.StreamPipeline
 	stats    *stats.Context
 
@@ -1645,15 +1750,16 @@ func (e *entryBufferedIterator) Labels() string { return e.currLabels.String() }
 func (e *entryBufferedIterator) StreamHash() uint64 { return e.pipeline.BaseLabels().Hash() }
 
 func (e *entryBufferedIterator) Next() bool {
-	for e.bufferedIterator.Next() {
-		newLine, lbs, matches := e.pipeline.Process(e.currTs, e.currLine, e.currStructuredMetadata...)
+	for e.blockReader.Next() {
+		ts, line, structuredMetadata := e.entry()
+		newLine, lbs, matches := e.pipeline.Process(ts, line, structuredMetadata...)
 		if !matches {
 			continue
 		}
 
 		e.stats.AddPostFilterLines(1)
 		e.currLabels = lbs
-		e.cur.Timestamp = time.Unix(0, e.currTs)
+		e.cur.Timestamp = time.Unix(0, ts)
 		e.cur.Line = string(newLine)
 		e.cur.StructuredMetadata = logproto.FromLabelsToLabelAdapters(lbs.StructuredMetadata())
 		e.cur.Parsed = logproto.FromLabelsToLabelAdapters(lbs.Parsed())
@@ -1668,19 +1774,20 @@ func (e *entryBufferedIterator) Close() error {
 		e.stats.SetQueryReferencedStructuredMetadata()
 	}
 
-	return e.bufferedIterator.Close()
+	return e.blockReader.Close()
 }
 
 func newSampleIterator(ctx context.Context, pool ReaderPool, b []byte, format byte, extractor log.StreamSampleExtractor, symbolizer *symbolizer) iter.SampleIterator {
+	filterer, _ := extractor.(log.StructuredMetadataFilterer)
 	return &sampleBufferedIterator{
-		bufferedIterator: newBufferedIterator(ctx, pool, b, format, symbolizer),
-		extractor:        extractor,
-		stats:            stats.FromContext(ctx),
+		blockReader: newBlockReader(ctx, pool, b, format, symbolizer, filterer),
+		extractor:   extractor,
+		stats:       stats.FromContext(ctx),
 	}
 }
 
 type sampleBufferedIterator struct {
-	*bufferedIterator
+	blockReader
 
 	extractor log.StreamSampleExtractor
 	stats     *stats.Context
@@ -1690,16 +1797,17 @@ type sampleBufferedIterator struct {
 }
 
 func (e *sampleBufferedIterator) Next() bool {
-	for e.bufferedIterator.Next() {
-		val, labels, ok := e.extractor.Process(e.currTs, e.currLine, e.currStructuredMetadata...)
+	for e.blockReader.Next() {
+		ts, line, structuredMetadata := e.entry()
+		val, labels, ok := e.extractor.Process(ts, line, structuredMetadata...)
 		if !ok {
 			continue
 		}
 		e.stats.AddPostFilterLines(1)
 		e.currLabels = labels
 		e.cur.Value = val
-		e.cur.Hash = xxhash.Sum64(e.currLine)
-		e.cur.Timestamp = e.currTs
+		e.cur.Hash = xxhash.Sum64(line)
+		e.cur.Timestamp = ts
 		return true
 	}
 	return false
@@ -1710,7 +1818,7 @@ func (e *sampleBufferedIterator) Close() error {
 		e.stats.SetQueryReferencedStructuredMetadata()
 	}
 
-	return e.bufferedIterator.Close()
+	return e.blockReader.Close()
 }
 
 func (e *sampleBufferedIterator) Labels() string { return e.currLabels.String() }
@@ -41,6 +41,7 @@ var testEncoding = []Encoding{
 	EncSnappy,
 	EncFlate,
 	EncZstd,
+	EncZstdDict,
 }
 
 var (
@@ -74,6 +75,10 @@ var (
 			headBlockFmt: UnorderedWithStructuredMetadataHeadBlockFmt,
 			chunkFormat:  ChunkFormatV4,
 		},
+		{
+			headBlockFmt: UnorderedWithStructuredMetadataHeadBlockFmt,
+			chunkFormat:  ChunkFormatV5,
+		},
 	}
 )
 
@@ -2102,6 +2107,10 @@ func TestDecodeChunkIncorrectBlockOffset(t *testing.T) {
 							eb.putUvarint(b.uncompressedSize)
 						}
 						eb.putUvarint(len(b.b))
+						if chk.format >= ChunkFormatV5 {
+							eb.putUvarint(len(b.bloom))
+							eb.putBytes(b.bloom)
+						}
 					}
 					metasLen := len(eb.get())
 					eb.putHash(crc32Hash)
@@ -106,6 +106,8 @@ func GetReaderPool(enc Encoding) ReaderPool {
 		return &Flate
 	case EncZstd:
 		return &Zstd
+	case EncZstdDict:
+		return &ZstdDict
 	default:
 		panic("unknown encoding")
 	}
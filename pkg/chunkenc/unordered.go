@@ -62,6 +62,15 @@ type unorderedHeadBlock struct {
 	lines      int   // number of entries
 	size       int   // size of uncompressed bytes.
 	mint, maxt int64 // upper and lower bounds
+
+	// The entries of the previous compressions of the head block, oldest first.
+	// The lines, size and bounds above account for them too.
+	compressed []compressedEntries
+	// The generation of the appended entries, incremented by the compressions which observe new entries,
+	// to compress the entries by age. The entries of the generations before compressedGen are compressed.
+	gen, compressedGen uint32
+	markedLines        int          // lines at the last increment of the generation.
+	appendMarks        []appendMark // oldest first
 }
 
 func newUnorderedHeadBlock(headBlockFmt HeadBlockFmt, symbolizer *symbolizer) *unorderedHeadBlock {
@@ -98,6 +107,7 @@ func (hb *unorderedHeadBlock) Reset() {
 type nsEntry struct {
 	line                      string
 	structuredMetadataSymbols symbols
+	gen                       uint32
 }
 
 // collection of entries belonging to the same nanosecond
@@ -125,6 +135,9 @@ func (hb *unorderedHeadBlock) Append(ts int64, line string, structuredMetadata l
 	// Then, we detect if we've displaced any existing entries, and
 	// append the new one to the existing, preallocated slice.
 	// If not, we create a slice with one entry.
+	if hb.isCompressedDuplicate(ts, line) {
+		return true, nil
+	}
 	e := &nsEntries{
 		ts: ts,
 	}
@@ -139,9 +152,9 @@ func (hb *unorderedHeadBlock) Append(ts int64, line string, structuredMetadata l
 				return true, nil
 			}
 		}
-		e.entries = append(displaced[0].(*nsEntries).entries, nsEntry{line, hb.symbolizer.Add(structuredMetadata)})
+		e.entries = append(displaced[0].(*nsEntries).entries, nsEntry{line, hb.symbolizer.Add(structuredMetadata), hb.gen})
 	} else {
-		e.entries = []nsEntry{{line, hb.symbolizer.Add(structuredMetadata)}}
+		e.entries = []nsEntry{{line, hb.symbolizer.Add(structuredMetadata), hb.gen}}
 	}
 
 	// Update hb metdata
@@ -179,6 +192,15 @@ func (i interval) LowAtDimension(_ uint64) int64 { return i.mint }
 // or [from, through) in nanoseconds
 func (i interval) HighAtDimension(_ uint64) int64 { return i.maxt - 1 }
 
+// forEntriesFunc iterates over entries of a head block, see forEntries.
+type forEntriesFunc func(
+	ctx context.Context,
+	direction logproto.Direction,
+	mint,
+	maxt int64,
+	entryFn func(*stats.Context, int64, string, symbols) error,
+) error
+
 // helper for base logic across {Entry,Sample}Iterator
 func (hb *unorderedHeadBlock) forEntries(
 	ctx context.Context,
@@ -190,7 +212,20 @@ func (hb *unorderedHeadBlock) forEntries(
 	if hb.IsEmpty() || (maxt < hb.mint || hb.maxt < mint) {
 		return
 	}
+	if len(hb.compressed) > 0 {
+		return hb.forMergedEntries(ctx, direction, mint, maxt, entryFn)
+	}
+	return hb.forUncompressedEntries(ctx, direction, mint, maxt, entryFn)
+}
 
+// forUncompressedEntries iterates over the entries appended since the last compression of the head block.
+func (hb *unorderedHeadBlock) forUncompressedEntries(
+	ctx context.Context,
+	direction logproto.Direction,
+	mint,
+	maxt int64,
+	entryFn func(*stats.Context, int64, string, symbols) error,
+) (err error) {
 	entries := hb.rt.Query(interval{
 		mint: mint,
 		maxt: maxt,
@@ -374,6 +409,11 @@ func (hb *unorderedHeadBlock) SampleIterator(
 // nolint:unused
 // serialise is used in creating an ordered, compressed block from an unorderedHeadBlock
 func (hb *unorderedHeadBlock) Serialise(pool WriterPool) ([]byte, error) {
+	return hb.serialise(pool, hb.forEntries)
+}
+
+// serialise compresses the entries iterated by forEntries in the format of the blocks.
+func (hb *unorderedHeadBlock) serialise(pool WriterPool, forEntries forEntriesFunc) ([]byte, error) {
 	inBuf := serializeBytesBufferPool.Get().(*bytes.Buffer)
 	defer func() {
 		inBuf.Reset()
@@ -392,7 +432,7 @@ func (hb *unorderedHeadBlock) Serialise(pool WriterPool) ([]byte, error) {
 	compressedWriter := pool.GetWriter(outBuf)
 	defer pool.PutWriter(compressedWriter)
 
-	_ = hb.forEntries(
+	_ = forEntries(
 		context.Background(),
 		logproto.FORWARD,
 		0,
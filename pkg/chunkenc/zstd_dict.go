@@ -0,0 +1,310 @@
+package chunkenc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/sync/singleflight"
+)
+
+// ZstdDictionaries holds the zstd dictionaries the blocks of the EncZstdDict chunks can be compressed with.
+// A dictionary has to be registered, or loaded by the loader of the registry, before the blocks compressed with it can be read.
+var ZstdDictionaries = NewZstdDictionaryRegistry()
+
+// ZstdDict is the compression pool of the EncZstdDict encoding.
+// Each block starts with the ID of the dictionary it is compressed with (uvarint), 0 meaning no dictionary,
+// followed by a zstd frame. The blocks written by this pool aren't compressed with a dictionary,
+// see ZstdDictionaryRegistry.WriterPool to compress them with one.
+var ZstdDict = ZstdDictPool{registry: ZstdDictionaries}
+
+// ErrUnknownZstdDictionary is returned when reading a block compressed with a dictionary which isn't registered and can't be loaded.
+type ErrUnknownZstdDictionary uint32
+
+func (e ErrUnknownZstdDictionary) Error() string {
+	return fmt.Sprintf("unknown zstd dictionary %d", uint32(e))
+}
+
+// TrainZstdDictionary builds a zstd dictionary of at most maxSize bytes from sampled log lines.
+// The content of the dictionary is made of the distinct samples, the last ones being kept first.
+func TrainZstdDictionary(id uint32, samples [][]byte, maxSize int) ([]byte, error) {
+	if id == 0 {
+		return nil, fmt.Errorf("the dictionary ID must not be 0")
+	}
+	seen := make(map[string]struct{}, len(samples))
+	var history []byte
+	for i := len(samples) - 1; i >= 0 && len(history) < maxSize; i-- {
+		sample := samples[i]
+		if _, ok := seen[string(sample)]; ok || len(history)+len(sample) > maxSize {
+			continue
+		}
+		seen[string(sample)] = struct{}{}
+		// the most useful content goes at the end of the dictionary, where the offsets are the shortest.
+		history = append(append(make([]byte, 0, len(history)+len(sample)), sample...), history...)
+	}
+	return zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: samples,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+		Level:    zstd.SpeedDefault,
+	})
+}
+
+// ZstdDictionaryRegistry holds zstd dictionaries by ID.
+// The dictionaries which aren't registered are loaded when they are used if the registry has a loader,
+// and the least recently used ones are evicted once the registry holds its maximum number of dictionaries.
+type ZstdDictionaryRegistry struct {
+	mtx   sync.Mutex
+	pools *simplelru.LRU[uint32, *zstdDictPool]
+	load  func(id uint32) ([]byte, error)
+
+	// loads deduplicates the concurrent loads of a dictionary, the loads of distinct dictionaries run concurrently.
+	loads singleflight.Group
+}
+
+// NewZstdDictionaryRegistry creates a registry without loader nor maximum number of dictionaries.
+func NewZstdDictionaryRegistry() *ZstdDictionaryRegistry {
+	pools, _ := simplelru.NewLRU[uint32, *zstdDictPool](math.MaxInt32, nil)
+	return &ZstdDictionaryRegistry{pools: pools}
+}
+
+// SetLoader sets the function loading the dictionaries which aren't registered, and the maximum number of dictionaries held.
+func (r *ZstdDictionaryRegistry) SetLoader(maxDictionaries int, load func(id uint32) ([]byte, error)) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.load = load
+	r.pools.Resize(maxDictionaries)
+}
+
+// Register adds a dictionary to the registry and returns its ID, which is read from the dictionary.
+// Registering a dictionary again is a no-op.
+func (r *ZstdDictionaryRegistry) Register(dict []byte) (uint32, error) {
+	p, err := r.register(dict)
+	if err != nil {
+		return 0, err
+	}
+	return p.id, nil
+}
+
+func (r *ZstdDictionaryRegistry) register(dict []byte) (*zstdDictPool, error) {
+	info, err := zstd.InspectDictionary(dict)
+	if err != nil {
+		return nil, fmt.Errorf("invalid zstd dictionary: %w", err)
+	}
+	id := info.ID()
+	if id == 0 {
+		return nil, fmt.Errorf("invalid zstd dictionary: the dictionary ID must not be 0")
+	}
+	if p, ok := r.get(id); ok {
+		return p, nil
+	}
+	// check the dictionary can be used before it is registered, the pools can't return errors.
+	if _, err := zstd.NewWriter(nil, zstd.WithEncoderDict(dict)); err != nil {
+		return nil, fmt.Errorf("invalid zstd dictionary: %w", err)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if p, ok := r.pools.Get(id); ok {
+		return p, nil
+	}
+	p := &zstdDictPool{id: id, dict: dict}
+	r.pools.Add(id, p)
+	return p, nil
+}
+
+// Has tells if a dictionary is registered.
+func (r *ZstdDictionaryRegistry) Has(id uint32) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.pools.Contains(id)
+}
+
+// Len returns the number of registered dictionaries.
+func (r *ZstdDictionaryRegistry) Len() int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.pools.Len()
+}
+
+// WriterPool returns the pool compressing the blocks with the given dictionary, which is loaded if needed.
+// The blocks aren't compressed with a dictionary if it can't be loaded.
+func (r *ZstdDictionaryRegistry) WriterPool(id uint32) WriterPool {
+	if id == 0 {
+		return &ZstdDict
+	}
+	p, err := r.pool(id)
+	if err != nil {
+		return &ZstdDict
+	}
+	return p
+}
+
+func (r *ZstdDictionaryRegistry) get(id uint32) (*zstdDictPool, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.pools.Get(id)
+}
+
+// pool returns the pool of a dictionary, loading it if it isn't registered.
+func (r *ZstdDictionaryRegistry) pool(id uint32) (*zstdDictPool, error) {
+	if p, ok := r.get(id); ok {
+		return p, nil
+	}
+	r.mtx.Lock()
+	load := r.load
+	r.mtx.Unlock()
+	if load == nil {
+		return nil, ErrUnknownZstdDictionary(id)
+	}
+
+	v, err, _ := r.loads.Do(strconv.FormatUint(uint64(id), 10), func() (interface{}, error) {
+		if p, ok := r.get(id); ok {
+			return p, nil
+		}
+		dict, err := load(id)
+		if err != nil {
+			return nil, fmt.Errorf("loading zstd dictionary %d: %w", id, err)
+		}
+		p, err := r.register(dict)
+		if err != nil {
+			return nil, err
+		}
+		if p.id != id {
+			return nil, fmt.Errorf("loading zstd dictionary %d: the dictionary has ID %d", id, p.id)
+		}
+		return p, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*zstdDictPool), nil
+}
+
+// ZstdDictPool reads the blocks of the EncZstdDict encoding, whatever the dictionary they are compressed with.
+type ZstdDictPool struct {
+	registry *ZstdDictionaryRegistry
+	noDict   zstdDictPool
+}
+
+// GetReader reads the dictionary ID of the block and returns a reader decompressing it with this dictionary.
+func (pool *ZstdDictPool) GetReader(src io.Reader) (io.Reader, error) {
+	br, ok := src.(io.ByteScanner)
+	if !ok {
+		b := bufio.NewReader(src)
+		br, src = b, b
+	}
+	id, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("reading zstd dictionary ID: %w", err)
+	}
+	p := &pool.noDict
+	if id != 0 {
+		if p, err = pool.registry.pool(uint32(id)); err != nil {
+			return nil, err
+		}
+	}
+	// the dictionary ID is always followed by a frame, even when the block is empty.
+	if _, err := br.ReadByte(); err != nil {
+		return nil, fmt.Errorf("reading zstd frame: %w", err)
+	}
+	if err := br.UnreadByte(); err != nil {
+		return nil, err
+	}
+	return p.GetReader(src)
+}
+
+// PutReader places back in the pool a CompressionReader
+func (pool *ZstdDictPool) PutReader(reader io.Reader) {
+	r := reader.(*zstdDictReader)
+	r.pool.PutReader(r)
+}
+
+// GetWriter returns a writer compressing without dictionary.
+func (pool *ZstdDictPool) GetWriter(dst io.Writer) io.WriteCloser {
+	return pool.noDict.GetWriter(dst)
+}
+
+// PutWriter places back in the pool a CompressionWriter
+func (pool *ZstdDictPool) PutWriter(writer io.WriteCloser) {
+	w := writer.(*zstdDictWriter)
+	w.pool.PutWriter(w)
+}
+
+// zstdDictPool pools the zstd readers and writers of a dictionary.
+type zstdDictPool struct {
+	id      uint32
+	dict    []byte
+	readers sync.Pool
+	writers sync.Pool
+}
+
+type zstdDictReader struct {
+	*zstd.Decoder
+	pool *zstdDictPool
+}
+
+type zstdDictWriter struct {
+	*zstd.Encoder
+	pool *zstdDictPool
+}
+
+// GetReader returns a reader decompressing src, whose dictionary ID is already read.
+func (pool *zstdDictPool) GetReader(src io.Reader) (io.Reader, error) {
+	if r := pool.readers.Get(); r != nil {
+		reader := r.(*zstdDictReader)
+		if err := reader.Reset(src); err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	var opts []zstd.DOption
+	if pool.dict != nil {
+		opts = append(opts, zstd.WithDecoderDicts(pool.dict))
+	}
+	decoder, err := zstd.NewReader(src, opts...)
+	if err != nil {
+		return nil, err
+	}
+	runtime.SetFinalizer(decoder, (*zstd.Decoder).Close)
+	return &zstdDictReader{Decoder: decoder, pool: pool}, nil
+}
+
+func (pool *zstdDictPool) PutReader(reader io.Reader) {
+	pool.readers.Put(reader)
+}
+
+// GetWriter returns a writer writing the dictionary ID to dst, then the zstd frame.
+func (pool *zstdDictPool) GetWriter(dst io.Writer) io.WriteCloser {
+	var buf [binary.MaxVarintLen32]byte
+	// the errors are returned by the writes of the frame.
+	_, _ = dst.Write(buf[:binary.PutUvarint(buf[:], uint64(pool.id))])
+
+	if w := pool.writers.Get(); w != nil {
+		writer := w.(*zstdDictWriter)
+		writer.Reset(dst)
+		return writer
+	}
+	// the empty blocks are written as empty frames, so that a block can't be only a dictionary ID.
+	opts := []zstd.EOption{zstd.WithZeroFrames(true)}
+	if pool.dict != nil {
+		opts = append(opts, zstd.WithEncoderDict(pool.dict))
+	}
+	encoder, err := zstd.NewWriter(dst, opts...)
+	if err != nil {
+		panic(err) // never happens, the dictionaries are checked when registered.
+	}
+	return &zstdDictWriter{Encoder: encoder, pool: pool}
+}
+
+func (pool *zstdDictPool) PutWriter(writer io.WriteCloser) {
+	pool.writers.Put(writer)
+}
@@ -0,0 +1,167 @@
+package chunkenc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func zstdDictTestLine(i int) string {
+	return fmt.Sprintf(`level=info ts=2024-06-01T10:%02d:%02d.%03dZ caller=handler.go:%d msg="request served" method=GET path=/api/v1/users/%d status=200 duration=%dms`, i/60%60, i%60, i%1000, 100+i%7, i, i%250)
+}
+
+func TestZstdDictionary(t *testing.T) {
+	samples := make([][]byte, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		samples = append(samples, []byte(zstdDictTestLine(i)))
+	}
+	dict, err := TrainZstdDictionary(424242, samples, 16<<10)
+	require.NoError(t, err)
+
+	id, err := ZstdDictionaries.Register(dict)
+	require.NoError(t, err)
+	require.Equal(t, uint32(424242), id)
+	require.True(t, ZstdDictionaries.Has(id))
+
+	// small blocks compress better with the dictionary.
+	newChunk := func(dictID uint32) *MemChunk {
+		chk := NewMemChunk(ChunkFormatV4, EncZstdDict, UnorderedWithStructuredMetadataHeadBlockFmt, 4<<10, 0)
+		chk.UseZstdDictionary(dictID)
+		for i := 2000; i < 2200; i++ {
+			_, err := chk.Append(&logproto.Entry{Timestamp: time.Unix(0, int64(i)), Line: zstdDictTestLine(i)})
+			require.NoError(t, err)
+		}
+		require.NoError(t, chk.Close())
+		return chk
+	}
+	withDict, withoutDict := newChunk(id), newChunk(0)
+	require.Less(t, withDict.CompressedSize(), withoutDict.CompressedSize())
+
+	for _, chk := range []*MemChunk{withDict, withoutDict} {
+		b, err := chk.Bytes()
+		require.NoError(t, err)
+		chk, err = NewByteChunk(b, 4<<10, 0)
+		require.NoError(t, err)
+
+		it, err := chk.Iterator(context.Background(), time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, noopStreamPipeline)
+		require.NoError(t, err)
+		i := 2000
+		for ; it.Next(); i++ {
+			require.Equal(t, zstdDictTestLine(i), it.At().Line)
+		}
+		require.NoError(t, it.Close())
+		require.Equal(t, 2200, i)
+	}
+
+	b, err := withDict.Bytes()
+	require.NoError(t, err)
+	chk, err := NewByteChunk(b, 4<<10, 0)
+	require.NoError(t, err)
+	require.Equal(t, id, chk.zstdDictID, "the chunk keeps being compressed with the dictionary of its last block")
+}
+
+func TestZstdDictionary_Unknown(t *testing.T) {
+	_, err := ZstdDict.GetReader(bytes.NewReader([]byte{0xff, 0x01}))
+	require.Equal(t, ErrUnknownZstdDictionary(255), err)
+
+	_, err = ZstdDictionaries.Register([]byte("not a dictionary"))
+	require.Error(t, err)
+
+	// blocks are written without dictionary when it isn't registered.
+	require.Equal(t, &ZstdDict, ZstdDictionaries.WriterPool(123))
+}
+
+func TestZstdDictionaryRegistry_Loader(t *testing.T) {
+	samples := make([][]byte, 0, 200)
+	for i := 0; i < 200; i++ {
+		samples = append(samples, []byte(zstdDictTestLine(i)))
+	}
+	dicts := map[uint32][]byte{}
+	for _, id := range []uint32{40001, 40002} {
+		dict, err := TrainZstdDictionary(id, samples, 4<<10)
+		require.NoError(t, err)
+		dicts[id] = dict
+	}
+
+	var loads int
+	r := NewZstdDictionaryRegistry()
+	r.SetLoader(1, func(id uint32) ([]byte, error) {
+		loads++
+		dict, ok := dicts[id]
+		if !ok {
+			return nil, ErrUnknownZstdDictionary(id)
+		}
+		return dict, nil
+	})
+
+	// the dictionaries are loaded when they are used.
+	require.False(t, r.Has(40001))
+	require.NotEqual(t, &ZstdDict, r.WriterPool(40001))
+	require.True(t, r.Has(40001))
+	_, err := r.pool(40001)
+	require.NoError(t, err)
+	require.Equal(t, 1, loads)
+
+	// the least recently used dictionaries are evicted.
+	_, err = r.pool(40002)
+	require.NoError(t, err)
+	require.Equal(t, 2, loads)
+	require.False(t, r.Has(40001))
+	require.Equal(t, 1, r.Len())
+
+	_, err = r.pool(40003)
+	var unknown ErrUnknownZstdDictionary
+	require.ErrorAs(t, err, &unknown)
+	require.Equal(t, &ZstdDict, r.WriterPool(40003))
+}
+
+func TestZstdDictionaryRegistry_ConcurrentLoads(t *testing.T) {
+	samples := make([][]byte, 0, 200)
+	for i := 0; i < 200; i++ {
+		samples = append(samples, []byte(zstdDictTestLine(i)))
+	}
+	dicts := map[uint32][]byte{}
+	for _, id := range []uint32{40001, 40002} {
+		dict, err := TrainZstdDictionary(id, samples, 4<<10)
+		require.NoError(t, err)
+		dicts[id] = dict
+	}
+
+	var loads atomic.Int32
+	slow := make(chan struct{})
+	r := NewZstdDictionaryRegistry()
+	r.SetLoader(10, func(id uint32) ([]byte, error) {
+		loads.Add(1)
+		if id == 40001 {
+			<-slow
+		}
+		return dicts[id], nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.pool(40001)
+			require.NoError(t, err)
+		}()
+	}
+
+	// a slow load doesn't block the loads of the other dictionaries.
+	_, err := r.pool(40002)
+	require.NoError(t, err)
+	close(slow)
+	wg.Wait()
+	require.True(t, r.Has(40001))
+	require.Equal(t, int32(2), loads.Load(), "the concurrent loads of a dictionary are deduplicated")
+}
@@ -0,0 +1,251 @@
+// Package cardinality tracks the number of distinct values of the stream labels of each tenant,
+// so that the labels with too many values, like request IDs, can be rejected or demoted to structured metadata.
+package cardinality
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/axiomhq/hyperloglog"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// Mode is the action taken on the labels exceeding the limit of distinct values.
+type Mode string
+
+const (
+	// ModeMonitor only tracks the labels, so that they can be reported.
+	ModeMonitor Mode = "monitor"
+	// ModeReject rejects the streams with labels exceeding the limit.
+	ModeReject Mode = "reject"
+	// ModeDemote removes the labels exceeding the limit from the streams and adds them to the structured metadata of their entries.
+	ModeDemote Mode = "demote"
+
+	defaultPeriod = time.Hour
+
+	// pruneInterval is how often the tenants which didn't send any stream for two periods are evicted.
+	pruneInterval = time.Minute
+)
+
+type Config struct {
+	Mode              Mode           `yaml:"mode,omitempty" json:"mode,omitempty" doc:"description=Tracking of the distinct values of the stream labels, either monitor, reject or demote. monitor only reports the labels in the label cardinality endpoint of the distributor. reject rejects the streams whose labels exceed max_values_per_label. demote removes these labels from the streams and stores them in the structured metadata of their entries instead. Disabled when empty."`
+	MaxValuesPerLabel int            `yaml:"max_values_per_label,omitempty" json:"max_values_per_label,omitempty" doc:"description=Maximum number of distinct values of a label within the period, estimated by each distributor from the streams it receives."`
+	Period            model.Duration `yaml:"period,omitempty" json:"period,omitempty" doc:"description=Period over which the distinct values of the labels are counted. Defaults to 1h."`
+	ExemptLabels      []string       `yaml:"exempt_labels,omitempty" json:"exempt_labels,omitempty" doc:"description=Labels which are tracked but never rejected nor demoted."`
+}
+
+// Enabled tells if the labels are tracked.
+func (cfg *Config) Enabled() bool {
+	return cfg.Mode != ""
+}
+
+// Enforced tells if the labels exceeding the limit are rejected or demoted.
+func (cfg *Config) Enforced() bool {
+	return cfg.Mode == ModeReject || cfg.Mode == ModeDemote
+}
+
+func (cfg *Config) period() time.Duration {
+	if cfg.Period <= 0 {
+		return defaultPeriod
+	}
+	return time.Duration(cfg.Period)
+}
+
+func (cfg *Config) Validate() error {
+	switch cfg.Mode {
+	case "", ModeMonitor:
+	case ModeReject, ModeDemote:
+		if cfg.MaxValuesPerLabel <= 0 {
+			return fmt.Errorf("the maximum number of values per label must be positive in %s mode", cfg.Mode)
+		}
+	default:
+		return fmt.Errorf("unsupported label cardinality mode %q, it must be one of: %s, %s, %s", cfg.Mode, ModeMonitor, ModeReject, ModeDemote)
+	}
+	if cfg.Period < 0 {
+		return errors.New("the label cardinality period must not be negative")
+	}
+	return nil
+}
+
+// LabelCardinality is the estimated number of distinct values of a label.
+type LabelCardinality struct {
+	Name     string `json:"name"`
+	Values   uint64 `json:"values"`
+	Exceeded bool   `json:"exceeded"`
+}
+
+// Tracker estimates the number of distinct values of the stream labels of each tenant with HyperLogLog sketches.
+// The sketches are reset every period, and the estimates of the previous period are kept until they are exceeded,
+// so that the enforcement doesn't stop when the sketches are reset.
+type Tracker struct {
+	mtx       sync.RWMutex
+	tenants   map[string]*tenantTracker
+	lastPrune time.Time
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		tenants: map[string]*tenantTracker{},
+	}
+}
+
+// Observe records the label values of a stream and returns the names of its labels exceeding the limit.
+func (t *Tracker) Observe(tenantID string, cfg Config, lbs labels.Labels, now time.Time) []string {
+	t.prune(now)
+	tt := t.tenant(tenantID)
+
+	tt.mtx.Lock()
+	defer tt.mtx.Unlock()
+
+	if period := cfg.period(); now.Sub(tt.rotatedAt) >= period {
+		tt.rotate(now.Sub(tt.rotatedAt) < 2*period)
+		tt.rotatedAt = now
+	}
+	tt.maxValues = cfg.MaxValuesPerLabel
+	tt.period = cfg.period()
+	tt.lastSeen = now
+
+	var exceeded []string
+	lbs.Range(func(l labels.Label) {
+		ls, ok := tt.labels[l.Name]
+		if !ok {
+			ls = &labelSketch{sketch: hyperloglog.New()}
+			tt.labels[l.Name] = ls
+		}
+		if ls.sketch.Insert([]byte(l.Value)) {
+			ls.estimate = ls.sketch.Estimate()
+		}
+		if cfg.Enforced() && ls.values() > uint64(cfg.MaxValuesPerLabel) && !slices.Contains(cfg.ExemptLabels, l.Name) {
+			exceeded = append(exceeded, l.Name)
+		}
+	})
+	return exceeded
+}
+
+// TopLabels returns the labels of the tenant with the most distinct values, at most limit of them.
+func (t *Tracker) TopLabels(tenantID string, limit int) []LabelCardinality {
+	t.mtx.RLock()
+	tt, ok := t.tenants[tenantID]
+	t.mtx.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	tt.mtx.Lock()
+	defer tt.mtx.Unlock()
+
+	top := make([]LabelCardinality, 0, len(tt.labels))
+	for name, ls := range tt.labels {
+		values := ls.values()
+		top = append(top, LabelCardinality{
+			Name:     name,
+			Values:   values,
+			Exceeded: tt.maxValues > 0 && values > uint64(tt.maxValues),
+		})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Values != top[j].Values {
+			return top[i].Values > top[j].Values
+		}
+		return top[i].Name < top[j].Name
+	})
+	if limit > 0 && len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
+
+// Tenants returns the tracked tenants, sorted.
+func (t *Tracker) Tenants() []string {
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+
+	tenants := make([]string, 0, len(t.tenants))
+	for tenantID := range t.tenants {
+		tenants = append(tenants, tenantID)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+func (t *Tracker) tenant(tenantID string) *tenantTracker {
+	t.mtx.RLock()
+	tt, ok := t.tenants[tenantID]
+	t.mtx.RUnlock()
+	if ok {
+		return tt
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if tt, ok = t.tenants[tenantID]; !ok {
+		tt = &tenantTracker{labels: map[string]*labelSketch{}}
+		t.tenants[tenantID] = tt
+	}
+	return tt
+}
+
+// prune evicts the tenants which didn't send any stream for two periods, since all their estimates are outdated.
+func (t *Tracker) prune(now time.Time) {
+	t.mtx.RLock()
+	due := now.Sub(t.lastPrune) >= pruneInterval
+	t.mtx.RUnlock()
+	if !due {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if now.Sub(t.lastPrune) < pruneInterval {
+		return
+	}
+	t.lastPrune = now
+	for tenantID, tt := range t.tenants {
+		if tt.idle(now) {
+			delete(t.tenants, tenantID)
+		}
+	}
+}
+
+type tenantTracker struct {
+	mtx       sync.Mutex
+	rotatedAt time.Time
+	lastSeen  time.Time
+	period    time.Duration
+	maxValues int
+	labels    map[string]*labelSketch
+}
+
+func (tt *tenantTracker) idle(now time.Time) bool {
+	tt.mtx.Lock()
+	defer tt.mtx.Unlock()
+	return now.Sub(tt.lastSeen) >= 2*tt.period
+}
+
+// rotate resets the sketches, keeping their estimates if they are from the previous period.
+func (tt *tenantTracker) rotate(keepEstimates bool) {
+	for name, ls := range tt.labels {
+		if !keepEstimates || ls.estimate == 0 {
+			delete(tt.labels, name)
+			continue
+		}
+		ls.previousEstimate = ls.estimate
+		ls.estimate = 0
+		ls.sketch = hyperloglog.New()
+	}
+}
+
+type labelSketch struct {
+	sketch           *hyperloglog.Sketch
+	estimate         uint64
+	previousEstimate uint64
+}
+
+func (ls *labelSketch) values() uint64 {
+	return max(ls.estimate, ls.previousEstimate)
+}
@@ -0,0 +1,77 @@
+package cardinality
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker_Observe(t *testing.T) {
+	tracker := NewTracker()
+	cfg := Config{Mode: ModeReject, MaxValuesPerLabel: 10, ExemptLabels: []string{"pod"}}
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		lbs := labels.FromStrings("app", "shop", "pod", fmt.Sprintf("pod-%d", i), "request_id", fmt.Sprintf("%d", i))
+		require.Empty(t, tracker.Observe("tenant", cfg, lbs, now))
+	}
+	lbs := labels.FromStrings("app", "shop", "pod", "pod-10", "request_id", "10")
+	require.Equal(t, []string{"request_id"}, tracker.Observe("tenant", cfg, lbs, now), "exempt labels are not enforced")
+	require.Empty(t, tracker.Observe("other", cfg, lbs, now), "tenants are tracked separately")
+
+	// the estimates of the previous period are kept after the sketches are reset.
+	lbs = labels.FromStrings("app", "shop", "request_id", "11")
+	require.Equal(t, []string{"request_id"}, tracker.Observe("tenant", cfg, lbs, now.Add(time.Hour)))
+	require.Empty(t, tracker.Observe("tenant", cfg, lbs, now.Add(2*time.Hour)))
+
+	// the monitor mode doesn't enforce the limit.
+	cfg.Mode = ModeMonitor
+	for i := 0; i < 20; i++ {
+		require.Empty(t, tracker.Observe("monitored", cfg, labels.FromStrings("request_id", fmt.Sprintf("%d", i)), now))
+	}
+}
+
+func TestTracker_TopLabels(t *testing.T) {
+	tracker := NewTracker()
+	cfg := Config{Mode: ModeMonitor, MaxValuesPerLabel: 2}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		tracker.Observe("tenant", cfg, labels.FromStrings("app", "shop", "env", fmt.Sprintf("%d", i%2), "request_id", fmt.Sprintf("%d", i)), now)
+	}
+
+	require.Equal(t, []LabelCardinality{
+		{Name: "request_id", Values: 3, Exceeded: true},
+		{Name: "env", Values: 2},
+	}, tracker.TopLabels("tenant", 2))
+	require.Len(t, tracker.TopLabels("tenant", 0), 3)
+	require.Nil(t, tracker.TopLabels("unknown", 2))
+	require.Equal(t, []string{"tenant"}, tracker.Tenants())
+}
+
+func TestTracker_EvictsIdleTenants(t *testing.T) {
+	tracker := NewTracker()
+	cfg := Config{Mode: ModeMonitor, Period: model.Duration(time.Hour)}
+	now := time.Now()
+	lbs := labels.FromStrings("app", "shop")
+
+	tracker.Observe("idle", cfg, lbs, now)
+	tracker.Observe("active", cfg, lbs, now)
+	tracker.Observe("active", cfg, lbs, now.Add(90*time.Minute))
+	require.Equal(t, []string{"active", "idle"}, tracker.Tenants(), "the tenants are kept for two periods")
+
+	tracker.Observe("active", cfg, lbs, now.Add(2*time.Hour))
+	require.Equal(t, []string{"active"}, tracker.Tenants())
+	require.Nil(t, tracker.TopLabels("idle", 0))
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, (&Config{}).Validate())
+	require.NoError(t, (&Config{Mode: ModeMonitor}).Validate())
+	require.NoError(t, (&Config{Mode: ModeDemote, MaxValuesPerLabel: 100, Period: model.Duration(time.Hour)}).Validate())
+	require.EqualError(t, (&Config{Mode: "drop"}).Validate(), `unsupported label cardinality mode "drop", it must be one of: monitor, reject, demote`)
+	require.EqualError(t, (&Config{Mode: ModeReject}).Validate(), "the maximum number of values per label must be positive in reject mode")
+}
@@ -0,0 +1,168 @@
+package distributor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+// dedupPruneInterval is the interval at which the caches of the tenants which stopped pushing are removed.
+const dedupPruneInterval = time.Minute
+
+// dedupKey identifies an entry of a stream.
+type dedupKey struct {
+	stream    uint64
+	timestamp int64
+	line      uint64
+}
+
+func newDedupKey(streamHash uint64, entry logproto.Entry) dedupKey {
+	return dedupKey{
+		stream:    streamHash,
+		timestamp: entry.Timestamp.UnixNano(),
+		line:      xxhash.Sum64String(entry.Line),
+	}
+}
+
+// deduplicator keeps the keys of the entries pushed by each tenant within its deduplication window,
+// so that the identical entries pushed by replicated senders can be dropped.
+// The keys are pending until their push succeeds, and only the entries whose key was pushed are dropped,
+// so that the duplicates of an entry whose push fails are still pushed.
+type deduplicator struct {
+	mtx       sync.Mutex
+	tenants   map[string]*dedupCache
+	lastPrune time.Time
+}
+
+func newDeduplicator() *deduplicator {
+	return &deduplicator{
+		tenants: map[string]*dedupCache{},
+	}
+}
+
+// cache returns the cache of the tenant for the given window and maximum number of keys.
+func (d *deduplicator) cache(tenantID string, window time.Duration, maxKeys int, now time.Time) *dedupCache {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	if now.Sub(d.lastPrune) >= dedupPruneInterval {
+		for id, c := range d.tenants {
+			if c.expired(now) {
+				delete(d.tenants, id)
+			}
+		}
+		d.lastPrune = now
+	}
+
+	c, ok := d.tenants[tenantID]
+	if !ok {
+		c = &dedupCache{rotatedAt: now, current: map[dedupKey]dedupState{}}
+		d.tenants[tenantID] = c
+	}
+	c.rotate(window, maxKeys, now)
+	return c
+}
+
+// dedupCache keeps the keys of the entries of a tenant in two generations which are rotated every window,
+// so that the keys are kept for at least a window and at most two.
+// At most maxKeys keys are kept, the keys added past it are not recorded.
+type dedupCache struct {
+	mtx       sync.Mutex
+	window    time.Duration
+	maxKeys   int
+	rotatedAt time.Time
+	current   map[dedupKey]dedupState
+	previous  map[dedupKey]dedupState
+}
+
+// dedupState is the state of the key of an entry.
+type dedupState struct {
+	pending int  // number of pushes of the entry in progress
+	pushed  bool // whether a push of the entry succeeded
+}
+
+func (c *dedupCache) rotate(window time.Duration, maxKeys int, now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.window = window
+	c.maxKeys = maxKeys
+	elapsed := now.Sub(c.rotatedAt)
+	switch {
+	case elapsed >= 2*window:
+		c.previous = nil
+	case elapsed >= window:
+		c.previous = c.current
+	default:
+		return
+	}
+	c.current = map[dedupKey]dedupState{}
+	c.rotatedAt = now
+}
+
+// add records the key as pending and returns false if it was already pushed within the window.
+func (c *dedupCache) add(key dedupKey) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, keys := range []map[dedupKey]dedupState{c.current, c.previous} {
+		if state, ok := keys[key]; ok {
+			if state.pushed {
+				return false
+			}
+			// the entry may still fail to be pushed, so its duplicates are pushed too.
+			state.pending++
+			keys[key] = state
+			return true
+		}
+	}
+	if c.maxKeys > 0 && len(c.current)+len(c.previous) >= c.maxKeys {
+		return true
+	}
+	c.current[key] = dedupState{pending: 1}
+	return true
+}
+
+// commit marks the keys of entries which were pushed, so that their duplicates are dropped.
+func (c *dedupCache) commit(keys []dedupKey) {
+	c.update(keys, true)
+}
+
+// forget removes the keys of entries which failed to be pushed, unless they are pushed by other pushes,
+// so that they are accepted when retried.
+func (c *dedupCache) forget(keys []dedupKey) {
+	c.update(keys, false)
+}
+
+func (c *dedupCache) update(keys []dedupKey, pushed bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, key := range keys {
+		for _, m := range []map[dedupKey]dedupState{c.current, c.previous} {
+			state, ok := m[key]
+			if !ok {
+				continue
+			}
+			if state.pending > 0 {
+				state.pending--
+			}
+			state.pushed = state.pushed || pushed
+			if state.pending == 0 && !state.pushed {
+				delete(m, key)
+			} else {
+				m[key] = state
+			}
+		}
+	}
+}
+
+func (c *dedupCache) expired(now time.Time) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return now.Sub(c.rotatedAt) >= 2*c.window
+}
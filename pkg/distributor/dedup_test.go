@@ -0,0 +1,83 @@
+package distributor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestDeduplicator(t *testing.T) {
+	d := newDeduplicator()
+	now := time.Now()
+	window := time.Minute
+	key := newDedupKey(1, logproto.Entry{Timestamp: now, Line: "a"})
+
+	require.True(t, d.cache("tenant", window, 0, now).add(key))
+	d.cache("tenant", window, 0, now).commit([]dedupKey{key})
+	require.False(t, d.cache("tenant", window, 0, now).add(key))
+	require.True(t, d.cache("other", window, 0, now).add(key), "tenants are deduplicated separately")
+	require.True(t, d.cache("tenant", window, 0, now).add(newDedupKey(2, logproto.Entry{Timestamp: now, Line: "a"})))
+	require.True(t, d.cache("tenant", window, 0, now).add(newDedupKey(1, logproto.Entry{Timestamp: now, Line: "b"})))
+
+	// the keys are kept for at least a window.
+	require.False(t, d.cache("tenant", window, 0, now.Add(window)).add(key))
+	// and forgotten after two.
+	require.True(t, d.cache("tenant", window, 0, now.Add(3*window)).add(key))
+
+	c := d.cache("tenant", window, 0, now.Add(3*window))
+	c.forget([]dedupKey{key})
+	require.Empty(t, c.current, "the keys of failed pushes are forgotten")
+	require.True(t, c.add(key), "forgotten keys are accepted again")
+
+	// the caches of the tenants which stopped pushing are removed.
+	d.cache("tenant", window, 0, now.Add(10*window))
+	require.NotContains(t, d.tenants, "other")
+}
+
+func TestDeduplicator_MaxKeys(t *testing.T) {
+	d := newDeduplicator()
+	now := time.Now()
+	window := time.Minute
+	first := newDedupKey(1, logproto.Entry{Timestamp: now, Line: "a"})
+	second := newDedupKey(1, logproto.Entry{Timestamp: now, Line: "b"})
+
+	c := d.cache("tenant", window, 1, now)
+	require.True(t, c.add(first))
+	c.commit([]dedupKey{first})
+	require.False(t, c.add(first))
+	// the keys past the limit are accepted without being recorded.
+	require.True(t, c.add(second))
+	require.True(t, c.add(second))
+
+	// the limit counts the keys of both generations.
+	c = d.cache("tenant", window, 1, now.Add(window))
+	require.False(t, c.add(first))
+	require.True(t, c.add(second))
+	require.True(t, c.add(second))
+}
+
+func TestDeduplicator_PendingKeys(t *testing.T) {
+	d := newDeduplicator()
+	now := time.Now()
+	window := time.Minute
+	key := newDedupKey(1, logproto.Entry{Timestamp: now, Line: "a"})
+	c := d.cache("tenant", window, 0, now)
+
+	// the duplicates of an entry whose push is in progress are pushed too.
+	require.True(t, c.add(key))
+	require.True(t, c.add(key))
+
+	// the key is kept when one of the pushes fails, until the other one succeeds.
+	c.forget([]dedupKey{key})
+	require.True(t, c.add(key))
+	c.forget([]dedupKey{key})
+	c.commit([]dedupKey{key})
+	require.False(t, c.add(key))
+
+	// a pushed entry isn't forgotten when a later push of it fails.
+	c.forget([]dedupKey{key})
+	require.False(t, c.add(key))
+}
@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 	"unsafe"
@@ -39,8 +40,13 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/analytics"
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/distributor/cardinality"
 	"github.com/grafana/loki/v3/pkg/distributor/clientpool"
+	"github.com/grafana/loki/v3/pkg/distributor/ingestionpipeline"
+	"github.com/grafana/loki/v3/pkg/distributor/redaction"
+	"github.com/grafana/loki/v3/pkg/distributor/sampling"
 	"github.com/grafana/loki/v3/pkg/distributor/shardstreams"
+	"github.com/grafana/loki/v3/pkg/distributor/syslog"
 	"github.com/grafana/loki/v3/pkg/distributor/writefailures"
 	"github.com/grafana/loki/v3/pkg/ingester"
 	"github.com/grafana/loki/v3/pkg/ingester/client"
@@ -88,6 +94,10 @@ type Config struct {
 	WriteFailuresLogging writefailures.Cfg `yaml:"write_failures_logging" doc:"description=Customize the logging of write failures."`
 
 	OTLPConfig push.GlobalOTLPConfig `yaml:"otlp_config"`
+
+	Syslog syslog.Config `yaml:"syslog" doc:"description=Receiver of syslog messages over TCP and UDP. Disabled unless a listen address is set."`
+
+	SpillQueue SpillQueueConfig `yaml:"spill_queue" doc:"description=Queue on local disk of the pushes failing because the ingesters are unavailable, replayed once they recover."`
 }
 
 // RegisterFlags registers distributor-related flags.
@@ -96,6 +106,8 @@ func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
 	cfg.DistributorRing.RegisterFlags(fs)
 	cfg.RateStore.RegisterFlagsWithPrefix("distributor.rate-store", fs)
 	cfg.WriteFailuresLogging.RegisterFlagsWithPrefix("distributor.write-failures-logging", fs)
+	cfg.Syslog.RegisterFlagsWithPrefix("distributor.syslog", fs)
+	cfg.SpillQueue.RegisterFlagsWithPrefix("distributor.spill-queue", fs)
 }
 
 // RateStore manages the ingestion rate of streams, populated by data fetched from ingesters.
@@ -133,6 +145,10 @@ type Distributor struct {
 	// Per-user rate limiter.
 	ingestionRateLimiter *limiter.RateLimiter
 	labelCache           *lru.Cache
+	ingestionPipelines   *ingestionpipeline.Cache
+	deduplicator         *deduplicator
+	labelCardinality     *cardinality.Tracker
+	spillQueue           *spillQueue
 
 	// Push failures rate limiter.
 	writeFailuresManager *writefailures.Manager
@@ -144,6 +160,11 @@ type Distributor struct {
 	ingesterAppendTimeouts *prometheus.CounterVec
 	replicationFactor      prometheus.Gauge
 	streamShardCount       prometheus.Counter
+	redactedBytes          *prometheus.CounterVec
+	deduplicatedLines      *prometheus.CounterVec
+	deduplicatedBytes      *prometheus.CounterVec
+	sampledOutLines        *prometheus.CounterVec
+	sampledOutBytes        *prometheus.CounterVec
 
 	usageTracker push.UsageTracker
 }
@@ -202,6 +223,9 @@ func New(
 		validator:             validator,
 		pool:                  clientpool.NewPool("ingester", clientCfg.PoolConfig, ingestersRing, factory, logger, metricsNamespace),
 		labelCache:            labelCache,
+		ingestionPipelines:    ingestionpipeline.NewCache(),
+		deduplicator:          newDeduplicator(),
+		labelCardinality:      cardinality.NewTracker(),
 		shardTracker:          NewShardTracker(),
 		healthyInstancesCount: atomic.NewUint32(0),
 		rateLimitStrat:        rateLimitStrat,
@@ -227,6 +251,31 @@ func New(
 			Name:      "stream_sharding_count",
 			Help:      "Total number of times the distributor has sharded streams",
 		}),
+		redactedBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_redacted_bytes_total",
+			Help:      "The total number of bytes of sensitive values redacted from the pushed log lines, by detector.",
+		}, []string{"tenant", "detector"}),
+		deduplicatedLines: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_deduplicated_lines_total",
+			Help:      "The total number of lines dropped because they were already pushed to the same stream within the deduplication window.",
+		}, []string{"tenant"}),
+		deduplicatedBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_deduplicated_bytes_total",
+			Help:      "The total number of bytes of the lines dropped because they were already pushed to the same stream within the deduplication window.",
+		}, []string{"tenant"}),
+		sampledOutLines: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_sampled_out_lines_total",
+			Help:      "The total number of lines dropped by the sampling rules of the tenant.",
+		}, []string{"tenant"}),
+		sampledOutBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_sampled_out_bytes_total",
+			Help:      "The total number of bytes of the lines dropped by the sampling rules of the tenant.",
+		}, []string{"tenant"}),
 		writeFailuresManager: writefailures.NewManager(logger, registerer, cfg.WriteFailuresLogging, configs, "distributor"),
 	}
 
@@ -269,6 +318,26 @@ func New(
 	d.rateStore = rs
 
 	servs = append(servs, d.pool, rs)
+
+	if cfg.Syslog.Enabled() {
+		receiver, err := syslog.NewReceiver(cfg.Syslog, func(ctx context.Context, req *logproto.PushRequest) error {
+			_, err := d.Push(ctx, req)
+			return err
+		}, logger, registerer)
+		if err != nil {
+			return nil, errors.Wrap(err, "syslog receiver")
+		}
+		servs = append(servs, receiver)
+	}
+
+	if cfg.SpillQueue.Enabled {
+		d.spillQueue, err = newSpillQueue(cfg.SpillQueue, d.sendToIngesters, logger, registerer)
+		if err != nil {
+			return nil, errors.Wrap(err, "spill queue")
+		}
+		servs = append(servs, d.spillQueue)
+	}
+
 	d.subservices, err = services.NewManager(servs...)
 	if err != nil {
 		return nil, errors.Wrap(err, "services manager")
@@ -322,6 +391,14 @@ type pushTracker struct {
 // Push a set of streams.
 // The returned error is the last one seen.
 func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*logproto.PushResponse, error) {
+	return d.push(ctx, req, nil)
+}
+
+// rejectedFunc is called with the indexes of the stream and of the entry of
+// a push request rejected by the validation. entry is -1 when the whole stream is rejected.
+type rejectedFunc func(stream, entry int, err error)
+
+func (d *Distributor) push(ctx context.Context, req *logproto.PushRequest, rejected rejectedFunc) (resp *logproto.PushResponse, err error) {
 	tenantID, err := tenant.TenantID(ctx)
 	if err != nil {
 		return nil, err
@@ -341,6 +418,41 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 
 	var validationErrors util.GroupedErrors
 	validationContext := d.validator.getValidationContextForTime(time.Now(), tenantID)
+	pipeline, err := d.ingestionPipeline(tenantID)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusInternalServerError, "invalid ingestion pipeline: %s", err)
+	}
+	if pipeline != nil {
+		defer d.ingestionPipelines.Put(tenantID, pipeline)
+	}
+	redactor, err := d.redactor(tenantID)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusInternalServerError, "invalid redaction config: %s", err)
+	}
+	redacted := func(detector string, bytes int) {
+		d.redactedBytes.WithLabelValues(tenantID, detector).Add(float64(bytes))
+	}
+
+	cardinalityCfg := d.validator.Limits.LabelCardinality(tenantID)
+	sampler, err := d.sampler(tenantID)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusInternalServerError, "invalid sampling rules: %s", err)
+	}
+
+	var dedupCache *dedupCache
+	var dedupKeys []dedupKey
+	var pushedKeys map[dedupKey]struct{} // keys of the entries of this push, which are pending in the cache
+	if validationContext.deduplicationWindow > 0 {
+		dedupCache = d.deduplicator.cache(tenantID, validationContext.deduplicationWindow, validationContext.deduplicationMaxKeys, time.Now())
+		defer func() {
+			// The entries of failed pushes are forgotten, so that they are not dropped when retried.
+			if resp == nil && err != nil {
+				dedupCache.forget(dedupKeys)
+			} else {
+				dedupCache.commit(dedupKeys)
+			}
+		}()
+	}
 
 	func() {
 		sp := opentracing.SpanFromContext(ctx)
@@ -350,7 +462,7 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 				sp.LogKV("event", "finished to validate request")
 			}()
 		}
-		for _, stream := range req.Streams {
+		for i, stream := range req.Streams {
 			// Return early if stream does not contain any entries
 			if len(stream.Entries) == 0 {
 				continue
@@ -359,11 +471,19 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 			// Truncate first so subsequent steps have consistent line lengths
 			d.truncateLines(validationContext, &stream)
 
+			var entriesPipeline *ingestionpipeline.StreamPipeline
+			if pipeline != nil {
+				stream.Labels, entriesPipeline = applyIngestionPipeline(pipeline, stream.Labels)
+			}
+
 			var lbs labels.Labels
 			lbs, stream.Labels, stream.Hash, err = d.parseStreamLabels(validationContext, stream.Labels, stream)
 			if err != nil {
 				d.writeFailuresManager.Log(tenantID, err)
 				validationErrors.Add(err)
+				if rejected != nil {
+					rejected(i, -1, err)
+				}
 				validation.DiscardedSamples.WithLabelValues(validation.InvalidLabels, tenantID).Add(float64(len(stream.Entries)))
 				bytes := 0
 				for _, e := range stream.Entries {
@@ -373,19 +493,92 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 				continue
 			}
 
+			if redactor != nil {
+				var changed bool
+				if lbs, changed = redactor.RedactLabels(lbs, redacted); changed {
+					stream.Labels, stream.Hash = lbs.String(), lbs.Hash()
+				}
+			}
+
+			var demotedLabels []logproto.LabelAdapter
+			if cardinalityCfg.Enabled() {
+				lbs, demotedLabels, err = d.guardLabelCardinality(tenantID, cardinalityCfg, lbs)
+				if err != nil {
+					d.writeFailuresManager.Log(tenantID, err)
+					validationErrors.Add(err)
+					if rejected != nil {
+						rejected(i, -1, err)
+					}
+					validation.DiscardedSamples.WithLabelValues(validation.LabelCardinalityLimit, tenantID).Add(float64(len(stream.Entries)))
+					bytes := 0
+					for _, e := range stream.Entries {
+						bytes += len(e.Line)
+					}
+					validation.DiscardedBytes.WithLabelValues(validation.LabelCardinalityLimit, tenantID).Add(float64(bytes))
+					continue
+				}
+				if len(demotedLabels) > 0 {
+					stream.Labels, stream.Hash = lbs.String(), lbs.Hash()
+				}
+			}
+
+			var streamSampler *sampling.StreamSampler
+			if sampler != nil {
+				streamSampler = sampler.ForStream(lbs, stream.Hash)
+			}
+
 			n := 0
 			pushSize := 0
 			prevTs := stream.Entries[0].Timestamp
 
 			shouldDiscoverLevels := validationContext.allowStructuredMetadata && validationContext.discoverLogLevels
 			levelFromLabel, hasLevelLabel := hasAnyLevelLabels(lbs)
-			for _, entry := range stream.Entries {
+			droppedLines, droppedBytes := 0, 0
+			dedupedLines, dedupedBytes := 0, 0
+			sampledLines, sampledBytes := 0, 0
+			for j, entry := range stream.Entries {
+				if entriesPipeline != nil && !entriesPipeline.ProcessEntry(&entry) {
+					droppedLines++
+					droppedBytes += len(entry.Line)
+					continue
+				}
+				if redactor != nil {
+					redactor.RedactEntry(&entry, redacted)
+				}
+				if len(demotedLabels) > 0 {
+					entry.StructuredMetadata = append(entry.StructuredMetadata, demotedLabels...)
+				}
+
+				// the entries are sampled before their validation, so that the sampled rate is validated like any structured metadata.
+				if streamSampler != nil && !streamSampler.Sample(&entry) {
+					sampledLines++
+					sampledBytes += len(entry.Line)
+					continue
+				}
+
 				if err := d.validator.ValidateEntry(ctx, validationContext, lbs, entry); err != nil {
 					d.writeFailuresManager.Log(tenantID, err)
 					validationErrors.Add(err)
+					if rejected != nil {
+						rejected(i, j, err)
+					}
 					continue
 				}
 
+				if dedupCache != nil {
+					key := newDedupKey(stream.Hash, entry)
+					if _, ok := pushedKeys[key]; ok || !dedupCache.add(key) {
+						dedupedLines++
+						dedupedBytes += len(entry.Line)
+						continue
+					}
+					if pushedKeys == nil {
+						pushedKeys = map[dedupKey]struct{}{}
+					}
+					pushedKeys[key] = struct{}{}
+					dedupKeys = append(dedupKeys, key)
+				}
+
 				structuredMetadata := logproto.FromLabelAdaptersToLabels(entry.StructuredMetadata)
 				if shouldDiscoverLevels {
 					var logLevel string
@@ -426,6 +619,18 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 				pushSize += len(entry.Line)
 			}
 			stream.Entries = stream.Entries[:n]
+			if droppedLines > 0 {
+				validation.DiscardedSamples.WithLabelValues(validation.IngestionPipeline, tenantID).Add(float64(droppedLines))
+				validation.DiscardedBytes.WithLabelValues(validation.IngestionPipeline, tenantID).Add(float64(droppedBytes))
+			}
+			if dedupedLines > 0 {
+				d.deduplicatedLines.WithLabelValues(tenantID).Add(float64(dedupedLines))
+				d.deduplicatedBytes.WithLabelValues(tenantID).Add(float64(dedupedBytes))
+			}
+			if sampledLines > 0 {
+				d.sampledOutLines.WithLabelValues(tenantID).Add(float64(sampledLines))
+				d.sampledOutBytes.WithLabelValues(tenantID).Add(float64(sampledBytes))
+			}
 			if len(stream.Entries) == 0 {
 				// Empty stream after validating all the entries
 				continue
@@ -485,10 +690,60 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 		d.tee.Duplicate(tenantID, streams)
 	}
 
+	sent, err := d.pushToIngesters(ctx, tenantID, streams)
+	if err == nil {
+		return &logproto.PushResponse{}, validationErr
+	}
+	if d.spillQueue != nil && ctx.Err() == nil && spillable(err) {
+		// only the streams which were not written to a quorum of ingesters are spilled.
+		failed := streams
+		if sent != nil {
+			failed = sent.failed()
+		}
+		spillErr := d.spillQueue.spill(tenantID, failed)
+		if spillErr == nil {
+			level.Warn(d.logger).Log("msg", "failed to push to the ingesters, the push is spilled to the queue", "tenant", tenantID, "err", err)
+			return &logproto.PushResponse{}, validationErr
+		}
+		level.Error(d.logger).Log("msg", "failed to spill the push to the queue", "tenant", tenantID, "err", spillErr)
+	}
+	return nil, err
+}
+
+// sendToIngesters sends the streams to their ingesters and waits until they are written to a quorum of them.
+func (d *Distributor) sendToIngesters(ctx context.Context, tenantID string, streams []KeyedStream) error {
+	_, err := d.pushToIngesters(ctx, tenantID, streams)
+	return err
+}
+
+// sentStreams tracks the streams of a push to the ingesters.
+type sentStreams struct {
+	trackers []streamTracker
+	wg       sync.WaitGroup
+}
+
+// failed waits for the streams to be sent to all their ingesters,
+// and returns the streams which were not written to a quorum of them.
+func (s *sentStreams) failed() []KeyedStream {
+	s.wg.Wait()
+
+	var failed []KeyedStream
+	for i := range s.trackers {
+		if s.trackers[i].succeeded.Load() < int32(s.trackers[i].minSuccess) {
+			failed = append(failed, s.trackers[i].KeyedStream)
+		}
+	}
+	return failed
+}
+
+// pushToIngesters is sendToIngesters also returning the tracked streams,
+// or nil if the streams could not be sent to any ingester.
+func (d *Distributor) pushToIngesters(ctx context.Context, tenantID string, streams []KeyedStream) (*sentStreams, error) {
 	const maxExpectedReplicationSet = 5 // typical replication factor 3 plus one for inactive plus one for luck
 	var descs [maxExpectedReplicationSet]ring.InstanceDesc
 
-	streamTrackers := make([]streamTracker, len(streams))
+	sent := &sentStreams{trackers: make([]streamTracker, len(streams))}
+	streamTrackers := sent.trackers
 	streamsByIngester := map[string][]*streamTracker{}
 	ingesterDescs := map[string]ring.InstanceDesc{}
 
@@ -527,8 +782,10 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 		err:  make(chan error, 1),
 	}
 	tracker.streamsPending.Store(int32(len(streams)))
+	sent.wg.Add(len(streamsByIngester))
 	for ingester, streams := range streamsByIngester {
 		go func(ingester ring.InstanceDesc, samples []*streamTracker) {
+			defer sent.wg.Done()
 			// Use a background context to make sure all ingesters get samples even if we return early
 			localCtx, cancel := context.WithTimeout(context.Background(), d.clientCfg.RemoteTimeout)
 			defer cancel()
@@ -541,11 +798,11 @@ func (d *Distributor) Push(ctx context.Context, req *logproto.PushRequest) (*log
 	}
 	select {
 	case err := <-tracker.err:
-		return nil, err
+		return sent, err
 	case <-tracker.done:
-		return &logproto.PushResponse{}, validationErr
+		return sent, nil
 	case <-ctx.Done():
-		return nil, ctx.Err()
+		return sent, ctx.Err()
 	}
 }
 
@@ -790,6 +1047,66 @@ type labelData struct {
 	hash uint64
 }
 
+// ingestionPipeline returns the ingestion pipeline of the tenant, or nil if it has none.
+// The pipeline must only be used by a single push request, and be released to the cache afterwards.
+func (d *Distributor) ingestionPipeline(tenantID string) (*ingestionpipeline.Pipeline, error) {
+	stages := d.validator.Limits.IngestionPipeline(tenantID)
+	if len(stages) == 0 {
+		return nil, nil
+	}
+	return d.ingestionPipelines.Get(tenantID, stages)
+}
+
+// guardLabelCardinality records the label values of a stream and enforces the label cardinality limit of the tenant.
+// In demote mode, the labels exceeding the limit are removed from the returned labels and returned as structured metadata.
+func (d *Distributor) guardLabelCardinality(tenantID string, cfg cardinality.Config, lbs labels.Labels) (labels.Labels, []logproto.LabelAdapter, error) {
+	exceeded := d.labelCardinality.Observe(tenantID, cfg, lbs, time.Now())
+	if len(exceeded) == 0 {
+		return lbs, nil, nil
+	}
+	if cfg.Mode == cardinality.ModeDemote && len(exceeded) < lbs.Len() {
+		demoted := make([]logproto.LabelAdapter, 0, len(exceeded))
+		for _, name := range exceeded {
+			demoted = append(demoted, logproto.LabelAdapter{Name: name, Value: lbs.Get(name)})
+		}
+		return labels.NewBuilder(lbs).Del(exceeded...).Labels(), demoted, nil
+	}
+	// the streams whose labels would all be demoted are rejected, as they need at least one label.
+	return lbs, nil, fmt.Errorf(validation.LabelCardinalityLimitErrorMsg, lbs.String(), cfg.MaxValuesPerLabel, strings.Join(exceeded, ", "))
+}
+
+// redactor returns the redactor of the sensitive values of the tenant, or nil if redaction is disabled.
+func (d *Distributor) redactor(tenantID string) (*redaction.Redactor, error) {
+	cfg := d.validator.Limits.Redaction(tenantID)
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	return redaction.New(cfg)
+}
+
+func (d *Distributor) sampler(tenantID string) (*sampling.Sampler, error) {
+	rules := d.validator.Limits.SamplingRules(tenantID)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return sampling.New(rules)
+}
+
+// applyIngestionPipeline applies the label stages of the pipeline to the labels of a stream,
+// and returns the new labels with the pipeline of its entries. Labels that can't be parsed
+// are returned unchanged, to be rejected when the stream is validated.
+func applyIngestionPipeline(pipeline *ingestionpipeline.Pipeline, key string) (string, *ingestionpipeline.StreamPipeline) {
+	ls, err := syntax.ParseLabels(key)
+	if err != nil {
+		return key, nil
+	}
+	ls, entriesPipeline := pipeline.ForStream(ls)
+	if entriesPipeline.Empty() {
+		entriesPipeline = nil
+	}
+	return ls.String(), entriesPipeline
+}
+
 func (d *Distributor) parseStreamLabels(vContext validationContext, key string, stream logproto.Stream) (labels.Labels, string, uint64, error) {
 	if val, ok := d.labelCache.Get(key); ok {
 		labelVal := val.(labelData)
@@ -22,6 +22,7 @@ import (
 	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/user"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/assert"
@@ -32,6 +33,10 @@ import (
 
 	"github.com/grafana/loki/pkg/push"
 
+	"github.com/grafana/loki/v3/pkg/distributor/cardinality"
+	"github.com/grafana/loki/v3/pkg/distributor/ingestionpipeline"
+	"github.com/grafana/loki/v3/pkg/distributor/redaction"
+	"github.com/grafana/loki/v3/pkg/distributor/sampling"
 	"github.com/grafana/loki/v3/pkg/ingester"
 	"github.com/grafana/loki/v3/pkg/ingester/client"
 	loghttp_push "github.com/grafana/loki/v3/pkg/loghttp/push"
@@ -562,6 +567,399 @@ func Test_DiscardEmptyStreamsAfterValidation(t *testing.T) {
 	})
 }
 
+func Test_IngestionPipeline(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AllowStructuredMetadata = true
+	limits.IngestionPipeline = []ingestionpipeline.StageConfig{
+		{Action: ingestionpipeline.DropLabels, Labels: []string{"pod"}},
+		{Action: ingestionpipeline.Drop, Expression: `|= "healthz"`},
+		{Action: ingestionpipeline.StructuredMetadata, Expression: `| logfmt`, Labels: []string{"trace_id"}},
+		{Action: ingestionpipeline.Mask, Regex: `token=\S+`, Replacement: "token=***"},
+	}
+	require.NoError(t, limits.Validate())
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	now := time.Now()
+	before := testutil.ToFloat64(validation.DiscardedSamples.WithLabelValues(validation.IngestionPipeline, "test"))
+	_, err := distributors[0].Push(ctx, &logproto.PushRequest{
+		Streams: []logproto.Stream{{
+			Labels: `{app="api", pod="api-1"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: now, Line: "GET /healthz"},
+				{Timestamp: now.Add(time.Millisecond), Line: "msg=login trace_id=abc token=secret"},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(validation.DiscardedSamples.WithLabelValues(validation.IngestionPipeline, "test"))-before)
+
+	pushed := ingester.Peek()
+	require.Len(t, pushed.Streams, 1)
+	require.Equal(t, `{app="api"}`, pushed.Streams[0].Labels)
+	require.Equal(t, []logproto.Entry{{
+		Timestamp:          now.Add(time.Millisecond),
+		Line:               "msg=login trace_id=abc token=***",
+		StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "abc"}},
+	}}, pushed.Streams[0].Entries)
+}
+
+func Test_IngestionPipeline_Invalid(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	// the stages are not validated, like overrides which fail to compile at runtime.
+	limits.IngestionPipeline = []ingestionpipeline.StageConfig{
+		{Action: ingestionpipeline.Mask, Regex: `token=(`},
+	}
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	// the streams are rejected rather than ingested without the pipeline.
+	_, err := distributors[0].Push(ctx, makeWriteRequest(1, 10))
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusInternalServerError), resp.Code)
+	require.Contains(t, string(resp.Body), "invalid ingestion pipeline")
+	require.Empty(t, ingester.pushed)
+}
+
+func Test_Redaction(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AllowStructuredMetadata = true
+	limits.Redaction = redaction.Config{
+		Detectors:       []string{redaction.Email, redaction.CreditCard},
+		CustomDetectors: []redaction.CustomDetector{{Name: "api_key", Regex: `api_key=(\w+)`}},
+	}
+	require.NoError(t, limits.Validate())
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	now := time.Now()
+	_, err := distributors[0].Push(ctx, &logproto.PushRequest{
+		Streams: []logproto.Stream{{
+			Labels: `{app="shop", owner="baz@example.com"}`,
+			Entries: []logproto.Entry{{
+				Timestamp:          now,
+				Line:               "order of foo@example.com paid with 4111 1111 1111 1111 api_key=s3cr3t",
+				StructuredMetadata: push.LabelsAdapter{{Name: "user", Value: "bar@example.com"}},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+
+	pushed := ingester.Peek()
+	require.Equal(t, `{app="shop", owner="[REDACTED]"}`, pushed.Streams[0].Labels)
+	lbs, err := syntax.ParseLabels(pushed.Streams[0].Labels)
+	require.NoError(t, err)
+	require.Equal(t, lbs.Hash(), pushed.Streams[0].Hash)
+	require.Equal(t, "order of [REDACTED] paid with [REDACTED] api_key=[REDACTED]", pushed.Streams[0].Entries[0].Line)
+	require.Equal(t, push.LabelsAdapter{{Name: "user", Value: "[REDACTED]"}}, pushed.Streams[0].Entries[0].StructuredMetadata)
+
+	redactedBytes := distributors[0].redactedBytes
+	require.Equal(t, 45.0, testutil.ToFloat64(redactedBytes.WithLabelValues("test", redaction.Email)))
+	require.Equal(t, 19.0, testutil.ToFloat64(redactedBytes.WithLabelValues("test", redaction.CreditCard)))
+	require.Equal(t, 6.0, testutil.ToFloat64(redactedBytes.WithLabelValues("test", "api_key")))
+}
+
+func Test_Deduplication(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.DeduplicationWindow = model.Duration(time.Minute)
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	now := time.Now()
+	lastPushedEntries := func() []logproto.Entry {
+		ingester.mu.Lock()
+		defer ingester.mu.Unlock()
+		return ingester.pushed[len(ingester.pushed)-1].Streams[0].Entries
+	}
+
+	_, err := distributors[0].Push(ctx, &logproto.PushRequest{
+		Streams: []logproto.Stream{{
+			Labels: `{app="shop"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: now, Line: "a"},
+				{Timestamp: now, Line: "a"},
+				{Timestamp: now, Line: "b"},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []logproto.Entry{{Timestamp: now, Line: "a"}, {Timestamp: now, Line: "b"}}, lastPushedEntries())
+
+	// the same entries sent by another agent, along with a new one.
+	_, err = distributors[0].Push(ctx, &logproto.PushRequest{
+		Streams: []logproto.Stream{{
+			Labels: `{app="shop"}`,
+			Entries: []logproto.Entry{
+				{Timestamp: now, Line: "a"},
+				{Timestamp: now, Line: "b"},
+				{Timestamp: now.Add(time.Millisecond), Line: "a"},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []logproto.Entry{{Timestamp: now.Add(time.Millisecond), Line: "a"}}, lastPushedEntries())
+
+	// the entries of other streams are not duplicates.
+	_, err = distributors[0].Push(ctx, &logproto.PushRequest{
+		Streams: []logproto.Stream{{
+			Labels:  `{app="cart"}`,
+			Entries: []logproto.Entry{{Timestamp: now, Line: "a"}},
+		}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []logproto.Entry{{Timestamp: now, Line: "a"}}, lastPushedEntries())
+
+	require.Equal(t, 3.0, testutil.ToFloat64(distributors[0].deduplicatedLines.WithLabelValues("test")))
+	require.Equal(t, 3.0, testutil.ToFloat64(distributors[0].deduplicatedBytes.WithLabelValues("test")))
+}
+
+func Test_Deduplication_FailedPush(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.DeduplicationWindow = model.Duration(time.Minute)
+
+	ingester := &mockIngester{failAfter: time.Millisecond}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	_, err := distributors[0].Push(ctx, makeWriteRequest(10, 10))
+	require.Error(t, err)
+
+	// the entries of the failed push are forgotten, so that they are accepted when retried.
+	cache := distributors[0].deduplicator.tenants["test"]
+	require.Empty(t, cache.current)
+	require.Empty(t, cache.previous)
+}
+
+func Test_SpillQueue(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.IngestionRateMB = 1
+	limits.IngestionBurstSizeMB = 1
+
+	ingester := &mockIngester{failAfter: time.Millisecond}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+	d := distributors[0]
+	d.spillQueue = newTestSpillQueue(t, SpillQueueConfig{}, d.sendToIngesters)
+
+	// the push failing because of the ingesters is spilled and acknowledged.
+	resp, err := d.Push(ctx, makeWriteRequest(10, 10))
+	require.NoError(t, err)
+	require.Equal(t, success, resp)
+	require.Equal(t, 1.0, testutil.ToFloat64(d.spillQueue.spilledRequests))
+
+	// the push failing because of the client is not spilled.
+	_, err = d.Push(ctx, makeWriteRequest(1, 2*1024*1024))
+	require.Error(t, err)
+	require.Equal(t, 1.0, testutil.ToFloat64(d.spillQueue.spilledRequests))
+
+	// the spilled push is kept until it can be replayed.
+	require.Error(t, d.spillQueue.replay(context.Background()))
+	require.Equal(t, 0.0, testutil.ToFloat64(d.spillQueue.replayedRequests))
+}
+
+func Test_SpillQueue_FailedStreams(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+
+	// the streams whose replication set has two of these ingesters fail.
+	failing := &mockIngester{failAfter: time.Millisecond}
+	succeeding := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(addr string) (ring_client.PoolClient, error) {
+		if addr == "ingester-0" || addr == "ingester-1" {
+			return failing, nil
+		}
+		return succeeding, nil
+	})
+	d := distributors[0]
+
+	var replayed []KeyedStream
+	d.spillQueue = newTestSpillQueue(t, SpillQueueConfig{}, func(_ context.Context, _ string, streams []KeyedStream) error {
+		replayed = append(replayed, streams...)
+		return nil
+	})
+
+	lbls := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		lbls = append(lbls, fmt.Sprintf(`{app="app-%d"}`, i))
+	}
+	resp, err := d.Push(ctx, makeWriteRequestWithLabels(1, 10, lbls))
+	require.NoError(t, err)
+	require.Equal(t, success, resp)
+	require.Equal(t, 1.0, testutil.ToFloat64(d.spillQueue.spilledRequests))
+
+	require.NoError(t, d.spillQueue.replay(context.Background()))
+	require.NotEmpty(t, replayed)
+	require.Less(t, len(replayed), len(lbls), "the streams written to a quorum of ingesters are not spilled")
+
+	replayedLabels := map[string]struct{}{}
+	for _, stream := range replayed {
+		replayedLabels[stream.Stream.Labels] = struct{}{}
+	}
+	succeeding.mu.Lock()
+	defer succeeding.mu.Unlock()
+	writes := map[string]int{}
+	for _, req := range succeeding.pushed {
+		for _, stream := range req.Streams {
+			writes[stream.Labels]++
+		}
+	}
+	for _, l := range lbls {
+		_, spilled := replayedLabels[l]
+		// the streams are written to the succeeding ingesters of their replication set.
+		require.Equal(t, writes[l] < 2, spilled, l)
+	}
+}
+
+func Test_LabelCardinality(t *testing.T) {
+	for _, mode := range []cardinality.Mode{cardinality.ModeReject, cardinality.ModeDemote} {
+		t.Run(string(mode), func(t *testing.T) {
+			limits := &validation.Limits{}
+			flagext.DefaultValues(limits)
+			limits.AllowStructuredMetadata = true
+			limits.LabelCardinality = cardinality.Config{Mode: mode, MaxValuesPerLabel: 2}
+			require.NoError(t, limits.Validate())
+
+			ingester := &mockIngester{}
+			distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+			now := time.Now()
+			req := &logproto.PushRequest{}
+			for i := 0; i < 3; i++ {
+				req.Streams = append(req.Streams, logproto.Stream{
+					Labels:  fmt.Sprintf(`{app="shop", request_id="%d"}`, i),
+					Entries: []logproto.Entry{{Timestamp: now, Line: "GET /"}},
+				})
+			}
+			_, err := distributors[0].Push(ctx, req)
+
+			streams := map[string][]logproto.Entry{}
+			ingester.mu.Lock()
+			for _, pushed := range ingester.pushed {
+				for _, s := range pushed.Streams {
+					streams[s.Labels] = s.Entries
+				}
+			}
+			ingester.mu.Unlock()
+
+			if mode == cardinality.ModeReject {
+				require.EqualError(t, err, fmt.Sprintf("rpc error: code = Code(400) desc = "+validation.LabelCardinalityLimitErrorMsg, `{app="shop", request_id="2"}`, 2, "request_id"))
+				require.Len(t, streams, 2)
+				require.NotContains(t, streams, `{app="shop", request_id="2"}`)
+			} else {
+				require.NoError(t, err)
+				require.Len(t, streams, 3)
+				require.Equal(t, []logproto.Entry{{
+					Timestamp:          now,
+					Line:               "GET /",
+					StructuredMetadata: push.LabelsAdapter{{Name: "request_id", Value: "2"}},
+				}}, streams[`{app="shop"}`])
+			}
+
+			require.Equal(t, []cardinality.LabelCardinality{
+				{Name: "request_id", Values: 3, Exceeded: true},
+				{Name: "app", Values: 1},
+			}, distributors[0].labelCardinality.TopLabels("test", 10))
+		})
+	}
+}
+
+func Test_Sampling(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AllowStructuredMetadata = true
+	limits.DiscoverLogLevels = false
+	limits.SamplingRules = []sampling.Rule{{Selector: `{level="debug"}`, Rate: 0.1}}
+	require.NoError(t, limits.Validate())
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	now := time.Now()
+	req := &logproto.PushRequest{Streams: []logproto.Stream{
+		{Labels: `{app="shop", level="debug"}`},
+		{Labels: `{app="shop", level="info"}`},
+	}}
+	for i := 0; i < 1000; i++ {
+		for j := range req.Streams {
+			req.Streams[j].Entries = append(req.Streams[j].Entries, logproto.Entry{Timestamp: now.Add(time.Duration(i)), Line: fmt.Sprintf("line %d", i)})
+		}
+	}
+	_, err := distributors[0].Push(ctx, req)
+	require.NoError(t, err)
+
+	streams := map[string][]logproto.Entry{}
+	ingester.mu.Lock()
+	for _, pushed := range ingester.pushed {
+		for _, s := range pushed.Streams {
+			streams[s.Labels] = s.Entries
+		}
+	}
+	ingester.mu.Unlock()
+
+	require.Len(t, streams[`{app="shop", level="info"}`], 1000)
+	for _, entry := range streams[`{app="shop", level="info"}`] {
+		require.Empty(t, entry.StructuredMetadata)
+	}
+	sampled := streams[`{app="shop", level="debug"}`]
+	require.InDelta(t, 100, len(sampled), 30)
+	for _, entry := range sampled {
+		require.Equal(t, push.LabelsAdapter{{Name: sampling.SampledRateLabel, Value: "0.1"}}, entry.StructuredMetadata)
+	}
+	require.Equal(t, float64(1000-len(sampled)), testutil.ToFloat64(distributors[0].sampledOutLines.WithLabelValues("test")))
+}
+
+func Test_Sampling_Validation(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.AllowStructuredMetadata = true
+	limits.DiscoverLogLevels = false
+	limits.MaxStructuredMetadataEntriesCount = 1
+	limits.StructuredMetadataSchema = validation.StructuredMetadataSchema{Keys: []validation.StructuredMetadataKey{{Name: "user"}}}
+	limits.SamplingRules = []sampling.Rule{
+		{Selector: `{app="shop"}`, LineFilter: `|= "user"`, Rate: 0.5},
+		{Selector: `{app="shop"}`, Rate: 0.5},
+	}
+	require.NoError(t, limits.Validate())
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	now := time.Now()
+	req := &logproto.PushRequest{Streams: []logproto.Stream{{Labels: `{app="shop"}`}}}
+	for i := 0; i < 100; i++ {
+		req.Streams[0].Entries = append(req.Streams[0].Entries,
+			logproto.Entry{Timestamp: now.Add(time.Duration(i)), Line: fmt.Sprintf("line %d", i)},
+			logproto.Entry{Timestamp: now.Add(time.Duration(i)), Line: fmt.Sprintf("user line %d", i), StructuredMetadata: push.LabelsAdapter{{Name: "user", Value: "foo"}}},
+		)
+	}
+	_, err := distributors[0].Push(ctx, req)
+	require.Error(t, err, "the sampled lines with too many structured metadata are rejected")
+	require.Contains(t, err.Error(), "structured metadata")
+
+	ingester.mu.Lock()
+	defer ingester.mu.Unlock()
+	var entries []logproto.Entry
+	for _, pushed := range ingester.pushed {
+		for _, s := range pushed.Streams {
+			entries = append(entries, s.Entries...)
+		}
+	}
+	// only the lines without structured metadata are kept, the sampled rate is accepted by the schema.
+	require.NotEmpty(t, entries)
+	for _, entry := range entries {
+		require.Equal(t, push.LabelsAdapter{{Name: sampling.SampledRateLabel, Value: "0.5"}}, entry.StructuredMetadata)
+	}
+}
+
 func TestStreamShard(t *testing.T) {
 	// setup base stream.
 	baseStream := logproto.Stream{}
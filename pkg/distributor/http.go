@@ -1,9 +1,13 @@
 package distributor
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/httpgrpc"
@@ -12,11 +16,18 @@ import (
 
 	"github.com/grafana/dskit/tenant"
 
+	"github.com/grafana/loki/v3/pkg/distributor/cardinality"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
+	"github.com/grafana/loki/v3/pkg/logproto"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 	"github.com/grafana/loki/v3/pkg/validation"
 )
 
+const (
+	defaultLabelCardinalityReportLimit = 10
+	labelCardinalityScope              = "distributor"
+)
+
 // PushHandler reads a snappy-compressed proto from the HTTP body.
 func (d *Distributor) PushHandler(w http.ResponseWriter, r *http.Request) {
 	d.pushHandler(w, r, push.ParseLokiRequest)
@@ -27,6 +38,111 @@ func (d *Distributor) OTLPPushHandler(w http.ResponseWriter, r *http.Request) {
 	d.pushHandler(interceptor, r, push.ParseOTLPRequest)
 }
 
+// OTLPTracesPushHandler pushes the events of the spans of OTLP traces as log entries.
+func (d *Distributor) OTLPTracesPushHandler(w http.ResponseWriter, r *http.Request) {
+	interceptor := newOtelErrorHeaderInterceptor(w)
+	d.pushHandler(interceptor, r, push.ParseOTLPTracesRequest)
+}
+
+// OTLPMetricsPushHandler pushes the exemplars of OTLP metrics as log entries.
+func (d *Distributor) OTLPMetricsPushHandler(w http.ResponseWriter, r *http.Request) {
+	interceptor := newOtelErrorHeaderInterceptor(w)
+	d.pushHandler(interceptor, r, push.ParseOTLPMetricsRequest)
+}
+
+// ElasticsearchBulkHandler implements the bulk API of Elasticsearch, so that its clients can push logs.
+// The validation failures of the entries are reported on their bulk actions.
+func (d *Distributor) ElasticsearchBulkHandler(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	logger := util_log.WithContext(r.Context(), util_log.Logger)
+	tenantID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", "error getting tenant id", "err", err)
+		writeElasticsearchError(w, http.StatusBadRequest, "security_exception", err)
+		return
+	}
+
+	var bulk *push.ElasticsearchBulkRequest
+	var pushRequestParser push.RequestParser = func(userID string, r *http.Request, tenantsRetention push.TenantsRetention, limits push.Limits, tracker push.UsageTracker) (*logproto.PushRequest, *push.Stats, error) {
+		var (
+			stats *push.Stats
+			err   error
+		)
+		bulk, stats, err = push.ParseElasticsearchBulk(userID, r, tenantsRetention, limits, tracker)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bulk.Request, stats, nil
+	}
+	if d.RequestParserWrapper != nil {
+		pushRequestParser = d.RequestParserWrapper(pushRequestParser)
+	}
+
+	req, err := push.ParseRequest(logger, tenantID, r, d.tenantsRetention, d.validator.Limits, pushRequestParser, d.usageTracker)
+	if err != nil {
+		if d.tenantConfigs.LogPushRequest(tenantID) {
+			level.Debug(logger).Log(
+				"msg", "push request failed",
+				"code", http.StatusBadRequest,
+				"err", err,
+			)
+		}
+		d.writeFailuresManager.Log(tenantID, fmt.Errorf("couldn't parse push request: %w", err))
+
+		writeElasticsearchError(w, http.StatusBadRequest, "parse_exception", err)
+		return
+	}
+
+	_, err = d.push(r.Context(), req, func(stream, entry int, err error) {
+		bulk.Reject(stream, entry, http.StatusBadRequest, err)
+	})
+	if err != nil {
+		code, body := http.StatusInternalServerError, err.Error()
+		if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+			code, body = int(resp.Code), string(resp.Body)
+		}
+		if d.tenantConfigs.LogPushRequest(tenantID) {
+			level.Debug(logger).Log(
+				"msg", "push request failed",
+				"code", code,
+				"err", body,
+			)
+		}
+		// validation failures were already reported on the actions of their entries.
+		if code != http.StatusBadRequest {
+			bulk.RejectAll(code, errors.New(body))
+		}
+	} else if d.tenantConfigs.LogPushRequest(tenantID) {
+		level.Debug(logger).Log(
+			"msg", "push request successful",
+		)
+	}
+
+	// Like Elasticsearch, the request succeeds even if some of its actions failed.
+	writeElasticsearchResponse(w, http.StatusOK, bulk.Response(time.Since(start)))
+}
+
+// ElasticsearchInfoHandler responds to the root endpoint of the Elasticsearch API,
+// which the Elasticsearch clients check before sending bulk requests.
+func (d *Distributor) ElasticsearchInfoHandler(w http.ResponseWriter, _ *http.Request) {
+	writeElasticsearchResponse(w, http.StatusOK, push.NewElasticsearchInfoResponse())
+}
+
+func writeElasticsearchError(w http.ResponseWriter, code int, errType string, err error) {
+	writeElasticsearchResponse(w, code, push.ElasticsearchErrorResponse{
+		Error:  push.ElasticsearchError{Type: errType, Reason: err.Error()},
+		Status: code,
+	})
+}
+
+func writeElasticsearchResponse(w http.ResponseWriter, code int, resp any) {
+	w.Header().Set("Content-Type", "application/json")
+	// recent Elasticsearch clients refuse the responses without this header.
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
 // otelErrorHeaderInterceptor maps 500 errors to 503.
 // According to the OTLP specification, 500 errors are never retried on the client side, but 503 are.
 type otelErrorHeaderInterceptor struct {
@@ -142,3 +258,49 @@ func (d *Distributor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			</html>`
 	util.WriteHTMLResponse(w, noRingPage)
 }
+
+// TenantLabelCardinality lists the labels of a tenant with the most distinct values.
+type TenantLabelCardinality struct {
+	Tenant string                         `json:"tenant"`
+	Labels []cardinality.LabelCardinality `json:"labels"`
+}
+
+// LabelCardinalityReport is the label cardinality estimated by a single distributor from the streams it received.
+// The estimates aren't aggregated across the distributors ring.
+type LabelCardinalityReport struct {
+	// Scope is always "distributor", since the estimates only cover the streams received by this distributor.
+	Scope       string                   `json:"scope"`
+	Distributor string                   `json:"distributor"`
+	Tenants     []TenantLabelCardinality `json:"tenants"`
+}
+
+// LabelCardinalityHandler reports the labels with the most distinct values of the tenants whose label cardinality is tracked
+// by this distributor only. The tenant query parameter restricts the report to a tenant, and limit sets the number of labels per tenant.
+func (d *Distributor) LabelCardinalityHandler(w http.ResponseWriter, r *http.Request) {
+	limit := defaultLabelCardinalityReportLimit
+	if v := r.FormValue("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q, it must be a positive integer", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenants := d.labelCardinality.Tenants()
+	if tenantID := r.FormValue("tenant"); tenantID != "" {
+		tenants = []string{tenantID}
+	}
+
+	report := LabelCardinalityReport{
+		Scope:       labelCardinalityScope,
+		Distributor: d.cfg.DistributorRing.InstanceID,
+		Tenants:     make([]TenantLabelCardinality, 0, len(tenants)),
+	}
+	for _, tenantID := range tenants {
+		report.Tenants = append(report.Tenants, TenantLabelCardinality{
+			Tenant: tenantID,
+			Labels: d.labelCardinality.TopLabels(tenantID, limit),
+		})
+	}
+	util.WriteJSONResponse(w, report)
+}
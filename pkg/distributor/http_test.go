@@ -2,17 +2,24 @@ package distributor
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/grafana/dskit/user"
 
+	"github.com/grafana/loki/v3/pkg/distributor/cardinality"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
 	"github.com/grafana/loki/v3/pkg/logproto"
 
 	"github.com/grafana/dskit/flagext"
+	ring_client "github.com/grafana/dskit/ring/client"
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/loki/v3/pkg/validation"
@@ -117,3 +124,175 @@ func Test_OtelErrorHeaderInterceptor(t *testing.T) {
 func stubParser(_ string, _ *http.Request, _ push.TenantsRetention, _ push.Limits, _ push.UsageTracker) (*logproto.PushRequest, *push.Stats, error) {
 	return &logproto.PushRequest{}, &push.Stats{}, nil
 }
+
+func Test_ElasticsearchBulkHandler(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.ElasticsearchConfig = push.ElasticsearchConfig{MessageField: "message"}
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	now := time.Now().UTC()
+	body := strings.Join([]string{
+		`{"index":{"_index":"app-logs","_id":"1"}}`,
+		fmt.Sprintf(`{"@timestamp":%q,"message":"GET /"}`, now.Format(time.RFC3339Nano)),
+		`{"index":{"_index":"app-logs","_id":"2"}}`,
+		`{"@timestamp":"2000-01-01T00:00:00Z","message":"too old"}`,
+		`{"index":{"_id":"3"}}`,
+		`{"message":"no labels"}`,
+	}, "\n")
+
+	req, err := http.NewRequestWithContext(user.InjectOrgID(context.Background(), "test"), http.MethodPost, "/loki/api/v1/es/_bulk", strings.NewReader(body))
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	distributors[0].ElasticsearchBulkHandler(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "Elasticsearch", rec.Header().Get("X-Elastic-Product"))
+
+	var resp push.ElasticsearchBulkResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.True(t, resp.Errors)
+	require.Len(t, resp.Items, 3)
+	require.Equal(t, http.StatusCreated, resp.Items[0]["index"].Status)
+	require.Equal(t, http.StatusBadRequest, resp.Items[1]["index"].Status)
+	require.Contains(t, resp.Items[1]["index"].Error.Reason, "has timestamp too old")
+	require.Equal(t, http.StatusBadRequest, resp.Items[2]["index"].Status)
+	require.Equal(t, "3", resp.Items[2]["index"].ID)
+
+	pushed := ingester.Peek()
+	require.Len(t, pushed.Streams, 1)
+	require.Equal(t, `{index="app-logs"}`, pushed.Streams[0].Labels)
+	require.Equal(t, "GET /", pushed.Streams[0].Entries[0].Line)
+}
+
+func Test_ElasticsearchBulkHandler_PathIndex(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.ElasticsearchConfig = push.ElasticsearchConfig{MessageField: "message"}
+
+	ingester := &mockIngester{}
+	distributors, _ := prepare(t, 1, 5, limits, func(_ string) (ring_client.PoolClient, error) { return ingester, nil })
+
+	router := mux.NewRouter()
+	router.Path("/loki/api/v1/es/{index}/_bulk").Methods("POST", "PUT").HandlerFunc(distributors[0].ElasticsearchBulkHandler)
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	body := strings.Join([]string{
+		`{"index":{"_id":"1"}}`,
+		fmt.Sprintf(`{"@timestamp":%q,"message":"GET /"}`, now),
+		`{"create":{"_index":"other-logs","_id":"2"}}`,
+		fmt.Sprintf(`{"@timestamp":%q,"message":"GET /other"}`, now),
+	}, "\n")
+
+	req, err := http.NewRequestWithContext(user.InjectOrgID(context.Background(), "test"), http.MethodPost, "/loki/api/v1/es/app-logs/_bulk", strings.NewReader(body))
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp push.ElasticsearchBulkResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.False(t, resp.Errors)
+	require.Len(t, resp.Items, 2)
+	// the actions without _index belong to the index of the path.
+	require.Equal(t, "app-logs", resp.Items[0]["index"].Index)
+	require.Equal(t, "other-logs", resp.Items[1]["create"].Index)
+
+	ingester.mu.Lock()
+	defer ingester.mu.Unlock()
+	labels := map[string]struct{}{}
+	for _, req := range ingester.pushed {
+		for _, stream := range req.Streams {
+			labels[stream.Labels] = struct{}{}
+		}
+	}
+	require.Equal(t, map[string]struct{}{`{index="app-logs"}`: {}, `{index="other-logs"}`: {}}, labels)
+}
+
+func Test_ElasticsearchInfoHandler(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	distributors, _ := prepare(t, 1, 3, limits, nil)
+
+	req, err := http.NewRequestWithContext(user.InjectOrgID(context.Background(), "test"), http.MethodGet, "/loki/api/v1/es/", nil)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	distributors[0].ElasticsearchInfoHandler(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	// the clients refuse the servers which aren't Elasticsearch.
+	require.Equal(t, "Elasticsearch", rec.Header().Get("X-Elastic-Product"))
+	var resp push.ElasticsearchInfoResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "8.11.0", resp.Version.Number)
+	require.Equal(t, "default", resp.Version.BuildFlavor)
+	require.Equal(t, "You Know, for Search", resp.Tagline)
+}
+
+func Test_ElasticsearchBulkHandler_MalformedRequest(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	distributors, _ := prepare(t, 1, 3, limits, nil)
+
+	req, err := http.NewRequestWithContext(user.InjectOrgID(context.Background(), "test"), http.MethodPost, "/loki/api/v1/es/_bulk", strings.NewReader(`{"index":`))
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	distributors[0].ElasticsearchBulkHandler(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.JSONEq(t, `{"error":{"type":"parse_exception","reason":"malformed action [1], expected a single action like {\"index\":{}}"},"status":400}`, rec.Body.String())
+}
+
+func Test_LabelCardinalityHandler(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.LabelCardinality = cardinality.Config{Mode: cardinality.ModeMonitor, MaxValuesPerLabel: 1}
+	distributors, _ := prepare(t, 1, 3, limits, nil)
+
+	now := time.Now()
+	for _, tenantID := range []string{"team-a", "team-b"} {
+		_, err := distributors[0].Push(user.InjectOrgID(context.Background(), tenantID), &logproto.PushRequest{
+			Streams: []logproto.Stream{
+				{Labels: `{app="shop", pod="a"}`, Entries: []logproto.Entry{{Timestamp: now, Line: "a"}}},
+				{Labels: `{app="shop", pod="b"}`, Entries: []logproto.Entry{{Timestamp: now, Line: "b"}}},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	for _, tc := range []struct {
+		query    string
+		status   int
+		expected string
+	}{
+		{
+			query:    "",
+			status:   http.StatusOK,
+			expected: `[{"tenant":"team-a","labels":[{"name":"pod","values":2,"exceeded":true},{"name":"app","values":1,"exceeded":false}]},{"tenant":"team-b","labels":[{"name":"pod","values":2,"exceeded":true},{"name":"app","values":1,"exceeded":false}]}]`,
+		},
+		{
+			query:    "?tenant=team-b&limit=1",
+			status:   http.StatusOK,
+			expected: `[{"tenant":"team-b","labels":[{"name":"pod","values":2,"exceeded":true}]}]`,
+		},
+		{
+			query:    "?limit=0",
+			status:   http.StatusBadRequest,
+			expected: "invalid limit \"0\", it must be a positive integer\n",
+		},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			distributors[0].LabelCardinalityHandler(rec, httptest.NewRequest(http.MethodGet, "/distributor/label_cardinality"+tc.query, nil))
+			require.Equal(t, tc.status, rec.Code)
+			if tc.status == http.StatusOK {
+				expected := fmt.Sprintf(`{"scope":"distributor","distributor":%q,"tenants":%s}`, distributors[0].cfg.DistributorRing.InstanceID, tc.expected)
+				require.JSONEq(t, expected, rec.Body.String())
+				return
+			}
+			require.Equal(t, tc.expected, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package ingestionpipeline
+
+import (
+	"sync"
+)
+
+// maxIdlePipelines is the maximum number of idle pipelines kept for each tenant.
+const maxIdlePipelines = 16
+
+// Cache caches the compiled pipelines of the tenants, so that they are not compiled
+// again by every push request. The pipelines of a tenant are compiled again when its
+// stages change, which happens when the overrides are reloaded.
+type Cache struct {
+	mtx     sync.Mutex
+	tenants map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	// stages are the overrides of the tenant the pipelines were compiled from.
+	stages []StageConfig
+	// err is the error the stages failed to compile with.
+	err  error
+	idle []*Pipeline
+}
+
+// NewCache creates a cache of the pipelines of the tenants.
+func NewCache() *Cache {
+	return &Cache{tenants: map[string]*cacheEntry{}}
+}
+
+// Get returns a pipeline running the stages of the tenant. Pipelines must only
+// be used by a single push request, and be released with Put once it is processed.
+func (c *Cache) Get(tenantID string, stages []StageConfig) (*Pipeline, error) {
+	c.mtx.Lock()
+	e, ok := c.tenants[tenantID]
+	if !ok || !sameStages(e.stages, stages) {
+		e = &cacheEntry{stages: stages}
+		c.tenants[tenantID] = e
+	}
+	if e.err != nil {
+		c.mtx.Unlock()
+		return nil, e.err
+	}
+	if n := len(e.idle); n > 0 {
+		p := e.idle[n-1]
+		e.idle = e.idle[:n-1]
+		c.mtx.Unlock()
+		return p, nil
+	}
+	c.mtx.Unlock()
+
+	p, err := New(stages)
+	if err != nil {
+		c.mtx.Lock()
+		if c.tenants[tenantID] == e {
+			e.err = err
+		}
+		c.mtx.Unlock()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Put releases a pipeline returned by Get. It is reused by the next push requests
+// of the tenant, unless its stages changed in the meantime.
+func (c *Cache) Put(tenantID string, p *Pipeline) {
+	// the pipelines of the streams are not reused, which would keep them for every stream of the tenant.
+	p.reset()
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.tenants[tenantID]
+	if !ok || !sameStages(e.stages, p.source) || len(e.idle) >= maxIdlePipelines {
+		return
+	}
+	e.idle = append(e.idle, p)
+}
+
+// sameStages tells if the stages are the same overrides. The overrides are replaced
+// when they are reloaded, so they are compared by identity.
+func sameStages(a, b []StageConfig) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
@@ -0,0 +1,45 @@
+package ingestionpipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	stages := []StageConfig{{Action: Drop, Expression: `|= "healthz"`}}
+	require.NoError(t, stages[0].Compile())
+	c := NewCache()
+
+	p, err := c.Get("tenant", stages)
+	require.NoError(t, err)
+	c.Put("tenant", p)
+
+	// the released pipeline is reused by the next request of the tenant.
+	reused, err := c.Get("tenant", stages)
+	require.NoError(t, err)
+	require.Same(t, p, reused)
+	other, err := c.Get("tenant", stages)
+	require.NoError(t, err)
+	require.NotSame(t, p, other, "a pipeline is only used by a single request")
+
+	// the pipelines are compiled again when the overrides are reloaded.
+	reloaded := []StageConfig{{Action: Drop, Expression: `|= "healthz"`}}
+	c.Put("tenant", reused)
+	p, err = c.Get("tenant", reloaded)
+	require.NoError(t, err)
+	require.NotSame(t, reused, p)
+	c.Put("tenant", other)
+	p, err = c.Get("tenant", reloaded)
+	require.NoError(t, err)
+	require.NotSame(t, other, p, "pipelines of the previous overrides are not reused")
+
+	// invalid stages fail until the overrides are reloaded.
+	invalid := []StageConfig{{Action: Mask, Regex: `(`}}
+	_, err = c.Get("tenant", invalid)
+	require.ErrorContains(t, err, "invalid regex")
+	_, err = c.Get("tenant", invalid)
+	require.ErrorContains(t, err, "invalid regex")
+	_, err = c.Get("tenant", reloaded)
+	require.NoError(t, err)
+}
@@ -0,0 +1,284 @@
+// Package ingestionpipeline implements the per-tenant pipelines that transform
+// the streams pushed to the distributor before they are validated.
+package ingestionpipeline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+)
+
+// Action is the action performed by a stage of an ingestion pipeline.
+type Action string
+
+const (
+	// DropLabels drops labels from the streams.
+	DropLabels Action = "drop_labels"
+	// RenameLabel renames a label of the streams.
+	RenameLabel Action = "rename_label"
+	// Drop drops the log lines matching the expression.
+	Drop Action = "drop"
+	// StructuredMetadata adds the labels extracted by the expression to the structured metadata of the log lines.
+	StructuredMetadata Action = "structured_metadata"
+	// Mask replaces the parts of the log lines matching the regex.
+	Mask Action = "mask"
+)
+
+// pipelineSelector is the stream selector the expressions of the stages are parsed with.
+// It is never matched against streams, the selector of the stage is used instead.
+const pipelineSelector = `{__ingestion_pipeline__=""} `
+
+var (
+	errUnsupportedAction  = fmt.Errorf("unsupported action, it must be one of: %s, %s, %s, %s, %s", DropLabels, RenameLabel, Drop, StructuredMetadata, Mask)
+	errMissingLabels      = errors.New("labels must be set")
+	errMissingRename      = errors.New("source and target must be set")
+	errMissingRegex       = errors.New("regex must be set")
+	errMissingExpression  = errors.New("expression must be set")
+	errUnexpectedPipeline = errors.New("the expression must only hold pipeline stages, like '|= \"debug\"' or '| logfmt'")
+)
+
+// StageConfig configures a stage of an ingestion pipeline.
+type StageConfig struct {
+	Selector    string   `yaml:"selector,omitempty" json:"selector,omitempty" doc:"description=Stream selector of the streams the stage applies to. The stage applies to all streams when empty."`
+	Action      Action   `yaml:"action" json:"action" doc:"description=Action of the stage, one of drop_labels, rename_label, drop, structured_metadata or mask."`
+	Labels      []string `yaml:"labels,omitempty" json:"labels,omitempty" doc:"description=Labels dropped by drop_labels, or extracted labels added to structured metadata by structured_metadata. All extracted labels are added when empty."`
+	Source      string   `yaml:"source,omitempty" json:"source,omitempty" doc:"description=Label renamed by rename_label."`
+	Target      string   `yaml:"target,omitempty" json:"target,omitempty" doc:"description=New name of the label renamed by rename_label."`
+	Expression  string   `yaml:"expression,omitempty" json:"expression,omitempty" doc:"description=LogQL pipeline stages of drop and structured_metadata. Lines matching the expression are dropped by drop, and the labels extracted by the expression are added by structured_metadata."`
+	Regex       string   `yaml:"regex,omitempty" json:"regex,omitempty" doc:"description=Regex of the parts of the lines replaced by mask."`
+	Replacement string   `yaml:"replacement,omitempty" json:"replacement,omitempty" doc:"description=Replacement of the parts of the lines matching the regex of mask. It may reference the capture groups of the regex like $1."`
+
+	// populated by Compile.
+	matchers []*labels.Matcher
+	expr     syntax.MultiStageExpr
+	regex    *regexp.Regexp
+	labels   map[string]struct{}
+	compiled bool
+}
+
+// Compile validates the stage and prepares it to be run.
+func (s *StageConfig) Compile() error {
+	s.matchers, s.expr, s.regex, s.labels = nil, nil, nil, nil
+
+	if s.Selector != "" {
+		matchers, err := syntax.ParseMatchers(s.Selector, false)
+		if err != nil {
+			return fmt.Errorf("invalid selector: %w", err)
+		}
+		s.matchers = matchers
+	}
+
+	switch s.Action {
+	case DropLabels:
+		if len(s.Labels) == 0 {
+			return errMissingLabels
+		}
+	case RenameLabel:
+		if s.Source == "" || s.Target == "" {
+			return errMissingRename
+		}
+		if !model.LabelName(s.Target).IsValid() {
+			return fmt.Errorf("invalid target label name %q", s.Target)
+		}
+	case Drop, StructuredMetadata:
+		if s.Action == Drop && s.Expression == "" && s.Selector == "" {
+			// dropping every line of the tenant is surely a configuration mistake.
+			return fmt.Errorf("selector or %w", errMissingExpression)
+		}
+		if s.Action == StructuredMetadata && s.Expression == "" {
+			return errMissingExpression
+		}
+		if s.Expression != "" {
+			expr, err := syntax.ParseLogSelector(pipelineSelector+s.Expression, false)
+			if err != nil {
+				return fmt.Errorf("invalid expression: %w", err)
+			}
+			pipelineExpr, ok := expr.(*syntax.PipelineExpr)
+			if !ok {
+				return errUnexpectedPipeline
+			}
+			if _, err := pipelineExpr.MultiStages.Pipeline(); err != nil {
+				return fmt.Errorf("invalid expression: %w", err)
+			}
+			s.expr = pipelineExpr.MultiStages
+		}
+		if len(s.Labels) > 0 {
+			s.labels = make(map[string]struct{}, len(s.Labels))
+			for _, name := range s.Labels {
+				s.labels[name] = struct{}{}
+			}
+		}
+	case Mask:
+		if s.Regex == "" {
+			return errMissingRegex
+		}
+		regex, err := regexp.Compile(s.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		s.regex = regex
+	default:
+		return errUnsupportedAction
+	}
+	s.compiled = true
+	return nil
+}
+
+func (s *StageConfig) matches(lbls labels.Labels) bool {
+	for _, m := range s.matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Pipeline runs the stages of the ingestion pipeline of a tenant.
+// LogQL pipelines are not safe for concurrent use, so a Pipeline must only be used by a single push request.
+type Pipeline struct {
+	source    []StageConfig
+	stages    []*StageConfig
+	pipelines []log.Pipeline // LogQL pipelines of the stages, indexed like stages
+}
+
+// New creates a pipeline running the given stages. The stages are usually
+// compiled when the limits are validated, the others are compiled on a copy.
+func New(stages []StageConfig) (*Pipeline, error) {
+	p := &Pipeline{
+		source:    stages,
+		stages:    make([]*StageConfig, 0, len(stages)),
+		pipelines: make([]log.Pipeline, 0, len(stages)),
+	}
+	for i := range stages {
+		s := &stages[i]
+		if !s.compiled {
+			cp := *s
+			if err := cp.Compile(); err != nil {
+				return nil, err
+			}
+			s = &cp
+		}
+		var pipeline log.Pipeline
+		if s.expr != nil {
+			var err error
+			if pipeline, err = s.expr.Pipeline(); err != nil {
+				return nil, err
+			}
+		}
+		p.stages = append(p.stages, s)
+		p.pipelines = append(p.pipelines, pipeline)
+	}
+	return p, nil
+}
+
+// reset forgets the pipelines of the streams the pipeline was used for.
+func (p *Pipeline) reset() {
+	for _, pipeline := range p.pipelines {
+		if pipeline != nil {
+			pipeline.Reset()
+		}
+	}
+}
+
+// ForStream applies the label stages to the labels of a stream and returns
+// them with the pipeline of its entries. The selector of each stage is
+// matched against the labels of the stream as transformed by the previous stages.
+func (p *Pipeline) ForStream(lbls labels.Labels) (labels.Labels, *StreamPipeline) {
+	sp := &StreamPipeline{}
+	for i, s := range p.stages {
+		if !s.matches(lbls) {
+			continue
+		}
+		switch s.Action {
+		case DropLabels:
+			b := labels.NewBuilder(lbls)
+			b.Del(s.Labels...)
+			lbls = b.Labels()
+		case RenameLabel:
+			if v := lbls.Get(s.Source); v != "" {
+				b := labels.NewBuilder(lbls)
+				b.Del(s.Source)
+				b.Set(s.Target, v)
+				lbls = b.Labels()
+			}
+		default:
+			stage := streamStage{cfg: s}
+			if p.pipelines[i] != nil {
+				stage.pipeline = p.pipelines[i].ForStream(lbls)
+			}
+			sp.stages = append(sp.stages, stage)
+		}
+	}
+	return lbls, sp
+}
+
+type streamStage struct {
+	cfg      *StageConfig
+	pipeline log.StreamPipeline
+}
+
+// StreamPipeline runs the stages of the ingestion pipeline that apply to the entries of a stream.
+type StreamPipeline struct {
+	stages []streamStage
+}
+
+// Empty tells if no stage applies to the entries of the stream.
+func (sp *StreamPipeline) Empty() bool {
+	return len(sp.stages) == 0
+}
+
+// ProcessEntry applies the stages to an entry. It returns false if the entry is dropped.
+func (sp *StreamPipeline) ProcessEntry(entry *logproto.Entry) bool {
+	for _, s := range sp.stages {
+		switch s.cfg.Action {
+		case Drop:
+			if s.pipeline == nil {
+				return false
+			}
+			if _, _, matches := s.pipeline.ProcessString(entry.Timestamp.UnixNano(), entry.Line, logproto.FromLabelAdaptersToLabels(entry.StructuredMetadata)...); matches {
+				return false
+			}
+		case StructuredMetadata:
+			// The structured metadata of the entry is not passed to the pipeline, so that
+			// the extracted labels override it instead of being suffixed with _extracted.
+			_, lbls, matches := s.pipeline.ProcessString(entry.Timestamp.UnixNano(), entry.Line)
+			if !matches || lbls.Labels().Has(logqlmodel.ErrorLabel) {
+				// nothing is extracted from the lines the expression fails to parse.
+				continue
+			}
+			for _, l := range lbls.Parsed() {
+				if strings.HasPrefix(l.Name, "__") {
+					continue
+				}
+				if s.cfg.labels != nil {
+					if _, ok := s.cfg.labels[l.Name]; !ok {
+						continue
+					}
+				}
+				entry.StructuredMetadata = setStructuredMetadata(entry.StructuredMetadata, l.Name, l.Value)
+			}
+		case Mask:
+			entry.Line = s.cfg.regex.ReplaceAllString(entry.Line, s.cfg.Replacement)
+		}
+	}
+	return true
+}
+
+func setStructuredMetadata(metadata []logproto.LabelAdapter, name, value string) []logproto.LabelAdapter {
+	for i := range metadata {
+		if metadata[i].Name == name {
+			metadata[i].Value = value
+			return metadata
+		}
+	}
+	return append(metadata, logproto.LabelAdapter{Name: name, Value: value})
+}
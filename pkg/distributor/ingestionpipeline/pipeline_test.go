@@ -0,0 +1,156 @@
+package ingestionpipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestStageConfig_Compile(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		stage StageConfig
+		err   string
+	}{
+		{
+			name:  "drop labels",
+			stage: StageConfig{Action: DropLabels, Labels: []string{"pod"}},
+		},
+		{
+			name:  "drop labels without labels",
+			stage: StageConfig{Action: DropLabels},
+			err:   "labels must be set",
+		},
+		{
+			name:  "rename label",
+			stage: StageConfig{Selector: `{app="foo"}`, Action: RenameLabel, Source: "svc", Target: "service_name"},
+		},
+		{
+			name:  "rename label to invalid name",
+			stage: StageConfig{Action: RenameLabel, Source: "svc", Target: "service.name"},
+			err:   `invalid target label name "service.name"`,
+		},
+		{
+			name:  "drop with filter",
+			stage: StageConfig{Action: Drop, Expression: `|= "healthz" | logfmt | status="200"`},
+		},
+		{
+			name:  "drop streams",
+			stage: StageConfig{Selector: `{app="noisy"}`, Action: Drop},
+		},
+		{
+			name:  "drop everything",
+			stage: StageConfig{Action: Drop},
+			err:   "selector or expression must be set",
+		},
+		{
+			name:  "drop with invalid expression",
+			stage: StageConfig{Action: Drop, Expression: `|= `},
+			err:   "invalid expression: parse error at line 1, col 32: syntax error: unexpected $end, expecting STRING or ip",
+		},
+		{
+			name:  "structured metadata",
+			stage: StageConfig{Action: StructuredMetadata, Expression: `| json`, Labels: []string{"trace_id"}},
+		},
+		{
+			name:  "structured metadata without expression",
+			stage: StageConfig{Action: StructuredMetadata},
+			err:   "expression must be set",
+		},
+		{
+			name:  "mask",
+			stage: StageConfig{Action: Mask, Regex: `password=\S+`, Replacement: "password=***"},
+		},
+		{
+			name:  "mask with invalid regex",
+			stage: StageConfig{Action: Mask, Regex: `(`},
+			err:   "invalid regex: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:  "invalid selector",
+			stage: StageConfig{Selector: `{app=}`, Action: DropLabels, Labels: []string{"pod"}},
+			err:   "invalid selector: parse error at line 1, col 6: syntax error: unexpected }, expecting STRING",
+		},
+		{
+			name:  "unknown action",
+			stage: StageConfig{Action: "relabel"},
+			err:   "unsupported action, it must be one of: drop_labels, rename_label, drop, structured_metadata, mask",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.stage.Compile()
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	stages := []StageConfig{
+		{Action: DropLabels, Labels: []string{"pod_template_hash"}},
+		{Selector: `{app="api"}`, Action: RenameLabel, Source: "svc", Target: "service_name"},
+		{Selector: `{service_name="checkout"}`, Action: Drop, Expression: `|= "GET /healthz"`},
+		{Selector: `{app="api"}`, Action: StructuredMetadata, Expression: `| logfmt`, Labels: []string{"trace_id"}},
+		{Action: Mask, Regex: `password=\S+`, Replacement: "password=***"},
+		{Selector: `{app="noisy"}`, Action: Drop},
+	}
+	for i := range stages {
+		require.NoError(t, stages[i].Compile())
+	}
+	p, err := New(stages)
+	require.NoError(t, err)
+
+	process := func(sp *StreamPipeline, line string) (logproto.Entry, bool) {
+		entry := logproto.Entry{Timestamp: time.Unix(0, 1), Line: line}
+		ok := sp.ProcessEntry(&entry)
+		return entry, ok
+	}
+
+	lbls, sp := p.ForStream(labels.FromStrings("app", "api", "svc", "checkout", "pod_template_hash", "abc"))
+	require.Equal(t, labels.FromStrings("app", "api", "service_name", "checkout"), lbls)
+
+	_, ok := process(sp, `msg="GET /healthz" status=200`)
+	require.False(t, ok)
+
+	entry, ok := process(sp, `msg="login" trace_id=123 user=foo password=secret`)
+	require.True(t, ok)
+	require.Equal(t, `msg="login" trace_id=123 user=foo password=***`, entry.Line)
+	require.Equal(t, []logproto.LabelAdapter{{Name: "trace_id", Value: "123"}}, []logproto.LabelAdapter(entry.StructuredMetadata))
+
+	// Stages only apply to the streams matching their selector.
+	lbls, sp = p.ForStream(labels.FromStrings("app", "web", "svc", "checkout"))
+	require.Equal(t, labels.FromStrings("app", "web", "svc", "checkout"), lbls)
+	entry, ok = process(sp, `msg="GET /healthz" trace_id=123 password=secret`)
+	require.True(t, ok)
+	require.Equal(t, `msg="GET /healthz" trace_id=123 password=***`, entry.Line)
+	require.Empty(t, entry.StructuredMetadata)
+
+	lbls, sp = p.ForStream(labels.FromStrings("app", "noisy"))
+	require.Equal(t, labels.FromStrings("app", "noisy"), lbls)
+	_, ok = process(sp, "anything")
+	require.False(t, ok)
+}
+
+func TestPipeline_StructuredMetadata(t *testing.T) {
+	p, err := New([]StageConfig{{Action: StructuredMetadata, Expression: `| json`}})
+	require.NoError(t, err, "the stages are compiled if they were not")
+
+	_, sp := p.ForStream(labels.FromStrings("app", "api"))
+	entry := logproto.Entry{
+		Line:               `{"trace_id":"abc","level":"info","broken":`,
+		StructuredMetadata: []logproto.LabelAdapter{{Name: "trace_id", Value: "old"}},
+	}
+	require.True(t, sp.ProcessEntry(&entry))
+	require.Equal(t, []logproto.LabelAdapter{{Name: "trace_id", Value: "old"}}, []logproto.LabelAdapter(entry.StructuredMetadata), "nothing is extracted from the lines that fail to parse")
+
+	entry.Line = `{"trace_id":"abc","level":"info"}`
+	require.True(t, sp.ProcessEntry(&entry))
+	require.Equal(t, []logproto.LabelAdapter{{Name: "trace_id", Value: "abc"}, {Name: "level", Value: "info"}}, []logproto.LabelAdapter(entry.StructuredMetadata))
+}
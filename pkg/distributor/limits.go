@@ -4,8 +4,13 @@ import (
 	"time"
 
 	"github.com/grafana/loki/v3/pkg/compactor/retention"
+	"github.com/grafana/loki/v3/pkg/distributor/cardinality"
+	"github.com/grafana/loki/v3/pkg/distributor/ingestionpipeline"
+	"github.com/grafana/loki/v3/pkg/distributor/redaction"
+	"github.com/grafana/loki/v3/pkg/distributor/sampling"
 	"github.com/grafana/loki/v3/pkg/distributor/shardstreams"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
+	"github.com/grafana/loki/v3/pkg/validation"
 )
 
 // Limits is an interface for distributor limits/related configs
@@ -22,6 +27,8 @@ type Limits interface {
 	RejectOldSamplesMaxAge(userID string) time.Duration
 
 	IncrementDuplicateTimestamps(userID string) bool
+	DeduplicationWindow(userID string) time.Duration
+	DeduplicationMaxKeys(userID string) int
 	DiscoverServiceName(userID string) []string
 	DiscoverLogLevels(userID string) bool
 
@@ -32,7 +39,13 @@ type Limits interface {
 	AllowStructuredMetadata(userID string) bool
 	MaxStructuredMetadataSize(userID string) int
 	MaxStructuredMetadataCount(userID string) int
+	StructuredMetadataSchema(userID string) validation.StructuredMetadataSchema
 	OTLPConfig(userID string) push.OTLPConfig
+	ElasticsearchConfig(userID string) push.ElasticsearchConfig
+	IngestionPipeline(userID string) []ingestionpipeline.StageConfig
+	Redaction(userID string) redaction.Config
+	LabelCardinality(userID string) cardinality.Config
+	SamplingRules(userID string) []sampling.Rule
 
 	BlockIngestionUntil(userID string) time.Time
 	BlockIngestionStatusCode(userID string) int
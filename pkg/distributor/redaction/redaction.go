@@ -0,0 +1,294 @@
+// Package redaction implements the redaction of sensitive data from the log
+// lines pushed to the distributor.
+package redaction
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+// Mode is how the sensitive values are redacted.
+type Mode string
+
+const (
+	// ModeReplace replaces the sensitive values with a fixed replacement.
+	ModeReplace Mode = "replace"
+	// ModeHash replaces the sensitive values with a hash, so that they can still be correlated.
+	ModeHash Mode = "hash"
+
+	defaultReplacement = "[REDACTED]"
+	// hashLength is the number of hexadecimal characters of the hashes.
+	hashLength = 16
+)
+
+// Built-in detectors.
+const (
+	CreditCard  = "credit_card"
+	Email       = "email"
+	IP          = "ip"
+	BearerToken = "bearer_token"
+	JWT         = "jwt"
+)
+
+type detector struct {
+	name  string
+	regex *regexp.Regexp
+	// valid tells if a match is actually a sensitive value. Optional.
+	valid func(string) bool
+}
+
+// builtinDetectors are run in this order, before the custom detectors.
+var builtinDetectors = []*detector{
+	{
+		// 13 to 19 digits, optionally grouped with spaces or dashes. Card numbers
+		// start with 2 to 6, which excludes the timestamps in seconds to nanoseconds.
+		name:  CreditCard,
+		regex: regexp.MustCompile(`\b[2-6]\d(?:[ -]?\d){11,17}\b`),
+		valid: luhn,
+	},
+	{
+		name:  Email,
+		regex: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	{
+		name:  IP,
+		regex: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?i:\b(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}\b)`),
+		valid: func(s string) bool { return net.ParseIP(s) != nil },
+	},
+	{
+		// only the token is redacted.
+		name:  BearerToken,
+		regex: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+	},
+	{
+		name:  JWT,
+		regex: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+}
+
+func builtinDetector(name string) *detector {
+	for _, d := range builtinDetectors {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// CustomDetector is a detector of sensitive values defined by a regex.
+type CustomDetector struct {
+	Name  string `yaml:"name" json:"name" doc:"description=Name of the detector, used as the value of the detector label of the metrics."`
+	Regex string `yaml:"regex" json:"regex" doc:"description=Regex matching the sensitive values. If the regex has capture groups, only the first one is redacted."`
+}
+
+// Config configures the redaction of the log lines of a tenant.
+type Config struct {
+	Detectors       []string         `yaml:"detectors,omitempty" json:"detectors,omitempty" doc:"description=Built-in detectors of sensitive values to redact from the log lines, their structured metadata and the label values of their streams. Supported detectors are credit_card (Luhn validated card numbers), email, ip, bearer_token and jwt."`
+	CustomDetectors []CustomDetector `yaml:"custom_detectors,omitempty" json:"custom_detectors,omitempty" doc:"description=Detectors of sensitive values defined by regexes, run after the built-in detectors."`
+	Mode            Mode             `yaml:"mode,omitempty" json:"mode,omitempty" doc:"description=How the sensitive values are redacted, either replace or hash. replace, the default, replaces them with the replacement. hash replaces them with a truncated HMAC-SHA256 of the value, so that they can still be correlated."`
+	Replacement     string           `yaml:"replacement,omitempty" json:"replacement,omitempty" doc:"description=Replacement of the sensitive values in replace mode. Defaults to [REDACTED]."`
+	HashKey         string           `yaml:"hash_key,omitempty" json:"hash_key,omitempty" doc:"description=Key of the HMAC used in hash mode. Without a key, values with few possibilities like IPs can be recovered from their hash."`
+
+	// populated by Compile.
+	detectors []*detector
+}
+
+// Enabled tells if any detector is configured.
+func (cfg *Config) Enabled() bool {
+	return len(cfg.Detectors) > 0 || len(cfg.CustomDetectors) > 0
+}
+
+// Compile validates the config and prepares the detectors.
+func (cfg *Config) Compile() error {
+	cfg.detectors = nil
+	switch cfg.Mode {
+	case "", ModeReplace, ModeHash:
+	default:
+		return fmt.Errorf("unsupported redaction mode %q, it must be one of: %s, %s", cfg.Mode, ModeReplace, ModeHash)
+	}
+
+	enabled := make(map[string]struct{}, len(cfg.Detectors))
+	for _, name := range cfg.Detectors {
+		if builtinDetector(name) == nil {
+			return fmt.Errorf("unknown redaction detector %q, it must be one of: %s, %s, %s, %s, %s", name, CreditCard, Email, IP, BearerToken, JWT)
+		}
+		enabled[name] = struct{}{}
+	}
+	detectors := make([]*detector, 0, len(enabled)+len(cfg.CustomDetectors))
+	for _, d := range builtinDetectors {
+		if _, ok := enabled[d.name]; ok {
+			detectors = append(detectors, d)
+		}
+	}
+	for _, c := range cfg.CustomDetectors {
+		if c.Name == "" {
+			return fmt.Errorf("the name of custom redaction detectors must be set")
+		}
+		if builtinDetector(c.Name) != nil {
+			return fmt.Errorf("custom redaction detector %q has the name of a built-in detector", c.Name)
+		}
+		regex, err := regexp.Compile(c.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex of custom redaction detector %q: %w", c.Name, err)
+		}
+		detectors = append(detectors, &detector{name: c.Name, regex: regex})
+	}
+	cfg.detectors = detectors
+	return nil
+}
+
+// Redactor redacts the sensitive values from log entries. It is safe for concurrent use.
+type Redactor struct {
+	detectors   []*detector
+	mode        Mode
+	replacement string
+	hashKey     []byte
+}
+
+// New creates a redactor of the sensitive values detected by the config.
+// The config is usually compiled when the limits are validated, otherwise it is compiled on a copy.
+func New(cfg Config) (*Redactor, error) {
+	if cfg.detectors == nil {
+		if err := cfg.Compile(); err != nil {
+			return nil, err
+		}
+	}
+	r := &Redactor{
+		detectors:   cfg.detectors,
+		mode:        cfg.Mode,
+		replacement: cfg.Replacement,
+		hashKey:     []byte(cfg.HashKey),
+	}
+	if r.mode == "" {
+		r.mode = ModeReplace
+	}
+	if r.replacement == "" {
+		r.replacement = defaultReplacement
+	}
+	return r, nil
+}
+
+// RedactEntry redacts the line and the structured metadata values of an entry.
+// redacted is called with the number of bytes redacted by each detector.
+func (r *Redactor) RedactEntry(entry *logproto.Entry, redacted func(detector string, bytes int)) {
+	entry.Line = r.Redact(entry.Line, redacted)
+	for i := range entry.StructuredMetadata {
+		entry.StructuredMetadata[i].Value = r.Redact(entry.StructuredMetadata[i].Value, redacted)
+	}
+}
+
+// RedactLabels redacts the label values of a stream. Internal labels, whose names start with
+// __, are not redacted. It returns false with the labels unchanged when no value is sensitive.
+func (r *Redactor) RedactLabels(lbls labels.Labels, redacted func(detector string, bytes int)) (labels.Labels, bool) {
+	var b *labels.Builder
+	lbls.Range(func(l labels.Label) {
+		if strings.HasPrefix(l.Name, "__") {
+			return
+		}
+		if v := r.Redact(l.Value, redacted); v != l.Value {
+			if b == nil {
+				b = labels.NewBuilder(lbls)
+			}
+			b.Set(l.Name, v)
+		}
+	})
+	if b == nil {
+		return lbls, false
+	}
+	return b.Labels(), true
+}
+
+// Redact redacts the sensitive values from s.
+// redacted is called with the number of bytes redacted by each detector.
+func (r *Redactor) Redact(s string, redacted func(detector string, bytes int)) string {
+	for _, d := range r.detectors {
+		s = r.redact(d, s, redacted)
+	}
+	return s
+}
+
+func (r *Redactor) redact(d *detector, s string, redacted func(detector string, bytes int)) string {
+	matches := d.regex.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var (
+		sb    strings.Builder
+		last  int
+		total int
+	)
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			// only redact the first capture group.
+			start, end = m[2], m[3]
+		}
+		value := s[start:end]
+		if value == "" || (d.valid != nil && !d.valid(value)) {
+			continue
+		}
+		if total == 0 {
+			sb.Grow(len(s))
+		}
+		sb.WriteString(s[last:start])
+		sb.WriteString(r.replace(value))
+		last = end
+		total += len(value)
+	}
+	if total == 0 {
+		return s
+	}
+	sb.WriteString(s[last:])
+	if redacted != nil {
+		redacted(d.name, total)
+	}
+	return sb.String()
+}
+
+func (r *Redactor) replace(value string) string {
+	if r.mode != ModeHash {
+		return r.replacement
+	}
+	h := hmac.New(sha256.New, r.hashKey)
+	_, _ = h.Write([]byte(value))
+	return "[HASH:" + hex.EncodeToString(h.Sum(nil))[:hashLength] + "]"
+}
+
+// luhn tells if the digits of s, ignoring spaces and dashes, pass the Luhn checksum.
+func luhn(s string) bool {
+	var (
+		sum    int
+		digits int
+		double bool
+	)
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
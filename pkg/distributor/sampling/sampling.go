@@ -0,0 +1,166 @@
+// Package sampling implements the per-tenant sampling of the log lines of
+// high-volume streams pushed to the distributor.
+package sampling
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+// SampledRateLabel is the structured metadata added to the sampled log lines, holding the rate they were sampled at.
+const SampledRateLabel = "__sampled_rate__"
+
+// filterSelector is the stream selector the line filters of the rules are parsed with.
+// It is never matched against streams, the selector of the rule is used instead.
+const filterSelector = `{__sampling__=""} `
+
+var (
+	errMissingSelector = errors.New("selector must be set")
+	errInvalidRate     = errors.New("rate must be greater than 0 and lower than 1")
+	errNotLineFilter   = errors.New("the line filter must only hold LogQL line filters, like '|= \"debug\"'")
+)
+
+// Rule keeps a fraction of the log lines of the streams matching its selector.
+type Rule struct {
+	Selector   string  `yaml:"selector" json:"selector" doc:"description=Stream selector of the streams the rule applies to."`
+	LineFilter string  `yaml:"line_filter,omitempty" json:"line_filter,omitempty" doc:"description=LogQL line filters the log lines must match for the rule to apply. The rule applies to all the lines of the streams when empty."`
+	Rate       float64 `yaml:"rate" json:"rate" doc:"description=Fraction of the log lines kept, greater than 0 and lower than 1."`
+
+	// populated by Compile.
+	matchers []*labels.Matcher
+	filter   log.Filterer
+	value    string
+	compiled bool
+}
+
+// Compile validates the rule and prepares it to be applied.
+func (r *Rule) Compile() error {
+	r.matchers, r.filter = nil, nil
+
+	if r.Selector == "" {
+		return errMissingSelector
+	}
+	matchers, err := syntax.ParseMatchers(r.Selector, false)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	r.matchers = matchers
+
+	if r.Rate <= 0 || r.Rate >= 1 {
+		return errInvalidRate
+	}
+	r.value = strconv.FormatFloat(r.Rate, 'f', -1, 64)
+
+	if r.LineFilter != "" {
+		expr, err := syntax.ParseLogSelector(filterSelector+r.LineFilter, false)
+		if err != nil {
+			return fmt.Errorf("invalid line filter: %w", err)
+		}
+		pipelineExpr, ok := expr.(*syntax.PipelineExpr)
+		if !ok {
+			return errNotLineFilter
+		}
+		filters := make([]log.Filterer, 0, len(pipelineExpr.MultiStages))
+		for _, stage := range pipelineExpr.MultiStages {
+			lineFilter, ok := stage.(*syntax.LineFilterExpr)
+			if !ok {
+				return errNotLineFilter
+			}
+			filter, err := lineFilter.Filter()
+			if err != nil {
+				return fmt.Errorf("invalid line filter: %w", err)
+			}
+			filters = append(filters, filter)
+		}
+		r.filter = log.NewAndFilters(filters)
+	}
+	r.compiled = true
+	return nil
+}
+
+func (r *Rule) matches(lbls labels.Labels) bool {
+	for _, m := range r.matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sampler samples the log lines of the streams of a tenant. It is safe for concurrent use.
+type Sampler struct {
+	rules []*Rule
+}
+
+// New creates a sampler applying the given rules. The rules are usually
+// compiled when the limits are validated, the others are compiled on a copy.
+func New(rules []Rule) (*Sampler, error) {
+	s := &Sampler{rules: make([]*Rule, 0, len(rules))}
+	for i := range rules {
+		r := &rules[i]
+		if !r.compiled {
+			cp := *r
+			if err := cp.Compile(); err != nil {
+				return nil, err
+			}
+			r = &cp
+		}
+		s.rules = append(s.rules, r)
+	}
+	return s, nil
+}
+
+// ForStream returns the sampler of the log lines of a stream, or nil if no rule applies to it.
+func (s *Sampler) ForStream(lbls labels.Labels, streamHash uint64) *StreamSampler {
+	var rules []*Rule
+	for _, r := range s.rules {
+		if r.matches(lbls) {
+			rules = append(rules, r)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return &StreamSampler{rules: rules, streamHash: streamHash}
+}
+
+// StreamSampler samples the log lines of a stream.
+type StreamSampler struct {
+	rules      []*Rule
+	streamHash uint64
+	buf        []byte
+}
+
+// Sample tells if an entry is kept. The first rule whose line filter matches the entry applies,
+// and the rate of the rule is added to the structured metadata of the entry when it is kept.
+// The entries are sampled by their hash, so that an entry pushed again is sampled the same way.
+func (ss *StreamSampler) Sample(entry *logproto.Entry) bool {
+	for _, r := range ss.rules {
+		if r.filter != nil && !r.filter.Filter([]byte(entry.Line)) {
+			continue
+		}
+		if float64(ss.hash(entry))/math.MaxUint64 >= r.Rate {
+			return false
+		}
+		entry.StructuredMetadata = append(entry.StructuredMetadata, logproto.LabelAdapter{Name: SampledRateLabel, Value: r.value})
+		return true
+	}
+	return true
+}
+
+func (ss *StreamSampler) hash(entry *logproto.Entry) uint64 {
+	ss.buf = binary.LittleEndian.AppendUint64(ss.buf[:0], ss.streamHash)
+	ss.buf = binary.LittleEndian.AppendUint64(ss.buf, uint64(entry.Timestamp.UnixNano()))
+	ss.buf = append(ss.buf, entry.Line...)
+	return xxhash.Sum64(ss.buf)
+}
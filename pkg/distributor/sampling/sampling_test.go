@@ -0,0 +1,81 @@
+package sampling
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestSampler(t *testing.T) {
+	sampler, err := New([]Rule{
+		{Selector: `{app="api"}`, LineFilter: `|= "GET /health" != "error"`, Rate: 0.01},
+		{Selector: `{level="debug"}`, Rate: 0.5},
+	})
+	require.NoError(t, err)
+
+	require.Nil(t, sampler.ForStream(labels.FromStrings("app", "web", "level", "info"), 1))
+
+	lbs := labels.FromStrings("app", "api", "level", "debug")
+	ss := sampler.ForStream(lbs, lbs.Hash())
+	require.NotNil(t, ss)
+
+	now := time.Now()
+	kept := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		for _, line := range []string{"GET /health 200", "GET /health error", "POST /orders"} {
+			entry := logproto.Entry{Timestamp: now.Add(time.Duration(i)), Line: line}
+			if !ss.Sample(&entry) {
+				continue
+			}
+			kept[line]++
+			require.Len(t, entry.StructuredMetadata, 1)
+			require.Equal(t, SampledRateLabel, entry.StructuredMetadata[0].Name)
+			if line == "GET /health 200" {
+				require.Equal(t, "0.01", entry.StructuredMetadata[0].Value)
+			} else {
+				require.Equal(t, "0.5", entry.StructuredMetadata[0].Value)
+			}
+		}
+	}
+	require.InDelta(t, 10, kept["GET /health 200"], 10)
+	require.InDelta(t, 500, kept["GET /health error"], 75, "the lines not matching the line filter fall through to the next rule")
+	require.InDelta(t, 500, kept["POST /orders"], 75)
+
+	// an entry pushed again is sampled the same way.
+	for i := 0; i < 100; i++ {
+		entry := logproto.Entry{Timestamp: now.Add(time.Duration(i)), Line: "POST /orders"}
+		first := ss.Sample(&entry)
+		entry = logproto.Entry{Timestamp: now.Add(time.Duration(i)), Line: "POST /orders"}
+		require.Equal(t, first, ss.Sample(&entry), fmt.Sprintf("entry %d", i))
+	}
+}
+
+func TestRule_Compile(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		rule Rule
+		err  string
+	}{
+		{name: "valid", rule: Rule{Selector: `{app="api"}`, LineFilter: `|~ "(?i)debug"`, Rate: 0.1}},
+		{name: "missing selector", rule: Rule{Rate: 0.1}, err: "selector must be set"},
+		{name: "invalid selector", rule: Rule{Selector: `{app=}`, Rate: 0.1}, err: "invalid selector"},
+		{name: "zero rate", rule: Rule{Selector: `{app="api"}`}, err: "rate must be greater than 0 and lower than 1"},
+		{name: "rate above one", rule: Rule{Selector: `{app="api"}`, Rate: 2}, err: "rate must be greater than 0 and lower than 1"},
+		{name: "parser", rule: Rule{Selector: `{app="api"}`, LineFilter: `| logfmt`, Rate: 0.1}, err: `the line filter must only hold LogQL line filters, like '|= "debug"'`},
+		{name: "invalid line filter", rule: Rule{Selector: `{app="api"}`, LineFilter: `|~ "("`, Rate: 0.1}, err: "invalid line filter"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.rule.Compile()
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
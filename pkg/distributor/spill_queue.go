@@ -0,0 +1,383 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/record"
+	"github.com/prometheus/prometheus/tsdb/wlog"
+	"golang.org/x/time/rate"
+
+	ingesterwal "github.com/grafana/loki/v3/pkg/ingester/wal"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/util/flagext"
+	lokiring "github.com/grafana/loki/v3/pkg/util/ring"
+	"github.com/grafana/loki/v3/pkg/util/wal"
+)
+
+const (
+	spillQueueSegmentSize = 16 << 20
+	// spillQueueOffsetFile stores the position of the last replayed record, so that
+	// the pushes already sent are not sent again after a failed replay or a restart.
+	spillQueueOffsetFile = "replay_offset"
+
+	spillDroppedFull      = "full"
+	spillDroppedExpired   = "expired"
+	spillDroppedCorrupted = "corrupted"
+	spillDroppedRejected  = "rejected"
+)
+
+// SpillQueueConfig configures the queue in which the distributor spills the pushes failing because the ingesters are unavailable.
+type SpillQueueConfig struct {
+	Enabled        bool             `yaml:"enabled"`
+	Dir            string           `yaml:"dir"`
+	MaxSize        flagext.ByteSize `yaml:"max_size"`
+	MaxAge         time.Duration    `yaml:"max_age"`
+	ReplayInterval time.Duration    `yaml:"replay_interval"`
+	ReplayRate     float64          `yaml:"replay_rate"`
+}
+
+// RegisterFlagsWithPrefix registers the flags of the spill queue.
+func (cfg *SpillQueueConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+".enabled", false, "Write the pushes failing because the ingesters are unavailable to a local disk queue and acknowledge them, instead of failing them. Only the streams of a push which were not written to a quorum of ingesters are queued, and they are synced to disk before the push is acknowledged. The queued pushes are replayed once the ingesters recover. Pushes failing with client errors, like rate limits, are never queued.")
+	f.StringVar(&cfg.Dir, prefix+".dir", "spill-queue", "Directory of the spill queue. It must be persisted across restarts of the distributor for the queued pushes to survive them.")
+	_ = cfg.MaxSize.Set("1GB")
+	f.Var(&cfg.MaxSize, prefix+".max-size", "Maximum size of the spill queue on disk. Pushes failing when the queue is full are returned to the clients.")
+	f.DurationVar(&cfg.MaxAge, prefix+".max-age", time.Hour, "Maximum age of the queued pushes. Older pushes are dropped instead of being replayed.")
+	f.DurationVar(&cfg.ReplayInterval, prefix+".replay-interval", 10*time.Second, "Interval at which the queued pushes are replayed. The replay stops at the first push failing because the ingesters are unavailable, and resumes from it at the next interval. Queued pushes rejected by the ingesters, like rate limited ones, are dropped.")
+	f.Float64Var(&cfg.ReplayRate, prefix+".replay-rate", 50, "Maximum number of queued pushes replayed per second, so that the recovering ingesters are not overwhelmed.")
+}
+
+// Validate validates the config of the spill queue.
+func (cfg *SpillQueueConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Dir == "" {
+		return errors.New("the spill queue directory must be set")
+	}
+	if cfg.MaxSize == 0 || cfg.MaxAge <= 0 {
+		return errors.New("the maximum size and age of the spill queue must be positive")
+	}
+	if cfg.ReplayInterval <= 0 || cfg.ReplayRate <= 0 {
+		return errors.New("the replay interval and rate of the spill queue must be positive")
+	}
+	return nil
+}
+
+// spillable tells if a push failed because of the ingesters, rather than because of the pushed data.
+func spillable(err error) bool {
+	if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return resp.Code/100 != 4
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+type sendFunc func(ctx context.Context, tenantID string, streams []KeyedStream) error
+
+// spillQueue is a queue of pushes on disk, using the record encoding of the WAL of the ingesters.
+// Each push is a series record, referencing the streams by their index, followed by an entries record.
+type spillQueue struct {
+	services.Service
+
+	cfg     SpillQueueConfig
+	send    sendFunc
+	logger  log.Logger
+	limiter *rate.Limiter
+
+	// mtx serializes the writes with the rotations of the segments, so that
+	// the records of a push are never split by a rotation of the replay.
+	mtx sync.Mutex
+	wal *wlog.WL
+
+	spilledRequests  prometheus.Counter
+	replayedRequests prometheus.Counter
+	droppedBytes     *prometheus.CounterVec
+	size             prometheus.Gauge
+}
+
+func newSpillQueue(cfg SpillQueueConfig, send sendFunc, logger log.Logger, registerer prometheus.Registerer) (*spillQueue, error) {
+	w, err := wlog.NewSize(logger, nil, cfg.Dir, spillQueueSegmentSize, wlog.CompressionSnappy)
+	if err != nil {
+		return nil, fmt.Errorf("open spill queue: %w", err)
+	}
+
+	q := &spillQueue{
+		cfg:     cfg,
+		send:    send,
+		logger:  log.With(logger, "component", "spill-queue"),
+		limiter: rate.NewLimiter(rate.Limit(cfg.ReplayRate), 1),
+		wal:     w,
+		spilledRequests: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_spill_queue_spilled_requests_total",
+			Help:      "The total number of pushes written to the spill queue because the ingesters were unavailable.",
+		}),
+		replayedRequests: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_spill_queue_replayed_requests_total",
+			Help:      "The total number of pushes of the spill queue replayed to the ingesters.",
+		}),
+		droppedBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_spill_queue_dropped_bytes_total",
+			Help:      "The total number of bytes of pushes which could not be written to the spill queue because it was full, or were dropped from it because they were too old, corrupted or rejected by the ingesters.",
+		}, []string{"reason"}),
+		size: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_spill_queue_size_bytes",
+			Help:      "The size of the spill queue on disk.",
+		}),
+	}
+	q.Service = services.NewTimerService(cfg.ReplayInterval, nil, q.iteration, q.stopping)
+	return q, nil
+}
+
+func (q *spillQueue) iteration(ctx context.Context) error {
+	if err := q.replay(ctx); err != nil {
+		level.Warn(q.logger).Log("msg", "failed to replay the spill queue, retrying at the next interval", "err", err)
+	}
+	return nil
+}
+
+func (q *spillQueue) stopping(_ error) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	return q.wal.Close()
+}
+
+// spill writes the streams of a push to the queue.
+func (q *spillQueue) spill(tenantID string, streams []KeyedStream) error {
+	rec := ingesterwal.Record{
+		UserID:     tenantID,
+		Series:     make([]record.RefSeries, 0, len(streams)),
+		RefEntries: make([]ingesterwal.RefEntries, 0, len(streams)),
+	}
+	for i, stream := range streams {
+		lbs, err := syntax.ParseLabels(stream.Stream.Labels)
+		if err != nil {
+			return err
+		}
+		rec.Series = append(rec.Series, record.RefSeries{Ref: chunks.HeadSeriesRef(i), Labels: lbs})
+		rec.RefEntries = append(rec.RefEntries, ingesterwal.RefEntries{Ref: chunks.HeadSeriesRef(i), Entries: stream.Stream.Entries})
+	}
+	series := rec.EncodeSeries(nil)
+	entries := rec.EncodeEntries(ingesterwal.CurrentEntriesRec, nil)
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	size, err := q.wal.Size()
+	if err != nil {
+		return err
+	}
+	if size+int64(len(series)+len(entries)) > int64(q.cfg.MaxSize) {
+		q.droppedBytes.WithLabelValues(spillDroppedFull).Add(float64(len(series) + len(entries)))
+		return errors.New("the spill queue is full")
+	}
+	if err := q.wal.Log(series, entries); err != nil {
+		return err
+	}
+	// the push is acknowledged once spilled, so it must survive a crash of the distributor.
+	if err := q.wal.Sync(); err != nil {
+		return err
+	}
+	q.spilledRequests.Inc()
+	q.size.Set(float64(size + int64(len(series)+len(entries))))
+	return nil
+}
+
+// replay sends the queued pushes to the ingesters, and removes the segments once all their pushes are sent.
+func (q *spillQueue) replay(ctx context.Context) error {
+	first, last, err := q.closeSegment()
+	if err != nil || first > last {
+		return err
+	}
+	defer q.updateSize()
+
+	// the segments are written in order, so the expired ones are the first ones.
+	for ; first <= last; first++ {
+		info, err := os.Stat(wlog.SegmentName(q.cfg.Dir, first))
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) <= q.cfg.MaxAge {
+			break
+		}
+		q.droppedBytes.WithLabelValues(spillDroppedExpired).Add(float64(info.Size()))
+	}
+	if err := q.wal.Truncate(first); err != nil || first > last {
+		return err
+	}
+
+	reader, closer, err := wal.NewWalRangeReader(q.cfg.Dir, first, last)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	offset := q.readOffset()
+	current := replayOffset{Segment: -1}
+
+	rec := &ingesterwal.Record{}
+	var series map[chunks.HeadSeriesRef]labels.Labels
+	for reader.Next() {
+		if reader.Segment() != current.Segment {
+			current = replayOffset{Segment: reader.Segment()}
+		}
+		current.Records++
+		if current.Segment == offset.Segment && current.Records <= offset.Records {
+			// the record was replayed before.
+			continue
+		}
+
+		b := reader.Record()
+		rec.Series, rec.RefEntries = rec.Series[:0], rec.RefEntries[:0]
+		if err := ingesterwal.DecodeRecord(b, rec); err != nil {
+			q.droppedBytes.WithLabelValues(spillDroppedCorrupted).Add(float64(len(b)))
+			level.Warn(q.logger).Log("msg", "dropping corrupted record of the spill queue", "segment", reader.Segment(), "err", err)
+			continue
+		}
+
+		if len(rec.Series) > 0 {
+			series = make(map[chunks.HeadSeriesRef]labels.Labels, len(rec.Series))
+			for _, s := range rec.Series {
+				series[s.Ref] = s.Labels
+			}
+			continue
+		}
+
+		streams := make([]KeyedStream, 0, len(rec.RefEntries))
+		for _, ref := range rec.RefEntries {
+			lbs, ok := series[ref.Ref]
+			if !ok {
+				// the series record was in an expired segment.
+				q.droppedBytes.WithLabelValues(spillDroppedExpired).Add(float64(len(b)))
+				streams = streams[:0]
+				break
+			}
+			streams = append(streams, KeyedStream{
+				HashKey: lokiring.TokenFor(rec.UserID, lbs.String()),
+				Stream:  logproto.Stream{Labels: lbs.String(), Hash: lbs.Hash(), Entries: ref.Entries},
+			})
+		}
+		series = nil
+		if len(streams) == 0 {
+			continue
+		}
+
+		if err := q.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		if err := q.send(ctx, rec.UserID, streams); err != nil {
+			if spillable(err) || ctx.Err() != nil {
+				// the segments before the one being read only contain pushes already sent.
+				if truncateErr := q.wal.Truncate(reader.Segment()); truncateErr != nil {
+					return truncateErr
+				}
+				return err
+			}
+			// the push would be rejected again at every replay.
+			q.droppedBytes.WithLabelValues(spillDroppedRejected).Add(float64(len(b)))
+			level.Warn(q.logger).Log("msg", "dropping push of the spill queue rejected by the ingesters", "tenant", rec.UserID, "err", err)
+		} else {
+			q.replayedRequests.Inc()
+		}
+		if err := q.writeOffset(current); err != nil {
+			return err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		var corruptionErr *wlog.CorruptionErr
+		if !errors.As(err, &corruptionErr) {
+			return err
+		}
+		level.Warn(q.logger).Log("msg", "dropping the corrupted end of the spill queue", "segment", corruptionErr.Segment, "err", err)
+	}
+	if err := q.wal.Truncate(last + 1); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(q.cfg.Dir, spillQueueOffsetFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// replayOffset is the number of records of a segment already replayed.
+type replayOffset struct {
+	Segment int
+	Records int
+}
+
+// readOffset returns the offset of the last replayed record, or an offset matching no segment if there is none.
+func (q *spillQueue) readOffset() replayOffset {
+	offset := replayOffset{Segment: -1}
+	b, err := os.ReadFile(filepath.Join(q.cfg.Dir, spillQueueOffsetFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Warn(q.logger).Log("msg", "failed to read the replay offset of the spill queue, replaying it from the start", "err", err)
+		}
+		return offset
+	}
+	if _, err := fmt.Sscanf(string(b), "%d %d", &offset.Segment, &offset.Records); err != nil {
+		level.Warn(q.logger).Log("msg", "invalid replay offset of the spill queue, replaying it from the start", "err", err)
+		return replayOffset{Segment: -1}
+	}
+	return offset
+}
+
+// writeOffset replaces the offset of the last replayed record. The file is renamed in place so that it is never partially written.
+func (q *spillQueue) writeOffset(offset replayOffset) error {
+	path := filepath.Join(q.cfg.Dir, spillQueueOffsetFile)
+	if err := os.WriteFile(path+".tmp", []byte(fmt.Sprintf("%d %d", offset.Segment, offset.Records)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// closeSegment starts a new segment if the current one has data, and returns the range of the closed segments.
+func (q *spillQueue) closeSegment() (int, int, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	current, offset, err := q.wal.LastSegmentAndOffset()
+	if err != nil {
+		return 0, 0, err
+	}
+	if offset > 0 {
+		if current, err = q.wal.NextSegmentSync(); err != nil {
+			return 0, 0, err
+		}
+	}
+	first, _, err := wlog.Segments(q.cfg.Dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	return first, current - 1, nil
+}
+
+func (q *spillQueue) updateSize() {
+	if size, err := q.wal.Size(); err == nil {
+		q.size.Set(float64(size))
+	}
+}
+
+func (q *spillQueue) String() string {
+	return fmt.Sprintf("spill queue in %s", q.cfg.Dir)
+}
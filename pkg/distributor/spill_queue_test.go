@@ -0,0 +1,204 @@
+package distributor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/tsdb/wlog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	lokiring "github.com/grafana/loki/v3/pkg/util/ring"
+)
+
+type spilledPush struct {
+	tenantID string
+	streams  []KeyedStream
+}
+
+func newTestSpillQueue(t *testing.T, cfg SpillQueueConfig, send sendFunc) *spillQueue {
+	t.Helper()
+	cfg.Enabled = true
+	cfg.Dir = t.TempDir()
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = 1 << 20
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = time.Hour
+	}
+	cfg.ReplayInterval = time.Second
+	cfg.ReplayRate = 1000
+	require.NoError(t, cfg.Validate())
+
+	q, err := newSpillQueue(cfg, send, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = q.stopping(nil) })
+	return q
+}
+
+func testSpillStreams(tenantID string, line string) []KeyedStream {
+	labels := `{app="shop", env="prod"}`
+	return []KeyedStream{{
+		HashKey: lokiring.TokenFor(tenantID, labels),
+		Stream: logproto.Stream{
+			Labels: labels,
+			Entries: []logproto.Entry{{
+				Timestamp:          time.Unix(0, 1).UTC(),
+				Line:               line,
+				StructuredMetadata: logproto.FromLabelsToLabelAdapters(nil),
+			}},
+		},
+	}}
+}
+
+func TestSpillQueue_Replay(t *testing.T) {
+	var (
+		sent []spilledPush
+		fail bool
+	)
+	q := newTestSpillQueue(t, SpillQueueConfig{}, func(_ context.Context, tenantID string, streams []KeyedStream) error {
+		if fail {
+			return errors.New("no ingester available")
+		}
+		sent = append(sent, spilledPush{tenantID: tenantID, streams: streams})
+		return nil
+	})
+
+	require.NoError(t, q.spill("tenant-a", testSpillStreams("tenant-a", "first")))
+	require.NoError(t, q.spill("tenant-b", testSpillStreams("tenant-b", "second")))
+	require.Equal(t, 2.0, testutil.ToFloat64(q.spilledRequests))
+
+	// the pushes are kept while the ingesters are unavailable.
+	fail = true
+	require.Error(t, q.replay(context.Background()))
+	require.Empty(t, sent)
+	require.NoError(t, q.spill("tenant-a", testSpillStreams("tenant-a", "third")))
+
+	fail = false
+	require.NoError(t, q.replay(context.Background()))
+	require.Len(t, sent, 3)
+	for i, push := range []spilledPush{
+		{tenantID: "tenant-a", streams: testSpillStreams("tenant-a", "first")},
+		{tenantID: "tenant-b", streams: testSpillStreams("tenant-b", "second")},
+		{tenantID: "tenant-a", streams: testSpillStreams("tenant-a", "third")},
+	} {
+		require.Equal(t, push.tenantID, sent[i].tenantID)
+		require.Len(t, sent[i].streams, 1)
+		require.Equal(t, push.streams[0].HashKey, sent[i].streams[0].HashKey)
+		require.Equal(t, push.streams[0].Stream.Labels, sent[i].streams[0].Stream.Labels)
+		require.Equal(t, push.streams[0].Stream.Entries[0].Line, sent[i].streams[0].Stream.Entries[0].Line)
+		require.Equal(t, push.streams[0].Stream.Entries[0].Timestamp, sent[i].streams[0].Stream.Entries[0].Timestamp.UTC())
+	}
+	require.Equal(t, 3.0, testutil.ToFloat64(q.replayedRequests))
+
+	// the replayed pushes are removed from the queue.
+	require.NoError(t, q.replay(context.Background()))
+	require.Len(t, sent, 3)
+	first, last, err := wlog.Segments(q.cfg.Dir)
+	require.NoError(t, err)
+	require.Equal(t, first, last, "only the current segment is left")
+}
+
+func TestSpillQueue_MaxSize(t *testing.T) {
+	q := newTestSpillQueue(t, SpillQueueConfig{MaxSize: 1024}, func(context.Context, string, []KeyedStream) error {
+		return nil
+	})
+
+	require.NoError(t, q.spill("tenant", testSpillStreams("tenant", "small")))
+	line := make([]byte, 2048)
+	for i := range line {
+		line[i] = byte('a' + i%26)
+	}
+	require.EqualError(t, q.spill("tenant", testSpillStreams("tenant", string(line))), "the spill queue is full")
+	require.Greater(t, testutil.ToFloat64(q.droppedBytes.WithLabelValues(spillDroppedFull)), 2048.0)
+}
+
+func TestSpillQueue_MaxAge(t *testing.T) {
+	var sent int
+	q := newTestSpillQueue(t, SpillQueueConfig{MaxAge: time.Minute}, func(context.Context, string, []KeyedStream) error {
+		sent++
+		return nil
+	})
+
+	require.NoError(t, q.spill("tenant", testSpillStreams("tenant", "expired")))
+	first, last, err := q.closeSegment()
+	require.NoError(t, err)
+	require.Equal(t, first, last)
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(wlog.SegmentName(q.cfg.Dir, first), old, old))
+
+	require.NoError(t, q.spill("tenant", testSpillStreams("tenant", "recent")))
+	require.NoError(t, q.replay(context.Background()))
+	require.Equal(t, 1, sent, "the expired push is dropped")
+	require.Greater(t, testutil.ToFloat64(q.droppedBytes.WithLabelValues(spillDroppedExpired)), 0.0)
+}
+
+func TestSpillQueue_ReplayResumesFromOffset(t *testing.T) {
+	var (
+		sent   []string
+		failAt = 2
+	)
+	send := func(_ context.Context, _ string, streams []KeyedStream) error {
+		line := streams[0].Stream.Entries[0].Line
+		if len(sent) == failAt {
+			return errors.New("no ingester available")
+		}
+		sent = append(sent, line)
+		return nil
+	}
+	q := newTestSpillQueue(t, SpillQueueConfig{}, send)
+	for _, line := range []string{"first", "second", "third"} {
+		require.NoError(t, q.spill("tenant", testSpillStreams("tenant", line)))
+	}
+
+	require.Error(t, q.replay(context.Background()))
+	require.Equal(t, []string{"first", "second"}, sent)
+
+	// the offset survives a restart of the distributor.
+	require.NoError(t, q.stopping(nil))
+	q, err := newSpillQueue(q.cfg, send, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = q.stopping(nil) })
+
+	failAt = -1
+	require.NoError(t, q.replay(context.Background()))
+	require.Equal(t, []string{"first", "second", "third"}, sent, "the pushes already sent are not sent again")
+	_, err = os.Stat(filepath.Join(q.cfg.Dir, spillQueueOffsetFile))
+	require.True(t, os.IsNotExist(err), "the offset is removed once the queue is replayed")
+}
+
+func TestSpillQueue_DropsRejectedPushes(t *testing.T) {
+	var sent []string
+	q := newTestSpillQueue(t, SpillQueueConfig{}, func(_ context.Context, _ string, streams []KeyedStream) error {
+		line := streams[0].Stream.Entries[0].Line
+		if line == "rate limited" {
+			return httpgrpc.Errorf(http.StatusTooManyRequests, "rate limited")
+		}
+		sent = append(sent, line)
+		return nil
+	})
+	for _, line := range []string{"first", "rate limited", "third"} {
+		require.NoError(t, q.spill("tenant", testSpillStreams("tenant", line)))
+	}
+
+	require.NoError(t, q.replay(context.Background()))
+	require.Equal(t, []string{"first", "third"}, sent)
+	require.Greater(t, testutil.ToFloat64(q.droppedBytes.WithLabelValues(spillDroppedRejected)), 0.0)
+	require.Equal(t, 2.0, testutil.ToFloat64(q.replayedRequests))
+}
+
+func Test_spillable(t *testing.T) {
+	require.True(t, spillable(errors.New("connection refused")))
+	require.True(t, spillable(httpgrpc.Errorf(http.StatusInternalServerError, "too many unhealthy instances in the ring")))
+	require.False(t, spillable(httpgrpc.Errorf(http.StatusTooManyRequests, "rate limited")))
+	require.False(t, spillable(context.Canceled))
+}
@@ -0,0 +1,194 @@
+package syslog
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	FormatRFC5424 = "rfc5424"
+	FormatRFC3164 = "rfc3164"
+
+	// Fields of the syslog messages that can be mapped to labels and structured metadata.
+	// The parameters of the RFC5424 structured data are referenced as sd.<SD-ID>.<PARAM-NAME>.
+	FieldHostname = "hostname"
+	FieldAppName  = "app_name"
+	FieldProcID   = "proc_id"
+	FieldMsgID    = "msg_id"
+	FieldFacility = "facility"
+	FieldSeverity = "severity"
+	FieldRemoteIP = "remote_ip"
+
+	structuredDataPrefix = "sd."
+	// tenantParam is the parameter of the structured data element selecting the tenant of a message.
+	tenantParam = "tenant"
+)
+
+var (
+	defaultLabelMapping = map[string]string{
+		FieldHostname: "hostname",
+		FieldAppName:  "app_name",
+		FieldFacility: "facility",
+	}
+	defaultStructuredMetadataMapping = map[string]string{
+		FieldSeverity: "severity",
+		FieldProcID:   "proc_id",
+		FieldMsgID:    "msg_id",
+	}
+)
+
+// Config configures the syslog receiver of the distributor.
+type Config struct {
+	TCPListenAddress string `yaml:"tcp_listen_address"`
+	UDPListenAddress string `yaml:"udp_listen_address"`
+	TLSCertPath      string `yaml:"tls_cert_path"`
+	TLSKeyPath       string `yaml:"tls_key_path"`
+	TLSClientCAPath  string `yaml:"tls_client_ca_path"`
+
+	Format               string        `yaml:"format"`
+	MaxMessageLength     int           `yaml:"max_message_length"`
+	IdleTimeout          time.Duration `yaml:"idle_timeout"`
+	UseIncomingTimestamp bool          `yaml:"use_incoming_timestamp"`
+
+	Tenant                 string                 `yaml:"tenant"`
+	TenantStructuredDataID string                 `yaml:"tenant_structured_data_id"`
+	TCPAllowedTenants      flagext.StringSliceCSV `yaml:"tcp_allowed_tenants"`
+	UDPAllowedTenants      flagext.StringSliceCSV `yaml:"udp_allowed_tenants"`
+
+	LabelMapping              map[string]string `yaml:"label_mapping" doc:"description=Mapping of the fields of the syslog messages to the names of the stream labels they are stored in. The fields are hostname, app_name, proc_id, msg_id, facility, severity, remote_ip and the parameters of the RFC5424 structured data referenced as sd.<SD-ID>.<PARAM-NAME>. Defaults to the hostname, app_name and facility fields stored in labels of the same name."`
+	StructuredMetadataMapping map[string]string `yaml:"structured_metadata_mapping" doc:"description=Mapping of the fields of the syslog messages to the names of the structured metadata they are stored in. Defaults to the severity, proc_id and msg_id fields stored in structured metadata of the same name."`
+
+	BatchSize     int            `yaml:"batch_size"`
+	BatchWait     time.Duration  `yaml:"batch_wait"`
+	BackoffConfig backoff.Config `yaml:"backoff_config" doc:"description=Backoff of the retries of the pushes failing with a retryable error, like the rate limited pushes. The syslog messages of the pushes still failing after the last retry are discarded."`
+}
+
+// RegisterFlagsWithPrefix registers the flags of the syslog receiver.
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.TCPListenAddress, prefix+".tcp-listen-address", "", "Address the distributor listens on for syslog messages over TCP, like :1514. The messages are framed with octet counting or newlines. Disabled when empty.")
+	f.StringVar(&cfg.UDPListenAddress, prefix+".udp-listen-address", "", "Address the distributor listens on for syslog messages over UDP, with one message per datagram. Disabled when empty.")
+	f.StringVar(&cfg.TLSCertPath, prefix+".tls-cert-path", "", "Path to the certificate of the TCP listener. TLS is enabled when set.")
+	f.StringVar(&cfg.TLSKeyPath, prefix+".tls-key-path", "", "Path to the key of the certificate of the TCP listener.")
+	f.StringVar(&cfg.TLSClientCAPath, prefix+".tls-client-ca-path", "", "Path to the CA certificate verifying the client certificates. Client certificates are required when set.")
+	f.StringVar(&cfg.Format, prefix+".format", FormatRFC5424, "Format of the syslog messages, either rfc5424 or rfc3164.")
+	f.IntVar(&cfg.MaxMessageLength, prefix+".max-message-length", 8192, "Maximum length of the syslog messages in bytes.")
+	f.DurationVar(&cfg.IdleTimeout, prefix+".idle-timeout", 120*time.Second, "Duration after which idle TCP connections are closed.")
+	f.BoolVar(&cfg.UseIncomingTimestamp, prefix+".use-incoming-timestamp", false, "Use the timestamp of the syslog messages instead of their time of receipt.")
+	f.StringVar(&cfg.Tenant, prefix+".tenant", "fake", "Tenant of the syslog messages. The default is the tenant used when authentication is disabled.")
+	f.StringVar(&cfg.TenantStructuredDataID, prefix+".tenant-structured-data-id", "", "SD-ID of the RFC5424 structured data element whose tenant parameter selects the tenant of a message, like loki@53595. The messages without it use the tenant of the listener. A message can only select the tenants allowed for its listener, other messages are rejected. WARNING: the syslog listeners don't authenticate the tenants, so any client able to reach a listener can write to the tenants allowed for it. Only allow tenants on listeners reachable by trusted clients, like the TCP listener requiring TLS client certificates.")
+	f.Var(&cfg.TCPAllowedTenants, prefix+".tcp-allowed-tenants", "Comma-separated list of the tenants the messages received over TCP can select with the tenant structured data parameter.")
+	f.Var(&cfg.UDPAllowedTenants, prefix+".udp-allowed-tenants", "Comma-separated list of the tenants the messages received over UDP can select with the tenant structured data parameter. The source of UDP datagrams can be spoofed.")
+	f.IntVar(&cfg.BatchSize, prefix+".batch-size", 1000, "Maximum number of syslog messages pushed at once.")
+	f.DurationVar(&cfg.BatchWait, prefix+".batch-wait", time.Second, "Maximum duration syslog messages wait before being pushed.")
+	cfg.BackoffConfig.RegisterFlagsWithPrefix(prefix, f)
+}
+
+// Enabled tells if any listener is configured.
+func (cfg *Config) Enabled() bool {
+	return cfg.TCPListenAddress != "" || cfg.UDPListenAddress != ""
+}
+
+func (cfg *Config) tlsEnabled() bool {
+	return cfg.TLSCertPath != "" || cfg.TLSKeyPath != "" || cfg.TLSClientCAPath != ""
+}
+
+// tenantAllowed tells if the messages received with the protocol can select the tenant.
+func (cfg *Config) tenantAllowed(protocol, tenantID string) bool {
+	if tenantID == cfg.Tenant {
+		return true
+	}
+	allowed := cfg.TCPAllowedTenants
+	if protocol == protocolUDP {
+		allowed = cfg.UDPAllowedTenants
+	}
+	for _, t := range allowed {
+		if t == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg *Config) labelMapping() map[string]string {
+	if cfg.LabelMapping == nil {
+		return defaultLabelMapping
+	}
+	return cfg.LabelMapping
+}
+
+func (cfg *Config) structuredMetadataMapping() map[string]string {
+	if cfg.StructuredMetadataMapping == nil {
+		return defaultStructuredMetadataMapping
+	}
+	return cfg.StructuredMetadataMapping
+}
+
+// Validate validates the config of the syslog receiver.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled() {
+		return nil
+	}
+	if cfg.Format != FormatRFC5424 && cfg.Format != FormatRFC3164 {
+		return fmt.Errorf("unsupported syslog format %q, it must be one of: %s, %s", cfg.Format, FormatRFC5424, FormatRFC3164)
+	}
+	if cfg.tlsEnabled() && (cfg.TLSCertPath == "" || cfg.TLSKeyPath == "") {
+		return errors.New("the certificate and key of the syslog TLS listener must be set")
+	}
+	if err := tenant.ValidTenantID(cfg.Tenant); err != nil {
+		return fmt.Errorf("invalid syslog tenant: %w", err)
+	}
+	for _, tenants := range []flagext.StringSliceCSV{cfg.TCPAllowedTenants, cfg.UDPAllowedTenants} {
+		for _, t := range tenants {
+			if err := tenant.ValidTenantID(t); err != nil {
+				return fmt.Errorf("invalid allowed syslog tenant: %w", err)
+			}
+		}
+	}
+	if cfg.MaxMessageLength <= 0 {
+		return errors.New("the maximum length of the syslog messages must be positive")
+	}
+	if cfg.BatchSize <= 0 || cfg.BatchWait <= 0 {
+		return errors.New("the syslog batch size and wait must be positive")
+	}
+	for _, mapping := range []map[string]string{cfg.labelMapping(), cfg.structuredMetadataMapping()} {
+		for field, name := range mapping {
+			if !isField(field) {
+				return fmt.Errorf("unknown syslog field %q", field)
+			}
+			if !model.LabelName(name).IsValid() {
+				return fmt.Errorf("invalid name %q of syslog field %q", name, field)
+			}
+		}
+	}
+	return nil
+}
+
+func isField(field string) bool {
+	switch field {
+	case FieldHostname, FieldAppName, FieldProcID, FieldMsgID, FieldFacility, FieldSeverity, FieldRemoteIP:
+		return true
+	}
+	_, _, ok := structuredDataField(field)
+	return ok
+}
+
+// structuredDataField returns the SD-ID and parameter name of a structured data field.
+func structuredDataField(field string) (string, string, bool) {
+	if !strings.HasPrefix(field, structuredDataPrefix) {
+		return "", "", false
+	}
+	field = strings.TrimPrefix(field, structuredDataPrefix)
+	i := strings.LastIndexByte(field, '.')
+	if i <= 0 || i == len(field)-1 {
+		return "", "", false
+	}
+	return field[:i], field[i+1:], true
+}
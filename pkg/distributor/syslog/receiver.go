@@ -0,0 +1,546 @@
+// Package syslog implements the syslog receiver of the distributor, so that
+// network devices can push their logs without an agent.
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	"github.com/leodido/go-syslog/v4"
+	"github.com/leodido/go-syslog/v4/nontransparent"
+	"github.com/leodido/go-syslog/v4/octetcounting"
+	"github.com/leodido/go-syslog/v4/rfc3164"
+	"github.com/leodido/go-syslog/v4/rfc5424"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+)
+
+const (
+	protocolTCP = "tcp"
+	protocolUDP = "udp"
+
+	pushTimeout = 10 * time.Second
+)
+
+// PushFunc pushes the streams of the tenant of the context.
+type PushFunc func(ctx context.Context, req *logproto.PushRequest) error
+
+type entry struct {
+	tenant string
+	labels string
+	logproto.Entry
+}
+
+// Receiver listens for syslog messages and pushes them in batches.
+type Receiver struct {
+	services.Service
+
+	cfg       Config
+	push      PushFunc
+	logger    log.Logger
+	tlsConfig *tls.Config
+
+	tcpListener net.Listener
+	udpConn     net.PacketConn
+
+	// connsMtx guards conns, the open TCP connections, closed on shutdown.
+	connsMtx    sync.Mutex
+	conns       map[net.Conn]struct{}
+	connsClosed bool
+	// wg waits for the listeners and the connections.
+	wg sync.WaitGroup
+
+	entries     chan entry
+	batcherDone chan struct{}
+
+	receivedEntries *prometheus.CounterVec
+	parsingErrors   *prometheus.CounterVec
+	rejectedEntries *prometheus.CounterVec
+	pushFailures    *prometheus.CounterVec
+}
+
+// NewReceiver creates a syslog receiver pushing the messages with push.
+func NewReceiver(cfg Config, push PushFunc, logger log.Logger, registerer prometheus.Registerer) (*Receiver, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	r := &Receiver{
+		cfg:         cfg,
+		push:        push,
+		logger:      log.With(logger, "component", "syslog-receiver"),
+		conns:       map[net.Conn]struct{}{},
+		entries:     make(chan entry, cfg.BatchSize),
+		batcherDone: make(chan struct{}),
+		receivedEntries: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_syslog_entries_received_total",
+			Help:      "The total number of syslog messages received.",
+		}, []string{"protocol"}),
+		parsingErrors: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_syslog_parsing_errors_total",
+			Help:      "The total number of syslog messages that failed to parse.",
+		}, []string{"protocol"}),
+		rejectedEntries: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_syslog_entries_rejected_total",
+			Help:      "The total number of syslog messages rejected because they selected a tenant not allowed for their listener.",
+		}, []string{"protocol"}),
+		pushFailures: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "distributor_syslog_push_failures_total",
+			Help:      "The total number of syslog messages that failed to be pushed, by tenant.",
+		}, []string{"tenant"}),
+	}
+	if cfg.tlsEnabled() {
+		tlsConfig, err := newTLSConfig(cfg.TLSCertPath, cfg.TLSKeyPath, cfg.TLSClientCAPath)
+		if err != nil {
+			return nil, err
+		}
+		r.tlsConfig = tlsConfig
+	}
+	r.Service = services.NewBasicService(r.starting, r.running, r.stopping)
+	return r, nil
+}
+
+func newTLSConfig(certPath, keyPath, clientCAPath string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the syslog TLS certificate or key: %w", err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCAPath != "" {
+		ca, err := os.ReadFile(clientCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the syslog TLS client CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("unable to parse the syslog TLS client CA certificate")
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func (r *Receiver) starting(_ context.Context) error {
+	if r.cfg.TCPListenAddress != "" {
+		l, err := net.Listen(protocolTCP, r.cfg.TCPListenAddress)
+		if err != nil {
+			return fmt.Errorf("error listening for syslog messages: %w", err)
+		}
+		if r.tlsConfig != nil {
+			l = tls.NewListener(l, r.tlsConfig)
+		}
+		r.tcpListener = l
+		level.Info(r.logger).Log("msg", "listening for syslog messages", "address", l.Addr().String(), "protocol", protocolTCP, "tls", r.tlsConfig != nil)
+	}
+	if r.cfg.UDPListenAddress != "" {
+		conn, err := net.ListenPacket(protocolUDP, r.cfg.UDPListenAddress)
+		if err != nil {
+			if r.tcpListener != nil {
+				_ = r.tcpListener.Close()
+			}
+			return fmt.Errorf("error listening for syslog messages: %w", err)
+		}
+		r.udpConn = conn
+		level.Info(r.logger).Log("msg", "listening for syslog messages", "address", conn.LocalAddr().String(), "protocol", protocolUDP)
+	}
+
+	go r.batch()
+	if r.tcpListener != nil {
+		r.wg.Add(1)
+		go r.acceptConnections()
+	}
+	if r.udpConn != nil {
+		r.wg.Add(1)
+		go r.readPackets()
+	}
+	return nil
+}
+
+func (r *Receiver) running(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (r *Receiver) stopping(_ error) error {
+	if r.tcpListener != nil {
+		_ = r.tcpListener.Close()
+	}
+	if r.udpConn != nil {
+		_ = r.udpConn.Close()
+	}
+	r.connsMtx.Lock()
+	for c := range r.conns {
+		_ = c.Close()
+	}
+	r.connsClosed = true
+	r.connsMtx.Unlock()
+	r.wg.Wait()
+
+	// push the remaining messages.
+	close(r.entries)
+	<-r.batcherDone
+	return nil
+}
+
+// TCPAddr returns the address of the TCP listener, nil if it is disabled.
+func (r *Receiver) TCPAddr() net.Addr {
+	if r.tcpListener == nil {
+		return nil
+	}
+	return r.tcpListener.Addr()
+}
+
+// UDPAddr returns the address of the UDP listener, nil if it is disabled.
+func (r *Receiver) UDPAddr() net.Addr {
+	if r.udpConn == nil {
+		return nil
+	}
+	return r.udpConn.LocalAddr()
+}
+
+func (r *Receiver) acceptConnections() {
+	defer r.wg.Done()
+	for {
+		c, err := r.tcpListener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			level.Warn(r.logger).Log("msg", "failed to accept syslog connection", "err", err)
+			// avoid spinning on persistent errors like running out of file descriptors.
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+		r.connsMtx.Lock()
+		if r.connsClosed {
+			// accepted while shutting down.
+			r.connsMtx.Unlock()
+			_ = c.Close()
+			return
+		}
+		r.conns[c] = struct{}{}
+		r.wg.Add(1)
+		r.connsMtx.Unlock()
+		go r.handleConnection(c)
+	}
+}
+
+func (r *Receiver) handleConnection(c net.Conn) {
+	defer func() {
+		r.connsMtx.Lock()
+		delete(r.conns, c)
+		r.connsMtx.Unlock()
+		_ = c.Close()
+		r.wg.Done()
+	}()
+
+	remoteIP := ""
+	if addr, ok := c.RemoteAddr().(*net.TCPAddr); ok {
+		remoteIP = addr.IP.String()
+	}
+	err := r.parseStream(&idleTimeoutConn{Conn: c, idleTimeout: r.cfg.IdleTimeout}, func(res *syslog.Result) {
+		r.handleResult(protocolTCP, remoteIP, res)
+	})
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+		level.Warn(r.logger).Log("msg", "error reading syslog connection", "remote", c.RemoteAddr().String(), "err", err)
+	}
+}
+
+func (r *Receiver) readPackets() {
+	defer r.wg.Done()
+	buf := make([]byte, r.cfg.MaxMessageLength)
+	for {
+		n, addr, err := r.udpConn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			level.Warn(r.logger).Log("msg", "failed to read syslog packet", "err", err)
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+		remoteIP := ""
+		if addr, ok := addr.(*net.UDPAddr); ok {
+			remoteIP = addr.IP.String()
+		}
+		// each datagram holds a single message.
+		err = r.parseStream(bytes.NewReader(buf[:n]), func(res *syslog.Result) {
+			r.handleResult(protocolUDP, remoteIP, res)
+		})
+		if err != nil {
+			r.parsingErrors.WithLabelValues(protocolUDP).Inc()
+		}
+	}
+}
+
+// parseStream parses the syslog messages of rd. The framing is detected from
+// the first byte, octet counting starts with the length of the message.
+func (r *Receiver) parseStream(rd io.Reader, callback func(*syslog.Result)) error {
+	buf := bufio.NewReaderSize(rd, 1<<10)
+	b, err := buf.ReadByte()
+	if err != nil {
+		return err
+	}
+	_ = buf.UnreadByte()
+
+	opts := []syslog.ParserOption{
+		syslog.WithListener(callback),
+		syslog.WithMaxMessageLength(r.cfg.MaxMessageLength),
+		syslog.WithBestEffort(),
+	}
+	rfc3164Format := r.cfg.Format == FormatRFC3164
+	switch {
+	case b == '<' && rfc3164Format:
+		nontransparent.NewParserRFC3164(opts...).Parse(buf)
+	case b == '<':
+		nontransparent.NewParser(opts...).Parse(buf)
+	case b >= '0' && b <= '9' && rfc3164Format:
+		octetcounting.NewParserRFC3164(opts...).Parse(buf)
+	case b >= '0' && b <= '9':
+		octetcounting.NewParser(opts...).Parse(buf)
+	default:
+		return fmt.Errorf("invalid or unsupported framing, first byte: %q", b)
+	}
+	return nil
+}
+
+func (r *Receiver) handleResult(protocol, remoteIP string, res *syslog.Result) {
+	if res.Error != nil {
+		var ne net.Error
+		if errors.As(res.Error, &ne) && ne.Timeout() {
+			// idle connection.
+			return
+		}
+		if !errors.Is(res.Error, net.ErrClosed) {
+			r.parsingErrors.WithLabelValues(protocol).Inc()
+			level.Debug(r.logger).Log("msg", "error parsing syslog message", "protocol", protocol, "err", res.Error)
+		}
+		return
+	}
+	e, ok := r.toEntry(res.Message, remoteIP)
+	if !ok {
+		r.parsingErrors.WithLabelValues(protocol).Inc()
+		return
+	}
+	if !r.cfg.tenantAllowed(protocol, e.tenant) {
+		r.rejectedEntries.WithLabelValues(protocol).Inc()
+		level.Debug(r.logger).Log("msg", "rejected syslog message of a tenant not allowed for its listener", "protocol", protocol, "tenant", e.tenant, "remote_ip", remoteIP)
+		return
+	}
+	r.receivedEntries.WithLabelValues(protocol).Inc()
+	r.entries <- e
+}
+
+// toEntry converts a syslog message to the entry of a stream.
+func (r *Receiver) toEntry(msg syslog.Message, remoteIP string) (entry, bool) {
+	var (
+		base           *syslog.Base
+		structuredData map[string]map[string]string
+	)
+	switch m := msg.(type) {
+	case *rfc5424.SyslogMessage:
+		base = &m.Base
+		if m.StructuredData != nil {
+			structuredData = *m.StructuredData
+		}
+	case *rfc3164.SyslogMessage:
+		base = &m.Base
+	default:
+		return entry{}, false
+	}
+	if base.Message == nil {
+		return entry{}, false
+	}
+
+	field := func(name string) string {
+		switch name {
+		case FieldHostname:
+			return deref(base.Hostname)
+		case FieldAppName:
+			return deref(base.Appname)
+		case FieldProcID:
+			return deref(base.ProcID)
+		case FieldMsgID:
+			return deref(base.MsgID)
+		case FieldFacility:
+			return deref(base.FacilityLevel())
+		case FieldSeverity:
+			return deref(base.SeverityLevel())
+		case FieldRemoteIP:
+			return remoteIP
+		}
+		if id, param, ok := structuredDataField(name); ok {
+			return structuredData[id][param]
+		}
+		return ""
+	}
+
+	e := entry{tenant: r.cfg.Tenant, Entry: logproto.Entry{Timestamp: time.Now(), Line: *base.Message}}
+	if r.cfg.UseIncomingTimestamp && base.Timestamp != nil {
+		e.Timestamp = *base.Timestamp
+	}
+	if r.cfg.TenantStructuredDataID != "" {
+		if t := structuredData[r.cfg.TenantStructuredDataID][tenantParam]; t != "" && tenant.ValidTenantID(t) == nil {
+			e.tenant = t
+		}
+	}
+
+	lbls := make(model.LabelSet, len(r.cfg.labelMapping()))
+	for f, name := range r.cfg.labelMapping() {
+		if v := field(f); v != "" {
+			lbls[model.LabelName(name)] = model.LabelValue(v)
+		}
+	}
+	e.labels = lbls.String()
+	for f, name := range r.cfg.structuredMetadataMapping() {
+		if v := field(f); v != "" {
+			e.StructuredMetadata = append(e.StructuredMetadata, logproto.LabelAdapter{Name: name, Value: v})
+		}
+	}
+	// keep the structured metadata in a stable order.
+	sort.Slice(e.StructuredMetadata, func(i, j int) bool { return e.StructuredMetadata[i].Name < e.StructuredMetadata[j].Name })
+	return e, true
+}
+
+// batch pushes the entries when a batch is full or has waited long enough.
+func (r *Receiver) batch() {
+	defer close(r.batcherDone)
+
+	ticker := time.NewTicker(r.cfg.BatchWait)
+	defer ticker.Stop()
+
+	b := newBatch()
+	for {
+		select {
+		case e, ok := <-r.entries:
+			if !ok {
+				r.flush(b)
+				return
+			}
+			b.add(e)
+			if b.size >= r.cfg.BatchSize {
+				r.flush(b)
+				b = newBatch()
+			}
+		case <-ticker.C:
+			if b.size > 0 {
+				r.flush(b)
+				b = newBatch()
+			}
+		}
+	}
+}
+
+func (r *Receiver) flush(b *batch) {
+	for tenantID, streams := range b.streams {
+		req := &logproto.PushRequest{Streams: make([]logproto.Stream, 0, len(streams))}
+		entries := 0
+		for _, s := range streams {
+			req.Streams = append(req.Streams, *s)
+			entries += len(s.Entries)
+		}
+		if err := r.pushWithRetries(tenantID, req); err != nil {
+			r.pushFailures.WithLabelValues(tenantID).Add(float64(entries))
+			level.Warn(r.logger).Log("msg", "failed to push syslog messages", "tenant", tenantID, "entries", entries, "err", err)
+		}
+	}
+}
+
+// pushWithRetries pushes the streams of the tenant, and retries the pushes failing with a retryable error.
+// The batching waits for the retries, so the syslog messages are read more slowly while Loki can't accept them.
+func (r *Receiver) pushWithRetries(tenantID string, req *logproto.PushRequest) error {
+	ctx := user.InjectOrgID(context.Background(), tenantID)
+	retries := backoff.New(ctx, r.cfg.BackoffConfig)
+	var err error
+	for retries.Ongoing() {
+		pushCtx, cancel := context.WithTimeout(ctx, pushTimeout)
+		err = r.push(pushCtx, req)
+		cancel()
+		if err == nil || !retryable(err) {
+			return err
+		}
+		level.Debug(r.logger).Log("msg", "retrying the push of syslog messages", "tenant", tenantID, "retries", retries.NumRetries(), "err", err)
+		retries.Wait()
+	}
+	return err
+}
+
+// retryable tells if a push failed because of Loki, like a rate limited push, rather than because of the pushed messages.
+func retryable(err error) bool {
+	if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return resp.Code == http.StatusTooManyRequests || resp.Code/100 == 5
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+type batch struct {
+	// streams holds the streams of each tenant by labels.
+	streams map[string]map[string]*logproto.Stream
+	size    int
+}
+
+func newBatch() *batch {
+	return &batch{streams: map[string]map[string]*logproto.Stream{}}
+}
+
+func (b *batch) add(e entry) {
+	streams, ok := b.streams[e.tenant]
+	if !ok {
+		streams = map[string]*logproto.Stream{}
+		b.streams[e.tenant] = streams
+	}
+	s, ok := streams[e.labels]
+	if !ok {
+		s = &logproto.Stream{Labels: e.labels}
+		streams[e.labels] = s
+	}
+	s.Entries = append(s.Entries, e.Entry)
+	b.size++
+}
+
+// idleTimeoutConn closes the connections that haven't received anything for the idle timeout.
+type idleTimeoutConn struct {
+	net.Conn
+	idleTimeout time.Duration
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+	return c.Conn.Read(b)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
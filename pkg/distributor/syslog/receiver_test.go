@@ -0,0 +1,273 @@
+package syslog
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+type pushRecorder struct {
+	mtx     sync.Mutex
+	entries map[string]map[string][]logproto.Entry // entries by tenant and labels
+}
+
+func (p *pushRecorder) push(ctx context.Context, req *logproto.PushRequest) error {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return err
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.entries[tenantID] == nil {
+		p.entries[tenantID] = map[string][]logproto.Entry{}
+	}
+	for _, s := range req.Streams {
+		p.entries[tenantID][s.Labels] = append(p.entries[tenantID][s.Labels], s.Entries...)
+	}
+	return nil
+}
+
+func (p *pushRecorder) count() int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	n := 0
+	for _, streams := range p.entries {
+		for _, entries := range streams {
+			n += len(entries)
+		}
+	}
+	return n
+}
+
+func defaultConfig() Config {
+	var cfg Config
+	cfg.RegisterFlagsWithPrefix("syslog", flag.NewFlagSet("", flag.PanicOnError))
+	cfg.TCPListenAddress = "127.0.0.1:0"
+	cfg.UDPListenAddress = "127.0.0.1:0"
+	cfg.BatchWait = 10 * time.Millisecond
+	return cfg
+}
+
+func startReceiver(t *testing.T, cfg Config) (*Receiver, *pushRecorder) {
+	t.Helper()
+	recorder := &pushRecorder{entries: map[string]map[string][]logproto.Entry{}}
+	r, err := NewReceiver(cfg, recorder.push, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), r)
+	})
+	return r, recorder
+}
+
+func TestReceiver_RFC5424(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.UseIncomingTimestamp = true
+	cfg.TenantStructuredDataID = "loki@53595"
+	cfg.TCPAllowedTenants = []string{"network"}
+	cfg.LabelMapping = map[string]string{FieldHostname: "host", "sd.origin.software": "software"}
+	r, recorder := startReceiver(t, cfg)
+
+	messages := []string{
+		`<165>1 2024-05-01T10:00:00Z router1 sshd 42 ID47 [origin software="ios"] login failed`,
+		`<165>1 2024-05-01T10:00:01Z router1 sshd 42 ID47 [loki@53595 tenant="network"] login succeeded`,
+	}
+	conn, err := net.Dial("tcp", r.TCPAddr().String())
+	require.NoError(t, err)
+	// octet counting framing.
+	for _, m := range messages {
+		_, err = fmt.Fprintf(conn, "%d %s", len(m), m)
+		require.NoError(t, err)
+	}
+	require.NoError(t, conn.Close())
+
+	udpConn, err := net.Dial("udp", r.UDPAddr().String())
+	require.NoError(t, err)
+	_, err = udpConn.Write([]byte(`<13>1 2024-05-01T10:00:02Z switch2 - - - - port up`))
+	require.NoError(t, err)
+	// the tenant is only allowed for the TCP listener.
+	_, err = udpConn.Write([]byte(`<13>1 2024-05-01T10:00:03Z switch2 - - - [loki@53595 tenant="network"] port down`))
+	require.NoError(t, err)
+	require.NoError(t, udpConn.Close())
+
+	require.Eventually(t, func() bool { return recorder.count() == 3 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(r.rejectedEntries.WithLabelValues(protocolUDP)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+	require.Equal(t, map[string]map[string][]logproto.Entry{
+		"fake": {
+			`{host="router1", software="ios"}`: {{
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+				Line:      "login failed",
+				StructuredMetadata: []logproto.LabelAdapter{
+					{Name: "msg_id", Value: "ID47"},
+					{Name: "proc_id", Value: "42"},
+					{Name: "severity", Value: "notice"},
+				},
+			}},
+			`{host="switch2"}`: {{
+				Timestamp:          time.Date(2024, 5, 1, 10, 0, 2, 0, time.UTC),
+				Line:               "port up",
+				StructuredMetadata: []logproto.LabelAdapter{{Name: "severity", Value: "notice"}},
+			}},
+		},
+		"network": {
+			`{host="router1"}`: {{
+				Timestamp: time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC),
+				Line:      "login succeeded",
+				StructuredMetadata: []logproto.LabelAdapter{
+					{Name: "msg_id", Value: "ID47"},
+					{Name: "proc_id", Value: "42"},
+					{Name: "severity", Value: "notice"},
+				},
+			}},
+		},
+	}, recorder.entries)
+}
+
+func TestReceiver_RFC3164(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Format = FormatRFC3164
+	cfg.UDPListenAddress = ""
+	cfg.StructuredMetadataMapping = map[string]string{}
+	r, recorder := startReceiver(t, cfg)
+
+	conn, err := net.Dial("tcp", r.TCPAddr().String())
+	require.NoError(t, err)
+	// non-transparent framing.
+	_, err = fmt.Fprint(conn, "<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8\n<13>Oct 11 22:14:16 mymachine cron: job done\n")
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	require.Eventually(t, func() bool { return recorder.count() == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+	require.Len(t, recorder.entries["fake"], 2)
+	entries := recorder.entries["fake"][`{app_name="su", facility="auth", hostname="mymachine"}`]
+	require.Len(t, entries, 1)
+	require.Equal(t, "'su root' failed for lonvick on /dev/pts/8", entries[0].Line)
+	require.Empty(t, entries[0].StructuredMetadata)
+	require.WithinDuration(t, time.Now(), entries[0].Timestamp, time.Minute, "the time of receipt is used by default")
+}
+
+func TestReceiver_FlushesOnStop(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.BatchWait = time.Hour
+	r, recorder := startReceiver(t, cfg)
+
+	conn, err := net.Dial("tcp", r.TCPAddr().String())
+	require.NoError(t, err)
+	// with non-transparent framing, a message is only parsed once the next one starts.
+	_, err = fmt.Fprint(conn, "<13>1 - host app - - - hello\n<13>1 - host app - - - world\n")
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(r.receivedEntries.WithLabelValues(protocolTCP)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 0, recorder.count())
+
+	// the open connection doesn't block the shutdown, and its last message is parsed when it is closed.
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), r))
+	require.Equal(t, 2, recorder.count())
+}
+
+func TestReceiver_RetriesPushes(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.BackoffConfig.MinBackoff = time.Millisecond
+	cfg.BackoffConfig.MaxBackoff = 10 * time.Millisecond
+	cfg.BackoffConfig.MaxRetries = 3
+
+	var (
+		mtx      sync.Mutex
+		attempts = map[string]int{}
+		pushed   = map[string]int{}
+	)
+	push := func(_ context.Context, req *logproto.PushRequest) error {
+		mtx.Lock()
+		defer mtx.Unlock()
+		line := req.Streams[0].Entries[0].Line
+		attempts[line]++
+		switch {
+		case line == "rate limited" && attempts[line] <= 2:
+			return httpgrpc.Errorf(http.StatusTooManyRequests, "ingestion rate limit exceeded")
+		case line == "unavailable":
+			return httpgrpc.Errorf(http.StatusServiceUnavailable, "no ingester available")
+		case line == "invalid":
+			return httpgrpc.Errorf(http.StatusBadRequest, "entry too far behind")
+		}
+		pushed[line]++
+		return nil
+	}
+	r, err := NewReceiver(cfg, push, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
+	defer func() { _ = services.StopAndAwaitTerminated(context.Background(), r) }()
+
+	conn, err := net.Dial("udp", r.UDPAddr().String())
+	require.NoError(t, err)
+	for _, line := range []string{"rate limited", "unavailable", "invalid"} {
+		_, err = fmt.Fprintf(conn, "<13>1 - host app - - - %s", line)
+		require.NoError(t, err)
+		// one message per batch.
+		time.Sleep(5 * cfg.BatchWait)
+	}
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(r.pushFailures.WithLabelValues("fake")) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	mtx.Lock()
+	defer mtx.Unlock()
+	// the rate limited push succeeded once retried.
+	require.Equal(t, 3, attempts["rate limited"])
+	require.Equal(t, 1, pushed["rate limited"])
+	// the unavailable push is discarded after the last retry, the invalid one isn't retried.
+	require.Equal(t, 3, attempts["unavailable"])
+	require.Equal(t, 1, attempts["invalid"])
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(*Config)
+		err    string
+	}{
+		{name: "valid", modify: func(*Config) {}},
+		{name: "disabled", modify: func(c *Config) { c.TCPListenAddress, c.UDPListenAddress, c.Format = "", "", "json" }},
+		{name: "unknown format", modify: func(c *Config) { c.Format = "json" }, err: `unsupported syslog format "json", it must be one of: rfc5424, rfc3164`},
+		{name: "tls without key", modify: func(c *Config) { c.TLSCertPath = "cert.pem" }, err: "the certificate and key of the syslog TLS listener must be set"},
+		{name: "invalid tenant", modify: func(c *Config) { c.Tenant = "a/b" }, err: `invalid syslog tenant: tenant ID 'a/b' contains unsupported character '/'`},
+		{name: "invalid allowed tenant", modify: func(c *Config) { c.UDPAllowedTenants = []string{"a/b"} }, err: `invalid allowed syslog tenant: tenant ID 'a/b' contains unsupported character '/'`},
+		{name: "unknown field", modify: func(c *Config) { c.LabelMapping = map[string]string{"host": "host"} }, err: `unknown syslog field "host"`},
+		{name: "invalid structured data field", modify: func(c *Config) { c.LabelMapping = map[string]string{"sd.origin": "origin"} }, err: `unknown syslog field "sd.origin"`},
+		{name: "invalid name", modify: func(c *Config) { c.StructuredMetadataMapping = map[string]string{FieldAppName: "app.name"} }, err: `invalid name "app.name" of syslog field "app_name"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			tc.modify(&cfg)
+			err := cfg.Validate()
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -43,12 +43,15 @@ type validationContext struct {
 	maxLabelValueLength    int
 
 	incrementDuplicateTimestamps bool
+	deduplicationWindow          time.Duration
+	deduplicationMaxKeys         int
 	discoverServiceName          []string
 	discoverLogLevels            bool
 
 	allowStructuredMetadata    bool
 	maxStructuredMetadataSize  int
 	maxStructuredMetadataCount int
+	structuredMetadataSchema   validation.StructuredMetadataSchema
 
 	blockIngestionUntil      time.Time
 	blockIngestionStatusCode int
@@ -68,11 +71,14 @@ func (v Validator) getValidationContextForTime(now time.Time, userID string) val
 		maxLabelNameLength:           v.MaxLabelNameLength(userID),
 		maxLabelValueLength:          v.MaxLabelValueLength(userID),
 		incrementDuplicateTimestamps: v.IncrementDuplicateTimestamps(userID),
+		deduplicationWindow:          v.DeduplicationWindow(userID),
+		deduplicationMaxKeys:         v.DeduplicationMaxKeys(userID),
 		discoverServiceName:          v.DiscoverServiceName(userID),
 		discoverLogLevels:            v.DiscoverLogLevels(userID),
 		allowStructuredMetadata:      v.AllowStructuredMetadata(userID),
 		maxStructuredMetadataSize:    v.MaxStructuredMetadataSize(userID),
 		maxStructuredMetadataCount:   v.MaxStructuredMetadataCount(userID),
+		structuredMetadataSchema:     v.StructuredMetadataSchema(userID),
 		blockIngestionUntil:          v.BlockIngestionUntil(userID),
 		blockIngestionStatusCode:     v.BlockIngestionStatusCode(userID),
 	}
@@ -153,6 +159,17 @@ func (v Validator) ValidateEntry(ctx context.Context, vCtx validationContext, la
 		}
 	}
 
+	if vCtx.structuredMetadataSchema.Enabled() {
+		if reason, err := vCtx.structuredMetadataSchema.ValidateEntry(labels.String(), entry.StructuredMetadata); err != nil {
+			validation.DiscardedSamples.WithLabelValues(reason, vCtx.userID).Inc()
+			validation.DiscardedBytes.WithLabelValues(reason, vCtx.userID).Add(float64(len(entry.Line)))
+			if v.usageTracker != nil {
+				v.usageTracker.DiscardedBytesAdd(ctx, vCtx.userID, reason, labels, float64(len(entry.Line)))
+			}
+			return err
+		}
+	}
+
 	return nil
 }
 
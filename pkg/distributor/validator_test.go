@@ -9,6 +9,7 @@ import (
 	"github.com/grafana/dskit/flagext"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/relabel"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/grafana/loki/pkg/push"
@@ -22,6 +23,14 @@ var (
 	testStreamLabels       = labels.Labels{{Name: "my", Value: "label"}}
 	testStreamLabelsString = testStreamLabels.String()
 	testTime               = time.Now()
+
+	testStructuredMetadataSchema = validation.StructuredMetadataSchema{
+		Keys: []validation.StructuredMetadataKey{
+			{Name: "trace_id", Regex: relabel.MustNewRegexp("[0-9a-f]+"), Required: true},
+			{Name: "user"},
+		},
+		MaxValueLength: 8,
+	}
 )
 
 type fakeLimits struct {
@@ -121,6 +130,78 @@ func TestValidator_ValidateEntry(t *testing.T) {
 			logproto.Entry{Timestamp: testTime, Line: "12345678901", StructuredMetadata: push.LabelsAdapter{{Name: "foo", Value: "bar"}, {Name: "too", Value: "many"}}},
 			fmt.Errorf(validation.StructuredMetadataTooManyErrorMsg, testStreamLabelsString, 2, 1),
 		},
+		{
+			"structured metadata key not in schema",
+			"test",
+			fakeLimits{
+				&validation.Limits{
+					AllowStructuredMetadata:  true,
+					StructuredMetadataSchema: testStructuredMetadataSchema,
+				},
+			},
+			logproto.Entry{Timestamp: testTime, Line: "test", StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "abc"}, {Name: "foo", Value: "bar"}}},
+			fmt.Errorf(validation.StructuredMetadataKeyNotAllowedErrorMsg, testStreamLabelsString, "foo"),
+		},
+		{
+			"structured metadata value not matching schema",
+			"test",
+			fakeLimits{
+				&validation.Limits{
+					AllowStructuredMetadata:  true,
+					StructuredMetadataSchema: testStructuredMetadataSchema,
+				},
+			},
+			logproto.Entry{Timestamp: testTime, Line: "test", StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "xyz"}}},
+			fmt.Errorf(validation.StructuredMetadataInvalidValueErrorMsg, testStreamLabelsString, "trace_id", "xyz", "[0-9a-f]+"),
+		},
+		{
+			"structured metadata missing required key",
+			"test",
+			fakeLimits{
+				&validation.Limits{
+					AllowStructuredMetadata:  true,
+					StructuredMetadataSchema: testStructuredMetadataSchema,
+				},
+			},
+			logproto.Entry{Timestamp: testTime, Line: "test", StructuredMetadata: push.LabelsAdapter{{Name: "user", Value: "foo"}}},
+			fmt.Errorf(validation.StructuredMetadataMissingKeyErrorMsg, testStreamLabelsString, "trace_id"),
+		},
+		{
+			"structured metadata value too long",
+			"test",
+			fakeLimits{
+				&validation.Limits{
+					AllowStructuredMetadata:  true,
+					StructuredMetadataSchema: testStructuredMetadataSchema,
+				},
+			},
+			logproto.Entry{Timestamp: testTime, Line: "test", StructuredMetadata: push.LabelsAdapter{{Name: "trace_id", Value: "abc"}, {Name: "user", Value: "0123456789"}}},
+			fmt.Errorf(validation.StructuredMetadataValueTooLongErrorMsg, testStreamLabelsString, "user", 10, 8),
+		},
+		{
+			"structured metadata value too long without schema keys",
+			"test",
+			fakeLimits{
+				&validation.Limits{
+					AllowStructuredMetadata:  true,
+					StructuredMetadataSchema: validation.StructuredMetadataSchema{MaxValueLength: 8},
+				},
+			},
+			logproto.Entry{Timestamp: testTime, Line: "test", StructuredMetadata: push.LabelsAdapter{{Name: "pod", Value: "0123456789"}}},
+			fmt.Errorf(validation.StructuredMetadataValueTooLongErrorMsg, testStreamLabelsString, "pod", 10, 8),
+		},
+		{
+			"structured metadata matching schema",
+			"test",
+			fakeLimits{
+				&validation.Limits{
+					AllowStructuredMetadata:  true,
+					StructuredMetadataSchema: testStructuredMetadataSchema,
+				},
+			},
+			logproto.Entry{Timestamp: testTime, Line: "test", StructuredMetadata: push.LabelsAdapter{{Name: "user", Value: "foo"}, {Name: "trace_id", Value: "abc"}}},
+			nil,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
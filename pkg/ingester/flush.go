@@ -169,7 +169,11 @@ func (i *Ingester) sweepUsers(immediate, mayRemoveStreams bool) {
 }
 
 func (i *Ingester) sweepInstance(instance *instance, immediate, mayRemoveStreams bool) {
+	now := time.Now()
 	_ = instance.streams.ForEach(func(s *stream) (bool, error) {
+		if !immediate && i.cfg.HeadCompressionAge > 0 {
+			s.compressHead(now, i.cfg.HeadCompressionAge)
+		}
 		i.sweepStream(instance, s, immediate)
 		i.removeFlushedChunks(instance, s, mayRemoveStreams)
 		return true, nil
@@ -80,6 +80,11 @@ var (
 	activeTenantsStats = analytics.NewInt("ingester_active_tenants")
 )
 
+// ZstdDictionaries returns the ID of the last zstd dictionary of a tenant, or 0 if it has none.
+type ZstdDictionaries interface {
+	Latest(tenant string) uint32
+}
+
 // Config for an ingester.
 type Config struct {
 	LifecyclerConfig ring.LifecyclerConfig `yaml:"lifecycler,omitempty" doc:"description=Configures how the lifecycle of the ingester will operate and where it will register for discovery."`
@@ -94,6 +99,8 @@ type Config struct {
 	TargetChunkSize     int               `yaml:"chunk_target_size"`
 	ChunkEncoding       string            `yaml:"chunk_encoding"`
 	parsedEncoding      chunkenc.Encoding `yaml:"-"` // placeholder for validated encoding
+	ChunkBlockBlooms    bool              `yaml:"chunk_block_blooms" category:"experimental"`
+	HeadCompressionAge  time.Duration     `yaml:"head_block_compression_age" category:"experimental"`
 	MaxChunkAge         time.Duration     `yaml:"max_chunk_age"`
 	AutoForgetUnhealthy bool              `yaml:"autoforget_unhealthy"`
 
@@ -115,6 +122,9 @@ type Config struct {
 	PipelineWrapper        lokilog.PipelineWrapper        `yaml:"-"`
 	SampleExtractorWrapper lokilog.SampleExtractorWrapper `yaml:"-"`
 
+	// Optional source of the zstd dictionaries the chunks of the zstd-dict encoding are compressed with.
+	ZstdDictionaries ZstdDictionaries `yaml:"-"`
+
 	// Optional wrapper that can be used to modify the behaviour of the ingester
 	Wrapper Wrapper `yaml:"-"`
 
@@ -143,6 +153,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.BlockSize, "ingester.chunks-block-size", 256*1024, "The targeted _uncompressed_ size in bytes of a chunk block When this threshold is exceeded the head block will be cut and compressed inside the chunk.")
 	f.IntVar(&cfg.TargetChunkSize, "ingester.chunk-target-size", 1572864, "A target _compressed_ size in bytes for chunks. This is a desired size not an exact size, chunks may be slightly bigger or significantly smaller if they get flushed for other reasons (e.g. chunk_idle_period). A value of 0 creates chunks with a fixed 10 blocks, a non zero value will create chunks with a variable number of blocks to meet the target size.") // 1.5 MB
 	f.StringVar(&cfg.ChunkEncoding, "ingester.chunk-encoding", chunkenc.EncGZIP.String(), fmt.Sprintf("The algorithm to use for compressing chunk. (%s)", chunkenc.SupportedEncoding()))
+	f.DurationVar(&cfg.HeadCompressionAge, "ingester.head-block-compression-age", 0, "Experimental: How long the log lines appended to the head block of a chunk stay uncompressed in memory before being re-encoded into a compressed form, which is still queried, until the head block is cut. This reduces the memory used by the streams whose head blocks take long to fill up. 0 disables the compression of the head blocks.")
+	f.BoolVar(&cfg.ChunkBlockBlooms, "ingester.chunk-block-blooms", false, "Experimental: Store the bloom of the n-grams of the lines of each chunk block, which lets the queries skip the blocks which can't match their line filters. The blooms count toward the chunk target size, and are capped to a quarter of the compressed size of their block. Only supported by the chunks of the schema v14 and later.")
 	f.DurationVar(&cfg.SyncPeriod, "ingester.sync-period", 1*time.Hour, "Parameters used to synchronize ingesters to cut chunks at the same moment. Sync period is used to roll over incoming entry to a new chunk. If chunk's utilization isn't high enough (eg. less than 50% when sync_min_utilization is set to 0.5), then this chunk rollover doesn't happen.")
 	f.Float64Var(&cfg.SyncMinUtilization, "ingester.sync-min-utilization", 0.1, "Minimum utilization of chunk when doing synchronization.")
 	f.IntVar(&cfg.MaxReturnedErrors, "ingester.max-ignored-stream-errors", 10, "The maximum number of errors a stream will report to the user when a push fails. 0 to make unlimited.")
@@ -178,6 +190,9 @@ func (cfg *Config) Validate() error {
 	if cfg.IndexShards <= 0 {
 		return fmt.Errorf("invalid ingester index shard factor: %d", cfg.IndexShards)
 	}
+	if cfg.HeadCompressionAge < 0 {
+		return fmt.Errorf("invalid head block compression age: %s", cfg.HeadCompressionAge)
+	}
 
 	return nil
 }
@@ -1420,6 +1435,11 @@ func (i *Ingester) tail(req *logproto.TailRequest, queryServer logproto.Querier_
 		return fmt.Errorf("unsupported query expression: want (LogSelectorExpr), got (%T)", req.Plan.AST)
 	}
 
+	expr, err = syntax.BindLookupTables(expr, instance.lookupTable)
+	if err != nil {
+		return err
+	}
+
 	tailer, err := newTailer(instanceID, expr, queryServer, i.cfg.MaxDroppedStreams)
 	if err != nil {
 		return err
@@ -310,6 +310,7 @@ func (i *instance) createStream(ctx context.Context, pushReqStream logproto.Stre
 	}
 
 	s := newStream(chunkfmt, headfmt, i.cfg, i.limiter, i.instanceID, fp, sortedLabels, i.limiter.UnorderedWrites(i.instanceID), i.streamRateCalculator, i.metrics, i.writeFailures, i.configs)
+	s.windowLimiter = i.limiter
 
 	// record will be nil when replaying the wal (we don't want to rewrite wal entries as we replay them).
 	if record != nil {
@@ -375,6 +376,7 @@ func (i *instance) createStreamByFP(ls labels.Labels, fp model.Fingerprint) (*st
 	}
 
 	s := newStream(chunkfmt, headfmt, i.cfg, i.limiter, i.instanceID, fp, sortedLabels, i.limiter.UnorderedWrites(i.instanceID), i.streamRateCalculator, i.metrics, i.writeFailures, i.configs)
+	s.windowLimiter = i.limiter
 
 	i.onStreamCreated(s)
 
@@ -451,6 +453,11 @@ func (i *instance) query(ctx context.Context, req logql.SelectLogParams) (iter.E
 		return nil, err
 	}
 
+	expr, err = syntax.BindLookupTables(expr, i.lookupTable)
+	if err != nil {
+		return nil, err
+	}
+
 	pipeline, err := expr.Pipeline()
 	if err != nil {
 		return nil, err
@@ -499,6 +506,11 @@ func (i *instance) query(ctx context.Context, req logql.SelectLogParams) (iter.E
 	return iter.NewSortEntryIterator(iters, req.Direction), nil
 }
 
+// lookupTable returns the lookup table with the given name for the tenant.
+func (i *instance) lookupTable(name string) (*log.LookupTable, bool) {
+	return i.limiter.limits.LookupTable(i.instanceID, name)
+}
+
 func (i *instance) QuerySample(ctx context.Context, req logql.SelectSampleParams) (iter.SampleIterator, error) {
 	it, err := i.querySample(ctx, req)
 	err = server_util.ClientGrpcStatusAndError(err)
@@ -511,6 +523,11 @@ func (i *instance) querySample(ctx context.Context, req logql.SelectSampleParams
 		return nil, err
 	}
 
+	expr, err = syntax.BindLookupTables(expr, i.lookupTable)
+	if err != nil {
+		return nil, err
+	}
+
 	extractor, err := expr.Extractor()
 	if err != nil {
 		return nil, err
@@ -1042,6 +1042,42 @@ func Test_QuerySampleWithDelete(t *testing.T) {
 	require.Equal(t, samples, []float64{1.})
 }
 
+func Test_QuerySampleWithLookup(t *testing.T) {
+	instance := defaultInstance(t)
+
+	limits := defaultLimitsTestConfig()
+	limits.LookupTables = []validation.LookupTable{{Name: "ownership", Format: "csv", Data: "log_stream,team\nworker,platform\n"}}
+	require.NoError(t, limits.Validate())
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+	instance.limiter = NewLimiter(overrides, NilMetrics, &ringCountMock{count: 1}, 1)
+
+	query := `sum by (team) (count_over_time({job="3"} | lookup ownership on log_stream [5m]))`
+	it, err := instance.QuerySample(context.TODO(),
+		logql.SelectSampleParams{
+			SampleQueryRequest: &logproto.SampleQueryRequest{
+				Selector: query,
+				Start:    time.Unix(0, 0),
+				End:      time.Unix(0, 110000000),
+				Plan: &plan.QueryPlan{
+					AST: syntax.MustParseExpr(query),
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+	defer it.Close()
+
+	teams := map[string]int{}
+	for it.Next() {
+		teams[it.Labels()]++
+	}
+	require.Equal(t, map[string]int{
+		`{}`:                5,
+		`{team="platform"}`: 5,
+	}, teams)
+}
+
 type fakeLimits struct {
 	limits map[string]*validation.Limits
 }
@@ -6,9 +6,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/prometheus/prometheus/model/labels"
 	"golang.org/x/time/rate"
 
 	"github.com/grafana/loki/v3/pkg/distributor/shardstreams"
+	"github.com/grafana/loki/v3/pkg/logql/log"
 	"github.com/grafana/loki/v3/pkg/validation"
 )
 
@@ -26,11 +28,13 @@ type RingCount interface {
 
 type Limits interface {
 	UnorderedWrites(userID string) bool
+	OutOfOrderWindows(userID string) []validation.OutOfOrderWindow
 	UseOwnedStreamCount(userID string) bool
 	MaxLocalStreamsPerUser(userID string) int
 	MaxGlobalStreamsPerUser(userID string) int
 	PerStreamRateLimit(userID string) validation.RateLimit
 	ShardStreams(userID string) shardstreams.Config
+	LookupTable(userID, name string) (*log.LookupTable, bool)
 }
 
 // Limiter implements primitives to get the maximum number of streams
@@ -79,6 +83,26 @@ func (l *Limiter) UnorderedWrites(userID string) bool {
 	return l.limits.UnorderedWrites(userID)
 }
 
+// OutOfOrderWindow returns the out-of-order window of the first rule of the tenant matching the stream,
+// and false if none matches.
+func (l *Limiter) OutOfOrderWindow(userID string, lbs labels.Labels) (time.Duration, bool) {
+	for _, rule := range l.limits.OutOfOrderWindows(userID) {
+		if matchesStream(rule.Matchers, lbs) {
+			return time.Duration(rule.Window), true
+		}
+	}
+	return 0, false
+}
+
+func matchesStream(matchers []*labels.Matcher, lbs labels.Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbs.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (l *Limiter) GetStreamCountLimit(tenantID string) (calculatedLimit, localLimit, globalLimit, adjustedGlobalLimit int) {
 	// Start by setting the local limit either from override or default
 	localLimit = l.limits.MaxLocalStreamsPerUser(tenantID)
@@ -73,7 +73,13 @@ type stream struct {
 	// introduced to facilitate removing the ordering constraint.
 	entryCt int64
 
-	unorderedWrites      bool
+	unorderedWrites bool
+	// the out-of-order window of the rule matching the stream, if any, replaces the default validity window of unordered writes.
+	// It is read from windowLimiter at each push, so that the changes of the runtime overrides apply to the existing streams.
+	windowLimiter       *Limiter
+	outOfOrderWindow    time.Duration
+	hasOutOfOrderWindow bool
+
 	streamRateCalculator *StreamRateCalculator
 
 	writeFailures *writefailures.Manager
@@ -170,7 +176,12 @@ func (s *stream) setChunks(chunks []Chunk) (bytesAdded, entriesAdded int, err er
 }
 
 func (s *stream) NewChunk() *chunkenc.MemChunk {
-	return chunkenc.NewMemChunk(s.chunkFormat, s.cfg.parsedEncoding, s.chunkHeadBlockFormat, s.cfg.BlockSize, s.cfg.TargetChunkSize)
+	c := chunkenc.NewMemChunk(s.chunkFormat, s.cfg.parsedEncoding, s.chunkHeadBlockFormat, s.cfg.BlockSize, s.cfg.TargetChunkSize)
+	if s.cfg.parsedEncoding == chunkenc.EncZstdDict && s.cfg.ZstdDictionaries != nil {
+		c.UseZstdDictionary(s.cfg.ZstdDictionaries.Latest(s.tenant))
+	}
+	c.UseBlockBlooms(s.cfg.ChunkBlockBlooms)
+	return c
 }
 
 func (s *stream) Push(
@@ -395,6 +406,9 @@ func (s *stream) validateEntries(ctx context.Context, entries []logproto.Entry,
 		highestTs                            = s.highestTs
 		toStore                              = make([]logproto.Entry, 0, len(entries))
 	)
+	if s.unorderedWrites && !isReplay {
+		s.updateOutOfOrderWindow()
+	}
 
 	for i := range entries {
 		// If this entry matches our last appended line's timestamp and contents,
@@ -421,8 +435,9 @@ func (s *stream) validateEntries(ctx context.Context, entries []logproto.Entry,
 			continue
 		}
 
-		// The validity window for unordered writes is the highest timestamp present minus 1/2 * max-chunk-age.
-		cutoff := highestTs.Add(-s.cfg.MaxChunkAge / 2)
+		// The validity window for unordered writes is the highest timestamp present minus 1/2 * max-chunk-age,
+		// or minus the out-of-order window of the stream.
+		cutoff := highestTs.Add(-s.validityWindow())
 		if !isReplay && s.unorderedWrites && !highestTs.IsZero() && cutoff.After(entries[i].Timestamp) {
 			failedEntriesWithError = append(failedEntriesWithError, entryWithError{&entries[i], chunkenc.ErrTooFarBehind(entries[i].Timestamp, cutoff)})
 			s.writeFailures.Log(s.tenant, fmt.Errorf("%w for stream %s", failedEntriesWithError[len(failedEntriesWithError)-1].e, s.labels))
@@ -461,11 +476,54 @@ func (s *stream) validateEntries(ctx context.Context, entries []logproto.Entry,
 	return toStore, failedEntriesWithError
 }
 
+// compressHead compresses the entries of the head block of the last chunk appended for at least the given age,
+// so that the entries of the head blocks don't stay uncompressed much longer than that age.
+func (s *stream) compressHead(now time.Time, age time.Duration) {
+	s.chunkMtx.Lock()
+	defer s.chunkMtx.Unlock()
+	if len(s.chunks) == 0 {
+		return
+	}
+
+	chunk := &s.chunks[len(s.chunks)-1]
+	if chunk.closed {
+		return
+	}
+	compressed, err := chunk.chunk.CompressHead(now, age)
+	if err != nil {
+		s.metrics.headBlockCompressions.WithLabelValues("failure").Inc()
+		level.Warn(util_log.Logger).Log("msg", "failed to compress head block", "stream", s.labelsString, "err", err)
+		return
+	}
+	if compressed {
+		s.metrics.headBlockCompressions.WithLabelValues("success").Inc()
+	}
+}
+
+func (s *stream) updateOutOfOrderWindow() {
+	if s.windowLimiter == nil {
+		return
+	}
+	s.outOfOrderWindow, s.hasOutOfOrderWindow = s.windowLimiter.OutOfOrderWindow(s.tenant, s.labels)
+}
+
+// validityWindow returns how far behind its newest log line the log lines of the stream are accepted.
+// It is at most half of the max chunk age, since older log lines could belong to chunks which are already flushed.
+func (s *stream) validityWindow() time.Duration {
+	if s.hasOutOfOrderWindow {
+		return min(s.outOfOrderWindow, s.cfg.MaxChunkAge/2)
+	}
+	return s.cfg.MaxChunkAge / 2
+}
+
 func (s *stream) reportMetrics(ctx context.Context, outOfOrderSamples, outOfOrderBytes, rateLimitedSamples, rateLimitedBytes int, usageTracker push.UsageTracker) {
 	if outOfOrderSamples > 0 {
 		name := validation.OutOfOrder
 		if s.unorderedWrites {
 			name = validation.TooFarBehind
+			if s.hasOutOfOrderWindow {
+				name = validation.TooFarBehindStreamWindow
+			}
 		}
 		validation.DiscardedSamples.WithLabelValues(name, s.tenant).Add(float64(outOfOrderSamples))
 		validation.DiscardedBytes.WithLabelValues(name, s.tenant).Add(float64(outOfOrderBytes))
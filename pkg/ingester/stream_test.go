@@ -596,6 +596,177 @@ func TestReplayAppendIgnoresValidityWindow(t *testing.T) {
 
 }
 
+func TestPushOutOfOrderWindow(t *testing.T) {
+	limitsCfg := defaultLimitsTestConfig()
+	limitsCfg.OutOfOrderWindows = []validation.OutOfOrderWindow{
+		{Selector: `{job="audit"}`, Window: 0},
+		{Selector: `{source="batch"}`, Window: model.Duration(30 * time.Minute)},
+		{Selector: `{source="backfill"}`, Window: model.Duration(24 * time.Hour)},
+	}
+	require.NoError(t, limitsCfg.Validate())
+	limits, err := validation.NewOverrides(limitsCfg, nil)
+	require.NoError(t, err)
+	limiter := NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1)
+
+	cfg := defaultConfig()
+	cfg.MaxChunkAge = 2 * time.Hour
+	chunkfmt, headfmt := defaultChunkFormat(t)
+
+	for _, tc := range []struct {
+		name           string
+		labels         labels.Labels
+		behind         time.Duration
+		expectedReason string
+	}{
+		{name: "strict ordering", labels: labels.FromStrings("job", "audit"), behind: time.Second, expectedReason: validation.TooFarBehindStreamWindow},
+		{name: "within the stream window", labels: labels.FromStrings("job", "app", "source", "batch"), behind: 20 * time.Minute},
+		{name: "outside the stream window", labels: labels.FromStrings("job", "app", "source", "batch"), behind: 40 * time.Minute, expectedReason: validation.TooFarBehindStreamWindow},
+		{name: "stream window capped to half of the max chunk age", labels: labels.FromStrings("job", "app", "source", "backfill"), behind: 90 * time.Minute, expectedReason: validation.TooFarBehindStreamWindow},
+		{name: "within the default window", labels: labels.FromStrings("job", "app"), behind: 50 * time.Minute},
+		{name: "outside the default window", labels: labels.FromStrings("job", "app"), behind: 90 * time.Minute, expectedReason: validation.TooFarBehind},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// the discarded samples of each case are counted for a distinct tenant.
+			tenant := tc.name
+			s := newStream(chunkfmt, headfmt, cfg, limiter, tenant, model.Fingerprint(0), tc.labels, true, NewStreamRateCalculator(), NilMetrics, nil, nil)
+			s.windowLimiter = limiter
+
+			base := time.Now()
+			_, err := s.Push(context.Background(), []logproto.Entry{{Timestamp: base, Line: "1"}}, recordPool.GetRecord(), 0, true, false, nil)
+			require.NoError(t, err)
+
+			// an entry with the same timestamp as the newest one is always in order.
+			_, err = s.Push(context.Background(), []logproto.Entry{{Timestamp: base, Line: "2"}}, recordPool.GetRecord(), 0, true, false, nil)
+			require.NoError(t, err)
+
+			_, err = s.Push(context.Background(), []logproto.Entry{{Timestamp: base.Add(-tc.behind), Line: "3"}}, recordPool.GetRecord(), 0, true, false, nil)
+			if tc.expectedReason == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Equal(t, 1.0, testutil.ToFloat64(validation.DiscardedSamples.WithLabelValues(tc.expectedReason, tenant)))
+		})
+	}
+}
+
+func TestPushOutOfOrderWindow_RuntimeOverrides(t *testing.T) {
+	limitsCfg := defaultLimitsTestConfig()
+	require.NoError(t, limitsCfg.Validate())
+	tenantLimits := defaultLimitsTestConfig()
+	tenantLimits.OutOfOrderWindows = []validation.OutOfOrderWindow{{Selector: `{job="audit"}`, Window: 0}}
+	require.NoError(t, tenantLimits.Validate())
+	overrides := fakeLimits{limits: map[string]*validation.Limits{}}
+	limits, err := validation.NewOverrides(limitsCfg, overrides)
+	require.NoError(t, err)
+	limiter := NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1)
+
+	cfg := defaultConfig()
+	cfg.MaxChunkAge = time.Hour
+	chunkfmt, headfmt := defaultChunkFormat(t)
+	s := newStream(chunkfmt, headfmt, cfg, limiter, "fake", model.Fingerprint(0), labels.FromStrings("job", "audit"), true, NewStreamRateCalculator(), NilMetrics, nil, nil)
+	s.windowLimiter = limiter
+
+	base := time.Now()
+	_, err = s.Push(context.Background(), []logproto.Entry{{Timestamp: base, Line: "1"}}, recordPool.GetRecord(), 0, true, false, nil)
+	require.NoError(t, err)
+	_, err = s.Push(context.Background(), []logproto.Entry{{Timestamp: base.Add(-time.Second), Line: "2"}}, recordPool.GetRecord(), 0, true, false, nil)
+	require.NoError(t, err)
+
+	// the rules added by the runtime overrides apply to the existing streams.
+	overrides.limits["fake"] = &tenantLimits
+	_, err = s.Push(context.Background(), []logproto.Entry{{Timestamp: base.Add(-2 * time.Second), Line: "3"}}, recordPool.GetRecord(), 0, true, false, nil)
+	require.Error(t, err)
+}
+
+func TestStreamCompressHead(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+	limiter := NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1)
+	metrics := newIngesterMetrics(prometheus.NewRegistry(), "loki")
+
+	chunkfmt, headfmt := defaultChunkFormat(t)
+	cfg := defaultConfig()
+	// the head block isn't cut.
+	cfg.BlockSize = 1 << 20
+	s := newStream(chunkfmt, headfmt, cfg, limiter, "fake", model.Fingerprint(0), labels.FromStrings("foo", "bar"), true, NewStreamRateCalculator(), metrics, nil, nil)
+
+	push := func(from, through int) {
+		entries := make([]logproto.Entry, 0, through-from)
+		for i := from; i < through; i++ {
+			entries = append(entries, logproto.Entry{Timestamp: time.Unix(int64(i), 0), Line: fmt.Sprintf("line %d", i)})
+		}
+		_, err := s.Push(context.Background(), entries, recordPool.GetRecord(), 0, true, false, nil)
+		require.NoError(t, err)
+	}
+
+	now := time.Now()
+	push(0, 50)
+	s.compressHead(now, time.Hour)
+	require.Equal(t, 0.0, testutil.ToFloat64(metrics.headBlockCompressions.WithLabelValues("success")))
+
+	s.compressHead(now.Add(time.Hour), time.Hour)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.headBlockCompressions.WithLabelValues("success")))
+
+	// only the entries appended for the given age are compressed.
+	push(50, 100)
+	s.compressHead(now.Add(2*time.Hour), time.Hour)
+	push(100, 150)
+	s.compressHead(now.Add(2*time.Hour+time.Minute), time.Hour)
+	require.Equal(t, 1.0, testutil.ToFloat64(metrics.headBlockCompressions.WithLabelValues("success")))
+	s.compressHead(now.Add(3*time.Hour), time.Hour)
+	require.Equal(t, 2.0, testutil.ToFloat64(metrics.headBlockCompressions.WithLabelValues("success")))
+
+	// the compressed entries are still queried along the next ones.
+	it, err := s.Iterator(context.Background(), nil, time.Unix(0, 0), time.Unix(150, 0), logproto.FORWARD, log.NewNoopPipeline().ForStream(s.labels))
+	require.NoError(t, err)
+	testIteratorForward(t, it, 0, 150)
+	require.NoError(t, it.Close())
+}
+
+type fakeZstdDictionaries []string
+
+func (f *fakeZstdDictionaries) Latest(tenant string) uint32 {
+	*f = append(*f, tenant)
+	return 0
+}
+
+func TestStreamZstdDictionary(t *testing.T) {
+	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
+	require.NoError(t, err)
+	limiter := NewLimiter(limits, NilMetrics, &ringCountMock{count: 1}, 1)
+
+	dictionaries := &fakeZstdDictionaries{}
+	cfg := defaultConfig()
+	cfg.parsedEncoding = chunkenc.EncZstdDict
+	cfg.ZstdDictionaries = dictionaries
+	chunkfmt, headfmt := defaultChunkFormat(t)
+
+	s := newStream(
+		chunkfmt,
+		headfmt,
+		cfg,
+		limiter,
+		"fake",
+		model.Fingerprint(0),
+		labels.Labels{
+			{Name: "foo", Value: "bar"},
+		},
+		true,
+		NewStreamRateCalculator(),
+		NilMetrics,
+		nil,
+		nil,
+	)
+
+	_, err = s.Push(context.Background(), []logproto.Entry{
+		{Timestamp: time.Unix(1, 0), Line: "test"},
+	}, recordPool.GetRecord(), 0, true, false, nil)
+	require.NoError(t, err)
+	require.Len(t, s.chunks, 1)
+	require.Equal(t, fakeZstdDictionaries{"fake"}, *dictionaries, "the chunk uses the dictionary of the tenant of the stream")
+}
+
 func iterEq(t *testing.T, exp []logproto.Entry, got iter.EntryIterator) {
 	var i int
 	for got.Next() {
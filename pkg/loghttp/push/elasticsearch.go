@@ -0,0 +1,526 @@
+package push
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage/remote/otlptranslator/prometheus"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	loki_util "github.com/grafana/loki/v3/pkg/util"
+)
+
+const (
+	defaultElasticsearchIndexLabel     = "index"
+	defaultElasticsearchTimestampField = "@timestamp"
+
+	esActionIndex  = "index"
+	esActionCreate = "create"
+	esActionUpdate = "update"
+	esActionDelete = "delete"
+
+	// Types of the errors of the items of Elasticsearch bulk responses.
+	esMapperParsingException           = "mapper_parsing_exception"
+	esIllegalArgumentException         = "illegal_argument_exception"
+	esRejectedExecutionException       = "es_rejected_execution_exception"
+	esUnavailableShardsException       = "unavailable_shards_exception"
+	esActionRequestValidationException = "action_request_validation_exception"
+)
+
+// ElasticsearchConfig configures how the documents pushed to the Elasticsearch bulk endpoint are mapped to log entries.
+type ElasticsearchConfig struct {
+	IndexLabel               string   `yaml:"index_label,omitempty" json:"index_label,omitempty" doc:"description=Stream label set to the _index of the bulk actions. Defaults to index."`
+	TimestampField           string   `yaml:"timestamp_field,omitempty" json:"timestamp_field,omitempty" doc:"description=Document field holding the timestamp of the log entry, either as an RFC3339 date or as milliseconds since epoch. Defaults to @timestamp. The time of the push is used for the documents without it."`
+	MessageField             string   `yaml:"message_field,omitempty" json:"message_field,omitempty" doc:"description=Document field used as the log line. The whole document is used as the log line when empty or when the document does not have the field."`
+	LabelFields              []string `yaml:"label_fields,omitempty" json:"label_fields,omitempty" doc:"description=Document fields stored as stream labels. Nested fields are referenced with dots, like kubernetes.namespace. Label names are the field names with unsupported characters replaced by underscores."`
+	StructuredMetadataFields []string `yaml:"structured_metadata_fields,omitempty" json:"structured_metadata_fields,omitempty" doc:"description=Document fields stored as structured metadata of the log entries. Nested fields are referenced with dots, like trace.id."`
+}
+
+// Validate validates the Elasticsearch config.
+func (c *ElasticsearchConfig) Validate() error {
+	if c.IndexLabel != "" && !model.LabelName(c.IndexLabel).IsValid() {
+		return fmt.Errorf("invalid elasticsearch index label name %q", c.IndexLabel)
+	}
+	for _, f := range append(c.LabelFields, c.StructuredMetadataFields...) {
+		if f == "" {
+			return errors.New("elasticsearch document fields must not be empty")
+		}
+	}
+	return nil
+}
+
+func (c *ElasticsearchConfig) indexLabel() string {
+	if c.IndexLabel == "" {
+		return defaultElasticsearchIndexLabel
+	}
+	return c.IndexLabel
+}
+
+func (c *ElasticsearchConfig) timestampField() string {
+	if c.TimestampField == "" {
+		return defaultElasticsearchTimestampField
+	}
+	return c.TimestampField
+}
+
+// ElasticsearchError is the error of an item of an Elasticsearch bulk response.
+type ElasticsearchError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// ElasticsearchErrorResponse is the response to an Elasticsearch request that failed as a whole.
+type ElasticsearchErrorResponse struct {
+	Error  ElasticsearchError `json:"error"`
+	Status int                `json:"status"`
+}
+
+// ElasticsearchBulkItemResponse is the response to an action of an Elasticsearch bulk request.
+type ElasticsearchBulkItemResponse struct {
+	Index  string              `json:"_index"`
+	ID     string              `json:"_id,omitempty"`
+	Status int                 `json:"status"`
+	Result string              `json:"result,omitempty"`
+	Error  *ElasticsearchError `json:"error,omitempty"`
+}
+
+// ElasticsearchInfoResponse is the response to the root endpoint of Elasticsearch,
+// which the clients request to check the version and the product of the server.
+type ElasticsearchInfoResponse struct {
+	Name        string                   `json:"name"`
+	ClusterName string                   `json:"cluster_name"`
+	Version     ElasticsearchVersionInfo `json:"version"`
+	Tagline     string                   `json:"tagline"`
+}
+
+// ElasticsearchVersionInfo is the version of Elasticsearch whose API is implemented.
+type ElasticsearchVersionInfo struct {
+	Number                           string `json:"number"`
+	BuildFlavor                      string `json:"build_flavor"`
+	LuceneVersion                    string `json:"lucene_version"`
+	MinimumWireCompatibilityVersion  string `json:"minimum_wire_compatibility_version"`
+	MinimumIndexCompatibilityVersion string `json:"minimum_index_compatibility_version"`
+}
+
+// NewElasticsearchInfoResponse returns the info the Elasticsearch clients expect before sending bulk requests.
+// The clients check the version is supported and the product is Elasticsearch.
+func NewElasticsearchInfoResponse() ElasticsearchInfoResponse {
+	return ElasticsearchInfoResponse{
+		Name:        "loki",
+		ClusterName: "loki",
+		Version: ElasticsearchVersionInfo{
+			Number:                           "8.11.0",
+			BuildFlavor:                      "default",
+			LuceneVersion:                    "9.8.0",
+			MinimumWireCompatibilityVersion:  "7.17.0",
+			MinimumIndexCompatibilityVersion: "7.0.0",
+		},
+		Tagline: "You Know, for Search",
+	}
+}
+
+// ElasticsearchBulkResponse is the response to an Elasticsearch bulk request.
+type ElasticsearchBulkResponse struct {
+	Took   int64                                       `json:"took"`
+	Errors bool                                        `json:"errors"`
+	Items  []map[string]*ElasticsearchBulkItemResponse `json:"items"`
+}
+
+type elasticsearchBulkItem struct {
+	action   string
+	response ElasticsearchBulkItemResponse
+	// stream and entry are the indexes of the entry of the item in the push request, stream is -1 if the item was rejected when parsed.
+	stream, entry int
+}
+
+// ElasticsearchBulkRequest is a parsed Elasticsearch bulk request.
+// It keeps track of the push request entry of each action, so that the
+// validation failures of the entries are reported on their action.
+type ElasticsearchBulkRequest struct {
+	Request *logproto.PushRequest
+
+	items []elasticsearchBulkItem
+	// itemsByEntry holds the indexes of the items of each entry of each stream of the push request.
+	itemsByEntry [][]int
+}
+
+// Reject rejects the action of an entry of the push request. All the actions of the stream are rejected when entry is -1.
+func (b *ElasticsearchBulkRequest) Reject(stream, entry int, status int, err error) {
+	if stream < 0 || stream >= len(b.itemsByEntry) {
+		return
+	}
+	if entry < 0 {
+		for _, i := range b.itemsByEntry[stream] {
+			b.items[i].reject(status, elasticsearchErrorType(status), err.Error())
+		}
+		return
+	}
+	if entry < len(b.itemsByEntry[stream]) {
+		b.items[b.itemsByEntry[stream][entry]].reject(status, elasticsearchErrorType(status), err.Error())
+	}
+}
+
+// RejectAll rejects all the actions that were not already rejected, like when the whole push request fails.
+func (b *ElasticsearchBulkRequest) RejectAll(status int, err error) {
+	for i := range b.items {
+		if b.items[i].response.Error == nil {
+			b.items[i].reject(status, elasticsearchErrorType(status), err.Error())
+		}
+	}
+}
+
+// Response returns the Elasticsearch response of the bulk request.
+// The actions that were not rejected are reported as created.
+func (b *ElasticsearchBulkRequest) Response(took time.Duration) *ElasticsearchBulkResponse {
+	resp := &ElasticsearchBulkResponse{
+		Took:  took.Milliseconds(),
+		Items: make([]map[string]*ElasticsearchBulkItemResponse, 0, len(b.items)),
+	}
+	for i := range b.items {
+		item := &b.items[i]
+		if item.response.Error != nil {
+			resp.Errors = true
+		} else {
+			item.response.Status = http.StatusCreated
+			item.response.Result = "created"
+		}
+		resp.Items = append(resp.Items, map[string]*ElasticsearchBulkItemResponse{item.action: &item.response})
+	}
+	return resp
+}
+
+func (i *elasticsearchBulkItem) reject(status int, errType, reason string) {
+	i.response.Status = status
+	i.response.Result = ""
+	i.response.Error = &ElasticsearchError{Type: errType, Reason: reason}
+}
+
+func elasticsearchErrorType(status int) string {
+	switch {
+	case status == http.StatusTooManyRequests:
+		// clients retry the actions rejected with this error.
+		return esRejectedExecutionException
+	case status >= http.StatusInternalServerError:
+		return esUnavailableShardsException
+	default:
+		return esIllegalArgumentException
+	}
+}
+
+type elasticsearchAction struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+// ParseElasticsearchBulkRequest parses an Elasticsearch bulk request into a push request.
+func ParseElasticsearchBulkRequest(userID string, r *http.Request, tenantsRetention TenantsRetention, limits Limits, tracker UsageTracker) (*logproto.PushRequest, *Stats, error) {
+	bulk, stats, err := ParseElasticsearchBulk(userID, r, tenantsRetention, limits, tracker)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bulk.Request, stats, nil
+}
+
+// ParseElasticsearchBulk parses the NDJSON body of an Elasticsearch bulk request.
+// The documents of the index and create actions are mapped to log entries as configured
+// by the Elasticsearch config of the tenant. The actions that can't be mapped to a log
+// entry are rejected and only fail the whole request if the body is malformed.
+// The actions without _index belong to the index of the request path, if any.
+func ParseElasticsearchBulk(userID string, r *http.Request, tenantsRetention TenantsRetention, limits Limits, tracker UsageTracker) (*ElasticsearchBulkRequest, *Stats, error) {
+	stats := newPushStats()
+	stats.ContentType = r.Header.Get(contentType)
+	stats.ContentEncoding = r.Header.Get(contentEnc)
+
+	// bodySize should always reflect the compressed size of the request body
+	bodySize := loki_util.NewSizeReader(r.Body)
+	var body io.Reader = bodySize
+	switch stats.ContentEncoding {
+	case "":
+	case gzipContentEncoding:
+		gzipReader, err := gzip.NewReader(bodySize)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	default:
+		return nil, nil, fmt.Errorf("Content-Encoding %q not supported", stats.ContentEncoding)
+	}
+
+	p := &elasticsearchParser{
+		ctx:              r.Context(),
+		userID:           userID,
+		cfg:              limits.ElasticsearchConfig(userID),
+		tenantsRetention: tenantsRetention,
+		tracker:          tracker,
+		stats:            stats,
+		bulk:             &ElasticsearchBulkRequest{Request: &logproto.PushRequest{}},
+		defaultIndex:     mux.Vars(r)["index"],
+		streams:          map[string]int{},
+		now:              time.Now(),
+	}
+
+	reader := bufio.NewReader(body)
+	for {
+		line, err := readNDJSONLine(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := p.parseAction(line, reader); err != nil {
+			return nil, nil, err
+		}
+	}
+	stats.BodySize = bodySize.Size()
+
+	if len(p.bulk.items) == 0 {
+		return nil, nil, errors.New("the bulk request must not be empty")
+	}
+	return p.bulk, stats, nil
+}
+
+// readNDJSONLine returns the next non-empty line of an NDJSON body.
+func readNDJSONLine(r *bufio.Reader) ([]byte, error) {
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+type elasticsearchParser struct {
+	ctx              context.Context
+	userID           string
+	cfg              ElasticsearchConfig
+	tenantsRetention TenantsRetention
+	tracker          UsageTracker
+	stats            *Stats
+	bulk             *ElasticsearchBulkRequest
+	defaultIndex     string // index of the request path
+	// streams holds the index of each stream of the push request by its labels.
+	streams map[string]int
+	now     time.Time
+}
+
+func (p *elasticsearchParser) parseAction(line []byte, reader *bufio.Reader) error {
+	var actions map[string]elasticsearchAction
+	if err := json.Unmarshal(line, &actions); err != nil || len(actions) != 1 {
+		return fmt.Errorf("malformed action [%d], expected a single action like {\"index\":{}}", len(p.bulk.items)+1)
+	}
+
+	var item elasticsearchBulkItem
+	for name, action := range actions {
+		if action.Index == "" {
+			action.Index = p.defaultIndex
+		}
+		item = elasticsearchBulkItem{
+			action:   name,
+			response: ElasticsearchBulkItemResponse{Index: action.Index, ID: action.ID},
+			stream:   -1,
+		}
+	}
+
+	switch item.action {
+	case esActionIndex, esActionCreate:
+	case esActionDelete:
+		// delete actions have no document.
+		item.reject(http.StatusBadRequest, esActionRequestValidationException, "delete actions are not supported, log entries can only be indexed or created")
+		p.bulk.items = append(p.bulk.items, item)
+		return nil
+	case esActionUpdate:
+		if _, err := readNDJSONLine(reader); err != nil && err != io.EOF {
+			return err
+		}
+		item.reject(http.StatusBadRequest, esActionRequestValidationException, "update actions are not supported, log entries can only be indexed or created")
+		p.bulk.items = append(p.bulk.items, item)
+		return nil
+	default:
+		return fmt.Errorf("malformed action [%d], unsupported action %q", len(p.bulk.items)+1, item.action)
+	}
+
+	doc, err := readNDJSONLine(reader)
+	if err == io.EOF {
+		return fmt.Errorf("the %s action [%d] has no document", item.action, len(p.bulk.items)+1)
+	}
+	if err != nil {
+		return err
+	}
+	if err := p.parseDocument(&item, doc); err != nil {
+		item.reject(http.StatusBadRequest, esMapperParsingException, err.Error())
+	}
+	p.bulk.items = append(p.bulk.items, item)
+	return nil
+}
+
+func (p *elasticsearchParser) parseDocument(item *elasticsearchBulkItem, raw []byte) error {
+	var doc map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return fmt.Errorf("failed to parse the document: %w", err)
+	}
+
+	entry := push.Entry{Timestamp: p.now, Line: string(raw)}
+	if v, ok := lookupField(doc, p.cfg.timestampField()); ok && v != nil {
+		ts, err := parseElasticsearchTimestamp(v)
+		if err != nil {
+			return fmt.Errorf("failed to parse field [%s] of type [date]: %w", p.cfg.timestampField(), err)
+		}
+		entry.Timestamp = ts
+	}
+	if p.cfg.MessageField != "" {
+		if v, ok := lookupField(doc, p.cfg.MessageField); ok && v != nil {
+			entry.Line = fieldValue(v)
+		}
+	}
+
+	streamLabels := make(model.LabelSet, len(p.cfg.LabelFields)+1)
+	if item.response.Index != "" {
+		streamLabels[model.LabelName(p.cfg.indexLabel())] = model.LabelValue(item.response.Index)
+	}
+	for _, f := range p.cfg.LabelFields {
+		if v, ok := lookupField(doc, f); ok && v != nil {
+			for _, l := range fieldToLabels(f, v) {
+				streamLabels[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+			}
+		}
+	}
+	if err := streamLabels.Validate(); err != nil {
+		return fmt.Errorf("invalid labels: %w", err)
+	}
+	for _, f := range p.cfg.StructuredMetadataFields {
+		if v, ok := lookupField(doc, f); ok && v != nil {
+			entry.StructuredMetadata = append(entry.StructuredMetadata, fieldToLabels(f, v)...)
+		}
+	}
+
+	labelsStr := streamLabels.String()
+	lbs := modelLabelsSetToLabelsList(streamLabels)
+	stream, ok := p.streams[labelsStr]
+	if !ok {
+		stream = len(p.bulk.Request.Streams)
+		p.streams[labelsStr] = stream
+		p.bulk.Request.Streams = append(p.bulk.Request.Streams, push.Stream{Labels: labelsStr})
+		p.bulk.itemsByEntry = append(p.bulk.itemsByEntry, nil)
+		p.stats.StreamLabelsSize += int64(labelsSize(logproto.FromLabelsToLabelAdapters(lbs)))
+	}
+	item.stream = stream
+	item.entry = len(p.bulk.Request.Streams[stream].Entries)
+	p.bulk.Request.Streams[stream].Entries = append(p.bulk.Request.Streams[stream].Entries, entry)
+	p.bulk.itemsByEntry[stream] = append(p.bulk.itemsByEntry[stream], len(p.bulk.items))
+
+	p.trackEntry(lbs, entry)
+	return nil
+}
+
+func (p *elasticsearchParser) trackEntry(lbs labels.Labels, entry push.Entry) {
+	retentionPeriod := p.tenantsRetention.RetentionPeriodFor(p.userID, lbs)
+	metadataSize := int64(labelsSize(entry.StructuredMetadata))
+	p.stats.LogLinesBytes[retentionPeriod] += int64(len(entry.Line))
+	p.stats.StructuredMetadataBytes[retentionPeriod] += metadataSize
+	if p.tracker != nil {
+		p.tracker.ReceivedBytesAdd(p.ctx, p.userID, retentionPeriod, lbs, float64(len(entry.Line)))
+		p.tracker.ReceivedBytesAdd(p.ctx, p.userID, retentionPeriod, lbs, float64(metadataSize))
+	}
+	p.stats.NumLines++
+	if entry.Timestamp.After(p.stats.MostRecentEntryTimestamp) {
+		p.stats.MostRecentEntryTimestamp = entry.Timestamp
+	}
+}
+
+// lookupField returns the value of a document field. The fields of nested
+// objects are referenced with dots, and take precedence over dotted field names.
+func lookupField(doc map[string]any, field string) (any, bool) {
+	if head, rest, ok := strings.Cut(field, "."); ok {
+		if nested, ok := doc[head].(map[string]any); ok {
+			if v, ok := lookupField(nested, rest); ok {
+				return v, true
+			}
+		}
+	}
+	v, ok := doc[field]
+	return v, ok
+}
+
+// fieldToLabels converts a document field to labels. Objects are flattened
+// with the names of their fields appended to the name of the field.
+func fieldToLabels(name string, v any) push.LabelsAdapter {
+	name = prometheus.NormalizeLabel(name)
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return push.LabelsAdapter{{Name: name, Value: fieldValue(v)}}
+	}
+	lbls := make(push.LabelsAdapter, 0, len(obj))
+	for k, v := range obj {
+		if v != nil {
+			lbls = append(lbls, fieldToLabels(name+"_"+k, v)...)
+		}
+	}
+	sort.Slice(lbls, func(i, j int) bool { return lbls[i].Name < lbls[j].Name })
+	return lbls
+}
+
+func fieldValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+// parseElasticsearchTimestamp parses a timestamp of the default date format of
+// Elasticsearch, either an RFC3339 date or milliseconds since epoch.
+func parseElasticsearchTimestamp(v any) (time.Time, error) {
+	switch v := v.(type) {
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return ts, nil
+		}
+		if ts, err := time.Parse("2006-01-02T15:04:05.999999999Z0700", v); err == nil {
+			return ts, nil
+		}
+		if ms, err := json.Number(v).Int64(); err == nil {
+			return time.UnixMilli(ms), nil
+		}
+		return time.Time{}, fmt.Errorf("%q is not an RFC3339 date or milliseconds since epoch", v)
+	case json.Number:
+		if ms, err := v.Int64(); err == nil {
+			return time.UnixMilli(ms), nil
+		}
+		ms, err := v.Float64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(ms*float64(time.Millisecond))), nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported value %v", v)
+	}
+}
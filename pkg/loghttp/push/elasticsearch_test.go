@@ -0,0 +1,181 @@
+package push
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestParseElasticsearchBulk(t *testing.T) {
+	body := strings.Join([]string{
+		`{"index":{"_index":"app-logs","_id":"1"}}`,
+		`{"@timestamp":"2024-05-01T10:00:00.123Z","message":"GET /","kubernetes":{"namespace":"prod"},"trace":{"id":"abc"},"http":{"status":200}}`,
+		`{"create":{"_index":"app-logs"}}`,
+		`{"@timestamp":1714557600000,"message":"POST /","kubernetes":{"namespace":"prod"}}`,
+		``,
+		`{"delete":{"_index":"app-logs","_id":"1"}}`,
+		`{"update":{"_index":"app-logs","_id":"1"}}`,
+		`{"doc":{"message":"updated"}}`,
+		`{"index":{"_index":"audit"}}`,
+		`{"@timestamp":"yesterday","message":"login"}`,
+		`{"index":{}}`,
+		`{"level":"info"}`,
+	}, "\n")
+
+	limits := &fakeLimits{elasticsearchConfig: ElasticsearchConfig{
+		MessageField:             "message",
+		LabelFields:              []string{"kubernetes.namespace"},
+		StructuredMetadataFields: []string{"trace.id", "http"},
+	}}
+	request := httptestRequest(t, body, "")
+	bulk, stats, err := ParseElasticsearchBulk("fake", request, limits, limits, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []logproto.Stream{
+		{
+			Labels: `{index="app-logs", kubernetes_namespace="prod"}`,
+			Entries: []logproto.Entry{
+				{
+					Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 123000000, time.UTC),
+					Line:      "GET /",
+					StructuredMetadata: push.LabelsAdapter{
+						{Name: "trace_id", Value: "abc"},
+						{Name: "http_status", Value: "200"},
+					},
+				},
+				{Timestamp: time.UnixMilli(1714557600000), Line: "POST /"},
+			},
+		},
+		{
+			Labels:  `{}`,
+			Entries: []logproto.Entry{{Timestamp: bulk.Request.Streams[1].Entries[0].Timestamp, Line: `{"level":"info"}`}},
+		},
+	}, bulk.Request.Streams)
+	require.WithinDuration(t, time.Now(), bulk.Request.Streams[1].Entries[0].Timestamp, time.Minute, "documents without timestamp use the time of the push")
+	require.Equal(t, int64(3), stats.NumLines)
+
+	resp := bulk.Response(time.Second)
+	require.True(t, resp.Errors)
+	require.Equal(t, int64(1000), resp.Took)
+	require.Equal(t, []map[string]*ElasticsearchBulkItemResponse{
+		{"index": {Index: "app-logs", ID: "1", Status: http.StatusCreated, Result: "created"}},
+		{"create": {Index: "app-logs", Status: http.StatusCreated, Result: "created"}},
+		{"delete": {Index: "app-logs", ID: "1", Status: http.StatusBadRequest, Error: &ElasticsearchError{
+			Type:   esActionRequestValidationException,
+			Reason: "delete actions are not supported, log entries can only be indexed or created",
+		}}},
+		{"update": {Index: "app-logs", ID: "1", Status: http.StatusBadRequest, Error: &ElasticsearchError{
+			Type:   esActionRequestValidationException,
+			Reason: "update actions are not supported, log entries can only be indexed or created",
+		}}},
+		{"index": {Index: "audit", Status: http.StatusBadRequest, Error: &ElasticsearchError{
+			Type:   esMapperParsingException,
+			Reason: `failed to parse field [@timestamp] of type [date]: "yesterday" is not an RFC3339 date or milliseconds since epoch`,
+		}}},
+		{"index": {Status: http.StatusCreated, Result: "created"}},
+	}, resp.Items)
+}
+
+func TestElasticsearchBulkRequest_Reject(t *testing.T) {
+	body := strings.Join([]string{
+		`{"index":{"_index":"a"}}`,
+		`{"message":"1"}`,
+		`{"index":{"_index":"b"}}`,
+		`{"message":"2"}`,
+		`{"index":{"_index":"a"}}`,
+		`{"message":"3"}`,
+		`{"index":{"_index":"c"}}`,
+		`{"message":"4"}`,
+	}, "\n")
+	limits := &fakeLimits{}
+	bulk, _, err := ParseElasticsearchBulk("fake", httptestRequest(t, body, gzipContentEncoding), limits, limits, nil)
+	require.NoError(t, err)
+	require.Len(t, bulk.Request.Streams, 3)
+
+	bulk.Reject(0, 1, http.StatusBadRequest, errors.New("too old"))
+	bulk.Reject(1, -1, http.StatusBadRequest, errors.New("invalid labels"))
+	bulk.RejectAll(http.StatusTooManyRequests, errors.New("rate limited"))
+
+	statuses := make([]string, 0, 4)
+	for _, item := range bulk.Response(0).Items {
+		resp := item["index"]
+		if resp.Error == nil {
+			statuses = append(statuses, resp.Result)
+			continue
+		}
+		statuses = append(statuses, resp.Error.Type+": "+resp.Error.Reason)
+	}
+	require.Equal(t, []string{
+		"es_rejected_execution_exception: rate limited",
+		"illegal_argument_exception: invalid labels",
+		"illegal_argument_exception: too old",
+		"es_rejected_execution_exception: rate limited",
+	}, statuses)
+}
+
+func TestParseElasticsearchBulk_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		body string
+		err  string
+	}{
+		{name: "empty", body: "\n", err: "the bulk request must not be empty"},
+		{name: "malformed action", body: `{"index":`, err: `malformed action [1], expected a single action like {"index":{}}`},
+		{name: "unknown action", body: `{"upsert":{}}`, err: `malformed action [1], unsupported action "upsert"`},
+		{name: "missing document", body: "{\"index\":{}}\n{\"message\":\"1\"}\n{\"create\":{}}", err: "the create action [2] has no document"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := ParseElasticsearchBulk("fake", httptestRequest(t, tc.body, ""), &fakeLimits{}, &fakeLimits{}, nil)
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestElasticsearchConfig_Validate(t *testing.T) {
+	require.NoError(t, (&ElasticsearchConfig{IndexLabel: "es_index", LabelFields: []string{"host.name"}}).Validate())
+	require.EqualError(t, (&ElasticsearchConfig{IndexLabel: "es.index"}).Validate(), `invalid elasticsearch index label name "es.index"`)
+	require.EqualError(t, (&ElasticsearchConfig{StructuredMetadataFields: []string{""}}).Validate(), "elasticsearch document fields must not be empty")
+}
+
+func TestElasticsearchBulkResponse_JSON(t *testing.T) {
+	resp := &ElasticsearchBulkResponse{
+		Took: 3,
+		Items: []map[string]*ElasticsearchBulkItemResponse{
+			{"index": {Index: "logs", Status: http.StatusCreated, Result: "created"}},
+		},
+	}
+	b, err := json.Marshal(resp)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"took":3,"errors":false,"items":[{"index":{"_index":"logs","status":201,"result":"created"}}]}`, string(b))
+}
+
+func httptestRequest(t *testing.T, body string, contentEncoding string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	if contentEncoding == gzipContentEncoding {
+		gz := gzip.NewWriter(&buf)
+		_, err := gz.Write([]byte(body))
+		require.NoError(t, err)
+		require.NoError(t, gz.Close())
+	} else {
+		buf.WriteString(body)
+	}
+	request, err := http.NewRequest(http.MethodPost, "/loki/api/v1/es/_bulk", &buf)
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	if contentEncoding != "" {
+		request.Header.Set("Content-Encoding", contentEncoding)
+	}
+	return request
+}
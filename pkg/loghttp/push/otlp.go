@@ -52,6 +52,16 @@ func ParseOTLPRequest(userID string, r *http.Request, tenantsRetention TenantsRe
 }
 
 func extractLogs(r *http.Request, pushStats *Stats) (plog.Logs, error) {
+	req := plogotlp.NewExportRequest()
+	if err := unmarshalOTLPRequest(r, pushStats, req.UnmarshalProto, req.UnmarshalJSON); err != nil {
+		return plog.NewLogs(), err
+	}
+
+	return req.Logs(), nil
+}
+
+// unmarshalOTLPRequest reads the body of an OTLP HTTP request and unmarshals it using the function matching its content type.
+func unmarshalOTLPRequest(r *http.Request, pushStats *Stats, unmarshalProto, unmarshalJSON func([]byte) error) error {
 	pushStats.ContentEncoding = r.Header.Get(contentEnc)
 	// bodySize should always reflect the compressed size of the request body
 	bodySize := loki_util.NewSizeReader(r.Body)
@@ -59,7 +69,7 @@ func extractLogs(r *http.Request, pushStats *Stats) (plog.Logs, error) {
 	if pushStats.ContentEncoding == gzipContentEncoding {
 		r, err := gzip.NewReader(bodySize)
 		if err != nil {
-			return plog.NewLogs(), err
+			return err
 		}
 		body = r
 		defer func(reader *gzip.Reader) {
@@ -68,34 +78,23 @@ func extractLogs(r *http.Request, pushStats *Stats) (plog.Logs, error) {
 	}
 	buf, err := io.ReadAll(body)
 	if err != nil {
-		return plog.NewLogs(), err
+		return err
 	}
 
 	pushStats.BodySize = bodySize.Size()
 
-	req := plogotlp.NewExportRequest()
-
 	pushStats.ContentType = r.Header.Get(contentType)
 	switch pushStats.ContentType {
 	case pbContentType:
-		err := req.UnmarshalProto(buf)
-		if err != nil {
-			return plog.NewLogs(), err
-		}
+		return unmarshalProto(buf)
 	case applicationJSON:
-		err := req.UnmarshalJSON(buf)
-		if err != nil {
-			return plog.NewLogs(), err
-		}
+		return unmarshalJSON(buf)
 	default:
-		return plog.NewLogs(),
-			errors.Errorf(
-				"content type: %s is not supported",
-				r.Header.Get("Content-Type"),
-			)
+		return errors.Errorf(
+			"content type: %s is not supported",
+			r.Header.Get("Content-Type"),
+		)
 	}
-
-	return req.Logs(), nil
 }
 
 func otlpToLokiPushRequest(ctx context.Context, ld plog.Logs, userID string, tenantsRetention TenantsRetention, otlpConfig OTLPConfig, discoverServiceName []string, tracker UsageTracker, stats *Stats) *logproto.PushRequest {
@@ -111,45 +110,7 @@ func otlpToLokiPushRequest(ctx context.Context, ld plog.Logs, userID string, ten
 		res := rls.At(i).Resource()
 		resAttrs := res.Attributes()
 
-		resourceAttributesAsStructuredMetadata := make(push.LabelsAdapter, 0, resAttrs.Len())
-		streamLabels := make(model.LabelSet, 30) // we have a default labels limit of 30 so just initialize the map of same size
-
-		shouldDiscoverServiceName := len(discoverServiceName) > 0 && !stats.IsAggregatedMetric
-		hasServiceName := false
-		if v, ok := resAttrs.Get(attrServiceName); ok && v.AsString() != "" {
-			hasServiceName = true
-		}
-		resAttrs.Range(func(k string, v pcommon.Value) bool {
-			action := otlpConfig.ActionForResourceAttribute(k)
-			if action == Drop {
-				return true
-			}
-
-			attributeAsLabels := attributeToLabels(k, v, "")
-			if action == IndexLabel {
-				for _, lbl := range attributeAsLabels {
-					streamLabels[model.LabelName(lbl.Name)] = model.LabelValue(lbl.Value)
-
-					if !hasServiceName && shouldDiscoverServiceName {
-						for _, labelName := range discoverServiceName {
-							if lbl.Name == labelName {
-								streamLabels[model.LabelName(LabelServiceName)] = model.LabelValue(lbl.Value)
-								hasServiceName = true
-								break
-							}
-						}
-					}
-				}
-			} else if action == StructuredMetadata {
-				resourceAttributesAsStructuredMetadata = append(resourceAttributesAsStructuredMetadata, attributeAsLabels...)
-			}
-
-			return true
-		})
-
-		if !hasServiceName && shouldDiscoverServiceName {
-			streamLabels[model.LabelName(LabelServiceName)] = model.LabelValue(ServiceUnknown)
-		}
+		streamLabels, resourceAttributesAsStructuredMetadata := resourceAttributesToLabels(resAttrs, otlpConfig, discoverServiceName, stats)
 
 		if err := streamLabels.Validate(); err != nil {
 			stats.Errs = append(stats.Errs, fmt.Errorf("invalid labels: %w", err))
@@ -179,7 +140,6 @@ func otlpToLokiPushRequest(ctx context.Context, ld plog.Logs, userID string, ten
 		for j := 0; j < sls.Len(); j++ {
 			scope := sls.At(j).Scope()
 			logs := sls.At(j).LogRecords()
-			scopeAttrs := scope.Attributes()
 
 			// it would be rare to have multiple scopes so if the entries slice is empty, pre-allocate it for the number of log entries
 			if cap(pushRequestsByStream[labelsStr].Entries) == 0 {
@@ -188,40 +148,7 @@ func otlpToLokiPushRequest(ctx context.Context, ld plog.Logs, userID string, ten
 				pushRequestsByStream[labelsStr] = stream
 			}
 
-			// use fields and attributes from scope as structured metadata
-			scopeAttributesAsStructuredMetadata := make(push.LabelsAdapter, 0, scopeAttrs.Len()+3)
-			scopeAttrs.Range(func(k string, v pcommon.Value) bool {
-				action := otlpConfig.ActionForScopeAttribute(k)
-				if action == Drop {
-					return true
-				}
-
-				attributeAsLabels := attributeToLabels(k, v, "")
-				if action == StructuredMetadata {
-					scopeAttributesAsStructuredMetadata = append(scopeAttributesAsStructuredMetadata, attributeAsLabels...)
-				}
-
-				return true
-			})
-
-			if scopeName := scope.Name(); scopeName != "" {
-				scopeAttributesAsStructuredMetadata = append(scopeAttributesAsStructuredMetadata, push.LabelAdapter{
-					Name:  "scope_name",
-					Value: scopeName,
-				})
-			}
-			if scopeVersion := scope.Version(); scopeVersion != "" {
-				scopeAttributesAsStructuredMetadata = append(scopeAttributesAsStructuredMetadata, push.LabelAdapter{
-					Name:  "scope_version",
-					Value: scopeVersion,
-				})
-			}
-			if scopeDroppedAttributesCount := scope.DroppedAttributesCount(); scopeDroppedAttributesCount != 0 {
-				scopeAttributesAsStructuredMetadata = append(scopeAttributesAsStructuredMetadata, push.LabelAdapter{
-					Name:  "scope_dropped_attributes_count",
-					Value: fmt.Sprintf("%d", scopeDroppedAttributesCount),
-				})
-			}
+			scopeAttributesAsStructuredMetadata := scopeToStructuredMetadata(scope, otlpConfig)
 
 			scopeAttributesAsStructuredMetadataSize := labelsSize(scopeAttributesAsStructuredMetadata)
 			stats.StructuredMetadataBytes[retentionPeriodForUser] += int64(scopeAttributesAsStructuredMetadataSize)
@@ -277,6 +204,92 @@ func otlpToLokiPushRequest(ctx context.Context, ld plog.Logs, userID string, ten
 	return pr
 }
 
+// resourceAttributesToLabels returns the stream labels and the structured metadata of the resource attributes.
+func resourceAttributesToLabels(resAttrs pcommon.Map, otlpConfig OTLPConfig, discoverServiceName []string, stats *Stats) (model.LabelSet, push.LabelsAdapter) {
+	resourceAttributesAsStructuredMetadata := make(push.LabelsAdapter, 0, resAttrs.Len())
+	streamLabels := make(model.LabelSet, 30) // we have a default labels limit of 30 so just initialize the map of same size
+
+	shouldDiscoverServiceName := len(discoverServiceName) > 0 && !stats.IsAggregatedMetric
+	hasServiceName := false
+	if v, ok := resAttrs.Get(attrServiceName); ok && v.AsString() != "" {
+		hasServiceName = true
+	}
+	resAttrs.Range(func(k string, v pcommon.Value) bool {
+		action := otlpConfig.ActionForResourceAttribute(k)
+		if action == Drop {
+			return true
+		}
+
+		attributeAsLabels := attributeToLabels(k, v, "")
+		if action == IndexLabel {
+			for _, lbl := range attributeAsLabels {
+				streamLabels[model.LabelName(lbl.Name)] = model.LabelValue(lbl.Value)
+
+				if !hasServiceName && shouldDiscoverServiceName {
+					for _, labelName := range discoverServiceName {
+						if lbl.Name == labelName {
+							streamLabels[model.LabelName(LabelServiceName)] = model.LabelValue(lbl.Value)
+							hasServiceName = true
+							break
+						}
+					}
+				}
+			}
+		} else if action == StructuredMetadata {
+			resourceAttributesAsStructuredMetadata = append(resourceAttributesAsStructuredMetadata, attributeAsLabels...)
+		}
+
+		return true
+	})
+
+	if !hasServiceName && shouldDiscoverServiceName {
+		streamLabels[model.LabelName(LabelServiceName)] = model.LabelValue(ServiceUnknown)
+	}
+
+	return streamLabels, resourceAttributesAsStructuredMetadata
+}
+
+// scopeToStructuredMetadata returns the structured metadata of the fields and attributes of a scope.
+func scopeToStructuredMetadata(scope pcommon.InstrumentationScope, otlpConfig OTLPConfig) push.LabelsAdapter {
+	// use fields and attributes from scope as structured metadata
+	scopeAttrs := scope.Attributes()
+	scopeAttributesAsStructuredMetadata := make(push.LabelsAdapter, 0, scopeAttrs.Len()+3)
+	scopeAttrs.Range(func(k string, v pcommon.Value) bool {
+		action := otlpConfig.ActionForScopeAttribute(k)
+		if action == Drop {
+			return true
+		}
+
+		attributeAsLabels := attributeToLabels(k, v, "")
+		if action == StructuredMetadata {
+			scopeAttributesAsStructuredMetadata = append(scopeAttributesAsStructuredMetadata, attributeAsLabels...)
+		}
+
+		return true
+	})
+
+	if scopeName := scope.Name(); scopeName != "" {
+		scopeAttributesAsStructuredMetadata = append(scopeAttributesAsStructuredMetadata, push.LabelAdapter{
+			Name:  "scope_name",
+			Value: scopeName,
+		})
+	}
+	if scopeVersion := scope.Version(); scopeVersion != "" {
+		scopeAttributesAsStructuredMetadata = append(scopeAttributesAsStructuredMetadata, push.LabelAdapter{
+			Name:  "scope_version",
+			Value: scopeVersion,
+		})
+	}
+	if scopeDroppedAttributesCount := scope.DroppedAttributesCount(); scopeDroppedAttributesCount != 0 {
+		scopeAttributesAsStructuredMetadata = append(scopeAttributesAsStructuredMetadata, push.LabelAdapter{
+			Name:  "scope_dropped_attributes_count",
+			Value: fmt.Sprintf("%d", scopeDroppedAttributesCount),
+		})
+	}
+
+	return scopeAttributesAsStructuredMetadata
+}
+
 // otlpLogToPushEntry converts an OTLP log record to a Loki push.Entry.
 func otlpLogToPushEntry(log plog.LogRecord, otlpConfig OTLPConfig) push.Entry {
 	// copy log attributes and all the fields from log(except log.Body) to structured metadata
@@ -47,6 +47,8 @@ type OTLPConfig struct {
 
 type GlobalOTLPConfig struct {
 	DefaultOTLPResourceAttributesAsIndexLabels []string `yaml:"default_resource_attributes_as_index_labels"`
+	TracesReceiverEnabled                      bool     `yaml:"traces_receiver_enabled"`
+	MetricsExemplarsReceiverEnabled            bool     `yaml:"metrics_exemplars_receiver_enabled"`
 }
 
 // RegisterFlags registers distributor-related flags.
@@ -71,6 +73,8 @@ func (cfg *GlobalOTLPConfig) RegisterFlags(fs *flag.FlagSet) {
 		"k8s.job.name",
 	}
 	fs.Var((*flagext.StringSlice)(&cfg.DefaultOTLPResourceAttributesAsIndexLabels), "distributor.otlp.default_resource_attributes_as_index_labels", "List of default otlp resource attributes to be picked as index labels")
+	fs.BoolVar(&cfg.TracesReceiverEnabled, "distributor.otlp.traces_receiver_enabled", false, "Accept OTLP traces on /otlp/v1/traces and store the events of their spans, like the exceptions, as log entries with the trace_id and span_id structured metadata. The attributes are mapped like those of OTLP logs, and the spans themselves are discarded.")
+	fs.BoolVar(&cfg.MetricsExemplarsReceiverEnabled, "distributor.otlp.metrics_exemplars_receiver_enabled", false, "Accept OTLP metrics on /otlp/v1/metrics and store the exemplars of their data points as log entries whose line is the metric name, with the value, trace_id and span_id structured metadata. The attributes are mapped like those of OTLP logs, and the data points themselves are discarded.")
 }
 
 // ApplyGlobalOTLPConfig applies global otlp config, specifically DefaultOTLPResourceAttributesAsIndexLabels for the start.
@@ -0,0 +1,205 @@
+package push
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+// ParseOTLPMetricsRequest parses an OTLP metrics request and converts the exemplars of its data points to log entries.
+// The data points themselves are not stored.
+func ParseOTLPMetricsRequest(userID string, r *http.Request, tenantsRetention TenantsRetention, limits Limits, tracker UsageTracker) (*logproto.PushRequest, *Stats, error) {
+	stats := newPushStats()
+	otlpMetrics, err := extractMetrics(r, stats)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := otlpExemplarsToLokiPushRequest(r.Context(), otlpMetrics, userID, tenantsRetention, limits.OTLPConfig(userID), limits.DiscoverServiceName(userID), tracker, stats)
+	return req, stats, nil
+}
+
+func extractMetrics(r *http.Request, pushStats *Stats) (pmetric.Metrics, error) {
+	req := pmetricotlp.NewExportRequest()
+	if err := unmarshalOTLPRequest(r, pushStats, req.UnmarshalProto, req.UnmarshalJSON); err != nil {
+		return pmetric.NewMetrics(), err
+	}
+
+	return req.Metrics(), nil
+}
+
+// dataPointExemplars are the exemplars of a data point, with the attributes and the timestamp of the data point.
+type dataPointExemplars struct {
+	attributes pcommon.Map
+	timestamp  pcommon.Timestamp
+	exemplars  pmetric.ExemplarSlice
+}
+
+// metricExemplars returns the exemplars of the data points of a metric. Summaries have no exemplars.
+func metricExemplars(metric pmetric.Metric) []dataPointExemplars {
+	var res []dataPointExemplars
+	switch metric.Type() {
+	case pmetric.MetricTypeGauge:
+		res = numberDataPointsExemplars(metric.Gauge().DataPoints())
+	case pmetric.MetricTypeSum:
+		res = numberDataPointsExemplars(metric.Sum().DataPoints())
+	case pmetric.MetricTypeHistogram:
+		dps := metric.Histogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			res = append(res, dataPointExemplars{dps.At(i).Attributes(), dps.At(i).Timestamp(), dps.At(i).Exemplars()})
+		}
+	case pmetric.MetricTypeExponentialHistogram:
+		dps := metric.ExponentialHistogram().DataPoints()
+		for i := 0; i < dps.Len(); i++ {
+			res = append(res, dataPointExemplars{dps.At(i).Attributes(), dps.At(i).Timestamp(), dps.At(i).Exemplars()})
+		}
+	}
+	return res
+}
+
+func numberDataPointsExemplars(dps pmetric.NumberDataPointSlice) []dataPointExemplars {
+	res := make([]dataPointExemplars, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		res = append(res, dataPointExemplars{dps.At(i).Attributes(), dps.At(i).Timestamp(), dps.At(i).Exemplars()})
+	}
+	return res
+}
+
+// otlpExemplarsToLokiPushRequest converts the exemplars of OTLP metrics to a push request.
+// The resource and scope attributes are mapped to labels and structured metadata like for OTLP logs,
+// and the attributes of the data points and the exemplars are mapped like log attributes.
+func otlpExemplarsToLokiPushRequest(ctx context.Context, md pmetric.Metrics, userID string, tenantsRetention TenantsRetention, otlpConfig OTLPConfig, discoverServiceName []string, tracker UsageTracker, stats *Stats) *logproto.PushRequest {
+	rms := md.ResourceMetrics()
+	pushRequestsByStream := make(map[string]logproto.Stream, rms.Len())
+
+	for i := 0; i < rms.Len(); i++ {
+		sms := rms.At(i).ScopeMetrics()
+
+		streamLabels, resourceAttributesAsStructuredMetadata := resourceAttributesToLabels(rms.At(i).Resource().Attributes(), otlpConfig, discoverServiceName, stats)
+		if err := streamLabels.Validate(); err != nil {
+			stats.Errs = append(stats.Errs, fmt.Errorf("invalid labels: %w", err))
+			continue
+		}
+		labelsStr := streamLabels.String()
+		lbs := modelLabelsSetToLabelsList(streamLabels)
+		retentionPeriodForUser := tenantsRetention.RetentionPeriodFor(userID, lbs)
+
+		for j := 0; j < sms.Len(); j++ {
+			scopeAttributesAsStructuredMetadata := scopeToStructuredMetadata(sms.At(j).Scope(), otlpConfig)
+			metrics := sms.At(j).Metrics()
+
+			for k := 0; k < metrics.Len(); k++ {
+				metric := metrics.At(k)
+
+				for _, dp := range metricExemplars(metric) {
+					for l := 0; l < dp.exemplars.Len(); l++ {
+						entry := otlpExemplarToPushEntry(metric, dp, dp.exemplars.At(l), otlpConfig)
+						entry.StructuredMetadata = append(entry.StructuredMetadata, resourceAttributesAsStructuredMetadata...)
+						entry.StructuredMetadata = append(entry.StructuredMetadata, scopeAttributesAsStructuredMetadata...)
+
+						stream, ok := pushRequestsByStream[labelsStr]
+						if !ok {
+							stream = logproto.Stream{Labels: labelsStr}
+							stats.StreamLabelsSize += int64(labelsSize(logproto.FromLabelsToLabelAdapters(lbs)))
+						}
+						stream.Entries = append(stream.Entries, entry)
+						pushRequestsByStream[labelsStr] = stream
+
+						metadataSize := int64(labelsSize(entry.StructuredMetadata))
+						stats.StructuredMetadataBytes[retentionPeriodForUser] += metadataSize
+						stats.LogLinesBytes[retentionPeriodForUser] += int64(len(entry.Line))
+						if tracker != nil {
+							tracker.ReceivedBytesAdd(ctx, userID, retentionPeriodForUser, lbs, float64(len(entry.Line)))
+							tracker.ReceivedBytesAdd(ctx, userID, retentionPeriodForUser, lbs, float64(metadataSize))
+						}
+
+						stats.NumLines++
+						if entry.Timestamp.After(stats.MostRecentEntryTimestamp) {
+							stats.MostRecentEntryTimestamp = entry.Timestamp
+						}
+					}
+				}
+			}
+		}
+	}
+
+	pr := &push.PushRequest{
+		Streams: make([]push.Stream, 0, len(pushRequestsByStream)),
+	}
+
+	for _, stream := range pushRequestsByStream {
+		pr.Streams = append(pr.Streams, stream)
+	}
+
+	return pr
+}
+
+// otlpExemplarToPushEntry converts an OTLP exemplar to a Loki push.Entry whose line is the name of its metric.
+// The value of the exemplar is stored in the value structured metadata.
+func otlpExemplarToPushEntry(metric pmetric.Metric, dp dataPointExemplars, exemplar pmetric.Exemplar, otlpConfig OTLPConfig) push.Entry {
+	structuredMetadata := make(push.LabelsAdapter, 0, dp.attributes.Len()+exemplar.FilteredAttributes().Len()+3)
+	attributeToStructuredMetadata := func(k string, v pcommon.Value) bool {
+		if otlpConfig.ActionForLogAttribute(k) == StructuredMetadata {
+			structuredMetadata = append(structuredMetadata, attributeToLabels(k, v, "")...)
+		}
+		return true
+	}
+	dp.attributes.Range(attributeToStructuredMetadata)
+	exemplar.FilteredAttributes().Range(attributeToStructuredMetadata)
+
+	var value string
+	switch exemplar.ValueType() {
+	case pmetric.ExemplarValueTypeInt:
+		value = strconv.FormatInt(exemplar.IntValue(), 10)
+	case pmetric.ExemplarValueTypeDouble:
+		value = strconv.FormatFloat(exemplar.DoubleValue(), 'g', -1, 64)
+	}
+	if value != "" {
+		structuredMetadata = append(structuredMetadata, push.LabelAdapter{
+			Name:  "value",
+			Value: value,
+		})
+	}
+
+	if traceID := exemplar.TraceID(); !traceID.IsEmpty() {
+		structuredMetadata = append(structuredMetadata, push.LabelAdapter{
+			Name:  "trace_id",
+			Value: hex.EncodeToString(traceID[:]),
+		})
+	}
+	if spanID := exemplar.SpanID(); !spanID.IsEmpty() {
+		structuredMetadata = append(structuredMetadata, push.LabelAdapter{
+			Name:  "span_id",
+			Value: hex.EncodeToString(spanID[:]),
+		})
+	}
+
+	return push.Entry{
+		Timestamp:          timestampFromExemplar(dp, exemplar),
+		Line:               metric.Name(),
+		StructuredMetadata: structuredMetadata,
+	}
+}
+
+func timestampFromExemplar(dp dataPointExemplars, exemplar pmetric.Exemplar) time.Time {
+	if exemplar.Timestamp() != 0 {
+		return time.Unix(0, int64(exemplar.Timestamp()))
+	}
+
+	if dp.timestamp != 0 {
+		return time.Unix(0, int64(dp.timestamp))
+	}
+
+	return time.Unix(0, time.Now().UnixNano())
+}
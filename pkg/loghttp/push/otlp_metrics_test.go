@@ -0,0 +1,98 @@
+package push
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestParseOTLPMetricsRequest(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+	traceID := pcommon.TraceID{0x0b, 0xf0}
+	spanID := pcommon.SpanID{0x0a, 0x1b}
+
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	rm.Resource().Attributes().PutStr("host.name", "host-1")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	sm.Scope().SetName("http")
+
+	histogram := sm.Metrics().AppendEmpty()
+	histogram.SetName("http_request_duration_seconds")
+	hdp := histogram.SetEmptyHistogram().DataPoints().AppendEmpty()
+	hdp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	hdp.Attributes().PutStr("method", "GET")
+	slow := hdp.Exemplars().AppendEmpty()
+	slow.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Second)))
+	slow.SetDoubleValue(2.5)
+	slow.SetTraceID(traceID)
+	slow.SetSpanID(spanID)
+	slow.FilteredAttributes().PutStr("route", "/cart")
+
+	counter := sm.Metrics().AppendEmpty()
+	counter.SetName("http_requests_total")
+	cdp := counter.SetEmptySum().DataPoints().AppendEmpty()
+	cdp.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	cdp.Exemplars().AppendEmpty().SetIntValue(3)
+
+	// data points without exemplars and summaries are discarded.
+	gauge := sm.Metrics().AppendEmpty()
+	gauge.SetName("memory_bytes")
+	gauge.SetEmptyGauge().DataPoints().AppendEmpty().SetIntValue(1024)
+	summary := sm.Metrics().AppendEmpty()
+	summary.SetName("rpc_duration_seconds")
+	summary.SetEmptySummary().DataPoints().AppendEmpty().SetSum(1)
+
+	req := pmetricotlp.NewExportRequestFromMetrics(md)
+	body, err := req.MarshalProto()
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/otlp/v1/metrics", bytes.NewReader(body))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", pbContentType)
+
+	pushReq, stats, err := ParseOTLPMetricsRequest("fake", request, &fakeLimits{}, &fakeLimits{enabled: true}, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []logproto.Stream{
+		{
+			Labels: `{service_name="checkout"}`,
+			Entries: []logproto.Entry{
+				{
+					Timestamp: now.Add(-time.Second),
+					Line:      "http_request_duration_seconds",
+					StructuredMetadata: push.LabelsAdapter{
+						{Name: "method", Value: "GET"},
+						{Name: "route", Value: "/cart"},
+						{Name: "value", Value: "2.5"},
+						{Name: "trace_id", Value: "0bf00000000000000000000000000000"},
+						{Name: "span_id", Value: "0a1b000000000000"},
+						{Name: "host_name", Value: "host-1"},
+						{Name: "scope_name", Value: "http"},
+					},
+				},
+				{
+					Timestamp: now,
+					Line:      "http_requests_total",
+					StructuredMetadata: push.LabelsAdapter{
+						{Name: "value", Value: "3"},
+						{Name: "host_name", Value: "host-1"},
+						{Name: "scope_name", Value: "http"},
+					},
+				},
+			},
+		},
+	}, pushReq.Streams)
+	require.Equal(t, int64(2), stats.NumLines)
+	require.Equal(t, now, stats.MostRecentEntryTimestamp)
+}
@@ -0,0 +1,170 @@
+package push
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+// ParseOTLPTracesRequest parses an OTLP traces request and converts the events of its spans, like the exceptions, to log entries.
+// The spans themselves are not stored.
+func ParseOTLPTracesRequest(userID string, r *http.Request, tenantsRetention TenantsRetention, limits Limits, tracker UsageTracker) (*logproto.PushRequest, *Stats, error) {
+	stats := newPushStats()
+	otlpTraces, err := extractTraces(r, stats)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req := otlpSpanEventsToLokiPushRequest(r.Context(), otlpTraces, userID, tenantsRetention, limits.OTLPConfig(userID), limits.DiscoverServiceName(userID), tracker, stats)
+	return req, stats, nil
+}
+
+func extractTraces(r *http.Request, pushStats *Stats) (ptrace.Traces, error) {
+	req := ptraceotlp.NewExportRequest()
+	if err := unmarshalOTLPRequest(r, pushStats, req.UnmarshalProto, req.UnmarshalJSON); err != nil {
+		return ptrace.NewTraces(), err
+	}
+
+	return req.Traces(), nil
+}
+
+// otlpSpanEventsToLokiPushRequest converts the span events of OTLP traces to a push request.
+// The resource and scope attributes are mapped to labels and structured metadata like for OTLP logs,
+// and the event attributes are mapped like log attributes.
+func otlpSpanEventsToLokiPushRequest(ctx context.Context, td ptrace.Traces, userID string, tenantsRetention TenantsRetention, otlpConfig OTLPConfig, discoverServiceName []string, tracker UsageTracker, stats *Stats) *logproto.PushRequest {
+	rss := td.ResourceSpans()
+	pushRequestsByStream := make(map[string]logproto.Stream, rss.Len())
+
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+
+		streamLabels, resourceAttributesAsStructuredMetadata := resourceAttributesToLabels(rss.At(i).Resource().Attributes(), otlpConfig, discoverServiceName, stats)
+		if err := streamLabels.Validate(); err != nil {
+			stats.Errs = append(stats.Errs, fmt.Errorf("invalid labels: %w", err))
+			continue
+		}
+		labelsStr := streamLabels.String()
+		lbs := modelLabelsSetToLabelsList(streamLabels)
+		retentionPeriodForUser := tenantsRetention.RetentionPeriodFor(userID, lbs)
+
+		for j := 0; j < sss.Len(); j++ {
+			scopeAttributesAsStructuredMetadata := scopeToStructuredMetadata(sss.At(j).Scope(), otlpConfig)
+			spans := sss.At(j).Spans()
+
+			for k := 0; k < spans.Len(); k++ {
+				span := spans.At(k)
+				events := span.Events()
+
+				for l := 0; l < events.Len(); l++ {
+					entry := otlpSpanEventToPushEntry(span, events.At(l), otlpConfig)
+					entry.StructuredMetadata = append(entry.StructuredMetadata, resourceAttributesAsStructuredMetadata...)
+					entry.StructuredMetadata = append(entry.StructuredMetadata, scopeAttributesAsStructuredMetadata...)
+
+					stream, ok := pushRequestsByStream[labelsStr]
+					if !ok {
+						stream = logproto.Stream{Labels: labelsStr}
+						stats.StreamLabelsSize += int64(labelsSize(logproto.FromLabelsToLabelAdapters(lbs)))
+					}
+					stream.Entries = append(stream.Entries, entry)
+					pushRequestsByStream[labelsStr] = stream
+
+					metadataSize := int64(labelsSize(entry.StructuredMetadata))
+					stats.StructuredMetadataBytes[retentionPeriodForUser] += metadataSize
+					stats.LogLinesBytes[retentionPeriodForUser] += int64(len(entry.Line))
+					if tracker != nil {
+						tracker.ReceivedBytesAdd(ctx, userID, retentionPeriodForUser, lbs, float64(len(entry.Line)))
+						tracker.ReceivedBytesAdd(ctx, userID, retentionPeriodForUser, lbs, float64(metadataSize))
+					}
+
+					stats.NumLines++
+					if entry.Timestamp.After(stats.MostRecentEntryTimestamp) {
+						stats.MostRecentEntryTimestamp = entry.Timestamp
+					}
+				}
+			}
+		}
+	}
+
+	pr := &push.PushRequest{
+		Streams: make([]push.Stream, 0, len(pushRequestsByStream)),
+	}
+
+	for _, stream := range pushRequestsByStream {
+		pr.Streams = append(pr.Streams, stream)
+	}
+
+	return pr
+}
+
+// otlpSpanEventToPushEntry converts an OTLP span event to a Loki push.Entry whose line is the name of the event.
+func otlpSpanEventToPushEntry(span ptrace.Span, event ptrace.SpanEvent, otlpConfig OTLPConfig) push.Entry {
+	eventAttrs := event.Attributes()
+	structuredMetadata := make(push.LabelsAdapter, 0, eventAttrs.Len()+4)
+	eventAttrs.Range(func(k string, v pcommon.Value) bool {
+		action := otlpConfig.ActionForLogAttribute(k)
+		if action == Drop {
+			return true
+		}
+
+		attributeAsLabels := attributeToLabels(k, v, "")
+		if action == StructuredMetadata {
+			structuredMetadata = append(structuredMetadata, attributeAsLabels...)
+		}
+
+		return true
+	})
+
+	if droppedAttributesCount := event.DroppedAttributesCount(); droppedAttributesCount != 0 {
+		structuredMetadata = append(structuredMetadata, push.LabelAdapter{
+			Name:  "dropped_attributes_count",
+			Value: fmt.Sprintf("%d", droppedAttributesCount),
+		})
+	}
+
+	if traceID := span.TraceID(); !traceID.IsEmpty() {
+		structuredMetadata = append(structuredMetadata, push.LabelAdapter{
+			Name:  "trace_id",
+			Value: hex.EncodeToString(traceID[:]),
+		})
+	}
+	if spanID := span.SpanID(); !spanID.IsEmpty() {
+		structuredMetadata = append(structuredMetadata, push.LabelAdapter{
+			Name:  "span_id",
+			Value: hex.EncodeToString(spanID[:]),
+		})
+	}
+	if spanName := span.Name(); spanName != "" {
+		structuredMetadata = append(structuredMetadata, push.LabelAdapter{
+			Name:  "span_name",
+			Value: spanName,
+		})
+	}
+
+	return push.Entry{
+		Timestamp:          timestampFromSpanEvent(span, event),
+		Line:               event.Name(),
+		StructuredMetadata: structuredMetadata,
+	}
+}
+
+func timestampFromSpanEvent(span ptrace.Span, event ptrace.SpanEvent) time.Time {
+	if event.Timestamp() != 0 {
+		return time.Unix(0, int64(event.Timestamp()))
+	}
+
+	if span.StartTimestamp() != 0 {
+		return time.Unix(0, int64(span.StartTimestamp()))
+	}
+
+	return time.Unix(0, time.Now().UnixNano())
+}
@@ -0,0 +1,83 @@
+package push
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/pdata/ptrace/ptraceotlp"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+)
+
+func TestParseOTLPTracesRequest(t *testing.T) {
+	now := time.Unix(0, time.Now().UnixNano())
+	traceID := pcommon.TraceID{0x0b, 0xf0}
+	spanID := pcommon.SpanID{0x0a, 0x1b}
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "checkout")
+	rs.Resource().Attributes().PutStr("host.name", "host-1")
+	ss := rs.ScopeSpans().AppendEmpty()
+	ss.Scope().SetName("http")
+
+	span := ss.Spans().AppendEmpty()
+	span.SetName("GET /cart")
+	span.SetTraceID(traceID)
+	span.SetSpanID(spanID)
+	span.SetStartTimestamp(pcommon.NewTimestampFromTime(now.Add(-time.Second)))
+	exception := span.Events().AppendEmpty()
+	exception.SetName("exception")
+	exception.SetTimestamp(pcommon.NewTimestampFromTime(now))
+	exception.Attributes().PutStr("exception.type", "TimeoutError")
+	retry := span.Events().AppendEmpty()
+	retry.SetName("retry")
+
+	// spans without events are discarded.
+	ss.Spans().AppendEmpty().SetName("GET /health")
+
+	req := ptraceotlp.NewExportRequestFromTraces(td)
+	body, err := req.MarshalProto()
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/otlp/v1/traces", bytes.NewReader(body))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", pbContentType)
+
+	pushReq, stats, err := ParseOTLPTracesRequest("fake", request, &fakeLimits{}, &fakeLimits{enabled: true}, nil)
+	require.NoError(t, err)
+
+	spanMetadata := push.LabelsAdapter{
+		{Name: "trace_id", Value: "0bf00000000000000000000000000000"},
+		{Name: "span_id", Value: "0a1b000000000000"},
+		{Name: "span_name", Value: "GET /cart"},
+		{Name: "host_name", Value: "host-1"},
+		{Name: "scope_name", Value: "http"},
+	}
+	require.Equal(t, []logproto.Stream{
+		{
+			Labels: `{service_name="checkout"}`,
+			Entries: []logproto.Entry{
+				{
+					Timestamp:          now,
+					Line:               "exception",
+					StructuredMetadata: append(push.LabelsAdapter{{Name: "exception_type", Value: "TimeoutError"}}, spanMetadata...),
+				},
+				{
+					Timestamp:          now.Add(-time.Second),
+					Line:               "retry",
+					StructuredMetadata: spanMetadata,
+				},
+			},
+		},
+	}, pushReq.Streams)
+	require.Equal(t, int64(2), stats.NumLines)
+	require.Equal(t, now, stats.MostRecentEntryTimestamp)
+	require.Equal(t, int64(len("exception")+len("retry")), stats.LogLinesBytes[time.Hour])
+}
@@ -70,6 +70,7 @@ type TenantsRetention interface {
 
 type Limits interface {
 	OTLPConfig(userID string) OTLPConfig
+	ElasticsearchConfig(userID string) ElasticsearchConfig
 	DiscoverServiceName(userID string) []string
 }
 
@@ -79,6 +80,10 @@ func (EmptyLimits) OTLPConfig(string) OTLPConfig {
 	return DefaultOTLPConfig(GlobalOTLPConfig{})
 }
 
+func (EmptyLimits) ElasticsearchConfig(string) ElasticsearchConfig {
+	return ElasticsearchConfig{}
+}
+
 func (EmptyLimits) DiscoverServiceName(string) []string {
 	return nil
 }
@@ -404,6 +404,8 @@ type fakeLimits struct {
 	enabled         bool
 	labels          []string
 	indexAttributes []string
+
+	elasticsearchConfig ElasticsearchConfig
 }
 
 func (f *fakeLimits) RetentionPeriodFor(_ string, _ labels.Labels) time.Duration {
@@ -429,6 +431,10 @@ func (f *fakeLimits) OTLPConfig(_ string) OTLPConfig {
 	return DefaultOTLPConfig(defaultGlobalOTLPConfig)
 }
 
+func (f *fakeLimits) ElasticsearchConfig(_ string) ElasticsearchConfig {
+	return f.elasticsearchConfig
+}
+
 func (f *fakeLimits) DiscoverServiceName(_ string) []string {
 	if !f.enabled {
 		return nil
@@ -0,0 +1,101 @@
+package logql
+
+import (
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+// ParamsWithAtModifier overrides the time range of the query with the single
+// instant of an @ modifier, so that the range aggregation using it is
+// evaluated as an instant query.
+type ParamsWithAtModifier struct {
+	Params
+	Timestamp time.Time
+}
+
+// Start returns the timestamp of the @ modifier.
+func (p ParamsWithAtModifier) Start() time.Time { return p.Timestamp }
+
+// End returns the timestamp of the @ modifier.
+func (p ParamsWithAtModifier) End() time.Time { return p.Timestamp }
+
+// Step returns zero as the @ modifier is evaluated at a single instant.
+func (p ParamsWithAtModifier) Step() time.Duration { return 0 }
+
+// paramsForAtModifier returns the params used to evaluate a log range with the
+// given @ modifier. It returns q if the modifier is not set.
+func paramsForAtModifier(q Params, at *syntax.AtExpr) Params {
+	if at == nil {
+		return q
+	}
+	return ParamsWithAtModifier{
+		Params:    q,
+		Timestamp: at.Time(q.Start(), q.End()),
+	}
+}
+
+// AtModifierStepEvaluator evaluates its underlying evaluator once at the time
+// of the @ modifier and returns the result for every step of the query.
+type AtModifierStepEvaluator struct {
+	next StepEvaluator
+
+	stepMs, endMs, currentMs int64
+
+	evaluated bool
+	vec       promql.Vector
+}
+
+// withAtModifier wraps the given evaluator of a log range using the @
+// modifier. It returns next if the modifier is not set.
+func withAtModifier(next StepEvaluator, at *syntax.AtExpr, q Params) StepEvaluator {
+	if at == nil {
+		return next
+	}
+	stepMs := q.Step().Milliseconds()
+	// forces at least one step.
+	if stepMs == 0 {
+		stepMs = 1
+	}
+	return &AtModifierStepEvaluator{
+		next:      next,
+		stepMs:    stepMs,
+		endMs:     q.End().UnixMilli(),
+		currentMs: q.Start().UnixMilli() - stepMs,
+	}
+}
+
+func (e *AtModifierStepEvaluator) Next() (bool, int64, StepResult) {
+	if !e.evaluated {
+		e.evaluated = true
+		if ok, _, r := e.next.Next(); ok {
+			// copy the samples as the underlying evaluator may reuse them.
+			e.vec = append(promql.Vector(nil), r.SampleVector()...)
+		}
+		if e.next.Error() != nil {
+			return false, 0, SampleVector{}
+		}
+	}
+
+	e.currentMs = e.currentMs + e.stepMs
+	if e.currentMs > e.endMs {
+		return false, 0, SampleVector{}
+	}
+	vec := make(promql.Vector, 0, len(e.vec))
+	for _, s := range e.vec {
+		s.T = e.currentMs
+		vec = append(vec, s)
+	}
+	return true, e.currentMs, SampleVector(vec)
+}
+
+func (e *AtModifierStepEvaluator) Close() error { return e.next.Close() }
+
+func (e *AtModifierStepEvaluator) Error() error { return e.next.Error() }
+
+func (e *AtModifierStepEvaluator) Explain(parent Node) {
+	b := parent.Child("AtModifier")
+	e.next.Explain(b)
+}
@@ -0,0 +1,57 @@
+package logql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+type countingStepEvaluator struct {
+	StepEvaluator
+	calls int
+}
+
+func (e *countingStepEvaluator) Next() (bool, int64, StepResult) {
+	e.calls++
+	return e.StepEvaluator.Next()
+}
+
+func TestAtModifierStepEvaluator(t *testing.T) {
+	q, err := NewLiteralParams(`count_over_time({app="foo"}[1m] @ end())`, time.Unix(60, 0), time.Unix(120, 0), 30*time.Second, 0, logproto.FORWARD, 0, nil, nil)
+	require.NoError(t, err)
+
+	at := &syntax.AtExpr{StartOrEnd: syntax.OpAtEnd}
+	atParams := paramsForAtModifier(q, at)
+	require.Equal(t, time.Unix(120, 0), atParams.Start())
+	require.Equal(t, time.Unix(120, 0), atParams.End())
+	require.Equal(t, time.Duration(0), atParams.Step())
+
+	inner := &countingStepEvaluator{
+		StepEvaluator: NewMatrixStepEvaluator(time.Unix(120, 0), time.Unix(120, 0), 0, promql.Matrix{
+			{Metric: labels.FromStrings("app", "foo"), Floats: []promql.FPoint{{T: 120 * 1000, F: 42}}},
+		}),
+	}
+	ev := withAtModifier(inner, at, q)
+
+	var steps []int64
+	for ok, ts, r := ev.Next(); ok; ok, ts, r = ev.Next() {
+		steps = append(steps, ts)
+		require.Equal(t, promql.Vector{
+			{T: ts, F: 42, Metric: labels.FromStrings("app", "foo")},
+		}, r.SampleVector())
+	}
+	require.NoError(t, ev.Error())
+	require.Equal(t, []int64{60 * 1000, 90 * 1000, 120 * 1000}, steps)
+	require.Equal(t, 1, inner.calls)
+}
+
+func TestWithAtModifier_NotSet(t *testing.T) {
+	inner := NewMatrixStepEvaluator(time.Unix(0, 0), time.Unix(0, 0), 0, nil)
+	require.Equal(t, StepEvaluator(inner), withAtModifier(inner, nil, LiteralParams{}))
+}
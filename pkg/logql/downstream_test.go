@@ -70,10 +70,18 @@ func TestMappingEquivalence(t *testing.T) {
 			false,
 			nil,
 		},
+		{`histogram_over_time(10, 50, 90, {a=~".+"} | logfmt | unwrap value [1s])`, false, nil},
+		{`histogram_over_time(10, 50, 90, {a=~".+"} | logfmt | unwrap value [1s]) by (a)`, false, nil},
+		{`histogram_over_time(10, 50, 90, {a=~".+"} | logfmt | unwrap value [1s]) without (stream)`, false, nil},
+		{`sum by (le) (histogram_over_time(10, 50, 90, {a=~".+"} | logfmt | unwrap value [1s]))`, false, nil},
 		{`first_over_time({a=~".+"} | logfmt | unwrap value [1s])`, false, []string{ShardFirstOverTime}},
 		{`first_over_time({a=~".+"} | logfmt | unwrap value [1s]) by (a)`, false, []string{ShardFirstOverTime}},
 		{`last_over_time({a=~".+"} | logfmt | unwrap value [1s])`, false, []string{ShardLastOverTime}},
 		{`last_over_time({a=~".+"} | logfmt | unwrap value [1s]) by (a)`, false, []string{ShardLastOverTime}},
+		{`max_over_time(rate({a=~".+"}[1s])[5s:2s])`, false, nil},
+		{`sum(avg_over_time(rate({a=~".+"}[1s])[5s:2s]))`, false, nil},
+		{`max_over_time(sum by (a) (rate({a=~".+"}[1s]))[5s:2s])`, false, nil},
+		{`quantile_over_time(0.99, sum(count_over_time({a=~".+"}[2s]))[5s:1s] offset 1s)`, false, nil},
 		// topk prefers already-seen values in tiebreakers. Since the test data generates
 		// the same log lines for each series & the resulting promql.Vectors aren't deterministically
 		// sorted by labels, we don't expect this to pass.
@@ -363,6 +371,8 @@ func TestRangeMappingEquivalence(t *testing.T) {
 		{`rate({a=~".+"}[2s])`, time.Second},
 		{`rate({a=~".+"} | unwrap b [2s])`, time.Second},
 		{`bytes_rate({a=~".+"}[2s])`, time.Second},
+		{`histogram_over_time(0, 1, {a=~".+"} | unwrap b [2s])`, time.Second},
+		{`histogram_over_time(0, 1, {a=~".+"} | unwrap b [2s]) by (a)`, time.Second},
 
 		// sum
 		{`sum(bytes_over_time({a=~".+"}[2s]))`, time.Second},
@@ -375,6 +385,7 @@ func TestRangeMappingEquivalence(t *testing.T) {
 		{`sum(rate({a=~".+"}[2s]))`, time.Second},
 		{`sum(rate({a=~".+"} | unwrap b [2s]))`, time.Second},
 		{`sum(bytes_rate({a=~".+"}[2s]))`, time.Second},
+		{`sum by (le) (histogram_over_time(0, 1, {a=~".+"} | unwrap b [2s]))`, time.Second},
 
 		// sum by
 		{`sum by (a) (bytes_over_time({a=~".+"}[2s]))`, time.Second},
@@ -200,6 +200,20 @@ type Query interface {
 	Exec(ctx context.Context) (logqlmodel.Result, error)
 }
 
+// EntryWriter receives the entries of a streamed log query.
+type EntryWriter interface {
+	WriteEntry(labels string, entry logproto.Entry) error
+}
+
+// StreamingQuery is a LogQL query whose entries can be written as they are
+// read instead of being materialized in its result.
+type StreamingQuery interface {
+	Query
+	// ExecStream processes a log query and writes its entries to w in the
+	// order of the query direction. The result holds no data.
+	ExecStream(ctx context.Context, w EntryWriter) (logqlmodel.Result, error)
+}
+
 type query struct {
 	logger       log.Logger
 	params       Params
@@ -227,7 +241,24 @@ func (q *query) resultLength(res promql_parser.Value) int {
 
 // Exec Implements `Query`. It handles instrumentation & defers to Eval.
 func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
-	sp, ctx := opentracing.StartSpanFromContext(ctx, "query.Exec")
+	return q.exec(ctx, "query.Exec", func(ctx context.Context) (promql_parser.Value, int, error) {
+		data, err := q.Eval(ctx)
+		return data, q.resultLength(data), err
+	})
+}
+
+// ExecStream Implements `StreamingQuery`. It handles instrumentation & defers to EvalStream.
+func (q *query) ExecStream(ctx context.Context, w EntryWriter) (logqlmodel.Result, error) {
+	return q.exec(ctx, "query.ExecStream", func(ctx context.Context) (promql_parser.Value, int, error) {
+		n, err := q.EvalStream(ctx, w)
+		return nil, n, err
+	})
+}
+
+// exec instruments the evaluation of the query done by eval, which returns
+// the result of the query and its length.
+func (q *query) exec(ctx context.Context, operationName string, eval func(context.Context) (promql_parser.Value, int, error)) (logqlmodel.Result, error) {
+	sp, ctx := opentracing.StartSpanFromContext(ctx, operationName)
 	defer sp.Finish()
 
 	sp.LogKV(
@@ -257,11 +288,11 @@ func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
 	statsCtx, ctx := stats.NewContext(ctx)
 	metadataCtx, ctx := metadata.NewContext(ctx)
 
-	data, err := q.Eval(ctx)
+	data, length, err := eval(ctx)
 
 	queueTime, _ := ctx.Value(httpreq.QueryQueueTimeHTTPHeader).(time.Duration)
 
-	statResult := statsCtx.Result(time.Since(start), queueTime, q.resultLength(data))
+	statResult := statsCtx.Result(time.Since(start), queueTime, length)
 	sp.LogKV(statResult.KVList()...)
 
 	status, _ := server.ClientHTTPStatusAndError(err)
@@ -279,15 +310,11 @@ func (q *query) Exec(ctx context.Context) (logqlmodel.Result, error) {
 }
 
 func (q *query) Eval(ctx context.Context) (promql_parser.Value, error) {
-	tenants, _ := tenant.TenantIDs(ctx)
-	timeoutCapture := func(id string) time.Duration { return q.limits.QueryTimeout(ctx, id) }
-	queryTimeout := validation.SmallestPositiveNonZeroDurationPerTenant(tenants, timeoutCapture)
-	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
-	defer cancel()
-
-	if q.checkBlocked(ctx, tenants) {
-		return nil, logqlmodel.ErrBlocked
+	ctx, cancel, err := q.prepare(ctx)
+	if err != nil {
+		return nil, err
 	}
+	defer cancel()
 
 	switch e := q.params.GetExpression().(type) {
 	case syntax.SampleExpr:
@@ -295,16 +322,11 @@ func (q *query) Eval(ctx context.Context) (promql_parser.Value, error) {
 		return value, err
 
 	case syntax.LogSelectorExpr:
-		itr, err := q.evaluator.NewIterator(ctx, e, q.params)
+		itr, err := q.newEntryIterator(ctx, e)
 		if err != nil {
 			return nil, err
 		}
 
-		encodingFlags := httpreq.ExtractEncodingFlagsFromCtx(ctx)
-		if encodingFlags.Has(httpreq.FlagCategorizeLabels) {
-			itr = iter.NewCategorizeLabelsIterator(itr)
-		}
-
 		defer util.LogErrorWithContext(ctx, "closing iterator", itr.Close)
 		streams, err := readStreams(itr, q.params.Limit(), q.params.Direction(), q.params.Interval())
 		return streams, err
@@ -313,6 +335,62 @@ func (q *query) Eval(ctx context.Context) (promql_parser.Value, error) {
 	}
 }
 
+// EvalStream evaluates a log query writing its entries to w as they are read.
+// It returns the number of entries written.
+func (q *query) EvalStream(ctx context.Context, w EntryWriter) (int, error) {
+	e, ok := q.params.GetExpression().(syntax.LogSelectorExpr)
+	if !ok {
+		return 0, logqlmodel.ErrUnsupportedSyntaxForStreaming
+	}
+
+	ctx, cancel, err := q.prepare(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer cancel()
+
+	itr, err := q.newEntryIterator(ctx, e)
+	if err != nil {
+		return 0, err
+	}
+	defer util.LogErrorWithContext(ctx, "closing iterator", itr.Close)
+
+	var n int
+	err = readEntries(itr, q.params.Limit(), q.params.Direction(), q.params.Interval(), func(labels string, entry logproto.Entry) error {
+		n++
+		return w.WriteEntry(labels, entry)
+	})
+	return n, err
+}
+
+// prepare applies the query timeout of the tenants to the context and checks
+// that the query is not blocked.
+func (q *query) prepare(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	tenants, _ := tenant.TenantIDs(ctx)
+	timeoutCapture := func(id string) time.Duration { return q.limits.QueryTimeout(ctx, id) }
+	queryTimeout := validation.SmallestPositiveNonZeroDurationPerTenant(tenants, timeoutCapture)
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+
+	if q.checkBlocked(ctx, tenants) {
+		cancel()
+		return nil, nil, logqlmodel.ErrBlocked
+	}
+	return ctx, cancel, nil
+}
+
+func (q *query) newEntryIterator(ctx context.Context, e syntax.LogSelectorExpr) (iter.EntryIterator, error) {
+	itr, err := q.evaluator.NewIterator(ctx, e, q.params)
+	if err != nil {
+		return nil, err
+	}
+
+	encodingFlags := httpreq.ExtractEncodingFlagsFromCtx(ctx)
+	if encodingFlags.Has(httpreq.FlagCategorizeLabels) {
+		itr = iter.NewCategorizeLabelsIterator(itr)
+	}
+	return itr, nil
+}
+
 func (q *query) checkBlocked(ctx context.Context, tenants []string) bool {
 	blocker := newQueryBlocker(ctx, q)
 
@@ -475,6 +553,11 @@ func (q *query) checkIntervalLimit(expr syntax.SampleExpr, limit time.Duration)
 				return
 			}
 			err = fmt.Errorf("%w: [%s] > [%s]", logqlmodel.ErrIntervalLimit, model.Duration(e.Left.Interval), model.Duration(limit))
+		case *syntax.SubqueryExpr:
+			if e.Range <= limit {
+				return
+			}
+			err = fmt.Errorf("%w: [%s] > [%s]", logqlmodel.ErrIntervalLimit, model.Duration(e.Range), model.Duration(limit))
 		}
 	})
 	return err
@@ -548,6 +631,29 @@ func PopulateMatrixFromScalar(data promql.Scalar, params Params) promql.Matrix {
 // Otherwise, the stream labels are the whole series labels including the stream labels, structured metadata labels and parsed labels.
 func readStreams(i iter.EntryIterator, size uint32, dir logproto.Direction, interval time.Duration) (logqlmodel.Streams, error) {
 	streams := map[string]*logproto.Stream{}
+	err := readEntries(i, size, dir, interval, func(streamLabels string, entry logproto.Entry) error {
+		stream, ok := streams[streamLabels]
+		if !ok {
+			stream = &logproto.Stream{
+				Labels: streamLabels,
+			}
+			streams[streamLabels] = stream
+		}
+		stream.Entries = append(stream.Entries, entry)
+		return nil
+	})
+
+	result := make(logqlmodel.Streams, 0, len(streams))
+	for _, stream := range streams {
+		result = append(result, *stream)
+	}
+	sort.Sort(result)
+	return result, err
+}
+
+// readEntries calls f for at most size entries of the iterator, keeping only
+// one entry per interval when it is not zero.
+func readEntries(i iter.EntryIterator, size uint32, dir logproto.Direction, interval time.Duration, f func(streamLabels string, entry logproto.Entry) error) error {
 	respSize := uint32(0)
 	// lastEntry should be a really old time so that the first comparison is always true, we use a negative
 	// value here because many unit tests start at time.Unix(0,0)
@@ -564,25 +670,14 @@ func readStreams(i iter.EntryIterator, size uint32, dir logproto.Direction, inte
 		// If lastEntry.Unix < 0 this is the first pass through the loop and we should output the line.
 		// Then check to see if the entry is equal to, or past a forward or reverse step
 		if interval == 0 || lastEntry.Unix() < 0 || forwardShouldOutput || backwardShouldOutput {
-			stream, ok := streams[streamLabels]
-			if !ok {
-				stream = &logproto.Stream{
-					Labels: streamLabels,
-				}
-				streams[streamLabels] = stream
+			if err := f(streamLabels, entry); err != nil {
+				return err
 			}
-			stream.Entries = append(stream.Entries, entry)
-			lastEntry = i.At().Timestamp
+			lastEntry = entry.Timestamp
 			respSize++
 		}
 	}
-
-	result := make(logqlmodel.Streams, 0, len(streams))
-	for _, stream := range streams {
-		result = append(result, *stream)
-	}
-	sort.Sort(result)
-	return result, i.Err()
+	return i.Err()
 }
 
 type groupedAggregation struct {
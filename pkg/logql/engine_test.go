@@ -248,6 +248,26 @@ func TestEngine_InstantQuery(t *testing.T) {
 			},
 			promql.Vector{promql.Sample{T: 90 * 1000, F: 6, Metric: labels.FromStrings("app", "foo")}},
 		},
+		{
+			`count_over_time({app="foo"} |~".+bar" [1m] @ 60)`, time.Unix(90, 0), logproto.BACKWARD, 10,
+			[][]logproto.Series{
+				{newSeries(testSize, factor(10, identity), `{app="foo"}`)}, // 10 , 20 , 30 .. 60 = 6 total
+			},
+			[]SelectSampleParams{
+				{&logproto.SampleQueryRequest{Start: time.Unix(0, 0), End: time.Unix(60, 0), Selector: `count_over_time({app="foo"}|~".+bar"[1m] @ 60)`}},
+			},
+			promql.Vector{promql.Sample{T: 90 * 1000, F: 6, Metric: labels.FromStrings("app", "foo")}},
+		},
+		{
+			`sum_over_time(count_over_time({app="foo"} |~".+bar" [1m])[2m:1m])`, time.Unix(90, 0), logproto.BACKWARD, 10,
+			[][]logproto.Series{
+				{newSeries(testSize, factor(10, identity), `{app="foo"}`)}, // 1 at 0s + 6 at 60s = 7 total
+			},
+			[]SelectSampleParams{
+				{&logproto.SampleQueryRequest{Start: time.Unix(-60, 0), End: time.Unix(60, 0), Selector: `count_over_time({app="foo"}|~".+bar"[1m])`}},
+			},
+			promql.Vector{promql.Sample{T: 90 * 1000, F: 7, Metric: labels.FromStrings("app", "foo")}},
+		},
 		{
 			`count_over_time(({app="foo"} |~".+bar")[5m])`, time.Unix(5*60, 0), logproto.BACKWARD, 10,
 			[][]logproto.Series{
@@ -1088,6 +1108,21 @@ func TestEngine_RangeQuery(t *testing.T) {
 				},
 			},
 		},
+		{
+			`count_over_time({app="foo"} |~".+bar" [1m] @ 60)`, time.Unix(60, 0), time.Unix(120, 0), 30 * time.Second, 0, logproto.BACKWARD, 10,
+			[][]logproto.Series{
+				{newSeries(testSize, factor(10, identity), `{app="foo"}`)}, // 10 , 20 , 30 .. 60 = 6 total
+			},
+			[]SelectSampleParams{
+				{&logproto.SampleQueryRequest{Start: time.Unix(0, 0), End: time.Unix(60, 0), Selector: `count_over_time({app="foo"}|~".+bar"[1m] @ 60)`}},
+			},
+			promql.Matrix{
+				promql.Series{
+					Metric: labels.FromStrings("app", "foo"),
+					Floats: []promql.FPoint{{T: 60 * 1000, F: 6}, {T: 90 * 1000, F: 6}, {T: 120 * 1000, F: 6}},
+				},
+			},
+		},
 		{
 			`count_over_time(({app="foo"} |~".+bar")[5m])`, time.Unix(5*60, 0), time.Unix(5*120, 0), 30 * time.Second, 0, logproto.BACKWARD, 10,
 			[][]logproto.Series{
@@ -1240,6 +1275,63 @@ func TestEngine_RangeQuery(t *testing.T) {
 				},
 			},
 		},
+		{
+			`sum(count_over_time({app=~"foo|bar"} |~".+bar" [1m] offset 30s @ end())) by (app)`, time.Unix(60, 0), time.Unix(180, 0), 30 * time.Second, 0, logproto.FORWARD, 100,
+			[][]logproto.Series{
+				{newSeries(testSize, factor(10, identity), `{app="foo"}`), newSeries(testSize, factor(5, identity), `{app="bar"}`)},
+			},
+			[]SelectSampleParams{
+				{&logproto.SampleQueryRequest{Start: time.Unix(90, 0), End: time.Unix(150, 0), Selector: `sum by (app) (count_over_time({app=~"foo|bar"} |~".+bar" [1m] offset 30s @ end()))`}},
+			},
+			promql.Matrix{
+				promql.Series{
+					Metric: labels.FromStrings("app", "bar"),
+					Floats: []promql.FPoint{{T: 60 * 1000, F: 12}, {T: 90 * 1000, F: 12}, {T: 120 * 1000, F: 12}, {T: 150 * 1000, F: 12}, {T: 180 * 1000, F: 12}},
+				},
+				promql.Series{
+					Metric: labels.FromStrings("app", "foo"),
+					Floats: []promql.FPoint{{T: 60 * 1000, F: 6}, {T: 90 * 1000, F: 6}, {T: 120 * 1000, F: 6}, {T: 150 * 1000, F: 6}, {T: 180 * 1000, F: 6}},
+				},
+			},
+		},
+		{
+			`count_over_time(sum by (app) (count_over_time({app=~"foo|bar"} |~".+bar" [1m]))[2m:30s])`, time.Unix(60, 0), time.Unix(180, 0), 30 * time.Second, 0, logproto.FORWARD, 100,
+			[][]logproto.Series{
+				{newSeries(testSize, factor(10, identity), `{app="foo"}`), newSeries(testSize, factor(5, identity), `{app="bar"}`)},
+			},
+			[]SelectSampleParams{
+				{&logproto.SampleQueryRequest{Start: time.Unix(-90, 0), End: time.Unix(180, 0), Selector: `sum by (app) (count_over_time({app=~"foo|bar"} |~".+bar" [1m]))`}},
+			},
+			promql.Matrix{
+				promql.Series{
+					Metric: labels.FromStrings("app", "bar"),
+					Floats: []promql.FPoint{{T: 60 * 1000, F: 3}, {T: 90 * 1000, F: 4}, {T: 120 * 1000, F: 4}, {T: 150 * 1000, F: 4}, {T: 180 * 1000, F: 4}},
+				},
+				promql.Series{
+					Metric: labels.FromStrings("app", "foo"),
+					Floats: []promql.FPoint{{T: 60 * 1000, F: 3}, {T: 90 * 1000, F: 4}, {T: 120 * 1000, F: 4}, {T: 150 * 1000, F: 4}, {T: 180 * 1000, F: 4}},
+				},
+			},
+		},
+		{
+			`max_over_time(sum by (app) (count_over_time({app=~"foo|bar"} |~".+bar" [1m]))[1m:30s] offset 30s)`, time.Unix(60, 0), time.Unix(180, 0), 30 * time.Second, 0, logproto.FORWARD, 100,
+			[][]logproto.Series{
+				{newSeries(testSize, factor(10, identity), `{app="foo"}`), newSeries(testSize, factor(5, identity), `{app="bar"}`)},
+			},
+			[]SelectSampleParams{
+				{&logproto.SampleQueryRequest{Start: time.Unix(-30, 0), End: time.Unix(150, 0), Selector: `sum by (app) (count_over_time({app=~"foo|bar"} |~".+bar" [1m]))`}},
+			},
+			promql.Matrix{
+				promql.Series{
+					Metric: labels.FromStrings("app", "bar"),
+					Floats: []promql.FPoint{{T: 60 * 1000, F: 7}, {T: 90 * 1000, F: 12}, {T: 120 * 1000, F: 12}, {T: 150 * 1000, F: 12}, {T: 180 * 1000, F: 12}},
+				},
+				promql.Series{
+					Metric: labels.FromStrings("app", "foo"),
+					Floats: []promql.FPoint{{T: 60 * 1000, F: 4}, {T: 90 * 1000, F: 6}, {T: 120 * 1000, F: 6}, {T: 150 * 1000, F: 6}, {T: 180 * 1000, F: 6}},
+				},
+			},
+		},
 		{
 			`sum(count_over_time({app=~"foo|bar"} |~".+bar" [1m])) by (namespace,cluster, app)`, time.Unix(60, 0), time.Unix(180, 0), 30 * time.Second, 0, logproto.FORWARD, 100,
 			[][]logproto.Series{
@@ -2305,6 +2397,63 @@ func TestEngine_Stats(t *testing.T) {
 	require.Equal(t, queueTime.Seconds(), r.Statistics.Summary.QueueTime)
 }
 
+type entryRecorder struct {
+	labels  []string
+	entries []logproto.Entry
+}
+
+func (r *entryRecorder) WriteEntry(labels string, entry logproto.Entry) error {
+	r.labels = append(r.labels, labels)
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func TestEngine_ExecStream(t *testing.T) {
+	querier := &querierRecorder{
+		streams: map[string][]logproto.Stream{
+			"": {
+				newStream(5, identity, `{app="foo"}`),
+				newStream(5, identity, `{app="bar"}`),
+			},
+		},
+	}
+	eng := NewEngine(EngineOpts{}, querier, NoLimits, log.NewNopLogger())
+
+	t.Run("log query", func(t *testing.T) {
+		params, err := NewLiteralParams(`{app=~"foo|bar"}`, time.Unix(0, 0), time.Unix(30, 0), 0, 0, logproto.FORWARD, 5, nil, nil)
+		require.NoError(t, err)
+
+		w := &entryRecorder{}
+		r, err := eng.Query(params).(StreamingQuery).ExecStream(user.InjectOrgID(context.Background(), "fake"), w)
+		require.NoError(t, err)
+		require.Nil(t, r.Data)
+		require.Equal(t, int64(5), r.Statistics.Summary.TotalEntriesReturned)
+
+		require.Equal(t, []string{`{app="bar"}`, `{app="foo"}`, `{app="bar"}`, `{app="foo"}`, `{app="bar"}`}, w.labels)
+		for i, e := range w.entries {
+			require.Equal(t, time.Unix(int64(i/2), 0), e.Timestamp)
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		params, err := NewLiteralParams(`{app="foo"}`, time.Unix(0, 0), time.Unix(30, 0), 0, 2*time.Second, logproto.FORWARD, 5, nil, nil)
+		require.NoError(t, err)
+
+		w := &entryRecorder{}
+		_, err = eng.Query(params).(StreamingQuery).ExecStream(user.InjectOrgID(context.Background(), "fake"), w)
+		require.NoError(t, err)
+		require.Equal(t, []logproto.Entry{identity(0).Entry, identity(2).Entry, identity(4).Entry}, w.entries)
+	})
+
+	t.Run("metric query", func(t *testing.T) {
+		params, err := NewLiteralParams(`count_over_time({app="foo"}[1m])`, time.Unix(0, 0), time.Unix(30, 0), time.Second, 0, logproto.FORWARD, 5, nil, nil)
+		require.NoError(t, err)
+
+		_, err = eng.Query(params).(StreamingQuery).ExecStream(user.InjectOrgID(context.Background(), "fake"), &entryRecorder{})
+		require.ErrorIs(t, err, logqlmodel.ErrUnsupportedSyntaxForStreaming)
+	})
+}
+
 type metaQuerier struct{}
 
 func (metaQuerier) SelectLogs(ctx context.Context, _ SelectLogParams) (iter.EntryIterator, error) {
@@ -329,12 +329,13 @@ func (ev *DefaultEvaluator) NewStepEvaluator(
 			// if range expression is wrapped with a vector expression
 			// we should send the vector expression for allowing reducing labels at the source.
 			nextEvFactory = SampleEvaluatorFunc(func(ctx context.Context, _ SampleEvaluatorFactory, _ syntax.SampleExpr, _ Params) (StepEvaluator, error) {
+				rq := paramsForAtModifier(q, rangExpr.Left.At)
 				it, err := ev.querier.SelectSamples(ctx, SelectSampleParams{
 					&logproto.SampleQueryRequest{
 						// extend startTs backwards by step
-						Start: q.Start().Add(-rangExpr.Left.Interval).Add(-rangExpr.Left.Offset),
+						Start: rq.Start().Add(-rangExpr.Left.Interval).Add(-rangExpr.Left.Offset),
 						// add leap nanosecond to endTs to include lines exactly at endTs. range iterators work on start exclusive, end inclusive ranges
-						End: q.End().Add(-rangExpr.Left.Offset).Add(time.Nanosecond),
+						End: rq.End().Add(-rangExpr.Left.Offset).Add(time.Nanosecond),
 						// intentionally send the vector for reducing labels.
 						Selector: e.String(),
 						Shards:   q.Shards(),
@@ -347,17 +348,23 @@ func (ev *DefaultEvaluator) NewStepEvaluator(
 				if err != nil {
 					return nil, err
 				}
-				return newRangeAggEvaluator(iter.NewPeekingSampleIterator(it), rangExpr, q, rangExpr.Left.Offset)
+				rangeEvaluator, err := newRangeAggEvaluator(iter.NewPeekingSampleIterator(it), rangExpr, rq, rangExpr.Left.Offset)
+				if err != nil {
+					return nil, err
+				}
+				return withAtModifier(rangeEvaluator, rangExpr.Left.At, q), nil
 			})
 		}
 		return newVectorAggEvaluator(ctx, nextEvFactory, e, q)
 	case *syntax.RangeAggregationExpr:
+		// range aggregations using the @ modifier are evaluated once at a fixed time.
+		rq := paramsForAtModifier(q, e.Left.At)
 		it, err := ev.querier.SelectSamples(ctx, SelectSampleParams{
 			&logproto.SampleQueryRequest{
 				// extend startTs backwards by step
-				Start: q.Start().Add(-e.Left.Interval).Add(-e.Left.Offset),
+				Start: rq.Start().Add(-e.Left.Interval).Add(-e.Left.Offset),
 				// add leap nanosecond to endTs to include lines exactly at endTs. range iterators work on start exclusive, end inclusive ranges
-				End: q.End().Add(-e.Left.Offset).Add(time.Nanosecond),
+				End: rq.End().Add(-e.Left.Offset).Add(time.Nanosecond),
 				// intentionally send the vector for reducing labels.
 				Selector: e.String(),
 				Shards:   q.Shards(),
@@ -370,7 +377,13 @@ func (ev *DefaultEvaluator) NewStepEvaluator(
 		if err != nil {
 			return nil, err
 		}
-		return newRangeAggEvaluator(iter.NewPeekingSampleIterator(it), e, q, e.Left.Offset)
+		rangeEvaluator, err := newRangeAggEvaluator(iter.NewPeekingSampleIterator(it), e, rq, e.Left.Offset)
+		if err != nil {
+			return nil, err
+		}
+		return withAtModifier(rangeEvaluator, e.Left.At, q), nil
+	case *syntax.SubqueryAggregationExpr:
+		return newSubqueryAggEvaluator(ctx, nextEvFactory, e, q)
 	case *syntax.BinOpExpr:
 		return newBinOpStepEvaluator(ctx, nextEvFactory, e, q)
 	case *syntax.LabelReplaceExpr:
@@ -676,6 +689,17 @@ func newRangeAggEvaluator(
 			q.Start().UnixNano(), q.End().UnixNano(), o.Nanoseconds(),
 		)
 
+		return &RangeVectorEvaluator{
+			iter: iter,
+		}, nil
+	case syntax.OpRangeTypeHistogram:
+		iter := newHistogramIterator(
+			it, expr.Buckets,
+			expr.Left.Interval.Nanoseconds(),
+			q.Step().Nanoseconds(),
+			q.Start().UnixNano(), q.End().UnixNano(), o.Nanoseconds(),
+		)
+
 		return &RangeVectorEvaluator{
 			iter: iter,
 		}, nil
@@ -0,0 +1,109 @@
+package logql
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/v3/pkg/iter"
+)
+
+// newHistogramIterator returns an iterator that buckets the values of a
+// windowed aggregation into cumulative histogram buckets.
+func newHistogramIterator(
+	it iter.PeekingSampleIterator,
+	buckets []float64,
+	selRange, step, start, end, offset int64,
+) RangeVectorIterator {
+	// forces at least one step.
+	if step == 0 {
+		step = 1
+	}
+	if offset != 0 {
+		start = start - offset
+		end = end - offset
+	}
+	inner := &batchRangeVectorIterator{
+		iter:     it,
+		step:     step,
+		end:      end,
+		selRange: selRange,
+		metrics:  map[string]labels.Labels{},
+		window:   map[string]*promql.Series{},
+		agg:      nil,
+		current:  start - step, // first loop iteration will set it to start
+		offset:   offset,
+	}
+	return &histogramBatchRangeVectorIterator{
+		batchRangeVectorIterator: inner,
+		buckets:                  buckets,
+		bucketMetrics:            map[string][]labels.Labels{},
+		counts:                   make([]float64, len(buckets)+1),
+	}
+}
+
+// histogramBatchRangeVectorIterator returns one sample per series and bucket,
+// labelled with the bucket upper bound in the `le` label like a Prometheus
+// classic histogram. The last bucket is always `+Inf`.
+type histogramBatchRangeVectorIterator struct {
+	*batchRangeVectorIterator
+	buckets       []float64
+	bucketMetrics map[string][]labels.Labels
+	counts        []float64
+	at            []promql.Sample
+}
+
+// At aggregates the underlying window into cumulative bucket counts.
+func (r *histogramBatchRangeVectorIterator) At() (int64, StepResult) {
+	if r.at == nil {
+		r.at = make([]promql.Sample, 0, len(r.window)*len(r.counts))
+	}
+	r.at = r.at[:0]
+	// convert ts from nano to milli seconds as the iterator work with nanoseconds
+	ts := r.current/1e+6 + r.offset/1e+6
+	for lbs, series := range r.window {
+		metrics := r.metricsFor(lbs, series.Metric)
+		r.agg(series.Floats)
+		for i, count := range r.counts {
+			r.at = append(r.at, promql.Sample{
+				F:      count,
+				T:      ts,
+				Metric: metrics[i],
+			})
+		}
+	}
+	return ts, SampleVector(r.at)
+}
+
+// agg fills the cumulative bucket counts for the given samples.
+func (r *histogramBatchRangeVectorIterator) agg(samples []promql.FPoint) {
+	for i := range r.counts {
+		r.counts[i] = 0
+	}
+	for _, s := range samples {
+		// NaN values are only counted in the +Inf bucket.
+		r.counts[sort.SearchFloat64s(r.buckets, s.F)]++
+	}
+	for i := 1; i < len(r.counts); i++ {
+		r.counts[i] += r.counts[i-1]
+	}
+}
+
+// metricsFor returns the label sets of each bucket of a series.
+func (r *histogramBatchRangeVectorIterator) metricsFor(lbs string, metric labels.Labels) []labels.Labels {
+	if m, ok := r.bucketMetrics[lbs]; ok {
+		return m
+	}
+	m := make([]labels.Labels, 0, len(r.buckets)+1)
+	b := labels.NewBuilder(metric)
+	for _, bucket := range r.buckets {
+		b.Set(labels.BucketLabel, strconv.FormatFloat(bucket, 'f', -1, 64))
+		m = append(m, b.Labels())
+	}
+	b.Set(labels.BucketLabel, "+Inf")
+	m = append(m, b.Labels())
+	r.bucketMetrics[lbs] = m
+	return m
+}
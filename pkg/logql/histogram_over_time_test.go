@@ -0,0 +1,38 @@
+package logql
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramIterator(t *testing.T) {
+	var start, end int64 = 4, 4 // Instant query
+	it := newHistogramIterator(sampleIter(false), []float64{1, 2.5}, 3, 1, start, end, 0)
+
+	require.True(t, it.Next())
+	ts, value := it.At()
+	require.Equal(t, int64(0), ts)
+	require.Equal(t, promql.Vector{
+		{T: 0, F: 1, Metric: labels.FromStrings("app", "foo", "le", "1")},
+		{T: 0, F: 2, Metric: labels.FromStrings("app", "foo", "le", "2.5")},
+		{T: 0, F: 3, Metric: labels.FromStrings("app", "foo", "le", "+Inf")},
+	}, value.SampleVector())
+	require.False(t, it.Next())
+	require.NoError(t, it.Error())
+}
+
+func TestHistogramIterator_NegativeValues(t *testing.T) {
+	var start, end int64 = 4, 4 // Instant query
+	it := newHistogramIterator(sampleIter(true), []float64{-2, 0}, 3, 1, start, end, 0)
+
+	require.True(t, it.Next())
+	_, value := it.At()
+	require.Equal(t, promql.Vector{
+		{T: 0, F: 2, Metric: labels.FromStrings("app", "foo", "le", "-2")},
+		{T: 0, F: 3, Metric: labels.FromStrings("app", "foo", "le", "0")},
+		{T: 0, F: 3, Metric: labels.FromStrings("app", "foo", "le", "+Inf")},
+	}, value.SampleVector())
+}
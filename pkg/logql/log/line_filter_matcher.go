@@ -0,0 +1,134 @@
+package log
+
+// LineFilterMatcher is implemented by the stream pipelines and sample extractors which can tell from a summary of
+// a set of lines, like the n-grams of the lines of a chunk block, that none of them can match.
+type LineFilterMatcher interface {
+	// MatchesLineFilters returns false if none of the lines summarized by the checker can match the line filters
+	// the pipeline starts with. The checker tells if some of the lines may contain a string.
+	MatchesLineFilters(test Checker) bool
+}
+
+// lineFilterStage is the stage of a line filter, which keeps the filter so that it can be tested against a summary of lines.
+type lineFilterStage struct {
+	Stage
+	matcher Matcher // nil if the filter can't be tested against a summary of lines.
+}
+
+// NewLineFilterStage returns the stage running a line filter.
+func NewLineFilterStage(f Filterer) Stage {
+	s := f.ToStage()
+	if s == NoopStage {
+		return s
+	}
+	return lineFilterStage{Stage: s, matcher: testableMatcher(f)}
+}
+
+// testableMatcher returns a matcher which only tests the strings a line has to contain to match the filter,
+// or nil if there are none. The negated filters can't be tested: a summary only tells a string may be in the lines.
+func testableMatcher(f Filterer) Matcher {
+	switch f := f.(type) {
+	case *containsFilter, *containsAllFilter, containsAllFilter, equalFilter:
+		return f.(Matcher)
+	case wrapper:
+		if f.Filterer == nil {
+			return nil
+		}
+		return testableMatcher(f.Filterer)
+	case orFilter:
+		left, right := testableMatcher(f.left), testableMatcher(f.right)
+		if left == nil || right == nil {
+			return nil
+		}
+		return orMatcher{left, right}
+	case andFilter:
+		return newAndMatcher(testableMatcher(f.left), testableMatcher(f.right))
+	case andFilters:
+		matchers := make([]Matcher, 0, len(f.filters))
+		for _, filter := range f.filters {
+			matchers = append(matchers, testableMatcher(filter))
+		}
+		return newAndMatcher(matchers...)
+	default:
+		// the negated filters, the regexps which couldn't be simplified, the patterns and the IP filters.
+		return nil
+	}
+}
+
+type orMatcher struct {
+	left, right Matcher
+}
+
+func (m orMatcher) Matches(test Checker) bool {
+	return m.left.Matches(test) || m.right.Matches(test)
+}
+
+type andMatcher []Matcher
+
+// newAndMatcher returns a matcher testing all the given matchers, ignoring the nil ones.
+func newAndMatcher(matchers ...Matcher) Matcher {
+	var res andMatcher
+	for _, m := range matchers {
+		if m != nil {
+			res = append(res, m)
+		}
+	}
+	switch len(res) {
+	case 0:
+		return nil
+	case 1:
+		return res[0]
+	default:
+		return res
+	}
+}
+
+func (m andMatcher) Matches(test Checker) bool {
+	return matchesAll(m, test)
+}
+
+// leadingLineMatchers returns the testable matchers of the line filters the stages start with.
+// The label filters are skipped, they don't change the line.
+func leadingLineMatchers(stages []Stage) []Matcher {
+	var matchers []Matcher
+	for _, s := range stages {
+		switch s := s.(type) {
+		case lineFilterStage:
+			if s.matcher != nil {
+				matchers = append(matchers, s.matcher)
+			}
+		case LabelFilterer:
+		default:
+			return matchers
+		}
+	}
+	return matchers
+}
+
+func matchesAll(matchers []Matcher, test Checker) bool {
+	for _, m := range matchers {
+		if !m.Matches(test) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *streamPipeline) MatchesLineFilters(test Checker) bool {
+	return matchesAll(p.lineMatchers, test)
+}
+
+func (sp *filteringStreamPipeline) MatchesLineFilters(test Checker) bool {
+	// the filters only remove entries, so the entries not matching the pipeline can't match once filtered.
+	if m, ok := sp.pipeline.(LineFilterMatcher); ok {
+		return m.MatchesLineFilters(test)
+	}
+	return true
+}
+
+func (l *streamLineSampleExtractor) MatchesLineFilters(test Checker) bool {
+	return matchesAll(l.lineMatchers, test)
+}
+
+func (l *streamLabelSampleExtractor) MatchesLineFilters(test Checker) bool {
+	return matchesAll(l.lineMatchers, test)
+}
@@ -0,0 +1,185 @@
+package log
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// Supported formats of lookup tables.
+const (
+	LookupTableFormatCSV  = "csv"
+	LookupTableFormatJSON = "json"
+)
+
+// LookupTable is a static table used to enrich log lines with labels.
+// Each column of the table is a label name and each row a set of label values.
+type LookupTable struct {
+	columns []string
+	rows    [][]string
+
+	// the rows indexed by the values of a column, built once per column by the lookup stages.
+	mtx     sync.Mutex
+	indexes map[string]map[string][]labels.Label
+}
+
+// NewLookupTable creates a lookup table from the given columns and rows.
+func NewLookupTable(columns []string, rows [][]string) (*LookupTable, error) {
+	seen := make(map[string]struct{}, len(columns))
+	for _, c := range columns {
+		if !model.LabelName(c).IsValid() {
+			return nil, fmt.Errorf("invalid lookup table column name '%s'", c)
+		}
+		if _, ok := seen[c]; ok {
+			return nil, fmt.Errorf("duplicate lookup table column '%s'", c)
+		}
+		seen[c] = struct{}{}
+	}
+	for i, r := range rows {
+		if len(r) != len(columns) {
+			return nil, fmt.Errorf("lookup table row %d has %d values, expected %d", i+1, len(r), len(columns))
+		}
+	}
+	return &LookupTable{columns: columns, rows: rows}, nil
+}
+
+// ParseLookupTable parses a lookup table in the given format.
+func ParseLookupTable(format, data string) (*LookupTable, error) {
+	switch format {
+	case LookupTableFormatCSV:
+		return parseCSVLookupTable(data)
+	case LookupTableFormatJSON:
+		return parseJSONLookupTable(data)
+	default:
+		return nil, fmt.Errorf("unsupported lookup table format '%s'", format)
+	}
+}
+
+// parseCSVLookupTable parses a CSV lookup table. The first record is the
+// header holding the column names.
+func parseCSVLookupTable(data string) (*LookupTable, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("lookup table has no header")
+	}
+	return NewLookupTable(records[0], records[1:])
+}
+
+// parseJSONLookupTable parses a JSON lookup table made of an array of objects.
+// The columns are the union of the keys of all objects, missing values are empty.
+func parseJSONLookupTable(data string) (*LookupTable, error) {
+	var objects []map[string]string
+	if err := jsoniter.UnmarshalFromString(data, &objects); err != nil {
+		return nil, err
+	}
+	var columns []string
+	index := map[string]int{}
+	for _, o := range objects {
+		for k := range o {
+			if _, ok := index[k]; !ok {
+				index[k] = 0
+				columns = append(columns, k)
+			}
+		}
+	}
+	sort.Strings(columns)
+	for i, c := range columns {
+		index[c] = i
+	}
+	rows := make([][]string, 0, len(objects))
+	for _, o := range objects {
+		row := make([]string, len(columns))
+		for k, v := range o {
+			row[index[k]] = v
+		}
+		rows = append(rows, row)
+	}
+	return NewLookupTable(columns, rows)
+}
+
+// LookupStage adds the values of the matching row of a lookup table as labels.
+// The row is matched using the value of a label with the same name as one of
+// the columns of the table.
+type LookupStage struct {
+	on   string
+	rows map[string][]labels.Label
+}
+
+// NewLookupStage creates a stage matching rows of the table on the given label.
+// Rows with an empty value for this label are ignored, and when multiple rows
+// share the same value the first one is used.
+func NewLookupStage(table *LookupTable, on string) (*LookupStage, error) {
+	rows, err := table.index(on)
+	if err != nil {
+		return nil, err
+	}
+	return &LookupStage{on: on, rows: rows}, nil
+}
+
+// index returns the labels of the rows by value of the given column.
+// The index is built on first use and shared by all the stages matching on this column.
+func (t *LookupTable) index(on string) (map[string][]labels.Label, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if rows, ok := t.indexes[on]; ok {
+		return rows, nil
+	}
+
+	key := -1
+	for i, c := range t.columns {
+		if c == on {
+			key = i
+			break
+		}
+	}
+	if key < 0 {
+		return nil, fmt.Errorf("lookup table has no column '%s'", on)
+	}
+
+	rows := make(map[string][]labels.Label, len(t.rows))
+	for _, r := range t.rows {
+		if r[key] == "" {
+			continue
+		}
+		if _, ok := rows[r[key]]; ok {
+			continue
+		}
+		lbs := make([]labels.Label, 0, len(r)-1)
+		for i, v := range r {
+			if i == key || v == "" {
+				continue
+			}
+			lbs = append(lbs, labels.Label{Name: t.columns[i], Value: v})
+		}
+		rows[r[key]] = lbs
+	}
+	if t.indexes == nil {
+		t.indexes = map[string]map[string][]labels.Label{}
+	}
+	t.indexes[on] = rows
+	return rows, nil
+}
+
+func (l *LookupStage) Process(_ int64, line []byte, lbs *LabelsBuilder) ([]byte, bool) {
+	v, ok := lbs.Get(l.on)
+	if !ok {
+		return line, true
+	}
+	for _, lb := range l.rows[v] {
+		lbs.Set(ParsedLabel, lb.Name, lb.Value)
+	}
+	return line, true
+}
+
+func (l *LookupStage) RequiredLabelNames() []string { return []string{l.on} }
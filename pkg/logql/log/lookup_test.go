@@ -0,0 +1,144 @@
+package log
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseLookupTable(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		format string
+		data   string
+
+		want    *LookupTable
+		wantErr string
+	}{
+		{
+			"csv",
+			LookupTableFormatCSV,
+			"service,team\napi, platform\n\"web,frontend\",ui\n",
+			&LookupTable{
+				columns: []string{"service", "team"},
+				rows:    [][]string{{"api", "platform"}, {"web,frontend", "ui"}},
+			},
+			"",
+		},
+		{
+			"json",
+			LookupTableFormatJSON,
+			`[{"service":"api","team":"platform"},{"service":"web","owner":"bob"}]`,
+			&LookupTable{
+				columns: []string{"owner", "service", "team"},
+				rows:    [][]string{{"", "api", "platform"}, {"bob", "web", ""}},
+			},
+			"",
+		},
+		{
+			"csv invalid column",
+			LookupTableFormatCSV,
+			"service,team-name\napi,platform\n",
+			nil,
+			"invalid lookup table column name 'team-name'",
+		},
+		{
+			"csv duplicate column",
+			LookupTableFormatCSV,
+			"service,service\napi,platform\n",
+			nil,
+			"duplicate lookup table column 'service'",
+		},
+		{
+			"csv empty",
+			LookupTableFormatCSV,
+			"",
+			nil,
+			"lookup table has no header",
+		},
+		{
+			"unknown format",
+			"yaml",
+			"",
+			nil,
+			"unsupported lookup table format 'yaml'",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			table, err := ParseLookupTable(tc.format, tc.data)
+			if tc.wantErr != "" {
+				require.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, table)
+		})
+	}
+}
+
+func Test_LookupStage(t *testing.T) {
+	table, err := NewLookupTable(
+		[]string{"service", "team", "owner"},
+		[][]string{
+			{"api", "platform", "alice"},
+			{"web", "frontend", ""},
+			{"api", "other", "bob"},
+		},
+	)
+	require.NoError(t, err)
+
+	stage, err := NewLookupStage(table, "service")
+	require.NoError(t, err)
+	require.Equal(t, []string{"service"}, stage.RequiredLabelNames())
+
+	for _, tc := range []struct {
+		name string
+		lbs  labels.Labels
+		want labels.Labels
+	}{
+		{
+			"first matching row",
+			labels.FromStrings("app", "foo", "service", "api"),
+			labels.FromStrings("app", "foo", "owner", "alice", "service", "api", "team", "platform"),
+		},
+		{
+			"empty values are skipped",
+			labels.FromStrings("service", "web"),
+			labels.FromStrings("service", "web", "team", "frontend"),
+		},
+		{
+			"overrides existing labels",
+			labels.FromStrings("service", "web", "team", "unknown"),
+			labels.FromStrings("service", "web", "team", "frontend"),
+		},
+		{
+			"no matching row",
+			labels.FromStrings("service", "db"),
+			labels.FromStrings("service", "db"),
+		},
+		{
+			"no label",
+			labels.FromStrings("app", "foo"),
+			labels.FromStrings("app", "foo"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			lbs := NewBaseLabelsBuilder().ForLabels(tc.lbs, tc.lbs.Hash())
+			line, ok := stage.Process(0, []byte("line"), lbs)
+			require.True(t, ok)
+			require.Equal(t, []byte("line"), line)
+			require.Equal(t, tc.want, lbs.LabelsResult().Labels())
+		})
+	}
+
+	_, err = NewLookupStage(table, "pod")
+	require.EqualError(t, err, "lookup table has no column 'pod'")
+
+	// the index of the rows is built once per column.
+	other, err := NewLookupStage(table, "service")
+	require.NoError(t, err)
+	require.Equal(t, reflect.ValueOf(stage.rows).Pointer(), reflect.ValueOf(other.rows).Pointer())
+	require.Len(t, table.indexes, 1)
+}
@@ -50,6 +50,8 @@ type lineSampleExtractor struct {
 	Stage
 	LineExtractor
 
+	labelFilters     []Stage   // the label filters the stages start with
+	lineMatchers     []Matcher // the testable line filters the stages start with
 	baseBuilder      *BaseLabelsBuilder
 	streamExtractors map[uint64]StreamSampleExtractor
 }
@@ -62,6 +64,8 @@ func NewLineSampleExtractor(ex LineExtractor, stages []Stage, groups []string, w
 	return &lineSampleExtractor{
 		Stage:            s,
 		LineExtractor:    ex,
+		labelFilters:     leadingLabelFilters(stages),
+		lineMatchers:     leadingLineMatchers(stages),
 		baseBuilder:      NewBaseLabelsBuilderWithGrouping(groups, hints, without, noLabels),
 		streamExtractors: make(map[uint64]StreamSampleExtractor),
 	}, nil
@@ -76,6 +80,8 @@ func (l *lineSampleExtractor) ForStream(labels labels.Labels) StreamSampleExtrac
 	res := &streamLineSampleExtractor{
 		Stage:         l.Stage,
 		LineExtractor: l.LineExtractor,
+		labelFilters:  l.labelFilters,
+		lineMatchers:  l.lineMatchers,
 		builder:       l.baseBuilder.ForLabels(labels, hash),
 	}
 	l.streamExtractors[hash] = res
@@ -85,7 +91,9 @@ func (l *lineSampleExtractor) ForStream(labels labels.Labels) StreamSampleExtrac
 type streamLineSampleExtractor struct {
 	Stage
 	LineExtractor
-	builder *LabelsBuilder
+	labelFilters []Stage
+	lineMatchers []Matcher
+	builder      *LabelsBuilder
 }
 
 func (l *streamLineSampleExtractor) ReferencedStructuredMetadata() bool {
@@ -122,6 +130,8 @@ type labelSampleExtractor struct {
 	postFilter   Stage
 	labelName    string
 	conversionFn convertionFn
+	labelFilters []Stage   // the label filters the pre stages start with
+	lineMatchers []Matcher // the testable line filters the pre stages start with
 
 	baseBuilder      *BaseLabelsBuilder
 	streamExtractors map[uint64]StreamSampleExtractor
@@ -159,6 +169,8 @@ func LabelExtractorWithStages(
 		conversionFn:     convFn,
 		labelName:        labelName,
 		postFilter:       postFilter,
+		labelFilters:     leadingLabelFilters(preStages),
+		lineMatchers:     leadingLineMatchers(preStages),
 		baseBuilder:      NewBaseLabelsBuilderWithGrouping(groups, hints, without, noLabels),
 		streamExtractors: make(map[uint64]StreamSampleExtractor),
 	}, nil
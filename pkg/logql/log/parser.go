@@ -2,8 +2,10 @@ package log
 
 import (
 	"bytes"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"unicode/utf8"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/logql/log/jsonexpr"
 	"github.com/grafana/loki/v3/pkg/logql/log/logfmt"
 	"github.com/grafana/loki/v3/pkg/logql/log/pattern"
+	"github.com/grafana/loki/v3/pkg/logql/log/xmlexpr"
 	"github.com/grafana/loki/v3/pkg/logqlmodel"
 
 	"github.com/grafana/regexp"
@@ -33,6 +36,9 @@ var (
 	_ Stage = &JSONParser{}
 	_ Stage = &RegexpParser{}
 	_ Stage = &LogfmtParser{}
+	_ Stage = &DelimitedParser{}
+	_ Stage = &XMLParser{}
+	_ Stage = &XMLExpressionParser{}
 
 	trueBytes = []byte("true")
 
@@ -40,6 +46,8 @@ var (
 	errMissingCapture       = errors.New("at least one named capture must be supplied")
 	errFoundAllLabels       = errors.New("found all required labels")
 	errLabelDoesNotMatch    = errors.New("found a label with a matcher that didn't match")
+	errUnterminatedQuote    = errors.New("unterminated quoted field")
+	errUnexpectedQuote      = errors.New("unexpected character after quoted field")
 
 	// the rune error replacement is rejected by Prometheus hence replacing them with space.
 	removeInvalidUtf = func(r rune) rune {
@@ -428,6 +436,129 @@ func (l *PatternParser) Process(_ int64, line []byte, lbs *LabelsBuilder) ([]byt
 
 func (l *PatternParser) RequiredLabelNames() []string { return []string{} }
 
+type DelimitedParser struct {
+	sep     []byte
+	columns []string
+	buf     []byte // buffer used to unescape quoted fields
+}
+
+// NewDelimitedParser creates a parser that can extract labels from a line
+// of fields separated by sep, such as CSV or TSV. Each field is extracted
+// into the label of the column at the same position, fields of columns with
+// an empty name are skipped.
+// Like in RFC 4180, fields can be enclosed in double quotes to contain the
+// separator, and double quotes inside them are escaped by doubling them.
+func NewDelimitedParser(sep string, columns []string) (*DelimitedParser, error) {
+	if utf8.RuneCountInString(sep) != 1 || sep == `"` || sep == "\r" || sep == "\n" || sep == string(utf8.RuneError) {
+		return nil, fmt.Errorf("invalid separator %q", sep)
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("at least one column must be supplied")
+	}
+	seen := make(map[string]struct{}, len(columns))
+	for _, c := range columns {
+		if c == "" {
+			continue
+		}
+		if !model.LabelName(c).IsValid() {
+			return nil, fmt.Errorf("invalid column label name '%s'", c)
+		}
+		if _, ok := seen[c]; ok {
+			return nil, fmt.Errorf("duplicate column label name '%s'", c)
+		}
+		seen[c] = struct{}{}
+	}
+	return &DelimitedParser{
+		sep:     []byte(sep),
+		columns: columns,
+	}, nil
+}
+
+func (d *DelimitedParser) Process(_ int64, line []byte, lbs *LabelsBuilder) ([]byte, bool) {
+	parserHints := lbs.ParserLabelHints()
+	if parserHints.NoLabels() {
+		return line, true
+	}
+
+	rest := line
+	for i := 0; i < len(d.columns) && rest != nil; i++ {
+		var (
+			field []byte
+			err   error
+		)
+		field, rest, err = d.nextField(rest)
+		if err != nil {
+			addErrLabel(errDelimited, err, lbs)
+			if !parserHints.ShouldContinueParsingLine(logqlmodel.ErrorLabel, lbs) {
+				return line, false
+			}
+			return line, true
+		}
+
+		name := d.columns[i]
+		if name == "" || len(field) == 0 {
+			continue
+		}
+		if lbs.BaseHas(name) {
+			name = name + duplicateSuffix
+		}
+		if !parserHints.ShouldExtract(name) {
+			continue
+		}
+
+		if bytes.ContainsRune(field, utf8.RuneError) {
+			field = bytes.Map(removeInvalidUtf, field)
+		}
+		lbs.Set(ParsedLabel, name, string(field))
+		if !parserHints.ShouldContinueParsingLine(name, lbs) {
+			return line, false
+		}
+
+		if parserHints.AllRequiredExtracted() {
+			break
+		}
+	}
+	return line, true
+}
+
+// nextField returns the first field of line and the remaining of the line
+// after its separator, or nil if it is the last field.
+func (d *DelimitedParser) nextField(line []byte) ([]byte, []byte, error) {
+	if len(line) == 0 || line[0] != '"' {
+		i := bytes.Index(line, d.sep)
+		if i < 0 {
+			return line, nil, nil
+		}
+		return line[:i], line[i+len(d.sep):], nil
+	}
+
+	d.buf = d.buf[:0]
+	line = line[1:]
+	for {
+		i := bytes.IndexByte(line, '"')
+		if i < 0 {
+			return nil, nil, errUnterminatedQuote
+		}
+		d.buf = append(d.buf, line[:i]...)
+		line = line[i+1:]
+		// a doubled quote is an escaped quote.
+		if len(line) == 0 || line[0] != '"' {
+			break
+		}
+		d.buf = append(d.buf, '"')
+		line = line[1:]
+	}
+	if len(line) == 0 {
+		return d.buf, nil, nil
+	}
+	if !bytes.HasPrefix(line, d.sep) {
+		return nil, nil, errUnexpectedQuote
+	}
+	return d.buf, line[len(d.sep):], nil
+}
+
+func (d *DelimitedParser) RequiredLabelNames() []string { return []string{} }
+
 type LogfmtExpressionParser struct {
 	expressions map[string][]interface{}
 	dec         *logfmt.Decoder
@@ -655,6 +786,404 @@ func isValidJSONStart(data []byte) bool {
 
 func (j *JSONExpressionParser) RequiredLabelNames() []string { return []string{} }
 
+type XMLParser struct {
+	prefixBuffer []byte // buffer used to build element keys
+	prefixLens   []int  // length of the prefix of each open element
+	text         []byte // text of the current leaf element
+	lbs          *LabelsBuilder
+
+	reader      bytes.Reader
+	keys        internedStringSet
+	parserHints ParserHint
+}
+
+// NewXMLParser creates a log stage that can parse a xml log line and add the
+// text of its leaf elements and its attributes as labels. Nested element names
+// are joined with '_' like for the json parser.
+func NewXMLParser() *XMLParser {
+	return &XMLParser{
+		prefixBuffer: make([]byte, 0, 1024),
+		keys:         internedStringSet{},
+	}
+}
+
+func (x *XMLParser) Process(_ int64, line []byte, lbs *LabelsBuilder) ([]byte, bool) {
+	parserHints := lbs.ParserLabelHints()
+	if parserHints.NoLabels() {
+		return line, true
+	}
+	if !isValidXMLStart(line) {
+		addErrLabel(errXML, nil, lbs)
+		return line, true
+	}
+
+	// reset the state.
+	x.prefixBuffer = x.prefixBuffer[:0]
+	x.prefixLens = x.prefixLens[:0]
+	x.lbs = lbs
+	x.parserHints = parserHints
+	x.reader.Reset(line)
+
+	if err := x.parse(xml.NewDecoder(&x.reader)); err != nil {
+		if errors.Is(err, errFoundAllLabels) {
+			// Short-circuited
+			return line, true
+		}
+
+		if errors.Is(err, errLabelDoesNotMatch) {
+			// one of the label matchers does not match. The whole line can be thrown away
+			return line, false
+		}
+
+		addErrLabel(errXML, err, lbs)
+	}
+	return line, true
+}
+
+func (x *XMLParser) parse(dec *xml.Decoder) error {
+	// leaf tells if the current element has no child element so far.
+	leaf := false
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			if len(x.prefixLens) != 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			x.prefixLens = append(x.prefixLens, len(x.prefixBuffer))
+			if len(x.prefixBuffer) != 0 {
+				x.prefixBuffer = append(x.prefixBuffer, byte(jsonSpacer))
+			}
+			x.prefixBuffer = appendSanitized(x.prefixBuffer, unsafeGetBytes(t.Name.Local))
+			if !x.parserHints.ShouldExtractPrefix(unsafeGetString(x.prefixBuffer)) {
+				// none of the required labels are in this element, skip it.
+				x.popPrefix()
+				leaf = false
+				if err := skipXMLElement(dec); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, attr := range t.Attr {
+				if isXMLNamespaceDeclaration(attr.Name) {
+					continue
+				}
+				if err := x.parseLabelValue(unsafeGetBytes(attr.Name.Local), attr.Value); err != nil {
+					return err
+				}
+			}
+			leaf = true
+			x.text = x.text[:0]
+		case xml.CharData:
+			if leaf {
+				x.text = append(x.text, t...)
+			}
+		case xml.EndElement:
+			if len(x.prefixLens) == 0 {
+				return fmt.Errorf("unexpected end element </%s>", t.Name.Local)
+			}
+			if leaf {
+				if err := x.parseLabelValue(nil, string(bytes.TrimSpace(x.text))); err != nil {
+					return err
+				}
+			}
+			x.popPrefix()
+			leaf = false
+		}
+	}
+}
+
+func (x *XMLParser) popPrefix() {
+	x.prefixBuffer = x.prefixBuffer[:x.prefixLens[len(x.prefixLens)-1]]
+	x.prefixLens = x.prefixLens[:len(x.prefixLens)-1]
+}
+
+// parseLabelValue adds a label for the current element, or for one of its
+// attributes when name is not empty.
+func (x *XMLParser) parseLabelValue(name []byte, value string) error {
+	// snapshot the current prefix position
+	prefixLen := len(x.prefixBuffer)
+	if len(name) != 0 {
+		x.prefixBuffer = append(x.prefixBuffer, byte(jsonSpacer))
+		x.prefixBuffer = appendSanitized(x.prefixBuffer, name)
+	}
+	key, ok := x.keys.Get(x.prefixBuffer, func() (string, bool) {
+		if x.lbs.BaseHas(string(x.prefixBuffer)) {
+			x.prefixBuffer = append(x.prefixBuffer, duplicateSuffix...)
+		}
+		if !x.parserHints.ShouldExtract(string(x.prefixBuffer)) {
+			return "", false
+		}
+		return string(x.prefixBuffer), true
+	})
+
+	// reset the prefix position
+	x.prefixBuffer = x.prefixBuffer[:prefixLen]
+	if !ok {
+		return nil
+	}
+
+	x.lbs.Set(ParsedLabel, key, value)
+	if !x.parserHints.ShouldContinueParsingLine(key, x.lbs) {
+		return errLabelDoesNotMatch
+	}
+	if x.parserHints.AllRequiredExtracted() {
+		return errFoundAllLabels
+	}
+	return nil
+}
+
+func (x *XMLParser) RequiredLabelNames() []string { return []string{} }
+
+type XMLExpressionParser struct {
+	ids   []string
+	paths []xmlexpr.Path
+	keys  internedStringSet
+
+	reader  bytes.Reader
+	frames  []xmlExpressionFrame
+	found   []bool
+	depths  []int    // depth of the element whose text is captured for each expression, or -1
+	texts   [][]byte // text captured for each expression
+	pending int      // number of expressions not found yet
+}
+
+// xmlExpressionFrame holds the state of an open element.
+type xmlExpressionFrame struct {
+	// expressions with steps matching the path of the element.
+	alive []int
+	// number of children seen so far, in total and by local name.
+	children int
+	counts   map[string]int
+}
+
+// NewXMLExpressionParser creates a log stage that extracts the values matching
+// the given XPath-like expressions from a xml log line.
+// The first element or attribute matching an expression is used, and the
+// decoding of the line stops as soon as all of them are found.
+func NewXMLExpressionParser(expressions []LabelExtractionExpr) (*XMLExpressionParser, error) {
+	var ids []string
+	var paths []xmlexpr.Path
+	for _, exp := range expressions {
+		path, err := xmlexpr.Parse(exp.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse expression [%s]: %w", exp.Expression, err)
+		}
+
+		if !model.LabelName(exp.Identifier).IsValid() {
+			return nil, fmt.Errorf("invalid extracted label name '%s'", exp.Identifier)
+		}
+
+		ids = append(ids, exp.Identifier)
+		paths = append(paths, path)
+	}
+
+	return &XMLExpressionParser{
+		ids:    ids,
+		paths:  paths,
+		keys:   internedStringSet{},
+		found:  make([]bool, len(ids)),
+		depths: make([]int, len(ids)),
+		texts:  make([][]byte, len(ids)),
+	}, nil
+}
+
+func (x *XMLExpressionParser) Process(_ int64, line []byte, lbs *LabelsBuilder) ([]byte, bool) {
+	parserHints := lbs.ParserLabelHints()
+	if len(line) == 0 || parserHints.NoLabels() {
+		return line, true
+	}
+
+	if !isValidXMLStart(line) {
+		addErrLabel(errXML, nil, lbs)
+		return line, true
+	}
+
+	// reset the state, expressions not required by the query are not looked up.
+	x.frames = x.frames[:0]
+	root := x.pushFrame()
+	x.pending = 0
+	for i := range x.ids {
+		x.found[i] = !parserHints.ShouldExtract(x.key(i, lbs))
+		x.depths[i] = -1
+		if !x.found[i] {
+			root.alive = append(root.alive, i)
+			x.pending++
+		}
+	}
+
+	x.reader.Reset(line)
+	if err := x.parse(xml.NewDecoder(&x.reader), lbs); err != nil {
+		addErrLabel(errXML, err, lbs)
+	}
+
+	// Ensure there's a label for every value
+	for _, id := range x.ids {
+		if _, ok := lbs.Get(id); !ok {
+			lbs.Set(ParsedLabel, id, "")
+		}
+	}
+
+	return line, true
+}
+
+func (x *XMLExpressionParser) parse(dec *xml.Decoder, lbs *LabelsBuilder) error {
+	for x.pending > 0 {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			if len(x.frames) > 1 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		depth := len(x.frames) - 1
+		switch t := tok.(type) {
+		case xml.StartElement:
+			parent := &x.frames[depth]
+			parent.children++
+			parent.counts[t.Name.Local]++
+			position, count := parent.children, parent.counts[t.Name.Local]
+
+			child := x.pushFrame()
+			capturing := false
+			for _, i := range x.frames[depth].alive {
+				if x.found[i] {
+					continue
+				}
+				path := x.paths[i]
+				step := path.Steps[depth]
+				if !step.Matches(t.Name.Space, t.Name.Local) {
+					continue
+				}
+				if step.Position != 0 && (step.Local == xmlexpr.Wildcard && step.Position != position ||
+					step.Local != xmlexpr.Wildcard && step.Position != count) {
+					continue
+				}
+				switch {
+				case depth+1 < len(path.Steps):
+					child.alive = append(child.alive, i)
+				case path.Attr != "":
+					for _, attr := range t.Attr {
+						if attr.Name.Local == path.Attr && !isXMLNamespaceDeclaration(attr.Name) {
+							x.setLabel(i, attr.Value, lbs)
+							break
+						}
+					}
+				default:
+					x.depths[i] = depth + 1
+					x.texts[i] = x.texts[i][:0]
+					capturing = true
+				}
+			}
+			if x.pending == 0 {
+				return nil
+			}
+			if len(child.alive) == 0 && !capturing {
+				// no expression can match in this element, skip it.
+				x.frames = x.frames[:depth+1]
+				if err := skipXMLElement(dec); err != nil {
+					return err
+				}
+			}
+		case xml.CharData:
+			for i, d := range x.depths {
+				if d == depth {
+					x.texts[i] = append(x.texts[i], t...)
+				}
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				return fmt.Errorf("unexpected end element </%s>", t.Name.Local)
+			}
+			for i, d := range x.depths {
+				if d == depth {
+					x.depths[i] = -1
+					x.setLabel(i, string(bytes.TrimSpace(x.texts[i])), lbs)
+				}
+			}
+			x.frames = x.frames[:depth]
+		}
+	}
+	return nil
+}
+
+// pushFrame adds a frame for a new open element, reusing the memory of
+// previous frames.
+func (x *XMLExpressionParser) pushFrame() *xmlExpressionFrame {
+	if len(x.frames) < cap(x.frames) {
+		x.frames = x.frames[:len(x.frames)+1]
+	} else {
+		x.frames = append(x.frames, xmlExpressionFrame{counts: map[string]int{}})
+	}
+	f := &x.frames[len(x.frames)-1]
+	f.alive = f.alive[:0]
+	f.children = 0
+	clear(f.counts)
+	return f
+}
+
+func (x *XMLExpressionParser) key(i int, lbs *LabelsBuilder) string {
+	identifier := x.ids[i]
+	key, _ := x.keys.Get(unsafeGetBytes(identifier), func() (string, bool) {
+		if lbs.BaseHas(identifier) {
+			identifier = identifier + duplicateSuffix
+		}
+		return identifier, true
+	})
+	return key
+}
+
+func (x *XMLExpressionParser) setLabel(i int, value string, lbs *LabelsBuilder) {
+	lbs.Set(ParsedLabel, x.key(i, lbs), value)
+	x.found[i] = true
+	x.pending--
+}
+
+func (x *XMLExpressionParser) RequiredLabelNames() []string { return []string{} }
+
+func isValidXMLStart(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) != 0 && data[0] == '<'
+}
+
+// isXMLNamespaceDeclaration tells if the attribute declares a namespace.
+func isXMLNamespaceDeclaration(name xml.Name) bool {
+	return name.Space == "xmlns" || name.Space == "" && name.Local == "xmlns"
+}
+
+// skipXMLElement reads tokens until the end of the current element.
+// Unlike (*xml.Decoder).Skip it uses raw tokens which do not track namespaces.
+func skipXMLElement(dec *xml.Decoder) error {
+	depth := 1
+	for depth > 0 {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
+
 type UnpackParser struct {
 	lbsBuffer []string
 
@@ -43,6 +43,13 @@ var (
 	}`)
 
 	logfmtLine = []byte(`ts=2021-02-02T14:35:05.983992774Z caller=spanlogger.go:79 org_id=3677 traceID=2e5c7234b8640997 Ingester.TotalReached=15 Ingester.TotalChunksMatched=0 Ingester.TotalBatches=0`)
+
+	csvLine = []byte(`10.0.0.1,POST,"/rpc/v2/stage,old",204,30.001`)
+
+	tsvLine = []byte("10.0.0.1\tPOST\t/rpc/v2/stage\t204\t30.001")
+
+	// the line is truncated to check that parsers stop once the required labels are found.
+	xmlLine = []byte(`<request method="POST"><uri>/rpc/v2/stage</uri><response><status>204</status><latency>30.001</latency></response><body>`)
 )
 
 func Test_ParserHints(t *testing.T) {
@@ -224,6 +231,69 @@ func Test_ParserHints(t *testing.T) {
 			1,
 			`{app="nginx", message_message="foo"}`,
 		},
+		{
+			`sum by (method,app)(count_over_time({app="nginx"} | csv "ip","method","path","status","latency" | status = 204 [1m]))`,
+			csvLine,
+			true,
+			1,
+			`{app="nginx", method="POST"}`,
+		},
+		{
+			`sum by (path)(sum_over_time({app="nginx"} | csv "ip","method","path","status","latency" | unwrap latency [1m]))`,
+			csvLine,
+			true,
+			30.001,
+			`{path="/rpc/v2/stage,old"}`,
+		},
+		{
+			`sum by (method)(count_over_time({app="nginx"} | delimited sep="\t" "ip","method","path","status" | status != 204 [1m]))`,
+			tsvLine,
+			false,
+			0,
+			``,
+		},
+		{
+			`rate({app="nginx"} | delimited sep="\t" "ip","method","path","","latency" [1m])`,
+			tsvLine,
+			true,
+			1,
+			`{app="nginx", cluster="us-central-west", ip="10.0.0.1", latency="30.001", method="POST", path="/rpc/v2/stage"}`,
+		},
+		{
+			`sum by (request_method)(count_over_time({app="nginx"} | xml | request_response_status = 204 [1m]))`,
+			xmlLine,
+			true,
+			1,
+			`{request_method="POST"}`,
+		},
+		{
+			`sum by (request_uri)(sum_over_time({app="nginx"} | xml | unwrap request_response_latency [1m]))`,
+			xmlLine,
+			true,
+			30.001,
+			`{request_uri="/rpc/v2/stage"}`,
+		},
+		{
+			`sum by (method)(count_over_time({app="nginx"} | xml method="request/@method", status="request/response/status" | status != 204 [1m]))`,
+			xmlLine,
+			false,
+			0,
+			``,
+		},
+		{
+			`sum by (method)(count_over_time({app="nginx"} | xml method="request/@method", status="request/response/status" | __error__="" [1m]))`,
+			xmlLine,
+			true,
+			1,
+			`{method="POST"}`,
+		},
+		{
+			`sum(count_over_time({app="nginx"} | xml | __error__="" [1m]))`,
+			xmlLine,
+			false,
+			0,
+			``,
+		},
 	} {
 		tt := tt
 		t.Run(tt.expr, func(t *testing.T) {
@@ -1399,6 +1399,352 @@ func Test_PatternParser(t *testing.T) {
 	}
 }
 
+func Test_DelimitedParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		sep     string
+		columns []string
+		line    []byte
+		lbs     labels.Labels
+		want    labels.Labels
+	}{
+		{
+			"csv",
+			",",
+			[]string{"ip", "method", "path", "status"},
+			[]byte(`127.0.0.1,GET,/loki/api/v1/push,204`),
+			labels.FromStrings("foo", "bar"),
+			labels.FromStrings("foo", "bar",
+				"ip", "127.0.0.1",
+				"method", "GET",
+				"path", "/loki/api/v1/push",
+				"status", "204",
+			),
+		},
+		{
+			"tsv with skipped and missing columns",
+			"\t",
+			[]string{"ip", "", "path", "status", "size"},
+			[]byte("127.0.0.1\tGET\t/loki/api/v1/push\t204"),
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				"ip", "127.0.0.1",
+				"path", "/loki/api/v1/push",
+				"status", "204",
+			),
+		},
+		{
+			"quoted fields",
+			",",
+			[]string{"msg", "user", "status"},
+			[]byte(`"hello, ""world""",,"204"`),
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				"msg", `hello, "world"`,
+				"status", "204",
+			),
+		},
+		{
+			"extra fields and duplicate labels",
+			"|",
+			[]string{"method", "status"},
+			[]byte(`POST|204|1.238734ms`),
+			labels.FromStrings("method", "bar"),
+			labels.FromStrings("method", "bar",
+				"method_extracted", "POST",
+				"status", "204",
+			),
+		},
+		{
+			"unterminated quote",
+			",",
+			[]string{"method", "status"},
+			[]byte(`POST,"204`),
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				"method", "POST",
+				logqlmodel.ErrorLabel, errDelimited,
+				logqlmodel.ErrorDetailsLabel, errUnterminatedQuote.Error(),
+			),
+		},
+		{
+			"unexpected character after quote",
+			",",
+			[]string{"method", "status"},
+			[]byte(`"POST"x,204`),
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				logqlmodel.ErrorLabel, errDelimited,
+				logqlmodel.ErrorDetailsLabel, errUnexpectedQuote.Error(),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b := NewBaseLabelsBuilder().ForLabels(tt.lbs, tt.lbs.Hash())
+			b.Reset()
+			p, err := NewDelimitedParser(tt.sep, tt.columns)
+			require.NoError(t, err)
+			_, _ = p.Process(0, tt.line, b)
+			require.Equal(t, tt.want, b.LabelsResult().Labels())
+		})
+	}
+}
+
+func TestNewDelimitedParser(t *testing.T) {
+	for _, tc := range []struct {
+		sep     string
+		columns []string
+		err     string
+	}{
+		{",", []string{"a", "", "b"}, ""},
+		{"│", []string{"a"}, ""},
+		{"", []string{"a"}, `invalid separator ""`},
+		{";;", []string{"a"}, `invalid separator ";;"`},
+		{`"`, []string{"a"}, `invalid separator "\""`},
+		{"\n", []string{"a"}, `invalid separator "\n"`},
+		{",", nil, "at least one column must be supplied"},
+		{",", []string{"a-b"}, "invalid column label name 'a-b'"},
+		{",", []string{"a", "a"}, "duplicate column label name 'a'"},
+	} {
+		_, err := NewDelimitedParser(tc.sep, tc.columns)
+		if tc.err == "" {
+			require.NoError(t, err)
+			continue
+		}
+		require.EqualError(t, err, tc.err)
+	}
+}
+
+var testXMLLine = []byte(`<?xml version="1.0"?>
+<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event">
+  <System>
+    <Provider Name="Microsoft-Windows-Security-Auditing" Guid="{54849625}"/>
+    <EventID>4624</EventID>
+    <Level>0</Level>
+  </System>
+  <EventData>
+    <Data Name="SubjectUserName">alice</Data>
+    <Data Name="TargetUserName">bob &amp; co</Data>
+  </EventData>
+</Event>`)
+
+func Test_XMLParser(t *testing.T) {
+	tests := []struct {
+		name string
+		line []byte
+		lbs  labels.Labels
+		want labels.Labels
+	}{
+		{
+			"event",
+			testXMLLine,
+			labels.FromStrings("foo", "bar"),
+			labels.FromStrings("foo", "bar",
+				"Event_System_Provider_Name", "Microsoft-Windows-Security-Auditing",
+				"Event_System_Provider_Guid", "{54849625}",
+				"Event_System_Provider", "",
+				"Event_System_EventID", "4624",
+				"Event_System_Level", "0",
+				"Event_EventData_Data_Name", "TargetUserName",
+				"Event_EventData_Data", "bob & co",
+			),
+		},
+		{
+			"sanitized names and duplicate labels",
+			[]byte(`<log-entry level="info"><msg.text>hello</msg.text><ns:app>loki</ns:app></log-entry>`),
+			labels.FromStrings("log_entry_level", "debug"),
+			labels.FromStrings("log_entry_level", "debug",
+				"log_entry_level_extracted", "info",
+				"log_entry_msg_text", "hello",
+				"log_entry_app", "loki",
+			),
+		},
+		{
+			"not xml",
+			[]byte(`{"foo":"bar"}`),
+			labels.EmptyLabels(),
+			labels.FromStrings(logqlmodel.ErrorLabel, errXML),
+		},
+		{
+			"unexpected eof",
+			[]byte(`<a><b>foo</b>`),
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				"a_b", "foo",
+				logqlmodel.ErrorLabel, errXML,
+				logqlmodel.ErrorDetailsLabel, "unexpected EOF",
+			),
+		},
+		{
+			"syntax error",
+			[]byte(`<a><b foo></b></a>`),
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				logqlmodel.ErrorLabel, errXML,
+				logqlmodel.ErrorDetailsLabel, "XML syntax error on line 1: attribute name without = in element",
+			),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b := NewBaseLabelsBuilder().ForLabels(tt.lbs, tt.lbs.Hash())
+			b.Reset()
+			_, _ = NewXMLParser().Process(0, tt.line, b)
+			require.Equal(t, tt.want, b.LabelsResult().Labels())
+		})
+	}
+}
+
+func TestXMLExpressionParser(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        []byte
+		expressions []LabelExtractionExpr
+		lbs         labels.Labels
+		want        labels.Labels
+	}{
+		{
+			"elements and attributes",
+			testXMLLine,
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("event_id", "Event/System/EventID"),
+				NewLabelExtractionExpr("provider", "/Event/System/Provider/@Name"),
+			},
+			labels.FromStrings("foo", "bar"),
+			labels.FromStrings("foo", "bar",
+				"event_id", "4624",
+				"provider", "Microsoft-Windows-Security-Auditing",
+			),
+		},
+		{
+			"positions and wildcards",
+			testXMLLine,
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("first", "Event/EventData/Data"),
+				NewLabelExtractionExpr("second", "Event/*/Data[2]"),
+				NewLabelExtractionExpr("second_name", "Event/EventData/*[2]/@Name"),
+				NewLabelExtractionExpr("level", "*/*[1]/Level"),
+			},
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				"first", "alice",
+				"second", "bob & co",
+				"second_name", "TargetUserName",
+				"level", "0",
+			),
+		},
+		{
+			"first element having the attribute",
+			[]byte(`<a><b>1</b><b x="2"/><b x="3"/></a>`),
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("x", "a/b/@x"),
+			},
+			labels.EmptyLabels(),
+			labels.FromStrings("x", "2"),
+		},
+		{
+			"namespace prefixes",
+			[]byte(`<soap:Envelope xmlns:soap="urn:soap" xmlns:m="urn:m"><soap:Body><m:price>34.5</m:price><price>1</price></soap:Body></soap:Envelope>`),
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("price", "Envelope/Body/m:price"),
+				NewLabelExtractionExpr("unprefixed", "soap:Envelope/soap:Body/price"),
+				NewLabelExtractionExpr("missing", "soap:Envelope/m:Body"),
+			},
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				"price", "34.5",
+				"unprefixed", "34.5",
+				"missing", "",
+			),
+		},
+		{
+			"duplicate labels",
+			[]byte(`<a><app>loki</app></a>`),
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("app", "a/app"),
+			},
+			labels.FromStrings("app", "foo"),
+			labels.FromStrings("app", "foo", "app_extracted", "loki"),
+		},
+		{
+			"stops decoding once all values are found",
+			[]byte(`<a><b>1</b><c>`),
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("b", "a/b"),
+			},
+			labels.EmptyLabels(),
+			labels.FromStrings("b", "1"),
+		},
+		{
+			"stops decoding once all attributes are found",
+			[]byte(`<a x="1"><b>`),
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("x", "a/@x"),
+			},
+			labels.EmptyLabels(),
+			labels.FromStrings("x", "1"),
+		},
+		{
+			"not xml",
+			[]byte(`level=info`),
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("b", "a/b"),
+			},
+			labels.EmptyLabels(),
+			labels.FromStrings(logqlmodel.ErrorLabel, errXML),
+		},
+		{
+			"unexpected eof",
+			[]byte(`<a><c>1</c>`),
+			[]LabelExtractionExpr{
+				NewLabelExtractionExpr("b", "a/b"),
+			},
+			labels.EmptyLabels(),
+			labels.FromStrings(
+				"b", "",
+				logqlmodel.ErrorLabel, errXML,
+				logqlmodel.ErrorDetailsLabel, "unexpected EOF",
+			),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b := NewBaseLabelsBuilder().ForLabels(tt.lbs, tt.lbs.Hash())
+			b.Reset()
+			p, err := NewXMLExpressionParser(tt.expressions)
+			require.NoError(t, err)
+			_, _ = p.Process(0, tt.line, b)
+			require.Equal(t, tt.want, b.LabelsResult().Labels())
+		})
+	}
+}
+
+func TestNewXMLExpressionParser(t *testing.T) {
+	for _, tc := range []struct {
+		expression LabelExtractionExpr
+		err        string
+	}{
+		{NewLabelExtractionExpr("a", "a/b/@c"), ""},
+		{NewLabelExtractionExpr("a", "a//b"), "cannot parse expression [a//b]: empty step"},
+		{NewLabelExtractionExpr("a-b", "a/b"), "invalid extracted label name 'a-b'"},
+	} {
+		_, err := NewXMLExpressionParser([]LabelExtractionExpr{tc.expression})
+		if tc.err == "" {
+			require.NoError(t, err)
+			continue
+		}
+		require.EqualError(t, err, tc.err)
+	}
+}
+
 func BenchmarkJsonExpressionParser(b *testing.B) {
 	simpleJsn := []byte(`{
       "data": "Click Here",
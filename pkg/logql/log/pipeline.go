@@ -181,13 +181,15 @@ func NewPipeline(stages []Stage) Pipeline {
 }
 
 type streamPipeline struct {
-	stages     []Stage
-	builder    *LabelsBuilder
-	offsetsBuf []int
+	stages       []Stage
+	labelFilters []Stage   // the label filters the stages start with
+	lineMatchers []Matcher // the testable line filters the stages start with
+	builder      *LabelsBuilder
+	offsetsBuf   []int
 }
 
 func NewStreamPipeline(stages []Stage, labelsBuilder *LabelsBuilder) StreamPipeline {
-	return &streamPipeline{stages, labelsBuilder, make([]int, 0, 10)}
+	return &streamPipeline{stages, leadingLabelFilters(stages), leadingLineMatchers(stages), labelsBuilder, make([]int, 0, 10)}
 }
 
 func (p *pipeline) ForStream(labels labels.Labels) StreamPipeline {
@@ -1,9 +1,11 @@
 package log
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/grafana/regexp"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
 
@@ -208,6 +210,92 @@ func TestPipelineWithStructuredMetadata(t *testing.T) {
 	}
 }
 
+func TestPipeline_MatchesStructuredMetadata(t *testing.T) {
+	lbs := labels.FromStrings("foo", "bar")
+	p := NewPipeline([]Stage{
+		NewStringLabelFilter(labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")),
+		NewStringLabelFilter(labels.MustNewMatcher(labels.MatchEqual, "trace_id", "123")),
+		mustFilter(NewFilter("error", LineMatchEqual)).ToStage(),
+		NewStringLabelFilter(labels.MustNewMatcher(labels.MatchEqual, "user", "bob")),
+	})
+	sp := p.ForStream(lbs).(StructuredMetadataFilterer)
+
+	require.True(t, sp.MatchesStructuredMetadata(0, labels.FromStrings("trace_id", "123")...))
+	require.False(t, sp.MatchesStructuredMetadata(0, labels.FromStrings("trace_id", "456")...))
+	require.False(t, sp.MatchesStructuredMetadata(0))
+	// only the label filters before the first other stage are checked.
+	require.True(t, sp.MatchesStructuredMetadata(0, labels.FromStrings("trace_id", "123", "user", "alice")...))
+
+	_, _, matches := p.ForStream(lbs).Process(0, []byte("error"), labels.FromStrings("trace_id", "123", "user", "bob")...)
+	require.True(t, matches)
+
+	sp = NewPipeline([]Stage{
+		newMustLineFormatter("{{.foo}}"),
+		NewStringLabelFilter(labels.MustNewMatcher(labels.MatchEqual, "trace_id", "123")),
+	}).ForStream(lbs).(StructuredMetadataFilterer)
+	require.True(t, sp.MatchesStructuredMetadata(0, labels.FromStrings("trace_id", "456")...))
+}
+
+// linesChecker tells if some of its lines contain a string.
+type linesChecker []string
+
+func (c linesChecker) Test(s []byte, caseInsensitive bool, _ bool) bool {
+	if caseInsensitive {
+		return true
+	}
+	for _, l := range c {
+		if strings.Contains(l, string(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c linesChecker) TestRegex(_ *regexp.Regexp) bool { return true }
+
+func TestPipeline_MatchesLineFilters(t *testing.T) {
+	lbs := labels.FromStrings("foo", "bar")
+	lines := linesChecker{"level=error msg=timeout", "level=info msg=done"}
+	for _, tc := range []struct {
+		name    string
+		stages  []Stage
+		matches bool
+	}{
+		{"contains", []Stage{NewLineFilterStage(mustFilter(NewFilter("timeout", LineMatchEqual)))}, true},
+		{"doesn't contain", []Stage{NewLineFilterStage(mustFilter(NewFilter("panic", LineMatchEqual)))}, false},
+		{"case insensitive", []Stage{NewLineFilterStage(mustFilter(NewFilter("(?i)panic", LineMatchRegexp)))}, true},
+		{"simplified regexp", []Stage{NewLineFilterStage(mustFilter(NewFilter("panic|fatal", LineMatchRegexp)))}, false},
+		{"regexp", []Stage{NewLineFilterStage(mustFilter(NewFilter("pa.+ic", LineMatchRegexp)))}, true},
+		{"or", []Stage{NewLineFilterStage(ChainOrFilter(
+			mustFilter(NewFilter("panic", LineMatchEqual)),
+			mustFilter(NewFilter("fatal", LineMatchEqual)),
+		))}, false},
+		{"negated", []Stage{NewLineFilterStage(mustFilter(NewFilter("timeout", LineMatchNotEqual)))}, true},
+		{"and", []Stage{NewLineFilterStage(NewAndFilters([]Filterer{
+			mustFilter(NewFilter("timeout", LineMatchEqual)),
+			mustFilter(NewFilter("panic", LineMatchNotEqual)),
+			mustFilter(NewFilter("done", LineMatchEqual)),
+		}))}, true},
+		{"after label filter", []Stage{
+			NewStringLabelFilter(labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")),
+			NewLineFilterStage(mustFilter(NewFilter("panic", LineMatchEqual))),
+		}, false},
+		{"after line format", []Stage{
+			newMustLineFormatter("panic"),
+			NewLineFilterStage(mustFilter(NewFilter("panic", LineMatchEqual))),
+		}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sp := NewPipeline(tc.stages).ForStream(lbs)
+			require.Equal(t, tc.matches, sp.(LineFilterMatcher).MatchesLineFilters(lines))
+
+			ex, err := NewLineSampleExtractor(CountExtractor, tc.stages, nil, false, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.matches, ex.ForStream(lbs).(LineFilterMatcher).MatchesLineFilters(lines))
+		})
+	}
+}
+
 func TestFilteringPipeline(t *testing.T) {
 	tt := []struct {
 		name               string
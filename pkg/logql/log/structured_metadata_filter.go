@@ -0,0 +1,65 @@
+package log
+
+import (
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage/remote/otlptranslator/prometheus"
+)
+
+// StructuredMetadataFilterer is implemented by the stream pipelines and sample extractors which can tell
+// from the structured metadata of an entry alone that it doesn't match, so that its line doesn't need to be read.
+type StructuredMetadataFilterer interface {
+	// MatchesStructuredMetadata returns false if an entry with this timestamp and structured metadata can't match, whatever its line.
+	MatchesStructuredMetadata(ts int64, structuredMetadata ...labels.Label) bool
+}
+
+// leadingLabelFilters returns the label filters the stages start with.
+// They only see the stream labels and the structured metadata, so they can be run before the line is read.
+func leadingLabelFilters(stages []Stage) []Stage {
+	for i, s := range stages {
+		if _, ok := s.(LabelFilterer); !ok {
+			return stages[:i]
+		}
+	}
+	return stages
+}
+
+// matchesLabelFilters runs the label filters on the structured metadata, without any line.
+// The names of the structured metadata are normalized like the Process method of the caller does.
+func matchesLabelFilters(filters []Stage, builder *LabelsBuilder, ts int64, structuredMetadata []labels.Label, normalize bool) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	builder.Reset()
+	if normalize {
+		for i, lb := range structuredMetadata {
+			structuredMetadata[i].Name = prometheus.NormalizeLabel(lb.Name)
+		}
+	}
+	builder.Add(StructuredMetadataLabel, structuredMetadata...)
+	for _, f := range filters {
+		if _, ok := f.Process(ts, nil, builder); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *streamPipeline) MatchesStructuredMetadata(ts int64, structuredMetadata ...labels.Label) bool {
+	return matchesLabelFilters(p.labelFilters, p.builder, ts, structuredMetadata, true)
+}
+
+func (sp *filteringStreamPipeline) MatchesStructuredMetadata(ts int64, structuredMetadata ...labels.Label) bool {
+	// the filters only remove entries, so the entries not matching the pipeline can't match once filtered.
+	if f, ok := sp.pipeline.(StructuredMetadataFilterer); ok {
+		return f.MatchesStructuredMetadata(ts, structuredMetadata...)
+	}
+	return true
+}
+
+func (l *streamLineSampleExtractor) MatchesStructuredMetadata(ts int64, structuredMetadata ...labels.Label) bool {
+	return matchesLabelFilters(l.labelFilters, l.builder, ts, structuredMetadata, false)
+}
+
+func (l *streamLabelSampleExtractor) MatchesStructuredMetadata(ts int64, structuredMetadata ...labels.Label) bool {
+	return matchesLabelFilters(l.labelFilters, l.builder, ts, structuredMetadata, false)
+}
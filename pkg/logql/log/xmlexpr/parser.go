@@ -0,0 +1,139 @@
+// Package xmlexpr parses the XPath-like expressions used by the xml parser to
+// extract values from XML documents.
+//
+// An expression is a list of element steps separated by '/', starting at the
+// root element of the document and optionally ending with an attribute step:
+//
+//	Event/System/EventID
+//	/Event/System/Provider/@Name
+//	Event/EventData/Data[2]
+//	Event/*/Computer
+//
+// A step matches the elements with the given local name, or any element when
+// it is '*'. A name with a prefix such as 'ns:name' also requires the element
+// to use the same namespace prefix. An optional 1-based position selects the
+// n-th matching child of its parent.
+package xmlexpr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Wildcard is the name of a step matching any element.
+const Wildcard = "*"
+
+// Step matches child elements of the previous step.
+type Step struct {
+	// Prefix is the namespace prefix of the element, if any.
+	Prefix string
+	// Local is the local name of the element or Wildcard.
+	Local string
+	// Position is the 1-based position of the element among the matching
+	// children of its parent, 0 meaning any position.
+	Position int
+}
+
+// Matches tells if an element with the given prefix and local name matches the step.
+// The position of the element is not checked.
+func (s Step) Matches(prefix, local string) bool {
+	if s.Prefix != "" && s.Prefix != prefix {
+		return false
+	}
+	return s.Local == Wildcard || s.Local == local
+}
+
+// Path is a parsed expression.
+type Path struct {
+	Steps []Step
+	// Attr is the name of the attribute to extract from the element matched by
+	// the last step, or empty to extract the text of the element.
+	Attr string
+}
+
+// Parse parses the given expression.
+func Parse(expr string) (Path, error) {
+	var path Path
+	expr = strings.TrimPrefix(expr, "/")
+	if expr == "" {
+		return path, fmt.Errorf("empty expression")
+	}
+	parts := strings.Split(expr, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "@") {
+			if i != len(parts)-1 {
+				return Path{}, fmt.Errorf("attribute '%s' must be the last step", part)
+			}
+			if i == 0 {
+				return Path{}, fmt.Errorf("attribute '%s' must follow an element", part)
+			}
+			if !isName(part[1:]) {
+				return Path{}, fmt.Errorf("invalid attribute name '%s'", part[1:])
+			}
+			path.Attr = part[1:]
+			break
+		}
+		step, err := parseStep(part)
+		if err != nil {
+			return Path{}, err
+		}
+		path.Steps = append(path.Steps, step)
+	}
+	return path, nil
+}
+
+func parseStep(s string) (Step, error) {
+	var step Step
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		if !strings.HasSuffix(s, "]") {
+			return step, fmt.Errorf("missing closing bracket in '%s'", s)
+		}
+		pos, err := strconv.Atoi(s[i+1 : len(s)-1])
+		if err != nil || pos < 1 {
+			return step, fmt.Errorf("invalid position in '%s', expecting a positive integer", s)
+		}
+		step.Position = pos
+		s = s[:i]
+	}
+	if s == "" {
+		return step, fmt.Errorf("empty step")
+	}
+	if s == Wildcard {
+		step.Local = Wildcard
+		return step, nil
+	}
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		step.Prefix, s = s[:i], s[i+1:]
+		if !isName(step.Prefix) {
+			return step, fmt.Errorf("invalid element prefix '%s'", step.Prefix)
+		}
+	}
+	if !isName(s) {
+		return step, fmt.Errorf("invalid element name '%s'", s)
+	}
+	step.Local = s
+	return step, nil
+}
+
+// isName tells if s is a valid XML name without a namespace prefix.
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == utf8.RuneError {
+			return false
+		}
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package xmlexpr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       Path
+		err        string
+	}{
+		{
+			"single element",
+			"Event",
+			Path{Steps: []Step{{Local: "Event"}}},
+			"",
+		},
+		{
+			"nested element",
+			"Event/System/EventID",
+			Path{Steps: []Step{{Local: "Event"}, {Local: "System"}, {Local: "EventID"}}},
+			"",
+		},
+		{
+			"leading slash",
+			"/Event/System",
+			Path{Steps: []Step{{Local: "Event"}, {Local: "System"}}},
+			"",
+		},
+		{
+			"attribute",
+			"Event/System/Provider/@Name",
+			Path{Steps: []Step{{Local: "Event"}, {Local: "System"}, {Local: "Provider"}}, Attr: "Name"},
+			"",
+		},
+		{
+			"position",
+			"Event/EventData/Data[2]",
+			Path{Steps: []Step{{Local: "Event"}, {Local: "EventData"}, {Local: "Data", Position: 2}}},
+			"",
+		},
+		{
+			"wildcard and prefix",
+			"soap:Envelope/*[1]/m:price",
+			Path{Steps: []Step{{Prefix: "soap", Local: "Envelope"}, {Local: Wildcard, Position: 1}, {Prefix: "m", Local: "price"}}},
+			"",
+		},
+		{
+			"names with dashes and dots",
+			"log-entry/request.id",
+			Path{Steps: []Step{{Local: "log-entry"}, {Local: "request.id"}}},
+			"",
+		},
+		{
+			"empty",
+			"",
+			Path{},
+			"empty expression",
+		},
+		{
+			"empty step",
+			"Event//EventID",
+			Path{},
+			"empty step",
+		},
+		{
+			"attribute only",
+			"@Name",
+			Path{},
+			"attribute '@Name' must follow an element",
+		},
+		{
+			"attribute not last",
+			"Event/@Name/System",
+			Path{},
+			"attribute '@Name' must be the last step",
+		},
+		{
+			"invalid element name",
+			"Event/1System",
+			Path{},
+			"invalid element name '1System'",
+		},
+		{
+			"invalid position",
+			"Event/Data[0]",
+			Path{},
+			"invalid position in 'Data[0]', expecting a positive integer",
+		},
+		{
+			"missing closing bracket",
+			"Event/Data[1",
+			Path{},
+			"missing closing bracket in 'Data[1'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := Parse(tt.expression)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, path)
+		})
+	}
+}
+
+func TestStepMatches(t *testing.T) {
+	require.True(t, Step{Local: "a"}.Matches("", "a"))
+	require.True(t, Step{Local: "a"}.Matches("ns", "a"))
+	require.False(t, Step{Local: "a"}.Matches("", "b"))
+	require.True(t, Step{Prefix: "ns", Local: "a"}.Matches("ns", "a"))
+	require.False(t, Step{Prefix: "ns", Local: "a"}.Matches("", "a"))
+	require.True(t, Step{Local: Wildcard}.Matches("ns", "b"))
+}
@@ -127,6 +127,9 @@ func RecordRangeAndInstantQueryMetrics(
 
 	if result != nil && result.Type() == logqlmodel.ValueTypeStreams {
 		returnedLines = int(result.(logqlmodel.Streams).Lines())
+	} else if result == nil && queryType != QueryTypeMetric {
+		// streamed log queries have no result, their entries are only counted in the statistics.
+		returnedLines = int(stats.Summary.TotalEntriesReturned)
 	}
 
 	var (
@@ -59,6 +59,10 @@ func removeLineformat(expr syntax.SampleExpr) {
 					found = true
 					break
 				}
+				if _, ok := pipelineExpr.MultiStages[j].(*syntax.DelimitedParserExpr); ok {
+					found = true
+					break
+				}
 				if _, ok := pipelineExpr.MultiStages[j].(*syntax.LineFilterExpr); ok {
 					found = true
 					break
@@ -67,6 +71,10 @@ func removeLineformat(expr syntax.SampleExpr) {
 					found = true
 					break
 				}
+				if _, ok := pipelineExpr.MultiStages[j].(*syntax.XMLExpressionParser); ok {
+					found = true
+					break
+				}
 				if _, ok := pipelineExpr.MultiStages[j].(*syntax.LogfmtExpressionParser); ok {
 					found = true
 					break
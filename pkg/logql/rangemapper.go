@@ -32,6 +32,7 @@ var splittableRangeVectorOp = map[string]struct{}{
 	syntax.OpRangeTypeSum:       {},
 	syntax.OpRangeTypeMax:       {},
 	syntax.OpRangeTypeMin:       {},
+	syntax.OpRangeTypeHistogram: {},
 }
 
 // RangeMapper is used to rewrite LogQL sample expressions into multiple
@@ -175,6 +176,11 @@ func (m RangeMapper) Map(expr syntax.SampleExpr, vectorAggrPushdown *syntax.Vect
 		}
 		e.Left = lhsMapped
 		return e, nil
+	case *syntax.SubqueryAggregationExpr:
+		// Subqueries are not split by range, since each of their steps
+		// evaluates the inner expression over the whole range. Whether the
+		// inner expression can be sharded is decided by the ShardMapper.
+		return e, nil
 	case *syntax.LiteralExpr:
 		return e, nil
 	case *syntax.VectorExpr:
@@ -517,6 +523,19 @@ func (m RangeMapper) mapRangeAggregationExpr(expr *syntax.RangeAggregationExpr,
 		return m.vectorAggrWithRangeDownstreams(expr, vectorAggrPushdown, syntax.OpTypeMax, rangeInterval, recorder)
 	case syntax.OpRangeTypeMin:
 		return m.vectorAggrWithRangeDownstreams(expr, vectorAggrPushdown, syntax.OpTypeMin, rangeInterval, recorder)
+	case syntax.OpRangeTypeHistogram:
+		// bucket counts of the split ranges add up to the bucket counts of the full range
+		// histogram_over_time(0.5,{app="foo"} | unwrap bar [2m])
+		// => sum without (histogram_over_time(0.5,{app="foo"} | unwrap bar [1m]) ++ histogram_over_time(0.5,{app="foo"} | unwrap bar [1m] offset 1m))
+		var downstream syntax.SampleExpr = expr
+		if vectorAggrPushdown != nil && vectorAggrPushdown.Operation == syntax.OpTypeSum {
+			downstream = vectorAggrPushdown
+		}
+		return &syntax.VectorAggregationExpr{
+			Left:      m.mapConcatSampleExpr(downstream, rangeInterval, recorder),
+			Grouping:  histogramMergeGrouping(expr.Grouping),
+			Operation: syntax.OpTypeSum,
+		}
 	case syntax.OpRangeTypeRate:
 		if labelExtractor && vectorAggrPushdown.Operation != syntax.OpTypeSum {
 			return expr
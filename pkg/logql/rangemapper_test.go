@@ -205,6 +205,24 @@ func Test_SplitRangeVectorMapping(t *testing.T) {
 			)`,
 			3,
 		},
+		{
+			`histogram_over_time(0.5, 1, {app="foo"} | unwrap bar [3m])`,
+			`sum without () (
+				downstream<histogram_over_time(0.5,1,{app="foo"} | unwrap bar [1m] offset 2m0s), shard=<nil>>
+				++ downstream<histogram_over_time(0.5,1,{app="foo"} | unwrap bar [1m] offset 1m0s), shard=<nil>>
+				++ downstream<histogram_over_time(0.5,1,{app="foo"} | unwrap bar [1m]), shard=<nil>>
+			)`,
+			3,
+		},
+		{
+			`histogram_over_time(0.5, 1, {app="foo"} | unwrap bar [3m]) by (baz)`,
+			`sum by (baz, le) (
+				downstream<histogram_over_time(0.5,1,{app="foo"} | unwrap bar [1m] offset 2m0s) by (baz), shard=<nil>>
+				++ downstream<histogram_over_time(0.5,1,{app="foo"} | unwrap bar [1m] offset 1m0s) by (baz), shard=<nil>>
+				++ downstream<histogram_over_time(0.5,1,{app="foo"} | unwrap bar [1m]) by (baz), shard=<nil>>
+			)`,
+			3,
+		},
 		{
 			`max_over_time({app="foo"} | unwrap bar [3m])`,
 			`max without () (
@@ -1844,6 +1862,16 @@ func Test_SplitRangeVectorMapping_Noop(t *testing.T) {
 			`sum(avg_over_time({app="foo"} | unwrap bar[3m]))`,
 		},
 
+		// should be noop if subquery
+		{
+			`max_over_time(sum(rate({app="foo"}[3m]))[1h:5m])`,
+			`max_over_time(sum(rate({app="foo"}[3m]))[1h:5m])`,
+		},
+		{
+			`sum(bytes_over_time({app="foo"}[3m])) / max_over_time(sum(rate({app="foo"}[3m]))[1h:5m])`,
+			`(sum(bytes_over_time({app="foo"}[3m])) / max_over_time(sum(rate({app="foo"}[3m]))[1h:5m]))`,
+		},
+
 		// should be noop if range interval is lower or equal to split interval (1m)
 		{
 			`bytes_over_time({app="foo"}[1m])`,
@@ -6,6 +6,7 @@ import (
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/model/labels"
 
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/index"
@@ -94,6 +95,8 @@ func (m ShardMapper) Map(expr syntax.Expr, r *downstreamRecorder, topLevel bool)
 		return m.mapLabelReplaceExpr(e, r, topLevel)
 	case *syntax.RangeAggregationExpr:
 		return m.mapRangeAggregationExpr(e, r, topLevel)
+	case *syntax.SubqueryAggregationExpr:
+		return m.mapSubqueryAggregationExpr(e, r, topLevel)
 	case *syntax.BinOpExpr:
 		return m.mapBinOpExpr(e, r, topLevel)
 	default:
@@ -333,6 +336,33 @@ func (m ShardMapper) mapLabelReplaceExpr(expr *syntax.LabelReplaceExpr, r *downs
 	return &cpy, bytesPerShard, nil
 }
 
+func (m ShardMapper) mapSubqueryAggregationExpr(expr *syntax.SubqueryAggregationExpr, r *downstreamRecorder, topLevel bool) (syntax.SampleExpr, uint64, error) {
+	// If the series of the inner expression do not span multiple shards,
+	// the whole subquery can be evaluated on each shard and concatenated.
+	if expr.Shardable(topLevel) {
+		return m.mapSampleExpr(expr, r)
+	}
+
+	// Otherwise, the inner expression may still be sharded and merged on the
+	// frontend, where the subquery is evaluated on top of it.
+	subMapped, bytesPerShard, err := m.Map(expr.Left.Left, r, false)
+	if err != nil {
+		return nil, 0, err
+	}
+	if isNoOp(expr.Left.Left, subMapped) {
+		return noOp(expr, m.shards.Resolver())
+	}
+	sampleExpr, ok := subMapped.(syntax.SampleExpr)
+	if !ok {
+		return nil, 0, badASTMapping(subMapped)
+	}
+	subquery := *expr.Left
+	subquery.Left = sampleExpr
+	cpy := *expr
+	cpy.Left = &subquery
+	return &cpy, bytesPerShard, nil
+}
+
 // These functions require a different merge strategy than the default
 // concatenation.
 // This is because the same label sets may exist on multiple shards when label-reducing parsing is applied or when
@@ -451,6 +481,22 @@ func (m ShardMapper) mapRangeAggregationExpr(expr *syntax.RangeAggregationExpr,
 			Op:         syntax.OpTypeDiv,
 		}, bytesPerShard, nil
 
+	case syntax.OpRangeTypeHistogram:
+		potentialConflict := syntax.ReducesLabels(expr)
+		if !potentialConflict && (expr.Grouping == nil || expr.Grouping.Noop()) {
+			return m.mapSampleExpr(expr, r)
+		}
+
+		// bucket counts are summable, so the per shard histograms are merged
+		// with a sum which must keep the bucket label.
+		// histogram_over_time(_) by (foo) -> sum by (foo, le) (histogram_over_time(_) by (foo) ++ ...)
+		mapped, bytes, err := m.mapSampleExpr(expr, r)
+		return &syntax.VectorAggregationExpr{
+			Left:      mapped,
+			Grouping:  histogramMergeGrouping(expr.Grouping),
+			Operation: syntax.OpTypeSum,
+		}, bytes, err
+
 	case syntax.OpRangeTypeQuantile:
 		if !m.quantileOverTimeSharding {
 			return noOp(expr, m.shards.Resolver())
@@ -565,6 +611,25 @@ func (m ShardMapper) mapRangeAggregationExpr(expr *syntax.RangeAggregationExpr,
 	}
 }
 
+// histogramMergeGrouping returns the grouping of the vector aggregation used to
+// merge histogram_over_time results. Range aggregation groupings default to
+// `without ()` and the bucket label added after the grouping is always preserved.
+func histogramMergeGrouping(g *syntax.Grouping) *syntax.Grouping {
+	if g == nil {
+		return &syntax.Grouping{Without: true}
+	}
+	merged := &syntax.Grouping{Without: g.Without}
+	for _, l := range g.Groups {
+		if l != labels.BucketLabel {
+			merged.Groups = append(merged.Groups, l)
+		}
+	}
+	if !g.Without {
+		merged.Groups = append(merged.Groups, labels.BucketLabel)
+	}
+	return merged
+}
+
 func noOp[E syntax.Expr](expr E, shards ShardResolver) (E, uint64, error) {
 	exprStats, err := shards.GetStats(expr)
 	if err != nil {
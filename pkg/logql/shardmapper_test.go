@@ -455,11 +455,69 @@ func TestMappingStrings(t *testing.T) {
 			in:  `sum(count_over_time({a=~".+"}[1s]) * ignoring () count_over_time({a=~".+"}[1s]))`,
 			out: `sum(downstream<sum((count_over_time({a=~".+"}[1s])*count_over_time({a=~".+"}[1s]))),shard=0_of_2>++downstream<sum((count_over_time({a=~".+"}[1s])*count_over_time({a=~".+"}[1s]))),shard=1_of_2>)`,
 		},
+		{
+			in: `histogram_over_time(0.5, 1, {foo="bar"} | unwrap latency [5m])`,
+			out: `downstream<histogram_over_time(0.5,1,{foo="bar"}|unwraplatency[5m]),shard=0_of_2>
+					++ downstream<histogram_over_time(0.5,1,{foo="bar"}|unwraplatency[5m]),shard=1_of_2>`,
+		},
+		{
+			// bucket counts are summed across shards while keeping the bucket label
+			in: `histogram_over_time(0.5, 1, {foo="bar"} | unwrap latency [5m]) by (cluster)`,
+			out: `sum by (cluster, le) (
+				downstream<histogram_over_time(0.5,1,{foo="bar"}|unwraplatency[5m])by(cluster),shard=0_of_2>
+				++ downstream<histogram_over_time(0.5,1,{foo="bar"}|unwraplatency[5m])by(cluster),shard=1_of_2>
+			)`,
+		},
+		{
+			in: `histogram_over_time(0.5, 1, {foo="bar"} | unwrap latency [5m]) without (le, pod)`,
+			out: `sum without (pod) (
+				downstream<histogram_over_time(0.5,1,{foo="bar"}|unwraplatency[5m])without(le,pod),shard=0_of_2>
+				++ downstream<histogram_over_time(0.5,1,{foo="bar"}|unwraplatency[5m])without(le,pod),shard=1_of_2>
+			)`,
+		},
 		{
 			// shard the count since there is no label reduction in children
 			in:  `count by (foo) (rate({job="bar"}[1m]))`,
 			out: `sumby(foo)(downstream<countby(foo)(rate({job="bar"}[1m])),shard=0_of_2>++downstream<countby(foo)(rate({job="bar"}[1m])),shard=1_of_2>)`,
 		},
+		{
+			// ranges using the @ modifier are not sharded
+			in: `sum(rate({foo="bar"}[5m])) / sum(rate({foo="bar"}[5m] offset 1w @ end()))`,
+			out: `(
+				sum(
+					downstream<sum(rate({foo="bar"}[5m])),shard=0_of_2>
+					++ downstream<sum(rate({foo="bar"}[5m])),shard=1_of_2>
+				)
+				/ downstream<sum(rate({foo="bar"}[5m] offset 168h0m0s @ end())),shard=<nil>>
+			)`,
+		},
+		{
+			// subqueries over range aggregations are sharded as a whole
+			in: `max_over_time(rate({foo="bar"}[5m])[1h:5m])`,
+			out: `downstream<max_over_time(rate({foo="bar"}[5m])[1h:5m]),shard=0_of_2>
+				++ downstream<max_over_time(rate({foo="bar"}[5m])[1h:5m]),shard=1_of_2>`,
+		},
+		{
+			in: `sum(max_over_time(rate({foo="bar"}[5m])[1h:5m]))`,
+			out: `sum(
+				downstream<sum(max_over_time(rate({foo="bar"}[5m])[1h:5m])),shard=0_of_2>
+				++ downstream<sum(max_over_time(rate({foo="bar"}[5m])[1h:5m])),shard=1_of_2>
+			)`,
+		},
+		{
+			// subqueries over label reducing expressions shard the inner expression
+			in: `max_over_time(sum by (foo) (rate({foo="bar"}[5m]))[1h:5m] offset 1h)`,
+			out: `max_over_time(
+				sum by (foo) (
+					downstream<sum by (foo) (rate({foo="bar"}[5m])),shard=0_of_2>
+					++ downstream<sum by (foo) (rate({foo="bar"}[5m])),shard=1_of_2>
+				)[1h:5m] offset 1h0m0s
+			)`,
+		},
+		{
+			in:  `max_over_time(rate({foo="bar"}[5m] @ end())[1h:5m])`,
+			out: `max_over_time(rate({foo="bar"}[5m] @ end())[1h:5m])`,
+		},
 		{
 			// don't shard the count since there is label reduction in children
 			in:  `count by (foo) (sum by (foo, bar) (rate({job="bar"}[1m])))`,
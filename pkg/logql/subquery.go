@@ -0,0 +1,139 @@
+package logql
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/grafana/loki/v3/pkg/iter"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+)
+
+// ParamsWithSubquery overrides the time range and step of the query with the
+// ones used to evaluate the inner expression of a subquery.
+type ParamsWithSubquery struct {
+	Params
+	StartOverride time.Time
+	EndOverride   time.Time
+	StepOverride  time.Duration
+}
+
+// Start returns the start of the subquery.
+func (p ParamsWithSubquery) Start() time.Time { return p.StartOverride }
+
+// End returns the end of the subquery.
+func (p ParamsWithSubquery) End() time.Time { return p.EndOverride }
+
+// Step returns the step of the subquery.
+func (p ParamsWithSubquery) Step() time.Duration { return p.StepOverride }
+
+// paramsForSubquery returns the params used to evaluate the inner expression
+// of the given subquery. Like in Prometheus, the steps of the subquery are
+// aligned to multiples of its step so that they do not depend on the time range
+// of the query.
+func paramsForSubquery(q Params, e *syntax.SubqueryExpr) Params {
+	step := e.Step.Nanoseconds()
+	// the lower bound of the range is not inclusive.
+	start := alignDown(q.Start().Add(-e.Offset).Add(-e.Range).UnixNano(), step) + step
+	end := alignDown(q.End().Add(-e.Offset).UnixNano(), step)
+	return ParamsWithSubquery{
+		Params:        q,
+		StartOverride: time.Unix(0, start),
+		EndOverride:   time.Unix(0, end),
+		StepOverride:  e.Step,
+	}
+}
+
+// alignDown returns the largest multiple of step lower than or equal to ts.
+func alignDown(ts, step int64) int64 {
+	m := ts % step
+	if m < 0 {
+		m += step
+	}
+	return ts - m
+}
+
+// SubqueryAggEvaluator aggregates the samples returned by the evaluator of
+// the inner expression of a subquery over the range of the subquery.
+type SubqueryAggEvaluator struct {
+	*RangeVectorEvaluator
+
+	expr *syntax.SubqueryAggregationExpr
+	next StepEvaluator
+}
+
+func newSubqueryAggEvaluator(
+	ctx context.Context,
+	evFactory SampleEvaluatorFactory,
+	expr *syntax.SubqueryAggregationExpr,
+	q Params,
+) (*SubqueryAggEvaluator, error) {
+	next, err := evFactory.NewStepEvaluator(ctx, evFactory, expr.Left.Left, paramsForSubquery(q, expr.Left))
+	if err != nil {
+		return nil, err
+	}
+	it, err := newRangeVectorIterator(
+		iter.NewPeekingSampleIterator(&stepEvaluatorSampleIterator{next: next}),
+		// the range aggregation is only used to pick the aggregator, which
+		// does not depend on the log range for the operations supported by
+		// subqueries.
+		&syntax.RangeAggregationExpr{Operation: expr.Operation, Params: expr.Params},
+		expr.Left.Range.Nanoseconds(),
+		q.Step().Nanoseconds(),
+		q.Start().UnixNano(), q.End().UnixNano(), expr.Left.Offset.Nanoseconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &SubqueryAggEvaluator{
+		RangeVectorEvaluator: &RangeVectorEvaluator{iter: it},
+		expr:                 expr,
+		next:                 next,
+	}, nil
+}
+
+func (e *SubqueryAggEvaluator) Explain(parent Node) {
+	b := parent.Childf("[%s, %s:%s] SubqueryAgg", e.expr.Operation, e.expr.Left.Range, e.expr.Left.Step)
+	e.next.Explain(b)
+}
+
+// stepEvaluatorSampleIterator iterates over the samples of each step of a
+// step evaluator. Since the steps are in time order, so are the samples.
+type stepEvaluatorSampleIterator struct {
+	next StepEvaluator
+
+	vec promql.Vector
+	cur logproto.Sample
+	lbs string
+}
+
+func (it *stepEvaluatorSampleIterator) Next() bool {
+	for len(it.vec) == 0 {
+		ok, _, r := it.next.Next()
+		if !ok {
+			return false
+		}
+		it.vec = r.SampleVector()
+	}
+	s := it.vec[0]
+	it.vec = it.vec[1:]
+	it.lbs = s.Metric.String()
+	it.cur = logproto.Sample{
+		Timestamp: s.T * int64(time.Millisecond),
+		Value:     s.F,
+		Hash:      s.Metric.Hash(),
+	}
+	return true
+}
+
+func (it *stepEvaluatorSampleIterator) At() logproto.Sample { return it.cur }
+
+func (it *stepEvaluatorSampleIterator) Labels() string { return it.lbs }
+
+func (it *stepEvaluatorSampleIterator) StreamHash() uint64 { return it.cur.Hash }
+
+func (it *stepEvaluatorSampleIterator) Err() error { return it.next.Error() }
+
+func (it *stepEvaluatorSampleIterator) Close() error { return it.next.Close() }
@@ -573,7 +573,7 @@ func (e *LineFilterExpr) Stage() (log.Stage, error) {
 	if err != nil {
 		return nil, err
 	}
-	return f.ToStage(), nil
+	return log.NewLineFilterStage(f), nil
 }
 
 type LogfmtParserExpr struct {
@@ -672,6 +672,8 @@ func (e *LabelParserExpr) Stage() (log.Stage, error) {
 		return log.NewUnpackParser(), nil
 	case OpParserTypePattern:
 		return log.NewPatternParser(e.Param)
+	case OpParserTypeXML:
+		return log.NewXMLParser(), nil
 	default:
 		return nil, fmt.Errorf("unknown parser operator: %s", e.Op)
 	}
@@ -692,6 +694,71 @@ func (e *LabelParserExpr) String() string {
 	return sb.String()
 }
 
+// DelimitedParserExpr extracts the fields of lines such as CSV or TSV records
+// into labels.
+type DelimitedParserExpr struct {
+	Op      string
+	Sep     string
+	Columns []string
+	implicit
+}
+
+func newDelimitedParserExpr(op, sep string, columns []string) *DelimitedParserExpr {
+	// If we fail here, the error will be caught by the yacc parser.
+	if _, err := log.NewDelimitedParser(sep, columns); err != nil {
+		panic(logqlmodel.NewParseError(fmt.Sprintf("invalid %s parser: %s", op, err.Error()), 0, 0))
+	}
+
+	return &DelimitedParserExpr{
+		Op:      op,
+		Sep:     sep,
+		Columns: columns,
+	}
+}
+
+// mustNewDelimitedSeparator returns the separator given to the delimited parser
+// using the `sep` parameter.
+func mustNewDelimitedSeparator(name, value string) string {
+	if name != OpDelimitedSep {
+		panic(logqlmodel.NewParseError(fmt.Sprintf("invalid delimited parser parameter %s, expecting %s", name, OpDelimitedSep), 0, 0))
+	}
+	return value
+}
+
+func (*DelimitedParserExpr) isStageExpr() {}
+
+func (e *DelimitedParserExpr) Shardable(_ bool) bool { return true }
+
+func (e *DelimitedParserExpr) Walk(f WalkFn) { f(e) }
+
+func (e *DelimitedParserExpr) Accept(v RootVisitor) { v.VisitDelimitedParser(e) }
+
+func (e *DelimitedParserExpr) Stage() (log.Stage, error) {
+	return log.NewDelimitedParser(e.Sep, e.Columns)
+}
+
+func (e *DelimitedParserExpr) String() string {
+	var sb strings.Builder
+	sb.WriteString(OpPipe)
+	sb.WriteString(" ")
+	sb.WriteString(e.Op)
+	if e.Op == OpParserTypeDelimited {
+		sb.WriteString(" ")
+		sb.WriteString(OpDelimitedSep)
+		sb.WriteString("=")
+		sb.WriteString(strconv.Quote(e.Sep))
+	}
+	for i, c := range e.Columns {
+		if i == 0 {
+			sb.WriteString(" ")
+		} else {
+			sb.WriteString(",")
+		}
+		sb.WriteString(strconv.Quote(c))
+	}
+	return sb.String()
+}
+
 type LabelFilterExpr struct {
 	log.LabelFilterer
 	implicit
@@ -844,6 +911,70 @@ func (e *KeepLabelsExpr) Walk(f WalkFn) { f(e) }
 
 func (e *KeepLabelsExpr) Accept(v RootVisitor) { v.VisitKeepLabel(e) }
 
+// LookupExpr adds labels from the rows of a lookup table matching the value of
+// a label. The table is resolved per tenant using BindLookupTables before the
+// stage is built.
+type LookupExpr struct {
+	Table string
+	On    string
+
+	table *log.LookupTable
+	implicit
+}
+
+func newLookupExpr(table, on string) *LookupExpr {
+	return &LookupExpr{Table: table, On: on}
+}
+
+func (*LookupExpr) isStageExpr() {}
+
+func (e *LookupExpr) Shardable(_ bool) bool { return true }
+
+func (e *LookupExpr) Stage() (log.Stage, error) {
+	if e.table == nil {
+		return nil, fmt.Errorf("lookup table '%s' not found", e.Table)
+	}
+	return log.NewLookupStage(e.table, e.On)
+}
+
+func (e *LookupExpr) String() string {
+	return fmt.Sprintf("%s %s %s %s %s", OpPipe, OpLookup, e.Table, OpOn, e.On)
+}
+
+func (e *LookupExpr) Walk(f WalkFn) { f(e) }
+
+func (e *LookupExpr) Accept(v RootVisitor) { v.VisitLookup(e) }
+
+// LookupTableResolver returns the lookup table with the given name.
+type LookupTableResolver func(name string) (*log.LookupTable, bool)
+
+// BindLookupTables returns a copy of e in which the tables of the lookup stages
+// are resolved using the given resolver. Tables that cannot be resolved are left
+// unbound and fail when building the pipeline. e is returned as is if it has no
+// lookup stage.
+func BindLookupTables[T Expr](e T, resolve LookupTableResolver) (T, error) {
+	var hasLookup bool
+	e.Walk(func(e Expr) {
+		if _, ok := e.(*LookupExpr); ok {
+			hasLookup = true
+		}
+	})
+	if !hasLookup {
+		return e, nil
+	}
+
+	copied, err := Clone[T](e)
+	if err != nil {
+		return copied, err
+	}
+	copied.Walk(func(e Expr) {
+		if l, ok := e.(*LookupExpr); ok {
+			l.table, _ = resolve(l.Table)
+		}
+	})
+	return copied, nil
+}
+
 func (*LineFmtExpr) isStageExpr() {}
 
 func (e *LineFmtExpr) Shardable(_ bool) bool { return true }
@@ -946,6 +1077,45 @@ func (j *JSONExpressionParser) String() string {
 	return sb.String()
 }
 
+type XMLExpressionParser struct {
+	Expressions []log.LabelExtractionExpr
+
+	implicit
+}
+
+func newXMLExpressionParser(expressions []log.LabelExtractionExpr) *XMLExpressionParser {
+	return &XMLExpressionParser{
+		Expressions: expressions,
+	}
+}
+
+func (*XMLExpressionParser) isStageExpr() {}
+
+func (x *XMLExpressionParser) Shardable(_ bool) bool { return true }
+
+func (x *XMLExpressionParser) Walk(f WalkFn) { f(x) }
+
+func (x *XMLExpressionParser) Accept(v RootVisitor) { v.VisitXMLExpressionParser(x) }
+
+func (x *XMLExpressionParser) Stage() (log.Stage, error) {
+	return log.NewXMLExpressionParser(x.Expressions)
+}
+
+func (x *XMLExpressionParser) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s ", OpPipe, OpParserTypeXML))
+	for i, exp := range x.Expressions {
+		sb.WriteString(exp.Identifier)
+		sb.WriteString("=")
+		sb.WriteString(strconv.Quote(exp.Expression))
+
+		if i+1 != len(x.Expressions) {
+			sb.WriteString(",")
+		}
+	}
+	return sb.String()
+}
+
 type internedStringSet map[string]struct {
 	s  string
 	ok bool
@@ -1077,6 +1247,7 @@ type LogRange struct {
 	Left     LogSelectorExpr
 	Interval time.Duration
 	Offset   time.Duration
+	At       *AtExpr
 
 	Unwrap *UnwrapExpr
 
@@ -1095,10 +1266,17 @@ func (r LogRange) String() string {
 		offsetExpr := OffsetExpr{Offset: r.Offset}
 		sb.WriteString(offsetExpr.String())
 	}
+	if r.At != nil {
+		sb.WriteString(r.At.String())
+	}
 	return sb.String()
 }
 
-func (r *LogRange) Shardable(topLevel bool) bool { return r.Left.Shardable(topLevel) }
+// Shardable returns false for log ranges using the @ modifier, since the data
+// they select is not bound to the query time range the shards are computed for.
+func (r *LogRange) Shardable(topLevel bool) bool {
+	return r.At == nil && r.Left.Shardable(topLevel)
+}
 
 func (r *LogRange) Walk(f WalkFn) {
 	f(r)
@@ -1122,6 +1300,7 @@ func (r *LogRange) WithoutUnwrap() (*LogRange, error) {
 		Left:     left,
 		Interval: r.Interval,
 		Offset:   r.Offset,
+		At:       r.At.copy(),
 	}, nil
 }
 
@@ -1154,6 +1333,91 @@ func newOffsetExpr(offset time.Duration) *OffsetExpr {
 	}
 }
 
+// mustNewLogRangeWithAt sets the @ modifier of the given log range.
+func mustNewLogRangeWithAt(r *LogRange, at *AtExpr) *LogRange {
+	if r.At != nil {
+		panic(logqlmodel.NewParseError("@ modifier may not be set multiple times", 0, 0))
+	}
+	r.At = at
+	return r
+}
+
+// AtExpr is the @ modifier of a log range. It pins the evaluation time of the
+// range to a fixed timestamp, or to the start or end of the query.
+type AtExpr struct {
+	// Timestamp in milliseconds, only used when StartOrEnd is empty.
+	Timestamp int64
+	// StartOrEnd is either OpAtStart or OpAtEnd when set with start() or end().
+	StartOrEnd string
+}
+
+func (a *AtExpr) String() string {
+	if a.StartOrEnd != "" {
+		return fmt.Sprintf(" %s %s()", OpAt, a.StartOrEnd)
+	}
+	return fmt.Sprintf(" %s %.3f", OpAt, float64(a.Timestamp)/1e3)
+}
+
+// Time returns the evaluation time of the modifier for a query running from
+// start to end.
+func (a *AtExpr) Time(start, end time.Time) time.Time {
+	switch a.StartOrEnd {
+	case OpAtStart:
+		return start
+	case OpAtEnd:
+		return end
+	default:
+		return time.UnixMilli(a.Timestamp)
+	}
+}
+
+func (a *AtExpr) copy() *AtExpr {
+	if a == nil {
+		return nil
+	}
+	cp := *a
+	return &cp
+}
+
+func mustNewAtExpr(ts string) *AtExpr {
+	f := mustNewFloat(ts)
+	if math.IsInf(f, 0) || math.IsNaN(f) || f >= float64(math.MaxInt64)/1e3 || f <= float64(math.MinInt64)/1e3 {
+		panic(logqlmodel.NewParseError(fmt.Sprintf("timestamp out of bounds for @ modifier: %s", ts), 0, 0))
+	}
+	return &AtExpr{Timestamp: int64(math.Round(f * 1e3))}
+}
+
+func newAtExprStartOrEnd(startOrEnd string) *AtExpr {
+	return &AtExpr{StartOrEnd: startOrEnd}
+}
+
+// ResolveAtModifiers returns a copy of the expression in which the start() and
+// end() @ modifiers of all log ranges are replaced with the absolute start and
+// end timestamps of the query. The expression is returned as is if it does not
+// contain any of them.
+func ResolveAtModifiers(expr Expr, start, end time.Time) (Expr, error) {
+	var found bool
+	expr.Walk(func(e Expr) {
+		if r, ok := e.(*LogRange); ok && r.At != nil && r.At.StartOrEnd != "" {
+			found = true
+		}
+	})
+	if !found {
+		return expr, nil
+	}
+
+	resolved, err := Clone(expr)
+	if err != nil {
+		return nil, err
+	}
+	resolved.Walk(func(e Expr) {
+		if r, ok := e.(*LogRange); ok && r.At != nil && r.At.StartOrEnd != "" {
+			r.At = &AtExpr{Timestamp: r.At.Time(start, end).UnixMilli()}
+		}
+	})
+	return resolved, nil
+}
+
 const (
 	// vector ops
 	OpTypeSum      = "sum"
@@ -1184,6 +1448,7 @@ const (
 	OpRangeTypeFirst       = "first_over_time"
 	OpRangeTypeLast        = "last_over_time"
 	OpRangeTypeAbsent      = "absent_over_time"
+	OpRangeTypeHistogram   = "histogram_over_time"
 
 	//vector
 	OpTypeVector = "vector"
@@ -1210,11 +1475,14 @@ const (
 	OpTypeLTE   = "<="
 
 	// parsers
-	OpParserTypeJSON    = "json"
-	OpParserTypeLogfmt  = "logfmt"
-	OpParserTypeRegexp  = "regexp"
-	OpParserTypeUnpack  = "unpack"
-	OpParserTypePattern = "pattern"
+	OpParserTypeJSON      = "json"
+	OpParserTypeLogfmt    = "logfmt"
+	OpParserTypeRegexp    = "regexp"
+	OpParserTypeUnpack    = "unpack"
+	OpParserTypePattern   = "pattern"
+	OpParserTypeCSV       = "csv"
+	OpParserTypeDelimited = "delimited"
+	OpParserTypeXML       = "xml"
 
 	OpFmtLine    = "line_format"
 	OpFmtLabel   = "label_format"
@@ -1223,6 +1491,11 @@ const (
 	OpPipe   = "|"
 	OpUnwrap = "unwrap"
 	OpOffset = "offset"
+	OpAt     = "@"
+
+	// @ modifier preprocessors
+	OpAtStart = "start"
+	OpAtEnd   = "end"
 
 	OpOn       = "on"
 	OpIgnoring = "ignoring"
@@ -1246,6 +1519,12 @@ const (
 	// keep labels
 	OpKeep = "keep"
 
+	// lookup tables
+	OpLookup = "lookup"
+
+	// delimited parser parameters
+	OpDelimitedSep = "sep"
+
 	// parser flags
 	OpStrict    = "--strict"
 	OpKeepEmpty = "--keep-empty"
@@ -1296,6 +1575,7 @@ type RangeAggregationExpr struct {
 	Operation string
 
 	Params   *float64
+	Buckets  []float64
 	Grouping *Grouping
 	err      error
 	implicit
@@ -1330,6 +1610,33 @@ func newRangeAggregationExpr(left *LogRange, operation string, gr *Grouping, str
 	}
 	return e
 }
+
+// newHistogramRangeAggregationExpr creates a histogram_over_time range aggregation.
+// The bucket upper bounds must be strictly increasing; the +Inf bucket is implicit.
+func newHistogramRangeAggregationExpr(left *LogRange, gr *Grouping, stringBuckets []string) SampleExpr {
+	buckets := make([]float64, 0, len(stringBuckets))
+	for _, b := range stringBuckets {
+		bucket, err := strconv.ParseFloat(b, 64)
+		if err != nil {
+			return &RangeAggregationExpr{err: logqlmodel.NewParseError(fmt.Sprintf("invalid bucket for operation %s: %s", OpRangeTypeHistogram, err), 0, 0)}
+		}
+		if len(buckets) > 0 && bucket <= buckets[len(buckets)-1] {
+			return &RangeAggregationExpr{err: logqlmodel.NewParseError(fmt.Sprintf("buckets for operation %s must be in strictly increasing order", OpRangeTypeHistogram), 0, 0)}
+		}
+		buckets = append(buckets, bucket)
+	}
+	e := &RangeAggregationExpr{
+		Left:      left,
+		Operation: OpRangeTypeHistogram,
+		Grouping:  gr,
+		Buckets:   buckets,
+	}
+	if err := e.validate(); err != nil {
+		return &RangeAggregationExpr{err: logqlmodel.NewParseError(err.Error(), 0, 0)}
+	}
+	return e
+}
+
 func (e *RangeAggregationExpr) isSampleExpr() {}
 
 func (e *RangeAggregationExpr) Selector() (LogSelectorExpr, error) {
@@ -1361,7 +1668,8 @@ func (e RangeAggregationExpr) validate() error {
 		switch e.Operation {
 		case OpRangeTypeAvg, OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile,
 			OpRangeTypeQuantileSketch, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeFirst,
-			OpRangeTypeLast, OpRangeTypeFirstWithTimestamp, OpRangeTypeLastWithTimestamp:
+			OpRangeTypeLast, OpRangeTypeFirstWithTimestamp, OpRangeTypeLastWithTimestamp,
+			OpRangeTypeHistogram:
 		default:
 			return fmt.Errorf("grouping not allowed for %s aggregation", e.Operation)
 		}
@@ -1371,7 +1679,7 @@ func (e RangeAggregationExpr) validate() error {
 		case OpRangeTypeAvg, OpRangeTypeSum, OpRangeTypeMax, OpRangeTypeMin, OpRangeTypeStddev,
 			OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeRate, OpRangeTypeRateCounter,
 			OpRangeTypeAbsent, OpRangeTypeFirst, OpRangeTypeLast, OpRangeTypeQuantileSketch,
-			OpRangeTypeFirstWithTimestamp, OpRangeTypeLastWithTimestamp:
+			OpRangeTypeFirstWithTimestamp, OpRangeTypeLastWithTimestamp, OpRangeTypeHistogram:
 			return nil
 		default:
 			return fmt.Errorf("invalid aggregation %s with unwrap", e.Operation)
@@ -1398,6 +1706,10 @@ func (e *RangeAggregationExpr) String() string {
 		sb.WriteString(strconv.FormatFloat(*e.Params, 'f', -1, 64))
 		sb.WriteString(",")
 	}
+	for _, b := range e.Buckets {
+		sb.WriteString(strconv.FormatFloat(b, 'f', -1, 64))
+		sb.WriteString(",")
+	}
 	sb.WriteString(e.Left.String())
 	sb.WriteString(")")
 	if e.Grouping != nil {
@@ -1428,6 +1740,176 @@ func (e *RangeAggregationExpr) Walk(f WalkFn) {
 
 func (e *RangeAggregationExpr) Accept(v RootVisitor) { v.VisitRangeAggregation(e) }
 
+// SubqueryExpr evaluates a metric expression over a range with a fixed step,
+// e.g. `rate({app="foo"}[1m])[1h:5m]`. Like a log range, it can only be used
+// as the argument of a range aggregation.
+type SubqueryExpr struct {
+	Left   SampleExpr
+	Range  time.Duration
+	Step   time.Duration
+	Offset time.Duration
+
+	implicit
+}
+
+// impls Stringer
+func (e *SubqueryExpr) String() string {
+	var sb strings.Builder
+	sb.WriteString(e.Left.String())
+	sb.WriteString(fmt.Sprintf("[%v:%v]", model.Duration(e.Range), model.Duration(e.Step)))
+	if e.Offset != 0 {
+		offsetExpr := OffsetExpr{Offset: e.Offset}
+		sb.WriteString(offsetExpr.String())
+	}
+	return sb.String()
+}
+
+// Shardable returns false as a subquery can only be sharded as a whole, see
+// SubqueryAggregationExpr.Shardable.
+func (e *SubqueryExpr) Shardable(_ bool) bool { return false }
+
+func (e *SubqueryExpr) Walk(f WalkFn) {
+	f(e)
+	if e.Left == nil {
+		return
+	}
+	e.Left.Walk(f)
+}
+
+func (e *SubqueryExpr) Accept(v RootVisitor) { v.VisitSubquery(e) }
+
+func mustNewSubqueryExpr(left SampleExpr, r subqueryRange, o *OffsetExpr) *SubqueryExpr {
+	if r.Step <= 0 {
+		panic(logqlmodel.NewParseError("subquery step must be greater than 0", 0, 0))
+	}
+	var offset time.Duration
+	if o != nil {
+		offset = o.Offset
+	}
+	return &SubqueryExpr{
+		Left:   left,
+		Range:  r.Range,
+		Step:   r.Step,
+		Offset: offset,
+	}
+}
+
+// subqueryRange is the `[<range>:<step>]` token of a subquery.
+type subqueryRange struct {
+	Range, Step time.Duration
+}
+
+// SubqueryAggregationExpr is a range aggregation over the samples of a
+// subquery, e.g. `max_over_time(rate({app="foo"}[1m])[1h:5m])`.
+type SubqueryAggregationExpr struct {
+	Left      *SubqueryExpr
+	Operation string
+
+	Params *float64
+	err    error
+	implicit
+}
+
+func newSubqueryAggregationExpr(left *SubqueryExpr, operation string, stringParams *string) SampleExpr {
+	switch operation {
+	case OpRangeTypeCount, OpRangeTypeSum, OpRangeTypeAvg, OpRangeTypeMax, OpRangeTypeMin,
+		OpRangeTypeStddev, OpRangeTypeStdvar, OpRangeTypeQuantile, OpRangeTypeFirst, OpRangeTypeLast:
+	default:
+		return &SubqueryAggregationExpr{err: logqlmodel.NewParseError(fmt.Sprintf("invalid aggregation %s over subquery", operation), 0, 0)}
+	}
+	var params *float64
+	if stringParams != nil {
+		if operation != OpRangeTypeQuantile {
+			return &SubqueryAggregationExpr{err: logqlmodel.NewParseError(fmt.Sprintf("parameter %s not supported for operation %s", *stringParams, operation), 0, 0)}
+		}
+		var err error
+		params = new(float64)
+		*params, err = strconv.ParseFloat(*stringParams, 64)
+		if err != nil {
+			return &SubqueryAggregationExpr{err: logqlmodel.NewParseError(fmt.Sprintf("invalid parameter for operation %s: %s", operation, err), 0, 0)}
+		}
+	} else if operation == OpRangeTypeQuantile {
+		return &SubqueryAggregationExpr{err: logqlmodel.NewParseError(fmt.Sprintf("parameter required for operation %s", operation), 0, 0)}
+	}
+	return &SubqueryAggregationExpr{
+		Left:      left,
+		Operation: operation,
+		Params:    params,
+	}
+}
+
+func (e *SubqueryAggregationExpr) isSampleExpr() {}
+
+func (e *SubqueryAggregationExpr) Selector() (LogSelectorExpr, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.Left.Left.Selector()
+}
+
+func (e *SubqueryAggregationExpr) Extractor() (SampleExtractor, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.Left.Left.Extractor()
+}
+
+// MatcherGroups returns the matcher groups of the inner expression, with
+// their interval and offset extended by the range and offset of the subquery.
+func (e *SubqueryAggregationExpr) MatcherGroups() ([]MatcherRange, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	groups, err := e.Left.Left.MatcherGroups()
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		groups[i].Interval += e.Left.Range
+		groups[i].Offset += e.Left.Offset
+	}
+	return groups, nil
+}
+
+// impls Stringer
+func (e *SubqueryAggregationExpr) String() string {
+	var sb strings.Builder
+	sb.WriteString(e.Operation)
+	sb.WriteString("(")
+	if e.Params != nil {
+		sb.WriteString(strconv.FormatFloat(*e.Params, 'f', -1, 64))
+		sb.WriteString(",")
+	}
+	sb.WriteString(e.Left.String())
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// Shardable returns true if the whole subquery can be evaluated on each shard
+// independently. This is the case when its inner expression is a range
+// aggregation which does not reduce labels, since each of its series is then
+// computed from the streams of a single shard.
+func (e *SubqueryAggregationExpr) Shardable(_ bool) bool {
+	if e.err != nil {
+		return false
+	}
+	inner, ok := e.Left.Left.(*RangeAggregationExpr)
+	if !ok || inner.Operation == OpRangeTypeAbsent {
+		return false
+	}
+	return inner.Left.Shardable(false) && !ReducesLabels(inner)
+}
+
+func (e *SubqueryAggregationExpr) Walk(f WalkFn) {
+	f(e)
+	if e.Left == nil {
+		return
+	}
+	e.Left.Walk(f)
+}
+
+func (e *SubqueryAggregationExpr) Accept(v RootVisitor) { v.VisitSubqueryAggregation(e) }
+
 // Grouping struct represents the grouping by/without label(s) for vector aggregators and range vector aggregators.
 // The representation is as follows:
 //   - No Grouping (labels dismissed): <operation> (<expr>) => Grouping{Without: false, Groups: nil}
@@ -2241,6 +2723,7 @@ var shardableOps = map[string]bool{
 	OpRangeTypeMax:       true,
 	OpRangeTypeMin:       true,
 	OpRangeTypeQuantile:  true,
+	OpRangeTypeHistogram: true,
 
 	// binops - arith
 	OpTypeAdd: true,
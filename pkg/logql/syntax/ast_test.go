@@ -50,6 +50,10 @@ func Test_logSelectorExpr_String(t *testing.T) {
 		{`{foo="bar"} |= "baz" |~ "blip" != "flip" !~ "flap" | regexp "(?P<foo>foo|bar)"`, true},
 		{`{foo="bar"} |= "baz" |~ "blip" != "flip" !~ "flap" | regexp "(?P<foo>foo|bar)" | ( ( foo<5.01 , bar>20ms ) or foo="bar" ) | line_format "blip{{.boop}}bap" | label_format foo=bar,bar="blip{{.blop}}"`, true},
 		{`{foo="bar"} | logfmt | counter>-1 | counter>=-1 | counter<-1 | counter<=-1 | counter!=-1 | counter==-1`, true},
+		{`{foo="bar"} |= "baz" | csv "ip","","method" | method="GET"`, true},
+		{`{foo="bar"} |= "baz" | delimited sep="\t" "ip","method" | method="GET"`, true},
+		{`{foo="bar"} |= "baz" | xml | Event_System_EventID="4624"`, true},
+		{`{foo="bar"} |= "baz" | xml event_id="Event/System/EventID",provider="Event/System/Provider/@Name"`, true},
 	}
 
 	for _, tt := range tests {
@@ -1145,3 +1149,51 @@ func TestCombineFilters(t *testing.T) {
 		}
 	}
 }
+
+func TestBindLookupTables(t *testing.T) {
+	table, err := log.NewLookupTable([]string{"service", "team"}, [][]string{{"api", "platform"}})
+	require.NoError(t, err)
+	resolve := func(name string) (*log.LookupTable, bool) {
+		if name == "ownership" {
+			return table, true
+		}
+		return nil, false
+	}
+
+	expr, err := ParseSampleExpr(`sum by (team) (count_over_time({app="foo"} | lookup ownership on service | team="platform" [5m]))`)
+	require.NoError(t, err)
+	_, err = expr.Extractor()
+	require.EqualError(t, err, "parse error : stage '| lookup ownership on service' : lookup table 'ownership' not found")
+
+	bound, err := BindLookupTables(expr, resolve)
+	require.NoError(t, err)
+	require.NotSame(t, expr, bound)
+	require.Equal(t, expr.String(), bound.String())
+
+	sel, err := bound.Selector()
+	require.NoError(t, err)
+	p, err := sel.Pipeline()
+	require.NoError(t, err)
+	_, lbs, ok := p.ForStream(labels.FromStrings("app", "foo", "service", "api")).Process(0, []byte("line"))
+	require.True(t, ok)
+	require.Equal(t, labels.FromStrings("app", "foo", "service", "api", "team", "platform"), lbs.Labels())
+
+	// the original expression is left unbound.
+	_, err = expr.Extractor()
+	require.Error(t, err)
+
+	// unknown tables are left unbound.
+	unknown, err := ParseLogSelector(`{app="foo"} | lookup unknown on service`, true)
+	require.NoError(t, err)
+	unknown, err = BindLookupTables(unknown, resolve)
+	require.NoError(t, err)
+	_, err = unknown.Pipeline()
+	require.EqualError(t, err, "parse error : stage '| lookup unknown on service' : lookup table 'unknown' not found")
+
+	// expressions without lookups are not copied.
+	noLookup, err := ParseLogSelector(`{app="foo"} | json`, true)
+	require.NoError(t, err)
+	same, err := BindLookupTables(noLookup, resolve)
+	require.NoError(t, err)
+	require.Same(t, noLookup, same)
+}
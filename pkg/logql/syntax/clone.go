@@ -79,6 +79,25 @@ func (v *cloneVisitor) VisitRangeAggregation(e *RangeAggregationExpr) {
 		copied.Params = &tmp
 	}
 
+	if e.Buckets != nil {
+		copied.Buckets = make([]float64, len(e.Buckets))
+		copy(copied.Buckets, e.Buckets)
+	}
+
+	v.cloned = copied
+}
+
+func (v *cloneVisitor) VisitSubqueryAggregation(e *SubqueryAggregationExpr) {
+	copied := &SubqueryAggregationExpr{
+		Left:      MustClone[*SubqueryExpr](e.Left),
+		Operation: e.Operation,
+	}
+
+	if e.Params != nil {
+		tmp := *e.Params
+		copied.Params = &tmp
+	}
+
 	v.cloned = copied
 }
 
@@ -100,6 +119,7 @@ func (v *cloneVisitor) VisitLogRange(e *LogRange) {
 		Left:     MustClone[LogSelectorExpr](e.Left),
 		Interval: e.Interval,
 		Offset:   e.Offset,
+		At:       e.At.copy(),
 	}
 	if e.Unwrap != nil {
 		copied.Unwrap = &UnwrapExpr{
@@ -117,6 +137,15 @@ func (v *cloneVisitor) VisitLogRange(e *LogRange) {
 	v.cloned = copied
 }
 
+func (v *cloneVisitor) VisitSubquery(e *SubqueryExpr) {
+	v.cloned = &SubqueryExpr{
+		Left:   MustClone[SampleExpr](e.Left),
+		Range:  e.Range,
+		Step:   e.Step,
+		Offset: e.Offset,
+	}
+}
+
 func (v *cloneVisitor) VisitMatchers(e *MatchersExpr) {
 	copied := &MatchersExpr{
 		Mts: make([]*labels.Matcher, len(e.Mts)),
@@ -168,6 +197,15 @@ func (v *cloneVisitor) VisitJSONExpressionParser(e *JSONExpressionParser) {
 	v.cloned = copied
 }
 
+func (v *cloneVisitor) VisitXMLExpressionParser(e *XMLExpressionParser) {
+	copied := &XMLExpressionParser{
+		Expressions: make([]log.LabelExtractionExpr, len(e.Expressions)),
+	}
+	copy(copied.Expressions, e.Expressions)
+
+	v.cloned = copied
+}
+
 func (v *cloneVisitor) VisitKeepLabel(e *KeepLabelsExpr) {
 	copied := &KeepLabelsExpr{
 		keepLabels: make([]log.KeepLabel, len(e.keepLabels)),
@@ -251,6 +289,17 @@ func (v *cloneVisitor) VisitLabelFmt(e *LabelFmtExpr) {
 	v.cloned = copied
 }
 
+func (v *cloneVisitor) VisitDelimitedParser(e *DelimitedParserExpr) {
+	copied := &DelimitedParserExpr{
+		Op:      e.Op,
+		Sep:     e.Sep,
+		Columns: make([]string, len(e.Columns)),
+	}
+	copy(copied.Columns, e.Columns)
+
+	v.cloned = copied
+}
+
 func (v *cloneVisitor) VisitLabelParser(e *LabelParserExpr) {
 	v.cloned = &LabelParserExpr{
 		Op:    e.Op,
@@ -300,3 +349,12 @@ func (v *cloneVisitor) VisitLogfmtParser(e *LogfmtParserExpr) {
 		KeepEmpty: e.KeepEmpty,
 	}
 }
+
+func (v *cloneVisitor) VisitLookup(e *LookupExpr) {
+	v.cloned = &LookupExpr{
+		Table: e.Table,
+		On:    e.On,
+		// tables are immutable and can be shared.
+		table: e.table,
+	}
+}
@@ -38,6 +38,15 @@ func TestClone(t *testing.T) {
 		"keep label": {
 			query: `{app="foo"} |= "bar" | json | keep latency, status_code="200"`,
 		},
+		"delimited parser": {
+			query: `{app="foo"} | delimited sep=";" "ip","","method" | method="GET"`,
+		},
+		"xml parser": {
+			query: `{app="foo"} | xml | xml event_id="Event/System/EventID" | event_id="4624"`,
+		},
+		"lookup": {
+			query: `{app="foo"} | json | lookup ownership on service | team="platform"`,
+		},
 		"regexp": {
 			query: `{env="prod", app=~"loki.*"} |~ ".*foo.*"`,
 		},
@@ -58,6 +67,15 @@ func TestClone(t *testing.T) {
 				| line_format "blip{{ .foo }}blop {{.status_code}}" | label_format foo=bar,status_code="buzz{{.bar}}" | unwrap foo
 				| __error__ !~".+"[5m]) by (namespace,instance)`,
 		},
+		"histogram over time": {
+			query: `histogram_over_time(0.1,0.5,1,{app="foo"} | json | unwrap latency [5m]) by (namespace)`,
+		},
+		"at modifier": {
+			query: `sum(rate({app="foo"}[5m] @ 1609746000.000)) / sum(rate({app="foo"}[5m] offset 1w @ end()))`,
+		},
+		"subquery": {
+			query: `max_over_time(sum by (app) (rate({app="foo"}[5m]))[1h:5m] offset 1d) / quantile_over_time(0.99,rate({app="foo"}[5m])[1h:10m])`,
+		},
 		"multiple post filters": {
 			query: `rate({app="foo"} | json | unwrap foo | latency >= 250ms or bytes > 42B or ( status_code < 500 and status_code > 200) or source = ip("") and user = "me" [1m])`,
 		},
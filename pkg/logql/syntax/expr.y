@@ -16,6 +16,7 @@ import (
   Labels                  []string
   LogExpr                 LogSelectorExpr
   LogRangeExpr            *LogRange
+  SubqueryExpr            *SubqueryExpr
   Matcher                 *labels.Matcher
   Matchers                []*labels.Matcher
   RangeAggregationExpr    SampleExpr
@@ -34,6 +35,7 @@ import (
   bytes                   uint64
   str                     string
   duration                time.Duration
+  subqueryRange           subqueryRange
   LiteralExpr             *LiteralExpr
   BinOpModifier           *BinOpOptions
   BoolModifier            *BinOpOptions
@@ -65,12 +67,15 @@ import (
   UnwrapExpr              *UnwrapExpr
   DecolorizeExpr          *DecolorizeExpr
   OffsetExpr              *OffsetExpr
+  AtExpr                  *AtExpr
   DropLabel               log.DropLabel
   DropLabels              []log.DropLabel
   DropLabelsExpr          *DropLabelsExpr
   KeepLabel               log.KeepLabel
   KeepLabels              []log.KeepLabel
   KeepLabelsExpr          *KeepLabelsExpr
+  LookupExpr              *LookupExpr
+  HistogramBuckets        []string
 }
 
 %start root
@@ -82,6 +87,7 @@ import (
 %type <LogExpr>               logExpr
 %type <MetricExpr>            metricExpr
 %type <LogRangeExpr>          logRangeExpr
+%type <SubqueryExpr>          subqueryExpr
 %type <Matcher>               matcher
 %type <Matchers>              matchers
 %type <RangeAggregationExpr>  rangeAggregationExpr
@@ -100,6 +106,9 @@ import (
 %type <BoolModifier>          boolModifier
 %type <OnOrIgnoringModifier>  onOrIgnoringModifier
 %type <LabelParser>           labelParser
+%type <PipelineStage>         delimitedParser
+%type <PipelineStage>         xmlExpressionParser
+%type <Labels>                delimitedColumns
 %type <LogfmtParser>          logfmtParser
 %type <PipelineExpr>          pipelineExpr
 %type <PipelineStage>         pipelineStage
@@ -119,6 +128,7 @@ import (
 %type <KeepLabelsExpr>        keepLabelsExpr
 %type <KeepLabels>            keepLabels
 %type <KeepLabel>             keepLabel
+%type <LookupExpr>            lookupExpr
 %type <LabelFormatExpr>       labelFormatExpr
 %type <LabelFormat>           labelFormat
 %type <LabelsFormat>          labelsFormat
@@ -130,16 +140,19 @@ import (
 %type <UnitFilter>            unitFilter
 %type <IPLabelFilter>         ipLabelFilter
 %type <OffsetExpr>            offsetExpr
+%type <AtExpr>                atExpr
+%type <HistogramBuckets>      histogramBuckets
 
 %token <bytes> BYTES
 %token <str>      IDENTIFIER STRING NUMBER PARSER_FLAG
 %token <duration> DURATION RANGE
+%token <subqueryRange> SUBQUERY_RANGE
 %token <val>      MATCHERS LABELS EQ RE NRE NPA OPEN_BRACE CLOSE_BRACE OPEN_BRACKET CLOSE_BRACKET COMMA DOT PIPE_MATCH PIPE_EXACT PIPE_PATTERN
                   OPEN_PARENTHESIS CLOSE_PARENTHESIS BY WITHOUT COUNT_OVER_TIME RATE RATE_COUNTER SUM SORT SORT_DESC AVG MAX MIN COUNT STDDEV STDVAR BOTTOMK TOPK
                   BYTES_OVER_TIME BYTES_RATE BOOL JSON REGEXP LOGFMT PIPE LINE_FMT LABEL_FMT UNWRAP AVG_OVER_TIME SUM_OVER_TIME MIN_OVER_TIME
                   MAX_OVER_TIME STDVAR_OVER_TIME STDDEV_OVER_TIME QUANTILE_OVER_TIME BYTES_CONV DURATION_CONV DURATION_SECONDS_CONV
                   FIRST_OVER_TIME LAST_OVER_TIME ABSENT_OVER_TIME VECTOR LABEL_REPLACE UNPACK OFFSET PATTERN IP ON IGNORING GROUP_LEFT GROUP_RIGHT
-                  DECOLORIZE DROP KEEP
+                  DECOLORIZE DROP KEEP HISTOGRAM_OVER_TIME AT START END LOOKUP CSV DELIMITED XML
 
 // Operators are listed with increasing precedence.
 %left <binOp> OR
@@ -200,9 +213,15 @@ logRangeExpr:
     | selector RANGE pipelineExpr unwrapExpr                                                { $$ = newLogRange(newPipelineExpr(newMatcherExpr($1), $3), $2, $4, nil ) }
     | selector RANGE offsetExpr pipelineExpr unwrapExpr                                     { $$ = newLogRange(newPipelineExpr(newMatcherExpr($1), $4), $2, $5, $3 ) }
     | OPEN_PARENTHESIS logRangeExpr CLOSE_PARENTHESIS                                       { $$ = $2 }
+    | logRangeExpr atExpr                                                                   { $$ = mustNewLogRangeWithAt($1, $2) }
     | logRangeExpr error
     ;
 
+subqueryExpr:
+      metricExpr SUBQUERY_RANGE                     { $$ = mustNewSubqueryExpr($1, $2, nil) }
+    | metricExpr SUBQUERY_RANGE offsetExpr          { $$ = mustNewSubqueryExpr($1, $2, $3) }
+    ;
+
 unwrapExpr:
     PIPE UNWRAP IDENTIFIER                                                   { $$ = newUnwrapExpr($3, "")}
   | PIPE UNWRAP convOp OPEN_PARENTHESIS IDENTIFIER CLOSE_PARENTHESIS         { $$ = newUnwrapExpr($5, $3)}
@@ -220,6 +239,15 @@ rangeAggregationExpr:
     | rangeOp OPEN_PARENTHESIS NUMBER COMMA logRangeExpr CLOSE_PARENTHESIS           { $$ = newRangeAggregationExpr($5, $1, nil, &$3) }
     | rangeOp OPEN_PARENTHESIS logRangeExpr CLOSE_PARENTHESIS grouping               { $$ = newRangeAggregationExpr($3, $1, $5, nil) }
     | rangeOp OPEN_PARENTHESIS NUMBER COMMA logRangeExpr CLOSE_PARENTHESIS grouping  { $$ = newRangeAggregationExpr($5, $1, $7, &$3) }
+    | rangeOp OPEN_PARENTHESIS subqueryExpr CLOSE_PARENTHESIS                        { $$ = newSubqueryAggregationExpr($3, $1, nil) }
+    | rangeOp OPEN_PARENTHESIS NUMBER COMMA subqueryExpr CLOSE_PARENTHESIS           { $$ = newSubqueryAggregationExpr($5, $1, &$3) }
+    | HISTOGRAM_OVER_TIME OPEN_PARENTHESIS histogramBuckets COMMA logRangeExpr CLOSE_PARENTHESIS          { $$ = newHistogramRangeAggregationExpr($5, nil, $3) }
+    | HISTOGRAM_OVER_TIME OPEN_PARENTHESIS histogramBuckets COMMA logRangeExpr CLOSE_PARENTHESIS grouping { $$ = newHistogramRangeAggregationExpr($5, $7, $3) }
+    ;
+
+histogramBuckets:
+      NUMBER                        { $$ = []string{ $1 } }
+    | histogramBuckets COMMA NUMBER { $$ = append($1, $3) }
     ;
 
 vectorAggregationExpr:
@@ -274,7 +302,9 @@ pipelineStage:
    lineFilters                   { $$ = $1 }
   | PIPE logfmtParser            { $$ = $2 }
   | PIPE labelParser             { $$ = $2 }
+  | PIPE delimitedParser         { $$ = $2 }
   | PIPE jsonExpressionParser    { $$ = $2 }
+  | PIPE xmlExpressionParser     { $$ = $2 }
   | PIPE logfmtExpressionParser  { $$ = $2 }
   | PIPE labelFilter             { $$ = &LabelFilterExpr{LabelFilterer: $2 }}
   | PIPE lineFormatExpr          { $$ = $2 }
@@ -282,6 +312,7 @@ pipelineStage:
   | PIPE labelFormatExpr         { $$ = $2 }
   | PIPE dropLabelsExpr          { $$ = $2 }
   | PIPE keepLabelsExpr          { $$ = $2 }
+  | PIPE lookupExpr              { $$ = $2 }
   ;
 
 filterOp:
@@ -321,11 +352,25 @@ labelParser:
   | REGEXP STRING       { $$ = newLabelParserExpr(OpParserTypeRegexp, $2) }
   | UNPACK              { $$ = newLabelParserExpr(OpParserTypeUnpack, "") }
   | PATTERN STRING      { $$ = newLabelParserExpr(OpParserTypePattern, $2) }
+  | XML                 { $$ = newLabelParserExpr(OpParserTypeXML, "") }
+  ;
+
+delimitedParser:
+    CSV delimitedColumns                              { $$ = newDelimitedParserExpr(OpParserTypeCSV, ",", $2) }
+  | DELIMITED IDENTIFIER EQ STRING delimitedColumns   { $$ = newDelimitedParserExpr(OpParserTypeDelimited, mustNewDelimitedSeparator($2, $4), $5) }
+  ;
+
+delimitedColumns:
+    STRING                          { $$ = []string{ $1 } }
+  | delimitedColumns COMMA STRING   { $$ = append($1, $3) }
   ;
 
 jsonExpressionParser:
     JSON labelExtractionExpressionList { $$ = newJSONExpressionParser($2) }
 
+xmlExpressionParser:
+    XML labelExtractionExpressionList { $$ = newXMLExpressionParser($2) }
+
 logfmtExpressionParser:
     LOGFMT parserFlags labelExtractionExpressionList  { $$ = newLogfmtExpressionParser($3, $2)}
   | LOGFMT labelExtractionExpressionList              { $$ = newLogfmtExpressionParser($2, nil)}
@@ -431,6 +476,8 @@ keepLabels:
 
 keepLabelsExpr: KEEP keepLabels { $$ = newKeepLabelsExpr($2) }
 
+lookupExpr: LOOKUP IDENTIFIER ON IDENTIFIER { $$ = newLookupExpr($2, $4) }
+
 // Operator precedence only works if each of these is listed separately.
 binOpExpr:
          expr OR binOpModifier expr          { $$ = mustNewBinOpExpr("or", $3, $1, $4) }
@@ -568,6 +615,12 @@ rangeOp:
 offsetExpr:
     OFFSET DURATION { $$ = newOffsetExpr( $2 ) }
 
+atExpr:
+      AT NUMBER                                       { $$ = mustNewAtExpr( $2 ) }
+    | AT START OPEN_PARENTHESIS CLOSE_PARENTHESIS     { $$ = newAtExprStartOrEnd( OpAtStart ) }
+    | AT END OPEN_PARENTHESIS CLOSE_PARENTHESIS       { $$ = newAtExprStartOrEnd( OpAtEnd ) }
+    ;
+
 labels:
       IDENTIFIER                 { $$ = []string{ $1 } }
     | labels COMMA IDENTIFIER    { $$ = append($1, $3) }
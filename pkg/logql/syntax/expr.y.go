@@ -1,19 +1,15 @@
 // Code generated by goyacc -p expr -o expr.y.go expr.y. DO NOT EDIT.
 
-//line expr.y:2
 package syntax
 
 import __yyfmt__ "fmt"
 
-//line expr.y:2
-
 import (
 	"github.com/grafana/loki/v3/pkg/logql/log"
 	"github.com/prometheus/prometheus/model/labels"
 	"time"
 )
 
-//line expr.y:12
 type exprSymType struct {
 	yys                   int
 	Expr                  Expr
@@ -22,6 +18,7 @@ type exprSymType struct {
 	Labels                []string
 	LogExpr               LogSelectorExpr
 	LogRangeExpr          *LogRange
+	SubqueryExpr          *SubqueryExpr
 	Matcher               *labels.Matcher
 	Matchers              []*labels.Matcher
 	RangeAggregationExpr  SampleExpr
@@ -40,6 +37,7 @@ type exprSymType struct {
 	bytes                 uint64
 	str                   string
 	duration              time.Duration
+	subqueryRange         subqueryRange
 	LiteralExpr           *LiteralExpr
 	BinOpModifier         *BinOpOptions
 	BoolModifier          *BinOpOptions
@@ -68,15 +66,18 @@ type exprSymType struct {
 	JSONExpressionParser          *JSONExpressionParser
 	LogfmtExpressionParser        *LogfmtExpressionParser
 
-	UnwrapExpr     *UnwrapExpr
-	DecolorizeExpr *DecolorizeExpr
-	OffsetExpr     *OffsetExpr
-	DropLabel      log.DropLabel
-	DropLabels     []log.DropLabel
-	DropLabelsExpr *DropLabelsExpr
-	KeepLabel      log.KeepLabel
-	KeepLabels     []log.KeepLabel
-	KeepLabelsExpr *KeepLabelsExpr
+	UnwrapExpr       *UnwrapExpr
+	DecolorizeExpr   *DecolorizeExpr
+	OffsetExpr       *OffsetExpr
+	AtExpr           *AtExpr
+	DropLabel        log.DropLabel
+	DropLabels       []log.DropLabel
+	DropLabelsExpr   *DropLabelsExpr
+	KeepLabel        log.KeepLabel
+	KeepLabels       []log.KeepLabel
+	KeepLabelsExpr   *KeepLabelsExpr
+	LookupExpr       *LookupExpr
+	HistogramBuckets []string
 }
 
 const BYTES = 57346
@@ -86,90 +87,99 @@ const NUMBER = 57349
 const PARSER_FLAG = 57350
 const DURATION = 57351
 const RANGE = 57352
-const MATCHERS = 57353
-const LABELS = 57354
-const EQ = 57355
-const RE = 57356
-const NRE = 57357
-const NPA = 57358
-const OPEN_BRACE = 57359
-const CLOSE_BRACE = 57360
-const OPEN_BRACKET = 57361
-const CLOSE_BRACKET = 57362
-const COMMA = 57363
-const DOT = 57364
-const PIPE_MATCH = 57365
-const PIPE_EXACT = 57366
-const PIPE_PATTERN = 57367
-const OPEN_PARENTHESIS = 57368
-const CLOSE_PARENTHESIS = 57369
-const BY = 57370
-const WITHOUT = 57371
-const COUNT_OVER_TIME = 57372
-const RATE = 57373
-const RATE_COUNTER = 57374
-const SUM = 57375
-const SORT = 57376
-const SORT_DESC = 57377
-const AVG = 57378
-const MAX = 57379
-const MIN = 57380
-const COUNT = 57381
-const STDDEV = 57382
-const STDVAR = 57383
-const BOTTOMK = 57384
-const TOPK = 57385
-const BYTES_OVER_TIME = 57386
-const BYTES_RATE = 57387
-const BOOL = 57388
-const JSON = 57389
-const REGEXP = 57390
-const LOGFMT = 57391
-const PIPE = 57392
-const LINE_FMT = 57393
-const LABEL_FMT = 57394
-const UNWRAP = 57395
-const AVG_OVER_TIME = 57396
-const SUM_OVER_TIME = 57397
-const MIN_OVER_TIME = 57398
-const MAX_OVER_TIME = 57399
-const STDVAR_OVER_TIME = 57400
-const STDDEV_OVER_TIME = 57401
-const QUANTILE_OVER_TIME = 57402
-const BYTES_CONV = 57403
-const DURATION_CONV = 57404
-const DURATION_SECONDS_CONV = 57405
-const FIRST_OVER_TIME = 57406
-const LAST_OVER_TIME = 57407
-const ABSENT_OVER_TIME = 57408
-const VECTOR = 57409
-const LABEL_REPLACE = 57410
-const UNPACK = 57411
-const OFFSET = 57412
-const PATTERN = 57413
-const IP = 57414
-const ON = 57415
-const IGNORING = 57416
-const GROUP_LEFT = 57417
-const GROUP_RIGHT = 57418
-const DECOLORIZE = 57419
-const DROP = 57420
-const KEEP = 57421
-const OR = 57422
-const AND = 57423
-const UNLESS = 57424
-const CMP_EQ = 57425
-const NEQ = 57426
-const LT = 57427
-const LTE = 57428
-const GT = 57429
-const GTE = 57430
-const ADD = 57431
-const SUB = 57432
-const MUL = 57433
-const DIV = 57434
-const MOD = 57435
-const POW = 57436
+const SUBQUERY_RANGE = 57353
+const MATCHERS = 57354
+const LABELS = 57355
+const EQ = 57356
+const RE = 57357
+const NRE = 57358
+const NPA = 57359
+const OPEN_BRACE = 57360
+const CLOSE_BRACE = 57361
+const OPEN_BRACKET = 57362
+const CLOSE_BRACKET = 57363
+const COMMA = 57364
+const DOT = 57365
+const PIPE_MATCH = 57366
+const PIPE_EXACT = 57367
+const PIPE_PATTERN = 57368
+const OPEN_PARENTHESIS = 57369
+const CLOSE_PARENTHESIS = 57370
+const BY = 57371
+const WITHOUT = 57372
+const COUNT_OVER_TIME = 57373
+const RATE = 57374
+const RATE_COUNTER = 57375
+const SUM = 57376
+const SORT = 57377
+const SORT_DESC = 57378
+const AVG = 57379
+const MAX = 57380
+const MIN = 57381
+const COUNT = 57382
+const STDDEV = 57383
+const STDVAR = 57384
+const BOTTOMK = 57385
+const TOPK = 57386
+const BYTES_OVER_TIME = 57387
+const BYTES_RATE = 57388
+const BOOL = 57389
+const JSON = 57390
+const REGEXP = 57391
+const LOGFMT = 57392
+const PIPE = 57393
+const LINE_FMT = 57394
+const LABEL_FMT = 57395
+const UNWRAP = 57396
+const AVG_OVER_TIME = 57397
+const SUM_OVER_TIME = 57398
+const MIN_OVER_TIME = 57399
+const MAX_OVER_TIME = 57400
+const STDVAR_OVER_TIME = 57401
+const STDDEV_OVER_TIME = 57402
+const QUANTILE_OVER_TIME = 57403
+const BYTES_CONV = 57404
+const DURATION_CONV = 57405
+const DURATION_SECONDS_CONV = 57406
+const FIRST_OVER_TIME = 57407
+const LAST_OVER_TIME = 57408
+const ABSENT_OVER_TIME = 57409
+const VECTOR = 57410
+const LABEL_REPLACE = 57411
+const UNPACK = 57412
+const OFFSET = 57413
+const PATTERN = 57414
+const IP = 57415
+const ON = 57416
+const IGNORING = 57417
+const GROUP_LEFT = 57418
+const GROUP_RIGHT = 57419
+const DECOLORIZE = 57420
+const DROP = 57421
+const KEEP = 57422
+const HISTOGRAM_OVER_TIME = 57423
+const AT = 57424
+const START = 57425
+const END = 57426
+const LOOKUP = 57427
+const CSV = 57428
+const DELIMITED = 57429
+const XML = 57430
+const OR = 57431
+const AND = 57432
+const UNLESS = 57433
+const CMP_EQ = 57434
+const NEQ = 57435
+const LT = 57436
+const LTE = 57437
+const GT = 57438
+const GTE = 57439
+const ADD = 57440
+const SUB = 57441
+const MUL = 57442
+const DIV = 57443
+const MOD = 57444
+const POW = 57445
 
 var exprToknames = [...]string{
 	"$end",
@@ -182,6 +192,7 @@ var exprToknames = [...]string{
 	"PARSER_FLAG",
 	"DURATION",
 	"RANGE",
+	"SUBQUERY_RANGE",
 	"MATCHERS",
 	"LABELS",
 	"EQ",
@@ -251,6 +262,14 @@ var exprToknames = [...]string{
 	"DECOLORIZE",
 	"DROP",
 	"KEEP",
+	"HISTOGRAM_OVER_TIME",
+	"AT",
+	"START",
+	"END",
+	"LOOKUP",
+	"CSV",
+	"DELIMITED",
+	"XML",
 	"OR",
 	"AND",
 	"UNLESS",
@@ -274,9 +293,6 @@ const exprEofCode = 1
 const exprErrCode = 2
 const exprInitialStackSize = 16
 
-//line expr.y:582
-
-//line yacctab:1
 var exprExca = [...]int8{
 	-1, 1,
 	1, -1,
@@ -285,124 +301,144 @@ var exprExca = [...]int8{
 
 const exprPrivate = 57344
 
-const exprLast = 639
+const exprLast = 779
 
 var exprAct = [...]int16{
-	289, 228, 84, 4, 214, 64, 182, 126, 204, 189,
-	75, 200, 197, 63, 237, 5, 152, 187, 77, 2,
-	56, 80, 48, 49, 50, 57, 58, 61, 62, 59,
-	60, 51, 52, 53, 54, 55, 56, 283, 10, 49,
-	50, 57, 58, 61, 62, 59, 60, 51, 52, 53,
-	54, 55, 56, 57, 58, 61, 62, 59, 60, 51,
-	52, 53, 54, 55, 56, 53, 54, 55, 56, 109,
-	217, 139, 215, 115, 51, 52, 53, 54, 55, 56,
-	266, 140, 221, 16, 216, 265, 292, 156, 136, 166,
-	167, 297, 262, 161, 220, 16, 294, 261, 154, 148,
-	150, 151, 366, 281, 184, 339, 16, 67, 280, 130,
-	163, 207, 150, 151, 168, 169, 170, 171, 172, 173,
-	174, 175, 176, 177, 178, 179, 180, 181, 278, 164,
-	165, 16, 366, 277, 239, 94, 293, 194, 142, 386,
-	191, 275, 202, 206, 16, 294, 274, 142, 264, 340,
-	85, 86, 292, 141, 272, 219, 316, 16, 381, 271,
-	260, 306, 235, 185, 183, 17, 18, 356, 229, 224,
-	149, 231, 232, 110, 306, 240, 294, 17, 18, 136,
-	355, 213, 208, 211, 212, 209, 210, 374, 17, 18,
-	248, 249, 250, 269, 331, 184, 16, 306, 268, 373,
-	130, 255, 306, 354, 252, 342, 343, 344, 353, 136,
-	239, 72, 74, 17, 18, 371, 363, 339, 306, 69,
-	70, 71, 285, 384, 308, 184, 17, 18, 287, 290,
-	130, 296, 314, 299, 369, 109, 302, 115, 303, 17,
-	18, 291, 154, 288, 359, 300, 263, 267, 270, 273,
-	276, 279, 282, 349, 185, 183, 330, 294, 293, 310,
-	312, 315, 317, 318, 72, 74, 202, 206, 325, 320,
-	324, 295, 69, 70, 71, 346, 72, 74, 17, 18,
-	73, 306, 239, 239, 69, 70, 71, 307, 328, 136,
-	239, 332, 304, 334, 336, 224, 338, 109, 294, 230,
-	243, 337, 348, 333, 313, 311, 109, 239, 295, 350,
-	130, 230, 241, 72, 74, 83, 224, 85, 86, 292,
-	301, 69, 70, 71, 233, 347, 144, 13, 136, 238,
-	143, 327, 153, 73, 360, 361, 155, 326, 284, 109,
-	362, 225, 13, 247, 184, 73, 364, 365, 230, 130,
-	246, 155, 370, 245, 244, 218, 160, 159, 158, 227,
-	16, 90, 89, 82, 72, 74, 376, 380, 377, 378,
-	13, 352, 69, 70, 71, 253, 298, 305, 259, 6,
-	382, 258, 73, 21, 22, 23, 36, 45, 46, 37,
-	39, 40, 38, 41, 42, 43, 44, 24, 25, 230,
-	256, 242, 234, 226, 183, 257, 254, 26, 27, 28,
-	29, 30, 31, 32, 81, 379, 368, 33, 34, 35,
-	47, 19, 236, 227, 367, 162, 345, 79, 72, 74,
-	335, 190, 13, 73, 251, 88, 69, 70, 71, 322,
-	323, 6, 17, 18, 87, 21, 22, 23, 36, 45,
-	46, 37, 39, 40, 38, 41, 42, 43, 44, 24,
-	25, 190, 375, 230, 188, 385, 383, 372, 358, 26,
-	27, 28, 29, 30, 31, 32, 146, 357, 3, 33,
-	34, 35, 47, 19, 157, 76, 329, 319, 309, 286,
-	72, 74, 145, 321, 13, 147, 198, 73, 69, 70,
-	71, 223, 222, 6, 17, 18, 136, 21, 22, 23,
-	36, 45, 46, 37, 39, 40, 38, 41, 42, 43,
-	44, 24, 25, 221, 220, 230, 195, 130, 193, 192,
-	351, 26, 27, 28, 29, 30, 31, 32, 205, 136,
-	201, 33, 34, 35, 47, 19, 190, 81, 122, 123,
-	121, 198, 131, 133, 297, 72, 74, 127, 128, 73,
-	130, 113, 114, 69, 70, 71, 17, 18, 196, 118,
-	124, 203, 125, 120, 199, 91, 119, 117, 132, 134,
-	135, 122, 123, 121, 116, 131, 133, 186, 65, 137,
-	66, 129, 138, 111, 112, 93, 92, 11, 9, 20,
-	12, 15, 8, 124, 341, 125, 14, 7, 78, 68,
-	1, 132, 134, 135, 0, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 73, 95, 96, 97, 98, 99,
-	100, 101, 102, 103, 104, 105, 106, 107, 108,
+	321, 250, 208, 65, 232, 221, 64, 134, 202, 195,
+	217, 5, 86, 4, 214, 163, 253, 10, 260, 57,
+	76, 81, 3, 309, 200, 54, 55, 56, 57, 77,
+	49, 50, 51, 58, 59, 62, 63, 60, 61, 52,
+	53, 54, 55, 56, 57, 50, 51, 58, 59, 62,
+	63, 60, 61, 52, 53, 54, 55, 56, 57, 52,
+	53, 54, 55, 56, 57, 235, 148, 285, 111, 324,
+	314, 292, 68, 239, 17, 327, 291, 119, 179, 180,
+	225, 159, 160, 17, 145, 374, 288, 233, 238, 17,
+	326, 287, 177, 178, 13, 164, 415, 166, 243, 169,
+	161, 197, 234, 6, 96, 174, 138, 22, 23, 24,
+	37, 46, 47, 38, 40, 41, 39, 42, 43, 44,
+	45, 25, 26, 149, 390, 435, 326, 78, 2, 415,
+	262, 27, 28, 29, 30, 31, 32, 33, 430, 112,
+	290, 34, 35, 36, 48, 20, 315, 316, 211, 324,
+	219, 223, 204, 351, 151, 286, 207, 15, 231, 226,
+	229, 230, 227, 228, 237, 18, 19, 341, 198, 196,
+	325, 248, 150, 400, 18, 19, 423, 252, 245, 76,
+	18, 19, 87, 88, 307, 258, 422, 17, 77, 306,
+	151, 420, 263, 58, 59, 62, 63, 60, 61, 52,
+	53, 54, 55, 56, 57, 406, 271, 272, 273, 304,
+	405, 326, 17, 301, 303, 404, 17, 298, 300, 393,
+	17, 176, 297, 381, 275, 181, 182, 183, 184, 185,
+	186, 187, 188, 189, 190, 191, 192, 193, 194, 372,
+	311, 410, 243, 289, 293, 296, 299, 302, 305, 308,
+	320, 322, 111, 368, 330, 332, 323, 313, 164, 328,
+	166, 119, 318, 317, 157, 159, 160, 335, 371, 337,
+	295, 338, 333, 17, 262, 294, 145, 339, 18, 19,
+	383, 384, 385, 145, 145, 353, 345, 347, 350, 352,
+	362, 219, 223, 197, 361, 341, 357, 349, 138, 280,
+	197, 399, 266, 18, 19, 138, 138, 18, 19, 73,
+	75, 18, 19, 256, 366, 374, 325, 70, 71, 72,
+	373, 370, 245, 375, 243, 377, 379, 111, 376, 341,
+	387, 319, 111, 412, 389, 398, 380, 73, 75, 262,
+	262, 247, 391, 158, 251, 70, 71, 72, 392, 386,
+	331, 153, 341, 152, 394, 369, 326, 326, 397, 401,
+	198, 196, 348, 346, 18, 19, 243, 249, 196, 365,
+	341, 341, 251, 73, 75, 408, 343, 342, 409, 13,
+	111, 70, 71, 72, 407, 329, 74, 334, 336, 413,
+	414, 433, 244, 320, 330, 111, 145, 364, 13, 418,
+	310, 73, 75, 417, 245, 17, 419, 336, 251, 70,
+	71, 72, 425, 197, 74, 427, 13, 428, 138, 270,
+	387, 85, 111, 87, 88, 165, 262, 431, 262, 22,
+	23, 24, 37, 46, 47, 38, 40, 41, 39, 42,
+	43, 44, 45, 25, 26, 269, 245, 268, 429, 264,
+	74, 261, 267, 27, 28, 29, 30, 31, 32, 33,
+	73, 75, 236, 34, 35, 36, 48, 20, 70, 71,
+	72, 254, 173, 172, 259, 319, 171, 92, 74, 15,
+	91, 73, 75, 84, 83, 13, 278, 282, 396, 70,
+	71, 72, 276, 340, 6, 251, 18, 19, 22, 23,
+	24, 37, 46, 47, 38, 40, 41, 39, 42, 43,
+	44, 45, 25, 26, 284, 324, 251, 283, 281, 265,
+	257, 155, 27, 28, 29, 30, 31, 32, 33, 255,
+	246, 279, 34, 35, 36, 48, 20, 74, 154, 82,
+	277, 156, 426, 170, 434, 249, 424, 416, 15, 411,
+	388, 73, 75, 80, 13, 378, 359, 360, 74, 70,
+	71, 72, 175, 6, 168, 18, 19, 22, 23, 24,
+	37, 46, 47, 38, 40, 41, 39, 42, 43, 44,
+	45, 25, 26, 90, 203, 203, 251, 274, 201, 89,
+	432, 27, 28, 29, 30, 31, 32, 33, 421, 403,
+	402, 34, 35, 36, 48, 20, 209, 367, 358, 356,
+	355, 215, 162, 354, 344, 312, 241, 15, 240, 239,
+	238, 212, 206, 13, 205, 395, 363, 222, 74, 218,
+	203, 82, 165, 145, 18, 19, 22, 23, 24, 37,
+	46, 47, 38, 40, 41, 39, 42, 43, 44, 45,
+	25, 26, 224, 215, 210, 138, 167, 242, 135, 136,
+	27, 28, 29, 30, 31, 32, 33, 116, 118, 213,
+	34, 35, 36, 48, 20, 145, 127, 128, 126, 122,
+	139, 141, 327, 125, 220, 124, 15, 216, 123, 121,
+	120, 199, 66, 146, 137, 147, 113, 138, 129, 117,
+	130, 73, 75, 18, 19, 115, 140, 142, 143, 70,
+	71, 72, 93, 144, 132, 133, 131, 114, 127, 128,
+	126, 95, 139, 141, 94, 11, 9, 21, 12, 16,
+	8, 382, 14, 7, 79, 69, 67, 1, 0, 0,
+	129, 0, 130, 0, 0, 0, 0, 0, 140, 142,
+	143, 0, 0, 0, 0, 144, 132, 133, 131, 0,
+	0, 0, 0, 97, 98, 99, 100, 101, 102, 103,
+	104, 105, 106, 107, 108, 109, 110, 0, 74,
 }
 
 var exprPact = [...]int16{
-	353, -1000, -58, -1000, -1000, 540, 353, -1000, -1000, -1000,
-	-1000, -1000, -1000, 409, 337, 289, -1000, 437, 428, 336,
-	335, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	76, -1000, -59, -1000, -1000, 685, 76, -1000, -1000, -1000,
+	-1000, -1000, -1000, 534, 457, 456, 394, -1000, 582, 576,
+	453, 450, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
 	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, 89, 89,
-	89, 89, 89, 89, 89, 89, 89, 89, 89, 89,
-	89, 89, 89, 540, -1000, 196, 534, -9, 75, -1000,
-	-1000, -1000, -1000, -1000, -1000, 303, 299, -58, 474, -1000,
-	-1000, 86, 325, 477, 332, 331, 330, -1000, -1000, 353,
-	418, 353, 56, 14, -1000, 353, 353, 353, 353, 353,
-	353, 353, 353, 353, 353, 353, 353, 353, 353, -1000,
-	-1000, -1000, -1000, -1000, -1000, 83, -1000, -1000, -1000, -1000,
-	-1000, 456, 541, 523, -1000, 522, -1000, -1000, -1000, -1000,
-	284, 520, -1000, 546, 535, 533, 98, -1000, -1000, 66,
-	-10, 329, -1000, -1000, -1000, -1000, -1000, 542, 518, 517,
-	496, 495, 314, 382, 413, 310, 297, 381, 415, 302,
-	285, 380, 273, -42, 328, 327, 324, 317, -30, -30,
-	-26, -26, -74, -74, -74, -74, -15, -15, -15, -15,
-	-15, -15, 83, 284, 284, 284, 426, 354, -1000, -1000,
-	393, 354, -1000, -1000, 174, -1000, 379, -1000, 392, 360,
-	-1000, 86, -1000, 357, -1000, 86, -1000, 88, 76, 189,
-	150, 137, 124, 99, -1000, -43, 312, 66, 483, -1000,
-	-1000, -1000, -1000, -1000, -1000, 122, 310, 249, 126, 261,
-	501, 349, 293, 122, 353, 265, 356, 260, -1000, -1000,
-	197, -1000, 482, -1000, 278, 277, 205, 129, 204, 83,
-	323, -1000, 354, 541, 481, -1000, 491, 434, 535, 533,
-	311, -1000, -1000, -1000, 305, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
-	-1000, -1000, -1000, 66, 480, -1000, 229, -1000, 167, 475,
-	46, 475, 421, 16, 284, 16, 95, 144, 416, 248,
-	298, -1000, -1000, 226, -1000, 353, 525, -1000, -1000, 350,
-	181, -1000, 176, -1000, -1000, 153, -1000, 140, -1000, -1000,
-	-1000, -1000, -1000, -1000, -1000, -1000, 471, 462, -1000, 217,
-	-1000, 122, 46, 475, 46, -1000, -1000, 83, -1000, 16,
-	-1000, 190, -1000, -1000, -1000, 82, 414, 406, 207, 122,
-	188, -1000, 461, -1000, -1000, -1000, -1000, 172, 160, -1000,
-	-1000, 46, -1000, 457, 52, 46, 38, 16, 16, 405,
-	-1000, -1000, 346, -1000, -1000, 131, 46, -1000, -1000, 16,
-	460, -1000, -1000, 202, 459, 112, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, 57,
+	57, 57, 57, 57, 57, 57, 57, 57, 57, 57,
+	57, 57, 57, 57, 685, -1000, 385, 670, -23, 117,
+	-1000, -1000, -1000, -1000, -1000, -1000, 325, 323, -59, 519,
+	-1000, -1000, 250, 605, 557, 536, 449, 446, 445, -1000,
+	-1000, 76, 555, 76, 18, 2, -1000, 76, 76, 76,
+	76, 76, 76, 76, 76, 76, 76, 76, 76, 76,
+	76, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, 79,
+	-1000, -1000, -1000, -1000, -1000, -1000, 580, 625, 618, -1000,
+	616, 625, 600, 649, -1000, -1000, -1000, -1000, 279, 615,
+	-1000, 648, 624, 622, 647, 66, -1000, -1000, 81, -24,
+	435, -1000, -1000, -1000, -1000, -1000, 626, 614, 613, 612,
+	610, 364, 508, 313, 535, 398, 460, 507, -1000, 285,
+	498, 467, 423, 421, 497, 274, -45, 425, 420, 418,
+	392, 101, 101, -75, -75, -84, -84, -84, -84, -39,
+	-39, -39, -39, -39, -39, 79, 279, 279, 279, 579,
+	470, -1000, -1000, 526, 470, -1000, -1000, 470, 464, -1000,
+	517, 271, -1000, 496, -1000, 473, 495, -1000, 250, -1000,
+	492, -1000, 250, -1000, -7, 82, 67, 266, 213, 209,
+	205, 180, -1000, -66, 373, 81, 609, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, 153, 63, 398, -1000, 465, 444,
+	160, 628, 357, 322, -2, 380, 153, 76, 249, 471,
+	349, -1000, -1000, 348, -1000, 608, -1000, 335, 334, 269,
+	125, 391, 79, 278, -1000, 470, 625, 607, 604, 603,
+	-1000, 606, 551, 624, 622, 621, 370, -1000, -1000, -1000,
+	342, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, -1000, 81,
+	601, -1000, 225, -1000, -1000, 328, 294, 240, 211, -2,
+	75, 293, 39, 293, 546, -2, 279, 218, 321, 540,
+	306, -1000, -1000, 96, -1000, 535, 361, -1000, 191, -1000,
+	76, 620, -1000, -1000, 466, 330, -1000, 307, -1000, -1000,
+	273, -1000, 145, -1000, -1000, -1000, 600, -1000, -1000, -1000,
+	-1000, -1000, -1000, -1000, 594, 593, -1000, 187, -1000, 182,
+	177, 153, -1000, -1000, -2, 39, 293, 39, -1000, -1000,
+	79, -1000, 214, -1000, -1000, -1000, 539, 305, 78, 537,
+	153, 465, 357, 153, 163, -1000, 592, -1000, -1000, -1000,
+	-1000, 464, 158, 148, -1000, -1000, -1000, -1000, -1000, 39,
+	541, -2, 532, 45, 39, 21, -2, -1000, 321, -1000,
+	-1000, 426, -1000, -1000, 110, -1000, -2, 39, -1000, 584,
+	-1000, -1000, 369, 538, 97, -1000,
 }
 
 var exprPgo = [...]int16{
-	0, 610, 18, 609, 2, 14, 478, 3, 16, 7,
-	608, 607, 606, 604, 15, 602, 601, 600, 599, 84,
-	598, 38, 597, 575, 596, 595, 594, 593, 13, 5,
-	592, 591, 589, 6, 588, 107, 4, 587, 584, 577,
-	576, 574, 11, 573, 571, 8, 569, 12, 568, 9,
-	17, 562, 561, 1, 558, 557, 0,
+	0, 737, 127, 735, 12, 18, 22, 13, 16, 15,
+	7, 734, 733, 732, 731, 11, 730, 729, 728, 727,
+	102, 726, 17, 725, 712, 724, 721, 717, 705, 699,
+	2, 696, 6, 3, 695, 694, 693, 9, 692, 72,
+	4, 691, 690, 689, 688, 687, 10, 685, 684, 5,
+	683, 679, 14, 669, 8, 24, 668, 667, 1, 659,
+	658, 0, 657, 656,
 }
 
 var exprR1 = [...]int8{
@@ -410,25 +446,28 @@ var exprR1 = [...]int8{
 	7, 6, 6, 6, 8, 8, 8, 8, 8, 8,
 	8, 8, 8, 8, 8, 8, 8, 8, 8, 8,
 	8, 8, 8, 8, 8, 8, 8, 8, 8, 8,
-	53, 53, 53, 13, 13, 13, 11, 11, 11, 11,
-	15, 15, 15, 15, 15, 15, 22, 3, 3, 3,
-	3, 3, 3, 14, 14, 14, 10, 10, 9, 9,
-	9, 9, 28, 28, 29, 29, 29, 29, 29, 29,
-	29, 29, 29, 29, 29, 19, 36, 36, 36, 35,
-	35, 35, 34, 34, 34, 37, 37, 27, 27, 26,
-	26, 26, 26, 52, 51, 51, 38, 39, 47, 47,
-	48, 48, 48, 46, 33, 33, 33, 33, 33, 33,
-	33, 33, 33, 49, 49, 50, 50, 55, 55, 54,
-	54, 32, 32, 32, 32, 32, 32, 32, 30, 30,
-	30, 30, 30, 30, 30, 31, 31, 31, 31, 31,
-	31, 31, 42, 42, 41, 41, 40, 45, 45, 44,
-	44, 43, 20, 20, 20, 20, 20, 20, 20, 20,
-	20, 20, 20, 20, 20, 20, 20, 24, 24, 25,
-	25, 25, 25, 23, 23, 23, 23, 23, 23, 23,
-	23, 21, 21, 21, 17, 18, 16, 16, 16, 16,
-	16, 16, 16, 16, 16, 16, 16, 12, 12, 12,
-	12, 12, 12, 12, 12, 12, 12, 12, 12, 12,
-	12, 12, 56, 5, 5, 4, 4, 4, 4,
+	8, 9, 9, 58, 58, 58, 14, 14, 14, 12,
+	12, 12, 12, 12, 12, 12, 12, 63, 63, 16,
+	16, 16, 16, 16, 16, 23, 3, 3, 3, 3,
+	3, 3, 15, 15, 15, 11, 11, 10, 10, 10,
+	10, 32, 32, 33, 33, 33, 33, 33, 33, 33,
+	33, 33, 33, 33, 33, 33, 33, 20, 40, 40,
+	40, 39, 39, 39, 38, 38, 38, 41, 41, 31,
+	31, 27, 27, 27, 27, 27, 28, 28, 30, 30,
+	57, 29, 56, 56, 42, 43, 52, 52, 53, 53,
+	53, 51, 37, 37, 37, 37, 37, 37, 37, 37,
+	37, 54, 54, 55, 55, 60, 60, 59, 59, 36,
+	36, 36, 36, 36, 36, 36, 34, 34, 34, 34,
+	34, 34, 34, 35, 35, 35, 35, 35, 35, 35,
+	46, 46, 45, 45, 44, 49, 49, 48, 48, 47,
+	50, 21, 21, 21, 21, 21, 21, 21, 21, 21,
+	21, 21, 21, 21, 21, 21, 25, 25, 26, 26,
+	26, 26, 24, 24, 24, 24, 24, 24, 24, 24,
+	22, 22, 22, 18, 19, 17, 17, 17, 17, 17,
+	17, 17, 17, 17, 17, 17, 13, 13, 13, 13,
+	13, 13, 13, 13, 13, 13, 13, 13, 13, 13,
+	13, 61, 62, 62, 62, 5, 5, 4, 4, 4,
+	4,
 }
 
 var exprR2 = [...]int8{
@@ -436,109 +475,122 @@ var exprR2 = [...]int8{
 	3, 1, 2, 3, 2, 3, 4, 5, 3, 4,
 	5, 6, 3, 4, 5, 6, 3, 4, 5, 6,
 	4, 5, 6, 7, 3, 4, 4, 5, 3, 2,
-	3, 6, 3, 1, 1, 1, 4, 6, 5, 7,
-	4, 5, 5, 6, 7, 7, 12, 1, 1, 1,
-	1, 1, 1, 3, 3, 2, 1, 3, 3, 3,
-	3, 3, 1, 2, 1, 2, 2, 2, 2, 2,
-	2, 2, 2, 2, 2, 1, 1, 4, 3, 2,
-	5, 4, 1, 3, 2, 1, 2, 1, 2, 1,
-	2, 1, 2, 2, 3, 2, 2, 1, 3, 3,
-	1, 3, 3, 2, 1, 1, 1, 1, 3, 2,
-	3, 3, 3, 3, 1, 1, 3, 6, 6, 1,
-	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	2, 2, 3, 3, 6, 3, 1, 1, 1, 4,
+	6, 5, 7, 4, 6, 6, 7, 1, 3, 4,
+	5, 5, 6, 7, 7, 12, 1, 1, 1, 1,
+	1, 1, 3, 3, 2, 1, 3, 3, 3, 3,
+	3, 1, 2, 1, 2, 2, 2, 2, 2, 2,
+	2, 2, 2, 2, 2, 2, 2, 1, 1, 4,
+	3, 2, 5, 4, 1, 3, 2, 1, 2, 1,
+	2, 1, 2, 1, 2, 1, 2, 5, 1, 3,
+	2, 2, 3, 2, 2, 1, 3, 3, 1, 3,
+	3, 2, 1, 1, 1, 1, 3, 2, 3, 3,
+	3, 3, 1, 1, 3, 6, 6, 1, 1, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
 	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
-	3, 3, 1, 1, 1, 3, 2, 1, 1, 1,
-	3, 2, 4, 4, 4, 4, 4, 4, 4, 4,
-	4, 4, 4, 4, 4, 4, 4, 0, 1, 5,
-	4, 5, 4, 1, 1, 2, 4, 5, 2, 4,
-	5, 1, 2, 2, 4, 1, 1, 1, 1, 1,
+	1, 1, 1, 3, 2, 1, 1, 1, 3, 2,
+	4, 4, 4, 4, 4, 4, 4, 4, 4, 4,
+	4, 4, 4, 4, 4, 4, 0, 1, 5, 4,
+	5, 4, 1, 1, 2, 4, 5, 2, 4, 5,
+	1, 2, 2, 4, 1, 1, 1, 1, 1, 1,
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
 	1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
-	1, 1, 2, 1, 3, 4, 4, 3, 3,
+	1, 2, 2, 4, 4, 1, 3, 4, 4, 3,
+	3,
 }
 
 var exprChk = [...]int16{
-	-1000, -1, -2, -6, -7, -14, 26, -11, -15, -20,
-	-21, -22, -17, 17, -12, -16, 7, 89, 90, 68,
-	-18, 30, 31, 32, 44, 45, 54, 55, 56, 57,
-	58, 59, 60, 64, 65, 66, 33, 36, 39, 37,
-	38, 40, 41, 42, 43, 34, 35, 67, 80, 81,
-	82, 89, 90, 91, 92, 93, 94, 83, 84, 87,
-	88, 85, 86, -28, -29, -34, 50, -35, -3, 23,
-	24, 25, 15, 84, 16, -7, -6, -2, -10, 18,
-	-9, 5, 26, 26, -4, 28, 29, 7, 7, 26,
-	26, -23, -24, -25, 46, -23, -23, -23, -23, -23,
-	-23, -23, -23, -23, -23, -23, -23, -23, -23, -29,
-	-35, -27, -26, -52, -51, -33, -38, -39, -46, -40,
-	-43, 49, 47, 48, 69, 71, -9, -55, -54, -31,
-	26, 51, 77, 52, 78, 79, 5, -32, -30, 80,
-	6, -19, 72, 27, 27, 18, 2, 21, 13, 84,
-	14, 15, -8, 7, -14, 26, -7, 7, 26, 26,
-	26, -7, 7, -2, 73, 74, 75, 76, -2, -2,
-	-2, -2, -2, -2, -2, -2, -2, -2, -2, -2,
-	-2, -2, -33, 81, 21, 80, -37, -50, 8, -49,
-	5, -50, 6, 6, -33, 6, -48, -47, 5, -41,
-	-42, 5, -9, -44, -45, 5, -9, 13, 84, 87,
-	88, 85, 86, 83, -36, 6, -19, 80, 26, -9,
-	6, 6, 6, 6, 2, 27, 21, 10, -53, -28,
-	50, -14, -8, 27, 21, -7, 7, -5, 27, 5,
-	-5, 27, 21, 27, 26, 26, 26, 26, -33, -33,
-	-33, 8, -50, 21, 13, 27, 21, 13, 21, 21,
-	72, 9, 4, -21, 72, 9, 4, -21, 9, 4,
-	-21, 9, 4, -21, 9, 4, -21, 9, 4, -21,
-	9, 4, -21, 80, 26, -36, 6, -4, -8, -56,
-	-53, -28, 70, 10, 50, 10, -53, 53, 27, -53,
-	-28, 27, -4, -7, 27, 21, 21, 27, 27, 6,
-	-5, 27, -5, 27, 27, -5, 27, -5, -49, 6,
-	-47, 2, 5, 6, -42, -45, 26, 26, -36, 6,
-	27, 27, -53, -28, -53, 9, -56, -33, -56, 10,
-	5, -13, 61, 62, 63, 10, 27, 27, -53, 27,
-	-7, 5, 21, 27, 27, 27, 27, 6, 6, 27,
-	-4, -53, -56, 26, -56, -53, 50, 10, 10, 27,
-	-4, 27, 6, 27, 27, 5, -53, -56, -56, 10,
-	21, 27, -56, 6, 21, 6, 27,
+	-1000, -1, -2, -6, -7, -15, 27, -12, -16, -21,
+	-22, -23, -18, 18, -13, 81, -17, 7, 98, 99,
+	69, -19, 31, 32, 33, 45, 46, 55, 56, 57,
+	58, 59, 60, 61, 65, 66, 67, 34, 37, 40,
+	38, 39, 41, 42, 43, 44, 35, 36, 68, 89,
+	90, 91, 98, 99, 100, 101, 102, 103, 92, 93,
+	96, 97, 94, 95, -32, -33, -38, 51, -39, -3,
+	24, 25, 26, 16, 93, 17, -7, -6, -2, -11,
+	19, -10, 5, 27, 27, 27, -4, 29, 30, 7,
+	7, 27, 27, -24, -25, -26, 47, -24, -24, -24,
+	-24, -24, -24, -24, -24, -24, -24, -24, -24, -24,
+	-24, -33, -39, -31, -27, -28, -57, -29, -56, -37,
+	-42, -43, -51, -44, -47, -50, 50, 48, 49, 70,
+	72, 88, 86, 87, -10, -60, -59, -35, 27, 52,
+	78, 53, 79, 80, 85, 5, -36, -34, 89, 6,
+	-20, 73, 28, 28, 19, 2, 22, 14, 93, 15,
+	16, -8, 7, -9, -15, 27, -7, -63, 7, -7,
+	7, 27, 27, 27, -7, 7, -2, 74, 75, 76,
+	77, -2, -2, -2, -2, -2, -2, -2, -2, -2,
+	-2, -2, -2, -2, -2, -37, 90, 22, 89, -41,
+	-55, 8, -54, 5, -55, 6, 6, -55, -30, 6,
+	5, -37, 6, -53, -52, 5, -45, -46, 5, -10,
+	-48, -49, 5, -10, 5, 14, 93, 96, 97, 94,
+	95, 92, -40, 6, -20, 89, 27, -10, 6, 6,
+	6, 6, -62, 2, 28, 82, 22, 28, -32, 10,
+	-58, 51, -15, -8, 11, 22, 28, 22, -7, 7,
+	-5, 28, 5, -5, 28, 22, 28, 27, 27, 27,
+	27, -37, -37, -37, 8, -55, 22, 14, 22, 14,
+	28, 22, 14, 22, 22, 74, 73, 9, 4, -22,
+	73, 9, 4, -22, 9, 4, -22, 9, 4, -22,
+	9, 4, -22, 9, 4, -22, 9, 4, -22, 89,
+	27, -40, 6, -4, 7, 83, 84, -8, -9, 10,
+	-58, -61, -58, -32, 71, 10, 51, 54, -32, 28,
+	-58, 28, -61, -8, 7, -15, 27, -4, -7, 28,
+	22, 22, 28, 28, 6, -5, 28, -5, 28, 28,
+	-5, 28, -5, -54, 6, 6, 6, -52, 2, 5,
+	6, -46, -49, 5, 27, 27, -40, 6, 28, 27,
+	27, 28, 28, -61, 10, -58, -32, -58, 9, -61,
+	-37, 5, -14, 62, 63, 64, 28, -58, 10, 28,
+	28, -32, -15, 28, -7, 5, 22, 28, 28, 28,
+	28, -30, 6, 6, 28, 28, 28, -4, -61, -58,
+	27, 10, 28, -61, -58, 51, 10, -4, -32, -4,
+	28, 6, 28, 28, 5, -61, 10, -58, -61, 22,
+	28, -61, 6, 22, 6, 28,
 }
 
 var exprDef = [...]int16{
 	0, -2, 1, 2, 3, 11, 0, 4, 5, 6,
-	7, 8, 9, 0, 0, 0, 191, 0, 0, 0,
-	0, 207, 208, 209, 210, 211, 212, 213, 214, 215,
-	216, 217, 218, 219, 220, 221, 196, 197, 198, 199,
-	200, 201, 202, 203, 204, 205, 206, 195, 177, 177,
-	177, 177, 177, 177, 177, 177, 177, 177, 177, 177,
-	177, 177, 177, 12, 72, 74, 0, 92, 0, 57,
-	58, 59, 60, 61, 62, 3, 2, 0, 0, 65,
-	66, 0, 0, 0, 0, 0, 0, 192, 193, 0,
-	0, 0, 183, 184, 178, 0, 0, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 0, 0, 0, 73,
-	94, 75, 76, 77, 78, 79, 80, 81, 82, 83,
-	84, 97, 99, 0, 101, 0, 114, 115, 116, 117,
-	0, 0, 107, 0, 0, 0, 0, 129, 130, 0,
-	89, 0, 85, 10, 13, 63, 64, 0, 0, 0,
-	0, 0, 0, 0, 0, 0, 3, 191, 0, 0,
-	0, 3, 0, 162, 0, 0, 185, 188, 163, 164,
-	165, 166, 167, 168, 169, 170, 171, 172, 173, 174,
-	175, 176, 119, 0, 0, 0, 98, 105, 95, 125,
-	124, 103, 100, 102, 0, 106, 113, 110, 0, 156,
-	154, 152, 153, 161, 159, 157, 158, 0, 0, 0,
-	0, 0, 0, 0, 93, 86, 0, 0, 0, 67,
-	68, 69, 70, 71, 39, 46, 0, 14, 0, 0,
-	0, 0, 0, 50, 0, 3, 191, 0, 227, 223,
-	0, 228, 0, 194, 0, 0, 0, 0, 120, 121,
-	122, 96, 104, 0, 0, 118, 0, 0, 0, 0,
-	0, 136, 143, 150, 0, 135, 142, 149, 131, 138,
-	145, 132, 139, 146, 133, 140, 147, 134, 141, 148,
-	137, 144, 151, 0, 0, 91, 0, 48, 0, 15,
-	18, 34, 0, 22, 0, 26, 0, 0, 0, 0,
-	0, 38, 52, 3, 51, 0, 0, 225, 226, 0,
-	0, 180, 0, 182, 186, 0, 189, 0, 126, 123,
-	111, 112, 108, 109, 155, 160, 0, 0, 88, 0,
-	90, 47, 19, 35, 36, 222, 23, 42, 27, 30,
-	40, 0, 43, 44, 45, 16, 0, 0, 0, 53,
-	3, 224, 0, 179, 181, 187, 190, 0, 0, 87,
-	49, 37, 31, 0, 17, 20, 0, 24, 28, 0,
-	54, 55, 0, 127, 128, 0, 21, 25, 29, 32,
-	0, 41, 33, 0, 0, 0, 56,
+	7, 8, 9, 0, 0, 0, 0, 210, 0, 0,
+	0, 0, 226, 227, 228, 229, 230, 231, 232, 233,
+	234, 235, 236, 237, 238, 239, 240, 215, 216, 217,
+	218, 219, 220, 221, 222, 223, 224, 225, 214, 196,
+	196, 196, 196, 196, 196, 196, 196, 196, 196, 196,
+	196, 196, 196, 196, 12, 81, 83, 0, 104, 0,
+	66, 67, 68, 69, 70, 71, 3, 2, 0, 0,
+	74, 75, 0, 0, 0, 0, 0, 0, 0, 211,
+	212, 0, 0, 0, 202, 203, 197, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 82, 106, 84, 85, 86, 87, 88, 89, 90,
+	91, 92, 93, 94, 95, 96, 109, 111, 0, 113,
+	0, 115, 0, 0, 132, 133, 134, 135, 0, 0,
+	125, 0, 0, 0, 0, 0, 147, 148, 0, 101,
+	0, 97, 10, 13, 72, 73, 0, 0, 0, 0,
+	0, 0, 210, 0, 11, 0, 3, 0, 57, 3,
+	210, 0, 0, 0, 3, 0, 181, 0, 0, 204,
+	207, 182, 183, 184, 185, 186, 187, 188, 189, 190,
+	191, 192, 193, 194, 195, 137, 0, 0, 0, 110,
+	123, 107, 143, 142, 120, 112, 114, 121, 116, 118,
+	0, 0, 124, 131, 128, 0, 174, 172, 170, 171,
+	179, 177, 175, 176, 0, 0, 0, 0, 0, 0,
+	0, 0, 105, 98, 0, 0, 0, 76, 77, 78,
+	79, 80, 39, 40, 49, 0, 0, 53, 12, 14,
+	0, 0, 11, 0, 41, 0, 59, 0, 3, 210,
+	0, 249, 245, 0, 250, 0, 213, 0, 0, 0,
+	0, 138, 139, 140, 108, 122, 0, 0, 0, 0,
+	136, 0, 0, 0, 0, 0, 0, 154, 161, 168,
+	0, 153, 160, 167, 149, 156, 163, 150, 157, 164,
+	151, 158, 165, 152, 159, 166, 155, 162, 169, 0,
+	0, 103, 0, 51, 242, 0, 0, 0, 0, 26,
+	0, 15, 18, 34, 0, 22, 0, 0, 12, 0,
+	0, 38, 42, 0, 58, 0, 0, 61, 3, 60,
+	0, 0, 247, 248, 0, 0, 199, 0, 201, 205,
+	0, 208, 0, 144, 141, 119, 0, 129, 130, 126,
+	127, 173, 178, 180, 0, 0, 100, 0, 102, 0,
+	0, 50, 54, 27, 30, 19, 35, 36, 241, 23,
+	45, 43, 0, 46, 47, 48, 0, 0, 16, 0,
+	55, 0, 0, 62, 3, 246, 0, 198, 200, 206,
+	209, 117, 0, 0, 99, 243, 244, 52, 31, 37,
+	0, 28, 0, 17, 20, 0, 24, 56, 0, 63,
+	64, 0, 145, 146, 0, 29, 32, 21, 25, 0,
+	44, 33, 0, 0, 0, 65,
 }
 
 var exprTok1 = [...]int8{
@@ -555,7 +607,8 @@ var exprTok2 = [...]int8{
 	62, 63, 64, 65, 66, 67, 68, 69, 70, 71,
 	72, 73, 74, 75, 76, 77, 78, 79, 80, 81,
 	82, 83, 84, 85, 86, 87, 88, 89, 90, 91,
-	92, 93, 94,
+	92, 93, 94, 95, 96, 97, 98, 99, 100, 101,
+	102, 103,
 }
 
 var exprTok3 = [...]int8{
@@ -568,8 +621,6 @@ var exprErrorMessages = [...]struct {
 	msg   string
 }{}
 
-//line yaccpar:1
-
 /*	parser for yacc output	*/
 
 var (
@@ -901,1368 +952,1252 @@ exprdefault:
 
 	case 1:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:154
 		{
 			exprlex.(*parser).expr = exprDollar[1].Expr
 		}
 	case 2:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:157
 		{
 			exprVAL.Expr = exprDollar[1].LogExpr
 		}
 	case 3:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:158
 		{
 			exprVAL.Expr = exprDollar[1].MetricExpr
 		}
 	case 4:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:162
 		{
 			exprVAL.MetricExpr = exprDollar[1].RangeAggregationExpr
 		}
 	case 5:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:163
 		{
 			exprVAL.MetricExpr = exprDollar[1].VectorAggregationExpr
 		}
 	case 6:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:164
 		{
 			exprVAL.MetricExpr = exprDollar[1].BinOpExpr
 		}
 	case 7:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:165
 		{
 			exprVAL.MetricExpr = exprDollar[1].LiteralExpr
 		}
 	case 8:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:166
 		{
 			exprVAL.MetricExpr = exprDollar[1].LabelReplaceExpr
 		}
 	case 9:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:167
 		{
 			exprVAL.MetricExpr = exprDollar[1].VectorExpr
 		}
 	case 10:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:168
 		{
 			exprVAL.MetricExpr = exprDollar[2].MetricExpr
 		}
 	case 11:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:172
 		{
 			exprVAL.LogExpr = newMatcherExpr(exprDollar[1].Selector)
 		}
 	case 12:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:173
 		{
 			exprVAL.LogExpr = newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].PipelineExpr)
 		}
 	case 13:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:174
 		{
 			exprVAL.LogExpr = exprDollar[2].LogExpr
 		}
 	case 14:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:178
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].duration, nil, nil)
 		}
 	case 15:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:179
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].duration, nil, exprDollar[3].OffsetExpr)
 		}
 	case 16:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:180
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[2].Selector), exprDollar[4].duration, nil, nil)
 		}
 	case 17:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:181
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[2].Selector), exprDollar[4].duration, nil, exprDollar[5].OffsetExpr)
 		}
 	case 18:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:182
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].duration, exprDollar[3].UnwrapExpr, nil)
 		}
 	case 19:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:183
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].duration, exprDollar[4].UnwrapExpr, exprDollar[3].OffsetExpr)
 		}
 	case 20:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:184
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[2].Selector), exprDollar[4].duration, exprDollar[5].UnwrapExpr, nil)
 		}
 	case 21:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:185
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[2].Selector), exprDollar[4].duration, exprDollar[6].UnwrapExpr, exprDollar[5].OffsetExpr)
 		}
 	case 22:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:186
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[1].Selector), exprDollar[3].duration, exprDollar[2].UnwrapExpr, nil)
 		}
 	case 23:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:187
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[1].Selector), exprDollar[3].duration, exprDollar[2].UnwrapExpr, exprDollar[4].OffsetExpr)
 		}
 	case 24:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:188
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[2].Selector), exprDollar[5].duration, exprDollar[3].UnwrapExpr, nil)
 		}
 	case 25:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:189
 		{
 			exprVAL.LogRangeExpr = newLogRange(newMatcherExpr(exprDollar[2].Selector), exprDollar[5].duration, exprDollar[3].UnwrapExpr, exprDollar[6].OffsetExpr)
 		}
 	case 26:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:190
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].PipelineExpr), exprDollar[3].duration, nil, nil)
 		}
 	case 27:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:191
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].PipelineExpr), exprDollar[3].duration, nil, exprDollar[4].OffsetExpr)
 		}
 	case 28:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:192
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[2].Selector), exprDollar[3].PipelineExpr), exprDollar[5].duration, nil, nil)
 		}
 	case 29:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:193
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[2].Selector), exprDollar[3].PipelineExpr), exprDollar[5].duration, nil, exprDollar[6].OffsetExpr)
 		}
 	case 30:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:194
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].PipelineExpr), exprDollar[4].duration, exprDollar[3].UnwrapExpr, nil)
 		}
 	case 31:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:195
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[2].PipelineExpr), exprDollar[4].duration, exprDollar[3].UnwrapExpr, exprDollar[5].OffsetExpr)
 		}
 	case 32:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:196
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[2].Selector), exprDollar[3].PipelineExpr), exprDollar[6].duration, exprDollar[4].UnwrapExpr, nil)
 		}
 	case 33:
 		exprDollar = exprS[exprpt-7 : exprpt+1]
-//line expr.y:197
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[2].Selector), exprDollar[3].PipelineExpr), exprDollar[6].duration, exprDollar[4].UnwrapExpr, exprDollar[7].OffsetExpr)
 		}
 	case 34:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:198
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[3].PipelineExpr), exprDollar[2].duration, nil, nil)
 		}
 	case 35:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:199
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[4].PipelineExpr), exprDollar[2].duration, nil, exprDollar[3].OffsetExpr)
 		}
 	case 36:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:200
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[3].PipelineExpr), exprDollar[2].duration, exprDollar[4].UnwrapExpr, nil)
 		}
 	case 37:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:201
 		{
 			exprVAL.LogRangeExpr = newLogRange(newPipelineExpr(newMatcherExpr(exprDollar[1].Selector), exprDollar[4].PipelineExpr), exprDollar[2].duration, exprDollar[5].UnwrapExpr, exprDollar[3].OffsetExpr)
 		}
 	case 38:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:202
 		{
 			exprVAL.LogRangeExpr = exprDollar[2].LogRangeExpr
 		}
-	case 40:
+	case 39:
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+		{
+			exprVAL.LogRangeExpr = mustNewLogRangeWithAt(exprDollar[1].LogRangeExpr, exprDollar[2].AtExpr)
+		}
+	case 41:
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+		{
+			exprVAL.SubqueryExpr = mustNewSubqueryExpr(exprDollar[1].MetricExpr, exprDollar[2].subqueryRange, nil)
+		}
+	case 42:
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+		{
+			exprVAL.SubqueryExpr = mustNewSubqueryExpr(exprDollar[1].MetricExpr, exprDollar[2].subqueryRange, exprDollar[3].OffsetExpr)
+		}
+	case 43:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:207
 		{
 			exprVAL.UnwrapExpr = newUnwrapExpr(exprDollar[3].str, "")
 		}
-	case 41:
+	case 44:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:208
 		{
 			exprVAL.UnwrapExpr = newUnwrapExpr(exprDollar[5].str, exprDollar[3].ConvOp)
 		}
-	case 42:
+	case 45:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:209
 		{
 			exprVAL.UnwrapExpr = exprDollar[1].UnwrapExpr.addPostFilter(exprDollar[3].LabelFilter)
 		}
-	case 43:
+	case 46:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:213
 		{
 			exprVAL.ConvOp = OpConvBytes
 		}
-	case 44:
+	case 47:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:214
 		{
 			exprVAL.ConvOp = OpConvDuration
 		}
-	case 45:
+	case 48:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:215
 		{
 			exprVAL.ConvOp = OpConvDurationSeconds
 		}
-	case 46:
+	case 49:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:219
 		{
 			exprVAL.RangeAggregationExpr = newRangeAggregationExpr(exprDollar[3].LogRangeExpr, exprDollar[1].RangeOp, nil, nil)
 		}
-	case 47:
+	case 50:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:220
 		{
 			exprVAL.RangeAggregationExpr = newRangeAggregationExpr(exprDollar[5].LogRangeExpr, exprDollar[1].RangeOp, nil, &exprDollar[3].str)
 		}
-	case 48:
+	case 51:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:221
 		{
 			exprVAL.RangeAggregationExpr = newRangeAggregationExpr(exprDollar[3].LogRangeExpr, exprDollar[1].RangeOp, exprDollar[5].Grouping, nil)
 		}
-	case 49:
+	case 52:
 		exprDollar = exprS[exprpt-7 : exprpt+1]
-//line expr.y:222
 		{
 			exprVAL.RangeAggregationExpr = newRangeAggregationExpr(exprDollar[5].LogRangeExpr, exprDollar[1].RangeOp, exprDollar[7].Grouping, &exprDollar[3].str)
 		}
-	case 50:
+	case 53:
+		exprDollar = exprS[exprpt-4 : exprpt+1]
+		{
+			exprVAL.RangeAggregationExpr = newSubqueryAggregationExpr(exprDollar[3].SubqueryExpr, exprDollar[1].RangeOp, nil)
+		}
+	case 54:
+		exprDollar = exprS[exprpt-6 : exprpt+1]
+		{
+			exprVAL.RangeAggregationExpr = newSubqueryAggregationExpr(exprDollar[5].SubqueryExpr, exprDollar[1].RangeOp, &exprDollar[3].str)
+		}
+	case 55:
+		exprDollar = exprS[exprpt-6 : exprpt+1]
+		{
+			exprVAL.RangeAggregationExpr = newHistogramRangeAggregationExpr(exprDollar[5].LogRangeExpr, nil, exprDollar[3].HistogramBuckets)
+		}
+	case 56:
+		exprDollar = exprS[exprpt-7 : exprpt+1]
+		{
+			exprVAL.RangeAggregationExpr = newHistogramRangeAggregationExpr(exprDollar[5].LogRangeExpr, exprDollar[7].Grouping, exprDollar[3].HistogramBuckets)
+		}
+	case 57:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.HistogramBuckets = []string{exprDollar[1].str}
+		}
+	case 58:
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+		{
+			exprVAL.HistogramBuckets = append(exprDollar[1].HistogramBuckets, exprDollar[3].str)
+		}
+	case 59:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:227
 		{
 			exprVAL.VectorAggregationExpr = mustNewVectorAggregationExpr(exprDollar[3].MetricExpr, exprDollar[1].VectorOp, nil, nil)
 		}
-	case 51:
+	case 60:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:228
 		{
 			exprVAL.VectorAggregationExpr = mustNewVectorAggregationExpr(exprDollar[4].MetricExpr, exprDollar[1].VectorOp, exprDollar[2].Grouping, nil)
 		}
-	case 52:
+	case 61:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:229
 		{
 			exprVAL.VectorAggregationExpr = mustNewVectorAggregationExpr(exprDollar[3].MetricExpr, exprDollar[1].VectorOp, exprDollar[5].Grouping, nil)
 		}
-	case 53:
+	case 62:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:231
 		{
 			exprVAL.VectorAggregationExpr = mustNewVectorAggregationExpr(exprDollar[5].MetricExpr, exprDollar[1].VectorOp, nil, &exprDollar[3].str)
 		}
-	case 54:
+	case 63:
 		exprDollar = exprS[exprpt-7 : exprpt+1]
-//line expr.y:232
 		{
 			exprVAL.VectorAggregationExpr = mustNewVectorAggregationExpr(exprDollar[5].MetricExpr, exprDollar[1].VectorOp, exprDollar[7].Grouping, &exprDollar[3].str)
 		}
-	case 55:
+	case 64:
 		exprDollar = exprS[exprpt-7 : exprpt+1]
-//line expr.y:233
 		{
 			exprVAL.VectorAggregationExpr = mustNewVectorAggregationExpr(exprDollar[6].MetricExpr, exprDollar[1].VectorOp, exprDollar[2].Grouping, &exprDollar[4].str)
 		}
-	case 56:
+	case 65:
 		exprDollar = exprS[exprpt-12 : exprpt+1]
-//line expr.y:238
 		{
 			exprVAL.LabelReplaceExpr = mustNewLabelReplaceExpr(exprDollar[3].MetricExpr, exprDollar[5].str, exprDollar[7].str, exprDollar[9].str, exprDollar[11].str)
 		}
-	case 57:
+	case 66:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:242
 		{
 			exprVAL.Filter = log.LineMatchRegexp
 		}
-	case 58:
+	case 67:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:243
 		{
 			exprVAL.Filter = log.LineMatchEqual
 		}
-	case 59:
+	case 68:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:244
 		{
 			exprVAL.Filter = log.LineMatchPattern
 		}
-	case 60:
+	case 69:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:245
 		{
 			exprVAL.Filter = log.LineMatchNotRegexp
 		}
-	case 61:
+	case 70:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:246
 		{
 			exprVAL.Filter = log.LineMatchNotEqual
 		}
-	case 62:
+	case 71:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:247
 		{
 			exprVAL.Filter = log.LineMatchNotPattern
 		}
-	case 63:
+	case 72:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:251
 		{
 			exprVAL.Selector = exprDollar[2].Matchers
 		}
-	case 64:
+	case 73:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:252
 		{
 			exprVAL.Selector = exprDollar[2].Matchers
 		}
-	case 65:
+	case 74:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:253
 		{
 		}
-	case 66:
+	case 75:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:257
 		{
 			exprVAL.Matchers = []*labels.Matcher{exprDollar[1].Matcher}
 		}
-	case 67:
+	case 76:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:258
 		{
 			exprVAL.Matchers = append(exprDollar[1].Matchers, exprDollar[3].Matcher)
 		}
-	case 68:
+	case 77:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:262
 		{
 			exprVAL.Matcher = mustNewMatcher(labels.MatchEqual, exprDollar[1].str, exprDollar[3].str)
 		}
-	case 69:
+	case 78:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:263
 		{
 			exprVAL.Matcher = mustNewMatcher(labels.MatchNotEqual, exprDollar[1].str, exprDollar[3].str)
 		}
-	case 70:
+	case 79:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:264
 		{
 			exprVAL.Matcher = mustNewMatcher(labels.MatchRegexp, exprDollar[1].str, exprDollar[3].str)
 		}
-	case 71:
+	case 80:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:265
 		{
 			exprVAL.Matcher = mustNewMatcher(labels.MatchNotRegexp, exprDollar[1].str, exprDollar[3].str)
 		}
-	case 72:
+	case 81:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:269
 		{
 			exprVAL.PipelineExpr = MultiStageExpr{exprDollar[1].PipelineStage}
 		}
-	case 73:
+	case 82:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:270
 		{
 			exprVAL.PipelineExpr = append(exprDollar[1].PipelineExpr, exprDollar[2].PipelineStage)
 		}
-	case 74:
+	case 83:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:274
 		{
 			exprVAL.PipelineStage = exprDollar[1].LineFilters
 		}
-	case 75:
+	case 84:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:275
 		{
 			exprVAL.PipelineStage = exprDollar[2].LogfmtParser
 		}
-	case 76:
+	case 85:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:276
 		{
 			exprVAL.PipelineStage = exprDollar[2].LabelParser
 		}
-	case 77:
+	case 86:
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+		{
+			exprVAL.PipelineStage = exprDollar[2].PipelineStage
+		}
+	case 87:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:277
 		{
 			exprVAL.PipelineStage = exprDollar[2].JSONExpressionParser
 		}
-	case 78:
+	case 88:
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+		{
+			exprVAL.PipelineStage = exprDollar[2].PipelineStage
+		}
+	case 89:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:278
 		{
 			exprVAL.PipelineStage = exprDollar[2].LogfmtExpressionParser
 		}
-	case 79:
+	case 90:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:279
 		{
 			exprVAL.PipelineStage = &LabelFilterExpr{LabelFilterer: exprDollar[2].LabelFilter}
 		}
-	case 80:
+	case 91:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:280
 		{
 			exprVAL.PipelineStage = exprDollar[2].LineFormatExpr
 		}
-	case 81:
+	case 92:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:281
 		{
 			exprVAL.PipelineStage = exprDollar[2].DecolorizeExpr
 		}
-	case 82:
+	case 93:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:282
 		{
 			exprVAL.PipelineStage = exprDollar[2].LabelFormatExpr
 		}
-	case 83:
+	case 94:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:283
 		{
 			exprVAL.PipelineStage = exprDollar[2].DropLabelsExpr
 		}
-	case 84:
+	case 95:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:284
 		{
 			exprVAL.PipelineStage = exprDollar[2].KeepLabelsExpr
 		}
-	case 85:
+	case 96:
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+		{
+			exprVAL.PipelineStage = exprDollar[2].LookupExpr
+		}
+	case 97:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:288
 		{
 			exprVAL.FilterOp = OpFilterIP
 		}
-	case 86:
+	case 98:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:292
 		{
 			exprVAL.OrFilter = newLineFilterExpr(log.LineMatchEqual, "", exprDollar[1].str)
 		}
-	case 87:
+	case 99:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:293
 		{
 			exprVAL.OrFilter = newLineFilterExpr(log.LineMatchEqual, exprDollar[1].FilterOp, exprDollar[3].str)
 		}
-	case 88:
+	case 100:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:294
 		{
 			exprVAL.OrFilter = newOrLineFilter(newLineFilterExpr(log.LineMatchEqual, "", exprDollar[1].str), exprDollar[3].OrFilter)
 		}
-	case 89:
+	case 101:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:298
 		{
 			exprVAL.LineFilter = newLineFilterExpr(exprDollar[1].Filter, "", exprDollar[2].str)
 		}
-	case 90:
+	case 102:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:299
 		{
 			exprVAL.LineFilter = newLineFilterExpr(exprDollar[1].Filter, exprDollar[2].FilterOp, exprDollar[4].str)
 		}
-	case 91:
+	case 103:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:300
 		{
 			exprVAL.LineFilter = newOrLineFilter(newLineFilterExpr(exprDollar[1].Filter, "", exprDollar[2].str), exprDollar[4].OrFilter)
 		}
-	case 92:
+	case 104:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:304
 		{
 			exprVAL.LineFilters = exprDollar[1].LineFilter
 		}
-	case 93:
+	case 105:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:305
 		{
 			exprVAL.LineFilters = newOrLineFilter(exprDollar[1].LineFilter, exprDollar[3].OrFilter)
 		}
-	case 94:
+	case 106:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:306
 		{
 			exprVAL.LineFilters = newNestedLineFilterExpr(exprDollar[1].LineFilters, exprDollar[2].LineFilter)
 		}
-	case 95:
+	case 107:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:310
 		{
 			exprVAL.ParserFlags = []string{exprDollar[1].str}
 		}
-	case 96:
+	case 108:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:311
 		{
 			exprVAL.ParserFlags = append(exprDollar[1].ParserFlags, exprDollar[2].str)
 		}
-	case 97:
+	case 109:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:315
 		{
 			exprVAL.LogfmtParser = newLogfmtParserExpr(nil)
 		}
-	case 98:
+	case 110:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:316
 		{
 			exprVAL.LogfmtParser = newLogfmtParserExpr(exprDollar[2].ParserFlags)
 		}
-	case 99:
+	case 111:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:320
 		{
 			exprVAL.LabelParser = newLabelParserExpr(OpParserTypeJSON, "")
 		}
-	case 100:
+	case 112:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:321
 		{
 			exprVAL.LabelParser = newLabelParserExpr(OpParserTypeRegexp, exprDollar[2].str)
 		}
-	case 101:
+	case 113:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:322
 		{
 			exprVAL.LabelParser = newLabelParserExpr(OpParserTypeUnpack, "")
 		}
-	case 102:
+	case 114:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:323
 		{
 			exprVAL.LabelParser = newLabelParserExpr(OpParserTypePattern, exprDollar[2].str)
 		}
-	case 103:
+	case 115:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.LabelParser = newLabelParserExpr(OpParserTypeXML, "")
+		}
+	case 116:
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+		{
+			exprVAL.PipelineStage = newDelimitedParserExpr(OpParserTypeCSV, ",", exprDollar[2].Labels)
+		}
+	case 117:
+		exprDollar = exprS[exprpt-5 : exprpt+1]
+		{
+			exprVAL.PipelineStage = newDelimitedParserExpr(OpParserTypeDelimited, mustNewDelimitedSeparator(exprDollar[2].str, exprDollar[4].str), exprDollar[5].Labels)
+		}
+	case 118:
+		exprDollar = exprS[exprpt-1 : exprpt+1]
+		{
+			exprVAL.Labels = []string{exprDollar[1].str}
+		}
+	case 119:
+		exprDollar = exprS[exprpt-3 : exprpt+1]
+		{
+			exprVAL.Labels = append(exprDollar[1].Labels, exprDollar[3].str)
+		}
+	case 120:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:327
 		{
 			exprVAL.JSONExpressionParser = newJSONExpressionParser(exprDollar[2].LabelExtractionExpressionList)
 		}
-	case 104:
+	case 121:
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+		{
+			exprVAL.PipelineStage = newXMLExpressionParser(exprDollar[2].LabelExtractionExpressionList)
+		}
+	case 122:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:330
 		{
 			exprVAL.LogfmtExpressionParser = newLogfmtExpressionParser(exprDollar[3].LabelExtractionExpressionList, exprDollar[2].ParserFlags)
 		}
-	case 105:
+	case 123:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:331
 		{
 			exprVAL.LogfmtExpressionParser = newLogfmtExpressionParser(exprDollar[2].LabelExtractionExpressionList, nil)
 		}
-	case 106:
+	case 124:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:334
 		{
 			exprVAL.LineFormatExpr = newLineFmtExpr(exprDollar[2].str)
 		}
-	case 107:
+	case 125:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:336
 		{
 			exprVAL.DecolorizeExpr = newDecolorizeExpr()
 		}
-	case 108:
+	case 126:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:339
 		{
 			exprVAL.LabelFormat = log.NewRenameLabelFmt(exprDollar[1].str, exprDollar[3].str)
 		}
-	case 109:
+	case 127:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:340
 		{
 			exprVAL.LabelFormat = log.NewTemplateLabelFmt(exprDollar[1].str, exprDollar[3].str)
 		}
-	case 110:
+	case 128:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:344
 		{
 			exprVAL.LabelsFormat = []log.LabelFmt{exprDollar[1].LabelFormat}
 		}
-	case 111:
+	case 129:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:345
 		{
 			exprVAL.LabelsFormat = append(exprDollar[1].LabelsFormat, exprDollar[3].LabelFormat)
 		}
-	case 113:
+	case 131:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:350
 		{
 			exprVAL.LabelFormatExpr = newLabelFmtExpr(exprDollar[2].LabelsFormat)
 		}
-	case 114:
+	case 132:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:353
 		{
 			exprVAL.LabelFilter = log.NewStringLabelFilter(exprDollar[1].Matcher)
 		}
-	case 115:
+	case 133:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:354
 		{
 			exprVAL.LabelFilter = exprDollar[1].IPLabelFilter
 		}
-	case 116:
+	case 134:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:355
 		{
 			exprVAL.LabelFilter = exprDollar[1].UnitFilter
 		}
-	case 117:
+	case 135:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:356
 		{
 			exprVAL.LabelFilter = exprDollar[1].NumberFilter
 		}
-	case 118:
+	case 136:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:357
 		{
 			exprVAL.LabelFilter = exprDollar[2].LabelFilter
 		}
-	case 119:
+	case 137:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:358
 		{
 			exprVAL.LabelFilter = log.NewAndLabelFilter(exprDollar[1].LabelFilter, exprDollar[2].LabelFilter)
 		}
-	case 120:
+	case 138:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:359
 		{
 			exprVAL.LabelFilter = log.NewAndLabelFilter(exprDollar[1].LabelFilter, exprDollar[3].LabelFilter)
 		}
-	case 121:
+	case 139:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:360
 		{
 			exprVAL.LabelFilter = log.NewAndLabelFilter(exprDollar[1].LabelFilter, exprDollar[3].LabelFilter)
 		}
-	case 122:
+	case 140:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:361
 		{
 			exprVAL.LabelFilter = log.NewOrLabelFilter(exprDollar[1].LabelFilter, exprDollar[3].LabelFilter)
 		}
-	case 123:
+	case 141:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:365
 		{
 			exprVAL.LabelExtractionExpression = log.NewLabelExtractionExpr(exprDollar[1].str, exprDollar[3].str)
 		}
-	case 124:
+	case 142:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:366
 		{
 			exprVAL.LabelExtractionExpression = log.NewLabelExtractionExpr(exprDollar[1].str, exprDollar[1].str)
 		}
-	case 125:
+	case 143:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:369
 		{
 			exprVAL.LabelExtractionExpressionList = []log.LabelExtractionExpr{exprDollar[1].LabelExtractionExpression}
 		}
-	case 126:
+	case 144:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:370
 		{
 			exprVAL.LabelExtractionExpressionList = append(exprDollar[1].LabelExtractionExpressionList, exprDollar[3].LabelExtractionExpression)
 		}
-	case 127:
+	case 145:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:374
 		{
 			exprVAL.IPLabelFilter = log.NewIPLabelFilter(exprDollar[5].str, exprDollar[1].str, log.LabelFilterEqual)
 		}
-	case 128:
+	case 146:
 		exprDollar = exprS[exprpt-6 : exprpt+1]
-//line expr.y:375
 		{
 			exprVAL.IPLabelFilter = log.NewIPLabelFilter(exprDollar[5].str, exprDollar[1].str, log.LabelFilterNotEqual)
 		}
-	case 129:
+	case 147:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:379
 		{
 			exprVAL.UnitFilter = exprDollar[1].DurationFilter
 		}
-	case 130:
+	case 148:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:380
 		{
 			exprVAL.UnitFilter = exprDollar[1].BytesFilter
 		}
-	case 131:
+	case 149:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:383
 		{
 			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, exprDollar[3].duration)
 		}
-	case 132:
+	case 150:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:384
 		{
 			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
-	case 133:
+	case 151:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:385
 		{
 			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, exprDollar[3].duration)
 		}
-	case 134:
+	case 152:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:386
 		{
 			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
-	case 135:
+	case 153:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:387
 		{
 			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
-	case 136:
+	case 154:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:388
 		{
 			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
-	case 137:
+	case 155:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:389
 		{
 			exprVAL.DurationFilter = log.NewDurationLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].duration)
 		}
-	case 138:
+	case 156:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:393
 		{
 			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, exprDollar[3].bytes)
 		}
-	case 139:
+	case 157:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:394
 		{
 			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
-	case 140:
+	case 158:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:395
 		{
 			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, exprDollar[3].bytes)
 		}
-	case 141:
+	case 159:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:396
 		{
 			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
-	case 142:
+	case 160:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:397
 		{
 			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
-	case 143:
+	case 161:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:398
 		{
 			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
-	case 144:
+	case 162:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:399
 		{
 			exprVAL.BytesFilter = log.NewBytesLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].bytes)
 		}
-	case 145:
+	case 163:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:403
 		{
 			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterGreaterThan, exprDollar[1].str, exprDollar[3].LiteralExpr.Val)
 		}
-	case 146:
+	case 164:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:404
 		{
 			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterGreaterThanOrEqual, exprDollar[1].str, exprDollar[3].LiteralExpr.Val)
 		}
-	case 147:
+	case 165:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:405
 		{
 			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterLesserThan, exprDollar[1].str, exprDollar[3].LiteralExpr.Val)
 		}
-	case 148:
+	case 166:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:406
 		{
 			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterLesserThanOrEqual, exprDollar[1].str, exprDollar[3].LiteralExpr.Val)
 		}
-	case 149:
+	case 167:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:407
 		{
 			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterNotEqual, exprDollar[1].str, exprDollar[3].LiteralExpr.Val)
 		}
-	case 150:
+	case 168:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:408
 		{
 			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].LiteralExpr.Val)
 		}
-	case 151:
+	case 169:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:409
 		{
 			exprVAL.NumberFilter = log.NewNumericLabelFilter(log.LabelFilterEqual, exprDollar[1].str, exprDollar[3].LiteralExpr.Val)
 		}
-	case 152:
+	case 170:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:413
 		{
 			exprVAL.DropLabel = log.NewDropLabel(nil, exprDollar[1].str)
 		}
-	case 153:
+	case 171:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:414
 		{
 			exprVAL.DropLabel = log.NewDropLabel(exprDollar[1].Matcher, "")
 		}
-	case 154:
+	case 172:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:417
 		{
 			exprVAL.DropLabels = []log.DropLabel{exprDollar[1].DropLabel}
 		}
-	case 155:
+	case 173:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:418
 		{
 			exprVAL.DropLabels = append(exprDollar[1].DropLabels, exprDollar[3].DropLabel)
 		}
-	case 156:
+	case 174:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:421
 		{
 			exprVAL.DropLabelsExpr = newDropLabelsExpr(exprDollar[2].DropLabels)
 		}
-	case 157:
+	case 175:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:424
 		{
 			exprVAL.KeepLabel = log.NewKeepLabel(nil, exprDollar[1].str)
 		}
-	case 158:
+	case 176:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:425
 		{
 			exprVAL.KeepLabel = log.NewKeepLabel(exprDollar[1].Matcher, "")
 		}
-	case 159:
+	case 177:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:428
 		{
 			exprVAL.KeepLabels = []log.KeepLabel{exprDollar[1].KeepLabel}
 		}
-	case 160:
+	case 178:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:429
 		{
 			exprVAL.KeepLabels = append(exprDollar[1].KeepLabels, exprDollar[3].KeepLabel)
 		}
-	case 161:
+	case 179:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:432
 		{
 			exprVAL.KeepLabelsExpr = newKeepLabelsExpr(exprDollar[2].KeepLabels)
 		}
-	case 162:
+	case 180:
+		exprDollar = exprS[exprpt-4 : exprpt+1]
+		{
+			exprVAL.LookupExpr = newLookupExpr(exprDollar[2].str, exprDollar[4].str)
+		}
+	case 181:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:436
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("or", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 163:
+	case 182:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:437
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("and", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 164:
+	case 183:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:438
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("unless", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 165:
+	case 184:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:439
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("+", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 166:
+	case 185:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:440
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("-", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 167:
+	case 186:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:441
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("*", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 168:
+	case 187:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:442
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("/", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 169:
+	case 188:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:443
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("%", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 170:
+	case 189:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:444
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("^", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 171:
+	case 190:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:445
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("==", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 172:
+	case 191:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:446
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("!=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 173:
+	case 192:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:447
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr(">", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 174:
+	case 193:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:448
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr(">=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 175:
+	case 194:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:449
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("<", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 176:
+	case 195:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:450
 		{
 			exprVAL.BinOpExpr = mustNewBinOpExpr("<=", exprDollar[3].BinOpModifier, exprDollar[1].Expr, exprDollar[4].Expr)
 		}
-	case 177:
+	case 196:
 		exprDollar = exprS[exprpt-0 : exprpt+1]
-//line expr.y:454
 		{
 			exprVAL.BoolModifier = &BinOpOptions{VectorMatching: &VectorMatching{Card: CardOneToOne}}
 		}
-	case 178:
+	case 197:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:458
 		{
 			exprVAL.BoolModifier = &BinOpOptions{VectorMatching: &VectorMatching{Card: CardOneToOne}, ReturnBool: true}
 		}
-	case 179:
+	case 198:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:465
 		{
 			exprVAL.OnOrIgnoringModifier = exprDollar[1].BoolModifier
 			exprVAL.OnOrIgnoringModifier.VectorMatching.On = true
 			exprVAL.OnOrIgnoringModifier.VectorMatching.MatchingLabels = exprDollar[4].Labels
 		}
-	case 180:
+	case 199:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:471
 		{
 			exprVAL.OnOrIgnoringModifier = exprDollar[1].BoolModifier
 			exprVAL.OnOrIgnoringModifier.VectorMatching.On = true
 		}
-	case 181:
+	case 200:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:476
 		{
 			exprVAL.OnOrIgnoringModifier = exprDollar[1].BoolModifier
 			exprVAL.OnOrIgnoringModifier.VectorMatching.MatchingLabels = exprDollar[4].Labels
 		}
-	case 182:
+	case 201:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:481
 		{
 			exprVAL.OnOrIgnoringModifier = exprDollar[1].BoolModifier
 		}
-	case 183:
+	case 202:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:487
 		{
 			exprVAL.BinOpModifier = exprDollar[1].BoolModifier
 		}
-	case 184:
+	case 203:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:488
 		{
 			exprVAL.BinOpModifier = exprDollar[1].OnOrIgnoringModifier
 		}
-	case 185:
+	case 204:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:490
 		{
 			exprVAL.BinOpModifier = exprDollar[1].OnOrIgnoringModifier
 			exprVAL.BinOpModifier.VectorMatching.Card = CardManyToOne
 		}
-	case 186:
+	case 205:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:495
 		{
 			exprVAL.BinOpModifier = exprDollar[1].OnOrIgnoringModifier
 			exprVAL.BinOpModifier.VectorMatching.Card = CardManyToOne
 		}
-	case 187:
+	case 206:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:500
 		{
 			exprVAL.BinOpModifier = exprDollar[1].OnOrIgnoringModifier
 			exprVAL.BinOpModifier.VectorMatching.Card = CardManyToOne
 			exprVAL.BinOpModifier.VectorMatching.Include = exprDollar[4].Labels
 		}
-	case 188:
+	case 207:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:506
 		{
 			exprVAL.BinOpModifier = exprDollar[1].OnOrIgnoringModifier
 			exprVAL.BinOpModifier.VectorMatching.Card = CardOneToMany
 		}
-	case 189:
+	case 208:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:511
 		{
 			exprVAL.BinOpModifier = exprDollar[1].OnOrIgnoringModifier
 			exprVAL.BinOpModifier.VectorMatching.Card = CardOneToMany
 		}
-	case 190:
+	case 209:
 		exprDollar = exprS[exprpt-5 : exprpt+1]
-//line expr.y:516
 		{
 			exprVAL.BinOpModifier = exprDollar[1].OnOrIgnoringModifier
 			exprVAL.BinOpModifier.VectorMatching.Card = CardOneToMany
 			exprVAL.BinOpModifier.VectorMatching.Include = exprDollar[4].Labels
 		}
-	case 191:
+	case 210:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:524
 		{
 			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[1].str, false)
 		}
-	case 192:
+	case 211:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:525
 		{
 			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[2].str, false)
 		}
-	case 193:
+	case 212:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:526
 		{
 			exprVAL.LiteralExpr = mustNewLiteralExpr(exprDollar[2].str, true)
 		}
-	case 194:
+	case 213:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:530
 		{
 			exprVAL.VectorExpr = NewVectorExpr(exprDollar[3].str)
 		}
-	case 195:
+	case 214:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:533
 		{
 			exprVAL.Vector = OpTypeVector
 		}
-	case 196:
+	case 215:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:537
 		{
 			exprVAL.VectorOp = OpTypeSum
 		}
-	case 197:
+	case 216:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:538
 		{
 			exprVAL.VectorOp = OpTypeAvg
 		}
-	case 198:
+	case 217:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:539
 		{
 			exprVAL.VectorOp = OpTypeCount
 		}
-	case 199:
+	case 218:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:540
 		{
 			exprVAL.VectorOp = OpTypeMax
 		}
-	case 200:
+	case 219:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:541
 		{
 			exprVAL.VectorOp = OpTypeMin
 		}
-	case 201:
+	case 220:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:542
 		{
 			exprVAL.VectorOp = OpTypeStddev
 		}
-	case 202:
+	case 221:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:543
 		{
 			exprVAL.VectorOp = OpTypeStdvar
 		}
-	case 203:
+	case 222:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:544
 		{
 			exprVAL.VectorOp = OpTypeBottomK
 		}
-	case 204:
+	case 223:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:545
 		{
 			exprVAL.VectorOp = OpTypeTopK
 		}
-	case 205:
+	case 224:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:546
 		{
 			exprVAL.VectorOp = OpTypeSort
 		}
-	case 206:
+	case 225:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:547
 		{
 			exprVAL.VectorOp = OpTypeSortDesc
 		}
-	case 207:
+	case 226:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:551
 		{
 			exprVAL.RangeOp = OpRangeTypeCount
 		}
-	case 208:
+	case 227:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:552
 		{
 			exprVAL.RangeOp = OpRangeTypeRate
 		}
-	case 209:
+	case 228:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:553
 		{
 			exprVAL.RangeOp = OpRangeTypeRateCounter
 		}
-	case 210:
+	case 229:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:554
 		{
 			exprVAL.RangeOp = OpRangeTypeBytes
 		}
-	case 211:
+	case 230:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:555
 		{
 			exprVAL.RangeOp = OpRangeTypeBytesRate
 		}
-	case 212:
+	case 231:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:556
 		{
 			exprVAL.RangeOp = OpRangeTypeAvg
 		}
-	case 213:
+	case 232:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:557
 		{
 			exprVAL.RangeOp = OpRangeTypeSum
 		}
-	case 214:
+	case 233:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:558
 		{
 			exprVAL.RangeOp = OpRangeTypeMin
 		}
-	case 215:
+	case 234:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:559
 		{
 			exprVAL.RangeOp = OpRangeTypeMax
 		}
-	case 216:
+	case 235:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:560
 		{
 			exprVAL.RangeOp = OpRangeTypeStdvar
 		}
-	case 217:
+	case 236:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:561
 		{
 			exprVAL.RangeOp = OpRangeTypeStddev
 		}
-	case 218:
+	case 237:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:562
 		{
 			exprVAL.RangeOp = OpRangeTypeQuantile
 		}
-	case 219:
+	case 238:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:563
 		{
 			exprVAL.RangeOp = OpRangeTypeFirst
 		}
-	case 220:
+	case 239:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:564
 		{
 			exprVAL.RangeOp = OpRangeTypeLast
 		}
-	case 221:
+	case 240:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:565
 		{
 			exprVAL.RangeOp = OpRangeTypeAbsent
 		}
-	case 222:
+	case 241:
 		exprDollar = exprS[exprpt-2 : exprpt+1]
-//line expr.y:569
 		{
 			exprVAL.OffsetExpr = newOffsetExpr(exprDollar[2].duration)
 		}
-	case 223:
+	case 242:
+		exprDollar = exprS[exprpt-2 : exprpt+1]
+		{
+			exprVAL.AtExpr = mustNewAtExpr(exprDollar[2].str)
+		}
+	case 243:
+		exprDollar = exprS[exprpt-4 : exprpt+1]
+		{
+			exprVAL.AtExpr = newAtExprStartOrEnd(OpAtStart)
+		}
+	case 244:
+		exprDollar = exprS[exprpt-4 : exprpt+1]
+		{
+			exprVAL.AtExpr = newAtExprStartOrEnd(OpAtEnd)
+		}
+	case 245:
 		exprDollar = exprS[exprpt-1 : exprpt+1]
-//line expr.y:572
 		{
 			exprVAL.Labels = []string{exprDollar[1].str}
 		}
-	case 224:
+	case 246:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:573
 		{
 			exprVAL.Labels = append(exprDollar[1].Labels, exprDollar[3].str)
 		}
-	case 225:
+	case 247:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:577
 		{
 			exprVAL.Grouping = &Grouping{Without: false, Groups: exprDollar[3].Labels}
 		}
-	case 226:
+	case 248:
 		exprDollar = exprS[exprpt-4 : exprpt+1]
-//line expr.y:578
 		{
 			exprVAL.Grouping = &Grouping{Without: true, Groups: exprDollar[3].Labels}
 		}
-	case 227:
+	case 249:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:579
 		{
 			exprVAL.Grouping = &Grouping{Without: false, Groups: nil}
 		}
-	case 228:
+	case 250:
 		exprDollar = exprS[exprpt-3 : exprpt+1]
-//line expr.y:580
 		{
 			exprVAL.Grouping = &Grouping{Without: true, Groups: nil}
 		}
@@ -38,6 +38,7 @@ var tokens = map[string]int{
 	"]":            CLOSE_BRACKET,
 	OpLabelReplace: LABEL_REPLACE,
 	OpOffset:       OFFSET,
+	OpAt:           AT,
 	OpOn:           ON,
 	OpIgnoring:     IGNORING,
 	OpGroupLeft:    GROUP_LEFT,
@@ -82,6 +83,17 @@ var tokens = map[string]int{
 	OpKeep: KEEP,
 }
 
+// stageTokens are tokens that are only keywords right after a pipe, so they can still be used as label names.
+var stageTokens = map[string]int{
+	// parsers
+	OpParserTypeCSV:       CSV,
+	OpParserTypeDelimited: DELIMITED,
+	OpParserTypeXML:       XML,
+
+	// lookup tables
+	OpLookup: LOOKUP,
+}
+
 var parserFlags = map[string]struct{}{
 	OpStrict:    {},
 	OpKeepEmpty: {},
@@ -105,6 +117,7 @@ var functionTokens = map[string]int{
 	OpRangeTypeFirst:       FIRST_OVER_TIME,
 	OpRangeTypeLast:        LAST_OVER_TIME,
 	OpRangeTypeAbsent:      ABSENT_OVER_TIME,
+	OpRangeTypeHistogram:   HISTOGRAM_OVER_TIME,
 	OpTypeVector:           VECTOR,
 
 	// vec ops
@@ -128,15 +141,25 @@ var functionTokens = map[string]int{
 
 	// filterOp
 	OpFilterIP: IP,
+
+	OpAtStart: START,
+	OpAtEnd:   END,
 }
 
 type lexer struct {
 	Scanner
 	errs    []logqlmodel.ParseError
 	builder strings.Builder
+	prev    int // the previous token
 }
 
 func (l *lexer) Lex(lval *exprSymType) int {
+	tok := l.lex(lval)
+	l.prev = tok
+	return tok
+}
+
+func (l *lexer) lex(lval *exprSymType) int {
 	r := l.Scan()
 
 	switch r {
@@ -146,7 +169,7 @@ func (l *lexer) Lex(lval *exprSymType) int {
 		for next := l.Peek(); !(next == '\n' || next == scanner.EOF); next = l.Next() {
 		}
 
-		return l.Lex(lval)
+		return l.lex(lval)
 
 	case scanner.EOF:
 		return 0
@@ -202,6 +225,10 @@ func (l *lexer) Lex(lval *exprSymType) int {
 		l.builder.Reset()
 		for r := l.Next(); r != scanner.EOF; r = l.Next() {
 			if r == ']' {
+				// subqueries use a range and a step, e.g. [1h:5m]
+				if rng, step, ok := strings.Cut(l.builder.String(), ":"); ok {
+					return l.lexSubqueryRange(lval, rng, step)
+				}
 				i, err := model.ParseDuration(l.builder.String())
 				if err != nil {
 					l.Error(err.Error())
@@ -247,10 +274,33 @@ func (l *lexer) Lex(lval *exprSymType) int {
 		return tok
 	}
 
+	if tok, ok := stageTokens[tokenTextLower]; ok && l.prev == PIPE && !isLabelFilter(l.Scanner) {
+		return tok
+	}
+
 	lval.str = tokenText
 	return IDENTIFIER
 }
 
+func (l *lexer) lexSubqueryRange(lval *exprSymType, rng, step string) int {
+	if step == "" {
+		l.Error("missing step in subquery range")
+		return 0
+	}
+	r, err := model.ParseDuration(rng)
+	if err != nil {
+		l.Error(err.Error())
+		return 0
+	}
+	s, err := model.ParseDuration(step)
+	if err != nil {
+		l.Error(err.Error())
+		return 0
+	}
+	lval.subqueryRange = subqueryRange{Range: time.Duration(r), Step: time.Duration(s)}
+	return SUBQUERY_RANGE
+}
+
 func (l *lexer) Error(msg string) {
 	l.errs = append(l.errs, logqlmodel.NewParseError(msg, l.Line, l.Column))
 }
@@ -405,6 +455,16 @@ func isFunction(sc Scanner) bool {
 	return false
 }
 
+// isLabelFilter returns true if the next token is a comparison operator of a label filter, e.g. `| csv="x"`.
+func isLabelFilter(sc Scanner) bool {
+	sc = trimSpace(sc)
+	switch sc.Peek() {
+	case '=', '!', '>', '<':
+		return true
+	}
+	return false
+}
+
 func trimSpace(l Scanner) Scanner {
 	for n := l.Peek(); n != scanner.EOF; n = l.Peek() {
 		if unicode.IsSpace(n) {
@@ -364,6 +364,290 @@ var ParseTestCases = []struct {
 		in:  `absent_over_time({ foo = "bar" }[5h]) by (foo)`,
 		err: logqlmodel.NewParseError("grouping not allowed for absent_over_time aggregation", 0, 0),
 	},
+	{
+		in: `histogram_over_time(0.1, 0.5, 1, { foo = "bar" } | unwrap latency [5m]) by (foo)`,
+		exp: newHistogramRangeAggregationExpr(
+			newLogRange(newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}),
+				5*time.Minute,
+				newUnwrapExpr("latency", ""),
+				nil),
+			&Grouping{Groups: []string{"foo"}},
+			[]string{"0.1", "0.5", "1"},
+		),
+	},
+	{
+		in:  `histogram_over_time(1, 0.5, { foo = "bar" } | unwrap latency [5m])`,
+		err: logqlmodel.NewParseError("buckets for operation histogram_over_time must be in strictly increasing order", 0, 0),
+	},
+	{
+		in:  `histogram_over_time(0.5, { foo = "bar" }[5m])`,
+		err: logqlmodel.NewParseError("invalid aggregation histogram_over_time without unwrap", 0, 0),
+	},
+	{
+		in: `count_over_time({ foo = "bar" }[5m] offset 1w @ 1609746000)`,
+		exp: newRangeAggregationExpr(
+			mustNewLogRangeWithAt(
+				newLogRange(newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}),
+					5*time.Minute,
+					nil,
+					newOffsetExpr(7*24*time.Hour)),
+				&AtExpr{Timestamp: 1609746000000},
+			),
+			OpRangeTypeCount, nil, nil,
+		),
+	},
+	{
+		in: `rate({ foo = "bar" }[5m] @ end()) / rate({ foo = "bar" }[5m] offset 1w @ end())`,
+		exp: mustNewBinOpExpr(
+			OpTypeDiv,
+			&BinOpOptions{
+				VectorMatching: &VectorMatching{Card: CardOneToOne},
+			},
+			newRangeAggregationExpr(
+				&LogRange{
+					Left:     newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}),
+					Interval: 5 * time.Minute,
+					At:       &AtExpr{StartOrEnd: OpAtEnd},
+				},
+				OpRangeTypeRate, nil, nil,
+			),
+			newRangeAggregationExpr(
+				&LogRange{
+					Left:     newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}),
+					Interval: 5 * time.Minute,
+					Offset:   7 * 24 * time.Hour,
+					At:       &AtExpr{StartOrEnd: OpAtEnd},
+				},
+				OpRangeTypeRate, nil, nil,
+			),
+		),
+	},
+	{
+		in: `sum by (start) (count_over_time({ start = "bar" } | json [5m] @ start()))`,
+		exp: mustNewVectorAggregationExpr(
+			newRangeAggregationExpr(
+				&LogRange{
+					Left: newPipelineExpr(
+						newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "start", "bar")}),
+						MultiStageExpr{newLabelParserExpr(OpParserTypeJSON, "")},
+					),
+					Interval: 5 * time.Minute,
+					At:       &AtExpr{StartOrEnd: OpAtStart},
+				},
+				OpRangeTypeCount, nil, nil,
+			),
+			OpTypeSum, &Grouping{Groups: []string{"start"}}, nil,
+		),
+	},
+	{
+		in:  `count_over_time({ foo = "bar" }[5m] @ 1 @ 2)`,
+		err: logqlmodel.NewParseError("@ modifier may not be set multiple times", 0, 0),
+	},
+	{
+		in: `max_over_time(rate({ foo = "bar" }[5m])[1h:5m])`,
+		exp: newSubqueryAggregationExpr(
+			&SubqueryExpr{
+				Left: newRangeAggregationExpr(
+					newLogRange(newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}), 5*time.Minute, nil, nil),
+					OpRangeTypeRate, nil, nil,
+				),
+				Range: time.Hour,
+				Step:  5 * time.Minute,
+			},
+			OpRangeTypeMax, nil,
+		),
+	},
+	{
+		in: `quantile_over_time(0.99, sum by (app) (rate({ foo = "bar" }[5m])) / 2 [1h:5m] offset 1d)`,
+		exp: newSubqueryAggregationExpr(
+			&SubqueryExpr{
+				Left: mustNewBinOpExpr(
+					OpTypeDiv,
+					&BinOpOptions{
+						VectorMatching: &VectorMatching{Card: CardOneToOne},
+					},
+					mustNewVectorAggregationExpr(
+						newRangeAggregationExpr(
+							newLogRange(newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "foo", "bar")}), 5*time.Minute, nil, nil),
+							OpRangeTypeRate, nil, nil,
+						),
+						OpTypeSum, &Grouping{Groups: []string{"app"}}, nil,
+					),
+					mustNewLiteralExpr("2", false),
+				),
+				Range:  time.Hour,
+				Step:   5 * time.Minute,
+				Offset: 24 * time.Hour,
+			},
+			OpRangeTypeQuantile, NewStringLabelFilter("0.99"),
+		),
+	},
+	{
+		in: `sum by (team) (count_over_time({app="foo"} | json | lookup ownership on service [5m]))`,
+		exp: mustNewVectorAggregationExpr(
+			newRangeAggregationExpr(
+				newLogRange(&PipelineExpr{
+					Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+					MultiStages: MultiStageExpr{
+						newLabelParserExpr(OpParserTypeJSON, ""),
+						newLookupExpr("ownership", "service"),
+					},
+				}, 5*time.Minute, nil, nil),
+				OpRangeTypeCount, nil, nil,
+			),
+			OpTypeSum, &Grouping{Groups: []string{"team"}}, nil,
+		),
+	},
+	{
+		in: `{app="foo"} | json | lookup="x"`,
+		exp: &PipelineExpr{
+			Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+			MultiStages: MultiStageExpr{
+				newLabelParserExpr(OpParserTypeJSON, ""),
+				newLabelFilterExpr(log.NewStringLabelFilter(mustNewMatcher(labels.MatchEqual, "lookup", "x"))),
+			},
+		},
+	},
+	{
+		in: `sum by (lookup) (count_over_time({lookup="foo"}[5m]))`,
+		exp: mustNewVectorAggregationExpr(
+			newRangeAggregationExpr(
+				newLogRange(newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "lookup", "foo")}), 5*time.Minute, nil, nil),
+				OpRangeTypeCount, nil, nil,
+			),
+			OpTypeSum, &Grouping{Groups: []string{"lookup"}}, nil,
+		),
+	},
+	{
+		in: `{app="foo"} | csv "ip","","method" | method="GET"`,
+		exp: &PipelineExpr{
+			Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+			MultiStages: MultiStageExpr{
+				newDelimitedParserExpr(OpParserTypeCSV, ",", []string{"ip", "", "method"}),
+				newLabelFilterExpr(log.NewStringLabelFilter(mustNewMatcher(labels.MatchEqual, "method", "GET"))),
+			},
+		},
+	},
+	{
+		in: `{app="foo"} | delimited sep="\t" "ip","method"`,
+		exp: &PipelineExpr{
+			Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+			MultiStages: MultiStageExpr{
+				newDelimitedParserExpr(OpParserTypeDelimited, "\t", []string{"ip", "method"}),
+			},
+		},
+	},
+	{
+		in: `{app="foo"} | logfmt | csv="x" | delimited != "y"`,
+		exp: &PipelineExpr{
+			Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+			MultiStages: MultiStageExpr{
+				newLogfmtParserExpr(nil),
+				newLabelFilterExpr(log.NewStringLabelFilter(mustNewMatcher(labels.MatchEqual, "csv", "x"))),
+				newLabelFilterExpr(log.NewStringLabelFilter(mustNewMatcher(labels.MatchNotEqual, "delimited", "y"))),
+			},
+		},
+	},
+	{
+		in: `sum by (csv, delimited) (count_over_time({app="foo"} | csv "delimited", "csv" [5m]))`,
+		exp: mustNewVectorAggregationExpr(
+			newRangeAggregationExpr(
+				newLogRange(&PipelineExpr{
+					Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+					MultiStages: MultiStageExpr{
+						newDelimitedParserExpr(OpParserTypeCSV, ",", []string{"delimited", "csv"}),
+					},
+				}, 5*time.Minute, nil, nil),
+				OpRangeTypeCount, nil, nil,
+			),
+			OpTypeSum, &Grouping{Groups: []string{"csv", "delimited"}}, nil,
+		),
+	},
+	{
+		in: `{app="foo"} | xml | Event_System_EventID="4624"`,
+		exp: &PipelineExpr{
+			Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+			MultiStages: MultiStageExpr{
+				newLabelParserExpr(OpParserTypeXML, ""),
+				newLabelFilterExpr(log.NewStringLabelFilter(mustNewMatcher(labels.MatchEqual, "Event_System_EventID", "4624"))),
+			},
+		},
+	},
+	{
+		in: `{app="foo"} | xml event_id="Event/System/EventID", provider="Event/System/Provider/@Name"`,
+		exp: &PipelineExpr{
+			Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+			MultiStages: MultiStageExpr{
+				newXMLExpressionParser([]log.LabelExtractionExpr{
+					log.NewLabelExtractionExpr("event_id", "Event/System/EventID"),
+					log.NewLabelExtractionExpr("provider", "Event/System/Provider/@Name"),
+				}),
+			},
+		},
+	},
+	{
+		in: `{app="foo"} | json | xml="x"`,
+		exp: &PipelineExpr{
+			Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+			MultiStages: MultiStageExpr{
+				newLabelParserExpr(OpParserTypeJSON, ""),
+				newLabelFilterExpr(log.NewStringLabelFilter(mustNewMatcher(labels.MatchEqual, "xml", "x"))),
+			},
+		},
+	},
+	{
+		in: `sum by (xml) (count_over_time({app="foo"} | xml xml="Event/System/EventID" [5m]))`,
+		exp: mustNewVectorAggregationExpr(
+			newRangeAggregationExpr(
+				newLogRange(&PipelineExpr{
+					Left: newMatcherExpr([]*labels.Matcher{mustNewMatcher(labels.MatchEqual, "app", "foo")}),
+					MultiStages: MultiStageExpr{
+						newXMLExpressionParser([]log.LabelExtractionExpr{
+							log.NewLabelExtractionExpr("xml", "Event/System/EventID"),
+						}),
+					},
+				}, 5*time.Minute, nil, nil),
+				OpRangeTypeCount, nil, nil,
+			),
+			OpTypeSum, &Grouping{Groups: []string{"xml"}}, nil,
+		),
+	},
+	{
+		in:  `{app="foo"} | delimited separator="\t" "ip","method"`,
+		err: logqlmodel.NewParseError("invalid delimited parser parameter separator, expecting sep", 0, 0),
+	},
+	{
+		in:  `{app="foo"} | delimited sep=";;" "ip","method"`,
+		err: logqlmodel.NewParseError(`invalid delimited parser: invalid separator ";;"`, 0, 0),
+	},
+	{
+		in:  `{app="foo"} | csv "ip","ip"`,
+		err: logqlmodel.NewParseError("invalid csv parser: duplicate column label name 'ip'", 0, 0),
+	},
+	{
+		in:  `{app="foo"} | csv`,
+		err: logqlmodel.NewParseError("syntax error: unexpected $end, expecting STRING", 1, 18),
+	},
+	{
+		in:  `{app="foo"} | lookup ownership`,
+		err: logqlmodel.NewParseError("syntax error: unexpected $end, expecting on", 1, 31),
+	},
+	{
+		in:  `rate(rate({ foo = "bar" }[5m])[1h:5m])`,
+		err: logqlmodel.NewParseError("invalid aggregation rate over subquery", 0, 0),
+	},
+	{
+		in:  `max_over_time(rate({ foo = "bar" }[5m])[1h:])`,
+		err: logqlmodel.NewParseError("missing step in subquery range", 0, 40),
+	},
+	{
+		in:  `max_over_time(rate({ foo = "bar" }[5m])[1h:0s])`,
+		err: logqlmodel.NewParseError("subquery step must be greater than 0", 0, 0),
+	},
+	{
+		in:  `count_over_time({ foo = "bar" }[1h:5m])`,
+		err: logqlmodel.NewParseError("syntax error: unexpected SUBQUERY_RANGE", 0, 32),
+	},
 	{
 		in:  `rate({ foo = "bar" }[5minutes])`,
 		err: logqlmodel.NewParseError(`unknown unit "minutes" in duration "5minutes"`, 0, 21),
@@ -2889,7 +3173,7 @@ var ParseTestCases = []struct {
 	},
 	{
 		in:  `quantile_over_time(foo,{namespace="tns"} |= "level=error" | json |foo>=5,bar<25ms| unwrap latency [5m])`,
-		err: logqlmodel.NewParseError("syntax error: unexpected IDENTIFIER, expecting NUMBER or { or (", 1, 20),
+		err: logqlmodel.NewParseError("syntax error: unexpected IDENTIFIER", 1, 20),
 	},
 	{
 		in:  `vector(abc)`,
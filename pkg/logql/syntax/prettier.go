@@ -113,6 +113,13 @@ func (e *LabelParserExpr) Pretty(level int) string {
 	return commonPrefixIndent(level, e)
 }
 
+// e.g:
+// `| csv "method","path"`
+// `| delimited sep="\t" "method","path"`
+func (e *DelimitedParserExpr) Pretty(level int) string {
+	return commonPrefixIndent(level, e)
+}
+
 func (e *DropLabelsExpr) Pretty(level int) string {
 	return commonPrefixIndent(level, e)
 }
@@ -121,6 +128,11 @@ func (e *KeepLabelsExpr) Pretty(level int) string {
 	return commonPrefixIndent(level, e)
 }
 
+// e.g: | lookup teams on service
+func (e *LookupExpr) Pretty(level int) string {
+	return commonPrefixIndent(level, e)
+}
+
 // e.g: | level!="error"
 func (e *LabelFilterExpr) Pretty(level int) string {
 	return commonPrefixIndent(level, e)
@@ -146,6 +158,11 @@ func (e *JSONExpressionParser) Pretty(level int) string {
 	return commonPrefixIndent(level, e)
 }
 
+// e.g: | xml label="expression", another="expression"
+func (e *XMLExpressionParser) Pretty(level int) string {
+	return commonPrefixIndent(level, e)
+}
+
 // e.g: | logfmt label="expression", another="expression"
 func (e *LogfmtExpressionParser) Pretty(level int) string {
 	return commonPrefixIndent(level, e)
@@ -185,6 +202,10 @@ func (e *LogRange) Pretty(level int) string {
 		s += oe.Pretty(level)
 	}
 
+	if e.At != nil {
+		s += e.At.Pretty(level)
+	}
+
 	return s
 }
 
@@ -198,6 +219,12 @@ func (e *OffsetExpr) Pretty(_ int) string {
 	return fmt.Sprintf(" %s %s", OpOffset, model.Duration(e.Offset))
 }
 
+// e.g: count_over_time({foo="bar"}[5m] @ end())
+// NOTE: Like offset, the @ modifier always goes with its parent expression.
+func (e *AtExpr) Pretty(_ int) string {
+	return e.String()
+}
+
 // e.g: count_over_time({foo="bar"}[5m])
 func (e *RangeAggregationExpr) Pretty(level int) string {
 	s := Indent(level)
@@ -214,6 +241,10 @@ func (e *RangeAggregationExpr) Pretty(level int) string {
 		s = fmt.Sprintf("%s%s%s,", s, Indent(level+1), fmt.Sprint(*e.Params))
 		s += "\n"
 	}
+	for _, b := range e.Buckets {
+		s = fmt.Sprintf("%s%s%s,", s, Indent(level+1), fmt.Sprint(b))
+		s += "\n"
+	}
 
 	s += e.Left.Pretty(level + 1)
 
@@ -226,6 +257,50 @@ func (e *RangeAggregationExpr) Pretty(level int) string {
 	return s
 }
 
+// e.g: max_over_time(rate({foo="bar"}[5m])[1h:5m])
+func (e *SubqueryAggregationExpr) Pretty(level int) string {
+	s := Indent(level)
+	if !NeedSplit(e) {
+		return s + e.String()
+	}
+
+	s += e.Operation // e.g: max_over_time
+
+	s += "(\n"
+
+	if e.Params != nil {
+		s = fmt.Sprintf("%s%s%s,", s, Indent(level+1), fmt.Sprint(*e.Params))
+		s += "\n"
+	}
+
+	s += e.Left.Pretty(level + 1)
+
+	s += "\n" + Indent(level) + ")"
+
+	return s
+}
+
+// e.g: rate({foo="bar"}[5m])[1h:5m]
+// NOTE: Like for log ranges, the subquery range and offset go with the inner expression.
+func (e *SubqueryExpr) Pretty(level int) string {
+	var s string
+	// binary operations are only wrapped in parentheses when not split.
+	if _, ok := e.Left.(*BinOpExpr); ok && NeedSplit(e.Left) {
+		s = fmt.Sprintf("%s(\n%s\n%s)", Indent(level), e.Left.Pretty(level+1), Indent(level))
+	} else {
+		s = e.Left.Pretty(level)
+	}
+
+	s = fmt.Sprintf("%s [%s:%s]", s, model.Duration(e.Range), model.Duration(e.Step))
+
+	if e.Offset != 0 {
+		oe := OffsetExpr{Offset: e.Offset}
+		s += oe.Pretty(level)
+	}
+
+	return s
+}
+
 // e.g:
 // sum(count_over_time({foo="bar"}[5m])) by (container)
 // topk(10, count_over_time({foo="bar"}[5m])) by (container)
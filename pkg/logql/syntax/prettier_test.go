@@ -64,6 +64,59 @@ func TestFormat(t *testing.T) {
 			exp: `count_over_time(
   {job="loki", instance="localhost"}
     |= "error" [5m] offset 20m
+)`,
+		},
+		{
+			name: "aggregation_with_at_modifier",
+			in:   `count_over_time({job="loki", instance="localhost"}|= "error"[5m] offset 1w @ end())`,
+			exp: `count_over_time(
+  {job="loki", instance="localhost"}
+    |= "error" [5m] offset 1w @ end()
+)`,
+		},
+		{
+			name: "subquery",
+			in:   `max_over_time(sum by (instance) (rate({job="loki", instance="localhost"}|= "error"[5m]))[1h:5m] offset 1d)`,
+			exp: `max_over_time(
+  sum by (instance)(
+    rate(
+      {job="loki", instance="localhost"}
+        |= "error" [5m]
+    )
+  ) [1h:5m] offset 1d
+)`,
+		},
+		{
+			name: "delimited parser",
+			in:   `sum by (method) (count_over_time({job="loki"}|delimited sep=";" "ip","method"|method="GET"[5m]))`,
+			exp: `sum by (method)(
+  count_over_time(
+    {job="loki"}
+      | delimited sep=";" "ip","method"
+      | method="GET" [5m]
+  )
+)`,
+		},
+		{
+			name: "xml parser",
+			in:   `sum by (event_id) (count_over_time({job="loki"}|xml event_id="Event/System/EventID"|event_id="4624"[5m]))`,
+			exp: `sum by (event_id)(
+  count_over_time(
+    {job="loki"}
+      | xml event_id="Event/System/EventID"
+      | event_id="4624" [5m]
+  )
+)`,
+		},
+		{
+			name: "lookup",
+			in:   `sum by (team) (count_over_time({job="loki"}|json|lookup ownership on service[5m]))`,
+			exp: `sum by (team)(
+  count_over_time(
+    {job="loki"}
+      | json
+      | lookup ownership on service [5m]
+  )
 )`,
 		},
 		{
@@ -75,6 +128,18 @@ func TestFormat(t *testing.T) {
     | json
     | unwrap response_latency_seconds
     | __error__="" [1m]
+) by (cluster)`,
+		},
+		{
+			name: "histogram",
+			in:   `histogram_over_time(0.1,0.5,1,{container="ingress-nginx",service="hosted-grafana"}| json| unwrap response_latency_seconds[1m]) by (cluster)`,
+			exp: `histogram_over_time(
+  0.1,
+  0.5,
+  1,
+  {container="ingress-nginx", service="hosted-grafana"}
+    | json
+    | unwrap response_latency_seconds [1m]
 ) by (cluster)`,
 		},
 		{
@@ -33,8 +33,11 @@ func EncodeJSON(e Expr, w io.Writer) error {
 const (
 	Bin                 = "bin"
 	Binary              = "binary"
+	Buckets             = "buckets"
 	Bytes               = "bytes"
 	And                 = "and"
+	AtMillis            = "at_millis"
+	AtStartOrEnd        = "at_start_or_end"
 	Card                = "cardinality"
 	Dst                 = "dst"
 	Duration            = "duration"
@@ -68,7 +71,10 @@ const (
 	ReturnBool          = "return_bool"
 	RHS                 = "rhs"
 	Src                 = "src"
+	StepNanos           = "step_nanos"
 	StringField         = "string"
+	Subquery            = "subquery"
+	SubqueryAgg         = "subquery_agg"
 	NoopField           = "noop"
 	Type                = "type"
 	Unwrap              = "unwrap"
@@ -90,6 +96,8 @@ func DecodeJSON(raw string) (Expr, error) {
 		return decodeVectorAgg(iter)
 	case RangeAgg:
 		return decodeRangeAgg(iter)
+	case SubqueryAgg:
+		return decodeSubqueryAgg(iter)
 	case Literal:
 		return decodeLiteral(iter)
 	case Vector:
@@ -195,6 +203,19 @@ func (v *JSONSerializer) VisitRangeAggregation(e *RangeAggregationExpr) {
 		v.WriteFloat64(*e.Params)
 	}
 
+	if e.Buckets != nil {
+		v.WriteMore()
+		v.WriteObjectField(Buckets)
+		v.WriteArrayStart()
+		for i, b := range e.Buckets {
+			if i > 0 {
+				v.WriteMore()
+			}
+			v.WriteFloat64(b)
+		}
+		v.WriteArrayEnd()
+	}
+
 	v.WriteMore()
 	v.WriteObjectField(Range)
 	v.VisitLogRange(e.Left)
@@ -213,6 +234,15 @@ func (v *JSONSerializer) VisitLogRange(e *LogRange) {
 	v.WriteObjectField(OffsetNanos)
 	v.WriteInt64(int64(e.Offset))
 
+	if e.At != nil {
+		v.WriteMore()
+		v.WriteObjectField(AtMillis)
+		v.WriteInt64(e.At.Timestamp)
+		v.WriteMore()
+		v.WriteObjectField(AtStartOrEnd)
+		v.WriteString(e.At.StartOrEnd)
+	}
+
 	// Serialize log selector pipeline as string.
 	v.WriteMore()
 	v.WriteObjectField(LogSelector)
@@ -228,6 +258,50 @@ func (v *JSONSerializer) VisitLogRange(e *LogRange) {
 	v.Flush()
 }
 
+func (v *JSONSerializer) VisitSubqueryAggregation(e *SubqueryAggregationExpr) {
+	v.WriteObjectStart()
+
+	v.WriteObjectField(SubqueryAgg)
+	v.WriteObjectStart()
+
+	v.WriteObjectField(Op)
+	v.WriteString(e.Operation)
+
+	if e.Params != nil {
+		v.WriteMore()
+		v.WriteObjectField(Params)
+		v.WriteFloat64(*e.Params)
+	}
+
+	v.WriteMore()
+	v.WriteObjectField(Subquery)
+	v.VisitSubquery(e.Left)
+	v.WriteObjectEnd()
+
+	v.WriteObjectEnd()
+	v.Flush()
+}
+
+func (v *JSONSerializer) VisitSubquery(e *SubqueryExpr) {
+	v.WriteObjectStart()
+
+	v.WriteObjectField(IntervalNanos)
+	v.WriteInt64(int64(e.Range))
+	v.WriteMore()
+	v.WriteObjectField(StepNanos)
+	v.WriteInt64(int64(e.Step))
+	v.WriteMore()
+	v.WriteObjectField(OffsetNanos)
+	v.WriteInt64(int64(e.Offset))
+
+	v.WriteMore()
+	v.WriteObjectField(Inner)
+	e.Left.Accept(v)
+
+	v.WriteObjectEnd()
+	v.Flush()
+}
+
 func (v *JSONSerializer) VisitLabelReplace(e *LabelReplaceExpr) {
 	v.WriteObjectStart()
 
@@ -307,6 +381,7 @@ func (v *JSONSerializer) VisitPipeline(e *PipelineExpr) {
 // Below are StageExpr visitors that we are skipping since a pipeline is
 // serialized as a string.
 func (*JSONSerializer) VisitDecolorize(*DecolorizeExpr)                     {}
+func (*JSONSerializer) VisitDelimitedParser(*DelimitedParserExpr)           {}
 func (*JSONSerializer) VisitDropLabels(*DropLabelsExpr)                     {}
 func (*JSONSerializer) VisitJSONExpressionParser(*JSONExpressionParser)     {}
 func (*JSONSerializer) VisitKeepLabel(*KeepLabelsExpr)                      {}
@@ -317,6 +392,8 @@ func (*JSONSerializer) VisitLineFilter(*LineFilterExpr)                     {}
 func (*JSONSerializer) VisitLineFmt(*LineFmtExpr)                           {}
 func (*JSONSerializer) VisitLogfmtExpressionParser(*LogfmtExpressionParser) {}
 func (*JSONSerializer) VisitLogfmtParser(*LogfmtParserExpr)                 {}
+func (*JSONSerializer) VisitLookup(*LookupExpr)                             {}
+func (*JSONSerializer) VisitXMLExpressionParser(*XMLExpressionParser)       {}
 
 func encodeGrouping(s *jsoniter.Stream, g *Grouping) {
 	s.WriteObjectStart()
@@ -711,6 +788,8 @@ func decodeSample(iter *jsoniter.Iterator) (SampleExpr, error) {
 			expr, err = decodeVectorAgg(iter)
 		case RangeAgg:
 			expr, err = decodeRangeAgg(iter)
+		case SubqueryAgg:
+			expr, err = decodeSubqueryAgg(iter)
 		case Literal:
 			expr, err = decodeLiteral(iter)
 		case Vector:
@@ -848,6 +927,11 @@ func decodeRangeAgg(iter *jsoniter.Iterator) (*RangeAggregationExpr, error) {
 		case Params:
 			tmp := iter.ReadFloat64()
 			expr.Params = &tmp
+		case Buckets:
+			iter.ReadArrayCB(func(i *jsoniter.Iterator) bool {
+				expr.Buckets = append(expr.Buckets, i.ReadFloat64())
+				return true
+			})
 		case Range:
 			expr.Left, err = decodeLogRange(iter)
 		case GroupingField:
@@ -870,6 +954,16 @@ func decodeLogRange(iter *jsoniter.Iterator) (*LogRange, error) {
 			expr.Interval = time.Duration(iter.ReadInt64())
 		case OffsetNanos:
 			expr.Offset = time.Duration(iter.ReadInt64())
+		case AtMillis:
+			if expr.At == nil {
+				expr.At = &AtExpr{}
+			}
+			expr.At.Timestamp = iter.ReadInt64()
+		case AtStartOrEnd:
+			if expr.At == nil {
+				expr.At = &AtExpr{}
+			}
+			expr.At.StartOrEnd = iter.ReadString()
 		case Unwrap:
 			expr.Unwrap = decodeUnwrap(iter)
 		}
@@ -878,6 +972,45 @@ func decodeLogRange(iter *jsoniter.Iterator) (*LogRange, error) {
 	return expr, err
 }
 
+func decodeSubqueryAgg(iter *jsoniter.Iterator) (*SubqueryAggregationExpr, error) {
+	expr := &SubqueryAggregationExpr{}
+	var err error
+
+	for f := iter.ReadObject(); f != ""; f = iter.ReadObject() {
+		switch f {
+		case Op:
+			expr.Operation = iter.ReadString()
+		case Params:
+			tmp := iter.ReadFloat64()
+			expr.Params = &tmp
+		case Subquery:
+			expr.Left, err = decodeSubquery(iter)
+		}
+	}
+
+	return expr, err
+}
+
+func decodeSubquery(iter *jsoniter.Iterator) (*SubqueryExpr, error) {
+	expr := &SubqueryExpr{}
+	var err error
+
+	for f := iter.ReadObject(); f != ""; f = iter.ReadObject() {
+		switch f {
+		case Inner:
+			expr.Left, err = decodeSample(iter)
+		case IntervalNanos:
+			expr.Range = time.Duration(iter.ReadInt64())
+		case StepNanos:
+			expr.Step = time.Duration(iter.ReadInt64())
+		case OffsetNanos:
+			expr.Offset = time.Duration(iter.ReadInt64())
+		}
+	}
+
+	return expr, err
+}
+
 func decodeLabelReplace(iter *jsoniter.Iterator) (*LabelReplaceExpr, error) {
 	var err error
 	var left SampleExpr
@@ -50,6 +50,27 @@ func TestJSONSerializationRoundTrip(t *testing.T) {
 				| line_format "blip{{ .foo }}blop {{.status_code}}" | label_format foo=bar,status_code="buzz{{.bar}}" | unwrap foo
 				| __error__ !~".+"[5m]) by (namespace,instance)`,
 		},
+		"histogram over time": {
+			query: `histogram_over_time(0.1,0.5,1,{app="foo"} | json | unwrap latency [5m]) by (namespace)`,
+		},
+		"at modifier": {
+			query: `sum(rate({app="foo"}[5m] @ 1609746000.000)) / sum(rate({app="foo"}[5m] offset 1w @ end()))`,
+		},
+		"subquery": {
+			query: `max_over_time(sum by (app) (rate({app="foo"}[5m]))[1h:5m] offset 1d) / quantile_over_time(0.99,max_over_time(rate({app="foo"}[5m])[10m:1m])[1h:10m])`,
+		},
+		"csv parser": {
+			query: `sum by (method) (rate({app="foo"} | csv "ip","","method" [5m]))`,
+		},
+		"delimited parser": {
+			query: `sum by (method) (rate({app="foo"} | delimited sep="\t" "ip","method" [5m]))`,
+		},
+		"xml parser": {
+			query: `sum by (event_id) (rate({app="foo"} | xml event_id="Event/System/EventID", provider="Event/System/Provider/@Name" [5m]))`,
+		},
+		"lookup": {
+			query: `sum by (team) (rate({app="foo"} | json | lookup ownership on service [5m]))`,
+		},
 		"multiple post filters": {
 			query: `rate({app="foo"} | json | unwrap foo | latency >= 250ms or bytes > 42B or ( status_code < 500 and status_code > 200) or source = ip("") and user = "me" [1m])`,
 		},
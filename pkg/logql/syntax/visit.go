@@ -10,12 +10,14 @@ type RootVisitor interface {
 	StageExprVisitor
 
 	VisitLogRange(*LogRange)
+	VisitSubquery(*SubqueryExpr)
 }
 
 type SampleExprVisitor interface {
 	VisitBinOp(*BinOpExpr)
 	VisitVectorAggregation(*VectorAggregationExpr)
 	VisitRangeAggregation(*RangeAggregationExpr)
+	VisitSubqueryAggregation(*SubqueryAggregationExpr)
 	VisitLabelReplace(*LabelReplaceExpr)
 	VisitLiteral(*LiteralExpr)
 	VisitVector(*VectorExpr)
@@ -30,6 +32,7 @@ type LogSelectorExprVisitor interface {
 
 type StageExprVisitor interface {
 	VisitDecolorize(*DecolorizeExpr)
+	VisitDelimitedParser(*DelimitedParserExpr)
 	VisitDropLabels(*DropLabelsExpr)
 	VisitJSONExpressionParser(*JSONExpressionParser)
 	VisitKeepLabel(*KeepLabelsExpr)
@@ -40,6 +43,8 @@ type StageExprVisitor interface {
 	VisitLineFmt(*LineFmtExpr)
 	VisitLogfmtExpressionParser(*LogfmtExpressionParser)
 	VisitLogfmtParser(*LogfmtParserExpr)
+	VisitLookup(*LookupExpr)
+	VisitXMLExpressionParser(*XMLExpressionParser)
 }
 
 var _ RootVisitor = &DepthFirstTraversal{}
@@ -47,6 +52,7 @@ var _ RootVisitor = &DepthFirstTraversal{}
 type DepthFirstTraversal struct {
 	VisitBinOpFn                  func(v RootVisitor, e *BinOpExpr)
 	VisitDecolorizeFn             func(v RootVisitor, e *DecolorizeExpr)
+	VisitDelimitedParserFn        func(v RootVisitor, e *DelimitedParserExpr)
 	VisitDropLabelsFn             func(v RootVisitor, e *DropLabelsExpr)
 	VisitJSONExpressionParserFn   func(v RootVisitor, e *JSONExpressionParser)
 	VisitKeepLabelFn              func(v RootVisitor, e *KeepLabelsExpr)
@@ -60,10 +66,14 @@ type DepthFirstTraversal struct {
 	VisitLogRangeFn               func(v RootVisitor, e *LogRange)
 	VisitLogfmtExpressionParserFn func(v RootVisitor, e *LogfmtExpressionParser)
 	VisitLogfmtParserFn           func(v RootVisitor, e *LogfmtParserExpr)
+	VisitLookupFn                 func(v RootVisitor, e *LookupExpr)
 	VisitMatchersFn               func(v RootVisitor, e *MatchersExpr)
 	VisitPipelineFn               func(v RootVisitor, e *PipelineExpr)
 	VisitRangeAggregationFn       func(v RootVisitor, e *RangeAggregationExpr)
+	VisitSubqueryFn               func(v RootVisitor, e *SubqueryExpr)
+	VisitSubqueryAggregationFn    func(v RootVisitor, e *SubqueryAggregationExpr)
 	VisitVectorFn                 func(v RootVisitor, e *VectorExpr)
+	VisitXMLExpressionParserFn    func(v RootVisitor, e *XMLExpressionParser)
 	VisitVectorAggregationFn      func(v RootVisitor, e *VectorAggregationExpr)
 }
 
@@ -90,6 +100,16 @@ func (v *DepthFirstTraversal) VisitDecolorize(e *DecolorizeExpr) {
 	}
 }
 
+// VisitDelimitedParser implements RootVisitor.
+func (v *DepthFirstTraversal) VisitDelimitedParser(e *DelimitedParserExpr) {
+	if e == nil {
+		return
+	}
+	if v.VisitDelimitedParserFn != nil {
+		v.VisitDelimitedParserFn(v, e)
+	}
+}
+
 // VisitDropLabels implements RootVisitor.
 func (v *DepthFirstTraversal) VisitDropLabels(e *DropLabelsExpr) {
 	if e == nil {
@@ -229,6 +249,16 @@ func (v *DepthFirstTraversal) VisitLogfmtParser(e *LogfmtParserExpr) {
 	}
 }
 
+// VisitLookup implements RootVisitor.
+func (v *DepthFirstTraversal) VisitLookup(e *LookupExpr) {
+	if e == nil {
+		return
+	}
+	if v.VisitLookupFn != nil {
+		v.VisitLookupFn(v, e)
+	}
+}
+
 // VisitMatchers implements RootVisitor.
 func (v *DepthFirstTraversal) VisitMatchers(e *MatchersExpr) {
 	if e == nil {
@@ -266,6 +296,30 @@ func (v *DepthFirstTraversal) VisitRangeAggregation(e *RangeAggregationExpr) {
 	}
 }
 
+// VisitSubquery implements RootVisitor.
+func (v *DepthFirstTraversal) VisitSubquery(e *SubqueryExpr) {
+	if e == nil {
+		return
+	}
+	if v.VisitSubqueryFn != nil {
+		v.VisitSubqueryFn(v, e)
+	} else {
+		e.Left.Accept(v)
+	}
+}
+
+// VisitSubqueryAggregation implements RootVisitor.
+func (v *DepthFirstTraversal) VisitSubqueryAggregation(e *SubqueryAggregationExpr) {
+	if e == nil {
+		return
+	}
+	if v.VisitSubqueryAggregationFn != nil {
+		v.VisitSubqueryAggregationFn(v, e)
+	} else {
+		e.Left.Accept(v)
+	}
+}
+
 // VisitVector implements RootVisitor.
 func (v *DepthFirstTraversal) VisitVector(e *VectorExpr) {
 	if e == nil {
@@ -287,3 +341,13 @@ func (v *DepthFirstTraversal) VisitVectorAggregation(e *VectorAggregationExpr) {
 		e.Left.Accept(v)
 	}
 }
+
+// VisitXMLExpressionParser implements RootVisitor.
+func (v *DepthFirstTraversal) VisitXMLExpressionParser(e *XMLExpressionParser) {
+	if e == nil {
+		return
+	}
+	if v.VisitXMLExpressionParserFn != nil {
+		v.VisitXMLExpressionParserFn(v, e)
+	}
+}
@@ -17,6 +17,7 @@ var (
 	ErrBlocked                          = errors.New("query blocked by policy")
 	ErrParseMatchers                    = errors.New("only label matchers are supported")
 	ErrUnsupportedSyntaxForInstantQuery = errors.New("log queries are not supported as an instant query type, please change your query to a range query type")
+	ErrUnsupportedSyntaxForStreaming    = errors.New("metric queries are not supported as a streaming query, please change your query to a log query")
 	ErrorLabel                          = "__error__"
 	PreserveErrorLabel                  = "__preserve_error__"
 	ErrorDetailsLabel                   = "__error_details__"
@@ -66,6 +66,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/storage/config"
 	"github.com/grafana/loki/v3/pkg/storage/stores/series/index"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/bloomshipper"
+	"github.com/grafana/loki/v3/pkg/storage/zstddict"
 	"github.com/grafana/loki/v3/pkg/tracing"
 	"github.com/grafana/loki/v3/pkg/util"
 	"github.com/grafana/loki/v3/pkg/util/constants"
@@ -262,6 +263,15 @@ func (c *Config) Validate() error {
 	if err := c.QueryScheduler.Validate(); err != nil {
 		errs = append(errs, errors.Wrap(err, "CONFIG ERROR: invalid query_scheduler config"))
 	}
+	if err := c.Frontend.QueryJobs.Validate(); err != nil {
+		errs = append(errs, errors.Wrap(err, "CONFIG ERROR: invalid frontend query_jobs config"))
+	}
+	if err := c.Distributor.Syslog.Validate(); err != nil {
+		errs = append(errs, errors.Wrap(err, "CONFIG ERROR: invalid distributor syslog config"))
+	}
+	if err := c.Distributor.SpillQueue.Validate(); err != nil {
+		errs = append(errs, errors.Wrap(err, "CONFIG ERROR: invalid distributor spill_queue config"))
+	}
 	if err := c.TableManager.Validate(); err != nil {
 		errs = append(errs, errors.Wrap(err, "CONFIG ERROR: invalid table_manager config"))
 	}
@@ -387,6 +397,7 @@ type Loki struct {
 	queryScheduler            *scheduler.Scheduler
 	querySchedulerRingManager *lokiring.RingManager
 	usageReport               *analytics.Reporter
+	zstdDictionaries          *zstddict.Dictionaries
 	indexGatewayRingManager   *lokiring.RingManager
 	MetastoreClient           *metastoreclient.Client
 	partitionRingWatcher      *ring.PartitionRingWatcher
@@ -723,6 +734,7 @@ func (t *Loki) setupModuleManager() error {
 	mm.RegisterModule(Metastore, t.initMetastore)
 	mm.RegisterModule(MetastoreClient, t.initMetastoreClient, modules.UserInvisibleModule)
 	mm.RegisterModule(PartitionRing, t.initPartitionRing, modules.UserInvisibleModule)
+	mm.RegisterModule(ZstdDictionaries, t.initZstdDictionaries, modules.UserInvisibleModule)
 
 	mm.RegisterModule(All, nil)
 	mm.RegisterModule(Read, nil)
@@ -737,7 +749,7 @@ func (t *Loki) setupModuleManager() error {
 		OverridesExporter:        {Overrides, Server},
 		TenantConfigs:            {RuntimeConfig},
 		Distributor:              {Ring, Server, Overrides, TenantConfigs, PatternRingClient, PatternIngesterTee, IngesterRF1RingClient, Analytics, PartitionRing},
-		Store:                    {Overrides, IndexGatewayRing},
+		Store:                    {Overrides, IndexGatewayRing, ZstdDictionaries},
 		IngesterRF1:              {Store, Server, MemberlistKV, TenantConfigs, MetastoreClient, Analytics, PartitionRing},
 		IngesterKafka:            {Store, Server, MemberlistKV, TenantConfigs, MetastoreClient, Analytics, PartitionRing},
 		Ingester:                 {Store, Server, MemberlistKV, TenantConfigs, Analytics},
@@ -748,7 +760,7 @@ func (t *Loki) setupModuleManager() error {
 		Ruler:                    {Ring, Server, RulerStorage, RuleEvaluator, Overrides, TenantConfigs, Analytics},
 		RuleEvaluator:            {Ring, Server, Store, IngesterQuerier, Overrides, TenantConfigs, Analytics},
 		TableManager:             {Server, Analytics},
-		Compactor:                {Server, Overrides, MemberlistKV, Analytics},
+		Compactor:                {Server, Overrides, MemberlistKV, Analytics, ZstdDictionaries},
 		IndexGateway:             {Server, Store, BloomStore, IndexGatewayRing, IndexGatewayInterceptors, Analytics},
 		BloomGateway:             {Server, BloomStore, Analytics},
 		BloomPlanner:             {Server, BloomStore, Analytics, Store},
@@ -763,6 +775,7 @@ func (t *Loki) setupModuleManager() error {
 		QuerySchedulerRing:       {Overrides, MemberlistKV},
 		IndexGatewayRing:         {Overrides, MemberlistKV},
 		PartitionRing:            {MemberlistKV, Server, Ring},
+		ZstdDictionaries:         {Overrides},
 		MemberlistKV:             {Server},
 
 		Read:    {QueryFrontend, Querier},
@@ -62,6 +62,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/lokifrontend/frontend/transport"
 	"github.com/grafana/loki/v3/pkg/lokifrontend/frontend/v1/frontendv1pb"
 	"github.com/grafana/loki/v3/pkg/lokifrontend/frontend/v2/frontendv2pb"
+	"github.com/grafana/loki/v3/pkg/lokifrontend/queryjobs"
 	"github.com/grafana/loki/v3/pkg/pattern"
 	"github.com/grafana/loki/v3/pkg/querier"
 	querierrf1 "github.com/grafana/loki/v3/pkg/querier-rf1"
@@ -73,6 +74,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/scheduler"
 	"github.com/grafana/loki/v3/pkg/scheduler/schedulerpb"
 	"github.com/grafana/loki/v3/pkg/storage"
+	"github.com/grafana/loki/v3/pkg/storage/bucket"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/cache"
 	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
 	chunk_util "github.com/grafana/loki/v3/pkg/storage/chunk/client/util"
@@ -84,6 +86,7 @@ import (
 	boltdbcompactor "github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/boltdb/compactor"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb"
 	"github.com/grafana/loki/v3/pkg/storage/types"
+	"github.com/grafana/loki/v3/pkg/storage/zstddict"
 	"github.com/grafana/loki/v3/pkg/util/constants"
 	"github.com/grafana/loki/v3/pkg/util/httpreq"
 	"github.com/grafana/loki/v3/pkg/util/limiter"
@@ -148,6 +151,7 @@ const (
 	Metastore                string = "metastore"
 	MetastoreClient          string = "metastore-client"
 	PartitionRing            string = "partition-ring"
+	ZstdDictionaries         string = "zstd-dictionaries"
 )
 
 const (
@@ -384,16 +388,32 @@ func (t *Loki) initDistributor() (services.Service, error) {
 
 	lokiPushHandler := httpPushHandlerMiddleware.Wrap(http.HandlerFunc(t.distributor.PushHandler))
 	otlpPushHandler := httpPushHandlerMiddleware.Wrap(http.HandlerFunc(t.distributor.OTLPPushHandler))
+	esBulkPushHandler := httpPushHandlerMiddleware.Wrap(http.HandlerFunc(t.distributor.ElasticsearchBulkHandler))
+	esInfoHandler := httpPushHandlerMiddleware.Wrap(http.HandlerFunc(t.distributor.ElasticsearchInfoHandler))
 
 	t.Server.HTTP.Path("/distributor/ring").Methods("GET", "POST").Handler(t.distributor)
+	t.Server.HTTP.Path("/distributor/label_cardinality").Methods("GET").HandlerFunc(t.distributor.LabelCardinalityHandler)
 
 	if t.Cfg.InternalServer.Enable {
 		t.InternalServer.HTTP.Path("/distributor/ring").Methods("GET", "POST").Handler(t.distributor)
+		t.InternalServer.HTTP.Path("/distributor/label_cardinality").Methods("GET").HandlerFunc(t.distributor.LabelCardinalityHandler)
 	}
 
 	t.Server.HTTP.Path("/api/prom/push").Methods("POST").Handler(lokiPushHandler)
 	t.Server.HTTP.Path("/loki/api/v1/push").Methods("POST").Handler(lokiPushHandler)
 	t.Server.HTTP.Path("/otlp/v1/logs").Methods("POST").Handler(otlpPushHandler)
+	if t.Cfg.Distributor.OTLPConfig.TracesReceiverEnabled {
+		otlpTracesPushHandler := httpPushHandlerMiddleware.Wrap(http.HandlerFunc(t.distributor.OTLPTracesPushHandler))
+		t.Server.HTTP.Path("/otlp/v1/traces").Methods("POST").Handler(otlpTracesPushHandler)
+	}
+	if t.Cfg.Distributor.OTLPConfig.MetricsExemplarsReceiverEnabled {
+		otlpMetricsPushHandler := httpPushHandlerMiddleware.Wrap(http.HandlerFunc(t.distributor.OTLPMetricsPushHandler))
+		t.Server.HTTP.Path("/otlp/v1/metrics").Methods("POST").Handler(otlpMetricsPushHandler)
+	}
+	t.Server.HTTP.Path("/loki/api/v1/es").Methods("GET", "HEAD").Handler(esInfoHandler)
+	t.Server.HTTP.Path("/loki/api/v1/es/").Methods("GET", "HEAD").Handler(esInfoHandler)
+	t.Server.HTTP.Path("/loki/api/v1/es/_bulk").Methods("POST", "PUT").Handler(esBulkPushHandler)
+	t.Server.HTTP.Path("/loki/api/v1/es/{index}/_bulk").Methods("POST", "PUT").Handler(esBulkPushHandler)
 	return t.distributor, nil
 }
 
@@ -580,6 +600,16 @@ func (t *Loki) initQuerier() (services.Service, error) {
 	t.Server.HTTP.Path("/loki/api/v1/tail").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.TailHandler)))
 	t.Server.HTTP.Path("/api/prom/tail").Methods("GET", "POST").Handler(httpMiddleware.Wrap(http.HandlerFunc(t.querierAPI.TailHandler)))
 
+	// Streamed queries write their entries as they are read, so like tail requests they can't go through the
+	// query-frontend and query-scheduler and are always registered externally. The frontend proxies them
+	// to the queriers the same way as tail requests.
+	t.Server.HTTP.Path("/loki/api/v1/query_range/stream").Methods("GET", "POST").Handler(
+		middleware.Merge(
+			httpMiddleware,
+			querier.WrapQuerySpanAndTimeout("query.StreamQuery", t.Overrides),
+		).Wrap(http.HandlerFunc(t.querierAPI.StreamQueryHandler)),
+	)
+
 	internalMiddlewares := []queryrangebase.Middleware{
 		serverutil.RecoveryMiddleware,
 		queryrange.Instrument{Metrics: t.Metrics},
@@ -1238,7 +1268,7 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 
 	toMerge := []middleware.Interface{
 		httpreq.ExtractQueryTagsMiddleware(),
-		httpreq.PropagateHeadersMiddleware(httpreq.LokiActorPathHeader, httpreq.LokiEncodingFlagsHeader, httpreq.LokiDisablePipelineWrappersHeader),
+		httpreq.PropagateHeadersMiddleware(httpreq.LokiActorPathHeader, httpreq.LokiQueryPriorityHeader, httpreq.LokiEncodingFlagsHeader, httpreq.LokiDisablePipelineWrappersHeader),
 		serverutil.RecoveryHTTPMiddleware,
 		t.HTTPAuthMiddleware,
 		queryrange.StatsHTTPMiddleware,
@@ -1253,7 +1283,7 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 
 	frontendHandler = middleware.Merge(toMerge...).Wrap(frontendHandler)
 
-	var defaultHandler http.Handler
+	var defaultHandler, streamQueryHandler http.Handler
 	// If this process also acts as a Querier we don't do any proxying of tail requests
 	if t.Cfg.Frontend.TailProxyURL != "" && !t.isModuleActive(Querier) {
 		httpMiddleware := middleware.Merge(
@@ -1283,8 +1313,15 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 		}
 
 		defaultHandler = httpMiddleware.Wrap(tp)
+		// Streamed queries go through the limits and the query budgets of the frontend before being proxied.
+		streamQueryHandler = middleware.Merge(
+			httpMiddleware,
+			queryrange.NewStreamQueryHTTPMiddleware(t.QueryFrontEndMiddleware.Wrap(frontendTripper), t.Cfg.Frontend.Handler.MaxBodySize),
+		).Wrap(tp)
 	} else {
+		// Without a querier to proxy them to, the streamed queries are rejected by the frontend handler.
 		defaultHandler = frontendHandler
+		streamQueryHandler = frontendHandler
 	}
 	t.Server.HTTP.Path("/loki/api/v1/query_range").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/loki/api/v1/query").Methods("GET", "POST").Handler(frontendHandler)
@@ -1304,16 +1341,52 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 	t.Server.HTTP.Path("/api/prom/label/{name}/values").Methods("GET", "POST").Handler(frontendHandler)
 	t.Server.HTTP.Path("/api/prom/series").Methods("GET", "POST").Handler(frontendHandler)
 
-	// Only register tailing requests if this process does not act as a Querier
-	// If this process is also a Querier the Querier will register the tail endpoints.
+	// Only register tailing and streamed query requests if this process does not act as a Querier
+	// If this process is also a Querier the Querier will register the tail and streamed query endpoints.
 	if !t.isModuleActive(Querier) {
-		// defer tail endpoints to the default handler
+		// defer tail and streamed query endpoints to the default handler
 		t.Server.HTTP.Path("/loki/api/v1/tail").Methods("GET", "POST").Handler(defaultHandler)
 		t.Server.HTTP.Path("/api/prom/tail").Methods("GET", "POST").Handler(defaultHandler)
+		t.Server.HTTP.Path("/loki/api/v1/query_range/stream").Methods("GET", "POST").Handler(streamQueryHandler)
+	}
+
+	var queryJobs *queryjobs.Manager
+	if t.Cfg.Frontend.QueryJobs.Enabled {
+		bucketClient, err := bucket.NewClient(context.Background(), t.Cfg.Frontend.QueryJobs.Storage, "query-jobs", util_log.Logger, prometheus.DefaultRegisterer)
+		if err != nil {
+			return nil, err
+		}
+		queryJobs = queryjobs.NewManager(t.Cfg.Frontend.QueryJobs, bucketClient, frontendHandler, util_log.Logger, prometheus.DefaultRegisterer, t.Cfg.MetricsNamespace)
+
+		// The queries of the jobs go through the frontend handler, which authenticates them again.
+		queryJobsMiddleware := middleware.Merge(
+			httpreq.ExtractQueryTagsMiddleware(),
+			serverutil.RecoveryHTTPMiddleware,
+			t.HTTPAuthMiddleware,
+		)
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs").Methods("POST").Handler(queryJobsMiddleware.Wrap(http.HandlerFunc(queryJobs.SubmitHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs/{id}").Methods("GET").Handler(queryJobsMiddleware.Wrap(http.HandlerFunc(queryJobs.StatusHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs/{id}").Methods("DELETE").Handler(queryJobsMiddleware.Wrap(http.HandlerFunc(queryJobs.CancelHandler)))
+		t.Server.HTTP.Path("/loki/api/v1/query_jobs/{id}/results").Methods("GET").Handler(queryJobsMiddleware.Wrap(http.HandlerFunc(queryJobs.ResultsHandler)))
+	}
+	startQueryJobs := func(ctx context.Context) error {
+		if queryJobs == nil {
+			return nil
+		}
+		return services.StartAndAwaitRunning(ctx, queryJobs)
+	}
+	stopQueryJobs := func() {
+		if queryJobs == nil {
+			return
+		}
+		if err := services.StopAndAwaitTerminated(context.Background(), queryJobs); err != nil {
+			level.Warn(util_log.Logger).Log("msg", "failed to stop query jobs service", "err", err)
+		}
 	}
 
 	if t.frontend == nil {
-		return services.NewIdleService(nil, func(_ error) error {
+		return services.NewIdleService(startQueryJobs, func(_ error) error {
+			stopQueryJobs()
 			if t.stopper != nil {
 				t.stopper.Stop()
 				t.stopper = nil
@@ -1323,8 +1396,14 @@ func (t *Loki) initQueryFrontend() (_ services.Service, err error) {
 	}
 
 	return services.NewIdleService(func(ctx context.Context) error {
-		return services.StartAndAwaitRunning(ctx, t.frontend)
+		if err := services.StartAndAwaitRunning(ctx, t.frontend); err != nil {
+			return err
+		}
+		return startQueryJobs(ctx)
 	}, func(_ error) error {
+		// Stop the query jobs first, their queries run through the frontend.
+		stopQueryJobs()
+
 		// Log but not return in case of error, so that other following dependencies
 		// are stopped too.
 		if err := services.StopAndAwaitTerminated(context.Background(), t.frontend); err != nil {
@@ -1871,6 +1950,29 @@ func (t *Loki) initAnalytics() (services.Service, error) {
 	return ur, nil
 }
 
+func (t *Loki) initZstdDictionaries() (services.Service, error) {
+	if !t.Cfg.StorageConfig.ZstdDictionaries.Enabled {
+		return nil, nil
+	}
+	period, err := t.Cfg.SchemaConfig.SchemaForTime(model.Now())
+	if err != nil {
+		return nil, err
+	}
+	objectClient, err := storage.NewObjectClient(period.ObjectType, t.Cfg.StorageConfig, t.ClientMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := t.Cfg.StorageConfig.ZstdDictionaries
+	cfg.RetentionEnabled = t.Cfg.CompactorConfig.RetentionEnabled
+	cfg.MaxChunkAge = t.Cfg.Ingester.MaxChunkAge
+	logger := log.With(util_log.Logger, "component", "zstd-dictionaries")
+	// the dictionaries are trained and deleted by the compactor, there is a single one running at a time.
+	t.zstdDictionaries = zstddict.New(cfg, objectClient, t.isModuleActive(Compactor), t.Overrides, logger, prometheus.DefaultRegisterer)
+	t.Cfg.Ingester.ZstdDictionaries = t.zstdDictionaries
+	return t.zstdDictionaries, nil
+}
+
 func (t *Loki) initMetastore() (services.Service, error) {
 	if !t.Cfg.IngesterRF1.Enabled && !t.Cfg.KafkaIngester.Enabled {
 		return nil, nil
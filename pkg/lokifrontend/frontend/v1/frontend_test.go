@@ -136,7 +136,7 @@ func TestFrontendCheckReady(t *testing.T) {
 			qm := queue.NewMetrics(nil, constants.Loki, "query_frontend")
 			f := &Frontend{
 				log:          log.NewNopLogger(),
-				requestQueue: queue.NewRequestQueue(5, 0, limits.NewQueueLimits(nil), qm),
+				requestQueue: queue.NewRequestQueue(5, 0, limits.NewQueueLimits(nil), queue.PriorityConfig{}, qm),
 			}
 			for i := 0; i < tt.connectedClients; i++ {
 				f.requestQueue.RegisterConsumerConnection("test")
@@ -0,0 +1,54 @@
+package queryjobs
+
+import (
+	"errors"
+	"flag"
+	"time"
+
+	"github.com/grafana/loki/v3/pkg/storage/bucket"
+	"github.com/grafana/loki/v3/pkg/util/flagext"
+)
+
+// Config configures the asynchronous query jobs of the query frontend.
+type Config struct {
+	Enabled           bool             `yaml:"enabled"`
+	MaxConcurrentJobs int              `yaml:"max_concurrent_jobs"`
+	MaxJobsPerTenant  int              `yaml:"max_jobs_per_tenant"`
+	JobTimeout        time.Duration    `yaml:"job_timeout"`
+	MaxResultsSize    flagext.ByteSize `yaml:"max_results_size"`
+	ResultsRetention  time.Duration    `yaml:"results_retention"`
+	CancelPollPeriod  time.Duration    `yaml:"cancel_poll_period"`
+	Storage           bucket.Config    `yaml:"storage" doc:"description=Object storage where the status and the results of the query jobs are stored."`
+}
+
+// RegisterFlags registers flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	prefix := "frontend.query-jobs."
+
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "Enable the asynchronous query API of the query frontend. Query jobs are run in the background and their results are stored in object storage.")
+	f.IntVar(&cfg.MaxConcurrentJobs, prefix+"max-concurrent-jobs", 4, "Maximum number of query jobs run concurrently by each query frontend. Other jobs wait until a job completes.")
+	f.IntVar(&cfg.MaxJobsPerTenant, prefix+"max-jobs-per-tenant", 10, "Maximum number of pending or running query jobs of a tenant on each query frontend. 0 to disable.")
+	f.DurationVar(&cfg.JobTimeout, prefix+"job-timeout", time.Hour, "Maximum duration of a query job. Jobs which did not complete after this duration, for instance because their query frontend stopped, are reported as failed. 0 to disable.")
+	cfg.MaxResultsSize = flagext.ByteSize(100 << 20)
+	f.Var(&cfg.MaxResultsSize, prefix+"max-results-size", "Maximum size of the results of a query job. Jobs whose results exceed it fail. 0 to disable.")
+	f.DurationVar(&cfg.ResultsRetention, prefix+"results-retention", 24*time.Hour, "How long the status and the results of completed query jobs are kept.")
+	f.DurationVar(&cfg.CancelPollPeriod, prefix+"cancel-poll-period", 5*time.Second, "How often the query frontend running a query job checks whether the job was cancelled through another query frontend.")
+	cfg.Storage.RegisterFlagsWithPrefix(prefix+"storage.", f)
+}
+
+// Validate validates the config.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.MaxConcurrentJobs <= 0 {
+		return errors.New("the maximum number of concurrent query jobs must be positive")
+	}
+	if cfg.CancelPollPeriod <= 0 {
+		return errors.New("the period of the checks of the cancellation of query jobs must be positive")
+	}
+	if cfg.ResultsRetention <= 0 {
+		return errors.New("the retention of the results of query jobs must be positive")
+	}
+	return cfg.Storage.Validate()
+}
@@ -0,0 +1,124 @@
+package queryjobs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/user"
+
+	"github.com/grafana/loki/v3/pkg/loghttp"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// SubmitHandler starts a query job. It accepts the parameters of range queries
+// and responds with the status of the job, including its ID.
+func (m *Manager) SubmitHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	query, err := loghttp.ParseRangeQuery(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	// Pin the time range, the job may run later.
+	params := r.Form
+	params.Set("start", strconv.FormatInt(query.Start.UnixNano(), 10))
+	params.Set("end", strconv.FormatInt(query.End.UnixNano(), 10))
+
+	status, err := m.Submit(r.Context(), tenantID, params, r.Header)
+	switch {
+	case errors.Is(err, errTooManyJobs):
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusTooManyRequests, "%s (limit: %d)", err.Error(), m.cfg.MaxJobsPerTenant), w)
+		return
+	case errors.Is(err, errManagerNotRunning):
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusServiceUnavailable, "%s", err.Error()), w)
+		return
+	case err != nil:
+		serverutil.WriteError(err, w)
+		return
+	}
+	writeStatus(w, http.StatusAccepted, status)
+}
+
+// StatusHandler responds with the status of a query job.
+func (m *Manager) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	status, err := m.Status(r.Context(), tenantID, mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeStatus(w, http.StatusOK, status)
+}
+
+// ResultsHandler responds with the results of a succeeded query job.
+// They are the response of the range query of the job.
+func (m *Manager) ResultsHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	results, status, err := m.Results(r.Context(), tenantID, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if results == nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusConflict, "query job %s is %s, results are only available for succeeded jobs", id, status.State), w)
+		return
+	}
+	defer results.Close()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, results); err != nil {
+		level.Warn(m.logger).Log("msg", "failed to write query job results", "tenant", tenantID, "job", id, "err", err)
+	}
+}
+
+// CancelHandler cancels a query job that did not complete, or deletes the results of a completed job.
+func (m *Manager) CancelHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, err := user.ExtractOrgID(r.Context())
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if err := m.Cancel(r.Context(), tenantID, mux.Vars(r)["id"]); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errJobNotFound) {
+		err = httpgrpc.Errorf(http.StatusNotFound, "%s", err.Error())
+	}
+	serverutil.WriteError(err, w)
+}
+
+func writeStatus(w http.ResponseWriter, code int, status Status) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
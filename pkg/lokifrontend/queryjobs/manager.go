@@ -0,0 +1,585 @@
+package queryjobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+)
+
+const (
+	// queryPath is the path of the requests run by the query jobs.
+	queryPath = "/loki/api/v1/query_range"
+
+	statusObject  = "status.json"
+	resultsObject = "results.json"
+	// cancelObject marks the jobs cancelled through any query frontend.
+	cancelObject = "cancel"
+
+	cleanupInterval = 10 * time.Minute
+	// storeTimeout is the timeout to store the status and the results of a completed job.
+	storeTimeout = time.Minute
+)
+
+var (
+	errJobNotFound       = errors.New("query job not found")
+	errTooManyJobs       = errors.New("too many pending or running query jobs")
+	errManagerNotRunning = errors.New("query jobs are not accepted while the query frontend is stopping")
+	errResultsTooLarge   = errors.New("the results of the query job are too large")
+)
+
+// State is the state of a query job.
+type State string
+
+const (
+	StatePending   State = "pending"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCancelled State = "cancelled"
+)
+
+// Done tells if the job completed.
+func (s State) Done() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCancelled
+}
+
+// Status is the status of a query job, as returned by the API and stored next to its results.
+type Status struct {
+	ID          string     `json:"id"`
+	State       State      `json:"state"`
+	Query       string     `json:"query"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	ResultBytes int        `json:"result_bytes,omitempty"`
+}
+
+type job struct {
+	tenantID string
+	req      *http.Request
+	cancel   context.CancelFunc
+
+	// protected by Manager.mtx
+	status    Status
+	cancelled bool
+}
+
+// Manager runs the query jobs and stores their results.
+//
+// Jobs run in the background through the query frontend handler, so their queries
+// are split, sharded and limited like synchronous queries. The status of the jobs
+// is stored under <tenant>/<job id>/ in the bucket when they are submitted and
+// whenever their state changes, next to the results of the succeeded jobs, which
+// are streamed to the bucket as the query responds. Any query frontend can report
+// the jobs and cancel them until they expire, while the jobs in progress of a tenant
+// are counted by the query frontend running them. Jobs are cancelled through a marker
+// object in the bucket, which the query frontend running them polls.
+type Manager struct {
+	services.Service
+
+	cfg     Config
+	bucket  objstore.Bucket
+	handler http.Handler
+	logger  log.Logger
+	now     func() time.Time
+
+	slots chan struct{}
+
+	mtx        sync.Mutex
+	jobs       map[string]*job // pending and running jobs of this query frontend, indexed by tenant and job ID
+	tenantJobs map[string]int  // number of pending and running jobs of this query frontend by tenant
+	stopping   bool
+	wg         sync.WaitGroup
+
+	jobsInProgress prometheus.Gauge
+	jobsFinished   *prometheus.CounterVec
+	resultsBytes   prometheus.Counter
+}
+
+// NewManager creates a manager of query jobs that runs them with the given handler.
+func NewManager(cfg Config, bucket objstore.Bucket, handler http.Handler, logger log.Logger, registerer prometheus.Registerer, metricsNamespace string) *Manager {
+	m := &Manager{
+		cfg:        cfg,
+		bucket:     bucket,
+		handler:    handler,
+		logger:     log.With(logger, "component", "query-jobs"),
+		now:        time.Now,
+		slots:      make(chan struct{}, cfg.MaxConcurrentJobs),
+		jobs:       map[string]*job{},
+		tenantJobs: map[string]int{},
+		jobsInProgress: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_jobs_in_progress",
+			Help:      "Number of pending or running query jobs.",
+		}),
+		jobsFinished: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_jobs_finished_total",
+			Help:      "Total number of completed query jobs by state.",
+		}, []string{"state"}),
+		resultsBytes: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_jobs_results_bytes_total",
+			Help:      "Total number of bytes of query job results stored in object storage.",
+		}),
+	}
+	m.Service = services.NewTimerService(cleanupInterval, nil, m.iteration, m.stop)
+	return m
+}
+
+func (m *Manager) iteration(ctx context.Context) error {
+	m.cleanup(ctx)
+	return nil
+}
+
+// stop cancels the jobs that did not complete and waits for them.
+func (m *Manager) stop(_ error) error {
+	m.mtx.Lock()
+	m.stopping = true
+	for _, j := range m.jobs {
+		j.cancel()
+	}
+	m.mtx.Unlock()
+
+	m.wg.Wait()
+	return nil
+}
+
+func jobKey(tenantID, id string) string {
+	return tenantID + "/" + id
+}
+
+func objectName(tenantID, id, object string) string {
+	return path.Join(tenantID, id, object)
+}
+
+// Submit starts a job running the range query with the given parameters.
+// The headers are passed on to the query, so it is authenticated like the request submitting the job.
+func (m *Manager) Submit(ctx context.Context, tenantID string, params url.Values, header http.Header) (Status, error) {
+	id := uuid.NewString()
+
+	var (
+		jobCtx context.Context
+		cancel context.CancelFunc
+	)
+	if m.cfg.JobTimeout > 0 {
+		jobCtx, cancel = context.WithTimeout(context.Background(), m.cfg.JobTimeout)
+	} else {
+		jobCtx, cancel = context.WithCancel(context.Background())
+	}
+
+	req, err := http.NewRequestWithContext(jobCtx, http.MethodGet, queryPath+"?"+params.Encode(), nil)
+	if err != nil {
+		cancel()
+		return Status{}, err
+	}
+	for name, values := range header {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Type", "Content-Length", "Accept-Encoding":
+			continue
+		}
+		req.Header[name] = values
+	}
+
+	j := &job{
+		tenantID: tenantID,
+		req:      req,
+		cancel:   cancel,
+		status: Status{
+			ID:          id,
+			State:       StatePending,
+			Query:       params.Get("query"),
+			SubmittedAt: m.now().UTC(),
+		},
+	}
+
+	m.mtx.Lock()
+	if m.stopping {
+		m.mtx.Unlock()
+		cancel()
+		return Status{}, errManagerNotRunning
+	}
+	if m.cfg.MaxJobsPerTenant > 0 && m.tenantJobs[tenantID] >= m.cfg.MaxJobsPerTenant {
+		m.mtx.Unlock()
+		cancel()
+		return Status{}, errTooManyJobs
+	}
+	// the job is counted before its status is stored, so that concurrent submissions don't exceed the limit.
+	m.jobs[jobKey(tenantID, id)] = j
+	m.tenantJobs[tenantID]++
+	m.jobsInProgress.Inc()
+	m.wg.Add(1)
+	m.mtx.Unlock()
+	defer m.wg.Done()
+
+	if err := m.storeStatus(ctx, tenantID, j.status); err != nil {
+		cancel()
+		m.forget(j)
+		return Status{}, fmt.Errorf("failed to store the status of the query job: %w", err)
+	}
+
+	// the job fails right away if the query frontend stopped meanwhile.
+	status := j.status
+	m.wg.Add(2)
+	go m.run(j)
+	go m.watchCancel(j)
+
+	return status, nil
+}
+
+// forget removes a job which completed or couldn't be submitted from the jobs in progress.
+func (m *Manager) forget(j *job) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.jobs, jobKey(j.tenantID, j.status.ID))
+	if m.tenantJobs[j.tenantID]--; m.tenantJobs[j.tenantID] <= 0 {
+		delete(m.tenantJobs, j.tenantID)
+	}
+	m.jobsInProgress.Dec()
+}
+
+func (m *Manager) run(j *job) {
+	defer m.wg.Done()
+	defer j.cancel()
+
+	ctx := j.req.Context()
+	select {
+	case m.slots <- struct{}{}:
+		defer func() { <-m.slots }()
+	case <-ctx.Done():
+		m.finish(ctx, j, 0, ctx.Err())
+		return
+	}
+
+	m.mtx.Lock()
+	started := m.now().UTC()
+	j.status.State = StateRunning
+	j.status.StartedAt = &started
+	status := j.status
+	m.mtx.Unlock()
+	if err := m.storeStatus(ctx, j.tenantID, status); err != nil {
+		level.Warn(m.logger).Log("msg", "failed to store query job status", "tenant", j.tenantID, "job", status.ID, "err", err)
+	}
+
+	rec := newResultsWriter(m.cfg.MaxResultsSize.Val(), j.cancel, func(r io.Reader) error {
+		return m.bucket.Upload(ctx, objectName(j.tenantID, status.ID, resultsObject), r)
+	})
+	m.handler.ServeHTTP(rec, j.req)
+
+	var err error
+	switch {
+	case rec.exceeded:
+		err = fmt.Errorf("%w, the limit is %s", errResultsTooLarge, m.cfg.MaxResultsSize)
+	case rec.uploadErr != nil:
+		err = fmt.Errorf("failed to store query job results: %w", rec.uploadErr)
+	case ctx.Err() != nil:
+		err = ctx.Err()
+	case rec.code != http.StatusOK:
+		err = fmt.Errorf("%s", strings.TrimSpace(rec.errBody.String()))
+	}
+	if closeErr := rec.close(err != nil); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to store query job results: %w", closeErr)
+	}
+	if err == nil {
+		m.resultsBytes.Add(float64(rec.written))
+	}
+	m.finish(ctx, j, rec.written, err)
+}
+
+// watchCancel cancels the job when its cancel marker is stored by any query frontend.
+func (m *Manager) watchCancel(j *job) {
+	defer m.wg.Done()
+
+	ctx := j.req.Context()
+	ticker := time.NewTicker(m.cfg.CancelPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ok, err := m.bucket.Exists(ctx, objectName(j.tenantID, j.status.ID, cancelObject))
+		if err != nil {
+			if ctx.Err() == nil {
+				level.Warn(m.logger).Log("msg", "failed to check the cancellation of query job", "tenant", j.tenantID, "job", j.status.ID, "err", err)
+			}
+			continue
+		}
+		if ok {
+			m.mtx.Lock()
+			j.cancelled = true
+			m.mtx.Unlock()
+			j.cancel()
+			return
+		}
+	}
+}
+
+// finish records the completion of a job and stores its status. The results of succeeded jobs are already stored.
+func (m *Manager) finish(ctx context.Context, j *job, resultBytes int, err error) {
+	m.mtx.Lock()
+	finished := m.now().UTC()
+	j.status.FinishedAt = &finished
+	switch {
+	case j.cancelled:
+		j.status.State = StateCancelled
+	case err != nil:
+		j.status.State = StateFailed
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			err = fmt.Errorf("query job exceeded the timeout of %s", m.cfg.JobTimeout)
+		case m.stopping && errors.Is(err, context.Canceled):
+			err = errors.New("query job was cancelled because its query frontend stopped")
+		}
+		j.status.Error = err.Error()
+	default:
+		j.status.State = StateSucceeded
+		j.status.ResultBytes = resultBytes
+	}
+	status := j.status
+	m.mtx.Unlock()
+
+	defer func() {
+		m.forget(j)
+		m.jobsFinished.WithLabelValues(string(status.State)).Inc()
+	}()
+
+	// The query context is done by now when the job failed, use a fresh one to store its status.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), storeTimeout)
+	defer cancel()
+
+	if err := m.storeStatus(ctx, j.tenantID, status); err != nil {
+		level.Error(m.logger).Log("msg", "failed to store query job status", "tenant", j.tenantID, "job", status.ID, "err", err)
+	}
+}
+
+func (m *Manager) storeStatus(ctx context.Context, tenantID string, status Status) error {
+	buf, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return m.bucket.Upload(ctx, objectName(tenantID, status.ID, statusObject), bytes.NewReader(buf))
+}
+
+// Status returns the status of a job, as stored by the query frontend running it.
+func (m *Manager) Status(ctx context.Context, tenantID, id string) (Status, error) {
+	r, err := m.bucket.Get(ctx, objectName(tenantID, id, statusObject))
+	if err != nil {
+		if m.bucket.IsObjNotFoundErr(err) {
+			return Status{}, errJobNotFound
+		}
+		return Status{}, err
+	}
+	defer r.Close()
+
+	var status Status
+	if err := json.NewDecoder(r).Decode(&status); err != nil {
+		return Status{}, fmt.Errorf("failed to decode the status of query job %s: %w", id, err)
+	}
+
+	// A job which did not complete in time was abandoned by its query frontend, which stopped before completing it.
+	if !status.State.Done() && m.cfg.JobTimeout > 0 && m.now().Sub(status.SubmittedAt) > m.cfg.JobTimeout+storeTimeout {
+		status.State = StateFailed
+		status.Error = "query job was abandoned by its query frontend"
+	}
+	return status, nil
+}
+
+// Results returns the results of a succeeded job. The caller must close them.
+func (m *Manager) Results(ctx context.Context, tenantID, id string) (io.ReadCloser, Status, error) {
+	status, err := m.Status(ctx, tenantID, id)
+	if err != nil {
+		return nil, Status{}, err
+	}
+	if status.State != StateSucceeded {
+		return nil, status, nil
+	}
+
+	r, err := m.bucket.Get(ctx, objectName(tenantID, id, resultsObject))
+	if err != nil {
+		if m.bucket.IsObjNotFoundErr(err) {
+			return nil, Status{}, errJobNotFound
+		}
+		return nil, Status{}, err
+	}
+	return r, status, nil
+}
+
+// Cancel cancels a job that did not complete yet, or deletes the status and
+// the results of a completed job. Jobs run by other query frontends are cancelled
+// when they find their cancel marker.
+func (m *Manager) Cancel(ctx context.Context, tenantID, id string) error {
+	status, err := m.Status(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	if status.State.Done() {
+		return m.deleteObjects(ctx, tenantID, id)
+	}
+
+	if err := m.bucket.Upload(ctx, objectName(tenantID, id, cancelObject), bytes.NewReader(nil)); err != nil {
+		return err
+	}
+
+	m.mtx.Lock()
+	if j, ok := m.jobs[jobKey(tenantID, id)]; ok {
+		j.cancelled = true
+		j.cancel()
+	}
+	m.mtx.Unlock()
+	return nil
+}
+
+func (m *Manager) deleteObjects(ctx context.Context, tenantID, id string) error {
+	// The status is deleted last, the job is known until all its objects are deleted.
+	for _, object := range []string{resultsObject, cancelObject, statusObject} {
+		if err := m.bucket.Delete(ctx, objectName(tenantID, id, object)); err != nil && !m.bucket.IsObjNotFoundErr(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// cleanup deletes the stored jobs whose status was not updated for longer than the retention,
+// unless they are still in progress.
+func (m *Manager) cleanup(ctx context.Context) {
+	deadline := m.now().Add(-m.cfg.ResultsRetention)
+
+	err := m.bucket.Iter(ctx, "", func(tenantDir string) error {
+		return m.bucket.Iter(ctx, tenantDir, func(jobDir string) error {
+			tenantID, id := path.Base(tenantDir), path.Base(jobDir)
+			attrs, err := m.bucket.Attributes(ctx, objectName(tenantID, id, statusObject))
+			if err != nil {
+				if m.bucket.IsObjNotFoundErr(err) {
+					// the job is being deleted.
+					return nil
+				}
+				return err
+			}
+			if attrs.LastModified.After(deadline) {
+				return nil
+			}
+			status, err := m.Status(ctx, tenantID, id)
+			if err != nil {
+				if errors.Is(err, errJobNotFound) {
+					return nil
+				}
+				return err
+			}
+			if !status.State.Done() {
+				return nil
+			}
+			level.Debug(m.logger).Log("msg", "deleting expired query job", "tenant", tenantID, "job", id)
+			return m.deleteObjects(ctx, tenantID, id)
+		})
+	})
+	if err != nil {
+		level.Warn(m.logger).Log("msg", "failed to delete expired query jobs", "err", err)
+	}
+}
+
+// resultsWriter streams the response of the query of a job to the bucket, while the query runs.
+// When the response exceeds the limit, the query is cancelled and the upload is aborted.
+// Error responses are kept in memory, to be reported in the status of the job.
+type resultsWriter struct {
+	header  http.Header
+	code    int
+	errBody bytes.Buffer
+	written int
+
+	upload    func(io.Reader) error
+	pw        *io.PipeWriter
+	uploaded  chan error
+	uploadErr error
+
+	limit    int // 0 for no limit
+	exceeded bool
+	cancel   context.CancelFunc
+}
+
+func newResultsWriter(limit int, cancel context.CancelFunc, upload func(io.Reader) error) *resultsWriter {
+	return &resultsWriter{header: http.Header{}, code: http.StatusOK, limit: limit, cancel: cancel, upload: upload}
+}
+
+func (r *resultsWriter) Header() http.Header { return r.header }
+
+func (r *resultsWriter) Write(b []byte) (int, error) {
+	if r.exceeded {
+		return 0, errResultsTooLarge
+	}
+	if r.uploadErr != nil {
+		return 0, r.uploadErr
+	}
+	if r.limit > 0 && r.written+len(b) > r.limit {
+		r.exceeded = true
+		r.cancel()
+		return 0, errResultsTooLarge
+	}
+	r.written += len(b)
+	if r.code != http.StatusOK {
+		return r.errBody.Write(b)
+	}
+
+	if r.pw == nil {
+		r.startUpload()
+	}
+	n, err := r.pw.Write(b)
+	if err != nil {
+		// the upload failed, there is no point in running the query any longer.
+		r.uploadErr = err
+		r.cancel()
+	}
+	return n, err
+}
+
+func (r *resultsWriter) WriteHeader(code int) { r.code = code }
+
+func (r *resultsWriter) startUpload() {
+	pr, pw := io.Pipe()
+	r.pw = pw
+	r.uploaded = make(chan error, 1)
+	go func() {
+		err := r.upload(pr)
+		_ = pr.CloseWithError(err)
+		r.uploaded <- err
+	}()
+}
+
+// close completes the upload of the results, or aborts it when the query of the job failed.
+func (r *resultsWriter) close(abort bool) error {
+	switch {
+	case r.pw == nil && abort:
+		return nil
+	case r.pw == nil:
+		// the query responded without results.
+		r.startUpload()
+	case abort:
+		_ = r.pw.CloseWithError(errors.New("query job failed"))
+		<-r.uploaded
+		return nil
+	}
+	_ = r.pw.Close()
+	return <-r.uploaded
+}
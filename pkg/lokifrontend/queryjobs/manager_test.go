@@ -0,0 +1,363 @@
+package queryjobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func testConfig() Config {
+	return Config{
+		Enabled:           true,
+		MaxConcurrentJobs: 2,
+		MaxJobsPerTenant:  2,
+		JobTimeout:        time.Minute,
+		MaxResultsSize:    1 << 20,
+		ResultsRetention:  time.Hour,
+		CancelPollPeriod:  10 * time.Millisecond,
+	}
+}
+
+func newTestManager(t *testing.T, cfg Config, bucket objstore.Bucket, handler http.HandlerFunc) *Manager {
+	m := NewManager(cfg, bucket, handler, log.NewNopLogger(), nil, "loki")
+	t.Cleanup(func() { _ = m.stop(nil) })
+	return m
+}
+
+func waitForState(t *testing.T, m *Manager, tenantID, id string, state State) Status {
+	var status Status
+	require.Eventually(t, func() bool {
+		var err error
+		status, err = m.Status(context.Background(), tenantID, id)
+		require.NoError(t, err)
+		return status.State == state
+	}, 5*time.Second, 10*time.Millisecond)
+	return status
+}
+
+func queryParams(query string) url.Values {
+	return url.Values{"query": []string{query}, "start": []string{"1"}, "end": []string{"2"}}
+}
+
+func TestManager_Succeeded(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	m := newTestManager(t, testConfig(), bucket, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, queryPath, r.URL.Path)
+		require.Equal(t, "tenant", r.Header.Get("X-Scope-OrgID"))
+		require.Empty(t, r.Header.Get("Content-Type"))
+		fmt.Fprintf(w, `{"status":"success","query":%q}`, r.URL.Query().Get("query"))
+	})
+
+	header := http.Header{"X-Scope-Orgid": []string{"tenant"}, "Content-Type": []string{"application/x-www-form-urlencoded"}}
+	status, err := m.Submit(context.Background(), "tenant", queryParams(`{app="foo"}`), header)
+	require.NoError(t, err)
+	require.Equal(t, StatePending, status.State)
+	require.Equal(t, `{app="foo"}`, status.Query)
+
+	// The status is stored when the job is submitted.
+	ok, err := bucket.Exists(context.Background(), objectName("tenant", status.ID, statusObject))
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	status = waitForState(t, m, "tenant", status.ID, StateSucceeded)
+	require.NotNil(t, status.StartedAt)
+	require.NotNil(t, status.FinishedAt)
+	expected := `{"status":"success","query":"{app=\"foo\"}"}`
+	require.Equal(t, len(expected), status.ResultBytes)
+
+	other := newTestManager(t, testConfig(), bucket, nil)
+	for _, m := range []*Manager{m, other} {
+		results, s, err := m.Results(context.Background(), "tenant", status.ID)
+		require.NoError(t, err)
+		require.Equal(t, StateSucceeded, s.State)
+		buf, err := io.ReadAll(results)
+		require.NoError(t, err)
+		require.NoError(t, results.Close())
+		require.Equal(t, expected, string(buf))
+	}
+
+	// Jobs are scoped by tenant.
+	_, err = other.Status(context.Background(), "other-tenant", status.ID)
+	require.ErrorIs(t, err, errJobNotFound)
+
+	// Deleting a completed job deletes its results, from any frontend.
+	require.NoError(t, other.Cancel(context.Background(), "tenant", status.ID))
+	_, err = m.Status(context.Background(), "tenant", status.ID)
+	require.ErrorIs(t, err, errJobNotFound)
+	require.Empty(t, bucket.Objects())
+}
+
+func TestManager_Failed(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	m := newTestManager(t, testConfig(), bucket, func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "parse error", http.StatusBadRequest)
+	})
+
+	status, err := m.Submit(context.Background(), "tenant", queryParams(`{app=`), nil)
+	require.NoError(t, err)
+	status = waitForState(t, m, "tenant", status.ID, StateFailed)
+	require.Equal(t, "parse error", status.Error)
+
+	results, status, err := m.Results(context.Background(), "tenant", status.ID)
+	require.NoError(t, err)
+	require.Nil(t, results)
+	require.Equal(t, StateFailed, status.State)
+}
+
+func TestManager_Cancel(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	started := make(chan struct{}, 2)
+	m := newTestManager(t, testConfig(), bucket, func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+		http.Error(w, r.Context().Err().Error(), 499)
+	})
+	other := newTestManager(t, testConfig(), bucket, nil)
+
+	first, err := m.Submit(context.Background(), "tenant", queryParams(`{app="foo"}`), nil)
+	require.NoError(t, err)
+	second, err := m.Submit(context.Background(), "tenant", queryParams(`{app="bar"}`), nil)
+	require.NoError(t, err)
+	<-started
+	<-started
+	waitForState(t, other, "tenant", first.ID, StateRunning)
+
+	// The tenant reached its limit of jobs in progress on this frontend.
+	_, err = m.Submit(context.Background(), "tenant", queryParams(`{app="baz"}`), nil)
+	require.ErrorIs(t, err, errTooManyJobs)
+	third, err := m.Submit(context.Background(), "other-tenant", queryParams(`{app="baz"}`), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Cancel(context.Background(), "tenant", first.ID))
+	status := waitForState(t, other, "tenant", first.ID, StateCancelled)
+	require.Empty(t, status.Error)
+
+	// The cancelled job released its slot.
+	waitForState(t, m, "other-tenant", third.ID, StateRunning)
+	status, err = m.Status(context.Background(), "tenant", second.ID)
+	require.NoError(t, err)
+	require.Equal(t, StateRunning, status.State)
+
+	// Jobs are cancelled through any frontend.
+	require.NoError(t, other.Cancel(context.Background(), "tenant", second.ID))
+	waitForState(t, m, "tenant", second.ID, StateCancelled)
+
+	// Cancelling a cancelled job deletes it.
+	require.NoError(t, other.Cancel(context.Background(), "tenant", second.ID))
+	_, err = m.Status(context.Background(), "tenant", second.ID)
+	require.ErrorIs(t, err, errJobNotFound)
+}
+
+func TestManager_ResultsTooLarge(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxResultsSize = 10
+	m := newTestManager(t, cfg, objstore.NewInMemBucket(), func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"status":`))
+		require.NoError(t, err)
+		_, err = w.Write([]byte(`"success"}`))
+		require.ErrorIs(t, err, errResultsTooLarge)
+		require.Error(t, r.Context().Err(), "the query is cancelled")
+	})
+
+	status, err := m.Submit(context.Background(), "tenant", queryParams(`{app="foo"}`), nil)
+	require.NoError(t, err)
+	status = waitForState(t, m, "tenant", status.ID, StateFailed)
+	require.Equal(t, "the results of the query job are too large, the limit is 10B", status.Error)
+}
+
+// streamingBucket signals the bytes of the uploaded objects as they are read, and fails the uploads with uploadErr.
+type streamingBucket struct {
+	objstore.Bucket
+	read      chan int
+	uploadErr error
+}
+
+func (b *streamingBucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	if strings.HasSuffix(name, resultsObject) {
+		if b.uploadErr != nil {
+			return b.uploadErr
+		}
+		src := r
+		r = readerFunc(func(p []byte) (int, error) {
+			n, err := src.Read(p)
+			if n > 0 {
+				b.read <- n
+			}
+			return n, err
+		})
+	}
+	return b.Bucket.Upload(ctx, name, r)
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+func TestManager_StreamsResults(t *testing.T) {
+	bucket := &streamingBucket{Bucket: objstore.NewInMemBucket(), read: make(chan int, 10)}
+	m := newTestManager(t, testConfig(), bucket, func(w http.ResponseWriter, _ *http.Request) {
+		_, err := w.Write([]byte(`{"status":`))
+		require.NoError(t, err)
+		// the results are uploaded while the query runs, instead of being held in memory.
+		require.Equal(t, len(`{"status":`), <-bucket.read)
+		_, err = w.Write([]byte(`"success"}`))
+		require.NoError(t, err)
+	})
+
+	status, err := m.Submit(context.Background(), "tenant", queryParams(`{app="foo"}`), nil)
+	require.NoError(t, err)
+	status = waitForState(t, m, "tenant", status.ID, StateSucceeded)
+	require.Equal(t, len(`{"status":"success"}`), status.ResultBytes)
+
+	results, _, err := m.Results(context.Background(), "tenant", status.ID)
+	require.NoError(t, err)
+	buf, err := io.ReadAll(results)
+	require.NoError(t, err)
+	require.NoError(t, results.Close())
+	require.Equal(t, `{"status":"success"}`, string(buf))
+}
+
+func TestManager_UploadFailed(t *testing.T) {
+	bucket := &streamingBucket{Bucket: objstore.NewInMemBucket(), uploadErr: errors.New("bucket unavailable")}
+	m := newTestManager(t, testConfig(), bucket, func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(`{"status":`))
+		require.Error(t, err)
+		require.Error(t, r.Context().Err(), "the query is cancelled")
+	})
+
+	status, err := m.Submit(context.Background(), "tenant", queryParams(`{app="foo"}`), nil)
+	require.NoError(t, err)
+	status = waitForState(t, m, "tenant", status.ID, StateFailed)
+	require.Equal(t, "failed to store query job results: bucket unavailable", status.Error)
+
+	// The failed job doesn't count in the jobs in progress of the tenant anymore.
+	for i := 0; i < 2; i++ {
+		status, err := m.Submit(context.Background(), "tenant", queryParams(`{app="foo"}`), nil)
+		require.NoError(t, err)
+		waitForState(t, m, "tenant", status.ID, StateFailed)
+	}
+}
+
+func TestManager_Abandoned(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	m := newTestManager(t, testConfig(), bucket, nil)
+
+	// The status of a job whose query frontend stopped before completing it.
+	require.NoError(t, m.storeStatus(context.Background(), "tenant", Status{
+		ID:          "abandoned",
+		State:       StateRunning,
+		SubmittedAt: time.Now().Add(-time.Hour),
+	}))
+
+	status, err := m.Status(context.Background(), "tenant", "abandoned")
+	require.NoError(t, err)
+	require.Equal(t, StateFailed, status.State)
+	require.Equal(t, "query job was abandoned by its query frontend", status.Error)
+}
+
+func TestManager_Timeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.JobTimeout = 50 * time.Millisecond
+	m := newTestManager(t, cfg, objstore.NewInMemBucket(), func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		http.Error(w, r.Context().Err().Error(), http.StatusGatewayTimeout)
+	})
+
+	status, err := m.Submit(context.Background(), "tenant", queryParams(`{app="foo"}`), nil)
+	require.NoError(t, err)
+	status = waitForState(t, m, "tenant", status.ID, StateFailed)
+	require.Equal(t, "query job exceeded the timeout of 50ms", status.Error)
+}
+
+func TestManager_Cleanup(t *testing.T) {
+	bucket := objstore.NewInMemBucket()
+	m := newTestManager(t, testConfig(), bucket, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	status, err := m.Submit(context.Background(), "tenant", queryParams(`{app="foo"}`), nil)
+	require.NoError(t, err)
+	waitForState(t, m, "tenant", status.ID, StateSucceeded)
+
+	m.cleanup(context.Background())
+	_, err = m.Status(context.Background(), "tenant", status.ID)
+	require.NoError(t, err)
+
+	m.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	m.cleanup(context.Background())
+	_, err = m.Status(context.Background(), "tenant", status.ID)
+	require.ErrorIs(t, err, errJobNotFound)
+	require.Empty(t, bucket.Objects())
+}
+
+func TestHandlers(t *testing.T) {
+	m := newTestManager(t, testConfig(), objstore.NewInMemBucket(), func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.RawQuery))
+	})
+
+	router := mux.NewRouter()
+	router.Path("/loki/api/v1/query_jobs").Methods("POST").HandlerFunc(m.SubmitHandler)
+	router.Path("/loki/api/v1/query_jobs/{id}").Methods("GET").HandlerFunc(m.StatusHandler)
+	router.Path("/loki/api/v1/query_jobs/{id}").Methods("DELETE").HandlerFunc(m.CancelHandler)
+	router.Path("/loki/api/v1/query_jobs/{id}/results").Methods("GET").HandlerFunc(m.ResultsHandler)
+
+	do := func(method, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req = req.WithContext(user.InjectOrgID(req.Context(), "tenant"))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("POST", "/loki/api/v1/query_jobs", `query={app="foo"}&start=1700000000&end=1700003600&limit=10`)
+	require.Equal(t, http.StatusAccepted, rec.Code, rec.Body.String())
+	var status Status
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	require.NotEmpty(t, status.ID)
+
+	require.Eventually(t, func() bool {
+		rec := do("GET", "/loki/api/v1/query_jobs/"+status.ID, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		return status.State == StateSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+
+	rec = do("GET", "/loki/api/v1/query_jobs/"+status.ID+"/results", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	params, err := url.ParseQuery(rec.Body.String())
+	require.NoError(t, err)
+	require.Equal(t, `{app="foo"}`, params.Get("query"))
+	require.Equal(t, "10", params.Get("limit"))
+	require.Equal(t, "1700000000000000000", params.Get("start"), "the time range is pinned when the job is submitted")
+	require.Equal(t, "1700003600000000000", params.Get("end"))
+
+	rec = do("POST", "/loki/api/v1/query_jobs", `query={app="foo"}&start=2&end=1`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = do("GET", "/loki/api/v1/query_jobs/unknown", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	rec = do("GET", "/loki/api/v1/query_jobs/unknown/results", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = do("DELETE", "/loki/api/v1/query_jobs/"+status.ID, "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do("GET", "/loki/api/v1/query_jobs/"+status.ID+"/results", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
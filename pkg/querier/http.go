@@ -2,6 +2,7 @@ package querier
 
 import (
 	"context"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -255,6 +256,86 @@ func (q *QuerierAPI) TailHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// StreamQueryHandler is a http.HandlerFunc for log queries writing their entries
+// as newline delimited JSON while they are read, instead of materializing the
+// whole result, so that large exports use a bounded amount of memory.
+func (q *QuerierAPI) StreamQueryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := util_log.WithContext(ctx, util_log.Logger)
+
+	req, err := loghttp.ParseRangeQuery(r)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+
+	// streamed queries don't hold their entries in memory, so they are limited by their own entries limit,
+	// which is also their limit when they don't have one.
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+		return
+	}
+	maxEntriesCapture := func(id string) int { return q.limits.MaxStreamedEntriesLimitPerQuery(ctx, id) }
+	maxEntriesLimit := util_validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, maxEntriesCapture)
+	if r.Form.Get("limit") == "" {
+		req.Limit = math.MaxUint32
+		if maxEntriesLimit != 0 {
+			req.Limit = uint32(maxEntriesLimit)
+		}
+	}
+	if int64(req.Limit) > int64(maxEntriesLimit) && maxEntriesLimit != 0 {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest,
+			"max streamed entries limit per query exceeded, limit > max_streamed_entries_limit_per_query (%d > %d)", req.Limit, maxEntriesLimit), w)
+		return
+	}
+
+	params, err := logql.NewLiteralParams(
+		req.Query,
+		req.Start,
+		req.End,
+		req.Step,
+		req.Interval,
+		req.Direction,
+		req.Limit,
+		req.Shards,
+		nil,
+	)
+	if err != nil {
+		serverutil.WriteError(err, w)
+		return
+	}
+	if _, ok := params.GetExpression().(syntax.LogSelectorExpr); !ok {
+		serverutil.WriteError(logqlmodel.ErrUnsupportedSyntaxForStreaming, w)
+		return
+	}
+
+	query, ok := q.engine.Query(params).(logql.StreamingQuery)
+	if !ok {
+		serverutil.WriteError(httpgrpc.Errorf(http.StatusNotImplemented, "streaming queries are not supported"), w)
+		return
+	}
+
+	encodingFlags := httpreq.ExtractEncodingFlags(r)
+	ctx = httpreq.AddEncodingFlagsToContext(ctx, encodingFlags)
+
+	w.Header().Set("Content-Type", marshal.NDJSONContentType)
+	writer := marshal.NewNDJSONQueryWriter(w, encodingFlags)
+	res, err := query.ExecStream(ctx, writer)
+	if err != nil {
+		if !writer.Flushed() {
+			// nothing was sent yet so the error can still be returned with its status.
+			serverutil.WriteError(err, w)
+			return
+		}
+		// the status was already sent, the error is written on the last line.
+		_, err = serverutil.ClientHTTPStatusAndError(err)
+	}
+	if err := writer.Close(res, err); err != nil {
+		level.Error(logger).Log("msg", "error writing streamed query response", "err", err)
+	}
+}
+
 // SeriesHandler returns the list of time series that match a certain label set.
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#finding-series-by-label-matchers
 func (q *QuerierAPI) SeriesHandler(ctx context.Context, req *logproto.SeriesRequest) (*logproto.SeriesResponse, stats.Result, error) {
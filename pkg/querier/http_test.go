@@ -2,15 +2,18 @@ package querier
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/grafana/loki/v3/pkg/iter"
 	"github.com/grafana/loki/v3/pkg/loghttp"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logqlmodel"
@@ -298,3 +301,129 @@ func setupAPI(querier *querierMock) *QuerierAPI {
 	api := NewQuerierAPI(Config{}, querier, nil, log.NewNopLogger())
 	return api
 }
+
+func TestStreamQueryHandler(t *testing.T) {
+	defaultLimits := defaultLimitsTestConfig()
+	limits, err := validation.NewOverrides(defaultLimits, nil)
+	require.NoError(t, err)
+
+	newRequestWithLimit := func(query, limit string) *http.Request {
+		req, err := http.NewRequest("GET", `/loki/api/v1/query_range/stream`, nil)
+		require.NoError(t, err)
+		q := req.URL.Query()
+		q.Add("query", query)
+		q.Add("start", "0")
+		q.Add("end", "10")
+		if limit != "" {
+			q.Add("limit", limit)
+		}
+		q.Add("direction", "forward")
+		req.URL.RawQuery = q.Encode()
+		require.NoError(t, req.ParseForm())
+		return req.WithContext(user.InjectOrgID(req.Context(), "user"))
+	}
+	newRequest := func(query string) *http.Request {
+		return newRequestWithLimit(query, "150")
+	}
+	countEntries := func(t *testing.T, body string) int {
+		lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+		var entries int
+		for _, l := range lines[:len(lines)-1] {
+			var stream loghttp.Stream
+			require.NoError(t, json.Unmarshal([]byte(l), &stream))
+			entries += len(stream.Entries)
+		}
+		return entries
+	}
+
+	t.Run("streams entries", func(t *testing.T) {
+		querier := newQuerierMock()
+		querier.On("SelectLogs", mock.Anything, mock.Anything).Return(func() iter.EntryIterator { return mockStreamIterator(1, 200) }, nil)
+		api := NewQuerierAPI(mockQuerierConfig(), querier, limits, log.NewNopLogger())
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.StreamQueryHandler).ServeHTTP(rr, newRequest(`{type="test"}`))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+		require.Len(t, lines, 3)
+
+		var entries int
+		for _, l := range lines[:2] {
+			var stream loghttp.Stream
+			require.NoError(t, json.Unmarshal([]byte(l), &stream))
+			require.Equal(t, loghttp.LabelSet{"type": "test"}, stream.Labels)
+			entries += len(stream.Entries)
+		}
+		require.Equal(t, 150, entries)
+
+		var status struct {
+			Status string `json:"status"`
+			Stats  struct {
+				Summary struct {
+					TotalEntriesReturned int `json:"totalEntriesReturned"`
+				} `json:"summary"`
+			} `json:"stats"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(lines[2]), &status))
+		require.Equal(t, "success", status.Status)
+		require.Equal(t, 150, status.Stats.Summary.TotalEntriesReturned)
+	})
+
+	t.Run("errors before any entry is sent", func(t *testing.T) {
+		querier := newQuerierMock()
+		querier.On("SelectLogs", mock.Anything, mock.Anything).Return(func() iter.EntryIterator { return nil }, errors.New("store unavailable"))
+		api := NewQuerierAPI(mockQuerierConfig(), querier, limits, log.NewNopLogger())
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.StreamQueryHandler).ServeHTTP(rr, newRequest(`{type="test"}`))
+		require.Equal(t, http.StatusInternalServerError, rr.Code)
+		require.Equal(t, "store unavailable", rr.Body.String())
+	})
+
+	t.Run("streamed entries limit", func(t *testing.T) {
+		streamedLimits := defaultLimitsTestConfig()
+		streamedLimits.MaxEntriesLimitPerQuery = 100
+		overrides, err := validation.NewOverrides(streamedLimits, nil)
+		require.NoError(t, err)
+		querier := newQuerierMock()
+		querier.On("SelectLogs", mock.Anything, mock.Anything).Return(func() iter.EntryIterator { return mockStreamIterator(1, 200) }, nil)
+		api := NewQuerierAPI(mockQuerierConfig(), querier, overrides, log.NewNopLogger())
+
+		// the entries limit of the other queries doesn't apply, and all the entries are streamed without limit.
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.StreamQueryHandler).ServeHTTP(rr, newRequest(`{type="test"}`))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, 150, countEntries(t, rr.Body.String()))
+
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(api.StreamQueryHandler).ServeHTTP(rr, newRequestWithLimit(`{type="test"}`, ""))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, 200, countEntries(t, rr.Body.String()))
+
+		streamedLimits.MaxStreamedEntriesLimit = 120
+		overrides, err = validation.NewOverrides(streamedLimits, nil)
+		require.NoError(t, err)
+		api = NewQuerierAPI(mockQuerierConfig(), querier, overrides, log.NewNopLogger())
+
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(api.StreamQueryHandler).ServeHTTP(rr, newRequest(`{type="test"}`))
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Contains(t, rr.Body.String(), "max streamed entries limit per query exceeded")
+
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(api.StreamQueryHandler).ServeHTTP(rr, newRequestWithLimit(`{type="test"}`, ""))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Equal(t, 120, countEntries(t, rr.Body.String()))
+	})
+
+	t.Run("metric queries are not supported", func(t *testing.T) {
+		api := NewQuerierAPI(mockQuerierConfig(), nil, limits, log.NewNopLogger())
+
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(api.StreamQueryHandler).ServeHTTP(rr, newRequest(`rate({type="test"}[1m])`))
+		require.Equal(t, http.StatusBadRequest, rr.Code)
+		require.Equal(t, logqlmodel.ErrUnsupportedSyntaxForStreaming.Error(), rr.Body.String())
+	})
+}
@@ -19,4 +19,5 @@ type Limits interface {
 	MaxStreamsMatchersPerQuery(context.Context, string) int
 	MaxConcurrentTailRequests(context.Context, string) int
 	MaxEntriesLimitPerQuery(context.Context, string) int
+	MaxStreamedEntriesLimitPerQuery(context.Context, string) int
 }
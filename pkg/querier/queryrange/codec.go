@@ -711,6 +711,11 @@ func (c Codec) EncodeRequest(ctx context.Context, r queryrangebase.Request) (*ht
 		header.Set(httpreq.LokiActorPathHeader, actor)
 	}
 
+	// Add query priority
+	if priority := httpreq.ExtractHeader(ctx, httpreq.LokiQueryPriorityHeader); priority != "" {
+		header.Set(httpreq.LokiQueryPriorityHeader, priority)
+	}
+
 	// Add disable wrappers
 	if disableWrappers := httpreq.ExtractHeader(ctx, httpreq.LokiDisablePipelineWrappersHeader); disableWrappers != "" {
 		header.Set(httpreq.LokiDisablePipelineWrappersHeader, disableWrappers)
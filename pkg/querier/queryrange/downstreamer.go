@@ -118,7 +118,9 @@ func withoutOffset(query logql.DownstreamQuery) (string, time.Time, time.Time) {
 		case *syntax.RangeAggregationExpr:
 			off := rng.Left.Offset
 
-			if off != 0 {
+			// the evaluation time of ranges using the @ modifier does not
+			// depend on the query time range, so their offset is kept.
+			if off != 0 && rng.Left.At == nil {
 				rng.Left.Offset = 0 // remove offset
 
 				// adjust start and end time
@@ -40,6 +40,7 @@ import (
 const (
 	limitErrTmpl                             = "maximum of series (%d) reached for a single query"
 	maxSeriesErrTmpl                         = "max entries limit per query exceeded, limit > max_entries_limit (%d > %d)"
+	maxStreamedEntriesErrTmpl                = "max streamed entries limit per query exceeded, limit > max_streamed_entries_limit_per_query (%d > %d)"
 	requiredLabelsErrTmpl                    = "stream selector is missing required matchers [%s], labels present in the query were [%s]"
 	requiredNumberLabelsErrTmpl              = "stream selector has less label matchers than required: (present: [%s], number_present: %d, required_number_label_matchers: %d)"
 	limErrQueryTooManyBytesTmpl              = "the query would read too many bytes (query: %s, limit: %s); consider adding more specific stream selectors or reduce the time range of the query"
@@ -369,6 +370,8 @@ func (q *querySizeLimiter) Do(ctx context.Context, r queryrangebase.Request) (qu
 			level.Warn(log).Log("msg", "Query exceeds limits", "status", "rejected", "limit_name", q.guessLimitName(), "limit_bytes", maxBytesReadStr, "resolved_bytes", statsBytesStr)
 			return nil, httpgrpc.Errorf(http.StatusBadRequest, q.limitErrorTmpl, statsBytesStr, maxBytesReadStr)
 		}
+		// the estimate is reused by the query budgets.
+		ctx = withQueryBytesEstimate(ctx, r, bytesRead)
 	}
 
 	return q.next.Do(ctx, r)
@@ -690,6 +693,22 @@ func validateMaxEntriesLimits(ctx context.Context, reqLimit uint32, limits Limit
 	return nil
 }
 
+// validates log entries limits of the streamed queries, which don't hold their entries in memory.
+func validateMaxStreamedEntriesLimits(ctx context.Context, reqLimit uint32, limits Limits) error {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+
+	maxEntriesCapture := func(id string) int { return limits.MaxStreamedEntriesLimitPerQuery(ctx, id) }
+	maxEntriesLimit := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, maxEntriesCapture)
+
+	if int64(reqLimit) > int64(maxEntriesLimit) && maxEntriesLimit != 0 {
+		return fmt.Errorf(maxStreamedEntriesErrTmpl, reqLimit, maxEntriesLimit)
+	}
+	return nil
+}
+
 func validateMatchers(ctx context.Context, limits Limits, matchers []*labels.Matcher) error {
 	tenants, err := tenant.TenantIDs(ctx)
 	if err != nil {
@@ -0,0 +1,457 @@
+package queryrange
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/storage/types"
+	"github.com/grafana/loki/v3/pkg/util/spanlogger"
+)
+
+const (
+	limErrQueryBudgetExceededTmpl = "the query would exceed the query bytes budget of tenant %s for the last %s (query: %s, remaining: %s, budget: %s); retry later, add more specific stream selectors or reduce the time range of the query"
+
+	// budgetWindowBuckets is the number of buckets a budget window is divided in.
+	// Bytes are released from the window one bucket at a time.
+	budgetWindowBuckets = 60
+)
+
+// budgetWindow is a rolling window over which the bytes read by the queries of a tenant are limited.
+type budgetWindow struct {
+	name     string
+	duration time.Duration
+	limit    func(Limits) func(context.Context, string) int
+}
+
+var budgetWindows = []budgetWindow{
+	{
+		name:     "hour",
+		duration: time.Hour,
+		limit:    func(l Limits) func(context.Context, string) int { return l.QueryBytesBudgetPerHour },
+	},
+	{
+		name:     "day",
+		duration: 24 * time.Hour,
+		limit:    func(l Limits) func(context.Context, string) int { return l.QueryBytesBudgetPerDay },
+	},
+}
+
+// rollingBytes counts bytes over a rolling window divided in budgetWindowBuckets buckets.
+type rollingBytes struct {
+	bucketDuration int64
+	buckets        [budgetWindowBuckets]uint64
+	last           int64 // index of the current bucket since the epoch
+}
+
+func newRollingBytes(window time.Duration) *rollingBytes {
+	return &rollingBytes{bucketDuration: int64(window / budgetWindowBuckets)}
+}
+
+// advance releases the bytes of the buckets that went out of the window.
+func (r *rollingBytes) advance(now time.Time) {
+	idx := now.UnixNano() / r.bucketDuration
+	if idx <= r.last {
+		return
+	}
+	if idx-r.last >= budgetWindowBuckets {
+		r.buckets = [budgetWindowBuckets]uint64{}
+	} else {
+		for i := r.last + 1; i <= idx; i++ {
+			r.buckets[i%budgetWindowBuckets] = 0
+		}
+	}
+	r.last = idx
+}
+
+func (r *rollingBytes) add(now time.Time, bytes uint64) {
+	r.advance(now)
+	r.buckets[r.last%budgetWindowBuckets] += bytes
+}
+
+// sub removes bytes added at the given time from the window, unless they already left it.
+func (r *rollingBytes) sub(now, at time.Time, bytes uint64) {
+	r.advance(now)
+	idx := at.UnixNano() / r.bucketDuration
+	if idx > r.last || r.last-idx >= budgetWindowBuckets {
+		return
+	}
+	b := &r.buckets[idx%budgetWindowBuckets]
+	*b -= min(*b, bytes)
+}
+
+func (r *rollingBytes) total(now time.Time) uint64 {
+	r.advance(now)
+	var total uint64
+	for _, b := range r.buckets {
+		total += b
+	}
+	return total
+}
+
+// QueryBudgets tracks the bytes read by the queries of each tenant over the budget windows.
+// Budgets are tracked in memory, so each query-frontend enforces them independently.
+type QueryBudgets struct {
+	mtx     sync.Mutex
+	tenants map[string][]*rollingBytes // indexed like budgetWindows
+	now     func() time.Time
+
+	usedBytesDesc *prometheus.Desc
+	limitBytes    *prometheus.GaugeVec
+	rejected      *prometheus.CounterVec
+	admittedBytes *prometheus.CounterVec
+	refundedBytes *prometheus.CounterVec
+}
+
+// NewQueryBudgets creates a tracker of query budgets and registers its metrics.
+func NewQueryBudgets(registerer prometheus.Registerer, metricsNamespace string) *QueryBudgets {
+	b := &QueryBudgets{
+		tenants: map[string][]*rollingBytes{},
+		now:     time.Now,
+		usedBytesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "query_frontend", "query_budget_used_bytes"),
+			"Bytes of the query budget used by the tenant over the rolling window.",
+			[]string{"tenant", "window"}, nil,
+		),
+		// the limits are collected with the used bytes, so that they are forgotten with the tenants.
+		limitBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_budget_limit_bytes",
+			Help:      "Query bytes budget of the tenant over the rolling window.",
+		}, []string{"tenant", "window"}),
+		rejected: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_budget_rejected_queries_total",
+			Help:      "Total number of queries rejected because they would exceed the query bytes budget of the tenant.",
+		}, []string{"tenant", "window"}),
+		admittedBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_budget_admitted_bytes_total",
+			Help:      "Total number of bytes of the queries admitted against the query bytes budget of the tenant.",
+		}, []string{"tenant"}),
+		refundedBytes: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "query_frontend_query_budget_refunded_bytes_total",
+			Help:      "Total number of bytes refunded to the query bytes budget of the tenant because the queries failed or were partly served by the results cache.",
+		}, []string{"tenant"}),
+	}
+	if registerer != nil {
+		registerer.MustRegister(b)
+	}
+	return b
+}
+
+// Describe implements prometheus.Collector.
+func (b *QueryBudgets) Describe(ch chan<- *prometheus.Desc) {
+	ch <- b.usedBytesDesc
+	b.limitBytes.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (b *QueryBudgets) Collect(ch chan<- prometheus.Metric) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := b.now()
+	for tenantID, windows := range b.tenants {
+		var used uint64
+		for i, w := range windows {
+			total := w.total(now)
+			used += total
+			ch <- prometheus.MustNewConstMetric(b.usedBytesDesc, prometheus.GaugeValue, float64(total), tenantID, budgetWindows[i].name)
+		}
+		// forget the tenants that didn't query anything for a whole window.
+		if used == 0 {
+			delete(b.tenants, tenantID)
+			for _, w := range budgetWindows {
+				b.limitBytes.DeleteLabelValues(tenantID, w.name)
+			}
+		}
+	}
+	b.limitBytes.Collect(ch)
+}
+
+// budgetExceeded describes the budget a query would exceed.
+type budgetExceeded struct {
+	tenantID  string
+	window    budgetWindow
+	remaining uint64
+	limit     uint64
+}
+
+// admit charges bytes to the budgets of the given tenants at the returned time, unless it would exceed one of them.
+// In that case nothing is charged and the exceeded budget is returned.
+func (b *QueryBudgets) admit(ctx context.Context, limits Limits, tenantIDs []string, bytes uint64) (time.Time, *budgetExceeded) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := b.now()
+	for _, tenantID := range tenantIDs {
+		windows := b.windows(tenantID)
+		for i, w := range budgetWindows {
+			limit := w.limit(limits)(ctx, tenantID)
+			if limit <= 0 {
+				continue
+			}
+			b.limitBytes.WithLabelValues(tenantID, w.name).Set(float64(limit))
+
+			used := windows[i].total(now)
+			if used+bytes > uint64(limit) {
+				b.rejected.WithLabelValues(tenantID, w.name).Inc()
+				var remaining uint64
+				if used < uint64(limit) {
+					remaining = uint64(limit) - used
+				}
+				return now, &budgetExceeded{tenantID: tenantID, window: w, remaining: remaining, limit: uint64(limit)}
+			}
+		}
+	}
+
+	for _, tenantID := range tenantIDs {
+		for _, w := range b.windows(tenantID) {
+			w.add(now, bytes)
+		}
+		b.admittedBytes.WithLabelValues(tenantID).Add(float64(bytes))
+	}
+	return now, nil
+}
+
+// refund gives back to the budgets of the given tenants the bytes charged at the given time.
+func (b *QueryBudgets) refund(tenantIDs []string, bytes uint64, chargedAt time.Time) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := b.now()
+	for _, tenantID := range tenantIDs {
+		for _, w := range b.windows(tenantID) {
+			w.sub(now, chargedAt, bytes)
+		}
+		b.refundedBytes.WithLabelValues(tenantID).Add(float64(bytes))
+	}
+}
+
+// hasBudget tells if any of the tenants has a query budget.
+func hasBudget(ctx context.Context, limits Limits, tenantIDs []string) bool {
+	for _, tenantID := range tenantIDs {
+		for _, w := range budgetWindows {
+			if w.limit(limits)(ctx, tenantID) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b *QueryBudgets) windows(tenantID string) []*rollingBytes {
+	windows, ok := b.tenants[tenantID]
+	if !ok {
+		windows = make([]*rollingBytes, 0, len(budgetWindows))
+		for _, w := range budgetWindows {
+			windows = append(windows, newRollingBytes(w.duration))
+		}
+		b.tenants[tenantID] = windows
+	}
+	return windows
+}
+
+const queryBytesEstimateKey ctxKeyType = "query_bytes_estimate"
+
+// queryBytesEstimate holds the bytes a query would read, as estimated from the index stats before splitting it.
+type queryBytesEstimate struct {
+	bytes  uint64
+	length time.Duration
+}
+
+func withQueryBytesEstimate(ctx context.Context, r queryrangebase.Request, bytes uint64) context.Context {
+	return context.WithValue(ctx, queryBytesEstimateKey, queryBytesEstimate{bytes: bytes, length: requestLength(r)})
+}
+
+func queryBytesEstimateFromContext(ctx context.Context) (queryBytesEstimate, bool) {
+	e, ok := ctx.Value(queryBytesEstimateKey).(queryBytesEstimate)
+	return e, ok
+}
+
+// share returns the part of the estimated bytes read by r, a split of the estimated query.
+func (e queryBytesEstimate) share(r queryrangebase.Request) uint64 {
+	length := requestLength(r)
+	if e.length <= 0 || length >= e.length {
+		return e.bytes
+	}
+	return uint64(float64(e.bytes) * float64(length) / float64(e.length))
+}
+
+// requestLength returns the time range of the logs read by a request: the time range of range requests,
+// or the longest range vector of instant requests, whose splits have shorter range vectors.
+func requestLength(r queryrangebase.Request) time.Duration {
+	if length := r.GetEnd().Sub(r.GetStart()); length > 0 {
+		return length
+	}
+	maxRVDuration, _, err := maxRangeVectorAndOffsetDurationFromQueryString(r.GetQuery())
+	if err != nil {
+		return 0
+	}
+	return maxRVDuration
+}
+
+const queryBudgetReservationKey ctxKeyType = "query_budget_reservation"
+
+// queryBudgetReservation holds the bytes of a query reserved in the budgets of its tenants before it is split.
+type queryBudgetReservation struct {
+	budgets   *QueryBudgets
+	tenantIDs []string
+	estimate  queryBytesEstimate
+	at        time.Time
+	used      atomic.Uint64 // estimated bytes of the splits which weren't cached
+}
+
+func queryBudgetReservationFromContext(ctx context.Context) (*queryBudgetReservation, bool) {
+	r, ok := ctx.Value(queryBudgetReservationKey).(*queryBudgetReservation)
+	return r, ok
+}
+
+// settle gives back the reserved bytes which weren't used by the query, or all of them if the query failed.
+func (r *queryBudgetReservation) settle(err error) {
+	unused := r.estimate.bytes
+	if err == nil {
+		unused -= min(unused, r.used.Load())
+	}
+	if unused > 0 {
+		r.budgets.refund(r.tenantIDs, unused, r.at)
+	}
+}
+
+type queryBudgetLimiter struct {
+	*querySizeLimiter
+	limits  Limits
+	budgets *QueryBudgets
+}
+
+// NewQueryBudgetMiddleware creates a new Middleware enforcing the rolling query bytes budgets of tenants.
+// The bytes a query would read are estimated from the index stats like for the query size limits, reusing
+// the estimate of the query size limiter when there is one, and reserved in the budgets before the query is split,
+// so that a query is either rejected as a whole or runs to completion. Once the query is done, the reserved bytes
+// of the splits served by the results cache are given back, as recorded by the middleware created by
+// NewQueryBudgetUsageMiddleware, or all of them if the query failed.
+func NewQueryBudgetMiddleware(
+	cfg []config.PeriodConfig,
+	engineOpts logql.EngineOpts,
+	logger log.Logger,
+	limits Limits,
+	budgets *QueryBudgets,
+	statsHandler ...queryrangebase.Handler,
+) queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return &queryBudgetLimiter{
+			querySizeLimiter: newQuerySizeLimiter(next, cfg, engineOpts, logger, nil, "", statsHandler...),
+			limits:           limits,
+			budgets:          budgets,
+		}
+	})
+}
+
+func (q *queryBudgetLimiter) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	log := spanlogger.FromContext(ctx)
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+	}
+	if !hasBudget(ctx, q.limits, tenantIDs) {
+		return q.next.Do(ctx, r)
+	}
+	if _, ok := queryBudgetReservationFromContext(ctx); ok {
+		return q.next.Do(ctx, r)
+	}
+
+	estimate, ok := queryBytesEstimateFromContext(ctx)
+	if !ok {
+		// Only support TSDB
+		schemaCfg, err := q.getSchemaCfg(r)
+		if err != nil {
+			level.Warn(log).Log("msg", "failed to get schema config, not applying query budget", "err", err)
+			return q.next.Do(ctx, r)
+		}
+		if schemaCfg.IndexType != types.TSDBType {
+			return q.next.Do(ctx, r)
+		}
+
+		bytesRead, err := q.getBytesReadForRequest(ctx, r)
+		if err != nil {
+			return nil, httpgrpc.Errorf(http.StatusInternalServerError, "Failed to get bytes read stats for query: %s", err.Error())
+		}
+		ctx = withQueryBytesEstimate(ctx, r, bytesRead)
+		estimate, _ = queryBytesEstimateFromContext(ctx)
+	}
+
+	chargedAt, exceeded := q.budgets.admit(ctx, q.limits, tenantIDs, estimate.bytes)
+	if exceeded != nil {
+		statsBytesStr := humanize.IBytes(estimate.bytes)
+		remainingStr := humanize.IBytes(exceeded.remaining)
+		limitStr := humanize.IBytes(exceeded.limit)
+		level.Warn(log).Log("msg", "Query exceeds budget", "status", "rejected", "tenant", exceeded.tenantID, "window", exceeded.window.name, "budget_bytes", limitStr, "remaining_bytes", remainingStr, "resolved_bytes", statsBytesStr)
+		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, limErrQueryBudgetExceededTmpl, exceeded.tenantID, exceeded.window.name, statsBytesStr, remainingStr, limitStr)
+	}
+
+	reservation := &queryBudgetReservation{budgets: q.budgets, tenantIDs: tenantIDs, estimate: estimate, at: chargedAt}
+	resp, err := q.next.Do(context.WithValue(ctx, queryBudgetReservationKey, reservation), r)
+	if settlement, ok := ctx.Value(queryBudgetSettlementKey).(*queryBudgetSettlement); ok && err == nil {
+		// the query still runs after it was admitted, so it is settled by the caller once it is done.
+		settlement.reservation = reservation
+		return resp, nil
+	}
+	reservation.settle(err)
+	return resp, err
+}
+
+const queryBudgetSettlementKey ctxKeyType = "query_budget_settlement"
+
+// queryBudgetSettlement settles the query budget reservation of an admitted query which runs after being admitted,
+// like the streamed queries which are proxied to the queriers.
+type queryBudgetSettlement struct {
+	reservation *queryBudgetReservation
+}
+
+func withQueryBudgetSettlement(ctx context.Context, s *queryBudgetSettlement) context.Context {
+	return context.WithValue(ctx, queryBudgetSettlementKey, s)
+}
+
+// settle settles the reservation of the query, if it was charged.
+func (s *queryBudgetSettlement) settle(err error) {
+	if s.reservation != nil {
+		s.reservation.settle(err)
+	}
+}
+
+type queryBudgetUsage struct {
+	next queryrangebase.Handler
+}
+
+// NewQueryBudgetUsageMiddleware creates a new Middleware recording the bytes used by the splits of a query
+// in the query budget reservation made by the middleware created by NewQueryBudgetMiddleware.
+// It goes after the splitting and the results caches, so that only the splits which aren't cached use their share
+// of the estimated bytes of the query.
+func NewQueryBudgetUsageMiddleware() queryrangebase.Middleware {
+	return queryrangebase.MiddlewareFunc(func(next queryrangebase.Handler) queryrangebase.Handler {
+		return &queryBudgetUsage{next: next}
+	})
+}
+
+func (q *queryBudgetUsage) Do(ctx context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+	if reservation, ok := queryBudgetReservationFromContext(ctx); ok {
+		reservation.used.Add(reservation.estimate.share(r))
+	}
+	return q.next.Do(ctx, r)
+}
@@ -0,0 +1,398 @@
+package queryrange
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+func Test_rollingBytes(t *testing.T) {
+	now := time.Unix(0, 0)
+	r := newRollingBytes(time.Hour)
+
+	r.add(now, 10)
+	r.add(now.Add(30*time.Minute), 20)
+	require.Equal(t, uint64(30), r.total(now.Add(59*time.Minute)))
+	// the first bucket left the window.
+	require.Equal(t, uint64(20), r.total(now.Add(time.Hour)))
+	require.Equal(t, uint64(20), r.total(now.Add(89*time.Minute)))
+	require.Equal(t, uint64(0), r.total(now.Add(90*time.Minute)))
+
+	r.add(now.Add(100*time.Minute), 5)
+	require.Equal(t, uint64(0), r.total(now.Add(10*time.Hour)))
+}
+
+func Test_QueryBudget(t *testing.T) {
+	const statsBytes = 1000
+
+	limits := fakeLimits{
+		queryBytesBudgetPerHour: 2500,
+		queryBytesBudgetPerDay:  3000,
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	budgets := NewQueryBudgets(reg, constants.Loki)
+	now := testTime
+	budgets.now = func() time.Time { return now }
+
+	statsHits, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: statsBytes})
+	queries := 0
+	handler := base.MergeMiddlewares(
+		NewQueryBudgetMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, budgets, statsHandler),
+		NewQueryBudgetUsageMiddleware(),
+	).Wrap(
+		base.HandlerFunc(func(_ context.Context, _ base.Request) (base.Response, error) {
+			queries++
+			return &LokiResponse{}, nil
+		}),
+	)
+
+	query := `{app="foo"} |= "foo"`
+	req := &LokiRequest{
+		Query:     query,
+		Limit:     1000,
+		StartTs:   testTime.Add(-time.Hour),
+		EndTs:     testTime,
+		Direction: logproto.FORWARD,
+		Path:      "/query_range",
+		Plan: &plan.QueryPlan{
+			AST: syntax.MustParseExpr(query),
+		},
+	}
+	ctx := user.InjectOrgID(context.Background(), "foo")
+
+	// two queries fit in the hourly budget.
+	for i := 0; i < 2; i++ {
+		_, err := handler.Do(ctx, req)
+		require.NoError(t, err)
+	}
+
+	_, err := handler.Do(ctx, req)
+	require.Error(t, err)
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	require.Equal(t, int32(http.StatusTooManyRequests), resp.Code)
+	require.Contains(t, string(resp.Body), "for the last hour")
+	require.Contains(t, string(resp.Body), "remaining: 500 B")
+	require.Equal(t, 2, queries)
+	require.Equal(t, 3, *statsHits)
+
+	// the bytes read an hour ago left the hourly window, but not the daily one.
+	now = now.Add(time.Hour)
+	_, err = handler.Do(ctx, req)
+	require.NoError(t, err)
+	_, err = handler.Do(ctx, req)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "for the last day")
+	require.Equal(t, 3, queries)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP loki_query_frontend_query_budget_used_bytes Bytes of the query budget used by the tenant over the rolling window.
+# TYPE loki_query_frontend_query_budget_used_bytes gauge
+loki_query_frontend_query_budget_used_bytes{tenant="foo",window="day"} 3000
+loki_query_frontend_query_budget_used_bytes{tenant="foo",window="hour"} 1000
+# HELP loki_query_frontend_query_budget_rejected_queries_total Total number of queries rejected because they would exceed the query bytes budget of the tenant.
+# TYPE loki_query_frontend_query_budget_rejected_queries_total counter
+loki_query_frontend_query_budget_rejected_queries_total{tenant="foo",window="day"} 1
+loki_query_frontend_query_budget_rejected_queries_total{tenant="foo",window="hour"} 1
+`), "loki_query_frontend_query_budget_used_bytes", "loki_query_frontend_query_budget_rejected_queries_total"))
+
+	// other tenants have their own budget.
+	_, err = handler.Do(user.InjectOrgID(context.Background(), "bar"), req)
+	require.NoError(t, err)
+}
+
+func Test_QueryBudget_Disabled(t *testing.T) {
+	budgets := NewQueryBudgets(nil, constants.Loki)
+	statsHits, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 1000})
+	handler := base.MergeMiddlewares(
+		NewQueryBudgetMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, fakeLimits{}, budgets, statsHandler),
+		NewQueryBudgetUsageMiddleware(),
+	).Wrap(
+		base.HandlerFunc(func(_ context.Context, _ base.Request) (base.Response, error) {
+			return &LokiResponse{}, nil
+		}),
+	)
+
+	_, err := handler.Do(user.InjectOrgID(context.Background(), "foo"), &LokiRequest{
+		Query:   `{app="foo"} |= "foo"`,
+		StartTs: testTime.Add(-time.Hour),
+		EndTs:   testTime,
+	})
+	require.NoError(t, err)
+	// no index stats request is made when the tenant has no budget.
+	require.Equal(t, 0, *statsHits)
+}
+
+func Test_QueryBudget_RefundsFailedQueries(t *testing.T) {
+	limits := fakeLimits{queryBytesBudgetPerHour: 1500}
+	budgets := NewQueryBudgets(nil, constants.Loki)
+	now := testTime
+	budgets.now = func() time.Time { return now }
+
+	_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 1000})
+	var queryErr error
+	handler := base.MergeMiddlewares(
+		NewQueryBudgetMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, budgets, statsHandler),
+		NewQueryBudgetUsageMiddleware(),
+	).Wrap(
+		base.HandlerFunc(func(_ context.Context, _ base.Request) (base.Response, error) {
+			return &LokiResponse{}, queryErr
+		}),
+	)
+	req := &LokiRequest{
+		Query:   `{app="foo"} |= "foo"`,
+		StartTs: testTime.Add(-time.Hour),
+		EndTs:   testTime,
+	}
+	ctx := user.InjectOrgID(context.Background(), "foo")
+
+	// the bytes of the failed queries are given back to the budget.
+	queryErr = errors.New("querier error")
+	for i := 0; i < 3; i++ {
+		_, err := handler.Do(ctx, req)
+		require.ErrorIs(t, err, queryErr)
+		now = now.Add(time.Minute)
+	}
+
+	queryErr = nil
+	_, err := handler.Do(ctx, req)
+	require.NoError(t, err)
+	_, err = handler.Do(ctx, req)
+	require.ErrorContains(t, err, "the query would exceed the query bytes budget")
+}
+
+func Test_QueryBudget_ReservesWholeQuery(t *testing.T) {
+	limits := fakeLimits{queryBytesBudgetPerHour: 1500}
+	reg := prometheus.NewPedanticRegistry()
+	budgets := NewQueryBudgets(reg, constants.Loki)
+	now := testTime
+	budgets.now = func() time.Time { return now }
+
+	_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 1000})
+	splits := []base.Request{
+		&LokiRequest{Query: `{app="foo"} |= "foo"`, StartTs: testTime.Add(-time.Hour), EndTs: testTime.Add(-30 * time.Minute)},
+		&LokiRequest{Query: `{app="foo"} |= "foo"`, StartTs: testTime.Add(-30 * time.Minute), EndTs: testTime},
+	}
+	var queries int
+	var splitErr error
+	usage := NewQueryBudgetUsageMiddleware().Wrap(
+		base.HandlerFunc(func(_ context.Context, _ base.Request) (base.Response, error) {
+			queries++
+			return &LokiResponse{}, nil
+		}),
+	)
+	handler := NewQueryBudgetMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, budgets, statsHandler).Wrap(
+		base.HandlerFunc(func(ctx context.Context, _ base.Request) (base.Response, error) {
+			for _, split := range splits {
+				if _, err := usage.Do(ctx, split); err != nil {
+					return nil, err
+				}
+			}
+			return &LokiResponse{}, splitErr
+		}),
+	)
+	req := &LokiRequest{
+		Query:   `{app="foo"} |= "foo"`,
+		StartTs: testTime.Add(-time.Hour),
+		EndTs:   testTime,
+	}
+	ctx := user.InjectOrgID(context.Background(), "foo")
+
+	// the bytes of all the splits of a failed query are given back.
+	splitErr = errors.New("querier error")
+	_, err := handler.Do(ctx, req)
+	require.ErrorIs(t, err, splitErr)
+	require.Equal(t, uint64(0), budgets.windows("foo")[0].total(now))
+	require.Equal(t, 2, queries)
+
+	splitErr = nil
+	_, err = handler.Do(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000), budgets.windows("foo")[0].total(now))
+
+	// the next query is rejected before any of its splits run.
+	_, err = handler.Do(ctx, req)
+	require.ErrorContains(t, err, "the query would exceed the query bytes budget")
+	require.Equal(t, 4, queries)
+
+	// the limit of the tenants which are forgotten isn't reported anymore.
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP loki_query_frontend_query_budget_limit_bytes Query bytes budget of the tenant over the rolling window.
+# TYPE loki_query_frontend_query_budget_limit_bytes gauge
+loki_query_frontend_query_budget_limit_bytes{tenant="foo",window="hour"} 1500
+`), "loki_query_frontend_query_budget_limit_bytes"))
+	now = now.Add(25 * time.Hour)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(``), "loki_query_frontend_query_budget_limit_bytes"))
+}
+
+func Test_QueryBudget_ChargesSplits(t *testing.T) {
+	limits := fakeLimits{queryBytesBudgetPerHour: 10000}
+	budgets := NewQueryBudgets(nil, constants.Loki)
+	budgets.now = func() time.Time { return testTime }
+
+	for _, tc := range []struct {
+		name   string
+		req    base.Request
+		splits []base.Request
+	}{
+		{
+			name: "range query",
+			req: &LokiRequest{
+				Query:   `{app="foo"} |= "foo"`,
+				StartTs: testTime.Add(-4 * time.Hour),
+				EndTs:   testTime,
+			},
+			splits: []base.Request{
+				&LokiRequest{Query: `{app="foo"} |= "foo"`, StartTs: testTime.Add(-4 * time.Hour), EndTs: testTime.Add(-3 * time.Hour)},
+				&LokiRequest{Query: `{app="foo"} |= "foo"`, StartTs: testTime.Add(-3 * time.Hour), EndTs: testTime.Add(-time.Hour)},
+			},
+		},
+		{
+			name: "instant query",
+			req: &LokiInstantRequest{
+				Query:  `sum(count_over_time({app="foo"}[4h]))`,
+				TimeTs: testTime,
+			},
+			splits: []base.Request{
+				&LokiInstantRequest{Query: `sum(count_over_time({app="foo"}[1h] offset 3h))`, TimeTs: testTime},
+				&LokiInstantRequest{Query: `sum(count_over_time({app="foo"}[2h] offset 1h))`, TimeTs: testTime},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tenantID := strings.ReplaceAll(tc.name, " ", "_")
+			ctx := user.InjectOrgID(context.Background(), tenantID)
+			_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 4000})
+			usage := NewQueryBudgetUsageMiddleware().Wrap(
+				base.HandlerFunc(func(_ context.Context, _ base.Request) (base.Response, error) {
+					return &LokiResponse{}, nil
+				}),
+			)
+			// the other splits of the query were served by the results cache.
+			handler := NewQueryBudgetMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, budgets, statsHandler).Wrap(
+				base.HandlerFunc(func(ctx context.Context, _ base.Request) (base.Response, error) {
+					for _, split := range tc.splits {
+						if _, err := usage.Do(ctx, split); err != nil {
+							return nil, err
+						}
+					}
+					return &LokiResponse{}, nil
+				}),
+			)
+
+			_, err := handler.Do(ctx, tc.req)
+			require.NoError(t, err)
+			// only the 3 hours of the uncached splits are charged.
+			require.Equal(t, uint64(3000), budgets.windows(tenantID)[0].total(testTime))
+		})
+	}
+}
+
+func Test_QueryBudget_ReusesQuerySizeEstimate(t *testing.T) {
+	limits := fakeLimits{
+		maxQueryBytesRead:       10000,
+		queryBytesBudgetPerHour: 10000,
+	}
+	budgets := NewQueryBudgets(nil, constants.Loki)
+
+	statsHits, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 1000})
+	handler := base.MergeMiddlewares(
+		NewQuerySizeLimiterMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, statsHandler),
+		NewQueryBudgetMiddleware(testSchemasTSDB, testEngineOpts, util_log.Logger, limits, budgets, statsHandler),
+		NewQueryBudgetUsageMiddleware(),
+	).Wrap(
+		base.HandlerFunc(func(_ context.Context, _ base.Request) (base.Response, error) {
+			return &LokiResponse{}, nil
+		}),
+	)
+
+	_, err := handler.Do(user.InjectOrgID(context.Background(), "foo"), &LokiRequest{
+		Query:   `{app="foo"} |= "foo"`,
+		StartTs: testTime.Add(-time.Hour),
+		EndTs:   testTime,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, *statsHits)
+	require.Equal(t, uint64(1000), budgets.windows("foo")[0].total(budgets.now()))
+}
+
+func Test_QueryBudget_CachedQueriesAreNotCharged(t *testing.T) {
+	l := fakeLimits{
+		maxSeries:               math.MaxInt32,
+		maxQueryParallelism:     1,
+		tsdbMaxQueryParallelism: 1,
+		queryTimeout:            time.Minute,
+		queryBytesBudgetPerHour: 300,
+		splitDuration: map[string]time.Duration{
+			"1": 4 * time.Hour,
+		},
+	}
+	tpw, stopper, err := NewMiddleware(testConfig, testEngineOpts, nil, util_log.Logger, l, config.SchemaConfig{Configs: testSchemasTSDB}, nil, false, nil, constants.Loki)
+	if stopper != nil {
+		defer stopper.Stop()
+	}
+	require.NoError(t, err)
+
+	query := `rate({app="foo"} |= "foo"[1m])`
+	req := &LokiRequest{
+		Query:     query,
+		Limit:     1000,
+		Step:      30000, // 30sec
+		StartTs:   testTime.Add(-6 * time.Hour),
+		EndTs:     testTime,
+		Direction: logproto.FORWARD,
+		Path:      "/query_range",
+		Plan: &plan.QueryPlan{
+			AST: syntax.MustParseExpr(query),
+		},
+	}
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 100})
+	count, queryHandler := promqlResult(matrix)
+	_, err = tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, 2, *count)
+
+	// the query is served by the results cache, so the bytes reserved for it are given back.
+	count, queryHandler = counter()
+	_, err = tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, 0, *count)
+
+	// only the hour which isn't cached is charged.
+	req.StartTs, req.EndTs = req.StartTs.Add(time.Hour), req.EndTs.Add(time.Hour)
+	_, queryHandler = promqlResult(matrix)
+	_, err = tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, req)
+	require.NoError(t, err)
+
+	// the queries which aren't cached use the remaining budget.
+	query = `rate({app="bar"} |= "foo"[1m])`
+	req.Query, req.Plan = query, &plan.QueryPlan{AST: syntax.MustParseExpr(query)}
+	_, err = tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, req)
+	require.NoError(t, err)
+
+	query = `rate({app="baz"} |= "foo"[1m])`
+	req.Query, req.Plan = query, &plan.QueryPlan{AST: syntax.MustParseExpr(query)}
+	_, err = tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, req)
+	require.ErrorContains(t, err, "the query would exceed the query bytes budget")
+}
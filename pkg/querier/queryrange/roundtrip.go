@@ -21,6 +21,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/logql"
 	logqllog "github.com/grafana/loki/v3/pkg/logql/log"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
 	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
 	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
@@ -140,6 +141,7 @@ func NewMiddleware(
 	metricsNamespace string,
 ) (base.Middleware, Stopper, error) {
 	metrics := NewMetrics(registerer, metricsNamespace)
+	budgets := NewQueryBudgets(registerer, metricsNamespace)
 
 	var (
 		resultsCache       cache.Cache
@@ -202,7 +204,7 @@ func NewMiddleware(
 	}
 
 	metricsTripperware, err := NewMetricTripperware(cfg, engineOpts, log, limits, schema, codec, iqo, resultsCache,
-		cacheGenNumLoader, retentionEnabled, PrometheusExtractor{}, metrics, indexStatsTripperware, metricsNamespace)
+		cacheGenNumLoader, retentionEnabled, PrometheusExtractor{}, metrics, budgets, indexStatsTripperware, metricsNamespace)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -214,7 +216,12 @@ func NewMiddleware(
 
 	// NOTE: When we would start caching response from non-metric queries we would have to consider cache gen headers as well in
 	// MergeResponse implementation for Loki codecs same as it is done in Cortex at https://github.com/cortexproject/cortex/blob/21bad57b346c730d684d6d0205efef133422ab28/pkg/querier/queryrange/query_range.go#L170
-	logFilterTripperware, err := NewLogFilterTripperware(cfg, engineOpts, log, limits, schema, codec, iqo, resultsCache, metrics, indexStatsTripperware, metricsNamespace)
+	logFilterTripperware, err := NewLogFilterTripperware(cfg, engineOpts, log, limits, schema, codec, iqo, resultsCache, metrics, budgets, indexStatsTripperware, metricsNamespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	streamTripperware, err := NewStreamQueryTripperware(engineOpts, log, limits, schema, budgets, indexStatsTripperware)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -229,7 +236,7 @@ func NewMiddleware(
 		return nil, nil, err
 	}
 
-	instantMetricTripperware, err := NewInstantMetricTripperware(cfg, engineOpts, log, limits, schema, metrics, codec, instantMetricCache, cacheGenNumLoader, retentionEnabled, indexStatsTripperware, metricsNamespace)
+	instantMetricTripperware, err := NewInstantMetricTripperware(cfg, engineOpts, log, limits, schema, metrics, budgets, codec, instantMetricCache, cacheGenNumLoader, retentionEnabled, indexStatsTripperware, metricsNamespace)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -268,6 +275,7 @@ func NewMiddleware(
 			metricRT         = metricsTripperware.Wrap(next)
 			limitedRT        = limitedTripperware.Wrap(next)
 			logFilterRT      = logFilterTripperware.Wrap(next)
+			streamRT         = streamTripperware.Wrap(next)
 			seriesRT         = seriesTripperware.Wrap(next)
 			labelsRT         = labelsTripperware.Wrap(next)
 			instantRT        = instantMetricTripperware.Wrap(next)
@@ -277,7 +285,7 @@ func NewMiddleware(
 			detectedLabelsRT = detectedLabelsTripperware.Wrap(next)
 		)
 
-		return newRoundTripper(log, next, limitedRT, logFilterRT, metricRT, seriesRT, labelsRT, instantRT, statsRT, seriesVolumeRT, detectedFieldsRT, detectedLabelsRT, limits)
+		return newRoundTripper(log, next, limitedRT, logFilterRT, streamRT, metricRT, seriesRT, labelsRT, instantRT, statsRT, seriesVolumeRT, detectedFieldsRT, detectedLabelsRT, limits)
 	}), StopperWrapper{resultsCache, statsCache, volumeCache}, nil
 }
 
@@ -331,17 +339,18 @@ func NewDetectedLabelsCardinalityFilter(rt queryrangebase.Handler) queryrangebas
 type roundTripper struct {
 	logger log.Logger
 
-	next, limited, log, metric, series, labels, instantMetric, indexStats, seriesVolume, detectedFields, detectedLabels base.Handler
+	next, limited, log, stream, metric, series, labels, instantMetric, indexStats, seriesVolume, detectedFields, detectedLabels base.Handler
 
 	limits Limits
 }
 
 // newRoundTripper creates a new queryrange roundtripper
-func newRoundTripper(logger log.Logger, next, limited, log, metric, series, labels, instantMetric, indexStats, seriesVolume, detectedFields, detectedLabels base.Handler, limits Limits) roundTripper {
+func newRoundTripper(logger log.Logger, next, limited, log, stream, metric, series, labels, instantMetric, indexStats, seriesVolume, detectedFields, detectedLabels base.Handler, limits Limits) roundTripper {
 	return roundTripper{
 		logger:         logger,
 		limited:        limited,
 		log:            log,
+		stream:         stream,
 		limits:         limits,
 		metric:         metric,
 		series:         series,
@@ -378,6 +387,20 @@ func (r roundTripper) Do(ctx context.Context, req base.Request) (base.Response,
 			return nil, errors.New("query plan is empty")
 		}
 
+		if isStreamQuery(op.Path) {
+			e, ok := op.Plan.AST.(syntax.LogSelectorExpr)
+			if !ok {
+				return nil, logqlmodel.ErrUnsupportedSyntaxForStreaming
+			}
+			if err := validateMaxStreamedEntriesLimits(ctx, op.Limit, r.limits); err != nil {
+				return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+			}
+			if err := validateMatchers(ctx, r.limits, e.Matchers()); err != nil {
+				return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
+			}
+			return r.stream.Do(ctx, req)
+		}
+
 		switch e := op.Plan.AST.(type) {
 		case syntax.SampleExpr:
 			// The error will be handled later.
@@ -537,7 +560,7 @@ func getOperation(path string) string {
 }
 
 // NewLogFilterTripperware creates a new frontend tripperware responsible for handling log requests.
-func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, merger base.Merger, iqo util.IngesterQueryOptions, c cache.Cache, metrics *Metrics, indexStatsTripperware base.Middleware, metricsNamespace string) (base.Middleware, error) {
+func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, merger base.Merger, iqo util.IngesterQueryOptions, c cache.Cache, metrics *Metrics, budgets *QueryBudgets, indexStatsTripperware base.Middleware, metricsNamespace string) (base.Middleware, error) {
 	return base.MiddlewareFunc(func(next base.Handler) base.Handler {
 		statsHandler := indexStatsTripperware.Wrap(next)
 		retryNextHandler := next
@@ -552,6 +575,7 @@ func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Lo
 			StatsCollectorMiddleware(),
 			NewLimitsMiddleware(limits),
 			NewQuerySizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
+			NewQueryBudgetMiddleware(schema.Configs, engineOpts, log, limits, budgets, statsHandler),
 			base.InstrumentMiddleware("split_by_interval", metrics.InstrumentMiddlewareMetrics),
 			SplitByIntervalMiddleware(schema.Configs, limits, merger, newDefaultSplitter(limits, iqo), metrics.SplitByMetrics),
 		}
@@ -574,6 +598,9 @@ func NewLogFilterTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Lo
 			)
 		}
 
+		// Only the splits which aren't cached use the bytes reserved in the query budgets.
+		queryRangeMiddleware = append(queryRangeMiddleware, NewQueryBudgetUsageMiddleware())
+
 		if cfg.ShardedQueries {
 			queryRangeMiddleware = append(queryRangeMiddleware,
 				NewQueryShardMiddleware(
@@ -818,7 +845,7 @@ func NewLabelsTripperware(
 }
 
 // NewMetricTripperware creates a new frontend tripperware responsible for handling metric queries
-func NewMetricTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, merger base.Merger, iqo util.IngesterQueryOptions, c cache.Cache, cacheGenNumLoader base.CacheGenNumberLoader, retentionEnabled bool, extractor base.Extractor, metrics *Metrics, indexStatsTripperware base.Middleware, metricsNamespace string) (base.Middleware, error) {
+func NewMetricTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, merger base.Merger, iqo util.IngesterQueryOptions, c cache.Cache, cacheGenNumLoader base.CacheGenNumberLoader, retentionEnabled bool, extractor base.Extractor, metrics *Metrics, budgets *QueryBudgets, indexStatsTripperware base.Middleware, metricsNamespace string) (base.Middleware, error) {
 	cacheKey := cacheKeyLimits{limits, cfg.Transformer, iqo}
 	var queryCacheMiddleware base.Middleware
 	if cfg.CacheResults {
@@ -879,6 +906,7 @@ func NewMetricTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logge
 		queryRangeMiddleware = append(
 			queryRangeMiddleware,
 			NewQuerySizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
+			NewQueryBudgetMiddleware(schema.Configs, engineOpts, log, limits, budgets, statsHandler),
 			base.InstrumentMiddleware("split_by_interval", metrics.InstrumentMiddlewareMetrics),
 			SplitByIntervalMiddleware(schema.Configs, limits, merger, newMetricQuerySplitter(limits, iqo), metrics.SplitByMetrics),
 		)
@@ -891,6 +919,9 @@ func NewMetricTripperware(cfg Config, engineOpts logql.EngineOpts, log log.Logge
 			)
 		}
 
+		// Only the splits which aren't cached use the bytes reserved in the query budgets.
+		queryRangeMiddleware = append(queryRangeMiddleware, NewQueryBudgetUsageMiddleware())
+
 		if cfg.ShardedQueries {
 			queryRangeMiddleware = append(queryRangeMiddleware,
 				NewQueryShardMiddleware(
@@ -944,6 +975,7 @@ func NewInstantMetricTripperware(
 	limits Limits,
 	schema config.SchemaConfig,
 	metrics *Metrics,
+	budgets *QueryBudgets,
 	merger base.Merger,
 	c cache.Cache,
 	cacheGenNumLoader base.CacheGenNumberLoader,
@@ -995,6 +1027,7 @@ func NewInstantMetricTripperware(
 			StatsCollectorMiddleware(),
 			NewLimitsMiddleware(limits),
 			NewQuerySizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
+			NewQueryBudgetMiddleware(schema.Configs, engineOpts, log, limits, budgets, statsHandler),
 			NewSplitByRangeMiddleware(log, engineOpts, limits, cfg.InstantMetricQuerySplitAlign, metrics.MiddlewareMapperMetrics.rangeMapper),
 		}
 
@@ -1006,6 +1039,9 @@ func NewInstantMetricTripperware(
 			)
 		}
 
+		// Only the splits which aren't cached use the bytes reserved in the query budgets.
+		queryRangeMiddleware = append(queryRangeMiddleware, NewQueryBudgetUsageMiddleware())
+
 		if cfg.ShardedQueries {
 			queryRangeMiddleware = append(queryRangeMiddleware,
 				NewQueryShardMiddleware(
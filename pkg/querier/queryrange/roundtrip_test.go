@@ -283,6 +283,102 @@ func TestMetricsTripperware(t *testing.T) {
 	require.Equal(t, lokiResponse.(*LokiPromResponse).Response, lokiCacheResponse.(*LokiPromResponse).Response)
 }
 
+func TestMetricsTripperware_HistogramOverTimeResultCaching(t *testing.T) {
+	bucket := func(le string, v float64) promql.Sample {
+		return promql.Sample{T: toMs(testTime.Add(-4 * time.Hour)), F: v, Metric: labels.FromStrings("job", "varlogs", "le", le)}
+	}
+	histogram := promql.Vector{bucket("0.5", 1), bucket("+Inf", 3)}
+
+	l := fakeLimits{
+		maxSeries:               math.MaxInt32,
+		maxQueryParallelism:     1,
+		tsdbMaxQueryParallelism: 1,
+		maxQueryBytesRead:       1000,
+		maxQuerierBytesRead:     100,
+		queryTimeout:            time.Minute,
+		splitDuration: map[string]time.Duration{
+			"1": 4 * time.Hour,
+		},
+		instantMetricSplitDuration: map[string]time.Duration{
+			"1": 5 * time.Minute,
+		},
+	}
+	cfg := testConfig
+	cfg.ShardedQueries = true
+	cfg.CacheInstantMetricResults = true
+	tpw, stopper, err := NewMiddleware(cfg, testEngineOpts, nil, util_log.Logger, l, config.SchemaConfig{
+		Configs: testSchemasTSDB,
+	}, nil, false, nil, constants.Loki)
+	if stopper != nil {
+		defer stopper.Stop()
+	}
+	require.NoError(t, err)
+	ctx := user.InjectOrgID(context.Background(), "1")
+	_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 10})
+
+	t.Run("range query", func(t *testing.T) {
+		q := `histogram_over_time(0.5, {app="foo"} | unwrap latency [1m]) by (job)`
+		lreq := &LokiRequest{
+			Query:     q,
+			Limit:     1000,
+			Step:      30000, // 30sec
+			StartTs:   testTime.Add(-6 * time.Hour),
+			EndTs:     testTime,
+			Direction: logproto.FORWARD,
+			Path:      "/query_range",
+			Plan: &plan.QueryPlan{
+				AST: syntax.MustParseExpr(q),
+			},
+		}
+
+		count, queryHandler := promqlResult(promql.Matrix{
+			{Metric: histogram[0].Metric, Floats: []promql.FPoint{{T: histogram[0].T, F: histogram[0].F}}},
+			{Metric: histogram[1].Metric, Floats: []promql.FPoint{{T: histogram[1].T, F: histogram[1].F}}},
+		})
+		lokiResponse, err := tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, lreq)
+		require.NoError(t, err)
+		require.Equal(t, 2, *count)
+
+		// the buckets are served by the results cache like any other series.
+		count, queryHandler = counter()
+		lokiCacheResponse, err := tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, lreq)
+		require.NoError(t, err)
+		require.Equal(t, 0, *count)
+		require.Equal(t, lokiResponse.(*LokiPromResponse).Response, lokiCacheResponse.(*LokiPromResponse).Response)
+	})
+
+	t.Run("instant query", func(t *testing.T) {
+		q := `histogram_over_time(0.5, {app="foo"} | unwrap latency [15m]) by (job)`
+		lreq := &LokiInstantRequest{
+			Query:     q,
+			Limit:     1000,
+			TimeTs:    testTime.Add(-4 * time.Hour),
+			Direction: logproto.FORWARD,
+			Path:      "/loki/api/v1/query",
+			Plan: &plan.QueryPlan{
+				AST: syntax.MustParseExpr(q),
+			},
+		}
+
+		count, queryHandler := promqlResult(histogram)
+		lokiResponse, err := tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, lreq)
+		require.NoError(t, err)
+		// split into 4 subqueries, whose bucket counts are summed.
+		require.Equal(t, 4, *count)
+		require.ElementsMatch(t, []logproto.LegacySample{
+			{TimestampMs: histogram[0].T, Value: 4},
+			{TimestampMs: histogram[1].T, Value: 12},
+		}, append(lokiResponse.(*LokiPromResponse).Response.Data.Result[0].Samples, lokiResponse.(*LokiPromResponse).Response.Data.Result[1].Samples...))
+
+		// the split bucket counts are served by the instant metric results cache.
+		count, queryHandler = counter()
+		lokiCacheResponse, err := tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, lreq)
+		require.NoError(t, err)
+		require.Equal(t, 0, *count)
+		assertInstantSampleValues(t, lokiResponse.(*LokiPromResponse), lokiCacheResponse.(*LokiPromResponse))
+	})
+}
+
 func TestLogFilterTripperware(t *testing.T) {
 	var l Limits = fakeLimits{
 		maxQueryParallelism:     1,
@@ -1005,6 +1101,7 @@ func TestPostQueries(t *testing.T) {
 		handler,
 		handler,
 		handler,
+		handler,
 		fakeLimits{},
 	).Do(ctx, lreq)
 	require.NoError(t, err)
@@ -1385,6 +1482,7 @@ type fakeLimits struct {
 	tsdbMaxQueryParallelism     int
 	maxQueryLookback            time.Duration
 	maxEntriesLimitPerQuery     int
+	maxStreamedEntriesLimit     int
 	maxSeries                   int
 	splitDuration               map[string]time.Duration
 	metadataSplitDuration       map[string]time.Duration
@@ -1398,6 +1496,8 @@ type fakeLimits struct {
 	requiredNumberLabels        int
 	maxQueryBytesRead           int
 	maxQuerierBytesRead         int
+	queryBytesBudgetPerHour     int
+	queryBytesBudgetPerDay      int
 	maxStatsCacheFreshness      time.Duration
 	maxMetadataCacheFreshness   time.Duration
 	volumeEnabled               bool
@@ -1464,6 +1564,10 @@ func (f fakeLimits) TSDBMaxQueryParallelism(context.Context, string) int {
 	return f.tsdbMaxQueryParallelism
 }
 
+func (f fakeLimits) MaxStreamedEntriesLimitPerQuery(context.Context, string) int {
+	return f.maxStreamedEntriesLimit
+}
+
 func (f fakeLimits) MaxEntriesLimitPerQuery(context.Context, string) int {
 	return f.maxEntriesLimitPerQuery
 }
@@ -1492,6 +1596,14 @@ func (f fakeLimits) MaxQuerierBytesRead(context.Context, string) int {
 	return f.maxQuerierBytesRead
 }
 
+func (f fakeLimits) QueryBytesBudgetPerHour(context.Context, string) int {
+	return f.queryBytesBudgetPerHour
+}
+
+func (f fakeLimits) QueryBytesBudgetPerDay(context.Context, string) int {
+	return f.queryBytesBudgetPerDay
+}
+
 func (f fakeLimits) QueryTimeout(context.Context, string) time.Duration {
 	return f.queryTimeout
 }
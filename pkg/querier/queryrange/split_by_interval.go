@@ -20,6 +20,7 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
 	"github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
 	"github.com/grafana/loki/v3/pkg/storage/config"
 	"github.com/grafana/loki/v3/pkg/util/validation"
@@ -182,6 +183,15 @@ func (h *splitByInterval) Do(ctx context.Context, r queryrangebase.Request) (que
 		return nil, httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error())
 	}
 
+	// start() and end() of the @ modifier refer to the time range of the
+	// original request and not to the one of each split.
+	if req, ok := r.(*LokiRequest); ok {
+		r, err = resolveAtModifiers(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var interval time.Duration
 	switch r.(type) {
 	case *LokiSeriesRequest, *LabelRequest:
@@ -254,6 +264,26 @@ func (h *splitByInterval) Do(ctx context.Context, r queryrangebase.Request) (que
 	return h.merger.MergeResponse(resps...)
 }
 
+// resolveAtModifiers returns a copy of the request in which the start() and
+// end() @ modifiers of its query are replaced with the start and end of the
+// request. The request is returned as is if there are none.
+func resolveAtModifiers(r *LokiRequest) (*LokiRequest, error) {
+	if r.Plan == nil {
+		return r, nil
+	}
+	resolved, err := syntax.ResolveAtModifiers(r.Plan.AST, r.StartTs, r.EndTs)
+	if err != nil {
+		return nil, err
+	}
+	if resolved == r.Plan.AST {
+		return r, nil
+	}
+	clone := *r
+	clone.Query = resolved.String()
+	clone.Plan = &plan.QueryPlan{AST: resolved}
+	return &clone, nil
+}
+
 // maxRangeVectorAndOffsetDurationFromQueryString
 func maxRangeVectorAndOffsetDurationFromQueryString(q string) (time.Duration, time.Duration, error) {
 	parsed, err := syntax.ParseExpr(q)
@@ -271,13 +301,24 @@ func maxRangeVectorAndOffsetDuration(expr syntax.Expr) (time.Duration, time.Dura
 
 	var maxRVDuration, maxOffset time.Duration
 	expr.Walk(func(e syntax.Expr) {
-		if r, ok := e.(*syntax.LogRange); ok {
+		switch r := e.(type) {
+		case *syntax.LogRange:
 			if r.Interval > maxRVDuration {
 				maxRVDuration = r.Interval
 			}
 			if r.Offset > maxOffset {
 				maxOffset = r.Offset
 			}
+		case *syntax.SubqueryExpr:
+			// the inner expression of a subquery is evaluated over the range
+			// of the subquery, in addition to its own range vectors.
+			innerRVDuration, innerOffset, _ := maxRangeVectorAndOffsetDuration(r.Left)
+			if r.Range+innerRVDuration > maxRVDuration {
+				maxRVDuration = r.Range + innerRVDuration
+			}
+			if r.Offset+innerOffset > maxOffset {
+				maxOffset = r.Offset + innerOffset
+			}
 		}
 	})
 	return maxRVDuration, maxOffset, nil
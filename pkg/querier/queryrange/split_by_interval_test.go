@@ -1548,6 +1548,86 @@ func Test_splitByInterval_Do(t *testing.T) {
 	}
 }
 
+func Test_splitByInterval_Do_AtModifier(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	var (
+		mtx  sync.Mutex
+		reqs []*LokiRequest
+	)
+	next := queryrangebase.HandlerFunc(func(_ context.Context, r queryrangebase.Request) (queryrangebase.Response, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		reqs = append(reqs, r.(*LokiRequest))
+
+		return &LokiPromResponse{
+			Response: &queryrangebase.PrometheusResponse{
+				Status: loghttp.QueryStatusSuccess,
+				Data: queryrangebase.PrometheusData{
+					ResultType: loghttp.ResultTypeMatrix,
+				},
+			},
+		}, nil
+	})
+
+	split := SplitByIntervalMiddleware(
+		testSchemas,
+		WithSplitByLimits(fakeLimits{maxQueryParallelism: 1}, time.Hour),
+		DefaultCodec,
+		newMetricQuerySplitter(fakeLimits{}, nil),
+		nilMetrics,
+	).Wrap(next)
+
+	query := `sum(rate({app="foo"}[1m] @ end())) / sum(rate({app="foo"}[1m] offset 1w @ start()))`
+	req := &LokiRequest{
+		StartTs:   time.Unix(0, 0),
+		EndTs:     time.Unix(0, (4 * time.Hour).Nanoseconds()),
+		Query:     query,
+		Step:      60 * 1e3,
+		Direction: logproto.FORWARD,
+		Path:      "/loki/api/v1/query_range",
+		Plan: &plan.QueryPlan{
+			AST: syntax.MustParseExpr(query),
+		},
+	}
+
+	_, err := split.Do(ctx, req)
+	require.NoError(t, err)
+
+	// start() and end() are resolved against the original request, not against each split.
+	expected := `(sum(rate({app="foo"}[1m] @ 14400.000)) / sum(rate({app="foo"}[1m] offset 168h0m0s @ 0.000)))`
+	require.Len(t, reqs, 4)
+	for _, r := range reqs {
+		require.Equal(t, expected, r.Query)
+		require.Equal(t, expected, r.Plan.AST.String())
+	}
+
+	// the original request is left untouched.
+	require.Equal(t, query, req.Query)
+	require.Equal(t, syntax.MustParseExpr(query).String(), req.Plan.AST.String())
+}
+
+func Test_maxRangeVectorAndOffsetDuration(t *testing.T) {
+	for _, tc := range []struct {
+		query          string
+		expectedRange  time.Duration
+		expectedOffset time.Duration
+	}{
+		{`rate({app="foo"}[5m])`, 5 * time.Minute, 0},
+		{`rate({app="foo"}[5m] offset 1h)`, 5 * time.Minute, time.Hour},
+		{`max_over_time(rate({app="foo"}[5m] offset 1h)[1h:5m])`, time.Hour + 5*time.Minute, time.Hour},
+		{`max_over_time(rate({app="foo"}[5m])[1h:5m] offset 1d) / rate({app="foo"}[2h])`, 2 * time.Hour, 24 * time.Hour},
+		{`max_over_time(max_over_time(rate({app="foo"}[5m])[1h:5m])[1d:1h])`, 25*time.Hour + 5*time.Minute, 0},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			rng, offset, err := maxRangeVectorAndOffsetDuration(syntax.MustParseExpr(tc.query))
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedRange, rng)
+			require.Equal(t, tc.expectedOffset, offset)
+		})
+	}
+}
+
 func Test_series_splitByInterval_Do(t *testing.T) {
 	ctx := user.InjectOrgID(context.Background(), "1")
 	next := queryrangebase.HandlerFunc(func(_ context.Context, _ queryrangebase.Request) (queryrangebase.Response, error) {
@@ -0,0 +1,151 @@
+package queryrange
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/middleware"
+
+	"github.com/grafana/loki/v3/pkg/logql"
+	base "github.com/grafana/loki/v3/pkg/querier/queryrange/queryrangebase"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	serverutil "github.com/grafana/loki/v3/pkg/util/server"
+)
+
+// isStreamQuery tells if the path is the one of the streamed log queries.
+func isStreamQuery(path string) bool {
+	return strings.HasSuffix(path, "/query_range/stream")
+}
+
+// NewStreamQueryTripperware creates a new frontend tripperware for the streamed log queries.
+// Streamed queries write their entries while the queriers read them, so they are proxied to the queriers
+// as they are instead of being split, sharded and cached. The tripperware only enforces the limits and
+// the query budgets of the queries, and returns an empty response for the admitted ones.
+func NewStreamQueryTripperware(engineOpts logql.EngineOpts, log log.Logger, limits Limits, schema config.SchemaConfig, budgets *QueryBudgets, indexStatsTripperware base.Middleware) (base.Middleware, error) {
+	return base.MiddlewareFunc(func(next base.Handler) base.Handler {
+		statsHandler := indexStatsTripperware.Wrap(next)
+		return base.MergeMiddlewares(
+			NewLimitsMiddleware(limits),
+			NewQuerySizeLimiterMiddleware(schema.Configs, engineOpts, log, limits, statsHandler),
+			NewQueryBudgetMiddleware(schema.Configs, engineOpts, log, limits, budgets, statsHandler),
+			NewQueryBudgetUsageMiddleware(),
+		).Wrap(base.HandlerFunc(func(_ context.Context, r base.Request) (base.Response, error) {
+			return NewEmptyResponse(r)
+		}))
+	}), nil
+}
+
+// NewStreamQueryHTTPMiddleware creates a middleware admitting the streamed log queries through the
+// query-frontend tripperware before passing them to the next handler, which proxies them to the queriers.
+// The bytes of the queries charged to the query budgets are refunded when the streams fail or are cancelled.
+func NewStreamQueryHTTPMiddleware(tripperware base.Handler, maxBodySize int64) middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the form is parsed from a copy of the request, so that the request is proxied with its body.
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			if err != nil {
+				status := http.StatusBadRequest
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				serverutil.WriteError(httpgrpc.Errorf(status, "%s", err.Error()), w)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			parsed := r.Clone(r.Context())
+			parsed.Body = io.NopCloser(bytes.NewReader(body))
+			if err := parsed.ParseForm(); err != nil {
+				serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+				return
+			}
+
+			req, err := parseRangeQuery(parsed)
+			if err != nil {
+				serverutil.WriteError(httpgrpc.Errorf(http.StatusBadRequest, "%s", err.Error()), w)
+				return
+			}
+			settlement := &queryBudgetSettlement{}
+			if _, err := tripperware.Do(withQueryBudgetSettlement(r.Context(), settlement), req); err != nil {
+				serverutil.WriteError(err, w)
+				return
+			}
+			sw := &streamResponseWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+			settlement.settle(sw.err(r.Context()))
+		})
+	})
+}
+
+// successLinePrefix is the beginning of the last line of the streamed queries which succeeded.
+var successLinePrefix = []byte(`{"status":"success"`)
+
+// streamResponseWriter records the outcome of a streamed query from its response: its status code,
+// and the status written on its last line once the status code was sent.
+type streamResponseWriter struct {
+	http.ResponseWriter
+	code     int
+	line     []byte // beginning of the current line
+	lastLine []byte // beginning of the last complete line
+}
+
+func (w *streamResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *streamResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	for rest := b; len(rest) > 0; {
+		i := bytes.IndexByte(rest, '\n')
+		part := rest
+		if i >= 0 {
+			part = rest[:i]
+		}
+		if n := len(successLinePrefix) - len(w.line); n > 0 {
+			w.line = append(w.line, part[:min(n, len(part))]...)
+		}
+		if i < 0 {
+			break
+		}
+		w.lastLine, w.line = w.line, w.lastLine[:0]
+		rest = rest[i+1:]
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *streamResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *streamResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// err returns the error of the streamed query, if it failed or was cancelled.
+func (w *streamResponseWriter) err(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if w.code >= http.StatusBadRequest {
+		return fmt.Errorf("streamed query failed with status %d", w.code)
+	}
+	// the status line can't be read from encoded responses.
+	if w.Header().Get("Content-Encoding") == "" && !bytes.HasPrefix(w.lastLine, successLinePrefix) {
+		return errors.New("streamed query failed")
+	}
+	return nil
+}
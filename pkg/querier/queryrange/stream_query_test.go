@@ -0,0 +1,235 @@
+package queryrange
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+	"github.com/grafana/loki/v3/pkg/querier/plan"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	util_log "github.com/grafana/loki/v3/pkg/util/log"
+)
+
+func TestStreamQueryTripperware(t *testing.T) {
+	l := fakeLimits{
+		maxQueryParallelism:     1,
+		tsdbMaxQueryParallelism: 1,
+		maxEntriesLimitPerQuery: 1000,
+		maxQueryLength:          2 * time.Hour,
+		requiredLabels:          []string{"app"},
+		maxQueryBytesRead:       1000,
+		queryBytesBudgetPerHour: 150,
+		queryTimeout:            time.Minute,
+	}
+	tpw, stopper, err := NewMiddleware(testConfig, testEngineOpts, nil, util_log.Logger, l, config.SchemaConfig{Configs: testSchemasTSDB}, nil, false, nil, constants.Loki)
+	if stopper != nil {
+		defer stopper.Stop()
+	}
+	require.NoError(t, err)
+	ctx := user.InjectOrgID(context.Background(), "1")
+
+	streamRequest := func(query string, length time.Duration) *LokiRequest {
+		return &LokiRequest{
+			Query:     query,
+			Limit:     100,
+			StartTs:   testTime.Add(-length),
+			EndTs:     testTime,
+			Direction: logproto.FORWARD,
+			Path:      "/loki/api/v1/query_range/stream",
+			Plan: &plan.QueryPlan{
+				AST: syntax.MustParseExpr(query),
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		req   *LokiRequest
+		bytes uint64
+		err   string
+	}{
+		{
+			name:  "admitted",
+			req:   streamRequest(`{app="foo"} |= "bar"`, time.Hour),
+			bytes: 100,
+		},
+		{
+			name: "metric query",
+			req:  streamRequest(`count_over_time({app="foo"}[1m])`, time.Hour),
+			err:  logqlmodel.ErrUnsupportedSyntaxForStreaming.Error(),
+		},
+		{
+			name: "missing required labels",
+			req:  streamRequest(`{job="foo"}`, time.Hour),
+			err:  "stream selector is missing required matchers [app]",
+		},
+		{
+			name: "max query length",
+			req:  streamRequest(`{app="foo"}`, 3*time.Hour),
+			err:  "the query time range exceeds the limit",
+		},
+		{
+			name:  "max query bytes read",
+			req:   streamRequest(`{app="bar"}`, time.Hour),
+			bytes: 2000,
+			err:   "the query would read too many bytes",
+		},
+		{
+			// the admitted query already used 100 bytes of the budget.
+			name:  "query budget",
+			req:   streamRequest(`{app="baz"}`, time.Hour),
+			bytes: 100,
+			err:   "the query would exceed the query bytes budget",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			count, queryHandler := counter()
+			_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: tc.bytes})
+			_, err := tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)).Do(ctx, tc.req)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+			// streamed queries are proxied to the queriers, never executed through the tripperware.
+			require.Equal(t, 0, *count)
+		})
+	}
+}
+
+func TestStreamQueryHTTPMiddleware(t *testing.T) {
+	l := fakeLimits{
+		maxQueryParallelism:     1,
+		tsdbMaxQueryParallelism: 1,
+		maxEntriesLimitPerQuery: 1000,
+		requiredLabels:          []string{"app"},
+		queryTimeout:            time.Minute,
+	}
+	tpw, stopper, err := NewMiddleware(testConfig, testEngineOpts, nil, util_log.Logger, l, config.SchemaConfig{Configs: testSchemasTSDB}, nil, false, nil, constants.Loki)
+	if stopper != nil {
+		defer stopper.Stop()
+	}
+	require.NoError(t, err)
+	_, queryHandler := counter()
+	_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 10})
+	mw := NewStreamQueryHTTPMiddleware(tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)), 1024)
+
+	for _, tc := range []struct {
+		name    string
+		query   string
+		proxied bool
+		status  int
+	}{
+		{
+			name:    "admitted",
+			query:   `{app="foo"}`,
+			proxied: true,
+			status:  http.StatusOK,
+		},
+		{
+			name:   "rejected",
+			query:  `{job="foo"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "body too large",
+			query:  `{app="foo"} |= "` + strings.Repeat("a", 1024) + `"`,
+			status: http.StatusRequestEntityTooLarge,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{
+				"query": []string{tc.query},
+				"start": []string{strconv.FormatInt(testTime.Add(-time.Hour).UnixNano(), 10)},
+				"end":   []string{strconv.FormatInt(testTime.UnixNano(), 10)},
+			}.Encode()
+
+			var proxiedBody string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				proxiedBody = string(b)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/query_range/stream", strings.NewReader(form))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req = req.WithContext(user.InjectOrgID(req.Context(), "1"))
+			rec := httptest.NewRecorder()
+			mw.Wrap(next).ServeHTTP(rec, req)
+
+			require.Equal(t, tc.status, rec.Code)
+			if tc.proxied {
+				// the request is proxied with its body.
+				require.Equal(t, form, proxiedBody)
+			} else {
+				require.Empty(t, proxiedBody)
+			}
+		})
+	}
+}
+
+func TestStreamQueryHTTPMiddlewareRefundsFailedStreams(t *testing.T) {
+	l := fakeLimits{
+		maxQueryParallelism:     1,
+		tsdbMaxQueryParallelism: 1,
+		maxEntriesLimitPerQuery: 1000,
+		queryBytesBudgetPerHour: 150,
+		queryTimeout:            time.Minute,
+	}
+	tpw, stopper, err := NewMiddleware(testConfig, testEngineOpts, nil, util_log.Logger, l, config.SchemaConfig{Configs: testSchemasTSDB}, nil, false, nil, constants.Loki)
+	if stopper != nil {
+		defer stopper.Stop()
+	}
+	require.NoError(t, err)
+	_, queryHandler := counter()
+	_, statsHandler := indexStatsResult(logproto.IndexStatsResponse{Bytes: 100})
+	mw := NewStreamQueryHTTPMiddleware(tpw.Wrap(getQueryAndStatsHandler(queryHandler, statsHandler)), 1024)
+
+	stream := func(next http.HandlerFunc) int {
+		form := url.Values{
+			"query": []string{`{app="foo"}`},
+			"start": []string{strconv.FormatInt(testTime.Add(-time.Hour).UnixNano(), 10)},
+			"end":   []string{strconv.FormatInt(testTime.UnixNano(), 10)},
+		}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/loki/api/v1/query_range/stream", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(user.InjectOrgID(req.Context(), "1"))
+		rec := httptest.NewRecorder()
+		mw.Wrap(next).ServeHTTP(rec, req)
+		return rec.Code
+	}
+	proxied := 0
+	respond := func(code int, lines ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, _ *http.Request) {
+			proxied++
+			w.WriteHeader(code)
+			for _, line := range lines {
+				_, _ = io.WriteString(w, line+"\n")
+			}
+		}
+	}
+
+	// failed streams are refunded, so each of them fits in the budget.
+	require.Equal(t, http.StatusInternalServerError, stream(respond(http.StatusInternalServerError)))
+	require.Equal(t, http.StatusOK, stream(respond(http.StatusOK, `{"stream":{"app":"foo"},"values":[["1","foo"]]}`, `{"status":"error","error":"querier failure"}`)))
+	require.Equal(t, 2, proxied)
+
+	// a successful stream uses its reservation, and the next one exceeds the budget.
+	require.Equal(t, http.StatusOK, stream(respond(http.StatusOK, `{"stream":{"app":"foo"},"values":[["1","foo"]]}`, `{"status":"success","stats":{}}`)))
+	require.Equal(t, http.StatusTooManyRequests, stream(respond(http.StatusOK, `{"status":"success","stats":{}}`)))
+	require.Equal(t, 3, proxied)
+}
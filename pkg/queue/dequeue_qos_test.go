@@ -58,7 +58,7 @@ func BenchmarkQueryFairness(t *testing.B) {
 
 	for _, useActor := range []bool{false, true} {
 		t.Run(fmt.Sprintf("use hierarchical queues = %v", useActor), func(t *testing.B) {
-			requestQueue := NewRequestQueue(1024, 0, noQueueLimits, NewMetrics(nil, constants.Loki, "query_scheduler"))
+			requestQueue := NewRequestQueue(1024, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
 			enqueueRequestsForActor(t, []string{}, useActor, requestQueue, numSubRequestsActorA, 50*time.Millisecond)
 			enqueueRequestsForActor(t, []string{"a"}, useActor, requestQueue, numSubRequestsActorA, 100*time.Millisecond)
 			enqueueRequestsForActor(t, []string{"b"}, useActor, requestQueue, numSubRequestsActorB, 50*time.Millisecond)
@@ -133,7 +133,7 @@ func TestQueryFairnessAcrossSameLevel(t *testing.T) {
 			  456: [210]
 	**/
 
-	requestQueue := NewRequestQueue(1024, 0, noQueueLimits, NewMetrics(nil, constants.Loki, "query_scheduler"))
+	requestQueue := NewRequestQueue(1024, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
 	_ = requestQueue.Enqueue("tenant1", []string{}, r(0), nil)
 	_ = requestQueue.Enqueue("tenant1", []string{}, r(1), nil)
 	_ = requestQueue.Enqueue("tenant1", []string{}, r(2), nil)
@@ -176,3 +176,42 @@ func TestQueryFairnessAcrossSameLevel(t *testing.T) {
 
 	require.Equal(t, []int{0, 10, 20, 1, 11, 200, 2, 12, 210, 21, 22}, items)
 }
+
+func TestQueryPriorities(t *testing.T) {
+	requestQueue := NewRequestQueue(1024, 0, noQueueLimits, PriorityConfig{HighWeight: 4, NormalWeight: 2, LowWeight: 1}, NewMetrics(nil, constants.Loki, "query_scheduler"))
+	for i := 0; i < 7; i++ {
+		require.NoError(t, requestQueue.EnqueueWithPriority("tenant1", PriorityLow, nil, r(i), nil))
+		require.NoError(t, requestQueue.EnqueueWithPriority("tenant1", PriorityNormal, []string{"abc"}, r(100+i), nil))
+		require.NoError(t, requestQueue.EnqueueWithPriority("tenant1", PriorityHigh, []string{"xyz"}, r(200+i), nil))
+	}
+	requestQueue.queues.recomputeUserConsumers()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	requestQueue.RegisterConsumerConnection("querier")
+	defer requestQueue.UnregisterConsumerConnection("querier")
+
+	priorities := make([]Priority, 0, 21)
+	idx := StartIndexWithLocalQueue
+	for len(priorities) < 21 {
+		r, newIdx, err := requestQueue.Dequeue(ctx, idx, "querier")
+		require.NoError(t, err)
+		idx = newIdx
+		priorities = append(priorities, Priority(r.(*dummyRequest).id/100))
+	}
+
+	count := func(items []Priority) map[Priority]int {
+		counts := map[Priority]int{}
+		for _, p := range items {
+			counts[p]++
+		}
+		return counts
+	}
+	// each class gets a share of the requests proportional to its weight.
+	require.Equal(t, map[Priority]int{PriorityHigh: 4, PriorityNormal: 2, PriorityLow: 1}, count(priorities[:7]))
+	require.Equal(t, map[Priority]int{PriorityHigh: 3, PriorityNormal: 3, PriorityLow: 1}, count(priorities[7:14]))
+	// high priority requests are exhausted, the others keep their relative shares.
+	require.Equal(t, map[Priority]int{PriorityNormal: 2, PriorityLow: 1}, count(priorities[14:17]))
+	require.Equal(t, 21, len(priorities))
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Priority is the priority class of a request within the queue of its tenant.
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+
+	numPriorities
+)
+
+var priorityNames = [numPriorities]string{
+	PriorityLow:    "low",
+	PriorityNormal: "normal",
+	PriorityHigh:   "high",
+}
+
+func (p Priority) String() string {
+	if p < 0 || p >= numPriorities {
+		return fmt.Sprintf("unknown(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority parses the name of a priority class. An empty name is the normal priority.
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityNormal, nil
+	}
+	for p, name := range priorityNames {
+		if name == s {
+			return Priority(p), nil
+		}
+	}
+	return PriorityNormal, fmt.Errorf("unknown query priority %q, expecting one of low, normal or high", s)
+}
+
+// PriorityConfig configures the share of requests dequeued from each priority class of a tenant
+// when several classes have pending requests. Zero weights are treated as 1.
+type PriorityConfig struct {
+	HighWeight   int `yaml:"high_weight"`
+	NormalWeight int `yaml:"normal_weight"`
+	LowWeight    int `yaml:"low_weight"`
+}
+
+// RegisterFlagsWithPrefix registers flags.
+func (cfg *PriorityConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.IntVar(&cfg.HighWeight, prefix+"high-priority-weight", 4, "Weight of the high priority requests of a tenant. When requests of several priority classes are pending, each class is dequeued proportionally to its weight.")
+	f.IntVar(&cfg.NormalWeight, prefix+"normal-priority-weight", 2, "Weight of the normal priority requests of a tenant. Requests without priority are normal priority requests.")
+	f.IntVar(&cfg.LowWeight, prefix+"low-priority-weight", 1, "Weight of the low priority requests of a tenant.")
+}
+
+func (cfg *PriorityConfig) Validate() error {
+	if cfg.HighWeight < 0 || cfg.NormalWeight < 0 || cfg.LowWeight < 0 {
+		return fmt.Errorf("priority weights must not be negative")
+	}
+	return nil
+}
+
+func (cfg PriorityConfig) weights() [numPriorities]int {
+	w := [numPriorities]int{
+		PriorityLow:    cfg.LowWeight,
+		PriorityNormal: cfg.NormalWeight,
+		PriorityHigh:   cfg.HighWeight,
+	}
+	for p := range w {
+		if w[p] <= 0 {
+			w[p] = 1
+		}
+	}
+	return w
+}
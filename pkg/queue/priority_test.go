@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriority(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		expected Priority
+	}{
+		{"", PriorityNormal},
+		{"low", PriorityLow},
+		{"normal", PriorityNormal},
+		{"high", PriorityHigh},
+	} {
+		p, err := ParsePriority(tc.in)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, p)
+		if tc.in != "" {
+			require.Equal(t, tc.in, p.String())
+		}
+	}
+
+	_, err := ParsePriority("urgent")
+	require.EqualError(t, err, `unknown query priority "urgent", expecting one of low, normal or high`)
+}
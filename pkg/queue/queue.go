@@ -69,9 +69,9 @@ type RequestQueue struct {
 	pool    *SlicePool[Request]
 }
 
-func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, limits Limits, metrics *Metrics) *RequestQueue {
+func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, limits Limits, priorities PriorityConfig, metrics *Metrics) *RequestQueue {
 	q := &RequestQueue{
-		queues:             newTenantQueues(maxOutstandingPerTenant, forgetDelay, limits),
+		queues:             newTenantQueues(maxOutstandingPerTenant, forgetDelay, limits, priorities),
 		connectedConsumers: atomic.NewInt32(0),
 		metrics:            metrics,
 		pool:               NewSlicePool[Request](1<<6, 1<<10, 2), // Buckets are [64, 128, 256, 512, 1024].
@@ -83,9 +83,15 @@ func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, lim
 	return q
 }
 
-// Enqueue puts the request into the queue.
+// Enqueue puts the request into the queue with the normal priority.
 // If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
 func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, successFn func()) error {
+	return q.EnqueueWithPriority(tenant, PriorityNormal, path, req, successFn)
+}
+
+// EnqueueWithPriority puts the request into the queue of the given priority class of the tenant.
+// If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
+func (q *RequestQueue) EnqueueWithPriority(tenant string, priority Priority, path []string, req Request, successFn func()) error {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
@@ -93,22 +99,16 @@ func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, succes
 		return ErrStopped
 	}
 
-	queue, err := q.queues.getOrAddQueue(tenant, path)
+	queue, err := q.queues.getOrAddQueue(tenant, priority, path)
 	if err != nil {
 		return fmt.Errorf("no queue found: %w", err)
 	}
 
-	// Optimistically increase queue counter for tenant instead of doing separate
-	// get and set operations, because _most_ of the time the increased value is
-	// smaller than the max queue length.
 	// We need to keep track of queue length separately because the size of the
-	// buffered channel is the same across all sub-queues which would allow
-	// enqueuing more items than there are allowed at tenant level.
-	queueLen := q.queues.perUserQueueLen.Inc(tenant)
-	if queueLen > q.queues.maxUserQueueSize {
+	// buffered channel is the same across all sub-queues and priority classes which
+	// would allow enqueuing more items than there are allowed at tenant level.
+	if !q.queues.reserve(tenant) {
 		q.metrics.discardedRequests.WithLabelValues(tenant).Inc()
-		// decrement, because we already optimistically increased the counter
-		q.queues.perUserQueueLen.Dec(tenant)
 		return ErrTooManyRequests
 	}
 
@@ -124,8 +124,7 @@ func (q *RequestQueue) Enqueue(tenant string, path []string, req Request, succes
 		return nil
 	default:
 		q.metrics.discardedRequests.WithLabelValues(tenant).Inc()
-		// decrement, because we already optimistically increased the counter
-		q.queues.perUserQueueLen.Dec(tenant)
+		q.queues.release(tenant)
 		return ErrTooManyRequests
 	}
 }
@@ -234,7 +233,7 @@ FindQueue:
 		q.queues.deleteQueue(tenant)
 	}
 
-	q.queues.perUserQueueLen.Dec(tenant)
+	q.queues.release(tenant)
 	q.metrics.queueLength.WithLabelValues(tenant).Dec()
 
 	// Tell close() we've processed a request.
@@ -50,7 +50,7 @@ func BenchmarkGetNextRequest(b *testing.B) {
 
 			queues := make([]*RequestQueue, 0, b.N)
 			for n := 0; n < b.N; n++ {
-				queue := NewRequestQueue(maxOutstandingPerTenant, 0, noQueueLimits, NewMetrics(nil, constants.Loki, "query_scheduler"))
+				queue := NewRequestQueue(maxOutstandingPerTenant, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
 				queues = append(queues, queue)
 
 				for ix := 0; ix < queriers; ix++ {
@@ -108,7 +108,7 @@ func BenchmarkQueueRequest(b *testing.B) {
 	requests := make([]string, 0, numTenants)
 
 	for n := 0; n < b.N; n++ {
-		q := NewRequestQueue(maxOutstandingPerTenant, 0, noQueueLimits, NewMetrics(nil, constants.Loki, "query_scheduler"))
+		q := NewRequestQueue(maxOutstandingPerTenant, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
 
 		for ix := 0; ix < queriers; ix++ {
 			q.RegisterConsumerConnection(fmt.Sprintf("querier-%d", ix))
@@ -138,7 +138,7 @@ func BenchmarkQueueRequest(b *testing.B) {
 func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBecauseQuerierHasBeenForgotten(t *testing.T) {
 	const forgetDelay = 3 * time.Second
 
-	queue := NewRequestQueue(1, forgetDelay, &mockQueueLimits{maxConsumers: 1}, NewMetrics(nil, constants.Loki, "query_scheduler"))
+	queue := NewRequestQueue(1, forgetDelay, &mockQueueLimits{maxConsumers: 1}, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
 
 	// Start the queue service.
 	ctx := context.Background()
@@ -309,7 +309,7 @@ func TestContextCond(t *testing.T) {
 func TestMaxQueueSize(t *testing.T) {
 	t.Run("queue size is tracked per tenant", func(t *testing.T) {
 		maxSize := 3
-		queue := NewRequestQueue(maxSize, 0, noQueueLimits, NewMetrics(nil, constants.Loki, "query_scheduler"))
+		queue := NewRequestQueue(maxSize, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
 		queue.RegisterConsumerConnection("querier")
 
 		// enqueue maxSize items with different actors
@@ -334,6 +334,30 @@ func TestMaxQueueSize(t *testing.T) {
 		err = queue.Enqueue("tenant", []string{"user-c"}, 6, nil)
 		assert.Equal(t, err, ErrTooManyRequests)
 	})
+
+	t.Run("queue size is shared by the priority classes", func(t *testing.T) {
+		maxSize := 3
+		queue := NewRequestQueue(maxSize, 0, noQueueLimits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
+		queue.RegisterConsumerConnection("querier")
+
+		// each priority class has its own channels with maxSize length
+		assert.NoError(t, queue.EnqueueWithPriority("tenant", PriorityLow, nil, 1, nil))
+		assert.NoError(t, queue.EnqueueWithPriority("tenant", PriorityNormal, []string{"user-a"}, 2, nil))
+		assert.NoError(t, queue.EnqueueWithPriority("tenant", PriorityHigh, nil, 3, nil))
+
+		// max queue length per tenant is tracked for all priority classes together
+		for _, p := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+			err := queue.EnqueueWithPriority("tenant", p, nil, 4, nil)
+			assert.Equal(t, err, ErrTooManyRequests)
+		}
+
+		_, _, err := queue.Dequeue(context.Background(), StartIndexWithLocalQueue, "querier")
+		assert.NoError(t, err)
+
+		assert.NoError(t, queue.EnqueueWithPriority("tenant", PriorityLow, nil, 4, nil))
+		err = queue.EnqueueWithPriority("tenant", PriorityHigh, nil, 5, nil)
+		assert.Equal(t, err, ErrTooManyRequests)
+	})
 }
 
 type mockLimits struct {
@@ -452,7 +476,7 @@ func Test_Queue_DequeueMany(t *testing.T) {
 			totalTasksCount := tt.tenantsCount * tt.tasksPerTenant
 			limits := &mockLimits{maxConsumer: tt.maxConsumersPerTenant}
 			require.LessOrEqual(t, tt.tasksPerTenant, tenantsQueueMaxSize, "test must be able to enqueue all the tasks without error")
-			queue := NewRequestQueue(tenantsQueueMaxSize, 0, limits, NewMetrics(nil, constants.Loki, "query_scheduler"))
+			queue := NewRequestQueue(tenantsQueueMaxSize, 0, limits, PriorityConfig{}, NewMetrics(nil, constants.Loki, "query_scheduler"))
 			for i := 0; i < tt.consumersCount; i++ {
 				queue.RegisterConsumerConnection(createConsumerName(i))
 			}
@@ -75,6 +75,9 @@ type tenantQueues struct {
 	sortedConsumers []string
 
 	limits Limits
+
+	// Weights of the priority classes of the tenant queues.
+	priorityWeights [numPriorities]int
 }
 
 type Queue interface {
@@ -101,9 +104,76 @@ type tenantQueue struct {
 	// Seed for shuffle sharding of consumers. This seed is based on userID only and is therefore consistent
 	// between different frontends.
 	seed int64
+
+	// Queues of the priority classes other than PriorityNormal, whose requests are
+	// queued in the embedded TreeQueue. They are created on demand.
+	// Each of them can hold up to size requests, but the requests of all of them are
+	// counted together against the size of the tenant queue, see tenantQueues.reserve.
+	priorities [numPriorities]*TreeQueue
+	// Weights and current credits of the priority classes for the smooth weighted round-robin.
+	weights [numPriorities]int
+	credits [numPriorities]int
 }
 
-func newTenantQueues(maxUserQueueSize int, forgetDelay time.Duration, limits Limits) *tenantQueues {
+// queue returns the queue of the given priority class, creating it if needed.
+func (q *tenantQueue) queue(p Priority) *TreeQueue {
+	if p == PriorityNormal {
+		return q.TreeQueue
+	}
+	if q.priorities[p] == nil {
+		q.priorities[p] = newTreeQueue(q.size, q.name)
+	}
+	return q.priorities[p]
+}
+
+// Dequeue implements Queue
+// When several priority classes have pending requests, the class is chosen by a smooth
+// weighted round-robin, so that each class gets a share of the requests proportional to its weight.
+func (q *tenantQueue) Dequeue() Request {
+	if q.priorities == [numPriorities]*TreeQueue{} {
+		// only normal priority requests were enqueued.
+		return q.TreeQueue.Dequeue()
+	}
+
+	var (
+		next        = Priority(-1)
+		totalWeight int
+	)
+	for p := PriorityLow; p < numPriorities; p++ {
+		subq := q.priorities[p]
+		if p == PriorityNormal {
+			subq = q.TreeQueue
+		}
+		if subq == nil || subq.Len() == 0 {
+			q.credits[p] = 0
+			continue
+		}
+		q.credits[p] += q.weights[p]
+		totalWeight += q.weights[p]
+		if next < 0 || q.credits[p] > q.credits[next] {
+			next = p
+		}
+	}
+	if next < 0 {
+		return nil
+	}
+	q.credits[next] -= totalWeight
+	return q.queue(next).Dequeue()
+}
+
+// Len implements Queue
+// It returns the number of requests of all priority classes.
+func (q *tenantQueue) Len() int {
+	count := q.TreeQueue.Len()
+	for _, subq := range q.priorities {
+		if subq != nil {
+			count += subq.Len()
+		}
+	}
+	return count
+}
+
+func newTenantQueues(maxUserQueueSize int, forgetDelay time.Duration, limits Limits, priorities PriorityConfig) *tenantQueues {
 	mm := &Mapping[*tenantQueue]{}
 	mm.Init(64)
 	return &tenantQueues{
@@ -114,6 +184,7 @@ func newTenantQueues(maxUserQueueSize int, forgetDelay time.Duration, limits Lim
 		consumers:        map[string]*consumer{},
 		sortedConsumers:  nil,
 		limits:           limits,
+		priorityWeights:  priorities.weights(),
 	}
 }
 
@@ -125,8 +196,24 @@ func (q *tenantQueues) deleteQueue(tenant string) {
 	q.mapping.Remove(tenant)
 }
 
-// Returns existing or new queue for a tenant.
-func (q *tenantQueues) getOrAddQueue(tenantID string, path []string) (Queue, error) {
+// reserve accounts for a new request of the tenant. The size of the queue of a tenant is shared
+// by all its priority classes and sub-queues, so it returns false if the tenant already has
+// maxUserQueueSize queued requests, whatever their priority classes and paths.
+func (q *tenantQueues) reserve(tenant string) bool {
+	if q.perUserQueueLen.Inc(tenant) > q.maxUserQueueSize {
+		q.perUserQueueLen.Dec(tenant)
+		return false
+	}
+	return true
+}
+
+// release accounts for a request of the tenant leaving the queue.
+func (q *tenantQueues) release(tenant string) {
+	q.perUserQueueLen.Dec(tenant)
+}
+
+// Returns existing or new queue for a tenant and priority class.
+func (q *tenantQueues) getOrAddQueue(tenantID string, priority Priority, path []string) (Queue, error) {
 	// Empty tenant is not allowed, as that would break our tenants list ("" is used for free spot).
 	if tenantID == "" {
 		return nil, fmt.Errorf("empty tenant is not allowed")
@@ -141,7 +228,8 @@ func (q *tenantQueues) getOrAddQueue(tenantID string, path []string) (Queue, err
 	uq := q.mapping.GetByKey(tenantID)
 	if uq == nil {
 		uq = &tenantQueue{
-			seed: util.ShuffleShardSeed(tenantID, ""),
+			seed:    util.ShuffleShardSeed(tenantID, ""),
+			weights: q.priorityWeights,
 		}
 		uq.TreeQueue = newTreeQueue(q.maxUserQueueSize, tenantID)
 		q.mapping.Put(tenantID, uq)
@@ -158,10 +246,10 @@ func (q *tenantQueues) getOrAddQueue(tenantID string, path []string) (Queue, err
 		uq.consumers = shuffleConsumersForTenants(uq.seed, consumersToSelect, q.sortedConsumers, nil)
 	}
 
-	if len(path) == 0 {
+	if len(path) == 0 && priority == PriorityNormal {
 		return uq, nil
 	}
-	return uq.add(path), nil
+	return uq.queue(priority).add(path), nil
 }
 
 // Finds next queue for the consumer. To support fair scheduling between users, client is expected
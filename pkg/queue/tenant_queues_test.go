@@ -22,7 +22,7 @@ import (
 var noQueueLimits = limits.NewQueueLimits(nil)
 
 func TestQueues(t *testing.T) {
-	uq := newTenantQueues(0, 0, noQueueLimits)
+	uq := newTenantQueues(0, 0, noQueueLimits, PriorityConfig{})
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -80,7 +80,7 @@ func TestQueues(t *testing.T) {
 }
 
 func TestQueuesOnTerminatingConsumer(t *testing.T) {
-	uq := newTenantQueues(0, 0, noQueueLimits)
+	uq := newTenantQueues(0, 0, noQueueLimits, PriorityConfig{})
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -111,7 +111,7 @@ func TestQueuesOnTerminatingConsumer(t *testing.T) {
 
 func TestQueuesWithConsumers(t *testing.T) {
 	maxConsumers := 5
-	uq := newTenantQueues(0, 0, &mockQueueLimits{maxConsumers: maxConsumers})
+	uq := newTenantQueues(0, 0, &mockQueueLimits{maxConsumers: maxConsumers}, PriorityConfig{})
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -187,7 +187,7 @@ func TestQueuesConsistency(t *testing.T) {
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
-			uq := newTenantQueues(0, testData.forgetDelay, &mockQueueLimits{maxConsumers: 3})
+			uq := newTenantQueues(0, testData.forgetDelay, &mockQueueLimits{maxConsumers: 3}, PriorityConfig{})
 			assert.NotNil(t, uq)
 			assert.NoError(t, isConsistent(uq))
 
@@ -200,7 +200,7 @@ func TestQueuesConsistency(t *testing.T) {
 			for i := 0; i < 10000; i++ {
 				switch r.Int() % 6 {
 				case 0:
-					q, err := uq.getOrAddQueue(generateTenant(r), generateActor(r))
+					q, err := uq.getOrAddQueue(generateTenant(r), PriorityNormal, generateActor(r))
 					assert.NoError(t, err)
 					assert.NotNil(t, q)
 				case 1:
@@ -238,7 +238,7 @@ func TestQueues_ForgetDelay(t *testing.T) {
 	)
 
 	now := time.Now()
-	uq := newTenantQueues(0, forgetDelay, &mockQueueLimits{maxConsumers: maxConsumers})
+	uq := newTenantQueues(0, forgetDelay, &mockQueueLimits{maxConsumers: maxConsumers}, PriorityConfig{})
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -330,7 +330,7 @@ func TestQueues_ForgetDelay_ShouldCorrectlyHandleConsumerReconnectingBeforeForge
 	)
 
 	now := time.Now()
-	uq := newTenantQueues(0, forgetDelay, &mockQueueLimits{maxConsumers: maxConsumers})
+	uq := newTenantQueues(0, forgetDelay, &mockQueueLimits{maxConsumers: maxConsumers}, PriorityConfig{})
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -409,11 +409,11 @@ func generateConsumer(r *rand.Rand) string {
 
 func getOrAdd(t *testing.T, uq *tenantQueues, tenant string) Queue {
 	actor := []string{}
-	q, err := uq.getOrAddQueue(tenant, actor)
+	q, err := uq.getOrAddQueue(tenant, PriorityNormal, actor)
 	assert.NoError(t, err)
 	assert.NotNil(t, q)
 	assert.NoError(t, isConsistent(uq))
-	q2, err := uq.getOrAddQueue(tenant, actor)
+	q2, err := uq.getOrAddQueue(tenant, PriorityNormal, actor)
 	assert.NoError(t, err)
 	assert.Equal(t, q, q2)
 	return q
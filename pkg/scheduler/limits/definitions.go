@@ -13,6 +13,12 @@ type Limits interface {
 	MaxQueryCapacity(user string) float64
 }
 
+// PriorityLimits needed for the Query Scheduler to enqueue the requests with their priority class.
+type PriorityLimits interface {
+	// MaxQueryPriority returns the highest priority class the requests of this user can be enqueued with.
+	MaxQueryPriority(user string) string
+}
+
 func NewQueueLimits(limits Limits) *QueueLimits {
 	return &QueueLimits{limits: limits}
 }
@@ -45,7 +45,13 @@ const (
 	ReplicationFactor = 2
 )
 
-var errSchedulerIsNotRunning = errors.New("scheduler is not running")
+var (
+	errSchedulerIsNotRunning = errors.New("scheduler is not running")
+	errRequestPreempted      = errors.New("the query was canceled to run a query of higher priority, please retry")
+)
+
+// preemptedResponseTimeout is the timeout to report the error of a preempted request to its frontend.
+const preemptedResponseTimeout = 5 * time.Second
 
 // Scheduler is responsible for queueing and dispatching queries to Queriers.
 type Scheduler struct {
@@ -78,6 +84,7 @@ type Scheduler struct {
 	queueDuration            prometheus.Histogram
 	schedulerRunning         prometheus.Gauge
 	inflightRequests         prometheus.Summary
+	preemptedRequests        *prometheus.CounterVec
 
 	// Ring used for finding schedulers
 	ringManager *lokiring.RingManager
@@ -102,10 +109,12 @@ type connectedFrontend struct {
 }
 
 type Config struct {
-	MaxOutstandingPerTenant int               `yaml:"max_outstanding_requests_per_tenant"`
-	MaxQueueHierarchyLevels int               `yaml:"max_queue_hierarchy_levels"`
-	QuerierForgetDelay      time.Duration     `yaml:"querier_forget_delay"`
-	GRPCClientConfig        grpcclient.Config `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
+	MaxOutstandingPerTenant int                  `yaml:"max_outstanding_requests_per_tenant"`
+	MaxQueueHierarchyLevels int                  `yaml:"max_queue_hierarchy_levels"`
+	QuerierForgetDelay      time.Duration        `yaml:"querier_forget_delay"`
+	Priorities              queue.PriorityConfig `yaml:"priorities" doc:"description=Weights of the priority classes of the requests of a tenant. The priority class of a query is set with the X-Loki-Query-Priority header, whose value is one of low, normal or high. Queries evaluated remotely by the ruler are high priority queries. The priority class of the queries of a tenant is capped by its max_query_priority limit."`
+	PriorityPreemptionWait  time.Duration        `yaml:"priority_preemption_wait"`
+	GRPCClientConfig        grpcclient.Config    `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
 	// Schedulers ring
 	UseSchedulerRing bool                `yaml:"use_scheduler_ring"`
 	SchedulerRing    lokiring.RingConfig `yaml:"scheduler_ring,omitempty" doc:"description=The hash ring configuration. This option is required only if use_scheduler_ring is true."`
@@ -114,6 +123,8 @@ type Config struct {
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 32000, "Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	f.IntVar(&cfg.MaxQueueHierarchyLevels, "query-scheduler.max-queue-hierarchy-levels", 3, "Maximum number of levels of nesting of hierarchical queues. 0 means that hierarchical queues are disabled.")
+	cfg.Priorities.RegisterFlagsWithPrefix("query-scheduler.", f)
+	f.DurationVar(&cfg.PriorityPreemptionWait, "query-scheduler.priority-preemption-wait", 0, "If a request of a tenant has been waiting in the queue for longer than this duration, the query-scheduler cancels a running request of the same tenant with a lower priority to free a querier worker. The canceled request fails with HTTP response status code 503 and is retried by the query-frontend. 0 disables preemption.")
 	f.DurationVar(&cfg.QuerierForgetDelay, "query-scheduler.querier-forget-delay", 0, "If a querier disconnects without sending notification about graceful shutdown, the query-scheduler will keep the querier in the tenant's shard until the forget delay has passed. This feature is useful to reduce the blast radius when shuffle-sharding is enabled.")
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
 	f.BoolVar(&cfg.UseSchedulerRing, "query-scheduler.use-scheduler-ring", false, "Set to true to have the query schedulers create and place themselves in a ring. If no frontend_address or scheduler_address are present anywhere else in the configuration, Loki will toggle this value to true.")
@@ -129,6 +140,9 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 }
 
 func (cfg *Config) Validate() error {
+	if err := cfg.Priorities.Validate(); err != nil {
+		return err
+	}
 	if cfg.SchedulerRing.NumTokens != NumTokens {
 		return errors.New("Num tokens must not be changed as it will not take effect")
 	}
@@ -158,7 +172,7 @@ func NewScheduler(cfg Config, schedulerLimits Limits, log log.Logger, ringManage
 		connectedFrontends: map[string]*connectedFrontend{},
 		queueMetrics:       queueMetrics,
 		ringManager:        ringManager,
-		requestQueue:       queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, limits.NewQueueLimits(schedulerLimits), queueMetrics),
+		requestQueue:       queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, limits.NewQueueLimits(schedulerLimits), cfg.Priorities, queueMetrics),
 	}
 
 	s.queueDuration = promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
@@ -190,6 +204,11 @@ func NewScheduler(cfg Config, schedulerLimits Limits, log log.Logger, ringManage
 		MaxAge:     time.Minute,
 		AgeBuckets: 6,
 	})
+	s.preemptedRequests = promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "query_scheduler_preempted_requests_total",
+		Help:      "Total number of running requests canceled to free a querier worker for a waiting request of higher priority.",
+	}, []string{"priority"})
 
 	s.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(s.cleanupMetricsForInactiveUser)
 
@@ -214,7 +233,11 @@ func NewScheduler(cfg Config, schedulerLimits Limits, log log.Logger, ringManage
 	return s, nil
 }
 
-type Limits limits.Limits
+// Limits needed for the query-scheduler.
+type Limits interface {
+	limits.Limits
+	limits.PriorityLimits
+}
 
 type schedulerRequest struct {
 	frontendAddress string
@@ -223,9 +246,18 @@ type schedulerRequest struct {
 	request         *httpgrpc.HTTPRequest
 	queryRequest    *queryrange.QueryRequest
 	statsEnabled    bool
+	priority        queue.Priority
 
 	queueTime time.Time
 
+	// dispatchTime is set before dispatched is true.
+	dispatchTime time.Time
+	dispatched   atomic.Bool
+	// preempted is true if the request was canceled to free a querier for a request of higher priority.
+	preempted atomic.Bool
+	// preempting is true if a request was already preempted for this request. Guarded by pendingRequestsMu.
+	preempting bool
+
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 	queueSpan opentracing.Span
@@ -367,6 +399,7 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 		queryRequest:    msg.GetQueryRequest(),
 		statsEnabled:    msg.StatsEnabled,
 	}
+	req.priority = s.requestPriority(msg)
 
 	now := time.Now()
 
@@ -393,7 +426,7 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	}
 
 	s.activeUsers.UpdateUserTimestamp(req.tenantID, now)
-	return s.requestQueue.Enqueue(req.tenantID, queuePath, req, func() {
+	return s.requestQueue.EnqueueWithPriority(req.tenantID, req.priority, queuePath, req, func() {
 		shouldCancel = false
 
 		s.pendingRequestsMu.Lock()
@@ -402,6 +435,33 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	})
 }
 
+// requestPriority returns the priority class of the request, set by the LokiQueryPriorityHeader header
+// and capped by the max query priority of its tenant.
+func (s *Scheduler) requestPriority(msg *schedulerpb.FrontendToScheduler) queue.Priority {
+	var value string
+	if r := msg.GetQueryRequest(); r != nil {
+		value = r.Metadata[lokihttpreq.LokiQueryPriorityHeader]
+	} else if r := msg.GetHttpRequest(); r != nil {
+		for _, h := range r.Headers {
+			if textproto.CanonicalMIMEHeaderKey(h.Key) == textproto.CanonicalMIMEHeaderKey(lokihttpreq.LokiQueryPriorityHeader) && len(h.Values) > 0 {
+				value = h.Values[0]
+				break
+			}
+		}
+	}
+
+	priority, err := queue.ParsePriority(value)
+	if err != nil {
+		level.Debug(s.log).Log("msg", "invalid query priority, using the normal priority", "tenant", msg.UserID, "err", err)
+	}
+	// the tenants can't give their queries a priority higher than allowed by their limits.
+	maxPriority, err := queue.ParsePriority(s.limits.MaxQueryPriority(msg.UserID))
+	if err != nil {
+		maxPriority = queue.PriorityNormal
+	}
+	return min(priority, maxPriority)
+}
+
 // This method doesn't do removal from the queue.
 func (s *Scheduler) cancelRequestAndRemoveFromPending(frontendAddr string, queryID uint64) {
 	s.pendingRequestsMu.Lock()
@@ -444,7 +504,9 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 		}
 		r := req.(*schedulerRequest)
 
-		reqQueueTime := time.Since(r.queueTime)
+		r.dispatchTime = time.Now()
+		r.dispatched.Store(true)
+		reqQueueTime := r.dispatchTime.Sub(r.queueTime)
 		s.queueDuration.Observe(reqQueueTime.Seconds())
 		r.queueSpan.Finish()
 
@@ -532,6 +594,12 @@ func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuer
 		// If the upstream request is cancelled (eg. frontend issued CANCEL or closed connection),
 		// we need to cancel the downstream req. Only way we can do that is to close the stream (by returning error here).
 		// Querier is expecting this semantics.
+		if req.preempted.Load() {
+			// The frontend is still waiting for the response of a preempted request.
+			ctx, cancel := context.WithTimeout(context.Background(), preemptedResponseTimeout)
+			defer cancel()
+			s.forwardErrorToFrontend(ctx, req, http.StatusServiceUnavailable, errRequestPreempted)
+		}
 		return req.ctx.Err()
 
 	case err := <-errCh:
@@ -539,13 +607,13 @@ func (s *Scheduler) forwardRequestToQuerier(querier schedulerpb.SchedulerForQuer
 		// then error out this upstream request _and_ stream.
 
 		if err != nil {
-			s.forwardErrorToFrontend(req.ctx, req, err)
+			s.forwardErrorToFrontend(req.ctx, req, http.StatusInternalServerError, err)
 		}
 		return err
 	}
 }
 
-func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *schedulerRequest, requestErr error) {
+func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *schedulerRequest, code int, requestErr error) {
 	opts, err := s.cfg.GRPCClientConfig.DialOption([]grpc.UnaryClientInterceptor{
 		otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
 		middleware.ClientUserHeaderInterceptor,
@@ -574,7 +642,7 @@ func (s *Scheduler) forwardErrorToFrontend(ctx context.Context, req *schedulerRe
 		QueryID: req.queryID,
 		Response: &frontendv2pb.QueryResultRequest_HttpResponse{
 			HttpResponse: &httpgrpc.HTTPResponse{
-				Code: http.StatusInternalServerError,
+				Code: int32(code),
 				Body: []byte(requestErr.Error()),
 			},
 		},
@@ -642,10 +710,62 @@ func (s *Scheduler) running(ctx context.Context) error {
 			s.pendingRequestsMu.Unlock()
 
 			s.inflightRequests.Observe(float64(inflight))
+
+			if s.cfg.PriorityPreemptionWait > 0 {
+				s.preemptRequests(time.Now())
+			}
 		}
 	}
 }
 
+// preemptRequests cancels running requests to free querier workers for the requests waiting for longer
+// than the preemption wait. For each of them, at most one running request of the same tenant
+// with a lower priority is canceled, the most recently dispatched one.
+func (s *Scheduler) preemptRequests(now time.Time) {
+	s.pendingRequestsMu.Lock()
+	defer s.pendingRequestsMu.Unlock()
+
+	// Running requests which can be preempted, by tenant, and requests waiting for too long.
+	running := map[string][]*schedulerRequest{}
+	var starved []*schedulerRequest
+	for _, r := range s.pendingRequests {
+		if r.dispatched.Load() {
+			if !r.preempted.Load() && r.priority < queue.PriorityHigh {
+				running[r.tenantID] = append(running[r.tenantID], r)
+			}
+			continue
+		}
+		if !r.preempting && r.priority > queue.PriorityLow && r.ctx.Err() == nil && now.Sub(r.queueTime) > s.cfg.PriorityPreemptionWait {
+			starved = append(starved, r)
+		}
+	}
+
+	for _, r := range starved {
+		candidates := running[r.tenantID]
+		victim := -1
+		for i, c := range candidates {
+			if c.priority >= r.priority {
+				continue
+			}
+			if victim < 0 || c.priority < candidates[victim].priority ||
+				(c.priority == candidates[victim].priority && c.dispatchTime.After(candidates[victim].dispatchTime)) {
+				victim = i
+			}
+		}
+		if victim < 0 {
+			continue
+		}
+
+		v := candidates[victim]
+		running[r.tenantID] = append(candidates[:victim], candidates[victim+1:]...)
+		r.preempting = true
+		v.preempted.Store(true)
+		v.ctxCancel()
+		s.preemptedRequests.WithLabelValues(v.priority.String()).Inc()
+		level.Debug(s.log).Log("msg", "preempted request", "tenant", v.tenantID, "priority", v.priority, "waiting_priority", r.priority, "waiting_for", now.Sub(r.queueTime))
+	}
+}
+
 func (s *Scheduler) setRunState(isInSet bool) {
 	if isInSet {
 		if s.shouldRun.CompareAndSwap(false, true) {
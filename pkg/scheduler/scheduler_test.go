@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/grafana/dskit/httpgrpc"
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,7 +13,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc/metadata"
 
+	"github.com/grafana/loki/v3/pkg/querier/queryrange"
+	"github.com/grafana/loki/v3/pkg/queue"
 	"github.com/grafana/loki/v3/pkg/scheduler/schedulerpb"
+	lokihttpreq "github.com/grafana/loki/v3/pkg/util/httpreq"
 	util_log "github.com/grafana/loki/v3/pkg/util/log"
 )
 
@@ -65,6 +69,112 @@ func TestScheduler_setRunState(t *testing.T) {
 
 }
 
+type priorityLimits struct {
+	maxPriority string
+}
+
+func (priorityLimits) MaxQueriersPerUser(string) uint   { return 0 }
+func (priorityLimits) MaxQueryCapacity(string) float64  { return 0 }
+func (l priorityLimits) MaxQueryPriority(string) string { return l.maxPriority }
+
+func TestScheduler_requestPriority(t *testing.T) {
+	s := Scheduler{log: util_log.Logger, limits: priorityLimits{maxPriority: "high"}}
+
+	assert.Equal(t, queue.PriorityNormal, s.requestPriority(&schedulerpb.FrontendToScheduler{
+		Request: &schedulerpb.FrontendToScheduler_HttpRequest{HttpRequest: &httpgrpc.HTTPRequest{}},
+	}))
+	assert.Equal(t, queue.PriorityHigh, s.requestPriority(&schedulerpb.FrontendToScheduler{
+		Request: &schedulerpb.FrontendToScheduler_HttpRequest{HttpRequest: &httpgrpc.HTTPRequest{
+			Headers: []*httpgrpc.Header{{Key: "X-Loki-Query-Priority", Values: []string{"high"}}},
+		}},
+	}))
+	assert.Equal(t, queue.PriorityLow, s.requestPriority(&schedulerpb.FrontendToScheduler{
+		Request: &schedulerpb.FrontendToScheduler_QueryRequest{QueryRequest: &queryrange.QueryRequest{
+			Metadata: map[string]string{lokihttpreq.LokiQueryPriorityHeader: "low"},
+		}},
+	}))
+	assert.Equal(t, queue.PriorityNormal, s.requestPriority(&schedulerpb.FrontendToScheduler{
+		Request: &schedulerpb.FrontendToScheduler_QueryRequest{QueryRequest: &queryrange.QueryRequest{
+			Metadata: map[string]string{lokihttpreq.LokiQueryPriorityHeader: "urgent"},
+		}},
+	}))
+
+	// the priority is capped by the limits of the tenant.
+	s.limits = priorityLimits{maxPriority: "normal"}
+	assert.Equal(t, queue.PriorityNormal, s.requestPriority(&schedulerpb.FrontendToScheduler{
+		Request: &schedulerpb.FrontendToScheduler_HttpRequest{HttpRequest: &httpgrpc.HTTPRequest{
+			Headers: []*httpgrpc.Header{{Key: "X-Loki-Query-Priority", Values: []string{"high"}}},
+		}},
+	}))
+	assert.Equal(t, queue.PriorityLow, s.requestPriority(&schedulerpb.FrontendToScheduler{
+		Request: &schedulerpb.FrontendToScheduler_QueryRequest{QueryRequest: &queryrange.QueryRequest{
+			Metadata: map[string]string{lokihttpreq.LokiQueryPriorityHeader: "low"},
+		}},
+	}))
+	s.limits = priorityLimits{maxPriority: "low"}
+	assert.Equal(t, queue.PriorityLow, s.requestPriority(&schedulerpb.FrontendToScheduler{
+		Request: &schedulerpb.FrontendToScheduler_HttpRequest{HttpRequest: &httpgrpc.HTTPRequest{}},
+	}))
+}
+
+func TestScheduler_preemptRequests(t *testing.T) {
+	s := Scheduler{
+		log: util_log.Logger,
+		cfg: Config{PriorityPreemptionWait: time.Second},
+		preemptedRequests: promauto.With(nil).NewCounterVec(prometheus.CounterOpts{
+			Name: "loki_query_scheduler_preempted_requests_total",
+		}, []string{"priority"}),
+		pendingRequests: map[requestKey]*schedulerRequest{},
+	}
+
+	now := time.Now()
+	newRequest := func(id uint64, tenant string, priority queue.Priority, queueTime time.Time, dispatchTime time.Time) *schedulerRequest {
+		r := &schedulerRequest{
+			tenantID:  tenant,
+			queryID:   id,
+			priority:  priority,
+			queueTime: queueTime,
+		}
+		r.ctx, r.ctxCancel = context.WithCancel(context.Background())
+		if !dispatchTime.IsZero() {
+			r.dispatchTime = dispatchTime
+			r.dispatched.Store(true)
+		}
+		s.pendingRequests[requestKey{queryID: id}] = r
+		return r
+	}
+
+	var (
+		lowOld    = newRequest(1, "tenant-a", queue.PriorityLow, now.Add(-time.Minute), now.Add(-time.Minute))
+		lowRecent = newRequest(2, "tenant-a", queue.PriorityLow, now.Add(-time.Minute), now.Add(-10*time.Second))
+		normal    = newRequest(3, "tenant-a", queue.PriorityNormal, now.Add(-time.Minute), now.Add(-5*time.Second))
+		otherLow  = newRequest(4, "tenant-b", queue.PriorityLow, now.Add(-time.Minute), now.Add(-5*time.Second))
+
+		starved    = newRequest(5, "tenant-a", queue.PriorityHigh, now.Add(-2*time.Second), time.Time{})
+		notStarved = newRequest(6, "tenant-a", queue.PriorityHigh, now, time.Time{})
+		waitingLow = newRequest(7, "tenant-b", queue.PriorityLow, now.Add(-time.Minute), time.Time{})
+	)
+
+	s.preemptRequests(now)
+	// the most recently dispatched request of the lowest priority of the same tenant is preempted.
+	assert.True(t, lowRecent.preempted.Load())
+	assert.Error(t, lowRecent.ctx.Err())
+	for _, r := range []*schedulerRequest{lowOld, normal, otherLow, starved, notStarved, waitingLow} {
+		assert.False(t, r.preempted.Load())
+		assert.NoError(t, r.ctx.Err())
+	}
+
+	// a waiting request preempts a single request.
+	s.preemptRequests(now.Add(time.Second))
+	assert.False(t, lowOld.preempted.Load())
+
+	// until another one is waiting for too long.
+	s.preemptRequests(now.Add(2 * time.Second))
+	assert.True(t, lowOld.preempted.Load())
+	assert.False(t, normal.preempted.Load())
+	assert.False(t, otherLow.preempted.Load())
+}
+
 func TestProtobufBackwardsCompatibility(t *testing.T) {
 	t.Run("SchedulerToQuerier", func(t *testing.T) {
 		expected := &schedulerpb.SchedulerToQuerier{
@@ -61,11 +61,14 @@ func ExtractTestableLabelMatchers(expr syntax.Expr) []LabelMatcher {
 
 		VisitLogfmtParserFn:           func(v syntax.RootVisitor, e *syntax.LogfmtParserExpr) { foundParseStage = true },
 		VisitLabelParserFn:            func(v syntax.RootVisitor, e *syntax.LabelParserExpr) { foundParseStage = true },
+		VisitDelimitedParserFn:        func(v syntax.RootVisitor, e *syntax.DelimitedParserExpr) { foundParseStage = true },
 		VisitJSONExpressionParserFn:   func(v syntax.RootVisitor, e *syntax.JSONExpressionParser) { foundParseStage = true },
+		VisitXMLExpressionParserFn:    func(v syntax.RootVisitor, e *syntax.XMLExpressionParser) { foundParseStage = true },
 		VisitLogfmtExpressionParserFn: func(v syntax.RootVisitor, e *syntax.LogfmtExpressionParser) { foundParseStage = true },
 		VisitLabelFmtFn:               func(v syntax.RootVisitor, e *syntax.LabelFmtExpr) { foundParseStage = true },
 		VisitKeepLabelFn:              func(v syntax.RootVisitor, e *syntax.KeepLabelsExpr) { foundParseStage = true },
 		VisitDropLabelsFn:             func(v syntax.RootVisitor, e *syntax.DropLabelsExpr) { foundParseStage = true },
+		VisitLookupFn:                 func(v syntax.RootVisitor, e *syntax.LookupExpr) { foundParseStage = true },
 	}
 	expr.Accept(visitor)
 
@@ -25,6 +25,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/iter"
 	"github.com/grafana/loki/v3/pkg/logproto"
 	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/logql/syntax"
 	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
 	"github.com/grafana/loki/v3/pkg/querier/astmapper"
 	"github.com/grafana/loki/v3/pkg/storage/chunk"
@@ -510,6 +511,11 @@ func (s *LokiStore) SelectLogs(ctx context.Context, req logql.SelectLogParams) (
 		return nil, err
 	}
 
+	expr, err = syntax.BindLookupTables(expr, s.lookupTables(ctx))
+	if err != nil {
+		return nil, err
+	}
+
 	pipeline, err := expr.Pipeline()
 	if err != nil {
 		return nil, err
@@ -557,6 +563,11 @@ func (s *LokiStore) SelectSamples(ctx context.Context, req logql.SelectSamplePar
 		return nil, err
 	}
 
+	expr, err = syntax.BindLookupTables(expr, s.lookupTables(ctx))
+	if err != nil {
+		return nil, err
+	}
+
 	extractor, err := expr.Extractor()
 	if err != nil {
 		return nil, err
@@ -584,6 +595,17 @@ func (s *LokiStore) SelectSamples(ctx context.Context, req logql.SelectSamplePar
 	return newSampleBatchIterator(ctx, s.schemaCfg, s.chunkMetrics, lazyChunks, s.cfg.MaxChunkBatchSize, matchers, extractor, req.Start, req.End, chunkFilterer)
 }
 
+// lookupTables returns a resolver of the lookup tables of the tenant of the request.
+func (s *LokiStore) lookupTables(ctx context.Context) syntax.LookupTableResolver {
+	return func(name string) (*lokilog.LookupTable, bool) {
+		userID, err := tenant.TenantID(ctx)
+		if err != nil {
+			return nil, false
+		}
+		return s.limits.LookupTable(userID, name)
+	}
+}
+
 func (s *LokiStore) GetSchemaConfigs() []config.PeriodConfig {
 	return s.schemaCfg.Configs
 }
@@ -54,7 +54,7 @@ func CreateSchema(cfg config.PeriodConfig) (SeriesStoreSchema, error) {
 			return newSeriesStoreSchema(buckets, v11Entries{v10}), nil
 		case "v12":
 			return newSeriesStoreSchema(buckets, v12Entries{v11Entries{v10}}), nil
-		case "v13":
+		case "v13", "v14":
 			return newSeriesStoreSchema(buckets, v13Entries{v12Entries{v11Entries{v10}}}), nil
 		}
 	}
@@ -0,0 +1,192 @@
+package zstddict
+
+import (
+	"context"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logql/log"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+)
+
+const (
+	// the samples are bounded to this multiple of the size of the dictionary.
+	samplesPerDictionaryByte = 100
+	// the dictionaries aren't trained from fewer samples.
+	minSamples = 100
+)
+
+var errNotEnoughSamples = errors.New("not enough log lines sampled")
+
+// trainAll trains a new dictionary for each tenant having chunks in the object storage.
+func (d *Dictionaries) trainAll(ctx context.Context) {
+	tenants, err := d.tenants(ctx)
+	if err != nil {
+		level.Warn(d.logger).Log("msg", "failed to list the tenants to train zstd dictionaries for", "err", err)
+		return
+	}
+	for _, tenant := range tenants {
+		if ctx.Err() != nil {
+			return
+		}
+		id, err := d.trainTenant(ctx, tenant)
+		switch {
+		case errors.Is(err, errNotEnoughSamples):
+			d.trainings.WithLabelValues("skipped").Inc()
+			level.Debug(d.logger).Log("msg", "not training zstd dictionary", "tenant", tenant, "err", err)
+		case err != nil:
+			d.trainings.WithLabelValues("failure").Inc()
+			level.Warn(d.logger).Log("msg", "failed to train zstd dictionary", "tenant", tenant, "err", err)
+		default:
+			d.trainings.WithLabelValues("success").Inc()
+			level.Info(d.logger).Log("msg", "trained zstd dictionary", "tenant", tenant, "id", id)
+		}
+	}
+}
+
+// tenants lists the top level prefixes of the object storage, where the chunks of the tenants are stored.
+// The prefixes which aren't tenants have no chunks to sample, so no dictionary is trained for them.
+func (d *Dictionaries) tenants(ctx context.Context) ([]string, error) {
+	_, prefixes, err := d.client.List(ctx, "", "/")
+	if err != nil {
+		return nil, err
+	}
+	tenants := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		if string(p) == Prefix {
+			continue
+		}
+		tenants = append(tenants, strings.TrimSuffix(string(p), "/"))
+	}
+	return tenants, nil
+}
+
+func (d *Dictionaries) trainTenant(ctx context.Context, tenant string) (uint32, error) {
+	keys, err := d.sampleChunks(ctx, tenant)
+	if err != nil {
+		return 0, errors.Wrap(err, "sampling chunks")
+	}
+
+	maxSamplesSize := int(d.cfg.MaxSize) * samplesPerDictionaryByte
+	var (
+		samples     [][]byte
+		samplesSize int
+	)
+	decodeContext := chunk.NewDecodeContext()
+	for _, k := range keys {
+		lines, err := d.readChunk(ctx, decodeContext, tenant, k, maxSamplesSize-samplesSize)
+		if err != nil {
+			level.Debug(d.logger).Log("msg", "ignoring chunk", "key", k, "err", err)
+			continue
+		}
+		for _, l := range lines {
+			samples = append(samples, l)
+			samplesSize += len(l)
+		}
+		if samplesSize >= maxSamplesSize {
+			break
+		}
+	}
+	if len(samples) < minSamples {
+		return 0, errNotEnoughSamples
+	}
+
+	id := d.newID()
+	dict, err := chunkenc.TrainZstdDictionary(id, samples, int(d.cfg.MaxSize))
+	if err != nil {
+		return 0, err
+	}
+	return id, d.put(ctx, tenant, id, dict)
+}
+
+// sampleChunks returns the keys of the most recent chunk of up to SampledChunks random streams of the tenant.
+func (d *Dictionaries) sampleChunks(ctx context.Context, tenant string) ([]string, error) {
+	objects, prefixes, err := d.client.List(ctx, tenant+"/", "/")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prefixes) == 0 {
+		// the chunk keys of the schemas before v12 aren't prefixed by the fingerprint of their stream.
+		return mostRecent(objects, d.cfg.SampledChunks), nil
+	}
+
+	rand.Shuffle(len(prefixes), func(i, j int) { prefixes[i], prefixes[j] = prefixes[j], prefixes[i] })
+	if len(prefixes) > d.cfg.SampledChunks {
+		prefixes = prefixes[:d.cfg.SampledChunks]
+	}
+	keys := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		objects, _, err := d.client.List(ctx, string(p), "")
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, mostRecent(objects, 1)...)
+	}
+	return keys, nil
+}
+
+func mostRecent(objects []client.StorageObject, n int) []string {
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].ModifiedAt.After(objects[j].ModifiedAt)
+	})
+	if len(objects) > n {
+		objects = objects[:n]
+	}
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		keys = append(keys, obj.Key)
+	}
+	return keys
+}
+
+// readChunk returns up to maxSize bytes of log lines from a chunk.
+func (d *Dictionaries) readChunk(ctx context.Context, decodeContext *chunk.DecodeContext, tenant, key string, maxSize int) ([][]byte, error) {
+	c, err := chunk.ParseExternalKey(tenant, key)
+	if err != nil {
+		return nil, err
+	}
+	reader, _, err := d.client.GetObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	buf, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Decode(decodeContext, buf); err != nil {
+		return nil, err
+	}
+	facade, ok := c.Data.(*chunkenc.Facade)
+	if !ok {
+		return nil, errors.New("not a log chunk")
+	}
+
+	it, err := facade.LokiChunk().Iterator(ctx, time.Unix(0, 0), time.Unix(0, math.MaxInt64), logproto.FORWARD, log.NewNoopPipeline().ForStream(c.Metric))
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var (
+		lines [][]byte
+		size  int
+	)
+	for size < maxSize && it.Next() {
+		line := it.At().Line
+		lines = append(lines, []byte(line))
+		size += len(line)
+	}
+	return lines, it.Err()
+}
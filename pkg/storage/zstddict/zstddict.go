@@ -0,0 +1,383 @@
+// Package zstddict stores the zstd dictionaries the chunks of the zstd-dict encoding are compressed with.
+// The dictionaries are trained per tenant by the compactor from sampled chunks, stored in the object
+// storage, and loaded by the components reading or writing chunks when they use them.
+package zstddict
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client"
+	"github.com/grafana/loki/v3/pkg/util/constants"
+	"github.com/grafana/loki/v3/pkg/util/flagext"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+// Prefix is the object storage prefix the dictionaries are stored under, as <Prefix><id>, so that they can be loaded
+// from their ID only. Each dictionary is listed in its tenant by an empty object, <Prefix><tenant>/<id>.
+const Prefix = "zstd-dictionaries/"
+
+// fetchTimeout is the timeout of the loads of the dictionaries from the object storage.
+const fetchTimeout = time.Minute
+
+const (
+	// the IDs below 32768 are reserved by the zstd format, as well as the ones from 2^31.
+	minID = 1 << 15
+	maxID = 1 << 31
+)
+
+type Config struct {
+	Enabled          bool             `yaml:"enabled"`
+	SyncInterval     time.Duration    `yaml:"sync_interval"`
+	MaxLoaded        int              `yaml:"max_loaded"`
+	TrainingInterval time.Duration    `yaml:"training_interval"`
+	SampledChunks    int              `yaml:"sampled_chunks"`
+	MaxSize          flagext.ByteSize `yaml:"max_size"`
+	DeletionDelay    time.Duration    `yaml:"deletion_delay"`
+
+	// RetentionEnabled tells if the compactor deletes the chunks past the retention period of their tenant,
+	// and MaxChunkAge is the maximum age of the chunks of the ingesters.
+	RetentionEnabled bool          `yaml:"-"`
+	MaxChunkAge      time.Duration `yaml:"-"`
+}
+
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "Experimental: Load the zstd dictionaries the chunks of the zstd-dict encoding are compressed with from the object storage, and train them per tenant in the compactor. The chunks are compressed without dictionary while this is disabled, and can't be read if they were compressed with one.")
+	f.DurationVar(&cfg.SyncInterval, prefix+"sync-interval", time.Minute, "How often the zstd dictionaries are listed from the object storage. The dictionaries are only loaded when a chunk compressed with them is read or written.")
+	f.IntVar(&cfg.MaxLoaded, prefix+"max-loaded", 256, "Maximum number of zstd dictionaries loaded in memory. The least recently used ones are evicted, and loaded again when they are needed.")
+	f.DurationVar(&cfg.TrainingInterval, prefix+"training-interval", 24*time.Hour, "How often the compactor trains a new zstd dictionary for each tenant.")
+	f.IntVar(&cfg.SampledChunks, prefix+"sampled-chunks", 100, "Number of recent chunks of a tenant, from distinct streams, the log lines used to train its zstd dictionary are sampled from.")
+	_ = cfg.MaxSize.Set("64KB")
+	f.Var(&cfg.MaxSize, prefix+"max-size", "Maximum size of the zstd dictionaries.")
+	f.DurationVar(&cfg.DeletionDelay, prefix+"deletion-delay", 24*time.Hour, "When the compactor retention is enabled, a zstd dictionary replaced by a newer one is deleted once the chunks compressed with it are past the retention period of its tenant, which is when the retention period, the maximum chunk age of the ingesters, the sync interval and this delay have passed since it was replaced. The dictionaries of the tenants without retention period are never deleted.")
+}
+
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.SyncInterval <= 0 {
+		return errors.New("the zstd dictionaries sync interval must be greater than 0")
+	}
+	if cfg.MaxLoaded <= 0 {
+		return errors.New("the maximum number of loaded zstd dictionaries must be greater than 0")
+	}
+	if cfg.DeletionDelay < 0 {
+		return errors.New("the zstd dictionaries deletion delay must not be negative")
+	}
+	if cfg.TrainingInterval <= 0 {
+		return errors.New("the zstd dictionaries training interval must be greater than 0")
+	}
+	if cfg.SampledChunks <= 0 {
+		return errors.New("the number of chunks sampled to train the zstd dictionaries must be greater than 0")
+	}
+	if cfg.MaxSize < 1<<10 {
+		return errors.New("the maximum size of the zstd dictionaries must be at least 1KB")
+	}
+	return nil
+}
+
+// Limits are the retention limits of the tenants.
+type Limits interface {
+	RetentionPeriod(userID string) time.Duration
+	StreamRetention(userID string) []validation.StreamRetention
+}
+
+// Dictionaries lists the zstd dictionaries of the object storage, loads them into chunkenc.ZstdDictionaries
+// when they are used, and trains new ones and deletes the unused ones when it runs in the compactor.
+type Dictionaries struct {
+	services.Service
+
+	cfg      Config
+	client   client.ObjectClient
+	train    bool
+	limits   Limits
+	registry *chunkenc.ZstdDictionaryRegistry
+	logger   log.Logger
+
+	mtx          sync.RWMutex
+	dictionaries map[string][]dictionary // the dictionaries stored for each tenant, the latest last.
+	byID         map[uint32]dictionary
+
+	loaded    prometheus.Gauge
+	trainings *prometheus.CounterVec
+	deleted   prometheus.Counter
+}
+
+type dictionary struct {
+	id         uint32
+	key        string // the key listing the dictionary in its tenant.
+	modifiedAt time.Time
+}
+
+// New creates the service listing the dictionaries, training and deleting them if train is set.
+func New(cfg Config, objectClient client.ObjectClient, train bool, limits Limits, logger log.Logger, reg prometheus.Registerer) *Dictionaries {
+	d := &Dictionaries{
+		cfg:          cfg,
+		client:       objectClient,
+		train:        train,
+		limits:       limits,
+		registry:     chunkenc.ZstdDictionaries,
+		logger:       logger,
+		dictionaries: map[string][]dictionary{},
+		byID:         map[uint32]dictionary{},
+
+		loaded: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Namespace: constants.Loki,
+			Name:      "zstd_dictionaries_loaded",
+			Help:      "Number of zstd dictionaries loaded in memory.",
+		}),
+		trainings: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "zstd_dictionary_trainings_total",
+			Help:      "Total number of zstd dictionaries trained, by status.",
+		}, []string{"status"}),
+		deleted: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace: constants.Loki,
+			Name:      "zstd_dictionaries_deleted_total",
+			Help:      "Total number of zstd dictionaries deleted because no chunk can be compressed with them anymore.",
+		}),
+	}
+	d.registry.SetLoader(cfg.MaxLoaded, d.fetch)
+	d.Service = services.NewBasicService(d.starting, d.running, nil)
+	return d
+}
+
+// Latest returns the ID of the last dictionary of the tenant, or 0 if it has none.
+func (d *Dictionaries) Latest(tenant string) uint32 {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	dicts := d.dictionaries[tenant]
+	if len(dicts) == 0 {
+		return 0
+	}
+	return dicts[len(dicts)-1].id
+}
+
+func (d *Dictionaries) starting(ctx context.Context) error {
+	return d.sync(ctx)
+}
+
+func (d *Dictionaries) running(ctx context.Context) error {
+	syncTicker := time.NewTicker(d.cfg.SyncInterval)
+	defer syncTicker.Stop()
+
+	var trainC <-chan time.Time
+	if d.train {
+		trainTicker := time.NewTicker(d.cfg.TrainingInterval)
+		defer trainTicker.Stop()
+		trainC = trainTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-syncTicker.C:
+			if err := d.sync(ctx); err != nil {
+				level.Warn(d.logger).Log("msg", "failed to list the zstd dictionaries", "err", err)
+			}
+		case <-trainC:
+			d.trainAll(ctx)
+			if d.cfg.RetentionEnabled {
+				d.applyRetention(ctx, time.Now())
+			}
+		}
+	}
+}
+
+// sync lists the dictionaries of the object storage. They are loaded when they are used.
+func (d *Dictionaries) sync(ctx context.Context) error {
+	objects, _, err := d.client.List(ctx, Prefix, "")
+	if err != nil {
+		return errors.Wrap(err, "listing zstd dictionaries")
+	}
+
+	tenants := map[string][]dictionary{}
+	byID := make(map[uint32]dictionary, len(objects))
+	for _, obj := range objects {
+		if !strings.Contains(strings.TrimPrefix(obj.Key, Prefix), "/") {
+			// the dictionaries are listed by the keys of their tenant.
+			continue
+		}
+		tenant, id, err := parseKey(obj.Key)
+		if err != nil {
+			level.Warn(d.logger).Log("msg", "ignoring object in the zstd dictionaries prefix", "key", obj.Key, "err", err)
+			continue
+		}
+		dict := dictionary{id: id, key: obj.Key, modifiedAt: obj.ModifiedAt}
+		tenants[tenant] = append(tenants[tenant], dict)
+		byID[id] = dict
+	}
+	for _, dicts := range tenants {
+		sort.Slice(dicts, func(i, j int) bool {
+			if dicts[i].modifiedAt.Equal(dicts[j].modifiedAt) {
+				return dicts[i].key < dicts[j].key
+			}
+			return dicts[i].modifiedAt.Before(dicts[j].modifiedAt)
+		})
+	}
+
+	d.mtx.Lock()
+	d.dictionaries = tenants
+	d.byID = byID
+	d.mtx.Unlock()
+	d.loaded.Set(float64(d.registry.Len()))
+	return nil
+}
+
+// fetch reads a dictionary from the object storage. It is the loader of the registry.
+// The dictionaries stored since the last sync are fetched as well, since their key only depends on their ID.
+func (d *Dictionaries) fetch(id uint32) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	reader, _, err := d.client.GetObject(ctx, dictionaryKey(id))
+	if err != nil {
+		if d.client.IsObjectNotFoundErr(err) {
+			return nil, chunkenc.ErrUnknownZstdDictionary(id)
+		}
+		return nil, err
+	}
+	defer reader.Close()
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (d *Dictionaries) dictionary(id uint32) (dictionary, bool) {
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	dict, ok := d.byID[id]
+	return dict, ok
+}
+
+// put stores and registers a new dictionary of the tenant. The dictionary is stored before it is listed
+// in the tenant, so that the listed dictionaries can always be fetched.
+func (d *Dictionaries) put(ctx context.Context, tenant string, id uint32, b []byte) error {
+	if err := d.client.PutObject(ctx, dictionaryKey(id), bytes.NewReader(b)); err != nil {
+		return err
+	}
+	k := key(tenant, id)
+	if err := d.client.PutObject(ctx, k, bytes.NewReader(nil)); err != nil {
+		return err
+	}
+	if _, err := d.registry.Register(b); err != nil {
+		return err
+	}
+
+	dict := dictionary{id: id, key: k, modifiedAt: time.Now()}
+	d.mtx.Lock()
+	d.dictionaries[tenant] = append(d.dictionaries[tenant], dict)
+	d.byID[id] = dict
+	d.mtx.Unlock()
+	return nil
+}
+
+// applyRetention deletes the dictionaries no chunk can be compressed with anymore. A dictionary replaced by a newer one
+// is used by the chunks created until the ingesters sync the newer one, whose entries are at most the maximum chunk age
+// more recent. It can be deleted once these chunks are past the retention period of the tenant.
+// The latest dictionary of a tenant is never deleted.
+func (d *Dictionaries) applyRetention(ctx context.Context, now time.Time) {
+	d.mtx.RLock()
+	var expired []dictionary
+	for tenant, dicts := range d.dictionaries {
+		retention := d.retentionPeriod(tenant)
+		if retention <= 0 {
+			continue
+		}
+		for i := 0; i < len(dicts)-1; i++ {
+			replacedAt := dicts[i+1].modifiedAt
+			if now.Sub(replacedAt) > retention+d.cfg.MaxChunkAge+d.cfg.SyncInterval+d.cfg.DeletionDelay {
+				expired = append(expired, dicts[i])
+			}
+		}
+	}
+	d.mtx.RUnlock()
+
+	for _, dict := range expired {
+		// the dictionary is no longer listed in the tenant before it is deleted.
+		if err := d.client.DeleteObject(ctx, dict.key); err != nil && !d.client.IsObjectNotFoundErr(err) {
+			level.Warn(d.logger).Log("msg", "failed to delete zstd dictionary", "key", dict.key, "err", err)
+			continue
+		}
+		if err := d.client.DeleteObject(ctx, dictionaryKey(dict.id)); err != nil && !d.client.IsObjectNotFoundErr(err) {
+			level.Warn(d.logger).Log("msg", "failed to delete zstd dictionary", "key", dictionaryKey(dict.id), "err", err)
+			continue
+		}
+		d.deleted.Inc()
+		level.Info(d.logger).Log("msg", "deleted zstd dictionary", "key", dict.key)
+	}
+	if len(expired) > 0 {
+		if err := d.sync(ctx); err != nil {
+			level.Warn(d.logger).Log("msg", "failed to list the zstd dictionaries", "err", err)
+		}
+	}
+}
+
+// retentionPeriod returns the longest retention period of the streams of a tenant, or 0 if some are kept forever.
+func (d *Dictionaries) retentionPeriod(tenant string) time.Duration {
+	retention := d.limits.RetentionPeriod(tenant)
+	if retention <= 0 {
+		return 0
+	}
+	for _, streamRetention := range d.limits.StreamRetention(tenant) {
+		period := time.Duration(streamRetention.Period)
+		if period <= 0 {
+			return 0
+		}
+		retention = max(retention, period)
+	}
+	return retention
+}
+
+// newID returns a dictionary ID which isn't used yet.
+func (d *Dictionaries) newID() uint32 {
+	for {
+		id := uint32(minID + rand.Int63n(maxID-minID))
+		if _, ok := d.dictionary(id); !ok && !d.registry.Has(id) {
+			return id
+		}
+	}
+}
+
+// key returns the key listing a dictionary in its tenant.
+func key(tenant string, id uint32) string {
+	return fmt.Sprintf("%s%s/%08x", Prefix, tenant, id)
+}
+
+// dictionaryKey returns the key of the content of a dictionary.
+func dictionaryKey(id uint32) string {
+	return fmt.Sprintf("%s%08x", Prefix, id)
+}
+
+func parseKey(k string) (string, uint32, error) {
+	tenant, id, ok := strings.Cut(strings.TrimPrefix(k, Prefix), "/")
+	if !ok || tenant == "" {
+		return "", 0, errors.New("invalid zstd dictionary key")
+	}
+	v, err := strconv.ParseUint(id, 16, 32)
+	if err != nil {
+		return "", 0, errors.Wrap(err, "invalid zstd dictionary ID")
+	}
+	return tenant, uint32(v), nil
+}
@@ -0,0 +1,167 @@
+package zstddict
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/chunkenc"
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/storage/chunk"
+	"github.com/grafana/loki/v3/pkg/storage/chunk/client/local"
+	"github.com/grafana/loki/v3/pkg/storage/config"
+	"github.com/grafana/loki/v3/pkg/validation"
+)
+
+var testSchema = config.SchemaConfig{Configs: []config.PeriodConfig{{
+	From:      config.DayTime{Time: 0},
+	Schema:    "v13",
+	RowShards: 16,
+}}}
+
+func testLine(stream, i int) string {
+	return fmt.Sprintf(`level=info ts=2024-06-01T10:00:%02d.%03dZ caller=handler.go:%d msg="request served" stream=%d path=/api/v1/users/%d status=200`, i%60, i%1000, 100+i%7, stream, i)
+}
+
+func putTestChunk(t *testing.T, objectClient *local.FSObjectClient, tenant string, stream int) {
+	t.Helper()
+	lbs := labels.FromStrings("app", "api", "stream", fmt.Sprint(stream))
+	memChunk := chunkenc.NewMemChunk(chunkenc.ChunkFormatV4, chunkenc.EncSnappy, chunkenc.UnorderedWithStructuredMetadataHeadBlockFmt, 256*1024, 0)
+	for i := 0; i < 200; i++ {
+		_, err := memChunk.Append(&logproto.Entry{Timestamp: time.Unix(0, int64(i+1)), Line: testLine(stream, i)})
+		require.NoError(t, err)
+	}
+	require.NoError(t, memChunk.Close())
+
+	from, through := memChunk.Bounds()
+	c := chunk.NewChunk(tenant, model.Fingerprint(lbs.Hash()), lbs, chunkenc.NewFacade(memChunk, 0, 0), model.TimeFromUnixNano(from.UnixNano()), model.TimeFromUnixNano(through.UnixNano()))
+	require.NoError(t, c.Encode())
+	encoded, err := c.Encoded()
+	require.NoError(t, err)
+	require.NoError(t, objectClient.PutObject(context.Background(), testSchema.ExternalKey(c.ChunkRef), bytes.NewReader(encoded)))
+}
+
+func TestDictionaries(t *testing.T) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	for stream := 0; stream < 3; stream++ {
+		putTestChunk(t, objectClient, "tenant-a", stream)
+	}
+	// a tenant without enough log lines to train a dictionary.
+	require.NoError(t, objectClient.PutObject(context.Background(), "tenant-b/not-a-chunk", bytes.NewReader([]byte("data"))))
+
+	cfg := Config{Enabled: true, SyncInterval: time.Minute, MaxLoaded: 10, TrainingInterval: time.Hour, SampledChunks: 10, MaxSize: 4 << 10}
+	require.NoError(t, cfg.Validate())
+
+	trainer := New(cfg, objectClient, true, fakeLimits{}, log.NewNopLogger(), prometheus.NewRegistry())
+	trainer.trainAll(context.Background())
+	require.Equal(t, 1.0, testutil.ToFloat64(trainer.trainings.WithLabelValues("success")))
+	require.Equal(t, 1.0, testutil.ToFloat64(trainer.trainings.WithLabelValues("skipped")))
+
+	id := trainer.Latest("tenant-a")
+	require.GreaterOrEqual(t, id, uint32(minID))
+	require.Zero(t, trainer.Latest("tenant-b"))
+	require.True(t, chunkenc.ZstdDictionaries.Has(id))
+
+	// the other components load the dictionary from the object storage when they use it.
+	loader := New(cfg, objectClient, false, fakeLimits{}, log.NewNopLogger(), prometheus.NewRegistry())
+	loader.registry = chunkenc.NewZstdDictionaryRegistry()
+	loader.registry.SetLoader(cfg.MaxLoaded, loader.fetch)
+	require.False(t, loader.registry.Has(id))
+	require.NotEqual(t, &chunkenc.ZstdDict, loader.registry.WriterPool(id), "the dictionaries are fetched by ID, even before they are synced")
+	require.True(t, loader.registry.Has(id))
+	require.NoError(t, loader.sync(context.Background()))
+	require.Equal(t, id, loader.Latest("tenant-a"))
+
+	_, err = loader.fetch(id + 1)
+	require.ErrorIs(t, err, chunkenc.ErrUnknownZstdDictionary(id+1))
+}
+
+type fakeLimits struct {
+	retention       time.Duration
+	streamRetention []validation.StreamRetention
+}
+
+func (f fakeLimits) RetentionPeriod(_ string) time.Duration {
+	return f.retention
+}
+
+func (f fakeLimits) StreamRetention(_ string) []validation.StreamRetention {
+	return f.streamRetention
+}
+
+func TestDictionaries_Retention(t *testing.T) {
+	objectClient, err := local.NewFSObjectClient(local.FSConfig{Directory: t.TempDir()})
+	require.NoError(t, err)
+	cfg := Config{SyncInterval: time.Minute, MaxLoaded: 10, DeletionDelay: time.Hour, RetentionEnabled: true, MaxChunkAge: time.Hour}
+	d := New(cfg, objectClient, true, tenantLimits{
+		"tenant":  fakeLimits{retention: 24 * time.Hour, streamRetention: []validation.StreamRetention{{Period: model.Duration(48 * time.Hour)}}},
+		"forever": fakeLimits{},
+	}, log.NewNopLogger(), prometheus.NewRegistry())
+	for i, tenant := range []string{"tenant", "tenant", "tenant", "forever", "forever"} {
+		id := uint32(minID + i)
+		require.NoError(t, objectClient.PutObject(context.Background(), dictionaryKey(id), bytes.NewReader([]byte("dictionary"))))
+		require.NoError(t, objectClient.PutObject(context.Background(), key(tenant, id), bytes.NewReader(nil)))
+	}
+
+	require.NoError(t, d.sync(context.Background()))
+
+	// the dictionaries were replaced at the time of the next one.
+	now := time.Now()
+	d.mtx.Lock()
+	for tenant, dicts := range d.dictionaries {
+		for i := range dicts {
+			dicts[i].modifiedAt = now.Add(-time.Duration(len(dicts)-i) * 48 * time.Hour)
+		}
+		d.dictionaries[tenant] = dicts
+	}
+	d.mtx.Unlock()
+
+	// the chunks of the first dictionary of the tenant are past the longest retention period of its streams,
+	// the chunks of the second one aren't, and the latest dictionary is never deleted.
+	d.applyRetention(context.Background(), now.Add(time.Hour))
+	require.Equal(t, 1.0, testutil.ToFloat64(d.deleted))
+	require.Equal(t, []uint32{minID + 1, minID + 2}, ids(d.dictionaries["tenant"]))
+	require.Equal(t, []uint32{minID + 3, minID + 4}, ids(d.dictionaries["forever"]), "the dictionaries of the tenants without retention are kept")
+	_, err = d.fetch(minID)
+	require.ErrorIs(t, err, chunkenc.ErrUnknownZstdDictionary(minID), "the content of the deleted dictionary is deleted")
+}
+
+type tenantLimits map[string]fakeLimits
+
+func (l tenantLimits) RetentionPeriod(tenant string) time.Duration {
+	return l[tenant].RetentionPeriod(tenant)
+}
+
+func (l tenantLimits) StreamRetention(tenant string) []validation.StreamRetention {
+	return l[tenant].StreamRetention(tenant)
+}
+
+func ids(dicts []dictionary) []uint32 {
+	res := make([]uint32, 0, len(dicts))
+	for _, dict := range dicts {
+		res = append(res, dict.id)
+	}
+	return res
+}
+
+func TestParseKey(t *testing.T) {
+	tenant, id, err := parseKey(key("tenant", 123456))
+	require.NoError(t, err)
+	require.Equal(t, "tenant", tenant)
+	require.Equal(t, uint32(123456), id)
+
+	_, _, err = parseKey(Prefix + "tenant")
+	require.Error(t, err)
+	_, _, err = parseKey(Prefix + "tenant/not-hex")
+	require.Error(t, err)
+}
@@ -23,6 +23,7 @@ type CombinedLimits interface {
 	queryrange_limits.Limits
 	ruler.RulesLimits
 	scheduler_limits.Limits
+	scheduler_limits.PriorityLimits
 	storage.StoreLimits
 	indexgateway.Limits
 	bloomgateway.Limits
@@ -407,45 +407,50 @@ func encodeStream(stream logproto.Stream, s *jsoniter.Stream, encodeFlags httpre
 		if i > 0 {
 			s.WriteMore()
 		}
+		encodeEntry(e, s, categorizeLabels)
+	}
 
-		s.WriteArrayStart()
-		s.WriteRaw(`"`)
-		s.WriteRaw(strconv.FormatInt(e.Timestamp.UnixNano(), 10))
-		s.WriteRaw(`"`)
+	s.WriteArrayEnd()
+
+	return nil
+}
+
+// encodeEntry encodes a logproto.Entry as an array of its timestamp and line,
+// followed by its structured metadata and parsed labels if categorizeLabels is set.
+func encodeEntry(e logproto.Entry, s *jsoniter.Stream, categorizeLabels bool) {
+	s.WriteArrayStart()
+	s.WriteRaw(`"`)
+	s.WriteRaw(strconv.FormatInt(e.Timestamp.UnixNano(), 10))
+	s.WriteRaw(`"`)
+	s.WriteMore()
+	s.WriteStringWithHTMLEscaped(e.Line)
+
+	if categorizeLabels {
 		s.WriteMore()
-		s.WriteStringWithHTMLEscaped(e.Line)
+		s.WriteObjectStart()
 
-		if categorizeLabels {
-			s.WriteMore()
+		var writeMore bool
+		if len(e.StructuredMetadata) > 0 {
+			s.WriteObjectField("structuredMetadata")
 			s.WriteObjectStart()
+			encodeLabels(e.StructuredMetadata, s)
+			s.WriteObjectEnd()
+			writeMore = true
+		}
 
-			var writeMore bool
-			if len(e.StructuredMetadata) > 0 {
-				s.WriteObjectField("structuredMetadata")
-				s.WriteObjectStart()
-				encodeLabels(e.StructuredMetadata, s)
-				s.WriteObjectEnd()
-				writeMore = true
-			}
-
-			if len(e.Parsed) > 0 {
-				if writeMore {
-					s.WriteMore()
-				}
-				s.WriteObjectField("parsed")
-				s.WriteObjectStart()
-				encodeLabels(e.Parsed, s)
-				s.WriteObjectEnd()
+		if len(e.Parsed) > 0 {
+			if writeMore {
+				s.WriteMore()
 			}
-
+			s.WriteObjectField("parsed")
+			s.WriteObjectStart()
+			encodeLabels(e.Parsed, s)
 			s.WriteObjectEnd()
 		}
-		s.WriteArrayEnd()
-	}
 
+		s.WriteObjectEnd()
+	}
 	s.WriteArrayEnd()
-
-	return nil
 }
 
 func encodeScalar(v promql.Scalar, s *jsoniter.Stream) {
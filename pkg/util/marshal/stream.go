@@ -0,0 +1,155 @@
+package marshal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+	"github.com/grafana/loki/v3/pkg/util/httpreq"
+)
+
+const (
+	// NDJSONContentType is the content type of streamed query responses.
+	NDJSONContentType = "application/x-ndjson"
+
+	// maxEntriesPerLine is the maximum number of entries of a stream written on a single line.
+	maxEntriesPerLine = 100
+	// flushSize is the size of the buffered lines above which they are flushed to the client.
+	flushSize = 64 << 10
+)
+
+// NDJSONQueryWriter writes the entries of a streamed log query as newline
+// delimited JSON.
+//
+// Each line holds consecutive entries of a single stream encoded like the
+// streams of the v1 query response:
+//
+//	{"stream":{"app":"foo"},"values":[["1700000000000000000","line"]]}
+//
+// The last line holds the status of the query and its statistics, or its error:
+//
+//	{"status":"success","stats":{...}}
+//	{"status":"error","error":"..."}
+type NDJSONQueryWriter struct {
+	s        *jsoniter.Stream
+	flusher  http.Flusher
+	encFlags httpreq.EncodingFlags
+
+	labels  string
+	entries int // number of entries written on the current line
+	flushed bool
+}
+
+// NewNDJSONQueryWriter creates a writer of streamed query responses to w.
+// Lines are buffered until they are large enough, then written to w and
+// flushed to the client if w is a http.Flusher.
+func NewNDJSONQueryWriter(w io.Writer, encodeFlags httpreq.EncodingFlags) *NDJSONQueryWriter {
+	flusher, _ := w.(http.Flusher)
+	return &NDJSONQueryWriter{
+		s:        jsoniter.ConfigFastest.BorrowStream(w),
+		flusher:  flusher,
+		encFlags: encodeFlags,
+	}
+}
+
+// WriteEntry writes an entry of the stream with the given labels.
+func (w *NDJSONQueryWriter) WriteEntry(labels string, entry logproto.Entry) error {
+	if w.entries > 0 && (w.labels != labels || w.entries == maxEntriesPerLine) {
+		if err := w.endLine(); err != nil {
+			return err
+		}
+	}
+
+	if w.entries == 0 {
+		lbls, err := parser.ParseMetric(labels)
+		if err != nil {
+			return err
+		}
+		w.labels = labels
+		w.s.WriteObjectStart()
+		w.s.WriteObjectField("stream")
+		w.s.WriteObjectStart()
+		encodeLabels(logproto.FromLabelsToLabelAdapters(lbls), w.s)
+		w.s.WriteObjectEnd()
+		w.s.WriteMore()
+		w.s.WriteObjectField("values")
+		w.s.WriteArrayStart()
+	} else {
+		w.s.WriteMore()
+	}
+	encodeEntry(entry, w.s, w.encFlags.Has(httpreq.FlagCategorizeLabels))
+	w.entries++
+	return w.s.Error
+}
+
+// endLine terminates the current line and flushes the lines written so far
+// if they are large enough.
+func (w *NDJSONQueryWriter) endLine() error {
+	w.s.WriteArrayEnd()
+	w.s.WriteObjectEnd()
+	w.s.WriteRaw("\n")
+	w.entries = 0
+	if w.s.Buffered() < flushSize {
+		return w.s.Error
+	}
+	return w.flush()
+}
+
+// Flushed tells if some lines were already flushed to the client.
+func (w *NDJSONQueryWriter) Flushed() bool {
+	return w.flushed
+}
+
+func (w *NDJSONQueryWriter) flush() error {
+	w.flushed = true
+	if err := w.s.Flush(); err != nil {
+		return err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return nil
+}
+
+// Close writes the last line with the status of the query and flushes the
+// response. The writer must not be used afterwards.
+func (w *NDJSONQueryWriter) Close(result logqlmodel.Result, queryErr error) error {
+	defer jsoniter.ConfigFastest.ReturnStream(w.s)
+
+	if w.entries > 0 {
+		if err := w.endLine(); err != nil {
+			return err
+		}
+	}
+
+	w.s.WriteObjectStart()
+	w.s.WriteObjectField("status")
+	if queryErr != nil {
+		w.s.WriteString("error")
+		w.s.WriteMore()
+		w.s.WriteObjectField("error")
+		w.s.WriteString(queryErr.Error())
+	} else {
+		w.s.WriteString("success")
+		if len(result.Warnings) > 0 {
+			w.s.WriteMore()
+			w.s.WriteObjectField("warnings")
+			w.s.WriteVal(result.Warnings)
+		}
+		w.s.WriteMore()
+		w.s.WriteObjectField("stats")
+		w.s.WriteVal(result.Statistics)
+	}
+	w.s.WriteObjectEnd()
+	w.s.WriteRaw("\n")
+
+	if err := w.flush(); err != nil {
+		return fmt.Errorf("could not write streamed query response: %w", err)
+	}
+	return nil
+}
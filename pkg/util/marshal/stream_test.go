@@ -0,0 +1,73 @@
+package marshal
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/loki/v3/pkg/logproto"
+	"github.com/grafana/loki/v3/pkg/logqlmodel"
+	"github.com/grafana/loki/v3/pkg/logqlmodel/stats"
+)
+
+func Test_NDJSONQueryWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONQueryWriter(&buf, nil)
+
+	require.NoError(t, w.WriteEntry(`{app="foo"}`, logproto.Entry{Timestamp: time.Unix(0, 1), Line: "a"}))
+	require.NoError(t, w.WriteEntry(`{app="foo"}`, logproto.Entry{Timestamp: time.Unix(0, 2), Line: "b"}))
+	require.NoError(t, w.WriteEntry(`{app="bar"}`, logproto.Entry{Timestamp: time.Unix(0, 3), Line: "c"}))
+	require.NoError(t, w.WriteEntry(`{app="foo"}`, logproto.Entry{Timestamp: time.Unix(0, 4), Line: "d"}))
+	require.False(t, w.Flushed())
+	require.NoError(t, w.Close(logqlmodel.Result{Warnings: []string{"warning"}, Statistics: stats.Result{Summary: stats.Summary{TotalEntriesReturned: 4}}}, nil))
+	require.True(t, w.Flushed())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, `{"stream":{"app":"foo"},"values":[["1","a"],["2","b"]]}`, lines[0])
+	require.Equal(t, `{"stream":{"app":"bar"},"values":[["3","c"]]}`, lines[1])
+	require.Equal(t, `{"stream":{"app":"foo"},"values":[["4","d"]]}`, lines[2])
+	require.True(t, strings.HasPrefix(lines[3], `{"status":"success","warnings":["warning"],"stats":{`))
+	require.Contains(t, lines[3], `"totalEntriesReturned":4`)
+}
+
+func Test_NDJSONQueryWriter_MaxEntriesPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONQueryWriter(&buf, nil)
+
+	for i := 0; i < maxEntriesPerLine+1; i++ {
+		require.NoError(t, w.WriteEntry(`{app="foo"}`, logproto.Entry{Timestamp: time.Unix(0, int64(i)), Line: "line"}))
+	}
+	require.NoError(t, w.Close(logqlmodel.Result{}, nil))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, maxEntriesPerLine, strings.Count(lines[0], `"line"]`))
+	require.Equal(t, `{"stream":{"app":"foo"},"values":[["100","line"]]}`, lines[1])
+}
+
+func Test_NDJSONQueryWriter_Error(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONQueryWriter(&buf, nil)
+
+	require.NoError(t, w.WriteEntry(`{app="foo"}`, logproto.Entry{Timestamp: time.Unix(0, 1), Line: "a"}))
+	require.NoError(t, w.Close(logqlmodel.Result{}, errors.New("store unavailable")))
+	require.Equal(t, `{"stream":{"app":"foo"},"values":[["1","a"]]}`+"\n"+`{"status":"error","error":"store unavailable"}`+"\n", buf.String())
+}
+
+func Test_NDJSONQueryWriter_Flush(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewNDJSONQueryWriter(&buf, nil)
+
+	line := strings.Repeat("x", 1024)
+	for i := 0; buf.Len() == 0; i++ {
+		require.Less(t, i, 2*flushSize/len(line), "lines should have been flushed")
+		require.NoError(t, w.WriteEntry(`{app="foo"}`, logproto.Entry{Timestamp: time.Unix(0, int64(i)), Line: line}))
+	}
+	require.True(t, w.Flushed())
+	require.NoError(t, w.Close(logqlmodel.Result{}, nil))
+}
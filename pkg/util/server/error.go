@@ -83,7 +83,8 @@ func ClientHTTPStatusAndError(err error) (int, error) {
 		errors.Is(err, logqlmodel.ErrPipeline) ||
 		errors.Is(err, logqlmodel.ErrBlocked) ||
 		errors.Is(err, logqlmodel.ErrParseMatchers) ||
-		errors.Is(err, logqlmodel.ErrUnsupportedSyntaxForInstantQuery):
+		errors.Is(err, logqlmodel.ErrUnsupportedSyntaxForInstantQuery) ||
+		errors.Is(err, logqlmodel.ErrUnsupportedSyntaxForStreaming):
 		return http.StatusBadRequest, err
 	case errors.Is(err, user.ErrNoOrgID):
 		return http.StatusBadRequest, err
@@ -40,3 +40,16 @@ func NewWalReader(dir string, startSegment int) (*wlog.Reader, io.Closer, error)
 	}
 	return wlog.NewReader(segmentReader), segmentReader, nil
 }
+
+// NewWalRangeReader returns a reader of the segments from first to last, both included.
+func NewWalRangeReader(dir string, first, last int) (*wlog.Reader, io.Closer, error) {
+	segmentReader, err := wlog.NewSegmentsRangeReader(wlog.SegmentRange{
+		Dir:   dir,
+		First: first,
+		Last:  last,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return wlog.NewReader(segmentReader), segmentReader, nil
+}
@@ -21,10 +21,16 @@ import (
 
 	"github.com/grafana/loki/v3/pkg/chunkenc"
 	"github.com/grafana/loki/v3/pkg/compactor/deletionmode"
+	"github.com/grafana/loki/v3/pkg/distributor/cardinality"
+	"github.com/grafana/loki/v3/pkg/distributor/ingestionpipeline"
+	"github.com/grafana/loki/v3/pkg/distributor/redaction"
+	"github.com/grafana/loki/v3/pkg/distributor/sampling"
 	"github.com/grafana/loki/v3/pkg/distributor/shardstreams"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
 	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/logql/log"
 	"github.com/grafana/loki/v3/pkg/logql/syntax"
+	"github.com/grafana/loki/v3/pkg/queue"
 	ruler_config "github.com/grafana/loki/v3/pkg/ruler/config"
 	"github.com/grafana/loki/v3/pkg/ruler/util"
 	"github.com/grafana/loki/v3/pkg/storage/stores/shipper/indexshipper/tsdb/sharding"
@@ -83,6 +89,8 @@ type Limits struct {
 	MaxLineSize                 flagext.ByteSize `yaml:"max_line_size" json:"max_line_size"`
 	MaxLineSizeTruncate         bool             `yaml:"max_line_size_truncate" json:"max_line_size_truncate"`
 	IncrementDuplicateTimestamp bool             `yaml:"increment_duplicate_timestamp" json:"increment_duplicate_timestamp"`
+	DeduplicationWindow         model.Duration   `yaml:"deduplication_window" json:"deduplication_window"`
+	DeduplicationMaxKeys        int              `yaml:"deduplication_max_keys" json:"deduplication_max_keys"`
 	DiscoverServiceName         []string         `yaml:"discover_service_name" json:"discover_service_name"`
 	DiscoverLogLevels           bool             `yaml:"discover_log_levels" json:"discover_log_levels"`
 
@@ -94,6 +102,8 @@ type Limits struct {
 	PerStreamRateLimit      flagext.ByteSize `yaml:"per_stream_rate_limit" json:"per_stream_rate_limit"`
 	PerStreamRateLimitBurst flagext.ByteSize `yaml:"per_stream_rate_limit_burst" json:"per_stream_rate_limit_burst"`
 
+	OutOfOrderWindows []OutOfOrderWindow `yaml:"out_of_order_windows,omitempty" json:"out_of_order_windows,omitempty" doc:"description=Per-stream out-of-order windows enforced by the ingesters when unordered writes are enabled. The log lines of a stream older than its newest line by more than the window of the first rule whose selector matches the stream are discarded, with the too_far_behind_stream_window reason. A window of 0 enforces strict ordering. The streams matching no rule accept the log lines up to half of the ingester max_chunk_age behind their newest line, which is also the maximum window of the rules since older log lines could belong to chunks already flushed. The rules are read at each push, so that their changes apply to the existing streams.\nExample:\n out_of_order_windows:\n - selector: '{job=\"audit\"}'\n window: 0s\n - selector: '{source=\"batch\"}'\n window: 2h"`
+
 	// Querier enforced limits.
 	MaxChunksPerQuery          int              `yaml:"max_chunks_per_query" json:"max_chunks_per_query"`
 	MaxQuerySeries             int              `yaml:"max_query_series" json:"max_query_series"`
@@ -109,11 +119,13 @@ type Limits struct {
 	MaxStreamsMatchersPerQuery int              `yaml:"max_streams_matchers_per_query" json:"max_streams_matchers_per_query"`
 	MaxConcurrentTailRequests  int              `yaml:"max_concurrent_tail_requests" json:"max_concurrent_tail_requests"`
 	MaxEntriesLimitPerQuery    int              `yaml:"max_entries_limit_per_query" json:"max_entries_limit_per_query"`
+	MaxStreamedEntriesLimit    int              `yaml:"max_streamed_entries_limit_per_query" json:"max_streamed_entries_limit_per_query"`
 	MaxCacheFreshness          model.Duration   `yaml:"max_cache_freshness_per_query" json:"max_cache_freshness_per_query"`
 	MaxMetadataCacheFreshness  model.Duration   `yaml:"max_metadata_cache_freshness" json:"max_metadata_cache_freshness"`
 	MaxStatsCacheFreshness     model.Duration   `yaml:"max_stats_cache_freshness" json:"max_stats_cache_freshness"`
 	MaxQueriersPerTenant       uint             `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
 	MaxQueryCapacity           float64          `yaml:"max_query_capacity" json:"max_query_capacity"`
+	MaxQueryPriority           string           `yaml:"max_query_priority" json:"max_query_priority"`
 	QueryReadyIndexNumDays     int              `yaml:"query_ready_index_num_days" json:"query_ready_index_num_days"`
 	QueryTimeout               model.Duration   `yaml:"query_timeout" json:"query_timeout"`
 
@@ -127,6 +139,8 @@ type Limits struct {
 	MinShardingLookback              model.Duration   `yaml:"min_sharding_lookback" json:"min_sharding_lookback"`
 	MaxQueryBytesRead                flagext.ByteSize `yaml:"max_query_bytes_read" json:"max_query_bytes_read"`
 	MaxQuerierBytesRead              flagext.ByteSize `yaml:"max_querier_bytes_read" json:"max_querier_bytes_read"`
+	QueryBytesBudgetPerHour          flagext.ByteSize `yaml:"query_bytes_budget_per_hour" json:"query_bytes_budget_per_hour"`
+	QueryBytesBudgetPerDay           flagext.ByteSize `yaml:"query_bytes_budget_per_day" json:"query_bytes_budget_per_day"`
 	VolumeEnabled                    bool             `yaml:"volume_enabled" json:"volume_enabled" doc:"description=Enable log-volume endpoints."`
 	VolumeMaxSeries                  int              `yaml:"volume_max_series" json:"volume_max_series" doc:"description=The maximum number of aggregated series in a log-volume response"`
 
@@ -197,6 +211,8 @@ type Limits struct {
 	RequiredLabels       []string `yaml:"required_labels,omitempty" json:"required_labels,omitempty" doc:"description=Define a list of required selector labels."`
 	RequiredNumberLabels int      `yaml:"minimum_labels_number,omitempty" json:"minimum_labels_number,omitempty" doc:"description=Minimum number of label matchers a query should contain."`
 
+	LookupTables []LookupTable `yaml:"lookup_tables,omitempty" json:"lookup_tables,omitempty" doc:"description=Lookup tables used by the LogQL lookup stage to add labels from the row matching the value of a label. The format of a table is either 'csv', with a header row holding the column names, or 'json', with an array of objects."`
+
 	IndexGatewayShardSize int `yaml:"index_gateway_shard_size" json:"index_gateway_shard_size"`
 
 	BloomGatewayShardSize        int           `yaml:"bloom_gateway_shard_size" json:"bloom_gateway_shard_size" category:"experimental"`
@@ -223,6 +239,15 @@ type Limits struct {
 	OTLPConfig                        push.OTLPConfig       `yaml:"otlp_config" json:"otlp_config" doc:"description=OTLP log ingestion configurations"`
 	GlobalOTLPConfig                  push.GlobalOTLPConfig `yaml:"-" json:"-"`
 
+	StructuredMetadataSchema StructuredMetadataSchema `yaml:"structured_metadata_schema" json:"structured_metadata_schema" doc:"description=Schema of the structured metadata of the log entries, enforced by the distributor after the ingestion pipeline and the redaction."`
+
+	ElasticsearchConfig push.ElasticsearchConfig `yaml:"elasticsearch_config" json:"elasticsearch_config" doc:"description=Mapping of the documents pushed to the Elasticsearch bulk endpoint to log entries."`
+
+	IngestionPipeline []ingestionpipeline.StageConfig `yaml:"ingestion_pipeline,omitempty" json:"ingestion_pipeline,omitempty" doc:"description=Stages run by the distributor on the pushed streams before they are validated. Each stage applies to the streams matching its selector, as transformed by the previous stages. The drop and structured_metadata stages take an expression made of LogQL line filters, parsers and label filters.\nExample:\n ingestion_pipeline:\n - action: drop_labels\n labels: [pod_template_hash]\n - selector: '{app=\"noisy\"}'\n action: drop\n - action: mask\n regex: 'password=\\S+'\n replacement: 'password=***'"`
+	Redaction         redaction.Config                `yaml:"redaction" json:"redaction" doc:"description=Redaction of the sensitive values of the pushed log lines, their structured metadata and the label values of their streams, run by the distributor after the ingestion pipeline. Streams whose label values are redacted are ingested as the streams with the redacted values."`
+	LabelCardinality  cardinality.Config              `yaml:"label_cardinality" json:"label_cardinality" doc:"description=Guard of the distributor against stream labels with too many distinct values, like request IDs."`
+	SamplingRules     []sampling.Rule                 `yaml:"sampling_rules,omitempty" json:"sampling_rules,omitempty" doc:"description=Rules sampling the log lines of high-volume streams in the distributor, before their validation and deduplication. The first rule whose selector and line filter match a line applies. The sampled out lines are counted by the loki_distributor_sampled_out_lines_total metric, and the kept lines get a __sampled_rate__ structured metadata with the rate of the rule, so that the counts of these lines can be re-weighted at query time by dividing them by the rate.\nExample:\n sampling_rules:\n - selector: '{level=\"debug\"}'\n rate: 0.1"`
+
 	BlockIngestionUntil      dskit_flagext.Time `yaml:"block_ingestion_until" json:"block_ingestion_until"`
 	BlockIngestionStatusCode int                `yaml:"block_ingestion_status_code" json:"block_ingestion_status_code"`
 }
@@ -234,6 +259,19 @@ type StreamRetention struct {
 	Matchers []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
 }
 
+type OutOfOrderWindow struct {
+	Selector string            `yaml:"selector" json:"selector" doc:"description:Stream selector expression."`
+	Window   model.Duration    `yaml:"window" json:"window" doc:"description:How far behind the newest log line of the stream the log lines are accepted."`
+	Matchers []*labels.Matcher `yaml:"-" json:"-"` // populated during validation.
+}
+
+type LookupTable struct {
+	Name   string           `yaml:"name" json:"name" doc:"description:Name of the table used in the lookup stage."`
+	Format string           `yaml:"format" json:"format" doc:"description:Format of the table, either csv or json."`
+	Data   string           `yaml:"data" json:"data" doc:"description:Content of the table."`
+	Table  *log.LookupTable `yaml:"-" json:"-"` // populated during validation.
+}
+
 // LimitError are errors that do not comply with the limits specified.
 type LimitError string
 
@@ -255,6 +293,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxLabelNamesPerSeries, "validation.max-label-names-per-series", 15, "Maximum number of label names per series.")
 	f.BoolVar(&l.RejectOldSamples, "validation.reject-old-samples", true, "Whether or not old samples will be rejected.")
 	f.BoolVar(&l.IncrementDuplicateTimestamp, "validation.increment-duplicate-timestamps", false, "Alter the log line timestamp during ingestion when the timestamp is the same as the previous entry for the same stream. When enabled, if a log line in a push request has the same timestamp as the previous line for the same stream, one nanosecond is added to the log line. This will preserve the received order of log lines with the exact same timestamp when they are queried, by slightly altering their stored timestamp. NOTE: This is imperfect, because Loki accepts out of order writes, and another push request for the same stream could contain duplicate timestamps to existing entries and they will not be incremented.")
+	f.Var(&l.DeduplicationWindow, "distributor.deduplication-window", "Drop the entries pushed again to the same stream with the same timestamp and line within this window, like those sent by pairs of replicated agents or retried by clients. The entries are remembered for at least the window and at most twice as long. The identical entries of a single push request are also deduplicated. An entry is only dropped once the push of its first copy succeeded, so the copies pushed concurrently with it are kept. The entries are remembered by each distributor, so the duplicates are only dropped when they are pushed to the same distributor, like when the replicated agents are routed to the same distributor by a load balancer with session affinity on the tenant. 0 to disable.")
+	f.IntVar(&l.DeduplicationMaxKeys, "distributor.deduplication-max-keys", 100000, "Maximum number of entries remembered by each distributor for the deduplication of a tenant within its deduplication window. Once reached, the new entries are accepted without being remembered until the window rotates, so their duplicates are not dropped. 0 for no limit.")
 	l.DiscoverServiceName = []string{
 		"service",
 		"app",
@@ -277,6 +317,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.CreationGracePeriod.Set("10m")
 	f.Var(&l.CreationGracePeriod, "validation.create-grace-period", "Duration which table will be created/deleted before/after it's needed; we won't accept sample from before this time.")
 	f.IntVar(&l.MaxEntriesLimitPerQuery, "validation.max-entries-limit", 5000, "Maximum number of log entries that will be returned for a query.")
+	f.IntVar(&l.MaxStreamedEntriesLimit, "validation.max-streamed-entries-limit", 0, "Maximum number of log entries that will be returned for a query streamed with /loki/api/v1/query_range/stream, instead of max_entries_limit_per_query, since streamed queries don't hold their entries in memory. It is also the number of entries returned when the streamed query has no limit. 0 means no limit.")
 
 	f.BoolVar(&l.UseOwnedStreamCount, "ingester.use-owned-stream-count", false, "When true an ingester takes into account only the streams that it owns according to the ring while applying the stream limit.")
 	f.IntVar(&l.MaxLocalStreamsPerUser, "ingester.max-streams-per-user", 0, "Maximum number of active streams per user, per ingester. 0 to disable.")
@@ -328,6 +369,9 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	_ = l.MaxQuerierBytesRead.Set("150GB")
 	f.Var(&l.MaxQuerierBytesRead, "frontend.max-querier-bytes-read", "Max number of bytes a query can fetch after splitting and sharding. Enforced in log and metric queries only when TSDB is used. This limit is not enforced on log queries without filters. The default value of 0 disables this limit.")
 
+	f.Var(&l.QueryBytesBudgetPerHour, "frontend.query-bytes-budget-per-hour", "Max number of bytes the queries of a tenant can fetch over a rolling hour, as estimated from the index stats before running them. The whole estimate of a query is reserved before the query is split, and queries exceeding the remaining budget are rejected with a 429 status code. Once the query is done, the share of the splits served by the results cache is given back, and all of it if the query failed. Budgets are tracked by each query-frontend independently. Enforced in log and metric queries only when TSDB is used. This limit is not enforced on log queries without filters. The default value of 0 disables this limit.")
+	f.Var(&l.QueryBytesBudgetPerDay, "frontend.query-bytes-budget-per-day", "Max number of bytes the queries of a tenant can fetch over a rolling day, as estimated from the index stats before running them. The whole estimate of a query is reserved before the query is split, and queries exceeding the remaining budget are rejected with a 429 status code. Once the query is done, the share of the splits served by the results cache is given back, and all of it if the query failed. Budgets are tracked by each query-frontend independently. Enforced in log and metric queries only when TSDB is used. This limit is not enforced on log queries without filters. The default value of 0 disables this limit.")
+
 	_ = l.MaxCacheFreshness.Set("10m")
 	f.Var(&l.MaxCacheFreshness, "frontend.max-cache-freshness", "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
 
@@ -339,6 +383,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 
 	f.UintVar(&l.MaxQueriersPerTenant, "frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
 	f.Float64Var(&l.MaxQueryCapacity, "frontend.max-query-capacity", 0, "How much of the available query capacity (\"querier\" components in distributed mode, \"read\" components in SSD mode) can be used by a single tenant. Allowed values are 0.0 to 1.0. For example, setting this to 0.5 would allow a tenant to use half of the available queriers for processing the query workload. If set to 0, query capacity is determined by frontend.max-queriers-per-tenant. When both frontend.max-queriers-per-tenant and frontend.max-query-capacity are configured, smaller value of the resulting querier replica count is considered: min(frontend.max-queriers-per-tenant, ceil(querier_replicas * frontend.max-query-capacity)). *All* queriers will handle requests for the tenant if neither limits are applied. This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL. Use this feature in a multi-tenant setup where you need to limit query capacity for certain tenants.")
+	f.StringVar(&l.MaxQueryPriority, "query-scheduler.max-query-priority", "normal", "Highest priority class a tenant can give to its queries with the X-Loki-Query-Priority header, one of low, normal or high. Queries asking for a higher priority are enqueued with this priority class. This also applies to the high priority queries evaluated remotely by the ruler, so the tenants whose alerting rules must be prioritized need this limit set to high.")
 	f.IntVar(&l.QueryReadyIndexNumDays, "store.query-ready-index-num-days", 0, "Number of days of index to be kept always downloaded for queries. Applies only to per user index in boltdb-shipper index store. 0 to disable.")
 
 	f.IntVar(&l.RulerMaxRulesPerRuleGroup, "ruler.max-rules-per-rule-group", 0, "Maximum number of rules per rule group per-tenant. 0 to disable.")
@@ -470,6 +515,27 @@ func (l *Limits) Validate() error {
 		}
 	}
 
+	for i, rule := range l.OutOfOrderWindows {
+		matchers, err := syntax.ParseMatchers(rule.Selector, true)
+		if err != nil {
+			return fmt.Errorf("invalid out-of-order window selector: %w", err)
+		}
+		if rule.Window < 0 {
+			return fmt.Errorf("out-of-order window must be >= 0 was %s", rule.Window)
+		}
+		// populate matchers during validation
+		l.OutOfOrderWindows[i].Matchers = matchers
+	}
+
+	for i, lt := range l.LookupTables {
+		table, err := log.ParseLookupTable(lt.Format, lt.Data)
+		if err != nil {
+			return fmt.Errorf("invalid lookup table %s: %w", lt.Name, err)
+		}
+		// populate the table during validation
+		l.LookupTables[i].Table = table
+	}
+
 	if _, err := deletionmode.ParseMode(l.DeletionMode); err != nil {
 		return err
 	}
@@ -488,10 +554,55 @@ func (l *Limits) Validate() error {
 		l.MaxQueryCapacity = 1
 	}
 
+	if _, err := queue.ParsePriority(l.MaxQueryPriority); err != nil {
+		return fmt.Errorf("invalid max query priority: %w", err)
+	}
+
 	if err := l.OTLPConfig.Validate(); err != nil {
 		return err
 	}
 
+	if err := l.ElasticsearchConfig.Validate(); err != nil {
+		return err
+	}
+
+	if err := l.StructuredMetadataSchema.Validate(); err != nil {
+		return err
+	}
+	for _, key := range l.StructuredMetadataSchema.Keys {
+		if key.Required && !l.AllowStructuredMetadata {
+			return fmt.Errorf("the structured metadata schema requires the key %q, but structured metadata is not allowed", key.Name)
+		}
+	}
+
+	if err := l.Redaction.Compile(); err != nil {
+		return err
+	}
+
+	if err := l.LabelCardinality.Validate(); err != nil {
+		return err
+	}
+	if l.LabelCardinality.Mode == cardinality.ModeDemote && !l.AllowStructuredMetadata {
+		return fmt.Errorf("the %s label cardinality mode requires structured metadata to be allowed", cardinality.ModeDemote)
+	}
+
+	for i := range l.SamplingRules {
+		// compile the rules during validation
+		if err := l.SamplingRules[i].Compile(); err != nil {
+			return fmt.Errorf("invalid sampling rule %d: %w", i, err)
+		}
+	}
+	if len(l.SamplingRules) > 0 && !l.AllowStructuredMetadata {
+		return errors.New("the sampling rules require structured metadata to be allowed")
+	}
+
+	for i := range l.IngestionPipeline {
+		// compile the stages during validation
+		if err := l.IngestionPipeline[i].Compile(); err != nil {
+			return fmt.Errorf("invalid ingestion pipeline stage %d: %w", i, err)
+		}
+	}
+
 	if _, err := logql.ParseShardVersion(l.TSDBShardingStrategy); err != nil {
 		return errors.Wrap(err, "invalid tsdb sharding strategy")
 	}
@@ -646,6 +757,11 @@ func (o *Overrides) MaxQueriersPerUser(userID string) uint {
 	return o.getOverridesForUser(userID).MaxQueriersPerTenant
 }
 
+// MaxQueryPriority returns the highest priority class the queries of this user can be enqueued with.
+func (o *Overrides) MaxQueryPriority(userID string) string {
+	return o.getOverridesForUser(userID).MaxQueryPriority
+}
+
 // MaxQueryCapacity returns how much of the available query capacity can be used by this user..
 func (o *Overrides) MaxQueryCapacity(userID string) float64 {
 	return o.getOverridesForUser(userID).MaxQueryCapacity
@@ -738,6 +854,16 @@ func (o *Overrides) MaxQuerierBytesRead(_ context.Context, userID string) int {
 	return o.getOverridesForUser(userID).MaxQuerierBytesRead.Val()
 }
 
+// QueryBytesBudgetPerHour returns the maximum bytes the queries of a tenant can read over a rolling hour.
+func (o *Overrides) QueryBytesBudgetPerHour(_ context.Context, userID string) int {
+	return o.getOverridesForUser(userID).QueryBytesBudgetPerHour.Val()
+}
+
+// QueryBytesBudgetPerDay returns the maximum bytes the queries of a tenant can read over a rolling day.
+func (o *Overrides) QueryBytesBudgetPerDay(_ context.Context, userID string) int {
+	return o.getOverridesForUser(userID).QueryBytesBudgetPerDay.Val()
+}
+
 // MaxConcurrentTailRequests returns the limit to number of concurrent tail requests.
 func (o *Overrides) MaxConcurrentTailRequests(_ context.Context, userID string) int {
 	return o.getOverridesForUser(userID).MaxConcurrentTailRequests
@@ -758,6 +884,11 @@ func (o *Overrides) MaxEntriesLimitPerQuery(_ context.Context, userID string) in
 	return o.getOverridesForUser(userID).MaxEntriesLimitPerQuery
 }
 
+// MaxStreamedEntriesLimitPerQuery returns the limit to number of entries the querier should return per streamed query.
+func (o *Overrides) MaxStreamedEntriesLimitPerQuery(_ context.Context, userID string) int {
+	return o.getOverridesForUser(userID).MaxStreamedEntriesLimit
+}
+
 func (o *Overrides) QueryTimeout(_ context.Context, userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).QueryTimeout)
 }
@@ -920,6 +1051,11 @@ func (o *Overrides) UnorderedWrites(userID string) bool {
 	return o.getOverridesForUser(userID).UnorderedWrites
 }
 
+// OutOfOrderWindows returns the per-stream out-of-order windows for a given user.
+func (o *Overrides) OutOfOrderWindows(userID string) []OutOfOrderWindow {
+	return o.getOverridesForUser(userID).OutOfOrderWindows
+}
+
 func (o *Overrides) DeletionMode(userID string) string {
 	return o.getOverridesForUser(userID).DeletionMode
 }
@@ -940,6 +1076,16 @@ func (o *Overrides) RequiredNumberLabels(_ context.Context, userID string) int {
 	return o.getOverridesForUser(userID).RequiredNumberLabels
 }
 
+// LookupTable returns the lookup table with the given name for a given user.
+func (o *Overrides) LookupTable(userID, name string) (*log.LookupTable, bool) {
+	for _, lt := range o.getOverridesForUser(userID).LookupTables {
+		if lt.Name == name && lt.Table != nil {
+			return lt.Table, true
+		}
+	}
+	return nil, false
+}
+
 func (o *Overrides) DefaultLimits() *Limits {
 	return o.defaultLimits
 }
@@ -957,6 +1103,14 @@ func (o *Overrides) IncrementDuplicateTimestamps(userID string) bool {
 	return o.getOverridesForUser(userID).IncrementDuplicateTimestamp
 }
 
+func (o *Overrides) DeduplicationWindow(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).DeduplicationWindow)
+}
+
+func (o *Overrides) DeduplicationMaxKeys(userID string) int {
+	return o.getOverridesForUser(userID).DeduplicationMaxKeys
+}
+
 func (o *Overrides) DiscoverServiceName(userID string) []string {
 	return o.getOverridesForUser(userID).DiscoverServiceName
 }
@@ -1046,10 +1200,34 @@ func (o *Overrides) MaxStructuredMetadataCount(userID string) int {
 	return o.getOverridesForUser(userID).MaxStructuredMetadataEntriesCount
 }
 
+func (o *Overrides) StructuredMetadataSchema(userID string) StructuredMetadataSchema {
+	return o.getOverridesForUser(userID).StructuredMetadataSchema
+}
+
 func (o *Overrides) OTLPConfig(userID string) push.OTLPConfig {
 	return o.getOverridesForUser(userID).OTLPConfig
 }
 
+func (o *Overrides) ElasticsearchConfig(userID string) push.ElasticsearchConfig {
+	return o.getOverridesForUser(userID).ElasticsearchConfig
+}
+
+func (o *Overrides) IngestionPipeline(userID string) []ingestionpipeline.StageConfig {
+	return o.getOverridesForUser(userID).IngestionPipeline
+}
+
+func (o *Overrides) Redaction(userID string) redaction.Config {
+	return o.getOverridesForUser(userID).Redaction
+}
+
+func (o *Overrides) LabelCardinality(userID string) cardinality.Config {
+	return o.getOverridesForUser(userID).LabelCardinality
+}
+
+func (o *Overrides) SamplingRules(userID string) []sampling.Rule {
+	return o.getOverridesForUser(userID).SamplingRules
+}
+
 func (o *Overrides) BlockIngestionUntil(userID string) time.Time {
 	return time.Time(o.getOverridesForUser(userID).BlockIngestionUntil)
 }
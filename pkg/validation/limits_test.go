@@ -16,6 +16,7 @@ import (
 	"github.com/grafana/loki/v3/pkg/compactor/deletionmode"
 	"github.com/grafana/loki/v3/pkg/loghttp/push"
 	"github.com/grafana/loki/v3/pkg/logql"
+	"github.com/grafana/loki/v3/pkg/logql/log"
 )
 
 func TestLimitsTagsYamlMatchJson(t *testing.T) {
@@ -354,3 +355,43 @@ func TestLimitsValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestLimitsLookupTables(t *testing.T) {
+	inputYAML := `
+lookup_tables:
+  - name: ownership
+    format: csv
+    data: |
+      service,team
+      api,platform
+  - name: owners
+    format: json
+    data: '[{"service":"web","owner":"bob"}]'
+`
+	limits := Limits{}
+	require.NoError(t, yaml.Unmarshal([]byte(inputYAML), &limits))
+	limits.DeletionMode = "disabled"
+	limits.BloomBlockEncoding = "none"
+	limits.TSDBShardingStrategy = logql.PowerOfTwoVersion.String()
+	limits.TSDBMaxBytesPerShard = DefaultTSDBMaxBytesPerShard
+	require.NoError(t, limits.Validate())
+
+	overrides, err := NewOverrides(Limits{}, newMockTenantLimits(map[string]*Limits{"tenant": &limits}))
+	require.NoError(t, err)
+
+	table, ok := overrides.LookupTable("tenant", "ownership")
+	require.True(t, ok)
+	expected, err := log.NewLookupTable([]string{"service", "team"}, [][]string{{"api", "platform"}})
+	require.NoError(t, err)
+	require.Equal(t, expected, table)
+
+	_, ok = overrides.LookupTable("tenant", "owners")
+	require.True(t, ok)
+	_, ok = overrides.LookupTable("tenant", "unknown")
+	require.False(t, ok)
+	_, ok = overrides.LookupTable("other", "ownership")
+	require.False(t, ok)
+
+	limits.LookupTables[0].Data = "service,team\napi\n"
+	require.ErrorContains(t, limits.Validate(), "invalid lookup table ownership")
+}
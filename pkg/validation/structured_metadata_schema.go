@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/relabel"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/distributor/sampling"
+)
+
+// StructuredMetadataSchema restricts the structured metadata of the log entries of a tenant.
+type StructuredMetadataSchema struct {
+	Keys             []StructuredMetadataKey `yaml:"keys,omitempty" json:"keys,omitempty" doc:"description=Keys of the structured metadata accepted by the schema. Any key is accepted when empty."`
+	AllowUnknownKeys bool                    `yaml:"allow_unknown_keys,omitempty" json:"allow_unknown_keys,omitempty" doc:"description=Accept the structured metadata whose keys are not part of the schema, instead of discarding their entries. The __sampled_rate__ structured metadata added by the sampling rules is always accepted."`
+	MaxValueLength   int                     `yaml:"max_value_length,omitempty" json:"max_value_length,omitempty" doc:"description=Maximum length of the values of the structured metadata, including those of unknown keys. 0 to disable."`
+}
+
+// StructuredMetadataKey is a key of a structured metadata schema.
+type StructuredMetadataKey struct {
+	Name     string         `yaml:"name" json:"name" doc:"description=Name of the key."`
+	Regex    relabel.Regexp `yaml:"regex,omitempty" json:"regex,omitempty" doc:"description=Regex the values of the key must fully match. Any value is accepted when empty."`
+	Required bool           `yaml:"required,omitempty" json:"required,omitempty" doc:"description=Discard the entries without this key."`
+}
+
+// Enabled tells if the schema has any key or limits the length of the values.
+func (s *StructuredMetadataSchema) Enabled() bool {
+	return len(s.Keys) > 0 || s.MaxValueLength > 0
+}
+
+func (s *StructuredMetadataSchema) Validate() error {
+	names := make(map[string]struct{}, len(s.Keys))
+	for _, key := range s.Keys {
+		if !model.LabelName(key.Name).IsValid() {
+			return fmt.Errorf("invalid structured metadata schema key %q", key.Name)
+		}
+		if _, ok := names[key.Name]; ok {
+			return fmt.Errorf("duplicate structured metadata schema key %q", key.Name)
+		}
+		names[key.Name] = struct{}{}
+	}
+	if s.MaxValueLength < 0 {
+		return errors.New("the maximum length of the structured metadata values must not be negative")
+	}
+	return nil
+}
+
+// ValidateEntry returns the reason and an error if the structured metadata of an entry of the stream doesn't match the schema.
+func (s *StructuredMetadataSchema) ValidateEntry(stream string, structuredMetadata push.LabelsAdapter) (string, error) {
+	for _, metadata := range structuredMetadata {
+		if s.MaxValueLength > 0 && len(metadata.Value) > s.MaxValueLength {
+			return StructuredMetadataValueTooLong, fmt.Errorf(StructuredMetadataValueTooLongErrorMsg, stream, metadata.Name, len(metadata.Value), s.MaxValueLength)
+		}
+
+		key := s.key(metadata.Name)
+		if key == nil {
+			// the sampled rate is added by the sampling rules of the tenant.
+			if s.AllowUnknownKeys || len(s.Keys) == 0 || metadata.Name == sampling.SampledRateLabel {
+				continue
+			}
+			return StructuredMetadataKeyNotAllowed, fmt.Errorf(StructuredMetadataKeyNotAllowedErrorMsg, stream, metadata.Name)
+		}
+		if key.Regex.Regexp != nil && !key.Regex.MatchString(metadata.Value) {
+			return StructuredMetadataInvalidValue, fmt.Errorf(StructuredMetadataInvalidValueErrorMsg, stream, metadata.Name, metadata.Value, key.Regex.String())
+		}
+	}
+
+	for _, key := range s.Keys {
+		if key.Required && !hasStructuredMetadata(structuredMetadata, key.Name) {
+			return StructuredMetadataMissingKey, fmt.Errorf(StructuredMetadataMissingKeyErrorMsg, stream, key.Name)
+		}
+	}
+	return "", nil
+}
+
+func (s *StructuredMetadataSchema) key(name string) *StructuredMetadataKey {
+	for i := range s.Keys {
+		if s.Keys[i].Name == name {
+			return &s.Keys[i]
+		}
+	}
+	return nil
+}
+
+func hasStructuredMetadata(structuredMetadata push.LabelsAdapter, name string) bool {
+	for _, metadata := range structuredMetadata {
+		if metadata.Name == name {
+			return true
+		}
+	}
+	return false
+}
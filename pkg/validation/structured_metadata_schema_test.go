@@ -0,0 +1,90 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/loki/pkg/push"
+
+	"github.com/grafana/loki/v3/pkg/distributor/sampling"
+)
+
+func TestStructuredMetadataSchema(t *testing.T) {
+	var limits Limits
+	require.NoError(t, yaml.UnmarshalStrict([]byte(`
+structured_metadata_schema:
+  keys:
+    - name: trace_id
+      regex: '[0-9a-f]{32}'
+      required: true
+    - name: user
+  allow_unknown_keys: true
+  max_value_length: 64
+`), &limits))
+	schema := limits.StructuredMetadataSchema
+	require.NoError(t, schema.Validate())
+	require.True(t, schema.Enabled())
+
+	reason, err := schema.ValidateEntry(`{app="shop"}`, push.LabelsAdapter{
+		{Name: "trace_id", Value: "0af7651916cd43dd8448eb211c80319c"},
+		{Name: "pod", Value: "shop-1"},
+	})
+	require.NoError(t, err, "unknown keys are allowed")
+	require.Empty(t, reason)
+
+	reason, err = schema.ValidateEntry(`{app="shop"}`, push.LabelsAdapter{{Name: "trace_id", Value: "0af7651916cd43dd8448eb211c80319c0000"}})
+	require.EqualError(t, err, `stream '{app="shop"}' has structured metadata 'trace_id' with value '0af7651916cd43dd8448eb211c80319c0000' not matching the schema regex '[0-9a-f]{32}'. Please see `+"`limits_config.structured_metadata_schema`"+` or contact your Loki administrator.`)
+	require.Equal(t, StructuredMetadataInvalidValue, reason)
+
+	reason, err = schema.ValidateEntry(`{app="shop"}`, nil)
+	require.Error(t, err)
+	require.Equal(t, StructuredMetadataMissingKey, reason)
+}
+
+func TestStructuredMetadataSchema_MaxValueLengthOnly(t *testing.T) {
+	schema := StructuredMetadataSchema{MaxValueLength: 8}
+	require.NoError(t, schema.Validate())
+	require.True(t, schema.Enabled(), "the length of the values is limited without any key")
+
+	reason, err := schema.ValidateEntry(`{app="shop"}`, push.LabelsAdapter{{Name: "pod", Value: "shop-1"}})
+	require.NoError(t, err, "any key is accepted without keys")
+	require.Empty(t, reason)
+
+	reason, err = schema.ValidateEntry(`{app="shop"}`, push.LabelsAdapter{{Name: "pod", Value: "shop-123456"}})
+	require.Error(t, err)
+	require.Equal(t, StructuredMetadataValueTooLong, reason)
+
+	require.False(t, (&StructuredMetadataSchema{AllowUnknownKeys: true}).Enabled())
+}
+
+func TestStructuredMetadataSchema_SampledRate(t *testing.T) {
+	schema := StructuredMetadataSchema{Keys: []StructuredMetadataKey{{Name: "user"}}}
+
+	reason, err := schema.ValidateEntry(`{app="shop"}`, push.LabelsAdapter{{Name: sampling.SampledRateLabel, Value: "0.1"}})
+	require.NoError(t, err, "the sampled rate is always accepted")
+	require.Empty(t, reason)
+}
+
+func TestStructuredMetadataSchema_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		schema StructuredMetadataSchema
+		err    string
+	}{
+		{name: "disabled"},
+		{name: "invalid key", schema: StructuredMetadataSchema{Keys: []StructuredMetadataKey{{Name: "trace.id"}}}, err: `invalid structured metadata schema key "trace.id"`},
+		{name: "duplicate key", schema: StructuredMetadataSchema{Keys: []StructuredMetadataKey{{Name: "user"}, {Name: "user"}}}, err: `duplicate structured metadata schema key "user"`},
+		{name: "negative length", schema: StructuredMetadataSchema{MaxValueLength: -1}, err: "the maximum length of the structured metadata values must not be negative"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.schema.Validate()
+			if tc.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.err)
+		})
+	}
+}
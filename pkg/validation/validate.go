@@ -43,6 +43,11 @@ const (
 	// half of `-ingester.max-chunk-age` compared to the newest line in the
 	// stream.
 	TooFarBehind = "too_far_behind"
+	// TooFarBehindStreamWindow is a reason for discarding lines when Loki accepts
+	// unordered ingest and the lines in question are older than the newest line
+	// in the stream by more than the out-of-order window of the first rule of
+	// `out_of_order_windows` matching the stream.
+	TooFarBehindStreamWindow = "too_far_behind_stream_window"
 	// GreaterThanMaxSampleAge is a reason for discarding log lines which are older than the current time - `reject_old_samples_max_age`
 	GreaterThanMaxSampleAge         = "greater_than_max_sample_age"
 	GreaterThanMaxSampleAgeErrorMsg = "entry for stream '%s' has timestamp too old: %v, oldest acceptable timestamp is: %v"
@@ -69,6 +74,21 @@ const (
 	StructuredMetadataTooManyErrorMsg    = "stream '%s' has too many structured metadata labels: '%d', limit: '%d'. Please see `limits_config.max_structured_metadata_entries_count` or contact your Loki administrator to increase it."
 	BlockedIngestion                     = "blocked_ingestion"
 	BlockedIngestionErrorMsg             = "ingestion blocked for user %s until '%s' with status code '%d'"
+	// StructuredMetadataKeyNotAllowed, StructuredMetadataMissingKey, StructuredMetadataInvalidValue and StructuredMetadataValueTooLong
+	// are reasons for discarding log lines whose structured metadata doesn't match the structured metadata schema of the tenant.
+	StructuredMetadataKeyNotAllowed         = "structured_metadata_key_not_allowed"
+	StructuredMetadataKeyNotAllowedErrorMsg = "stream '%s' has structured metadata key '%s' which is not part of the schema. Please see `limits_config.structured_metadata_schema` or contact your Loki administrator."
+	StructuredMetadataMissingKey            = "structured_metadata_missing_key"
+	StructuredMetadataMissingKeyErrorMsg    = "stream '%s' is missing the required structured metadata key '%s'. Please see `limits_config.structured_metadata_schema` or contact your Loki administrator."
+	StructuredMetadataInvalidValue          = "structured_metadata_invalid_value"
+	StructuredMetadataInvalidValueErrorMsg  = "stream '%s' has structured metadata '%s' with value '%s' not matching the schema regex '%s'. Please see `limits_config.structured_metadata_schema` or contact your Loki administrator."
+	StructuredMetadataValueTooLong          = "structured_metadata_value_too_long"
+	StructuredMetadataValueTooLongErrorMsg  = "stream '%s' has structured metadata '%s' with value too long: '%d' bytes, limit: '%d' bytes. Please see `limits_config.structured_metadata_schema` or contact your Loki administrator."
+	// IngestionPipeline is a reason for discarding log lines dropped by the ingestion pipeline of the tenant.
+	IngestionPipeline = "ingestion_pipeline"
+	// LabelCardinalityLimit is a reason for discarding log lines whose stream has labels with too many distinct values.
+	LabelCardinalityLimit         = "label_cardinality_limit"
+	LabelCardinalityLimitErrorMsg = "stream '%s' has labels with more than %d distinct values: %s. Please see `limits_config.label_cardinality` or contact your Loki administrator."
 )
 
 type ErrStreamRateLimit struct {